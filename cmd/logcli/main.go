@@ -16,6 +16,7 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/grafana/loki/v3/pkg/logcli/client"
+	"github.com/grafana/loki/v3/pkg/logcli/deletion"
 	"github.com/grafana/loki/v3/pkg/logcli/detected"
 	"github.com/grafana/loki/v3/pkg/logcli/index"
 	"github.com/grafana/loki/v3/pkg/logcli/labelquery"
@@ -287,6 +288,20 @@ The query is limited to processing 1000 lines per subquery; use --line-limit to
 `)
 
 	detectedFieldsQuery = newDetectedFieldsQuery(detectedFieldsCmd)
+
+	deleteStatusCmd = app.Command("delete-status", `Show the status of a delete request.
+
+The "delete-status" command returns the status and progress of a delete request,
+who requested it, the timestamps of its processing by the compactor and its audit log.
+Cancelled delete requests are only kept in their audit log.
+
+The delete requests API is served by the compactor, set --addr to its address.
+
+Example:
+
+	logcli delete-status --addr=http://compactor:3100 --org-id=tenant1 d1e2f3a4
+`)
+	deleteStatusQuery = newDeleteStatusQuery(deleteStatusCmd)
 )
 
 func main() {
@@ -424,6 +439,14 @@ func main() {
 		}
 	case detectedFieldsCmd.FullCommand():
 		detectedFieldsQuery.Do(queryClient, *outputMode)
+	case deleteStatusCmd.FullCommand():
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			log.Fatalf("Unable to load timezone '%s': %s", *timezone, err)
+		}
+
+		deleteStatusQuery.Timezone = location
+		deleteStatusQuery.Do(queryClient, *outputMode)
 	}
 }
 
@@ -484,6 +507,7 @@ func newQueryClient(app *kingpin.Application) client.Client {
 	app.Flag("auth-header", "The authorization header used. Can also be set using LOKI_AUTH_HEADER env var.").Default("Authorization").Envar("LOKI_AUTH_HEADER").StringVar(&client.AuthHeader)
 	app.Flag("proxy-url", "The http or https proxy to use when making requests. Can also be set using LOKI_HTTP_PROXY_URL env var.").Default("").Envar("LOKI_HTTP_PROXY_URL").StringVar(&client.ProxyURL)
 	app.Flag("compress", "Request that Loki compress returned data in transit. Can also be set using LOKI_HTTP_COMPRESSION env var.").Default("false").Envar("LOKI_HTTP_COMPRESSION").BoolVar(&client.Compression)
+	app.Flag("stream", "Request that Loki streams the results of log queries as they become available instead of buffering the whole response. Can also be set using LOKI_STREAM env var.").Default("false").Envar("LOKI_STREAM").BoolVar(&client.Stream)
 
 	return client
 }
@@ -732,3 +756,17 @@ func newDetectedFieldsQuery(cmd *kingpin.CmdClause) *detected.FieldsQuery {
 
 	return q
 }
+
+func newDeleteStatusQuery(cmd *kingpin.CmdClause) *deletion.StatusQuery {
+	q := &deletion.StatusQuery{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(_ *kingpin.ParseContext) error {
+		q.Quiet = *quiet
+		return nil
+	})
+
+	cmd.Arg("request_id", "The ID of the delete request.").Required().StringVar(&q.RequestID)
+
+	return q
+}
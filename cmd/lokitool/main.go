@@ -12,14 +12,16 @@ import (
 )
 
 var (
-	ruleCommand  commands.RuleCommand
-	auditCommand commands.AuditCommand
+	ruleCommand    commands.RuleCommand
+	auditCommand   commands.AuditCommand
+	migrateCommand commands.MigrateCommand
 )
 
 func main() {
 	app := kingpin.New("lokitool", "A command-line tool to manage Loki.")
 	ruleCommand.Register(app)
 	auditCommand.Register(app)
+	migrateCommand.Register(app)
 
 	app.Command("version", "Get the version of the lokitool CLI").Action(func(_ *kingpin.ParseContext) error {
 		fmt.Println(version.Print("loki"))
@@ -75,3 +75,8 @@ func (c *QueueLimits) MaxConsumers(tenantID string, allConsumers int) int {
 
 	return min(allConsumers, maxBuilders)
 }
+
+// QueueWeight returns the same weight for all tenants, so tasks are dequeued round-robin across tenants.
+func (c *QueueLimits) QueueWeight(_ string) int {
+	return 1
+}
@@ -72,6 +72,10 @@ func (l *fixedQueueLimits) MaxConsumers(_ string, _ int) int {
 	return l.maxConsumers
 }
 
+func (l *fixedQueueLimits) QueueWeight(_ string) int {
+	return 1
+}
+
 // New returns a new instance of the Bloom Gateway.
 func New(cfg Config, store bloomshipper.Store, logger log.Logger, reg prometheus.Registerer) (*Gateway, error) {
 	utillog.WarnExperimentalUse("Bloom Gateway", logger)
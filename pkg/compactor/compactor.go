@@ -9,14 +9,18 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"google.golang.org/grpc"
 
 	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/grpcclient"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
@@ -24,12 +28,15 @@ import (
 	"github.com/grafana/loki/v3/pkg/analytics"
 	"github.com/grafana/loki/v3/pkg/compactor/deletion"
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compactor/rollup"
+	"github.com/grafana/loki/v3/pkg/compactor/tiering"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
 	chunk_util "github.com/grafana/loki/v3/pkg/storage/chunk/client/util"
 	"github.com/grafana/loki/v3/pkg/storage/config"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/storage"
 	"github.com/grafana/loki/v3/pkg/util/filter"
+	"github.com/grafana/loki/v3/pkg/util/flagext"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
 	"github.com/grafana/loki/v3/pkg/validation"
@@ -72,25 +79,32 @@ var (
 )
 
 type Config struct {
-	WorkingDirectory            string              `yaml:"working_directory"`
-	CompactionInterval          time.Duration       `yaml:"compaction_interval"`
-	ApplyRetentionInterval      time.Duration       `yaml:"apply_retention_interval"`
-	RetentionEnabled            bool                `yaml:"retention_enabled"`
-	RetentionDeleteDelay        time.Duration       `yaml:"retention_delete_delay"`
-	RetentionDeleteWorkCount    int                 `yaml:"retention_delete_worker_count"`
-	RetentionTableTimeout       time.Duration       `yaml:"retention_table_timeout"`
-	RetentionBackoffConfig      backoff.Config      `yaml:"retention_backoff_config"`
-	DeleteRequestStore          string              `yaml:"delete_request_store"`
-	DeleteRequestStoreKeyPrefix string              `yaml:"delete_request_store_key_prefix"`
-	DeleteBatchSize             int                 `yaml:"delete_batch_size"`
-	DeleteRequestCancelPeriod   time.Duration       `yaml:"delete_request_cancel_period"`
-	DeleteMaxInterval           time.Duration       `yaml:"delete_max_interval"`
-	MaxCompactionParallelism    int                 `yaml:"max_compaction_parallelism"`
-	UploadParallelism           int                 `yaml:"upload_parallelism"`
-	CompactorRing               lokiring.RingConfig `yaml:"compactor_ring,omitempty" doc:"description=The hash ring configuration used by compactors to elect a single instance for running compactions. The CLI flags prefix for this block config is: compactor.ring"`
-	RunOnce                     bool                `yaml:"_" doc:"hidden"`
-	TablesToCompact             int                 `yaml:"tables_to_compact"`
-	SkipLatestNTables           int                 `yaml:"skip_latest_n_tables"`
+	WorkingDirectory             string              `yaml:"working_directory"`
+	CompactionInterval           time.Duration       `yaml:"compaction_interval"`
+	ApplyRetentionInterval       time.Duration       `yaml:"apply_retention_interval"`
+	RetentionEnabled             bool                `yaml:"retention_enabled"`
+	RetentionDeleteDelay         time.Duration       `yaml:"retention_delete_delay"`
+	RetentionDeleteWorkCount     int                 `yaml:"retention_delete_worker_count"`
+	RetentionTableTimeout        time.Duration       `yaml:"retention_table_timeout"`
+	RetentionBackoffConfig       backoff.Config      `yaml:"retention_backoff_config"`
+	DeleteRequestStore           string              `yaml:"delete_request_store"`
+	DeleteRequestStoreKeyPrefix  string              `yaml:"delete_request_store_key_prefix"`
+	DeleteBatchSize              int                 `yaml:"delete_batch_size"`
+	DeleteRequestCancelPeriod    time.Duration       `yaml:"delete_request_cancel_period"`
+	DeleteMaxInterval            time.Duration       `yaml:"delete_max_interval"`
+	DeleteDryRunSampledChunks    int                 `yaml:"delete_dry_run_sampled_chunks"`
+	DeleteRequestRequesterHeader string              `yaml:"delete_request_requester_header"`
+	MaxCompactionParallelism     int                 `yaml:"max_compaction_parallelism"`
+	UploadParallelism            int                 `yaml:"upload_parallelism"`
+	CompactorRing                lokiring.RingConfig `yaml:"compactor_ring,omitempty" doc:"description=The hash ring configuration used by compactors to elect a single instance for running compactions. The CLI flags prefix for this block config is: compactor.ring"`
+	ShardingEnabled              bool                `yaml:"sharding_enabled"`
+	LeaderClient                 grpcclient.Config   `yaml:"leader_client" doc:"description=Configures the gRPC client used by the compactors to forward the requests to the leader when they share the tables."`
+	RunOnce                      bool                `yaml:"_" doc:"hidden"`
+	TablesToCompact              int                 `yaml:"tables_to_compact"`
+	SkipLatestNTables            int                 `yaml:"skip_latest_n_tables"`
+	SmallChunksMergeThreshold    flagext.ByteSize    `yaml:"small_chunks_merge_threshold"`
+	SmallChunksMergeTargetSize   flagext.ByteSize    `yaml:"small_chunks_merge_target_size"`
+	Tiering                      tiering.Config      `yaml:"tiering"`
 }
 
 // RegisterFlags registers flags.
@@ -106,6 +120,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.DeleteBatchSize, "compactor.delete-batch-size", 70, "The max number of delete requests to run per compaction cycle.")
 	f.DurationVar(&cfg.DeleteRequestCancelPeriod, "compactor.delete-request-cancel-period", 24*time.Hour, "Allow cancellation of delete request until duration after they are created. Data would be deleted only after delete requests have been older than this duration. Ideally this should be set to at least 24h.")
 	f.DurationVar(&cfg.DeleteMaxInterval, "compactor.delete-max-interval", 24*time.Hour, "Constrain the size of any single delete request with line filters. When a delete request > delete_max_interval is input, the request is sharded into smaller requests of no more than delete_max_interval")
+	f.IntVar(&cfg.DeleteDryRunSampledChunks, "compactor.delete-dry-run-sampled-chunks", 100, "The max number of chunks downloaded to estimate the lines deleted by a delete request with the dry-run endpoint.")
+	f.StringVar(&cfg.DeleteRequestRequesterHeader, "compactor.delete-request-requester-header", "X-Grafana-User", "HTTP header holding who adds or cancels a delete request, recorded in the audit log of the request. The username of the basic auth credentials is recorded when the header is not set. Loki does not authenticate the header nor the credentials: they must be set by an authenticating gateway that removes them from the client requests, otherwise the recorded requester can be spoofed. Set to empty to only record the username of the basic auth credentials.")
 	f.DurationVar(&cfg.RetentionTableTimeout, "compactor.retention-table-timeout", 0, "The maximum amount of time to spend running retention and deletion on any given table in the index.")
 	f.IntVar(&cfg.MaxCompactionParallelism, "compactor.max-compaction-parallelism", 1, "Maximum number of tables to compact in parallel. While increasing this value, please make sure compactor has enough disk space allocated to be able to store and compact as many tables.")
 	f.IntVar(&cfg.UploadParallelism, "compactor.upload-parallelism", 10, "Number of upload/remove operations to execute in parallel when finalizing a compaction. NOTE: This setting is per compaction operation, which can be executed in parallel. The upper bound on the number of concurrent uploads is upload_parallelism * max_compaction_parallelism.")
@@ -113,7 +129,11 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.TablesToCompact, "compactor.tables-to-compact", 0, "Number of tables that compactor will try to compact. Newer tables are chosen when this is less than the number of tables available.")
 	f.IntVar(&cfg.SkipLatestNTables, "compactor.skip-latest-n-tables", 0, "Do not compact N latest tables. Together with -compactor.run-once and -compactor.tables-to-compact, this is useful when clearing compactor backlogs.")
 
+	f.Var(&cfg.SmallChunksMergeThreshold, "compactor.small-chunks-merge-threshold", "Chunks smaller than this size are merged with the adjacent small chunks of their stream while applying retention, once their index table is older than a day. The size of the chunks is read from the TSDB index, which records their uncompressed size. 0 disables merging. Requires retention to be enabled. A unit suffix (KB, MB, GB) may be applied.")
+	cfg.SmallChunksMergeTargetSize = flagext.ByteSize(1572864)
+	f.Var(&cfg.SmallChunksMergeTargetSize, "compactor.small-chunks-merge-target-size", "Maximum uncompressed size of the chunks built by merging small chunks. A unit suffix (KB, MB, GB) may be applied.")
 	cfg.RetentionBackoffConfig.RegisterFlagsWithPrefix("compactor.retention-backoff-config", f)
+	cfg.Tiering.RegisterFlagsWithPrefix("compactor.tiering.", f)
 	// Ring
 	skipFlags := []string{
 		"compactor.ring.num-tokens",
@@ -122,6 +142,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.CompactorRing.RegisterFlagsWithPrefix("compactor.", "collectors/", f, skipFlags...)
 	f.IntVar(&cfg.CompactorRing.NumTokens, "compactor.ring.num-tokens", ringNumTokens, fmt.Sprintf("IGNORED: Num tokens is fixed to %d", ringNumTokens))
 	f.IntVar(&cfg.CompactorRing.ReplicationFactor, "compactor.ring.replication-factor", ringReplicationFactor, fmt.Sprintf("IGNORED: Replication factor is fixed to %d", ringReplicationFactor))
+	f.BoolVar(&cfg.ShardingEnabled, "compactor.sharding-enabled", false, "Share the compaction and the retention of the index tables between all the compactors of the ring instead of electing a single one. The tables are split by their hash, and the index of each tenant of the tables older than a day by the hash of the table and the tenant. The compactor owning the leader token manages the delete requests and the rehydration requests, the other compactors forward them to it.")
+	cfg.LeaderClient.RegisterFlagsWithPrefix("compactor.leader-client", f)
 }
 
 // Validate verifies the config does not contain inappropriate values
@@ -157,7 +179,26 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
-	return nil
+	if cfg.SmallChunksMergeThreshold > 0 {
+		if !cfg.RetentionEnabled {
+			return errors.New("compactor.retention-enabled should be true when compactor.small-chunks-merge-threshold is set")
+		}
+		if cfg.SmallChunksMergeTargetSize <= cfg.SmallChunksMergeThreshold {
+			return errors.New("compactor.small-chunks-merge-target-size should be greater than compactor.small-chunks-merge-threshold")
+		}
+	}
+
+	if cfg.Tiering.Enabled() && !cfg.RetentionEnabled {
+		return errors.New("compactor.retention-enabled should be true when compactor.tiering.cold-store is configured")
+	}
+
+	if cfg.ShardingEnabled {
+		if err := cfg.LeaderClient.Validate(); err != nil {
+			return errors.Wrap(err, "invalid compactor.leader-client config")
+		}
+	}
+
+	return cfg.Tiering.Validate()
 }
 
 type Compactor struct {
@@ -172,6 +213,10 @@ type Compactor struct {
 	DeleteRequestsGRPCHandler *deletion.GRPCRequestHandler
 	deleteRequestsManager     *deletion.DeleteRequestsManager
 	expirationChecker         retention.ExpirationChecker
+	rollupManager             *rollup.Manager
+	TieringManager            *tiering.Manager
+	chunkMerger               *retention.ChunkMerger
+	chunkRepairer             *retention.ChunkRepairer
 	metrics                   *metrics
 	running                   bool
 	wg                        sync.WaitGroup
@@ -183,6 +228,12 @@ type Compactor struct {
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
 	ringPollPeriod time.Duration
+	// leader is set when this compactor owns the leader token while the compactors share the tables.
+	leader atomic.Bool
+	// leaderConn is the connection to the leader the requests are forwarded to, it is dialed again when the leader changes.
+	leaderConnMtx  sync.Mutex
+	leaderConnAddr string
+	leaderConn     *grpc.ClientConn
 
 	// Subservices manager.
 	subservices        *services.Manager
@@ -195,16 +246,20 @@ type Compactor struct {
 type storeContainer struct {
 	tableMarker        retention.TableMarker
 	sweeper            *retention.Sweeper
+	tieringSweeper     *retention.Sweeper
 	indexStorageClient storage.Client
+	chunkClient        client.Client
 }
 
 type Limits interface {
 	deletion.Limits
 	retention.Limits
+	rollup.Limits
+	tiering.Limits
 	DefaultLimits() *validation.Limits
 }
 
-func NewCompactor(cfg Config, objectStoreClients map[config.DayTime]client.ObjectClient, deleteStoreClient client.ObjectClient, schemaConfig config.SchemaConfig, limits Limits, r prometheus.Registerer, metricsNamespace string) (*Compactor, error) {
+func NewCompactor(cfg Config, objectStoreClients map[config.DayTime]client.ObjectClient, deleteStoreClient, coldStoreClient client.ObjectClient, schemaConfig config.SchemaConfig, limits Limits, r prometheus.Registerer, metricsNamespace string) (*Compactor, error) {
 	retentionEnabledStats.Set("false")
 	if cfg.RetentionEnabled {
 		retentionEnabledStats.Set("true")
@@ -230,7 +285,7 @@ func NewCompactor(cfg Config, objectStoreClients map[config.DayTime]client.Objec
 	if err != nil {
 		return nil, errors.Wrap(err, "create KV store client")
 	}
-	lifecyclerCfg, err := cfg.CompactorRing.ToLifecyclerConfig(ringNumTokens, util_log.Logger)
+	lifecyclerCfg, err := cfg.CompactorRing.ToLifecyclerConfig(numRingTokens(cfg), util_log.Logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid ring lifecycler config")
 	}
@@ -260,7 +315,7 @@ func NewCompactor(cfg Config, objectStoreClients map[config.DayTime]client.Objec
 	compactor.subservicesWatcher = services.NewFailureWatcher()
 	compactor.subservicesWatcher.WatchManager(compactor.subservices)
 
-	if err := compactor.init(objectStoreClients, deleteStoreClient, schemaConfig, limits, r); err != nil {
+	if err := compactor.init(objectStoreClients, deleteStoreClient, coldStoreClient, schemaConfig, limits, r); err != nil {
 		return nil, fmt.Errorf("init compactor: %w", err)
 	}
 
@@ -268,7 +323,7 @@ func NewCompactor(cfg Config, objectStoreClients map[config.DayTime]client.Objec
 	return compactor, nil
 }
 
-func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClient, deleteStoreClient client.ObjectClient, schemaConfig config.SchemaConfig, limits Limits, r prometheus.Registerer) error {
+func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClient, deleteStoreClient, coldStoreClient client.ObjectClient, schemaConfig config.SchemaConfig, limits Limits, r prometheus.Registerer) error {
 	err := chunk_util.EnsureDirectory(c.cfg.WorkingDirectory)
 	if err != nil {
 		return err
@@ -279,6 +334,18 @@ func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClie
 			return fmt.Errorf("delete store client not initialised when retention is enabled")
 		}
 
+		if c.cfg.Tiering.Enabled() {
+			if coldStoreClient == nil {
+				return fmt.Errorf("cold store client not initialised when tiering is enabled")
+			}
+
+			coldStoreClient = client.NewPrefixedObjectClient(coldStoreClient, c.cfg.Tiering.ColdStoreKeyPrefix)
+			c.TieringManager, err = tiering.NewManager(limits, coldStoreClient, newChunkClient(coldStoreClient, schemaConfig), c.cfg.Tiering.RehydrationPeriod, r)
+			if err != nil {
+				return fmt.Errorf("failed to init tiering: %w", err)
+			}
+		}
+
 		if err := c.initDeletes(deleteStoreClient, r, limits); err != nil {
 			return fmt.Errorf("failed to init delete store: %w", err)
 		}
@@ -297,8 +364,6 @@ func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClie
 
 		if c.cfg.RetentionEnabled {
 			var (
-				raw              client.ObjectClient
-				encoder          client.KeyEncoder
 				name             = fmt.Sprintf("%s_%s", period.ObjectType, period.From.String())
 				retentionWorkDir = filepath.Join(c.cfg.WorkingDirectory, "retention", name)
 				r                = prometheus.WrapRegistererWith(prometheus.Labels{"from": name}, r)
@@ -317,25 +382,34 @@ func (c *Compactor) init(objectStoreClients map[config.DayTime]client.ObjectClie
 			// remove markers from the store dir after copying them to period specific dirs.
 			legacyMarkerDirs[period.ObjectType] = struct{}{}
 
-			if casted, ok := objectClient.(client.PrefixedObjectClient); ok {
-				raw = casted.GetDownstream()
-			} else {
-				raw = objectClient
-			}
-			if _, ok := raw.(*local.FSObjectClient); ok {
-				encoder = client.FSEncoder
+			chunkClient := newChunkClient(objectClient, schemaConfig)
+			if c.TieringManager != nil {
+				// the chunks are read and deleted from both tiers.
+				chunkClient = tiering.NewChunkClient(chunkClient, c.TieringManager.ColdChunkClient())
 			}
-			chunkClient := client.NewClient(objectClient, encoder, schemaConfig)
+			sc.chunkClient = chunkClient
 
 			sc.sweeper, err = retention.NewSweeper(retentionWorkDir, chunkClient, c.cfg.RetentionDeleteWorkCount, c.cfg.RetentionDeleteDelay, c.cfg.RetentionBackoffConfig, r)
 			if err != nil {
 				return fmt.Errorf("failed to init sweeper: %w", err)
 			}
 
-			sc.tableMarker, err = retention.NewMarker(retentionWorkDir, c.expirationChecker, c.cfg.RetentionTableTimeout, chunkClient, r)
+			marker, err := retention.NewMarker(retentionWorkDir, c.expirationChecker, c.cfg.RetentionTableTimeout, chunkClient, c.chunkMerger, r)
 			if err != nil {
 				return fmt.Errorf("failed to init table marker: %w", err)
 			}
+			sc.tableMarker = c.rollupManager.WrapTableMarker(marker, chunkClient)
+
+			if c.TieringManager != nil {
+				standardChunkClient := newChunkClient(objectClient, schemaConfig)
+				tieringWorkDir := filepath.Join(c.cfg.WorkingDirectory, "tiering", name)
+				// the metrics of the sweeper of the archived chunks are not registered since they would collide with the ones of the retention sweeper.
+				sc.tieringSweeper, err = retention.NewSweeper(tieringWorkDir, standardChunkClient, c.cfg.RetentionDeleteWorkCount, c.cfg.RetentionDeleteDelay, c.cfg.RetentionBackoffConfig, nil)
+				if err != nil {
+					return fmt.Errorf("failed to init tiering sweeper: %w", err)
+				}
+				sc.tableMarker = c.TieringManager.WrapTableMarker(sc.tableMarker, standardChunkClient, tieringWorkDir)
+			}
 		}
 
 		c.storeContainers[from] = sc
@@ -368,21 +442,41 @@ func (c *Compactor) initDeletes(objectClient client.ObjectClient, r prometheus.R
 
 	c.DeleteRequestsHandler = deletion.NewDeleteRequestHandler(
 		c.deleteRequestsStore,
+		c,
 		c.cfg.DeleteMaxInterval,
+		c.cfg.DeleteRequestRequesterHeader,
 		r,
 	)
 
 	c.DeleteRequestsGRPCHandler = deletion.NewGRPCRequestHandler(c.deleteRequestsStore, limits)
 
+	var shardCoordinator *deletion.ShardCoordinator
+	if c.cfg.ShardingEnabled {
+		shardCoordinator = deletion.NewShardCoordinator(c, objectClient)
+		// the compactor only writes the requests once it is elected as the leader.
+		if err := c.deleteRequestsStore.SetReadOnly(true); err != nil {
+			return err
+		}
+		if c.TieringManager != nil {
+			c.TieringManager.SetFollower(true)
+		}
+	}
+
 	c.deleteRequestsManager = deletion.NewDeleteRequestsManager(
 		c.deleteRequestsStore,
 		c.cfg.DeleteRequestCancelPeriod,
 		c.cfg.DeleteBatchSize,
 		limits,
+		shardCoordinator,
 		r,
 	)
 
-	c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits), c.deleteRequestsManager)
+	c.rollupManager = rollup.NewManager(limits, r)
+	if c.cfg.SmallChunksMergeThreshold > 0 {
+		c.chunkMerger = retention.NewChunkMerger(c.cfg.SmallChunksMergeThreshold.Val(), c.cfg.SmallChunksMergeTargetSize.Val(), r)
+	}
+	c.chunkRepairer = retention.NewChunkRepairer(objectClient, r)
+	c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits, objectClient), c.deleteRequestsManager, c.rollupManager, c.TieringManager, c.chunkMerger, c.chunkRepairer)
 	return nil
 }
 
@@ -475,6 +569,19 @@ func (c *Compactor) loop(ctx context.Context) error {
 			level.Info(util_log.Logger).Log("msg", "compactor exiting")
 			return nil
 		case <-syncTicker.C:
+			if c.cfg.ShardingEnabled {
+				// all the compactors process the tables they own.
+				if !c.running {
+					level.Info(util_log.Logger).Log("msg", "compactors share the tables, starting compactor")
+					runningCtx, runningCancel = context.WithCancel(ctx)
+					go c.runCompactions(runningCtx)
+					c.running = true
+					c.metrics.compactorRunning.Set(1)
+				}
+				c.updateLeadership()
+				continue
+			}
+
 			bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
 			rs, err := c.ring.Get(ringKeyOfLeader, ring.Write, bufDescs, bufHosts, bufZones)
 			if err != nil {
@@ -582,9 +689,15 @@ func (c *Compactor) runCompactions(ctx context.Context) {
 				// starts the chunk sweeper
 				defer func() {
 					sc.sweeper.Stop()
+					if sc.tieringSweeper != nil {
+						sc.tieringSweeper.Stop()
+					}
 					c.wg.Done()
 				}()
 				sc.sweeper.Start()
+				if sc.tieringSweeper != nil {
+					sc.tieringSweeper.Start()
+				}
 				<-ctx.Done()
 			}(container)
 		}
@@ -593,6 +706,7 @@ func (c *Compactor) runCompactions(ctx context.Context) {
 }
 
 func (c *Compactor) stopping(_ error) error {
+	c.closeLeaderConn()
 	return services.StopManagerAndAwaitStopped(context.Background(), c.subservices)
 }
 
@@ -645,7 +759,7 @@ func (c *Compactor) CompactTable(ctx context.Context, tableName string, applyRet
 	defer c.tableLocker.unlockTable(tableName)
 
 	table, err := newTable(ctx, filepath.Join(c.cfg.WorkingDirectory, tableName), sc.indexStorageClient, indexCompactor,
-		schemaCfg, sc.tableMarker, c.expirationChecker, c.cfg.UploadParallelism)
+		schemaCfg, sc.tableMarker, c.expirationChecker, c.cfg.UploadParallelism, c.tableSharder())
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "failed to initialize table for compaction", "table", tableName, "err", err)
 		return err
@@ -666,6 +780,13 @@ func (c *Compactor) CompactTable(ctx context.Context, tableName string, applyRet
 	if !applyRetention {
 		c.metrics.skippedCompactingLockedTables.WithLabelValues(tableName).Set(0)
 	}
+	if c.cfg.ShardingEnabled {
+		state := shardTableProcessed
+		if table.skipped {
+			state = shardTableSkipped
+		}
+		c.metrics.shardTables.WithLabelValues(operationName(applyRetention), state).Inc()
+	}
 	return nil
 }
 
@@ -680,6 +801,10 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) (err
 	if applyRetention {
 		c.expirationChecker.MarkPhaseStarted()
 	}
+	if c.cfg.ShardingEnabled {
+		c.metrics.shardTables.WithLabelValues(operationName(applyRetention), shardTableProcessed).Set(0)
+		c.metrics.shardTables.WithLabelValues(operationName(applyRetention), shardTableSkipped).Set(0)
+	}
 
 	defer func() {
 		if err != nil {
@@ -815,48 +940,114 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) (err
 type expirationChecker struct {
 	retentionExpiryChecker retention.ExpirationChecker
 	deletionExpiryChecker  retention.ExpirationChecker
+	rollupManager          *rollup.Manager
+	// tieringManager is nil when tiering is disabled.
+	tieringManager *tiering.Manager
+	// chunkMerger is nil when the small chunks are not merged.
+	chunkMerger   *retention.ChunkMerger
+	chunkRepairer *retention.ChunkRepairer
 }
 
-func newExpirationChecker(retentionExpiryChecker, deletionExpiryChecker retention.ExpirationChecker) retention.ExpirationChecker {
-	return &expirationChecker{retentionExpiryChecker, deletionExpiryChecker}
+func newExpirationChecker(retentionExpiryChecker, deletionExpiryChecker retention.ExpirationChecker, rollupManager *rollup.Manager, tieringManager *tiering.Manager, chunkMerger *retention.ChunkMerger, chunkRepairer *retention.ChunkRepairer) retention.ExpirationChecker {
+	return &expirationChecker{retentionExpiryChecker, deletionExpiryChecker, rollupManager, tieringManager, chunkMerger, chunkRepairer}
 }
 
 func (e *expirationChecker) Expired(ref retention.ChunkEntry, now model.Time) (bool, filter.Func) {
-	if expired, nonDeletedIntervals := e.retentionExpiryChecker.Expired(ref, now); expired {
-		return expired, nonDeletedIntervals
+	// the chunks of the repair plans are missing or corrupted in the store.
+	if e.chunkRepairer.Expired(ref) {
+		return true, nil
 	}
 
-	return e.deletionExpiryChecker.Expired(ref, now)
+	retentionExpired, retentionFilter := e.retentionExpiryChecker.Expired(ref, now)
+	if retentionExpired && retentionFilter == nil {
+		return true, nil
+	}
+
+	deletionExpired, deletionFilter := e.deletionExpiryChecker.Expired(ref, now)
+	if !deletionExpired {
+		return retentionExpired, retentionFilter
+	}
+	if !retentionExpired || deletionFilter == nil {
+		return deletionExpired, deletionFilter
+	}
+
+	// both the structured metadata retention and the delete requests remove some of the lines of the chunk.
+	return true, func(ts time.Time, s string, structuredMetadata ...labels.Label) bool {
+		return retentionFilter(ts, s, structuredMetadata...) || deletionFilter(ts, s, structuredMetadata...)
+	}
 }
 
 func (e *expirationChecker) MarkPhaseStarted() {
 	e.retentionExpiryChecker.MarkPhaseStarted()
 	e.deletionExpiryChecker.MarkPhaseStarted()
+	e.rollupManager.MarkPhaseStarted()
+	e.chunkRepairer.MarkPhaseStarted()
+	if e.tieringManager != nil {
+		e.tieringManager.MarkPhaseStarted()
+	}
+	if e.chunkMerger != nil {
+		e.chunkMerger.MarkPhaseStarted()
+	}
 }
 
 func (e *expirationChecker) MarkPhaseFailed() {
 	e.retentionExpiryChecker.MarkPhaseFailed()
 	e.deletionExpiryChecker.MarkPhaseFailed()
+	e.rollupManager.MarkPhaseFailed()
+	e.chunkRepairer.MarkPhaseFailed()
+	if e.tieringManager != nil {
+		e.tieringManager.MarkPhaseFailed()
+	}
+	if e.chunkMerger != nil {
+		e.chunkMerger.MarkPhaseFailed()
+	}
 }
 
 func (e *expirationChecker) MarkPhaseFinished() {
 	e.retentionExpiryChecker.MarkPhaseFinished()
 	e.deletionExpiryChecker.MarkPhaseFinished()
+	e.rollupManager.MarkPhaseFinished()
+	e.chunkRepairer.MarkPhaseFinished()
+	if e.tieringManager != nil {
+		e.tieringManager.MarkPhaseFinished()
+	}
+	if e.chunkMerger != nil {
+		e.chunkMerger.MarkPhaseFinished()
+	}
 }
 
 func (e *expirationChecker) MarkPhaseTimedOut() {
 	e.retentionExpiryChecker.MarkPhaseTimedOut()
 	e.deletionExpiryChecker.MarkPhaseTimedOut()
+	e.chunkRepairer.MarkPhaseTimedOut()
+	if e.chunkMerger != nil {
+		e.chunkMerger.MarkPhaseTimedOut()
+	}
 }
 
 func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool {
-	return e.retentionExpiryChecker.IntervalMayHaveExpiredChunks(interval, userID) || e.deletionExpiryChecker.IntervalMayHaveExpiredChunks(interval, userID)
+	// the deletion expiry checker, the rollup manager, the chunk merger and the chunk repairer are always called since they track the tables they process.
+	deletionMayHaveExpiredChunks := e.deletionExpiryChecker.IntervalMayHaveExpiredChunks(interval, userID)
+	mayHaveRollups := e.rollupManager.IntervalMayHaveRollups(interval, userID)
+	mayHaveChunksToMerge := e.chunkMerger != nil && e.chunkMerger.IntervalMayHaveChunksToMerge(interval, userID)
+	mayHaveDanglingChunks := e.chunkRepairer.IntervalMayHaveDanglingChunks(interval, userID)
+	if e.retentionExpiryChecker.IntervalMayHaveExpiredChunks(interval, userID) || deletionMayHaveExpiredChunks || mayHaveRollups || mayHaveChunksToMerge || mayHaveDanglingChunks {
+		return true
+	}
+	return e.tieringManager != nil && e.tieringManager.IntervalMayHaveChunksToMove(interval, userID)
 }
 
 func (e *expirationChecker) DropFromIndex(ref retention.ChunkEntry, tableEndTime model.Time, now model.Time) bool {
 	return e.retentionExpiryChecker.DropFromIndex(ref, tableEndTime, now) || e.deletionExpiryChecker.DropFromIndex(ref, tableEndTime, now)
 }
 
+// MarkIndexProcessed implements retention.IndexRetentionTracker.
+func (e *expirationChecker) MarkIndexProcessed(tableName, userID string) {
+	if tracker, ok := e.retentionExpiryChecker.(retention.IndexRetentionTracker); ok {
+		tracker.MarkIndexProcessed(tableName, userID)
+	}
+}
+
 func (c *Compactor) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc ring.Desc, instanceExists bool, _ string, instanceDesc ring.InstanceDesc) (ring.InstanceState, ring.Tokens) {
 	// When we initialize the compactor instance in the ring we want to start from
 	// a clean situation, so whatever is the state we set it JOINING, while we keep existing
@@ -868,7 +1059,7 @@ func (c *Compactor) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc rin
 
 	takenTokens := ringDesc.GetTokens()
 	gen := ring.NewRandomTokenGenerator()
-	newTokens := gen.GenerateTokens(ringNumTokens-len(tokens), takenTokens)
+	newTokens := gen.GenerateTokens(numRingTokens(c.cfg)-len(tokens), takenTokens)
 
 	// Tokens sorting will be enforced by the parent caller.
 	tokens = append(tokens, newTokens...)
@@ -914,3 +1105,20 @@ func minDuration(x time.Duration, y time.Duration) time.Duration {
 
 	return y
 }
+
+// newChunkClient returns a client of the chunks stored with objectClient.
+func newChunkClient(objectClient client.ObjectClient, schemaConfig config.SchemaConfig) client.Client {
+	var (
+		raw     client.ObjectClient
+		encoder client.KeyEncoder
+	)
+	if casted, ok := objectClient.(client.PrefixedObjectClient); ok {
+		raw = casted.GetDownstream()
+	} else {
+		raw = objectClient
+	}
+	if _, ok := raw.(*local.FSObjectClient); ok {
+		encoder = client.FSEncoder
+	}
+	return client.NewClient(objectClient, encoder, schemaConfig)
+}
@@ -3,6 +3,8 @@ package compactor
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -61,7 +63,7 @@ func setupTestCompactor(t *testing.T, objectClients map[config.DayTime]client.Ob
 	overrides, err := validation.NewOverrides(defaultLimits, nil)
 	require.NoError(t, err)
 
-	c, err := NewCompactor(cfg, objectClients, objectClients[periodConfigs[len(periodConfigs)-1].From], config.SchemaConfig{
+	c, err := NewCompactor(cfg, objectClients, objectClients[periodConfigs[len(periodConfigs)-1].From], nil, config.SchemaConfig{
 		Configs: periodConfigs,
 	}, overrides, prometheus.NewPedanticRegistry(), constants.Loki)
 	require.NoError(t, err)
@@ -449,3 +451,35 @@ func TestCompactor_TableLocking(t *testing.T) {
 		})
 	}
 }
+
+func TestCompactor_ForwardToLeader(t *testing.T) {
+	served := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, tc := range []struct {
+		name            string
+		shardingEnabled bool
+		leader          bool
+		forwarded       bool
+		expectedStatus  int
+	}{
+		{name: "sharding disabled", expectedStatus: http.StatusNoContent},
+		{name: "leader", shardingEnabled: true, leader: true, expectedStatus: http.StatusNoContent},
+		{name: "leader serves forwarded requests", shardingEnabled: true, leader: true, forwarded: true, expectedStatus: http.StatusNoContent},
+		{name: "follower does not forward forwarded requests again", shardingEnabled: true, forwarded: true, expectedStatus: http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Compactor{cfg: Config{ShardingEnabled: tc.shardingEnabled}}
+			c.leader.Store(tc.leader)
+
+			req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/delete", nil)
+			if tc.forwarded {
+				req.Header.Set(forwardedToLeaderHeader, "true")
+			}
+			rec := httptest.NewRecorder()
+			c.ForwardToLeader(served).ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
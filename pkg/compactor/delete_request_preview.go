@@ -0,0 +1,108 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/compactor/deletion"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/storage"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+// PreviewDeleteRequest estimates the data deleted by the delete request without adding it.
+// It reads the compacted index of the tenant in the tables overlapping the interval of the request,
+// and samples the chunks matching the request to count the deleted lines.
+// Index files not compacted yet in the multi-tenant index are not read.
+func (c *Compactor) PreviewDeleteRequest(ctx context.Context, req deletion.DeleteRequest) (deletion.Preview, error) {
+	workingDir, err := os.MkdirTemp(c.cfg.WorkingDirectory, "delete-dry-run-")
+	if err != nil {
+		return deletion.Preview{}, err
+	}
+	defer func() {
+		if err := os.RemoveAll(workingDir); err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to remove delete request dry-run working directory", "dir", workingDir, "err", err)
+		}
+	}()
+
+	logger := log.With(util_log.Logger, "user", req.UserID, "delete_request_query", req.Query)
+	builder := deletion.NewPreviewBuilder(&req, c.cfg.DeleteDryRunSampledChunks)
+	requestInterval := model.Interval{Start: req.StartTime, End: req.EndTime}
+
+	seen := map[string]struct{}{}
+	for _, sc := range c.storeContainers {
+		tables, err := sc.indexStorageClient.ListTables(ctx)
+		if err != nil {
+			return deletion.Preview{}, fmt.Errorf("failed to list tables: %w", err)
+		}
+
+		for _, tableName := range tables {
+			if _, ok := seen[tableName]; ok || tableName == deletion.DeleteRequestsTableName {
+				continue
+			}
+			seen[tableName] = struct{}{}
+
+			if !intervalsOverlap(retention.ExtractIntervalFromTableName(tableName), requestInterval) {
+				continue
+			}
+
+			if err := c.previewTable(ctx, tableName, req.UserID, workingDir, builder, logger); err != nil {
+				return deletion.Preview{}, err
+			}
+		}
+	}
+
+	return builder.Build(ctx)
+}
+
+func (c *Compactor) previewTable(ctx context.Context, tableName, userID, workingDir string, builder *deletion.PreviewBuilder, logger log.Logger) error {
+	schemaCfg, ok := SchemaPeriodForTable(c.schemaConfig, tableName)
+	if !ok {
+		return nil
+	}
+
+	indexCompactor, ok := c.indexCompactors[schemaCfg.IndexType]
+	if !ok {
+		return fmt.Errorf("index processor not found for index type %s", schemaCfg.IndexType)
+	}
+
+	sc, ok := c.storeContainers[schemaCfg.From]
+	if !ok {
+		return fmt.Errorf("index store client not found for period starting at %s", schemaCfg.From.String())
+	}
+
+	tableDir := filepath.Join(workingDir, tableName)
+	idxSet, err := newUserIndexSet(ctx, tableName, userID, storage.NewIndexSet(sc.indexStorageClient, true), tableDir, log.With(logger, "table-name", tableName))
+	if err != nil {
+		return err
+	}
+
+	for _, sourceFile := range idxSet.ListSourceFiles() {
+		downloadedAt, err := idxSet.GetSourceFile(sourceFile)
+		if err != nil {
+			return err
+		}
+
+		compactedIndex, err := indexCompactor.OpenCompactedIndexFile(ctx, downloadedAt, tableName, userID, tableDir, schemaCfg, idxSet.GetLogger())
+		if err != nil {
+			return err
+		}
+
+		err = compactedIndex.ForEachChunk(ctx, builder.ChunkEntryCallback(sc.chunkClient))
+		compactedIndex.Cleanup()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func intervalsOverlap(interval1, interval2 model.Interval) bool {
+	return interval1.Start <= interval2.End && interval2.Start <= interval1.End
+}
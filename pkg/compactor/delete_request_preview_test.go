@@ -0,0 +1,80 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/compactor/deletion"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+)
+
+func TestCompactor_PreviewDeleteRequest(t *testing.T) {
+	tempDir := t.TempDir()
+	tablesPath := filepath.Join(tempDir, "index")
+
+	daySeconds := int64(24 * time.Hour / time.Second)
+	tableNumEnd := time.Now().Unix() / daySeconds
+	tableNumStart := tableNumEnd - 5
+
+	periodConfigs := []config.PeriodConfig{
+		{
+			From:       config.DayTime{Time: model.Time(0)},
+			IndexType:  "dummy",
+			ObjectType: "fs_01",
+			IndexTables: config.IndexPeriodicTableConfig{
+				PathPrefix: "index/",
+				PeriodicTableConfig: config.PeriodicTableConfig{
+					Prefix: indexTablePrefix,
+					Period: config.ObjectStorageIndexRequiredPeriod,
+				}},
+		},
+	}
+
+	for i := tableNumStart; i <= tableNumEnd; i++ {
+		SetupTable(t, filepath.Join(tablesPath, fmt.Sprintf("%s%d", indexTablePrefix, i)), IndexesConfig{}, PerUserIndexesConfig{
+			IndexesConfig: IndexesConfig{NumCompactedFiles: 1},
+			NumUsers:      2,
+		})
+	}
+
+	objectClients := map[config.DayTime]client.ObjectClient{}
+	var err error
+	objectClients[periodConfigs[0].From], err = local.NewFSObjectClient(local.FSConfig{Directory: tempDir})
+	require.NoError(t, err)
+
+	compactor := setupTestCompactor(t, objectClients, periodConfigs, tempDir)
+
+	req := deletion.DeleteRequest{
+		UserID:    BuildUserID(0),
+		StartTime: model.TimeFromUnix((tableNumEnd - 1) * daySeconds),
+		EndTime:   model.Now(),
+	}
+	require.NoError(t, req.SetQuery(`{foo="bar"} | json | user_id="42"`))
+
+	preview, err := compactor.PreviewDeleteRequest(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, deletion.Preview{}, preview)
+
+	// the downloaded index files are removed.
+	dirs, err := os.ReadDir(compactor.cfg.WorkingDirectory)
+	require.NoError(t, err)
+	for _, dir := range dirs {
+		require.NotContains(t, dir.Name(), "delete-dry-run-")
+	}
+
+	// the index files in the storage are untouched.
+	for i := tableNumStart; i <= tableNumEnd; i++ {
+		files, err := os.ReadDir(filepath.Join(tablesPath, fmt.Sprintf("%s%d", indexTablePrefix, i), BuildUserID(0)))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+	}
+}
@@ -1,6 +1,8 @@
 package deletion
 
 import (
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -8,6 +10,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/util/filter"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
@@ -33,6 +36,63 @@ type DeleteRequest struct {
 
 	Metrics      *deleteRequestsManagerMetrics `json:"-"`
 	DeletedLines int32                         `json:"-"`
+
+	// progress of the processing of the request, the filter functions update it concurrently.
+	TablesProcessed int64 `json:"-"`
+	ChunksDeleted   int64 `json:"-"`
+	ChunksRewritten int64 `json:"-"`
+	// processingStartedAt and processedTables are the rest of the progress kept across compactions.
+	processingStartedAt model.Time              `json:"-"`
+	processedTables     map[model.Time]struct{} `json:"-"`
+}
+
+// RequestProgress is the progress of the processing of a delete request stored in the delete requests store.
+// The processing of a request resumes from its stored progress in the following compactions.
+type RequestProgress struct {
+	loghttp.DeleteRequestProgress
+	// StartedAt is when the compactor started processing the request.
+	StartedAt model.Time `json:"started_at,omitempty"`
+	// Tables holds the start of the interval of the tables processed for the request, to count each table once.
+	Tables []model.Time `json:"tables,omitempty"`
+}
+
+// Progress returns the progress of the processing of the DeleteRequest.
+func (d *DeleteRequest) Progress() loghttp.DeleteRequestProgress {
+	return loghttp.DeleteRequestProgress{
+		TablesProcessed: atomic.LoadInt64(&d.TablesProcessed),
+		ChunksDeleted:   atomic.LoadInt64(&d.ChunksDeleted),
+		ChunksRewritten: atomic.LoadInt64(&d.ChunksRewritten),
+		DeletedLines:    int64(atomic.LoadInt32(&d.DeletedLines)),
+	}
+}
+
+// resume resumes the processing of the DeleteRequest from its stored progress.
+func (d *DeleteRequest) resume(progress RequestProgress) {
+	d.TablesProcessed = progress.TablesProcessed
+	d.ChunksDeleted = progress.ChunksDeleted
+	d.ChunksRewritten = progress.ChunksRewritten
+	d.DeletedLines = int32(progress.DeletedLines)
+	d.processingStartedAt = progress.StartedAt
+	d.processedTables = make(map[model.Time]struct{}, len(progress.Tables))
+	for _, table := range progress.Tables {
+		d.processedTables[table] = struct{}{}
+	}
+}
+
+// storedProgress returns the progress of the DeleteRequest to store. It must not be called concurrently with the
+// tracking of the processed tables.
+func (d *DeleteRequest) storedProgress() RequestProgress {
+	tables := make([]model.Time, 0, len(d.processedTables))
+	for table := range d.processedTables {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i] < tables[j] })
+
+	return RequestProgress{
+		DeleteRequestProgress: d.Progress(),
+		StartedAt:             d.processingStartedAt,
+		Tables:                tables,
+	}
 }
 
 func (d *DeleteRequest) SetQuery(logQL string) error {
@@ -87,8 +147,11 @@ func (d *DeleteRequest) FilterFunction(lbls labels.Labels) (filter.Func, error)
 
 		result, _, skip := f(0, s, structuredMetadata...)
 		if len(result) != 0 || skip {
-			d.Metrics.deletedLinesTotal.WithLabelValues(d.UserID).Inc()
-			d.DeletedLines++
+			// the delete requests of the dry-run endpoint have no metrics.
+			if d.Metrics != nil {
+				d.Metrics.deletedLinesTotal.WithLabelValues(d.UserID).Inc()
+			}
+			atomic.AddInt32(&d.DeletedLines, 1)
 			return true
 		}
 		return false
@@ -382,6 +382,54 @@ func TestDeleteRequest_FilterFunction(t *testing.T) {
 		require.Equal(t, float64(1), testutil.ToFloat64(dr.Metrics.deletedLinesTotal))
 	})
 
+	t.Run("lines matching with parsed label filter", func(t *testing.T) {
+		dr := DeleteRequest{
+			Query:        `{foo="bar"} | json | user_id="42"`,
+			DeletedLines: 0,
+			Metrics:      newDeleteRequestsManagerMetrics(prometheus.NewPedanticRegistry()),
+			StartTime:    0,
+			EndTime:      math.MaxInt64,
+		}
+
+		lblStr := lblFooBar
+		lbls := mustParseLabel(lblStr)
+
+		require.NoError(t, dr.SetQuery(dr.Query))
+		f, err := dr.FilterFunction(lbls)
+		require.NoError(t, err)
+
+		require.True(t, f(time.Now(), `{"user_id":"42","msg":"some line"}`))
+		require.True(t, f(time.Now(), `{"user_id":42}`))
+		require.False(t, f(time.Now(), `{"user_id":"43","msg":"some line"}`))
+		// lines failing to be parsed don't have the parsed labels.
+		require.False(t, f(time.Now(), `user_id=42`))
+		require.False(t, f(time.Now(), ""))
+		require.Equal(t, int32(2), dr.DeletedLines)
+		require.Equal(t, float64(2), testutil.ToFloat64(dr.Metrics.deletedLinesTotal))
+	})
+
+	t.Run("lines matching with parsed and structured metadata label filters", func(t *testing.T) {
+		dr := DeleteRequest{
+			Query:        `{foo="bar"} | logfmt | user_id="42" or ping="pong"`,
+			DeletedLines: 0,
+			Metrics:      newDeleteRequestsManagerMetrics(prometheus.NewPedanticRegistry()),
+			StartTime:    0,
+			EndTime:      math.MaxInt64,
+		}
+
+		lblStr := lblFooBar
+		lbls := mustParseLabel(lblStr)
+
+		require.NoError(t, dr.SetQuery(dr.Query))
+		f, err := dr.FilterFunction(lbls)
+		require.NoError(t, err)
+
+		require.True(t, f(time.Now(), `user_id=42 msg="some line"`))
+		require.True(t, f(time.Now(), `user_id=43 msg="some line"`, labels.Label{Name: lblPing, Value: lblPong}))
+		require.False(t, f(time.Now(), `user_id=43 msg="some line"`))
+		require.Equal(t, int32(2), dr.DeletedLines)
+	})
+
 	t.Run("labels not matching", func(t *testing.T) {
 		dr := DeleteRequest{
 			Query:        `{foo="bar"} |= "some"`,
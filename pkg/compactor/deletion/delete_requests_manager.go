@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/compactor/deletionmode"
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/util/filter"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 )
@@ -21,12 +23,17 @@ import (
 const (
 	statusSuccess = "success"
 	statusFail    = "fail"
+
+	// compactorActor is the actor of the events of the audit log added by the compactor.
+	compactorActor = "compactor"
 )
 
 type userDeleteRequests struct {
 	requests []*DeleteRequest
 	// requestsInterval holds the earliest start time and latest end time considering all the delete requests
 	requestsInterval model.Interval
+	// processedTables holds the start of the interval of the tables processed in the current compaction
+	processedTables map[model.Time]struct{}
 }
 
 type DeleteRequestsManager struct {
@@ -40,9 +47,23 @@ type DeleteRequestsManager struct {
 	done                       chan struct{}
 	batchSize                  int
 	limits                     Limits
+
+	// sharding is nil when the compactor does not share the index tables with other compactors.
+	sharding *ShardCoordinator
+	// shardPhase is the view of the shards of the running phase, it is protected by deleteRequestsToProcessMtx.
+	shardPhase shardPhase
+	// pinnedRequests restricts the requests loaded by the leader to the batch the other compactors are processing.
+	pinnedRequests map[string]struct{}
 }
 
-func NewDeleteRequestsManager(store DeleteRequestsStore, deleteRequestCancelPeriod time.Duration, batchSize int, limits Limits, registerer prometheus.Registerer) *DeleteRequestsManager {
+type shardPhase struct {
+	// follower is set when another compactor manages the delete requests.
+	follower  bool
+	batchID   string
+	instances []string
+}
+
+func NewDeleteRequestsManager(store DeleteRequestsStore, deleteRequestCancelPeriod time.Duration, batchSize int, limits Limits, sharding *ShardCoordinator, registerer prometheus.Registerer) *DeleteRequestsManager {
 	dm := &DeleteRequestsManager{
 		deleteRequestsStore:       store,
 		deleteRequestCancelPeriod: deleteRequestCancelPeriod,
@@ -51,6 +72,7 @@ func NewDeleteRequestsManager(store DeleteRequestsStore, deleteRequestCancelPeri
 		done:                      make(chan struct{}),
 		batchSize:                 batchSize,
 		limits:                    limits,
+		sharding:                  sharding,
 	}
 
 	go dm.loop()
@@ -115,20 +137,24 @@ func (d *DeleteRequestsManager) updateMetrics() error {
 }
 
 func (d *DeleteRequestsManager) loadDeleteRequestsToProcess() error {
-	d.deleteRequestsToProcessMtx.Lock()
-	defer d.deleteRequestsToProcessMtx.Unlock()
-
 	// Reset this first so any errors result in a clear map
+	d.deleteRequestsToProcessMtx.Lock()
 	d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
+	d.deleteRequestsToProcessMtx.Unlock()
 
 	deleteRequests, err := d.filteredSortedDeleteRequests()
 	if err != nil {
 		return err
 	}
 
-	reqCount := 0
+	toProcess := make([]DeleteRequest, 0, d.batchSize)
 	for i := range deleteRequests {
 		deleteRequest := deleteRequests[i]
+		if d.pinnedRequests != nil {
+			if _, ok := d.pinnedRequests[requestKey(deleteRequest)]; !ok {
+				continue
+			}
+		}
 		maxRetentionInterval := getMaxRetentionInterval(deleteRequest.UserID, d.limits)
 		// retention interval 0 means retain the data forever
 		if maxRetentionInterval != 0 {
@@ -139,37 +165,154 @@ func (d *DeleteRequestsManager) loadDeleteRequestsToProcess() error {
 					"delete_request_id", deleteRequest.RequestID,
 					"user", deleteRequest.UserID,
 				)
-				d.markRequestAsProcessed(deleteRequest)
+				d.markRequestAsProcessed(deleteRequest, deleteRequest.storedProgress())
 				continue
 			}
 		}
-		if reqCount >= d.batchSize {
-			logBatchTruncation(reqCount, len(deleteRequests))
+		if len(toProcess) >= d.batchSize {
+			logBatchTruncation(len(toProcess), len(deleteRequests))
 			break
 		}
 
+		if err := d.startProcessing(&deleteRequest); err != nil {
+			return err
+		}
+		toProcess = append(toProcess, deleteRequest)
+	}
+
+	d.deleteRequestsToProcessMtx.Lock()
+	defer d.deleteRequestsToProcessMtx.Unlock()
+	for _, deleteRequest := range toProcess {
+		d.addDeleteRequestToProcess(deleteRequest)
+	}
+	return nil
+}
+
+// startProcessing resumes the processing of the request from its stored progress. The start of the processing
+// is only added to the audit log of the request the first time it is processed.
+func (d *DeleteRequestsManager) startProcessing(deleteRequest *DeleteRequest) error {
+	progress, err := d.deleteRequestsStore.GetProgress(context.Background(), *deleteRequest)
+	if err != nil {
+		return fmt.Errorf("failed to get the progress of delete request %s: %w", deleteRequest.RequestID, err)
+	}
+	deleteRequest.resume(progress)
+	if deleteRequest.processingStartedAt != 0 {
 		level.Info(util_log.Logger).Log(
-			"msg", "Started processing delete request for user",
+			"msg", "Resumed processing delete request for user",
 			"delete_request_id", deleteRequest.RequestID,
 			"user", deleteRequest.UserID,
+			"tables_processed", deleteRequest.TablesProcessed,
 		)
+		return nil
+	}
+
+	level.Info(util_log.Logger).Log(
+		"msg", "Started processing delete request for user",
+		"delete_request_id", deleteRequest.RequestID,
+		"user", deleteRequest.UserID,
+	)
+	deleteRequest.processingStartedAt = model.Now()
+	d.updateProgress(*deleteRequest, deleteRequest.storedProgress(), auditEvent(*deleteRequest, loghttp.DeleteRequestProcessingStarted, "", nil))
+	return nil
+}
+
+// addDeleteRequestToProcess adds the request to the ones processed by the phase. It must be called with deleteRequestsToProcessMtx held.
+func (d *DeleteRequestsManager) addDeleteRequestToProcess(deleteRequest DeleteRequest) {
+	deleteRequest.Metrics = d.metrics
+	if deleteRequest.processedTables == nil {
+		deleteRequest.processedTables = map[model.Time]struct{}{}
+	}
+	ur := d.requestsForUser(deleteRequest)
+	ur.requests = append(ur.requests, &deleteRequest)
+	if deleteRequest.StartTime < ur.requestsInterval.Start {
+		ur.requestsInterval.Start = deleteRequest.StartTime
+	}
+	if deleteRequest.EndTime > ur.requestsInterval.End {
+		ur.requestsInterval.End = deleteRequest.EndTime
+	}
+}
 
-		deleteRequest.Metrics = d.metrics
+// loadShardedDeleteRequestsToProcess loads the delete requests processed by the phase when the compactors share the tables.
+// The leader keeps processing the published batch until all the compactors processed it, and publishes a new batch after.
+func (d *DeleteRequestsManager) loadShardedDeleteRequestsToProcess() error {
+	d.deleteRequestsToProcessMtx.Lock()
+	d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
+	d.shardPhase = shardPhase{}
+	d.deleteRequestsToProcessMtx.Unlock()
 
-		ur := d.requestsForUser(deleteRequest)
-		ur.requests = append(ur.requests, &deleteRequest)
-		if deleteRequest.StartTime < ur.requestsInterval.Start {
-			ur.requestsInterval.Start = deleteRequest.StartTime
+	ctx := context.Background()
+	instances, err := d.sharding.activeInstances()
+	if err != nil {
+		return err
+	}
+	publishedID, published, err := d.sharding.loadBatch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !d.sharding.ring.IsLeader() {
+		d.deleteRequestsToProcessMtx.Lock()
+		defer d.deleteRequestsToProcessMtx.Unlock()
+
+		d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
+		d.shardPhase = shardPhase{follower: true, batchID: publishedID, instances: instances}
+		for _, deleteRequest := range published {
+			d.addDeleteRequestToProcess(deleteRequest)
+		}
+		return nil
+	}
+
+	d.deleteRequestsToProcessMtx.Lock()
+	d.shardPhase = shardPhase{instances: instances}
+	d.deleteRequestsToProcessMtx.Unlock()
+
+	if len(published) > 0 {
+		d.pinnedRequests = make(map[string]struct{}, len(published))
+		for _, deleteRequest := range published {
+			d.pinnedRequests[requestKey(deleteRequest)] = struct{}{}
 		}
-		if deleteRequest.EndTime > ur.requestsInterval.End {
-			ur.requestsInterval.End = deleteRequest.EndTime
+	}
+	err = d.loadDeleteRequestsToProcess()
+	if err == nil && d.pinnedRequests != nil && len(d.requestsToProcess()) == 0 {
+		// the requests of the published batch were already processed.
+		d.pinnedRequests = nil
+		err = d.loadDeleteRequestsToProcess()
+	}
+	d.pinnedRequests = nil
+	if err != nil {
+		return err
+	}
+
+	reqs := d.requestsToProcess()
+	id := batchID(reqs)
+	if id != publishedID {
+		if id, err = d.sharding.publishBatch(ctx, reqs); err != nil {
+			d.deleteRequestsToProcessMtx.Lock()
+			d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
+			d.deleteRequestsToProcessMtx.Unlock()
+			return fmt.Errorf("failed to publish the delete requests to the other compactors: %w", err)
 		}
-		reqCount++
 	}
 
+	d.deleteRequestsToProcessMtx.Lock()
+	d.shardPhase.batchID = id
+	d.deleteRequestsToProcessMtx.Unlock()
 	return nil
 }
 
+func (d *DeleteRequestsManager) requestsToProcess() []DeleteRequest {
+	d.deleteRequestsToProcessMtx.Lock()
+	defer d.deleteRequestsToProcessMtx.Unlock()
+
+	var reqs []DeleteRequest
+	for _, userDeleteRequests := range d.deleteRequestsToProcess {
+		for _, deleteRequest := range userDeleteRequests.requests {
+			reqs = append(reqs, *deleteRequest)
+		}
+	}
+	return reqs
+}
+
 func (d *DeleteRequestsManager) filteredSortedDeleteRequests() ([]DeleteRequest, error) {
 	deleteRequests, err := d.deleteRequestsStore.GetDeleteRequestsByStatus(context.Background(), StatusReceived)
 	if err != nil {
@@ -219,6 +362,7 @@ func (d *DeleteRequestsManager) requestsForUser(dr DeleteRequest) *userDeleteReq
 				Start: dr.StartTime,
 				End:   dr.EndTime,
 			},
+			processedTables: map[model.Time]struct{}{},
 		}
 		d.deleteRequestsToProcess[dr.UserID] = ur
 	}
@@ -267,6 +411,7 @@ func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time)
 		}
 
 		if ff == nil {
+			atomic.AddInt64(&deleteRequest.ChunksDeleted, 1)
 			level.Info(util_log.Logger).Log(
 				"msg", "no chunks to retain: the whole chunk is deleted",
 				"delete_request_id", deleteRequest.RequestID,
@@ -277,7 +422,7 @@ func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time)
 			d.metrics.deleteRequestsChunksSelectedTotal.WithLabelValues(string(ref.UserID)).Inc()
 			return true, nil
 		}
-		filterFuncs = append(filterFuncs, ff)
+		filterFuncs = append(filterFuncs, countRewrittenChunk(deleteRequest, ff))
 	}
 
 	if len(filterFuncs) == 0 {
@@ -296,9 +441,28 @@ func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time)
 	}
 }
 
+// countRewrittenChunk wraps the filter.Func of a chunk to count the chunk as rewritten by the request once it deletes a line.
+func countRewrittenChunk(deleteRequest *DeleteRequest, ff filter.Func) filter.Func {
+	rewritten := false
+	return func(ts time.Time, s string, structuredMetadata ...labels.Label) bool {
+		if !ff(ts, s, structuredMetadata...) {
+			return false
+		}
+		if !rewritten {
+			rewritten = true
+			atomic.AddInt64(&deleteRequest.ChunksRewritten, 1)
+		}
+		return true
+	}
+}
+
 func (d *DeleteRequestsManager) MarkPhaseStarted() {
 	status := statusSuccess
-	if err := d.loadDeleteRequestsToProcess(); err != nil {
+	load := d.loadDeleteRequestsToProcess
+	if d.sharding != nil {
+		load = d.loadShardedDeleteRequestsToProcess
+	}
+	if err := load(); err != nil {
 		status = statusFail
 		level.Error(util_log.Logger).Log("msg", "failed to load delete requests to process", "err", err)
 	}
@@ -306,23 +470,59 @@ func (d *DeleteRequestsManager) MarkPhaseStarted() {
 }
 
 func (d *DeleteRequestsManager) MarkPhaseFailed() {
-	d.deleteRequestsToProcessMtx.Lock()
-	defer d.deleteRequestsToProcessMtx.Unlock()
-
 	d.metrics.deletionFailures.WithLabelValues("error").Inc()
-	d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
+	d.markRequestsAsFailed("error")
 }
 
 func (d *DeleteRequestsManager) MarkPhaseTimedOut() {
-	d.deleteRequestsToProcessMtx.Lock()
-	defer d.deleteRequestsToProcessMtx.Unlock()
-
 	d.metrics.deletionFailures.WithLabelValues("timeout").Inc()
+	d.markRequestsAsFailed("timeout")
+}
+
+// requestWithProgress is a request of the phase with a snapshot of its progress, to store it without holding
+// deleteRequestsToProcessMtx.
+type requestWithProgress struct {
+	deleteRequest DeleteRequest
+	progress      RequestProgress
+}
+
+// snapshotRequestsToProcess returns the requests of the phase with their progress. It must be called with
+// deleteRequestsToProcessMtx held.
+func (d *DeleteRequestsManager) snapshotRequestsToProcess() []requestWithProgress {
+	var reqs []requestWithProgress
+	for _, userDeleteRequests := range d.deleteRequestsToProcess {
+		if userDeleteRequests == nil {
+			continue
+		}
+		for _, deleteRequest := range userDeleteRequests.requests {
+			reqs = append(reqs, requestWithProgress{deleteRequest: *deleteRequest, progress: deleteRequest.storedProgress()})
+		}
+	}
+	return reqs
+}
+
+// markRequestsAsFailed stores the progress of the requests and adds the failure of their processing to their audit log.
+// The requests are processed again from their progress in the next compaction.
+func (d *DeleteRequestsManager) markRequestsAsFailed(reason string) {
+	d.deleteRequestsToProcessMtx.Lock()
+	follower := d.shardPhase.follower
+	reqs := d.snapshotRequestsToProcess()
 	d.deleteRequestsToProcess = map[string]*userDeleteRequests{}
+	d.deleteRequestsToProcessMtx.Unlock()
+
+	if follower {
+		return
+	}
+	for _, req := range reqs {
+		d.updateProgress(req.deleteRequest, req.progress, auditEvent(req.deleteRequest, loghttp.DeleteRequestProcessingFailed, reason, &req.progress.DeleteRequestProgress))
+	}
 }
 
-func (d *DeleteRequestsManager) markRequestAsProcessed(deleteRequest DeleteRequest) {
-	if err := d.deleteRequestsStore.UpdateStatus(context.Background(), deleteRequest, StatusProcessed); err != nil {
+func (d *DeleteRequestsManager) markRequestAsProcessed(deleteRequest DeleteRequest, progress RequestProgress) {
+	d.updateProgress(deleteRequest, progress)
+
+	event := auditEvent(deleteRequest, loghttp.DeleteRequestProcessed, "", &progress.DeleteRequestProgress)
+	if err := d.deleteRequestsStore.UpdateStatus(context.Background(), deleteRequest, StatusProcessed, event); err != nil {
 		level.Error(util_log.Logger).Log(
 			"msg", "failed to mark delete request for user as processed",
 			"delete_request_id", deleteRequest.RequestID,
@@ -343,33 +543,131 @@ func (d *DeleteRequestsManager) markRequestAsProcessed(deleteRequest DeleteReque
 	}
 }
 
+// updateProgress stores the progress of the request so that it can be followed while the compaction is running,
+// and resumed in the next compactions, along with the audit events of the progress.
+// It must be called without holding deleteRequestsToProcessMtx.
+func (d *DeleteRequestsManager) updateProgress(deleteRequest DeleteRequest, progress RequestProgress, auditEvents ...loghttp.DeleteRequestAuditEvent) {
+	if err := d.deleteRequestsStore.UpdateProgress(context.Background(), deleteRequest, progress, auditEvents...); err != nil {
+		level.Error(util_log.Logger).Log(
+			"msg", "failed to update progress of delete request",
+			"delete_request_id", deleteRequest.RequestID,
+			"sequence_num", deleteRequest.SequenceNum,
+			"user", deleteRequest.UserID,
+			"err", err,
+		)
+	}
+}
+
+// auditEvent returns an event of the compactor for the audit log of the delete request.
+func auditEvent(deleteRequest DeleteRequest, event loghttp.DeleteRequestEvent, reason string, progress *loghttp.DeleteRequestProgress) loghttp.DeleteRequestAuditEvent {
+	sequenceNum := deleteRequest.SequenceNum
+	return loghttp.DeleteRequestAuditEvent{
+		Time:        model.Now(),
+		Event:       event,
+		Actor:       compactorActor,
+		StartTime:   deleteRequest.StartTime,
+		EndTime:     deleteRequest.EndTime,
+		SequenceNum: &sequenceNum,
+		Reason:      reason,
+		Progress:    progress,
+	}
+}
+
 func (d *DeleteRequestsManager) MarkPhaseFinished() {
 	d.deleteRequestsToProcessMtx.Lock()
-	defer d.deleteRequestsToProcessMtx.Unlock()
+	phase := d.shardPhase
+	reqs := d.snapshotRequestsToProcess()
+	d.deleteRequestsToProcessMtx.Unlock()
 
-	for _, userDeleteRequests := range d.deleteRequestsToProcess {
-		if userDeleteRequests == nil {
-			continue
-		}
+	if d.sharding != nil && !d.shardedPhaseFinished(phase) {
+		return
+	}
 
-		for _, deleteRequest := range userDeleteRequests.requests {
-			d.markRequestAsProcessed(*deleteRequest)
-		}
+	for _, req := range reqs {
+		d.markRequestAsProcessed(req.deleteRequest, req.progress)
+	}
+}
+
+// shardedPhaseFinished reports the batch processed by this compactor to the leader. It tells if the requests of the batch
+// can be marked as processed, which is when this compactor is the leader and all the compactors processed the batch.
+func (d *DeleteRequestsManager) shardedPhaseFinished(phase shardPhase) bool {
+	if phase.batchID == "" {
+		return false
+	}
+
+	ctx := context.Background()
+	if err := d.sharding.reportBatch(ctx, phase.batchID, phase.instances); err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to report the processed delete requests", "batch_id", phase.batchID, "err", err)
+		return false
+	}
+	if phase.follower {
+		return false
+	}
+
+	processed, err := d.sharding.batchProcessedByAll(ctx, phase.batchID)
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to check the delete requests processed by the other compactors", "batch_id", phase.batchID, "err", err)
+		return false
+	}
+	if !processed {
+		level.Info(util_log.Logger).Log("msg", "waiting for all the compactors to process the delete requests", "batch_id", phase.batchID)
+		return false
 	}
+	return true
 }
 
-func (d *DeleteRequestsManager) IntervalMayHaveExpiredChunks(_ model.Interval, userID string) bool {
+func (d *DeleteRequestsManager) IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool {
 	d.deleteRequestsToProcessMtx.Lock()
-	defer d.deleteRequestsToProcessMtx.Unlock()
+	updated := d.trackTableProcessing(interval, userID)
 
 	// We can't do the overlap check between the passed interval and delete requests interval from a user because
 	// if a request is issued just for today and there are chunks spanning today and yesterday then
 	// the overlap check would skip processing yesterday's index which would result in the index pointing to deleted chunks.
+	mayHaveExpiredChunks := len(d.deleteRequestsToProcess) != 0
 	if userID != "" {
-		return d.deleteRequestsToProcess[userID] != nil
+		mayHaveExpiredChunks = d.deleteRequestsToProcess[userID] != nil
+	}
+	d.deleteRequestsToProcessMtx.Unlock()
+
+	for _, req := range updated {
+		d.updateProgress(req.deleteRequest, req.progress)
 	}
+	return mayHaveExpiredChunks
+}
+
+// trackTableProcessing counts the table of the interval as processed by the requests overlapping it, and returns
+// the requests whose progress must be stored, with the progress made in the previously processed tables.
+// Each table is counted once per request, even when the processing of the request is resumed in a later compaction.
+// The compactor checks each table with an empty userID before checking the index of each user in it.
+// It must be called with deleteRequestsToProcessMtx held.
+func (d *DeleteRequestsManager) trackTableProcessing(interval model.Interval, userID string) []requestWithProgress {
+	var updated []requestWithProgress
+	for user, userDeleteRequests := range d.deleteRequestsToProcess {
+		if userDeleteRequests == nil || (userID != "" && user != userID) {
+			continue
+		}
+		if _, ok := userDeleteRequests.processedTables[interval.Start]; ok {
+			continue
+		}
+		userDeleteRequests.processedTables[interval.Start] = struct{}{}
+		// the progress is stored by the leader, it only counts the tables it processed.
+		if d.shardPhase.follower {
+			continue
+		}
 
-	return len(d.deleteRequestsToProcess) != 0
+		for _, deleteRequest := range userDeleteRequests.requests {
+			if !intervalsOverlap(interval, model.Interval{Start: deleteRequest.StartTime, End: deleteRequest.EndTime}) {
+				continue
+			}
+			if _, ok := deleteRequest.processedTables[interval.Start]; ok {
+				continue
+			}
+			deleteRequest.processedTables[interval.Start] = struct{}{}
+			atomic.AddInt64(&deleteRequest.TablesProcessed, 1)
+			updated = append(updated, requestWithProgress{deleteRequest: *deleteRequest, progress: deleteRequest.storedProgress()})
+		}
+	}
+	return updated
 }
 
 func (d *DeleteRequestsManager) DropFromIndex(_ retention.ChunkEntry, _ model.Time, _ model.Time) bool {
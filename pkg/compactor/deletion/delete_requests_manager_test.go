@@ -12,6 +12,7 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/compactor/deletionmode"
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/util/filter"
 )
@@ -901,7 +902,7 @@ func TestDeleteRequestsManager_Expired(t *testing.T) {
 			mgr := NewDeleteRequestsManager(mockDeleteRequestsStore, time.Hour, tc.batchSize, &fakeLimits{defaultLimit: limit{
 				retentionPeriod: 7 * 24 * time.Hour,
 				deletionMode:    tc.deletionMode.String(),
-			}}, nil)
+			}}, nil, nil)
 			require.NoError(t, mgr.loadDeleteRequestsToProcess())
 
 			for _, deleteRequests := range mgr.deleteRequestsToProcess {
@@ -968,7 +969,7 @@ func TestDeleteRequestsManager_IntervalMayHaveExpiredChunks(t *testing.T) {
 	}
 
 	for _, tc := range tt {
-		mgr := NewDeleteRequestsManager(&mockDeleteRequestsStore{deleteRequests: tc.deleteRequestsFromStore}, time.Hour, 70, &fakeLimits{defaultLimit: limit{deletionMode: deletionmode.FilterAndDelete.String()}}, nil)
+		mgr := NewDeleteRequestsManager(&mockDeleteRequestsStore{deleteRequests: tc.deleteRequestsFromStore}, time.Hour, 70, &fakeLimits{defaultLimit: limit{deletionMode: deletionmode.FilterAndDelete.String()}}, nil, nil)
 		require.NoError(t, mgr.loadDeleteRequestsToProcess())
 
 		interval := model.Interval{Start: 300, End: 600}
@@ -976,6 +977,122 @@ func TestDeleteRequestsManager_IntervalMayHaveExpiredChunks(t *testing.T) {
 	}
 }
 
+func TestDeleteRequestsManager_Progress(t *testing.T) {
+	now := model.Now()
+	lblFoo := labels.FromStrings("foo", "bar")
+	deleteRequests := []DeleteRequest{
+		{RequestID: "1", UserID: testUserID, Query: `{foo="bar"}`, StartTime: now.Add(-12 * time.Hour), EndTime: now, Status: StatusReceived},
+		{RequestID: "2", UserID: testUserID, Query: `{foo="bar"} |= "fizz"`, StartTime: now.Add(-24 * time.Hour), EndTime: now, Status: StatusReceived},
+	}
+	store := &mockDeleteRequestsStore{deleteRequests: deleteRequests}
+	mgr := NewDeleteRequestsManager(store, time.Hour, 70, &fakeLimits{defaultLimit: limit{deletionMode: deletionmode.FilterAndDelete.String()}}, nil, nil)
+
+	mgr.MarkPhaseStarted()
+	require.Len(t, store.auditLog, 2)
+	for _, event := range store.auditLog {
+		require.Equal(t, loghttp.DeleteRequestProcessingStarted, event.Event)
+		require.Equal(t, compactorActor, event.Actor)
+	}
+
+	// the table is checked for all the users, then for each user.
+	tableInterval := model.Interval{Start: now.Add(-2 * time.Hour), End: now}
+	require.True(t, mgr.IntervalMayHaveExpiredChunks(tableInterval, ""))
+	require.True(t, mgr.IntervalMayHaveExpiredChunks(tableInterval, testUserID))
+	// the tables outside of the interval of the requests are not counted.
+	mgr.IntervalMayHaveExpiredChunks(model.Interval{Start: now.Add(-48 * time.Hour), End: now.Add(-25 * time.Hour)}, "")
+
+	// the whole chunk is deleted by the first request.
+	expired, ff := mgr.Expired(retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{UserID: []byte(testUserID), From: now.Add(-time.Hour), Through: now.Add(-time.Minute)},
+		Labels:   lblFoo,
+	}, now)
+	require.True(t, expired)
+	require.Nil(t, ff)
+
+	// the chunk partially deleted is rewritten by the second request.
+	expired, ff = mgr.Expired(retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{UserID: []byte(testUserID), From: now.Add(-25 * time.Hour), Through: now.Add(-23 * time.Hour)},
+		Labels:   lblFoo,
+	}, now)
+	require.True(t, expired)
+	require.NotNil(t, ff)
+	for _, line := range []string{"fizz", "buzz", "fizz buzz"} {
+		ff(now.Add(-23*time.Hour).Time(), line)
+	}
+
+	mgr.MarkPhaseFinished()
+
+	expectedProgress := []loghttp.DeleteRequestProgress{
+		{TablesProcessed: 1, ChunksDeleted: 1},
+		{TablesProcessed: 1, ChunksRewritten: 1, DeletedLines: 2},
+	}
+	require.Equal(t, expectedProgress[0], store.progress[testUserID+":1"].DeleteRequestProgress)
+	require.Equal(t, expectedProgress[1], store.progress[testUserID+":2"].DeleteRequestProgress)
+	require.Equal(t, []model.Time{tableInterval.Start}, store.progress[testUserID+":1"].Tables)
+
+	require.Len(t, store.auditLog, 4)
+	var processedProgress []loghttp.DeleteRequestProgress
+	for _, event := range store.auditLog[2:] {
+		require.Equal(t, loghttp.DeleteRequestProcessed, event.Event)
+		require.NotNil(t, event.Progress)
+		processedProgress = append(processedProgress, *event.Progress)
+	}
+	require.ElementsMatch(t, expectedProgress, processedProgress)
+}
+
+func TestDeleteRequestsManager_ResumeProgress(t *testing.T) {
+	now := model.Now()
+	lblFoo := labels.FromStrings("foo", "bar")
+	deleteRequests := []DeleteRequest{
+		{RequestID: "1", UserID: testUserID, Query: `{foo="bar"}`, StartTime: now.Add(-48 * time.Hour), EndTime: now, Status: StatusReceived},
+	}
+	store := &mockDeleteRequestsStore{deleteRequests: deleteRequests}
+	mgr := NewDeleteRequestsManager(store, time.Hour, 70, &fakeLimits{defaultLimit: limit{deletionMode: deletionmode.FilterAndDelete.String()}}, nil, nil)
+
+	firstTable := model.Interval{Start: now.Add(-26 * time.Hour), End: now.Add(-24 * time.Hour)}
+	secondTable := model.Interval{Start: now.Add(-2 * time.Hour), End: now}
+	chunk := func(table model.Interval) retention.ChunkEntry {
+		return retention.ChunkEntry{
+			ChunkRef: retention.ChunkRef{UserID: []byte(testUserID), From: table.Start, Through: table.End},
+			Labels:   lblFoo,
+		}
+	}
+
+	// the first compaction fails after processing the first table.
+	mgr.MarkPhaseStarted()
+	require.True(t, mgr.IntervalMayHaveExpiredChunks(firstTable, testUserID))
+	expired, _ := mgr.Expired(chunk(firstTable), now)
+	require.True(t, expired)
+	mgr.MarkPhaseFailed()
+
+	progress := store.progress[testUserID+":1"]
+	require.Equal(t, loghttp.DeleteRequestProgress{TablesProcessed: 1, ChunksDeleted: 1}, progress.DeleteRequestProgress)
+	require.NotZero(t, progress.StartedAt)
+
+	// the next compaction resumes from the stored progress, and processes the first table again without counting it.
+	mgr.MarkPhaseStarted()
+	require.True(t, mgr.IntervalMayHaveExpiredChunks(firstTable, testUserID))
+	require.True(t, mgr.IntervalMayHaveExpiredChunks(secondTable, testUserID))
+	expired, _ = mgr.Expired(chunk(secondTable), now)
+	require.True(t, expired)
+	mgr.MarkPhaseFinished()
+
+	progress = store.progress[testUserID+":1"]
+	require.Equal(t, loghttp.DeleteRequestProgress{TablesProcessed: 2, ChunksDeleted: 2}, progress.DeleteRequestProgress)
+	require.Equal(t, []model.Time{firstTable.Start, secondTable.Start}, progress.Tables)
+
+	// the processing start is only added once to the audit log.
+	var events []loghttp.DeleteRequestEvent
+	for _, event := range store.auditLog {
+		events = append(events, event.Event)
+	}
+	require.Equal(t, []loghttp.DeleteRequestEvent{
+		loghttp.DeleteRequestProcessingStarted,
+		loghttp.DeleteRequestProcessingFailed,
+		loghttp.DeleteRequestProcessed,
+	}, events)
+}
+
 type mockDeleteRequestsStore struct {
 	DeleteRequestsStore
 	deleteRequests           []DeleteRequest
@@ -996,6 +1113,9 @@ type mockDeleteRequestsStore struct {
 	getAllErr    error
 
 	genNumber string
+
+	auditLog []loghttp.DeleteRequestAuditEvent
+	progress map[string]RequestProgress
 }
 
 func (m *mockDeleteRequestsStore) GetDeleteRequestsByStatus(_ context.Context, status DeleteRequestStatus) ([]DeleteRequest, error) {
@@ -1008,16 +1128,22 @@ func (m *mockDeleteRequestsStore) GetDeleteRequestsByStatus(_ context.Context, s
 	return reqs, nil
 }
 
-func (m *mockDeleteRequestsStore) AddDeleteRequestGroup(_ context.Context, reqs []DeleteRequest) ([]DeleteRequest, error) {
+func (m *mockDeleteRequestsStore) AddDeleteRequestGroup(_ context.Context, reqs []DeleteRequest, auditEvents ...loghttp.DeleteRequestAuditEvent) ([]DeleteRequest, error) {
 	m.addReqs = reqs
+	if m.addErr == nil {
+		m.auditLog = append(m.auditLog, auditEvents...)
+	}
 	if m.returnZeroDeleteRequests {
 		return []DeleteRequest{}, m.addErr
 	}
 	return m.addReqs, m.addErr
 }
 
-func (m *mockDeleteRequestsStore) RemoveDeleteRequests(_ context.Context, reqs []DeleteRequest) error {
+func (m *mockDeleteRequestsStore) RemoveDeleteRequests(_ context.Context, reqs []DeleteRequest, auditEvents ...loghttp.DeleteRequestAuditEvent) error {
 	m.removeReqs = reqs
+	if m.removeErr == nil {
+		m.auditLog = append(m.auditLog, auditEvents...)
+	}
 	return m.removeErr
 }
 
@@ -1036,16 +1162,34 @@ func (m *mockDeleteRequestsStore) GetCacheGenerationNumber(_ context.Context, _
 	return m.genNumber, m.getErr
 }
 
-func (m *mockDeleteRequestsStore) UpdateStatus(_ context.Context, req DeleteRequest, newStatus DeleteRequestStatus) error {
+func (m *mockDeleteRequestsStore) UpdateStatus(_ context.Context, req DeleteRequest, newStatus DeleteRequestStatus, auditEvents ...loghttp.DeleteRequestAuditEvent) error {
 	for i := range m.deleteRequests {
 		if requestsAreEqual(m.deleteRequests[i], req) {
 			m.deleteRequests[i].Status = newStatus
 		}
 	}
+	m.auditLog = append(m.auditLog, auditEvents...)
 
 	return nil
 }
 
+func (m *mockDeleteRequestsStore) UpdateProgress(_ context.Context, req DeleteRequest, progress RequestProgress, auditEvents ...loghttp.DeleteRequestAuditEvent) error {
+	if m.progress == nil {
+		m.progress = map[string]RequestProgress{}
+	}
+	m.progress[backwardCompatibleDeleteRequestHash(req.UserID, req.RequestID, req.SequenceNum)] = progress
+	m.auditLog = append(m.auditLog, auditEvents...)
+	return nil
+}
+
+func (m *mockDeleteRequestsStore) GetProgress(_ context.Context, req DeleteRequest) (RequestProgress, error) {
+	return m.progress[backwardCompatibleDeleteRequestHash(req.UserID, req.RequestID, req.SequenceNum)], nil
+}
+
+func (m *mockDeleteRequestsStore) GetAuditLog(_ context.Context, _, _ string) ([]loghttp.DeleteRequestAuditEvent, error) {
+	return m.auditLog, nil
+}
+
 func requestsAreEqual(req1, req2 DeleteRequest) bool {
 	if req1.UserID == req2.UserID &&
 		req1.Query == req2.Query &&
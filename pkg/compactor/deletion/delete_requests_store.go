@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/prometheus/common/model"
 
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/storage/stores/series/index"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/storage"
 )
@@ -30,9 +32,11 @@ const (
 	StatusReceived  DeleteRequestStatus = "received"
 	StatusProcessed DeleteRequestStatus = "processed"
 
-	deleteRequestID      indexType = "1"
-	deleteRequestDetails indexType = "2"
-	cacheGenNum          indexType = "3"
+	deleteRequestID       indexType = "1"
+	deleteRequestDetails  indexType = "2"
+	cacheGenNum           indexType = "3"
+	deleteRequestProgress indexType = "4"
+	deleteRequestAudit    indexType = "5"
 
 	tempFileSuffix          = ".temp"
 	DeleteRequestsTableName = "delete_requests"
@@ -40,14 +44,21 @@ const (
 
 var ErrDeleteRequestNotFound = errors.New("could not find matching delete requests")
 
+// DeleteRequestsStore stores the delete requests. The audit events passed to the methods updating the requests are
+// added to the audit log of the requests in the same write, so that the audit log is only updated with the requests.
 type DeleteRequestsStore interface {
-	AddDeleteRequestGroup(ctx context.Context, req []DeleteRequest) ([]DeleteRequest, error)
+	AddDeleteRequestGroup(ctx context.Context, req []DeleteRequest, auditEvents ...loghttp.DeleteRequestAuditEvent) ([]DeleteRequest, error)
 	GetDeleteRequestsByStatus(ctx context.Context, status DeleteRequestStatus) ([]DeleteRequest, error)
 	GetAllDeleteRequestsForUser(ctx context.Context, userID string) ([]DeleteRequest, error)
-	UpdateStatus(ctx context.Context, req DeleteRequest, newStatus DeleteRequestStatus) error
+	UpdateStatus(ctx context.Context, req DeleteRequest, newStatus DeleteRequestStatus, auditEvents ...loghttp.DeleteRequestAuditEvent) error
 	GetDeleteRequestGroup(ctx context.Context, userID, requestID string) ([]DeleteRequest, error)
-	RemoveDeleteRequests(ctx context.Context, req []DeleteRequest) error
+	RemoveDeleteRequests(ctx context.Context, req []DeleteRequest, auditEvents ...loghttp.DeleteRequestAuditEvent) error
 	GetCacheGenerationNumber(ctx context.Context, userID string) (string, error)
+	UpdateProgress(ctx context.Context, req DeleteRequest, progress RequestProgress, auditEvents ...loghttp.DeleteRequestAuditEvent) error
+	GetProgress(ctx context.Context, req DeleteRequest) (RequestProgress, error)
+	GetAuditLog(ctx context.Context, userID, requestID string) ([]loghttp.DeleteRequestAuditEvent, error)
+	// SetReadOnly makes the store read-only while another compactor manages the delete requests.
+	SetReadOnly(readOnly bool) error
 	Stop()
 	Name() string
 }
@@ -75,9 +86,17 @@ func (ds *deleteRequestsStore) Stop() {
 	ds.indexClient.Stop()
 }
 
+func (ds *deleteRequestsStore) SetReadOnly(readOnly bool) error {
+	table, ok := ds.indexClient.(*deleteRequestsTable)
+	if !ok {
+		return fmt.Errorf("index client %T does not support read-only mode", ds.indexClient)
+	}
+	return table.SetReadOnly(readOnly)
+}
+
 // AddDeleteRequestGroup creates entries for new delete requests. All passed delete requests will be associated to
-// each other by request id
-func (ds *deleteRequestsStore) AddDeleteRequestGroup(ctx context.Context, reqs []DeleteRequest) ([]DeleteRequest, error) {
+// each other by request id. The audit events are added at the creation time of the requests.
+func (ds *deleteRequestsStore) AddDeleteRequestGroup(ctx context.Context, reqs []DeleteRequest, auditEvents ...loghttp.DeleteRequestAuditEvent) ([]DeleteRequest, error) {
 	if len(reqs) == 0 {
 		return nil, nil
 	}
@@ -100,6 +119,13 @@ func (ds *deleteRequestsStore) AddDeleteRequestGroup(ctx context.Context, reqs [
 		ds.writeDeleteRequest(newReq, writeBatch)
 	}
 
+	for i := range auditEvents {
+		auditEvents[i].Time = createdAt
+	}
+	if err := writeAuditEvents(writeBatch, reqs[0].UserID, string(requestID), auditEvents); err != nil {
+		return nil, err
+	}
+
 	if err := ds.indexClient.BatchWrite(ctx, writeBatch); err != nil {
 		return nil, err
 	}
@@ -182,7 +208,7 @@ func (ds *deleteRequestsStore) GetAllDeleteRequestsForUser(ctx context.Context,
 }
 
 // UpdateStatus updates status of a delete request.
-func (ds *deleteRequestsStore) UpdateStatus(ctx context.Context, req DeleteRequest, newStatus DeleteRequestStatus) error {
+func (ds *deleteRequestsStore) UpdateStatus(ctx context.Context, req DeleteRequest, newStatus DeleteRequestStatus, auditEvents ...loghttp.DeleteRequestAuditEvent) error {
 	userIDAndRequestID := backwardCompatibleDeleteRequestHash(req.UserID, req.RequestID, req.SequenceNum)
 
 	writeBatch := ds.indexClient.NewWriteBatch()
@@ -192,6 +218,9 @@ func (ds *deleteRequestsStore) UpdateStatus(ctx context.Context, req DeleteReque
 		// remove runtime filtering for deleted data
 		writeBatch.Add(DeleteRequestsTableName, fmt.Sprintf("%s:%s", cacheGenNum, req.UserID), []byte{}, generateCacheGenNumber())
 	}
+	if err := writeAuditEvents(writeBatch, req.UserID, req.RequestID, auditEvents); err != nil {
+		return err
+	}
 
 	return ds.indexClient.BatchWrite(ctx, writeBatch)
 }
@@ -326,13 +355,104 @@ func unmarshalDeleteRequestDetails(itr index.ReadBatchIterator, req DeleteReques
 	return requestWithDetails, nil
 }
 
+// UpdateProgress updates the progress of the processing of a delete request.
+func (ds *deleteRequestsStore) UpdateProgress(ctx context.Context, req DeleteRequest, progress RequestProgress, auditEvents ...loghttp.DeleteRequestAuditEvent) error {
+	value, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	userIDAndRequestID := backwardCompatibleDeleteRequestHash(req.UserID, req.RequestID, req.SequenceNum)
+	writeBatch := ds.indexClient.NewWriteBatch()
+	writeBatch.Add(DeleteRequestsTableName, fmt.Sprintf("%s:%s", deleteRequestProgress, userIDAndRequestID), []byte{}, value)
+	if err := writeAuditEvents(writeBatch, req.UserID, req.RequestID, auditEvents); err != nil {
+		return err
+	}
+
+	return ds.indexClient.BatchWrite(ctx, writeBatch)
+}
+
+// GetProgress returns the progress of the processing of a delete request, which is empty if its processing has not started.
+func (ds *deleteRequestsStore) GetProgress(ctx context.Context, req DeleteRequest) (RequestProgress, error) {
+	userIDAndRequestID := backwardCompatibleDeleteRequestHash(req.UserID, req.RequestID, req.SequenceNum)
+	query := index.Query{TableName: DeleteRequestsTableName, HashValue: fmt.Sprintf("%s:%s", deleteRequestProgress, userIDAndRequestID)}
+
+	var progress RequestProgress
+	var unmarshalErr error
+	err := ds.indexClient.QueryPages(ctx, []index.Query{query}, func(_ index.Query, batch index.ReadBatchResult) (shouldContinue bool) {
+		itr := batch.Iterator()
+		for itr.Next() {
+			unmarshalErr = json.Unmarshal(itr.Value(), &progress)
+			break
+		}
+		return false
+	})
+	if err != nil {
+		return RequestProgress{}, err
+	}
+
+	return progress, unmarshalErr
+}
+
+// writeAuditEvents appends events to the audit log of a delete request in writeBatch.
+// The audit log is never updated nor removed, even when the delete request is cancelled.
+func writeAuditEvents(writeBatch index.WriteBatch, userID, requestID string, events []loghttp.DeleteRequestAuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	hashValue := fmt.Sprintf("%s:%s:%s", deleteRequestAudit, userID, requestID)
+	now := time.Now().UnixNano()
+	for i, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		// the range value keeps the events in the order they were added.
+		rangeValue := fmt.Sprintf("%016x:%x", now, i)
+		writeBatch.Add(DeleteRequestsTableName, hashValue, []byte(rangeValue), value)
+	}
+
+	return nil
+}
+
+// GetAuditLog returns the audit log of a delete request in the order its events were added.
+func (ds *deleteRequestsStore) GetAuditLog(ctx context.Context, userID, requestID string) ([]loghttp.DeleteRequestAuditEvent, error) {
+	query := index.Query{TableName: DeleteRequestsTableName, HashValue: fmt.Sprintf("%s:%s:%s", deleteRequestAudit, userID, requestID)}
+
+	var events []loghttp.DeleteRequestAuditEvent
+	var unmarshalErr error
+	err := ds.indexClient.QueryPages(ctx, []index.Query{query}, func(_ index.Query, batch index.ReadBatchResult) (shouldContinue bool) {
+		itr := batch.Iterator()
+		for itr.Next() {
+			var event loghttp.DeleteRequestAuditEvent
+			if unmarshalErr = json.Unmarshal(itr.Value(), &event); unmarshalErr != nil {
+				return false
+			}
+			events = append(events, event)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, unmarshalErr
+}
+
 // RemoveDeleteRequests the passed delete requests
-func (ds *deleteRequestsStore) RemoveDeleteRequests(ctx context.Context, reqs []DeleteRequest) error {
+func (ds *deleteRequestsStore) RemoveDeleteRequests(ctx context.Context, reqs []DeleteRequest, auditEvents ...loghttp.DeleteRequestAuditEvent) error {
 	writeBatch := ds.indexClient.NewWriteBatch()
 
 	for _, r := range reqs {
 		ds.removeRequest(r, writeBatch)
 	}
+	if len(reqs) > 0 {
+		if err := writeAuditEvents(writeBatch, reqs[0].UserID, reqs[0].RequestID, auditEvents); err != nil {
+			return err
+		}
+	}
 
 	return ds.indexClient.BatchWrite(ctx, writeBatch)
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/storage"
 )
@@ -132,6 +133,54 @@ func TestDeleteRequestsStore(t *testing.T) {
 	require.NotEqual(t, updateGenNumber2, deleteGenNumber2)
 }
 
+func TestDeleteRequestsStore_ProgressAndAuditLog(t *testing.T) {
+	tc := setup(t)
+	defer tc.store.Stop()
+
+	// the audit events are added to the audit log with the requests.
+	created := loghttp.DeleteRequestAuditEvent{Event: loghttp.DeleteRequestCreated, Actor: "jane", Shards: 2}
+	requests, err := tc.store.AddDeleteRequestGroup(context.Background(), tc.user1Requests[:2], created)
+	require.NoError(t, err)
+	created.Time = requests[0].CreatedAt
+
+	// the progress of a request is empty until its processing starts, and is kept per sequence number.
+	progress, err := tc.store.GetProgress(context.Background(), requests[0])
+	require.NoError(t, err)
+	require.Equal(t, RequestProgress{}, progress)
+
+	started := loghttp.DeleteRequestAuditEvent{Time: 1, Event: loghttp.DeleteRequestProcessingStarted, Actor: compactorActor}
+	require.NoError(t, tc.store.UpdateProgress(context.Background(), requests[0], RequestProgress{
+		DeleteRequestProgress: loghttp.DeleteRequestProgress{TablesProcessed: 1},
+		StartedAt:             1,
+		Tables:                []model.Time{10},
+	}, started))
+	expected := RequestProgress{
+		DeleteRequestProgress: loghttp.DeleteRequestProgress{TablesProcessed: 2, DeletedLines: 10},
+		StartedAt:             1,
+		Tables:                []model.Time{10, 20},
+	}
+	require.NoError(t, tc.store.UpdateProgress(context.Background(), requests[0], expected))
+	progress, err = tc.store.GetProgress(context.Background(), requests[0])
+	require.NoError(t, err)
+	require.Equal(t, expected, progress)
+
+	progress, err = tc.store.GetProgress(context.Background(), requests[1])
+	require.NoError(t, err)
+	require.Equal(t, RequestProgress{}, progress)
+
+	// the audit log keeps the events in order, even after the request is removed.
+	cancelled := loghttp.DeleteRequestAuditEvent{Time: 2, Event: loghttp.DeleteRequestCancelled, Actor: "john", Shards: 2}
+	require.NoError(t, tc.store.RemoveDeleteRequests(context.Background(), requests, cancelled))
+
+	auditLog, err := tc.store.GetAuditLog(context.Background(), user1, requests[0].RequestID)
+	require.NoError(t, err)
+	require.Equal(t, []loghttp.DeleteRequestAuditEvent{created, started, cancelled}, auditLog)
+
+	auditLog, err = tc.store.GetAuditLog(context.Background(), user2, requests[0].RequestID)
+	require.NoError(t, err)
+	require.Empty(t, auditLog)
+}
+
 func TestBatchCreateGet(t *testing.T) {
 	t.Run("it adds the requests with different sequence numbers but the same request id, status, and creation time", func(t *testing.T) {
 		tc := setup(t)
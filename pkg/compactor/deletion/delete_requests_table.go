@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -26,13 +27,23 @@ type deleteRequestsTable struct {
 	dbPath             string
 
 	boltdbIndexClient *local.BoltIndexClient
-	db                *bbolt.DB
-	done              chan struct{}
-	wg                sync.WaitGroup
+	// dbMtx protects db from being used while it is reloaded.
+	dbMtx sync.RWMutex
+	db    *bbolt.DB
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	// readOnly is set when another compactor writes the delete requests. The db is then reloaded from the storage
+	// periodically instead of being uploaded.
+	readOnly atomic.Bool
+	// modified is set when the db was written since it was last uploaded.
+	modified atomic.Bool
 }
 
 const deleteRequestsIndexFileName = DeleteRequestsTableName + ".gz"
 
+var errReadOnlyDeleteRequestsTable = errors.New("delete requests are managed by another compactor")
+
 func newDeleteRequestsTable(workingDirectory string, indexStorageClient storage.Client) (index.Client, error) {
 	dbPath := filepath.Join(workingDirectory, DeleteRequestsTableName, DeleteRequestsTableName)
 	boltdbIndexClient, err := local.NewBoltDBIndexClient(local.BoltDBConfig{Directory: filepath.Dir(dbPath)})
@@ -66,11 +77,7 @@ func (t *deleteRequestsTable) init() error {
 
 	_, err := os.Stat(t.dbPath)
 	if err != nil {
-		err = storage.DownloadFileFromStorage(t.dbPath, true,
-			true, storage.LoggerWithFilename(util_log.Logger, deleteRequestsIndexFileName), func() (io.ReadCloser, error) {
-				return t.indexStorageClient.GetFile(context.Background(), DeleteRequestsTableName, deleteRequestsIndexFileName)
-			})
-		if err != nil && !t.indexStorageClient.IsFileNotFoundErr(err) {
+		if err := t.download(); err != nil {
 			return err
 		}
 	}
@@ -79,6 +86,63 @@ func (t *deleteRequestsTable) init() error {
 	return err
 }
 
+func (t *deleteRequestsTable) download() error {
+	err := storage.DownloadFileFromStorage(t.dbPath, true,
+		true, storage.LoggerWithFilename(util_log.Logger, deleteRequestsIndexFileName), func() (io.ReadCloser, error) {
+			return t.indexStorageClient.GetFile(context.Background(), DeleteRequestsTableName, deleteRequestsIndexFileName)
+		})
+	if err != nil && !t.indexStorageClient.IsFileNotFoundErr(err) {
+		return err
+	}
+	return nil
+}
+
+// reload replaces the db with the one in the storage.
+func (t *deleteRequestsTable) reload() error {
+	t.dbMtx.Lock()
+	defer t.dbMtx.Unlock()
+
+	if err := t.db.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(t.dbPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := t.download(); err != nil {
+		return err
+	}
+
+	db, err := shipper_util.SafeOpenBoltdbFile(t.dbPath)
+	if err != nil {
+		return err
+	}
+	t.db = db
+	t.modified.Store(false)
+	return nil
+}
+
+// SetReadOnly switches the table between read-only and writable. A writable table is reloaded from the storage since
+// the compactor which wrote the delete requests before may have uploaded newer ones.
+func (t *deleteRequestsTable) SetReadOnly(readOnly bool) error {
+	if t.readOnly.Load() == readOnly {
+		return nil
+	}
+
+	if readOnly {
+		t.readOnly.Store(true)
+		if t.modified.Load() {
+			return t.uploadFile()
+		}
+		return nil
+	}
+
+	if err := t.reload(); err != nil {
+		return err
+	}
+	t.readOnly.Store(false)
+	return nil
+}
+
 func (t *deleteRequestsTable) loop() {
 	uploadTicker := time.NewTicker(5 * time.Minute)
 	defer uploadTicker.Stop()
@@ -88,6 +152,12 @@ func (t *deleteRequestsTable) loop() {
 	for {
 		select {
 		case <-uploadTicker.C:
+			if t.readOnly.Load() {
+				if err := t.reload(); err != nil {
+					level.Error(util_log.Logger).Log("msg", "failed to reload delete requests file", "err", err)
+				}
+				continue
+			}
 			if err := t.uploadFile(); err != nil {
 				level.Error(util_log.Logger).Log("msg", "failed to upload delete requests file", "err", err)
 			}
@@ -97,7 +167,7 @@ func (t *deleteRequestsTable) loop() {
 	}
 }
 
-func (t *deleteRequestsTable) uploadFile() error {
+func (t *deleteRequestsTable) uploadFile() (err error) {
 	level.Debug(util_log.Logger).Log("msg", "uploading delete requests db")
 
 	tempFilePath := fmt.Sprintf("%s.%s", t.dbPath, tempFileSuffix)
@@ -116,6 +186,15 @@ func (t *deleteRequestsTable) uploadFile() error {
 		}
 	}()
 
+	t.dbMtx.RLock()
+	defer t.dbMtx.RUnlock()
+	// the writes made while uploading the file are uploaded the next time.
+	t.modified.Store(false)
+	defer func() {
+		if err != nil {
+			t.modified.Store(true)
+		}
+	}()
 	err = t.db.View(func(tx *bbolt.Tx) (err error) {
 		gzipPool := compression.GetWriterPool(compression.GZIP)
 		compressedWriter := gzipPool.GetWriter(f)
@@ -151,8 +230,10 @@ func (t *deleteRequestsTable) Stop() {
 	close(t.done)
 	t.wg.Wait()
 
-	if err := t.uploadFile(); err != nil {
-		level.Error(util_log.Logger).Log("msg", "failed to upload delete requests file during shutdown", "err", err)
+	if !t.readOnly.Load() {
+		if err := t.uploadFile(); err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to upload delete requests file during shutdown", "err", err)
+		}
 	}
 
 	if err := t.db.Close(); err != nil {
@@ -171,7 +252,13 @@ func (t *deleteRequestsTable) BatchWrite(ctx context.Context, batch index.WriteB
 	if !ok {
 		return errors.New("invalid write batch")
 	}
+	if t.readOnly.Load() {
+		return errReadOnlyDeleteRequestsTable
+	}
 
+	t.dbMtx.RLock()
+	defer t.dbMtx.RUnlock()
+	t.modified.Store(true)
 	for _, tableWrites := range boltWriteBatch.Writes {
 		if err := local.WriteToDB(ctx, t.db, local.IndexBucketName, tableWrites); err != nil {
 			return err
@@ -182,6 +269,8 @@ func (t *deleteRequestsTable) BatchWrite(ctx context.Context, batch index.WriteB
 }
 
 func (t *deleteRequestsTable) QueryPages(ctx context.Context, queries []index.Query, callback index.QueryPagesCallback) error {
+	t.dbMtx.RLock()
+	defer t.dbMtx.RUnlock()
 	for _, query := range queries {
 		if err := local.QueryDB(ctx, t.db, local.IndexBucketName, query, callback); err != nil {
 			return err
@@ -2,6 +2,8 @@ package deletion
 
 import (
 	"context"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
 )
 
 func NewNoOpDeleteRequestsStore() DeleteRequestsStore {
@@ -10,7 +12,7 @@ func NewNoOpDeleteRequestsStore() DeleteRequestsStore {
 
 type noOpDeleteRequestsStore struct{}
 
-func (d *noOpDeleteRequestsStore) AddDeleteRequestGroup(_ context.Context, _ []DeleteRequest) ([]DeleteRequest, error) {
+func (d *noOpDeleteRequestsStore) AddDeleteRequestGroup(_ context.Context, _ []DeleteRequest, _ ...loghttp.DeleteRequestAuditEvent) ([]DeleteRequest, error) {
 	return nil, nil
 }
 
@@ -22,7 +24,7 @@ func (d *noOpDeleteRequestsStore) GetAllDeleteRequestsForUser(_ context.Context,
 	return nil, nil
 }
 
-func (d *noOpDeleteRequestsStore) UpdateStatus(_ context.Context, _ DeleteRequest, _ DeleteRequestStatus) error {
+func (d *noOpDeleteRequestsStore) UpdateStatus(_ context.Context, _ DeleteRequest, _ DeleteRequestStatus, _ ...loghttp.DeleteRequestAuditEvent) error {
 	return nil
 }
 
@@ -30,7 +32,7 @@ func (d *noOpDeleteRequestsStore) GetDeleteRequestGroup(_ context.Context, _, _
 	return nil, nil
 }
 
-func (d *noOpDeleteRequestsStore) RemoveDeleteRequests(_ context.Context, _ []DeleteRequest) error {
+func (d *noOpDeleteRequestsStore) RemoveDeleteRequests(_ context.Context, _ []DeleteRequest, _ ...loghttp.DeleteRequestAuditEvent) error {
 	return nil
 }
 
@@ -38,6 +40,22 @@ func (d *noOpDeleteRequestsStore) GetCacheGenerationNumber(_ context.Context, _
 	return "", nil
 }
 
+func (d *noOpDeleteRequestsStore) UpdateProgress(_ context.Context, _ DeleteRequest, _ RequestProgress, _ ...loghttp.DeleteRequestAuditEvent) error {
+	return nil
+}
+
+func (d *noOpDeleteRequestsStore) GetProgress(_ context.Context, _ DeleteRequest) (RequestProgress, error) {
+	return RequestProgress{}, nil
+}
+
+func (d *noOpDeleteRequestsStore) GetAuditLog(_ context.Context, _, _ string) ([]loghttp.DeleteRequestAuditEvent, error) {
+	return nil, nil
+}
+
+func (d *noOpDeleteRequestsStore) SetReadOnly(_ bool) error {
+	return nil
+}
+
 func (d *noOpDeleteRequestsStore) Stop() {}
 
 func (d *noOpDeleteRequestsStore) Name() string {
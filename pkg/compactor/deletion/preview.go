@@ -0,0 +1,182 @@
+package deletion
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+)
+
+// Preview is the estimate of the data deleted by a delete request, returned by the dry-run endpoint.
+// Streams and Chunks are the streams and chunks matching the selector of the request in its interval,
+// the line filters of the request might only delete lines of some of them.
+// The deleted lines and affected chunks are extrapolated from a sample of the chunks.
+type Preview struct {
+	Streams                 int   `json:"streams"`
+	Chunks                  int   `json:"chunks"`
+	SampledChunks           int   `json:"sampled_chunks"`
+	SampledLines            int64 `json:"sampled_lines"`
+	SampledDeletedLines     int64 `json:"sampled_deleted_lines"`
+	EstimatedAffectedChunks int64 `json:"estimated_affected_chunks"`
+	EstimatedDeletedLines   int64 `json:"estimated_deleted_lines"`
+}
+
+// Previewer estimates the data deleted by a delete request without adding it.
+type Previewer interface {
+	PreviewDeleteRequest(ctx context.Context, req DeleteRequest) (Preview, error)
+}
+
+// ChunkFetcher fetches chunks from the object store.
+type ChunkFetcher interface {
+	GetChunks(ctx context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error)
+}
+
+type sampledChunk struct {
+	entry   retention.ChunkEntry
+	fetcher ChunkFetcher
+}
+
+// PreviewBuilder builds the Preview of a delete request from the chunk entries of the index.
+// It keeps a uniform sample of the chunks matching the request to count the deleted lines.
+type PreviewBuilder struct {
+	req        *DeleteRequest
+	maxSampled int
+	rnd        *rand.Rand
+
+	streams map[uint64]struct{}
+	chunks  int
+	sample  []sampledChunk
+}
+
+// NewPreviewBuilder creates a PreviewBuilder sampling up to maxSampledChunks chunks.
+func NewPreviewBuilder(req *DeleteRequest, maxSampledChunks int) *PreviewBuilder {
+	return &PreviewBuilder{
+		req:        req,
+		maxSampled: maxSampledChunks,
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		streams:    map[uint64]struct{}{},
+	}
+}
+
+// ChunkEntryCallback returns the callback adding the chunk entries of an index, whose chunks are fetched with fetcher.
+// The callback never deletes chunks.
+func (b *PreviewBuilder) ChunkEntryCallback(fetcher ChunkFetcher) retention.ChunkEntryCallback {
+	return func(entry retention.ChunkEntry) (bool, error) {
+		if deleted, _ := b.req.IsDeleted(entry); !deleted {
+			return false, nil
+		}
+
+		b.streams[entry.Labels.Hash()] = struct{}{}
+		b.chunks++
+
+		// reservoir sampling of the chunks.
+		i := b.chunks - 1
+		if len(b.sample) >= b.maxSampled {
+			i = b.rnd.Intn(b.chunks)
+			if i >= b.maxSampled {
+				return false, nil
+			}
+		}
+
+		// the index reuses the buffers of the entries.
+		sampled := sampledChunk{
+			entry: retention.ChunkEntry{
+				ChunkRef: retention.ChunkRef{
+					UserID:   append([]byte(nil), entry.UserID...),
+					SeriesID: append([]byte(nil), entry.SeriesID...),
+					ChunkID:  append([]byte(nil), entry.ChunkID...),
+					From:     entry.From,
+					Through:  entry.Through,
+				},
+				Labels: entry.Labels.Copy(),
+			},
+			fetcher: fetcher,
+		}
+		if i < len(b.sample) {
+			b.sample[i] = sampled
+		} else {
+			b.sample = append(b.sample, sampled)
+		}
+		return false, nil
+	}
+}
+
+// Build fetches the sampled chunks and returns the Preview.
+func (b *PreviewBuilder) Build(ctx context.Context) (Preview, error) {
+	preview := Preview{
+		Streams:       len(b.streams),
+		Chunks:        b.chunks,
+		SampledChunks: len(b.sample),
+	}
+
+	var affected int64
+	for _, s := range b.sample {
+		lines, deleted, err := b.countLines(ctx, s)
+		if err != nil {
+			return Preview{}, err
+		}
+		preview.SampledLines += lines
+		preview.SampledDeletedLines += deleted
+		if deleted > 0 {
+			affected++
+		}
+	}
+
+	if preview.SampledChunks > 0 {
+		ratio := float64(preview.Chunks) / float64(preview.SampledChunks)
+		preview.EstimatedAffectedChunks = int64(math.Round(float64(affected) * ratio))
+		preview.EstimatedDeletedLines = int64(math.Round(float64(preview.SampledDeletedLines) * ratio))
+	}
+	return preview, nil
+}
+
+// countLines returns the number of lines of the sampled chunk and the number of lines deleted by the request.
+func (b *PreviewBuilder) countLines(ctx context.Context, s sampledChunk) (int64, int64, error) {
+	userID := string(s.entry.UserID)
+	chk, err := chunk.ParseExternalKey(userID, string(s.entry.ChunkID))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	chks, err := s.fetcher.GetChunks(ctx, []chunk.Chunk{chk})
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(chks) != 1 {
+		return 0, 0, fmt.Errorf("expected 1 entry for chunk %s but found %d in storage", s.entry.ChunkID, len(chks))
+	}
+
+	facade, ok := chks[0].Data.(*chunkenc.Facade)
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected chunk data type %T for chunk %s", chks[0].Data, s.entry.ChunkID)
+	}
+
+	// a nil filter.Func means the whole chunk is deleted.
+	_, filterFunc := b.req.IsDeleted(s.entry)
+
+	// add a millisecond to the end time because the Chunk.Iterator considers the end time to be non-inclusive.
+	itr, err := facade.LokiChunk().Iterator(ctx, s.entry.From.Time(), s.entry.Through.Time().Add(time.Millisecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.EmptyLabels()))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer itr.Close()
+
+	var lines, deleted int64
+	for itr.Next() {
+		entry := itr.At()
+		lines++
+		if filterFunc == nil || filterFunc(entry.Timestamp, entry.Line, logproto.FromLabelAdaptersToLabels(entry.StructuredMetadata)...) {
+			deleted++
+		}
+	}
+	return lines, deleted, itr.Err()
+}
@@ -0,0 +1,174 @@
+package deletion
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compression"
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+)
+
+type mockChunkFetcher map[string]chunk.Chunk
+
+func (f mockChunkFetcher) GetChunks(_ context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	var result []chunk.Chunk
+	for _, c := range chunks {
+		if fetched, ok := f[config.SchemaConfig{}.ExternalKey(c.ChunkRef)]; ok {
+			result = append(result, fetched)
+		}
+	}
+	return result, nil
+}
+
+// add stores a chunk of 10 lines, every other line logged by the user 1, and returns its entry in the index.
+func (f mockChunkFetcher) add(t *testing.T, userID string, lbs labels.Labels, from model.Time) retention.ChunkEntry {
+	t.Helper()
+	chunkEnc := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, compression.Snappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, 256*1024, 1500*1024)
+	through := from
+	for i := 0; i < 10; i++ {
+		through = from.Add(time.Duration(i) * time.Minute)
+		dup, err := chunkEnc.Append(&logproto.Entry{
+			Timestamp:          through.Time(),
+			Line:               fmt.Sprintf("user_id=%d msg=%q", i%2, "hello"),
+			StructuredMetadata: logproto.FromLabelsToLabelAdapters(labels.FromStrings("trace_id", fmt.Sprintf("%d", i%5))),
+		})
+		require.False(t, dup)
+		require.NoError(t, err)
+	}
+	require.NoError(t, chunkEnc.Close())
+
+	c := chunk.NewChunk(userID, client.Fingerprint(lbs), lbs, chunkenc.NewFacade(chunkEnc, 256*1024, 1500*1024), from, through)
+	require.NoError(t, c.Encode())
+	key := config.SchemaConfig{}.ExternalKey(c.ChunkRef)
+	f[key] = c
+
+	return retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{
+			UserID:   []byte(userID),
+			SeriesID: []byte(lbs.String()),
+			ChunkID:  []byte(key),
+			From:     from,
+			Through:  through,
+		},
+		Labels: lbs,
+	}
+}
+
+func TestPreviewBuilder(t *testing.T) {
+	fetcher := mockChunkFetcher{}
+	now := model.Now()
+	entries := []retention.ChunkEntry{
+		fetcher.add(t, user1, labels.FromStrings("foo", "bar", "pod", "a"), now.Add(-2*time.Hour)),
+		fetcher.add(t, user1, labels.FromStrings("foo", "bar", "pod", "a"), now.Add(-time.Hour)),
+		fetcher.add(t, user1, labels.FromStrings("foo", "bar", "pod", "b"), now.Add(-time.Hour)),
+		fetcher.add(t, user1, labels.FromStrings("foo", "other"), now.Add(-time.Hour)),
+		fetcher.add(t, user2, labels.FromStrings("foo", "bar", "pod", "a"), now.Add(-time.Hour)),
+	}
+
+	for _, tc := range []struct {
+		name             string
+		query            string
+		maxSampledChunks int
+		expected         Preview
+	}{
+		{
+			name:             "whole chunks",
+			query:            `{foo="bar"}`,
+			maxSampledChunks: 10,
+			expected: Preview{
+				Streams:                 2,
+				Chunks:                  3,
+				SampledChunks:           3,
+				SampledLines:            30,
+				SampledDeletedLines:     30,
+				EstimatedAffectedChunks: 3,
+				EstimatedDeletedLines:   30,
+			},
+		},
+		{
+			name:             "parsed label filter",
+			query:            `{foo="bar"} | logfmt | user_id="1"`,
+			maxSampledChunks: 10,
+			expected: Preview{
+				Streams:                 2,
+				Chunks:                  3,
+				SampledChunks:           3,
+				SampledLines:            30,
+				SampledDeletedLines:     15,
+				EstimatedAffectedChunks: 3,
+				EstimatedDeletedLines:   15,
+			},
+		},
+		{
+			name:             "structured metadata filter",
+			query:            `{foo="bar", pod="a"} | trace_id="3"`,
+			maxSampledChunks: 10,
+			expected: Preview{
+				Streams:                 1,
+				Chunks:                  2,
+				SampledChunks:           2,
+				SampledLines:            20,
+				SampledDeletedLines:     4,
+				EstimatedAffectedChunks: 2,
+				EstimatedDeletedLines:   4,
+			},
+		},
+		{
+			name:             "no line deleted",
+			query:            `{foo="bar"} |= "goodbye"`,
+			maxSampledChunks: 10,
+			expected: Preview{
+				Streams:       2,
+				Chunks:        3,
+				SampledChunks: 3,
+				SampledLines:  30,
+			},
+		},
+		{
+			name:             "sampled chunks",
+			query:            `{foo="bar"} | logfmt | user_id="1"`,
+			maxSampledChunks: 1,
+			expected: Preview{
+				Streams:                 2,
+				Chunks:                  3,
+				SampledChunks:           1,
+				SampledLines:            10,
+				SampledDeletedLines:     5,
+				EstimatedAffectedChunks: 3,
+				EstimatedDeletedLines:   15,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := DeleteRequest{
+				UserID:    user1,
+				StartTime: now.Add(-3 * time.Hour),
+				EndTime:   now,
+			}
+			require.NoError(t, req.SetQuery(tc.query))
+
+			builder := NewPreviewBuilder(&req, tc.maxSampledChunks)
+			callback := builder.ChunkEntryCallback(fetcher)
+			for _, entry := range entries {
+				deleteChunk, err := callback(entry)
+				require.NoError(t, err)
+				require.False(t, deleteChunk)
+			}
+
+			preview, err := builder.Build(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, preview)
+		})
+	}
+}
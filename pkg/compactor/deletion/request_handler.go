@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/util"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
@@ -23,15 +24,22 @@ import (
 // DeleteRequestHandler provides handlers for delete requests
 type DeleteRequestHandler struct {
 	deleteRequestsStore DeleteRequestsStore
+	previewer           Previewer
 	metrics             *deleteRequestHandlerMetrics
 	maxInterval         time.Duration
+	requesterHeader     string
 }
 
-// NewDeleteRequestHandler creates a DeleteRequestHandler
-func NewDeleteRequestHandler(deleteStore DeleteRequestsStore, maxInterval time.Duration, registerer prometheus.Registerer) *DeleteRequestHandler {
+// NewDeleteRequestHandler creates a DeleteRequestHandler.
+// The requester of the delete requests added to their audit log is read from the requesterHeader header.
+// Loki does not authenticate the header: it must be set by an authenticating gateway that drops the header of the
+// client requests, otherwise anyone sending a delete request can record any requester.
+func NewDeleteRequestHandler(deleteStore DeleteRequestsStore, previewer Previewer, maxInterval time.Duration, requesterHeader string, registerer prometheus.Registerer) *DeleteRequestHandler {
 	deleteMgr := DeleteRequestHandler{
 		deleteRequestsStore: deleteStore,
+		previewer:           previewer,
 		maxInterval:         maxInterval,
+		requesterHeader:     requesterHeader,
 		metrics:             newDeleteRequestHandlerMetrics(registerer),
 	}
 
@@ -78,8 +86,16 @@ func (dm *DeleteRequestHandler) AddDeleteRequestHandler(w http.ResponseWriter, r
 		shardByInterval = endTime.Sub(startTime) + time.Minute
 	}
 
+	requester := dm.requester(r)
 	deleteRequests := shardDeleteRequestsByInterval(startTime, endTime, query, userID, shardByInterval)
-	createdDeleteRequests, err := dm.deleteRequestsStore.AddDeleteRequestGroup(ctx, deleteRequests)
+	createdDeleteRequests, err := dm.deleteRequestsStore.AddDeleteRequestGroup(ctx, deleteRequests, loghttp.DeleteRequestAuditEvent{
+		Event:     loghttp.DeleteRequestCreated,
+		Actor:     requester,
+		Query:     query,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Shards:    len(deleteRequests),
+	})
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "error adding delete request to the store", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -96,6 +112,7 @@ func (dm *DeleteRequestHandler) AddDeleteRequestHandler(w http.ResponseWriter, r
 		"msg", "delete request for user added",
 		"delete_request_id", createdDeleteRequests[0].RequestID,
 		"user", userID,
+		"requester", requester,
 		"query", query,
 		"interval", shardByInterval.String(),
 	)
@@ -104,6 +121,62 @@ func (dm *DeleteRequestHandler) AddDeleteRequestHandler(w http.ResponseWriter, r
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// DryRunDeleteRequestHandler estimates the chunks, streams and lines deleted by a delete request without adding it.
+func (dm *DeleteRequestHandler) DryRunDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+	query, _, err := query(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	startTime, err := startTime(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	endTime, err := endTime(params, startTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := DeleteRequest{
+		StartTime: startTime,
+		EndTime:   endTime,
+		UserID:    userID,
+	}
+	if err := req.SetQuery(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	preview, err := dm.previewer.PreviewDeleteRequest(ctx, req)
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "error previewing delete request", "user", userID, "query", query, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		level.Error(util_log.Logger).Log("msg", "error marshalling response", "err", err)
+		http.Error(w, fmt.Sprintf("Error marshalling response: %v", err), http.StatusInternalServerError)
+	}
+}
+
 func shardDeleteRequestsByInterval(startTime, endTime model.Time, query, userID string, interval time.Duration) []DeleteRequest {
 	deleteRequests := make([]DeleteRequest, 0, endTime.Sub(startTime)/interval)
 	for start := startTime; start.Before(endTime); start = start.Add(interval) + 1 {
@@ -279,7 +352,16 @@ func (dm *DeleteRequestHandler) CancelDeleteRequestHandler(w http.ResponseWriter
 		return
 	}
 
-	if err := dm.deleteRequestsStore.RemoveDeleteRequests(ctx, toDelete); err != nil {
+	event := loghttp.DeleteRequestAuditEvent{
+		Time:   model.Now(),
+		Event:  loghttp.DeleteRequestCancelled,
+		Actor:  dm.requester(r),
+		Shards: len(toDelete),
+	}
+	if len(toDelete) != len(deleteRequests) {
+		event.Reason = "forced cancellation of partially processed request"
+	}
+	if err := dm.deleteRequestsStore.RemoveDeleteRequests(ctx, toDelete, event); err != nil {
 		level.Error(util_log.Logger).Log("msg", "error cancelling the delete request", "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -288,6 +370,125 @@ func (dm *DeleteRequestHandler) CancelDeleteRequestHandler(w http.ResponseWriter
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetDeleteRequestDetailsHandler handles requests for the status, progress and audit log of a delete request.
+func (dm *DeleteRequestHandler) GetDeleteRequestDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requestID := r.URL.Query().Get("request_id")
+	if requestID == "" {
+		http.Error(w, "request_id not set", http.StatusBadRequest)
+		return
+	}
+
+	deleteRequests, err := dm.deleteRequestsStore.GetDeleteRequestGroup(ctx, userID, requestID)
+	if err != nil && !errors.Is(err, ErrDeleteRequestNotFound) {
+		level.Error(util_log.Logger).Log("msg", "error getting delete request from the store", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	auditLog, err := dm.deleteRequestsStore.GetAuditLog(ctx, userID, requestID)
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "error getting delete request audit log from the store", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(deleteRequests) == 0 && len(auditLog) == 0 {
+		http.Error(w, "could not find delete request with given id", http.StatusNotFound)
+		return
+	}
+
+	details := buildDeleteRequestDetails(requestID, deleteRequests, auditLog)
+	for _, req := range deleteRequests {
+		progress, err := dm.deleteRequestsStore.GetProgress(ctx, req)
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "error getting delete request progress from the store", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		details.Progress.Add(progress.DeleteRequestProgress)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(details); err != nil {
+		level.Error(util_log.Logger).Log("msg", "error marshalling response", "err", err)
+		http.Error(w, fmt.Sprintf("Error marshalling response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// buildDeleteRequestDetails builds the details of a delete request from its shards and audit log, without its progress.
+// A delete request without shards was cancelled and its details are read from its audit log.
+func buildDeleteRequestDetails(requestID string, deleteRequests []DeleteRequest, auditLog []loghttp.DeleteRequestAuditEvent) loghttp.DeleteRequestDetails {
+	details := loghttp.DeleteRequestDetails{
+		RequestID: requestID,
+		Status:    "cancelled",
+		Shards:    len(deleteRequests),
+		AuditLog:  []loghttp.DeleteRequestAuditEvent{}, // Declare this way so the return value is [] rather than null
+	}
+
+	if len(deleteRequests) > 0 {
+		startTime, endTime, status := mergeData(deleteRequests)
+		details.Query = deleteRequests[0].Query
+		details.StartTime = startTime
+		details.EndTime = endTime
+		details.Status = string(status)
+		details.CreatedAt = deleteRequests[0].CreatedAt
+		for _, req := range deleteRequests {
+			if req.Status == StatusProcessed {
+				details.ProcessedShards++
+			}
+		}
+	}
+
+	for _, event := range auditLog {
+		details.AuditLog = append(details.AuditLog, event)
+
+		switch event.Event {
+		case loghttp.DeleteRequestCreated:
+			details.RequestedBy = event.Actor
+			if len(deleteRequests) == 0 {
+				details.Query = event.Query
+				details.StartTime = event.StartTime
+				details.EndTime = event.EndTime
+				details.CreatedAt = event.Time
+			}
+		case loghttp.DeleteRequestProcessingStarted:
+			if details.ProcessingStartedAt == 0 {
+				details.ProcessingStartedAt = event.Time
+			}
+		case loghttp.DeleteRequestProcessed:
+			if details.Status == string(StatusProcessed) {
+				details.ProcessedAt = event.Time
+			}
+		case loghttp.DeleteRequestCancelled:
+			details.CancelledAt = event.Time
+		}
+	}
+
+	return details
+}
+
+// requester returns who sent the request, read from the requester header or the basic auth credentials.
+// Both are trusted as sent, see NewDeleteRequestHandler.
+func (dm *DeleteRequestHandler) requester(r *http.Request) string {
+	if dm.requesterHeader != "" {
+		if requester := r.Header.Get(dm.requesterHeader); requester != "" {
+			return requester
+		}
+	}
+
+	if username, _, ok := r.BasicAuth(); ok {
+		return username
+	}
+	return ""
+}
+
 func filterProcessed(reqs []DeleteRequest) []DeleteRequest {
 	var unprocessed []DeleteRequest
 	for _, r := range reqs {
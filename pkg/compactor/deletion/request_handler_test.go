@@ -15,13 +15,14 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/util"
 )
 
 func TestAddDeleteRequestHandler(t *testing.T) {
 	t.Run("it adds the delete request to the store", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", `{foo="bar"}`, "0000000000", "0000000001")
 
@@ -38,7 +39,7 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("an error is returned if adding delete request group returned zero", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{returnZeroDeleteRequests: true}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", `{foo="bar"}`, "0000000000", "0000000001")
 
@@ -50,7 +51,7 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("it only shards deletes with line filter based on a query param", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		from := model.TimeFromUnix(model.Now().Add(-3 * time.Hour).Unix())
 		to := model.TimeFromUnix(from.Add(3 * time.Hour).Unix())
@@ -80,7 +81,7 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("it uses the default for sharding when the query param isn't present", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
-		h := NewDeleteRequestHandler(store, time.Hour, nil)
+		h := NewDeleteRequestHandler(store, nil, time.Hour, "", nil)
 
 		from := model.TimeFromUnix(model.Now().Add(-3 * time.Hour).Unix())
 		to := model.TimeFromUnix(from.Add(3 * time.Hour).Unix())
@@ -107,7 +108,7 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("it does not shard deletes without line filter", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		from := model.TimeFromUnix(model.Now().Add(-3 * time.Hour).Unix())
 		to := model.TimeFromUnix(from.Add(3 * time.Hour).Unix())
@@ -128,7 +129,7 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("it works with RFC3339", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", `{foo="bar"}`, "2006-01-02T15:04:05Z", "2006-01-03T15:04:05Z")
 
@@ -145,7 +146,7 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("it fills in end time if blank", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", `{foo="bar"}`, "0000000000", "")
 
@@ -162,17 +163,19 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 
 	t.Run("it returns 500 when the delete store errors", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{addErr: errors.New("something bad")}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", `{foo="bar"}`, "0000000000", "0000000001")
 
 		w := httptest.NewRecorder()
 		h.AddDeleteRequestHandler(w, req)
 		require.Equal(t, w.Code, http.StatusInternalServerError)
+		// the audit event is written with the request, so it is not added either.
+		require.Empty(t, store.auditLog)
 	})
 
 	t.Run("Validation", func(t *testing.T) {
-		h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, time.Minute, nil)
+		h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, nil, time.Minute, "", nil)
 
 		for _, tc := range []struct {
 			orgID, query, startTime, endTime, interval, error string
@@ -208,6 +211,77 @@ func TestAddDeleteRequestHandler(t *testing.T) {
 	})
 }
 
+type mockPreviewer struct {
+	req DeleteRequest
+	err error
+}
+
+func (m *mockPreviewer) PreviewDeleteRequest(_ context.Context, req DeleteRequest) (Preview, error) {
+	m.req = req
+	return Preview{Streams: 1, Chunks: 2, SampledChunks: 2, SampledLines: 10, SampledDeletedLines: 4, EstimatedAffectedChunks: 2, EstimatedDeletedLines: 4}, m.err
+}
+
+func TestDryRunDeleteRequestHandler(t *testing.T) {
+	t.Run("it previews the delete request without adding it", func(t *testing.T) {
+		store := &mockDeleteRequestsStore{}
+		previewer := &mockPreviewer{}
+		h := NewDeleteRequestHandler(store, previewer, 0, "", nil)
+
+		req := buildRequest("org-id", `{foo="bar"} | json | user_id="42"`, "0000000000", "0000000001")
+
+		w := httptest.NewRecorder()
+		h.DryRunDeleteRequestHandler(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, store.addReqs)
+
+		require.Equal(t, "org-id", previewer.req.UserID)
+		require.Equal(t, `{foo="bar"} | json | user_id="42"`, previewer.req.Query)
+		require.Equal(t, toTime("0000000000"), previewer.req.StartTime)
+		require.Equal(t, toTime("0000000001"), previewer.req.EndTime)
+
+		var preview Preview
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+		require.Equal(t, int64(4), preview.EstimatedDeletedLines)
+	})
+
+	t.Run("error checks", func(t *testing.T) {
+		for _, tc := range []struct {
+			orgID, query, startTime, endTime, error string
+		}{
+			{"", `{foo="bar"}`, "0000000000", "0000000001", "no org id\n"},
+			{"org-id", "", "0000000000", "0000000001", "query not set\n"},
+			{"org-id", `not a query`, "0000000000", "0000000001", "invalid query expression\n"},
+			{"org-id", `{foo="bar"}`, "", "0000000001", "start time not set\n"},
+			{"org-id", `{foo="bar"}`, "0000000001", "0000000000", "start time can't be greater than end time\n"},
+		} {
+			t.Run(strings.TrimSpace(tc.error), func(t *testing.T) {
+				h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, &mockPreviewer{}, 0, "", nil)
+
+				req := buildRequest(tc.orgID, tc.query, tc.startTime, tc.endTime)
+
+				w := httptest.NewRecorder()
+				h.DryRunDeleteRequestHandler(w, req)
+
+				require.Equal(t, http.StatusBadRequest, w.Code)
+				require.Equal(t, tc.error, w.Body.String())
+			})
+		}
+	})
+
+	t.Run("it returns the errors of the previewer", func(t *testing.T) {
+		h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, &mockPreviewer{err: errors.New("something bad")}, 0, "", nil)
+
+		req := buildRequest("org-id", `{foo="bar"}`, "0000000000", "0000000001")
+
+		w := httptest.NewRecorder()
+		h.DryRunDeleteRequestHandler(w, req)
+
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, "something bad\n", w.Body.String())
+	})
+}
+
 func TestCancelDeleteRequestHandler(t *testing.T) {
 	t.Run("it removes unprocessed delete requests from the store when force is true", func(t *testing.T) {
 		stored := []DeleteRequest{
@@ -217,7 +291,7 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
 		store.getResult = stored
 
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", ``, "", "")
 		params := req.URL.Query()
@@ -244,7 +318,7 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
 		store.getResult = stored
 
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", ``, "", "")
 		params := req.URL.Query()
@@ -262,7 +336,7 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 	t.Run("error getting from store", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
 		store.getErr = errors.New("something bad")
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("orgid", ``, "", "")
 		params := req.URL.Query()
@@ -282,7 +356,7 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 		store.getResult = stored
 		store.removeErr = errors.New("something bad")
 
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", ``, "", "")
 		params := req.URL.Query()
@@ -294,11 +368,12 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 
 		require.Equal(t, w.Code, http.StatusInternalServerError)
 		require.Equal(t, "something bad\n", w.Body.String())
+		require.Empty(t, store.auditLog)
 	})
 
 	t.Run("Validation", func(t *testing.T) {
 		t.Run("no org id", func(t *testing.T) {
-			h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, 0, nil)
+			h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, nil, 0, "", nil)
 
 			req := buildRequest("", ``, "", "")
 			params := req.URL.Query()
@@ -313,7 +388,7 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 		})
 
 		t.Run("request not found", func(t *testing.T) {
-			h := NewDeleteRequestHandler(&mockDeleteRequestsStore{getErr: ErrDeleteRequestNotFound}, 0, nil)
+			h := NewDeleteRequestHandler(&mockDeleteRequestsStore{getErr: ErrDeleteRequestNotFound}, nil, 0, "", nil)
 
 			req := buildRequest("org-id", ``, "", "")
 			params := req.URL.Query()
@@ -332,7 +407,7 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 			store := &mockDeleteRequestsStore{}
 			store.getResult = stored
 
-			h := NewDeleteRequestHandler(store, 0, nil)
+			h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 			req := buildRequest("org-id", ``, "", "")
 			params := req.URL.Query()
@@ -348,11 +423,136 @@ func TestCancelDeleteRequestHandler(t *testing.T) {
 	})
 }
 
+func TestDeleteRequestAuditLog(t *testing.T) {
+	t.Run("it records who added the delete request", func(t *testing.T) {
+		store := &mockDeleteRequestsStore{}
+		h := NewDeleteRequestHandler(store, nil, 0, "X-Grafana-User", nil)
+
+		req := buildRequest("org-id", `{foo="bar"}`, unixString(now.Add(-time.Hour)), unixString(now))
+		req.Header.Set("X-Grafana-User", "jane")
+
+		w := httptest.NewRecorder()
+		h.AddDeleteRequestHandler(w, req)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		require.Len(t, store.auditLog, 1)
+		require.Equal(t, loghttp.DeleteRequestCreated, store.auditLog[0].Event)
+		require.Equal(t, "jane", store.auditLog[0].Actor)
+		require.Equal(t, `{foo="bar"}`, store.auditLog[0].Query)
+		require.Equal(t, 1, store.auditLog[0].Shards)
+	})
+
+	t.Run("it records who cancelled the delete request from the basic auth credentials", func(t *testing.T) {
+		store := &mockDeleteRequestsStore{}
+		store.getResult = []DeleteRequest{{RequestID: "test-request", UserID: "org-id", Status: StatusReceived}}
+		h := NewDeleteRequestHandler(store, nil, 0, "X-Grafana-User", nil)
+
+		req := buildRequest("org-id", ``, "", "")
+		req.SetBasicAuth("john", "secret")
+		params := req.URL.Query()
+		params.Set("request_id", "test-request")
+		req.URL.RawQuery = params.Encode()
+
+		w := httptest.NewRecorder()
+		h.CancelDeleteRequestHandler(w, req)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		require.Len(t, store.auditLog, 1)
+		require.Equal(t, loghttp.DeleteRequestCancelled, store.auditLog[0].Event)
+		require.Equal(t, "john", store.auditLog[0].Actor)
+		require.Empty(t, store.auditLog[0].Reason)
+	})
+}
+
+func TestGetDeleteRequestDetailsHandler(t *testing.T) {
+	getDetails := func(t *testing.T, store *mockDeleteRequestsStore, requestID string) *httptest.ResponseRecorder {
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
+
+		req := buildRequest("org-id", ``, "", "")
+		params := req.URL.Query()
+		params.Set("request_id", requestID)
+		req.URL.RawQuery = params.Encode()
+
+		w := httptest.NewRecorder()
+		h.GetDeleteRequestDetailsHandler(w, req)
+		return w
+	}
+
+	t.Run("it returns the progress, timestamps and audit log of the delete request", func(t *testing.T) {
+		seq0, seq1 := int64(0), int64(1)
+		store := &mockDeleteRequestsStore{}
+		store.getResult = []DeleteRequest{
+			{RequestID: "test-request", UserID: "org-id", Query: `{foo="bar"}`, SequenceNum: 0, CreatedAt: now, StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour), Status: StatusProcessed},
+			{RequestID: "test-request", UserID: "org-id", Query: `{foo="bar"}`, SequenceNum: 1, CreatedAt: now, StartTime: now.Add(-time.Hour), EndTime: now, Status: StatusReceived},
+		}
+		store.auditLog = []loghttp.DeleteRequestAuditEvent{
+			{Time: now, Event: loghttp.DeleteRequestCreated, Actor: "jane", Query: `{foo="bar"}`, Shards: 2},
+			{Time: now.Add(time.Hour), Event: loghttp.DeleteRequestProcessingStarted, Actor: compactorActor, SequenceNum: &seq0},
+			{Time: now.Add(2 * time.Hour), Event: loghttp.DeleteRequestProcessed, Actor: compactorActor, SequenceNum: &seq0},
+			{Time: now.Add(3 * time.Hour), Event: loghttp.DeleteRequestProcessingStarted, Actor: compactorActor, SequenceNum: &seq1},
+		}
+		store.progress = map[string]RequestProgress{
+			"org-id:test-request":   {DeleteRequestProgress: loghttp.DeleteRequestProgress{TablesProcessed: 2, ChunksDeleted: 3, DeletedLines: 0}},
+			"org-id:test-request:1": {DeleteRequestProgress: loghttp.DeleteRequestProgress{TablesProcessed: 1, ChunksRewritten: 4, DeletedLines: 20}},
+		}
+
+		w := getDetails(t, store, "test-request")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var details loghttp.DeleteRequestDetails
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+		require.Equal(t, loghttp.DeleteRequestDetails{
+			RequestID:           "test-request",
+			Query:               `{foo="bar"}`,
+			StartTime:           now.Add(-2 * time.Hour),
+			EndTime:             now,
+			Status:              "50% Complete",
+			RequestedBy:         "jane",
+			CreatedAt:           now,
+			ProcessingStartedAt: now.Add(time.Hour),
+			Shards:              2,
+			ProcessedShards:     1,
+			Progress:            loghttp.DeleteRequestProgress{TablesProcessed: 3, ChunksDeleted: 3, ChunksRewritten: 4, DeletedLines: 20},
+			AuditLog:            store.auditLog,
+		}, details)
+	})
+
+	t.Run("it returns the cancelled delete requests from their audit log", func(t *testing.T) {
+		store := &mockDeleteRequestsStore{getErr: ErrDeleteRequestNotFound}
+		store.auditLog = []loghttp.DeleteRequestAuditEvent{
+			{Time: now, Event: loghttp.DeleteRequestCreated, Actor: "jane", Query: `{foo="bar"}`, StartTime: now.Add(-time.Hour), EndTime: now, Shards: 1},
+			{Time: now.Add(time.Minute), Event: loghttp.DeleteRequestCancelled, Actor: "john", Shards: 1},
+		}
+
+		w := getDetails(t, store, "test-request")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var details loghttp.DeleteRequestDetails
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+		require.Equal(t, "cancelled", details.Status)
+		require.Equal(t, `{foo="bar"}`, details.Query)
+		require.Equal(t, "jane", details.RequestedBy)
+		require.Equal(t, now, details.CreatedAt)
+		require.Equal(t, now.Add(time.Minute), details.CancelledAt)
+		require.Len(t, details.AuditLog, 2)
+	})
+
+	t.Run("it returns 404 for unknown delete requests", func(t *testing.T) {
+		w := getDetails(t, &mockDeleteRequestsStore{getErr: ErrDeleteRequestNotFound}, "test-request")
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("it requires the request id", func(t *testing.T) {
+		w := getDetails(t, &mockDeleteRequestsStore{}, "")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 func TestGetAllDeleteRequestsHandler(t *testing.T) {
 	t.Run("it gets all the delete requests for the user", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
 		store.getAllResult = []DeleteRequest{{RequestID: "test-request-1", Status: StatusReceived}, {RequestID: "test-request-2", Status: StatusReceived}}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", ``, "", "")
 
@@ -375,7 +575,7 @@ func TestGetAllDeleteRequestsHandler(t *testing.T) {
 			{RequestID: "test-request-2", CreatedAt: now.Add(time.Minute), StartTime: now.Add(30 * time.Minute), EndTime: now.Add(90 * time.Minute)},
 			{RequestID: "test-request-1", CreatedAt: now, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
 		}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", ``, "", "")
 
@@ -405,7 +605,7 @@ func TestGetAllDeleteRequestsHandler(t *testing.T) {
 			{RequestID: "test-request-2", CreatedAt: now.Add(time.Minute), Status: StatusProcessed},
 			{RequestID: "test-request-3", CreatedAt: now.Add(2 * time.Minute), Status: StatusReceived},
 		}
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("org-id", ``, "", "")
 
@@ -428,7 +628,7 @@ func TestGetAllDeleteRequestsHandler(t *testing.T) {
 	t.Run("error getting from store", func(t *testing.T) {
 		store := &mockDeleteRequestsStore{}
 		store.getAllErr = errors.New("something bad")
-		h := NewDeleteRequestHandler(store, 0, nil)
+		h := NewDeleteRequestHandler(store, nil, 0, "", nil)
 
 		req := buildRequest("orgid", ``, "", "")
 		params := req.URL.Query()
@@ -444,7 +644,7 @@ func TestGetAllDeleteRequestsHandler(t *testing.T) {
 
 	t.Run("validation", func(t *testing.T) {
 		t.Run("no org id", func(t *testing.T) {
-			h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, 0, nil)
+			h := NewDeleteRequestHandler(&mockDeleteRequestsStore{}, nil, 0, "", nil)
 
 			req := buildRequest("", ``, "", "")
 
@@ -0,0 +1,207 @@
+package deletion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+)
+
+const (
+	// shardsObjectsPrefix is the prefix of the objects shared by the compactors sharing the index tables.
+	shardsObjectsPrefix  = "compactor_shards/"
+	shardsBatchObjectKey = shardsObjectsPrefix + "delete_requests_batch.json"
+	shardsReportsPrefix  = shardsObjectsPrefix + "reports/"
+)
+
+// ShardingRing is the view of the ring of the compactors sharing the index tables.
+type ShardingRing interface {
+	// IsLeader tells if this compactor manages the delete requests.
+	IsLeader() bool
+	InstanceID() string
+	// ActiveInstances returns the IDs of the compactors sharing the index tables.
+	ActiveInstances() ([]string, error)
+}
+
+// ShardCoordinator lets the compactors sharing the index tables process the same delete requests.
+// The leader publishes the batch of delete requests it processes in the object storage and the other compactors process
+// the published batch. Each compactor reports the batch once it processed it in all the tables it owns, and the leader
+// marks the requests as processed once all the compactors of the ring processed them.
+type ShardCoordinator struct {
+	ring         ShardingRing
+	objectClient client.ObjectClient
+}
+
+func NewShardCoordinator(ring ShardingRing, objectClient client.ObjectClient) *ShardCoordinator {
+	return &ShardCoordinator{
+		ring:         ring,
+		objectClient: objectClient,
+	}
+}
+
+type shardBatch struct {
+	ID       string              `json:"id"`
+	Requests []shardBatchRequest `json:"requests"`
+}
+
+type shardBatchRequest struct {
+	RequestID   string     `json:"request_id"`
+	SequenceNum int64      `json:"sequence_num"`
+	UserID      string     `json:"user_id"`
+	Query       string     `json:"query"`
+	StartTime   model.Time `json:"start_time"`
+	EndTime     model.Time `json:"end_time"`
+	CreatedAt   model.Time `json:"created_at"`
+}
+
+// shardReport is the last batch processed by a compactor, with the compactors of the ring when it started processing it.
+type shardReport struct {
+	InstanceID string     `json:"instance_id"`
+	BatchID    string     `json:"batch_id"`
+	Instances  []string   `json:"instances"`
+	FinishedAt model.Time `json:"finished_at"`
+}
+
+func requestKey(req DeleteRequest) string {
+	return fmt.Sprintf("%s/%s/%d", req.UserID, req.RequestID, req.SequenceNum)
+}
+
+// batchID identifies the delete requests of a batch. It is empty when there are no requests.
+func batchID(reqs []DeleteRequest) string {
+	if len(reqs) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		keys = append(keys, requestKey(req))
+	}
+	sort.Strings(keys)
+
+	h := xxhash.New()
+	for _, key := range keys {
+		_, _ = h.WriteString(key)
+		_, _ = h.WriteString("\n")
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// publishBatch publishes the delete requests processed by the leader and returns the ID of the batch.
+func (s *ShardCoordinator) publishBatch(ctx context.Context, reqs []DeleteRequest) (string, error) {
+	batch := shardBatch{ID: batchID(reqs), Requests: make([]shardBatchRequest, 0, len(reqs))}
+	for _, req := range reqs {
+		batch.Requests = append(batch.Requests, shardBatchRequest{
+			RequestID:   req.RequestID,
+			SequenceNum: req.SequenceNum,
+			UserID:      req.UserID,
+			Query:       req.Query,
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+			CreatedAt:   req.CreatedAt,
+		})
+	}
+	if err := s.putObject(ctx, shardsBatchObjectKey, batch); err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+// loadBatch returns the ID and the delete requests of the batch published by the leader.
+func (s *ShardCoordinator) loadBatch(ctx context.Context) (string, []DeleteRequest, error) {
+	var batch shardBatch
+	found, err := s.getObject(ctx, shardsBatchObjectKey, &batch)
+	if err != nil || !found {
+		return "", nil, err
+	}
+
+	reqs := make([]DeleteRequest, 0, len(batch.Requests))
+	for _, r := range batch.Requests {
+		req := DeleteRequest{
+			RequestID:   r.RequestID,
+			SequenceNum: r.SequenceNum,
+			UserID:      r.UserID,
+			StartTime:   r.StartTime,
+			EndTime:     r.EndTime,
+			Status:      StatusReceived,
+			CreatedAt:   r.CreatedAt,
+		}
+		if err := req.SetQuery(r.Query); err != nil {
+			return "", nil, fmt.Errorf("invalid query of delete request %s: %w", r.RequestID, err)
+		}
+		reqs = append(reqs, req)
+	}
+	return batch.ID, reqs, nil
+}
+
+// reportBatch records that this compactor processed the batch in all the tables it owns.
+func (s *ShardCoordinator) reportBatch(ctx context.Context, batchID string, instances []string) error {
+	instanceID := s.ring.InstanceID()
+	return s.putObject(ctx, shardsReportsPrefix+instanceID+".json", shardReport{
+		InstanceID: instanceID,
+		BatchID:    batchID,
+		Instances:  instances,
+		FinishedAt: model.Now(),
+	})
+}
+
+// batchProcessedByAll tells if all the compactors of the ring processed the batch while the ring had the same compactors,
+// which guarantees that each table was processed by its owner.
+func (s *ShardCoordinator) batchProcessedByAll(ctx context.Context, batchID string) (bool, error) {
+	instances, err := s.activeInstances()
+	if err != nil {
+		return false, err
+	}
+
+	for _, instanceID := range instances {
+		var report shardReport
+		found, err := s.getObject(ctx, shardsReportsPrefix+instanceID+".json", &report)
+		if err != nil {
+			return false, err
+		}
+		if !found || report.BatchID != batchID || !slices.Equal(report.Instances, instances) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// activeInstances returns the sorted IDs of the compactors of the ring.
+func (s *ShardCoordinator) activeInstances() ([]string, error) {
+	instances, err := s.ring.ActiveInstances()
+	if err != nil {
+		return nil, err
+	}
+	instances = slices.Clone(instances)
+	sort.Strings(instances)
+	return instances, nil
+}
+
+func (s *ShardCoordinator) putObject(ctx context.Context, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.objectClient.PutObject(ctx, key, bytes.NewReader(data))
+}
+
+func (s *ShardCoordinator) getObject(ctx context.Context, key string, v interface{}) (bool, error) {
+	reader, _, err := s.objectClient.GetObject(ctx, key)
+	if err != nil {
+		if s.objectClient.IsObjectNotFoundErr(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return true, nil
+}
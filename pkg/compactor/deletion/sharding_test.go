@@ -0,0 +1,91 @@
+package deletion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/compactor/deletionmode"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+)
+
+type fakeShardingRing struct {
+	leader     bool
+	instanceID string
+	instances  []string
+}
+
+func (f *fakeShardingRing) IsLeader() bool {
+	return f.leader
+}
+
+func (f *fakeShardingRing) InstanceID() string {
+	return f.instanceID
+}
+
+func (f *fakeShardingRing) ActiveInstances() ([]string, error) {
+	return f.instances, nil
+}
+
+func TestDeleteRequestsManager_Sharding(t *testing.T) {
+	now := model.Now()
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	limits := &fakeLimits{defaultLimit: limit{deletionMode: deletionmode.FilterAndDelete.String()}}
+
+	leaderStore := &mockDeleteRequestsStore{deleteRequests: []DeleteRequest{
+		{RequestID: "1", UserID: testUserID, Query: `{foo="bar"}`, StartTime: now.Add(-12 * time.Hour), EndTime: now, Status: StatusReceived},
+	}}
+	followerStore := &mockDeleteRequestsStore{}
+	instances := []string{"compactor-1", "compactor-2"}
+	leaderRing := &fakeShardingRing{leader: true, instanceID: "compactor-1", instances: instances}
+	followerRing := &fakeShardingRing{instanceID: "compactor-2", instances: instances}
+	leader := NewDeleteRequestsManager(leaderStore, time.Hour, 70, limits, NewShardCoordinator(leaderRing, objectClient), nil)
+	follower := NewDeleteRequestsManager(followerStore, time.Hour, 70, limits, NewShardCoordinator(followerRing, objectClient), nil)
+
+	chunk := retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{
+			UserID:  []byte(testUserID),
+			From:    now.Add(-2 * time.Hour),
+			Through: now.Add(-time.Hour),
+		},
+		Labels: labels.FromStrings("foo", "bar"),
+	}
+
+	// the follower processes the requests published by the leader.
+	leader.MarkPhaseStarted()
+	follower.MarkPhaseStarted()
+	isExpired, _ := follower.Expired(chunk, now)
+	require.True(t, isExpired)
+
+	// the requests are not processed until the follower processed them.
+	leader.MarkPhaseFinished()
+	require.Equal(t, StatusReceived, leaderStore.deleteRequests[0].Status)
+
+	follower.MarkPhaseFinished()
+	require.Empty(t, followerStore.auditLog)
+	require.Empty(t, followerStore.progress)
+
+	// the leader keeps processing the same batch and marks it as processed once all the compactors processed it.
+	leaderStore.deleteRequests = append(leaderStore.deleteRequests, DeleteRequest{
+		RequestID: "2", UserID: testUserID, Query: `{foo="buzz"}`, StartTime: now.Add(-12 * time.Hour), EndTime: now, Status: StatusReceived,
+	})
+	leader.MarkPhaseStarted()
+	require.Len(t, leader.requestsToProcess(), 1)
+	leader.MarkPhaseFinished()
+	require.Equal(t, StatusProcessed, leaderStore.deleteRequests[0].Status)
+	require.Equal(t, StatusReceived, leaderStore.deleteRequests[1].Status)
+
+	// a change of the ring requires the compactors to process the batch again.
+	leader.MarkPhaseStarted()
+	require.Len(t, leader.requestsToProcess(), 1)
+	follower.MarkPhaseStarted()
+	follower.MarkPhaseFinished()
+	leaderRing.instances = append(instances, "compactor-3")
+	leader.MarkPhaseFinished()
+	require.Equal(t, StatusReceived, leaderStore.deleteRequests[1].Status)
+}
@@ -31,6 +31,20 @@ func TestParseLogQLExpressionForDeletion(t *testing.T) {
 		require.NoError(t, err)
 	})
 
+	t.Run("pipeline expression with parser and label filter", func(t *testing.T) {
+		logSelectorExpr, err := parseDeletionQuery(`{env="dev"} | json | user_id="42"`)
+		require.NotNil(t, logSelectorExpr)
+		require.NoError(t, err)
+		require.True(t, logSelectorExpr.HasFilter())
+	})
+
+	t.Run("pipeline expression with structured metadata filter", func(t *testing.T) {
+		logSelectorExpr, err := parseDeletionQuery(`{env="dev"} | user_id="42"`)
+		require.NotNil(t, logSelectorExpr)
+		require.NoError(t, err)
+		require.True(t, logSelectorExpr.HasFilter())
+	})
+
 	t.Run("pipeline expression with invalid line filter", func(t *testing.T) {
 		logSelectorExpr, err := parseDeletionQuery(`{env="dev", secret="true"} |= social sec number`)
 		require.Nil(t, logSelectorExpr)
@@ -283,14 +283,26 @@ func (is *indexSet) removeFilesFromStorage() error {
 // - recreate the compacted db if required.
 // - upload the compacted db if required.
 // - remove the source objects from storage if required.
-func (is *indexSet) done() error {
+// checkOwnership, when not nil, is called before the upload and the removal to stop when the index set has moved to
+// another compactor.
+func (is *indexSet) done(checkOwnership func() error) error {
 	if is.uploadCompactedDB {
+		if checkOwnership != nil {
+			if err := checkOwnership(); err != nil {
+				return err
+			}
+		}
 		if err := is.upload(); err != nil {
 			return err
 		}
 	}
 
 	if is.removeSourceObjects {
+		if checkOwnership != nil {
+			if err := checkOwnership(); err != nil {
+				return err
+			}
+		}
 		return is.removeFilesFromStorage()
 	}
 
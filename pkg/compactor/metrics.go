@@ -1,6 +1,7 @@
 package compactor
 
 import (
+	"github.com/grafana/dskit/instrument"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -8,8 +9,18 @@ import (
 const (
 	statusFailure = "failure"
 	statusSuccess = "success"
+
+	shardTableProcessed = "processed"
+	shardTableSkipped   = "skipped"
 )
 
+func operationName(applyRetention bool) string {
+	if applyRetention {
+		return "retention"
+	}
+	return "compaction"
+}
+
 type metrics struct {
 	compactTablesOperationTotal            *prometheus.CounterVec
 	compactTablesOperationDurationSeconds  prometheus.Gauge
@@ -19,6 +30,9 @@ type metrics struct {
 	applyRetentionLastSuccess              prometheus.Gauge
 	compactorRunning                       prometheus.Gauge
 	skippedCompactingLockedTables          *prometheus.GaugeVec
+	shardLeader                            prometheus.Gauge
+	shardTables                            *prometheus.GaugeVec
+	leaderRequestDuration                  *prometheus.HistogramVec
 }
 
 func newMetrics(r prometheus.Registerer) *metrics {
@@ -63,6 +77,22 @@ func newMetrics(r prometheus.Registerer) *metrics {
 			Name:      "locked_table_successive_compaction_skips",
 			Help:      "Number of times uncompacted tables were consecutively skipped due to them being locked by retention",
 		}, []string{"table_name"}),
+		shardLeader: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki_compactor",
+			Name:      "shard_leader",
+			Help:      "Value will be 1 if this instance manages the delete requests while the compactors share the tables",
+		}),
+		shardTables: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "loki_compactor",
+			Name:      "shard_tables",
+			Help:      "Number of tables processed and skipped by this instance in the running or last compaction and retention runs while the compactors share the tables",
+		}, []string{"operation", "state"}),
+		leaderRequestDuration: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "loki_compactor",
+			Name:      "leader_request_duration_seconds",
+			Help:      "Time (in seconds) spent forwarding the requests to the leader while the compactors share the tables",
+			Buckets:   instrument.DefBuckets,
+		}, []string{"operation", "status_code"}),
 	}
 
 	return &m
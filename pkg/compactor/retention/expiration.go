@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
 	"github.com/grafana/loki/v3/pkg/util/filter"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	"github.com/grafana/loki/v3/pkg/validation"
@@ -31,33 +32,46 @@ type ExpirationChecker interface {
 	DropFromIndex(ref ChunkEntry, tableEndTime model.Time, now model.Time) bool
 }
 
+// IndexRetentionTracker is implemented by the ExpirationCheckers which track the indexes processed by the retention phase.
+type IndexRetentionTracker interface {
+	// MarkIndexProcessed is called once the retention went through all the chunks of the index of userID in the table
+	// without timing out. The multi-tenant index of the table has an empty userID.
+	MarkIndexProcessed(tableName, userID string)
+}
+
 type expirationChecker struct {
-	tenantsRetention         *TenantsRetention
-	latestRetentionStartTime latestRetentionStartTime
+	tenantsRetention            *TenantsRetention
+	structuredMetadataRetention *structuredMetadataRetention
+	latestRetentionStartTime    latestRetentionStartTime
 }
 
 type Limits interface {
 	RetentionPeriod(userID string) time.Duration
 	StreamRetention(userID string) []validation.StreamRetention
+	StructuredMetadataRetention(userID string) []validation.StructuredMetadataRetention
 	AllByUserID() map[string]*validation.Limits
 	DefaultLimits() *validation.Limits
 }
 
-func NewExpirationChecker(limits Limits) ExpirationChecker {
+// NewExpirationChecker returns the ExpirationChecker applying the retention limits.
+// objectClient stores the watermarks of the structured metadata retention, they are only kept in memory when it is nil.
+func NewExpirationChecker(limits Limits, objectClient client.ObjectClient) ExpirationChecker {
 	return &expirationChecker{
-		tenantsRetention: NewTenantsRetention(limits),
+		tenantsRetention:            NewTenantsRetention(limits),
+		structuredMetadataRetention: newStructuredMetadataRetention(limits, objectClient),
 	}
 }
 
 // Expired tells if a ref chunk is expired based on retention rules.
+// When only some of its lines are out of the structured metadata retention rules, the chunk is expired with a filter removing them.
 func (e *expirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, filter.Func) {
 	userID := unsafeGetString(ref.UserID)
 	period := e.tenantsRetention.RetentionPeriodFor(userID, ref.Labels)
 	// The 0 value should disable retention
-	if period <= 0 {
-		return false, nil
+	if period > 0 && now.Sub(ref.Through) > period {
+		return true, nil
 	}
-	return now.Sub(ref.Through) > period, nil
+	return e.structuredMetadataRetention.expired(ref, period, now)
 }
 
 // DropFromIndex tells if it is okay to drop the chunk entry from index table.
@@ -74,14 +88,24 @@ func (e *expirationChecker) DropFromIndex(ref ChunkEntry, tableEndTime model.Tim
 }
 
 func (e *expirationChecker) MarkPhaseStarted() {
-	e.latestRetentionStartTime = findLatestRetentionStartTime(model.Now(), e.tenantsRetention.limits)
+	now := model.Now()
+	e.structuredMetadataRetention.markPhaseStarted(now)
+	e.latestRetentionStartTime = findLatestRetentionStartTime(now, e.tenantsRetention.limits)
 	level.Info(util_log.Logger).Log("msg", fmt.Sprintf("overall smallest retention period %v, default smallest retention period %v",
 		e.latestRetentionStartTime.overall, e.latestRetentionStartTime.defaults))
 }
 
-func (e *expirationChecker) MarkPhaseFailed()   {}
-func (e *expirationChecker) MarkPhaseTimedOut() {}
-func (e *expirationChecker) MarkPhaseFinished() {}
+func (e *expirationChecker) MarkPhaseFailed() {
+	e.structuredMetadataRetention.markPhaseFailed()
+}
+
+func (e *expirationChecker) MarkPhaseTimedOut() {
+	e.structuredMetadataRetention.markPhaseFailed()
+}
+
+func (e *expirationChecker) MarkPhaseFinished() {
+	e.structuredMetadataRetention.markPhaseFinished()
+}
 
 func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool {
 	// when userID is empty, it means we are checking for common index table. In this case we use e.overallLatestRetentionStartTime.
@@ -100,6 +124,11 @@ func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval
 	return interval.Start.Before(latestRetentionStartTime)
 }
 
+// MarkIndexProcessed implements IndexRetentionTracker.
+func (e *expirationChecker) MarkIndexProcessed(tableName, userID string) {
+	e.structuredMetadataRetention.markIndexProcessed(ExtractIntervalFromTableName(tableName), userID)
+}
+
 // NeverExpiringExpirationChecker returns an expiration checker that never expires anything
 func NeverExpiringExpirationChecker(_ Limits) ExpirationChecker {
 	return &neverExpiringExpirationChecker{}
@@ -187,6 +216,11 @@ func findLatestRetentionStartTime(now model.Time, limits Limits) latestRetention
 			smallestDefaultRetentionPeriod = streamRetention.Period
 		}
 	}
+	for _, rule := range defaultLimits.StructuredMetadataRetention {
+		if rule.Period < smallestDefaultRetentionPeriod {
+			smallestDefaultRetentionPeriod = rule.Period
+		}
+	}
 
 	overallSmallestRetentionPeriod := smallestDefaultRetentionPeriod
 
@@ -200,6 +234,11 @@ func findLatestRetentionStartTime(now model.Time, limits Limits) latestRetention
 				smallestRetentionPeriodForUser = streamRetention.Period
 			}
 		}
+		for _, rule := range limit.StructuredMetadataRetention {
+			if rule.Period < smallestRetentionPeriodForUser {
+				smallestRetentionPeriodForUser = rule.Period
+			}
+		}
 
 		// update the overallSmallestRetentionPeriod if this user has smaller value
 		smallestRetentionPeriodByUser[userID] = now.Add(time.Duration(-smallestRetentionPeriodForUser))
@@ -1,6 +1,7 @@
 package retention
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -9,18 +10,21 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
 	"github.com/grafana/loki/v3/pkg/validation"
 )
 
 type retentionLimit struct {
-	retentionPeriod time.Duration
-	streamRetention []validation.StreamRetention
+	retentionPeriod             time.Duration
+	streamRetention             []validation.StreamRetention
+	structuredMetadataRetention []validation.StructuredMetadataRetention
 }
 
 func (r retentionLimit) convertToValidationLimit() *validation.Limits {
 	return &validation.Limits{
-		RetentionPeriod: model.Duration(r.retentionPeriod),
-		StreamRetention: r.streamRetention,
+		RetentionPeriod:             model.Duration(r.retentionPeriod),
+		StreamRetention:             r.streamRetention,
+		StructuredMetadataRetention: r.structuredMetadataRetention,
 	}
 }
 
@@ -37,6 +41,10 @@ func (f fakeLimits) StreamRetention(userID string) []validation.StreamRetention
 	return f.perTenant[userID].streamRetention
 }
 
+func (f fakeLimits) StructuredMetadataRetention(userID string) []validation.StructuredMetadataRetention {
+	return f.perTenant[userID].structuredMetadataRetention
+}
+
 func (f fakeLimits) DefaultLimits() *validation.Limits {
 	return f.defaultLimit.convertToValidationLimit()
 }
@@ -101,7 +109,7 @@ func Test_expirationChecker_Expired(t *testing.T) {
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
 
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil)
 	tests := []struct {
 		name string
 		ref  ChunkEntry
@@ -123,6 +131,224 @@ func Test_expirationChecker_Expired(t *testing.T) {
 	}
 }
 
+func Test_expirationChecker_StructuredMetadataRetention(t *testing.T) {
+	const dayDuration = 24 * time.Hour
+	limits := fakeLimits{
+		perTenant: map[string]retentionLimit{
+			"1": {
+				retentionPeriod: 30 * dayDuration,
+				structuredMetadataRetention: []validation.StructuredMetadataRetention{
+					{
+						Period:                     model.Duration(3 * dayDuration),
+						StructuredMetadataMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "detected_level", "debug")},
+					},
+					{
+						Period:                     model.Duration(10 * dayDuration),
+						Priority:                   1,
+						Matchers:                   []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "buzz")},
+						StructuredMetadataMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "detected_level", "debug|info")},
+					},
+					{
+						Period:                     model.Duration(60 * dayDuration),
+						StructuredMetadataMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "detected_level", "error")},
+					},
+				},
+			},
+		},
+	}
+
+	now := model.Now()
+	debug := labels.Label{Name: "detected_level", Value: "debug"}
+	info := labels.Label{Name: "detected_level", Value: "info"}
+	errorLevel := labels.Label{Name: "detected_level", Value: "error"}
+
+	// line is a log line of the chunk with whether the filter is expected to remove it.
+	type line struct {
+		age                time.Duration
+		structuredMetadata []labels.Label
+		removed            bool
+	}
+
+	for _, tc := range []struct {
+		name          string
+		ref           ChunkEntry
+		lastApplied   model.Time
+		expectExpired bool
+		expectFilter  bool
+		lines         []line
+	}{
+		{
+			name:          "stream out of retention",
+			ref:           newChunkEntry("1", `{foo="bar"}`, now.Add(-32*dayDuration), now.Add(-31*dayDuration)),
+			expectExpired: true,
+		},
+		{
+			name: "chunk not out of any rule period",
+			ref:  newChunkEntry("1", `{foo="bar"}`, now.Add(-2*dayDuration), now.Add(-time.Hour)),
+		},
+		{
+			name:          "chunk out of the debug rule period",
+			ref:           newChunkEntry("1", `{foo="bar"}`, now.Add(-5*dayDuration), now.Add(-4*dayDuration)),
+			expectExpired: true,
+			expectFilter:  true,
+			lines: []line{
+				{age: 4 * dayDuration, structuredMetadata: []labels.Label{debug}, removed: true},
+				{age: 4 * dayDuration, structuredMetadata: []labels.Label{info}},
+				{age: 4 * dayDuration},
+			},
+		},
+		{
+			name:          "higher priority rule for the stream keeps the debug lines",
+			ref:           newChunkEntry("1", `{foo="buzz"}`, now.Add(-5*dayDuration), now.Add(-4*dayDuration)),
+			expectExpired: true,
+			expectFilter:  true,
+			lines: []line{
+				{age: 4 * dayDuration, structuredMetadata: []labels.Label{debug}},
+				{age: 4 * dayDuration, structuredMetadata: []labels.Label{errorLevel}},
+			},
+		},
+		{
+			name:          "rule longer than the stream retention is not applied",
+			ref:           newChunkEntry("1", `{foo="buzz"}`, now.Add(-12*dayDuration), now.Add(-11*dayDuration)),
+			expectExpired: true,
+			expectFilter:  true,
+			lines: []line{
+				{age: 11 * dayDuration, structuredMetadata: []labels.Label{info}, removed: true},
+				{age: 11 * dayDuration, structuredMetadata: []labels.Label{errorLevel}},
+			},
+		},
+		{
+			name:        "chunk already out of the rule periods during the last retention phase",
+			ref:         newChunkEntry("1", `{foo="bar"}`, now.Add(-5*dayDuration), now.Add(-4*dayDuration)),
+			lastApplied: now.Add(-time.Hour),
+		},
+		{
+			name: "tenant without rules",
+			ref:  newChunkEntry("2", `{foo="bar"}`, now.Add(-5*dayDuration), now.Add(-4*dayDuration)),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExpirationChecker(limits, nil).(*expirationChecker)
+			if tc.lastApplied != 0 {
+				for _, table := range chunkTableNames(tc.ref) {
+					e.structuredMetadataRetention.applied[mergedKey{table: ExtractIntervalFromTableName(table).Start, userID: "1"}] = tc.lastApplied
+				}
+			}
+
+			expired, filterFunc := e.Expired(tc.ref, now)
+			require.Equal(t, tc.expectExpired, expired)
+			if !tc.expectFilter {
+				require.Nil(t, filterFunc)
+				return
+			}
+			require.NotNil(t, filterFunc)
+			for _, line := range tc.lines {
+				require.Equal(t, line.removed, filterFunc(now.Add(-line.age).Time(), "line", line.structuredMetadata...))
+			}
+		})
+	}
+}
+
+func Test_expirationChecker_StructuredMetadataRetention_Phases(t *testing.T) {
+	limits := fakeLimits{
+		perTenant: map[string]retentionLimit{
+			"1": {
+				structuredMetadataRetention: []validation.StructuredMetadataRetention{
+					{
+						Period:                     model.Duration(24 * time.Hour),
+						StructuredMetadataMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "detected_level", "debug")},
+					},
+				},
+			},
+		},
+	}
+	e := NewExpirationChecker(limits, nil)
+	ref := newChunkEntry("1", `{foo="bar"}`, model.Now().Add(-72*time.Hour), model.Now().Add(-48*time.Hour))
+	markChunkTablesProcessed := func(userID string) {
+		for _, table := range chunkTableNames(ref) {
+			e.(IndexRetentionTracker).MarkIndexProcessed(table, userID)
+		}
+	}
+
+	// a timed out phase does not move forward the watermarks.
+	e.MarkPhaseStarted()
+	markChunkTablesProcessed("1")
+	e.MarkPhaseTimedOut()
+	e.MarkPhaseFinished()
+	expired, filterFunc := e.Expired(ref, model.Now())
+	require.True(t, expired)
+	require.NotNil(t, filterFunc)
+
+	// a phase which did not process the tables of the chunk does not move forward their watermarks.
+	e.MarkPhaseStarted()
+	markChunkTablesProcessed("2")
+	e.MarkPhaseFinished()
+	expired, _ = e.Expired(ref, model.Now())
+	require.True(t, expired)
+
+	// once a phase processed the tables of the chunk and finished, the chunk does not need to be rewritten again.
+	e.MarkPhaseStarted()
+	markChunkTablesProcessed("1")
+	e.MarkPhaseFinished()
+	expired, filterFunc = e.Expired(ref, model.Now())
+	require.False(t, expired)
+	require.Nil(t, filterFunc)
+}
+
+func Test_expirationChecker_StructuredMetadataRetention_Restart(t *testing.T) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+
+	limits := fakeLimits{
+		perTenant: map[string]retentionLimit{
+			"1": {
+				structuredMetadataRetention: []validation.StructuredMetadataRetention{
+					{
+						Period:                     model.Duration(24 * time.Hour),
+						StructuredMetadataMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "detected_level", "debug")},
+					},
+				},
+			},
+		},
+	}
+	now := model.Now()
+	oldRef := newChunkEntry("1", `{foo="bar"}`, now.Add(-96*time.Hour), now.Add(-72*time.Hour))
+	recentRef := newChunkEntry("1", `{foo="bar"}`, now.Add(-24*time.Hour), now.Add(-23*time.Hour))
+
+	e := NewExpirationChecker(limits, objectClient)
+	e.MarkPhaseStarted()
+	for _, table := range append(chunkTableNames(oldRef), chunkTableNames(recentRef)...) {
+		e.(IndexRetentionTracker).MarkIndexProcessed(table, "1")
+	}
+	e.MarkPhaseFinished()
+
+	// a restarted compactor loads the watermarks when the retention phase starts.
+	restarted := NewExpirationChecker(limits, objectClient)
+	restarted.MarkPhaseStarted()
+	expired, filterFunc := restarted.Expired(oldRef, now)
+	require.False(t, expired)
+	require.Nil(t, filterFunc)
+
+	// the chunks which went out of the period of the rule since the last phase are considered.
+	expired, filterFunc = restarted.Expired(recentRef, now.Add(2*time.Hour))
+	require.True(t, expired)
+	require.NotNil(t, filterFunc)
+
+	// the chunks of the tables which were never processed are considered.
+	neverProcessedRef := newChunkEntry("1", `{foo="bar"}`, now.Add(-240*time.Hour), now.Add(-200*time.Hour))
+	expired, _ = restarted.Expired(neverProcessedRef, now)
+	require.True(t, expired)
+}
+
+// chunkTableNames returns the names of the daily tables indexing the chunk.
+func chunkTableNames(ref ChunkEntry) []string {
+	var names []string
+	for table := tableStart(ref.From); !table.After(ref.Through); table = table.Add(tableDuration) {
+		names = append(names, fmt.Sprintf("index_%d", table.Unix()/86400))
+	}
+	return names
+}
+
 func Test_expirationChecker_Expired_zeroValue(t *testing.T) {
 
 	// Default retention should be zero
@@ -139,7 +365,7 @@ func Test_expirationChecker_Expired_zeroValue(t *testing.T) {
 	}
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil)
 	tests := []struct {
 		name string
 		ref  ChunkEntry
@@ -185,7 +411,7 @@ func Test_expirationChecker_Expired_zeroValueOverride(t *testing.T) {
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
 
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil)
 	tests := []struct {
 		name string
 		ref  ChunkEntry
@@ -219,7 +445,7 @@ func Test_expirationChecker_DropFromIndex_zeroValue(t *testing.T) {
 	}
 	o, err := overridesTestConfig(d, f)
 	require.NoError(t, err)
-	e := NewExpirationChecker(o)
+	e := NewExpirationChecker(o, nil)
 
 	chunkFrom := model.Now().Add(-3 * time.Hour)
 	chunkThrough := model.Now().Add(-2 * time.Hour)
@@ -390,6 +616,31 @@ func TestFindLatestRetentionStartTime(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "user structured metadata retention period smallest",
+			limit: fakeLimits{
+				defaultLimit: retentionLimit{
+					retentionPeriod: 7 * dayDuration,
+				},
+				perTenant: map[string]retentionLimit{
+					"0": {
+						retentionPeriod: 20 * dayDuration,
+						structuredMetadataRetention: []validation.StructuredMetadataRetention{
+							{
+								Period: model.Duration(3 * dayDuration),
+							},
+						},
+					},
+				},
+			},
+			expectedLatestRetentionStartTime: latestRetentionStartTime{
+				overall:  now.Add(-3 * dayDuration),
+				defaults: now.Add(-7 * dayDuration),
+				byUser: map[string]model.Time{
+					"0": now.Add(-3 * dayDuration),
+				},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			latestRetentionStartTime := findLatestRetentionStartTime(now, tc.limit)
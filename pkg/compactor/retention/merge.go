@@ -0,0 +1,335 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+	"github.com/grafana/loki/v3/pkg/util"
+)
+
+const (
+	// mergeMinTableAge is the minimum age of the end of the tables whose small chunks are merged,
+	// so that the ingesters are done flushing chunks to them.
+	mergeMinTableAge = 24 * time.Hour
+
+	mergedChunkBlockSize = 256 * 1024
+)
+
+// ChunkMerger merges the adjacent small chunks of the streams while the retention processes the index tables.
+// Only the chunks kept by the retention and the delete requests are merged, and the merged chunks are marked for deletion
+// like the expired ones. The size of the chunks is read from the index so only the TSDB index is supported.
+// The small chunks of a table are merged once, by the first retention phase after the table is older than mergeMinTableAge.
+// To avoid processing the tables again on every retention run, the merged tables are tracked in memory.
+type ChunkMerger struct {
+	smallChunkSize  int
+	targetChunkSize int
+	metrics         *mergerMetrics
+
+	mtx        sync.Mutex
+	phaseStart model.Time
+	// merged holds the tables merged by the previous retention phases which finished successfully.
+	merged map[mergedKey]struct{}
+	// pending holds the tables merged by the running retention phase.
+	pending map[mergedKey]struct{}
+}
+
+type mergedKey struct {
+	table  model.Time
+	userID string
+}
+
+// NewChunkMerger returns a ChunkMerger merging the chunks smaller than smallChunkSize into chunks of up to targetChunkSize.
+func NewChunkMerger(smallChunkSize, targetChunkSize int, r prometheus.Registerer) *ChunkMerger {
+	return &ChunkMerger{
+		smallChunkSize:  smallChunkSize,
+		targetChunkSize: targetChunkSize,
+		metrics:         newMergerMetrics(r),
+		merged:          map[mergedKey]struct{}{},
+		pending:         map[mergedKey]struct{}{},
+	}
+}
+
+func (m *ChunkMerger) MarkPhaseStarted() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.phaseStart = model.Now()
+	m.pending = map[mergedKey]struct{}{}
+}
+
+func (m *ChunkMerger) MarkPhaseFailed() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.pending = map[mergedKey]struct{}{}
+}
+
+// MarkPhaseTimedOut forgets the tables of the running phase since the phase may have skipped their merge.
+func (m *ChunkMerger) MarkPhaseTimedOut() {
+	m.MarkPhaseFailed()
+}
+
+func (m *ChunkMerger) MarkPhaseFinished() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for key := range m.pending {
+		m.merged[key] = struct{}{}
+	}
+	m.pending = map[mergedKey]struct{}{}
+}
+
+// IntervalMayHaveChunksToMerge tells if the small chunks of the index of the table of interval for userID have to be merged.
+// The table is considered merged by the retention phase since it is processed right after.
+func (m *ChunkMerger) IntervalMayHaveChunksToMerge(interval model.Interval, userID string) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !m.due(interval, userID) {
+		return false
+	}
+	m.pending[mergedKey{table: interval.Start, userID: userID}] = struct{}{}
+	return true
+}
+
+// due tells if the small chunks of the table have to be merged. It must be called with mtx held.
+func (m *ChunkMerger) due(interval model.Interval, userID string) bool {
+	if !interval.End.Before(m.phaseStart.Add(-mergeMinTableAge)) {
+		return false
+	}
+	_, ok := m.merged[mergedKey{table: interval.Start, userID: userID}]
+	return !ok
+}
+
+// newTableMerger returns the merger of the small chunks of the table, or nil if the table does not have to be merged.
+func (m *ChunkMerger) newTableMerger(tableName, userID string, chunkClient client.Client, indexer chunkIndexer) *tableMerger {
+	tableInterval := ExtractIntervalFromTableName(tableName)
+	m.mtx.Lock()
+	due := m.due(tableInterval, userID)
+	m.mtx.Unlock()
+	if !due {
+		return nil
+	}
+
+	return &tableMerger{
+		merger:        m,
+		chunkClient:   chunkClient,
+		chunkIndexer:  indexer,
+		tableInterval: tableInterval,
+		series:        map[string]*seriesRuns{},
+		merged:        map[string]struct{}{},
+	}
+}
+
+type tableMerger struct {
+	merger        *ChunkMerger
+	chunkClient   client.Client
+	chunkIndexer  chunkIndexer
+	tableInterval model.Interval
+
+	series map[string]*seriesRuns
+	// merged holds the IDs of the chunks merged into bigger chunks.
+	merged map[string]struct{}
+}
+
+// seriesRuns holds the runs of adjacent small chunks of a series.
+type seriesRuns struct {
+	labels  labels.Labels
+	runs    [][]ChunkEntry
+	current []ChunkEntry
+}
+
+func (s *seriesRuns) closeRun() {
+	if len(s.current) > 1 {
+		s.runs = append(s.runs, s.current)
+	}
+	s.current = nil
+}
+
+// add tracks a chunk kept in the index. The chunks of a series must be added in the order of their start time.
+func (t *tableMerger) add(c ChunkEntry) {
+	if c.KB == 0 {
+		// the size of the chunk is unknown.
+		return
+	}
+
+	key := string(c.UserID) + "/" + string(c.SeriesID)
+	s, ok := t.series[key]
+
+	// the chunks indexed in other tables too and the archived chunks are not merged.
+	if int(c.KB)*1024 >= t.merger.smallChunkSize || c.Tier != tsdbindex.TierStandard ||
+		c.From < t.tableInterval.Start || c.Through > t.tableInterval.End {
+		if ok {
+			s.closeRun()
+		}
+		return
+	}
+	if !ok {
+		s = &seriesRuns{labels: c.Labels.Copy()}
+		t.series[key] = s
+	}
+	s.current = append(s.current, ChunkEntry{
+		ChunkRef: ChunkRef{
+			UserID:   slices.Clone(c.UserID),
+			SeriesID: slices.Clone(c.SeriesID),
+			ChunkID:  slices.Clone(c.ChunkID),
+			From:     c.From,
+			Through:  c.Through,
+		},
+		KB: c.KB,
+	})
+}
+
+func (t *tableMerger) isMerged(chunkID []byte) bool {
+	_, ok := t.merged[unsafeGetString(chunkID)]
+	return ok
+}
+
+// merge merges the runs of small chunks of the series into chunks of up to the target size, and indexes and uploads them.
+func (t *tableMerger) merge(ctx context.Context) error {
+	for _, s := range t.series {
+		s.closeRun()
+		for _, run := range s.runs {
+			var (
+				group []ChunkEntry
+				size  int
+			)
+			for _, c := range run {
+				if len(group) > 0 && size+int(c.KB)*1024 > t.merger.targetChunkSize {
+					if err := t.mergeGroup(ctx, s.labels, group); err != nil {
+						return err
+					}
+					group, size = nil, 0
+				}
+				group = append(group, c)
+				size += int(c.KB) * 1024
+			}
+			if err := t.mergeGroup(ctx, s.labels, group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeGroup merges the lines of the chunks, deduplicating the lines written by multiple ingesters.
+func (t *tableMerger) mergeGroup(ctx context.Context, lbs labels.Labels, group []ChunkEntry) error {
+	if len(group) < 2 {
+		return nil
+	}
+
+	from, through := group[0].From, group[0].Through
+	keys := make([]chunk.Chunk, 0, len(group))
+	for _, c := range group {
+		key, err := chunk.ParseExternalKey(unsafeGetString(c.UserID), unsafeGetString(c.ChunkID))
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		from, through = min(from, c.From), max(through, c.Through)
+	}
+	chks, err := t.chunkClient.GetChunks(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if len(chks) != len(keys) {
+		return fmt.Errorf("expected %d chunks but found %d in storage", len(keys), len(chks))
+	}
+
+	its := make([]iter.EntryIterator, 0, len(chks))
+	for _, chk := range chks {
+		facade, ok := chk.Data.(*chunkenc.Facade)
+		if !ok {
+			return errors.New("invalid chunk type")
+		}
+		it, err := facade.LokiChunk().Iterator(ctx, from.Time(), through.Time().Add(time.Millisecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(lbs))
+		if err != nil {
+			return err
+		}
+		its = append(its, it)
+	}
+	it := iter.NewMergeEntryIterator(ctx, its, logproto.FORWARD)
+	defer it.Close()
+
+	var (
+		newChunks []chunk.Chunk
+		c         *chunkenc.MemChunk
+		enc       = chks[0].Data.(*chunkenc.Facade).LokiChunk().Encoding()
+	)
+	flush := func() error {
+		if c == nil || c.Size() == 0 {
+			return nil
+		}
+		if err := c.Close(); err != nil {
+			return err
+		}
+		newChunkStart, newChunkEnd := util.RoundToMilliseconds(c.Bounds())
+		newChunk := chunk.NewChunk(
+			unsafeGetString(group[0].UserID), chks[0].FingerprintModel(), chks[0].Metric,
+			chunkenc.NewFacade(c, mergedChunkBlockSize, t.merger.targetChunkSize),
+			newChunkStart,
+			newChunkEnd,
+		)
+		if err := newChunk.Encode(); err != nil {
+			return err
+		}
+		newChunks = append(newChunks, newChunk)
+		return nil
+	}
+
+	for it.Next() {
+		entry := it.At()
+		if c != nil && !c.SpaceFor(&entry) {
+			if err := flush(); err != nil {
+				return err
+			}
+			c = nil
+		}
+		if c == nil {
+			c = chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, enc, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, mergedChunkBlockSize, t.merger.targetChunkSize)
+		}
+		if _, err := c.Append(&entry); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if len(newChunks) == 0 {
+		return nil
+	}
+
+	for _, newChunk := range newChunks {
+		indexed, err := t.chunkIndexer.IndexChunk(newChunk)
+		if err != nil {
+			return err
+		}
+		if !indexed {
+			return fmt.Errorf("merged chunk of series %s is out of the table", lbs)
+		}
+	}
+	if err := t.chunkClient.PutChunks(ctx, newChunks); err != nil {
+		return err
+	}
+
+	for _, c := range group {
+		t.merged[string(c.ChunkID)] = struct{}{}
+	}
+	t.merger.metrics.chunksMergedTotal.Add(float64(len(group)))
+	t.merger.metrics.chunksWrittenTotal.Add(float64(len(newChunks)))
+	return nil
+}
@@ -0,0 +1,120 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+// sizedTable is a table recording the size of its chunks like the TSDB index.
+type sizedTable struct {
+	*table
+	kb map[string]uint32
+}
+
+func (s *sizedTable) ForEachChunk(ctx context.Context, callback ChunkEntryCallback) error {
+	return s.table.ForEachChunk(ctx, func(c ChunkEntry) (bool, error) {
+		c.KB = s.kb[string(c.ChunkID)]
+		return callback(c)
+	})
+}
+
+func TestMarkForDelete_MergeSmallChunks(t *testing.T) {
+	day := model.Now().Add(-3 * 24 * time.Hour).Time().UTC().Truncate(24 * time.Hour)
+	start := model.TimeFromUnixNano(day.UnixNano())
+	tableName := fmt.Sprintf("index_%d", day.Unix()/86400)
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	chunkClient := client.NewClient(objectClient, client.FSEncoder, schemaCfg)
+
+	foo := labels.FromStrings("app", "foo")
+	bar := labels.FromStrings("app", "bar")
+	// c1 and c2 overlap, like the chunks flushed by the replicas of a stream.
+	c1 := createChunk(t, "1", foo, start, start.Add(time.Hour))
+	c2 := createChunk(t, "1", foo, start.Add(30*time.Minute), start.Add(90*time.Minute))
+	c3 := createChunk(t, "1", foo, start.Add(2*time.Hour), start.Add(3*time.Hour))
+	big := createChunk(t, "1", foo, start.Add(4*time.Hour), start.Add(5*time.Hour))
+	c4 := createChunk(t, "1", foo, start.Add(6*time.Hour), start.Add(7*time.Hour))
+	single := createChunk(t, "1", bar, start, start.Add(time.Hour))
+	chunks := []chunk.Chunk{c1, c2, c3, big, c4, single}
+	require.NoError(t, chunkClient.PutChunks(context.Background(), chunks))
+
+	tbl := &sizedTable{table: newTable(tableName), kb: map[string]uint32{}}
+	for _, c := range chunks {
+		tbl.Put(c)
+		tbl.kb[getChunkID(c.ChunkRef)] = 10
+	}
+	tbl.kb[getChunkID(big.ChunkRef)] = 1024
+
+	merger := NewChunkMerger(100*1024, 1024*1024, prometheus.NewRegistry())
+	// the table is not merged before the phase knows its start.
+	require.Nil(t, merger.newTableMerger(tableName, "", chunkClient, tbl))
+	merger.MarkPhaseStarted()
+	require.True(t, merger.IntervalMayHaveChunksToMerge(ExtractIntervalFromTableName(tableName), ""))
+	tableMerger := merger.newTableMerger(tableName, "", chunkClient, tbl)
+	require.NotNil(t, tableMerger)
+
+	marker := &noopWriter{}
+	empty, modified, err := markForDelete(context.Background(), 0, tableName, "", marker, tbl, NewExpirationChecker(fakeLimits{}, nil), nil, tableMerger, util_log.Logger)
+	require.NoError(t, err)
+	require.False(t, empty)
+	require.True(t, modified)
+	merger.MarkPhaseFinished()
+
+	// the runs of small chunks are cut by the big chunk, and the single small chunk of bar is left alone.
+	require.Equal(t, int64(3), marker.Count())
+	fooChunks := tbl.GetChunks("1", start, start.Add(24*time.Hour), foo)
+	require.Len(t, fooChunks, 3)
+	require.ElementsMatch(t, []chunk.Chunk{big, c4}, fooChunks[:2])
+	require.Len(t, tbl.GetChunks("1", start, start.Add(24*time.Hour), bar), 1)
+
+	merged := fooChunks[2]
+	require.Equal(t, c1.From, merged.From)
+	require.Equal(t, c3.Through, merged.Through)
+	fetched, err := chunkClient.GetChunks(context.Background(), []chunk.Chunk{merged})
+	require.NoError(t, err)
+	require.Len(t, fetched, 1)
+	it, err := fetched[0].Data.(*chunkenc.Facade).LokiChunk().Iterator(context.Background(), c1.From.Time(), c3.Through.Time().Add(time.Millisecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(foo))
+	require.NoError(t, err)
+	lines := 0
+	for it.Next() {
+		lines++
+	}
+	require.NoError(t, it.Close())
+	// the lines written by both c1 and c2 are deduplicated.
+	require.Equal(t, 91+61, lines)
+
+	// the table is merged once.
+	merger.MarkPhaseStarted()
+	require.False(t, merger.IntervalMayHaveChunksToMerge(ExtractIntervalFromTableName(tableName), ""))
+	require.Nil(t, merger.newTableMerger(tableName, "", chunkClient, tbl))
+}
+
+func TestChunkMerger_RecentTables(t *testing.T) {
+	merger := NewChunkMerger(100*1024, 1024*1024, prometheus.NewRegistry())
+	merger.MarkPhaseStarted()
+	now := model.Now()
+	require.False(t, merger.IntervalMayHaveChunksToMerge(model.Interval{Start: now.Add(-24 * time.Hour), End: now}, "1"))
+
+	// the tables of a failed phase are merged again.
+	old := model.Interval{Start: now.Add(-72 * time.Hour), End: now.Add(-48 * time.Hour)}
+	require.True(t, merger.IntervalMayHaveChunksToMerge(old, "1"))
+	merger.MarkPhaseFailed()
+	merger.MarkPhaseStarted()
+	require.True(t, merger.IntervalMayHaveChunksToMerge(old, "1"))
+}
@@ -74,3 +74,43 @@ func newMarkerMetrics(r prometheus.Registerer) *markerMetrics {
 		}, []string{"table", "status"}),
 	}
 }
+
+type mergerMetrics struct {
+	chunksMergedTotal  prometheus.Counter
+	chunksWrittenTotal prometheus.Counter
+}
+
+func newMergerMetrics(r prometheus.Registerer) *mergerMetrics {
+	return &mergerMetrics{
+		chunksMergedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "small_chunks_merged_total",
+			Help:      "Total number of small chunks merged into bigger chunks.",
+		}),
+		chunksWrittenTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "merged_chunks_written_total",
+			Help:      "Total number of chunks written by merging small chunks.",
+		}),
+	}
+}
+
+type repairerMetrics struct {
+	chunksRemovedTotal prometheus.Counter
+	plansAppliedTotal  prometheus.Counter
+}
+
+func newRepairerMetrics(r prometheus.Registerer) *repairerMetrics {
+	return &repairerMetrics{
+		chunksRemovedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "repaired_chunks_total",
+			Help:      "Total number of missing or corrupted chunks removed from the index by the repair plans.",
+		}),
+		plansAppliedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_compactor",
+			Name:      "repair_plans_applied_total",
+			Help:      "Total number of repair plans applied to the index.",
+		}),
+	}
+}
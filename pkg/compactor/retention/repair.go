@@ -0,0 +1,190 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+// RepairPlansPrefix is the prefix of the repair plans in the delete request store.
+const RepairPlansPrefix = "compactor_repairs/"
+
+// RepairPlan lists the chunks of the index of a tenant in a table which are missing or corrupted in the object storage.
+// The compactor removes them from the index the next time it applies retention to the table.
+type RepairPlan struct {
+	Table     string     `json:"table"`
+	UserID    string     `json:"user_id"`
+	ChunkIDs  []string   `json:"chunk_ids"`
+	CreatedAt model.Time `json:"created_at"`
+}
+
+// RepairPlanObjectKey returns the key of the repair plan of the tenant in the table.
+func RepairPlanObjectKey(table, userID string) string {
+	return RepairPlansPrefix + table + "/" + userID + ".json"
+}
+
+// WriteRepairPlan submits the repair plan to the compactor by writing it in the delete request store.
+func WriteRepairPlan(ctx context.Context, objectClient client.ObjectClient, plan RepairPlan) error {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	return objectClient.PutObject(ctx, RepairPlanObjectKey(plan.Table, plan.UserID), bytes.NewReader(data))
+}
+
+// ChunkRepairer removes the chunks of the repair plans from the index while the retention processes the index tables.
+// The plans are loaded at the start of each retention phase and deleted once the phase which applied them finished.
+type ChunkRepairer struct {
+	objectClient client.ObjectClient
+	metrics      *repairerMetrics
+
+	mtx sync.Mutex
+	// plans holds the object keys of the repair plans by table and tenant.
+	plans map[mergedKey]string
+	// chunks holds the chunks to remove from the index by tenant and chunk ID.
+	chunks map[string]struct{}
+	// applied holds the object keys of the repair plans of the tables processed by the running retention phase.
+	applied map[string]struct{}
+}
+
+func NewChunkRepairer(objectClient client.ObjectClient, r prometheus.Registerer) *ChunkRepairer {
+	return &ChunkRepairer{
+		objectClient: objectClient,
+		metrics:      newRepairerMetrics(r),
+		plans:        map[mergedKey]string{},
+		chunks:       map[string]struct{}{},
+		applied:      map[string]struct{}{},
+	}
+}
+
+func repairChunkKey(userID, chunkID string) string {
+	return userID + "/" + chunkID
+}
+
+func (r *ChunkRepairer) MarkPhaseStarted() {
+	plans, chunks, err := r.loadPlans(context.Background())
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to load the repair plans, they will be applied by the next retention phase", "err", err)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.plans = plans
+	r.chunks = chunks
+	r.applied = map[string]struct{}{}
+}
+
+func (r *ChunkRepairer) MarkPhaseFailed() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.applied = map[string]struct{}{}
+}
+
+// MarkPhaseTimedOut keeps the repair plans of the running phase since the phase may have skipped their tables.
+func (r *ChunkRepairer) MarkPhaseTimedOut() {
+	r.MarkPhaseFailed()
+}
+
+// MarkPhaseFinished deletes the repair plans applied by the phase.
+func (r *ChunkRepairer) MarkPhaseFinished() {
+	r.mtx.Lock()
+	applied := r.applied
+	r.applied = map[string]struct{}{}
+	r.mtx.Unlock()
+
+	for key := range applied {
+		if err := r.objectClient.DeleteObject(context.Background(), key); err != nil && !r.objectClient.IsObjectNotFoundErr(err) {
+			level.Error(util_log.Logger).Log("msg", "failed to delete applied repair plan, it will be applied again", "key", key, "err", err)
+			continue
+		}
+		r.metrics.plansAppliedTotal.Inc()
+	}
+}
+
+// IntervalMayHaveDanglingChunks tells if a repair plan removes chunks from the index of the table of interval for userID.
+// The plan is considered applied by the retention phase since the table is processed right after.
+// The multi-tenant index of a table, with an empty userID, is repaired by the plans of all the tenants.
+func (r *ChunkRepairer) IntervalMayHaveDanglingChunks(interval model.Interval, userID string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	found := false
+	for key, objectKey := range r.plans {
+		if key.table == interval.Start && (userID == "" || key.userID == userID) {
+			r.applied[objectKey] = struct{}{}
+			found = true
+		}
+	}
+	return found
+}
+
+// Expired tells if the chunk has to be removed from the index by a repair plan.
+// The chunks of the cold tier are never removed, since the sweeper deletes the chunks from both tiers.
+func (r *ChunkRepairer) Expired(ref ChunkEntry) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if len(r.chunks) == 0 || ref.Tier != tsdbindex.TierStandard {
+		return false
+	}
+
+	_, ok := r.chunks[repairChunkKey(unsafeGetString(ref.UserID), unsafeGetString(ref.ChunkID))]
+	if ok {
+		r.metrics.chunksRemovedTotal.Inc()
+	}
+	return ok
+}
+
+func (r *ChunkRepairer) loadPlans(ctx context.Context) (map[mergedKey]string, map[string]struct{}, error) {
+	plans := map[mergedKey]string{}
+	chunks := map[string]struct{}{}
+
+	objects, _, err := r.objectClient.List(ctx, RepairPlansPrefix, "")
+	if err != nil {
+		return plans, chunks, err
+	}
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		plan, err := r.readPlan(ctx, object.Key)
+		if err != nil {
+			return plans, chunks, err
+		}
+		if _, err := config.ExtractTableNumberFromName(plan.Table); err != nil || len(plan.Table) < 5 || plan.UserID == "" {
+			level.Warn(util_log.Logger).Log("msg", "skipping invalid repair plan", "key", object.Key, "table", plan.Table, "user", plan.UserID)
+			continue
+		}
+
+		plans[mergedKey{table: ExtractIntervalFromTableName(plan.Table).Start, userID: plan.UserID}] = object.Key
+		for _, chunkID := range plan.ChunkIDs {
+			chunks[repairChunkKey(plan.UserID, chunkID)] = struct{}{}
+		}
+	}
+	return plans, chunks, nil
+}
+
+func (r *ChunkRepairer) readPlan(ctx context.Context, key string) (RepairPlan, error) {
+	var plan RepairPlan
+	reader, _, err := r.objectClient.GetObject(ctx, key)
+	if err != nil {
+		return plan, err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(&plan); err != nil {
+		return plan, fmt.Errorf("failed to decode repair plan %s: %w", key, err)
+	}
+	return plan, nil
+}
@@ -0,0 +1,68 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+)
+
+func TestChunkRepairer(t *testing.T) {
+	day := model.Now().Add(-3 * 24 * time.Hour).Time().UTC().Truncate(24 * time.Hour)
+	tableName := fmt.Sprintf("index_%d", day.Unix()/86400)
+	tableInterval := ExtractIntervalFromTableName(tableName)
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, WriteRepairPlan(context.Background(), objectClient, RepairPlan{
+		Table:     tableName,
+		UserID:    "1",
+		ChunkIDs:  []string{"missing", "corrupted"},
+		CreatedAt: model.Now(),
+	}))
+
+	repairer := NewChunkRepairer(objectClient, prometheus.NewRegistry())
+	chunkEntry := func(userID, chunkID string) ChunkEntry {
+		return ChunkEntry{ChunkRef: ChunkRef{UserID: []byte(userID), ChunkID: []byte(chunkID)}}
+	}
+
+	// the plans are loaded when the phase starts.
+	require.False(t, repairer.Expired(chunkEntry("1", "missing")))
+	repairer.MarkPhaseStarted()
+
+	require.False(t, repairer.IntervalMayHaveDanglingChunks(tableInterval, "2"))
+	require.False(t, repairer.IntervalMayHaveDanglingChunks(model.Interval{Start: tableInterval.End, End: tableInterval.End.Add(24 * time.Hour)}, "1"))
+	require.True(t, repairer.IntervalMayHaveDanglingChunks(tableInterval, "1"))
+	require.True(t, repairer.Expired(chunkEntry("1", "missing")))
+	require.True(t, repairer.Expired(chunkEntry("1", "corrupted")))
+	require.False(t, repairer.Expired(chunkEntry("1", "healthy")))
+	require.False(t, repairer.Expired(chunkEntry("2", "missing")))
+
+	// the chunks of the cold tier are never removed.
+	coldEntry := chunkEntry("1", "missing")
+	coldEntry.Tier = tsdbindex.TierCold
+	require.False(t, repairer.Expired(coldEntry))
+
+	// the plan is kept when the phase fails.
+	repairer.MarkPhaseFailed()
+	repairer.MarkPhaseStarted()
+	require.True(t, repairer.Expired(chunkEntry("1", "missing")))
+
+	// the multi-tenant index of the table is repaired too, and the plan is deleted once applied.
+	require.True(t, repairer.IntervalMayHaveDanglingChunks(tableInterval, ""))
+	repairer.MarkPhaseFinished()
+	exists, err := objectClient.ObjectExists(context.Background(), RepairPlanObjectKey(tableName, "1"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	repairer.MarkPhaseStarted()
+	require.False(t, repairer.IntervalMayHaveDanglingChunks(tableInterval, "1"))
+	require.False(t, repairer.Expired(chunkEntry("1", "missing")))
+}
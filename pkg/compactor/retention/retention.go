@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/storage/chunk"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
 	chunk_util "github.com/grafana/loki/v3/pkg/storage/chunk/client/util"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
 	"github.com/grafana/loki/v3/pkg/util"
 	"github.com/grafana/loki/v3/pkg/util/filter"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
@@ -46,6 +47,10 @@ func (c ChunkRef) String() string {
 type ChunkEntry struct {
 	ChunkRef
 	Labels labels.Labels
+	// Tier is the storage tier of the chunk recorded in the index, only supported by TSDB.
+	Tier tsdbindex.Tier
+	// KB is the approximate size of the chunk recorded in the index, only supported by TSDB.
+	KB uint32
 }
 
 type ChunkEntryCallback func(ChunkEntry) (deleteChunk bool, err error)
@@ -89,15 +94,18 @@ type Marker struct {
 	markerMetrics    *markerMetrics
 	chunkClient      client.Client
 	markTimeout      time.Duration
+	// chunkMerger is nil when the small chunks are not merged.
+	chunkMerger *ChunkMerger
 }
 
-func NewMarker(workingDirectory string, expiration ExpirationChecker, markTimeout time.Duration, chunkClient client.Client, r prometheus.Registerer) (*Marker, error) {
+func NewMarker(workingDirectory string, expiration ExpirationChecker, markTimeout time.Duration, chunkClient client.Client, chunkMerger *ChunkMerger, r prometheus.Registerer) (*Marker, error) {
 	return &Marker{
 		workingDirectory: workingDirectory,
 		expiration:       expiration,
 		markerMetrics:    newMarkerMetrics(r),
 		chunkClient:      chunkClient,
 		markTimeout:      markTimeout,
+		chunkMerger:      chunkMerger,
 	}, nil
 }
 
@@ -130,8 +138,12 @@ func (t *Marker) markTable(ctx context.Context, tableName, userID string, indexP
 	}
 
 	chunkRewriter := newChunkRewriter(t.chunkClient, tableName, indexProcessor)
+	var merger *tableMerger
+	if t.chunkMerger != nil {
+		merger = t.chunkMerger.newTableMerger(tableName, userID, t.chunkClient, indexProcessor)
+	}
 
-	empty, modified, err := markForDelete(ctx, t.markTimeout, tableName, markerWriter, indexProcessor, t.expiration, chunkRewriter, logger)
+	empty, modified, err := markForDelete(ctx, t.markTimeout, tableName, userID, markerWriter, indexProcessor, t.expiration, chunkRewriter, merger, logger)
 	if err != nil {
 		return false, false, err
 	}
@@ -156,11 +168,12 @@ func (t *Marker) markTable(ctx context.Context, tableName, userID string, indexP
 func markForDelete(
 	ctx context.Context,
 	timeout time.Duration,
-	tableName string,
+	tableName, userID string,
 	marker MarkerStorageWriter,
 	indexFile IndexProcessor,
 	expiration ExpirationChecker,
 	chunkRewriter *chunkRewriter,
+	merger *tableMerger,
 	logger log.Logger,
 ) (bool, bool, error) {
 	seriesMap := newUserSeriesMap()
@@ -226,6 +239,9 @@ func markForDelete(
 
 		empty = false
 		seriesMap.MarkSeriesNotDeleted(c.SeriesID, c.UserID)
+		if merger != nil {
+			merger.add(c)
+		}
 		return false, nil
 	})
 	if err != nil {
@@ -233,9 +249,13 @@ func markForDelete(
 			// Deletes timed out. Don't return an error so compaction can continue and deletes can be retried
 			level.Warn(logger).Log("msg", "Timed out while running delete")
 			expiration.MarkPhaseTimedOut()
+			// the small chunks are merged by the next retention run.
+			merger = nil
 		} else {
 			return false, false, err
 		}
+	} else if tracker, ok := expiration.(IndexRetentionTracker); ok {
+		tracker.MarkIndexProcessed(tableName, userID)
 	}
 
 	if !chunksFound {
@@ -248,6 +268,26 @@ func markForDelete(
 		return false, false, ctx.Err()
 	}
 
+	if merger != nil {
+		if err := merger.merge(ctx); err != nil {
+			return false, false, fmt.Errorf("failed to merge small chunks: %w", err)
+		}
+		// the merged chunks are dropped from the index in the same update as the indexing of the chunks they were merged into.
+		if len(merger.merged) > 0 {
+			modified = true
+			err := indexFile.ForEachChunk(ctx, func(c ChunkEntry) (bool, error) {
+				if !merger.isMerged(c.ChunkID) {
+					return false, nil
+				}
+				return true, marker.Put(c.ChunkID)
+			})
+			if err != nil {
+				return false, false, err
+			}
+			level.Info(logger).Log("msg", "merged small chunks", "chunks", len(merger.merged))
+		}
+	}
+
 	return false, modified, seriesMap.ForEach(func(info userSeriesInfo) error {
 		if !info.isDeleted {
 			return nil
@@ -0,0 +1,333 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/util/filter"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// StructuredMetadataRetentionPrefix is the prefix of the structured metadata retention watermarks in the delete request store.
+const StructuredMetadataRetentionPrefix = "compactor_structured_metadata_retention/"
+
+const tableDuration = 24 * time.Hour
+
+// structuredMetadataRetention applies the per-line retention rules matching the structured metadata of the log lines.
+// The lines are removed by rewriting the chunks once they are entirely older than the period of a rule.
+// To avoid rewriting the same chunks on every retention run, a watermark is kept for the index of each tenant in each table:
+// the start of the last retention phase which processed it and finished successfully. A chunk is only considered when it
+// went out of the period of a rule since the watermarks of the tables it is indexed in.
+// The watermarks are stored in the object storage so that they survive the restarts of the compactor.
+type structuredMetadataRetention struct {
+	limits Limits
+	// objectClient stores the watermarks, they are only kept in memory when it is nil.
+	objectClient client.ObjectClient
+
+	mtx sync.RWMutex
+	// enabled is set when any tenant has structured metadata retention rules.
+	enabled bool
+	// phaseStart is the start time of the running retention phase.
+	phaseStart model.Time
+	// applied holds the watermarks by table and tenant. The multi-tenant index of a table has an empty tenant.
+	applied map[mergedKey]model.Time
+	// processed holds the indexes processed by the running retention phase.
+	processed map[mergedKey]struct{}
+}
+
+// structuredMetadataRetentionWatermarks is the object holding the watermarks of a table.
+type structuredMetadataRetentionWatermarks struct {
+	TableStart model.Time            `json:"table_start"`
+	Applied    map[string]model.Time `json:"applied"`
+}
+
+func structuredMetadataRetentionObjectKey(tableStart model.Time) string {
+	return fmt.Sprintf("%s%d.json", StructuredMetadataRetentionPrefix, tableStart.Unix()/int64(tableDuration/time.Second))
+}
+
+func newStructuredMetadataRetention(limits Limits, objectClient client.ObjectClient) *structuredMetadataRetention {
+	return &structuredMetadataRetention{
+		limits:       limits,
+		objectClient: objectClient,
+		applied:      map[mergedKey]model.Time{},
+		processed:    map[mergedKey]struct{}{},
+	}
+}
+
+func (s *structuredMetadataRetention) markPhaseStarted(now model.Time) {
+	enabled := hasStructuredMetadataRetention(s.limits)
+	var loaded map[mergedKey]model.Time
+	if enabled && s.objectClient != nil {
+		var err error
+		loaded, err = s.loadWatermarks(context.Background())
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to load the structured metadata retention watermarks, chunks may be rewritten again", "err", err)
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.enabled = enabled
+	s.phaseStart = now
+	s.processed = map[mergedKey]struct{}{}
+	for key, ts := range loaded {
+		if ts > s.applied[key] {
+			s.applied[key] = ts
+		}
+	}
+}
+
+// markPhaseFailed forgets the indexes processed by the running phase. It is also used when the phase times out
+// since it may have skipped some of their chunks.
+func (s *structuredMetadataRetention) markPhaseFailed() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.processed = map[mergedKey]struct{}{}
+}
+
+// markPhaseFinished moves the watermarks of the indexes processed by the phase to its start and stores them.
+// The watermarks of a table are read, updated and written back. When compactors sharing the tables race on it,
+// a lost update only leaves an older watermark, so chunks are rewritten again but none is missed.
+func (s *structuredMetadataRetention) markPhaseFinished() {
+	s.mtx.Lock()
+	phaseStart := s.phaseStart
+	byTable := map[model.Time][]string{}
+	for key := range s.processed {
+		s.applied[key] = phaseStart
+		byTable[key.table] = append(byTable[key.table], key.userID)
+	}
+	s.processed = map[mergedKey]struct{}{}
+	s.mtx.Unlock()
+
+	if s.objectClient == nil {
+		return
+	}
+	for table, userIDs := range byTable {
+		if err := s.storeWatermarks(context.Background(), table, userIDs, phaseStart); err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to store the structured metadata retention watermarks, chunks may be rewritten again after a restart", "table", table, "err", err)
+		}
+	}
+}
+
+// markIndexProcessed records that the running phase processed the index of userID in the table of interval.
+// Only the indexes which may have chunks with lines out of the structured metadata retention are recorded.
+func (s *structuredMetadataRetention) markIndexProcessed(interval model.Interval, userID string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.enabled || (userID != "" && len(s.limits.StructuredMetadataRetention(userID)) == 0) {
+		return
+	}
+	s.processed[mergedKey{table: interval.Start, userID: userID}] = struct{}{}
+}
+
+// lastApplied returns the oldest watermark of the tables which may index the chunk.
+// The watermarks of the tenant and of the multi-tenant index are used since the chunk can be indexed in both,
+// and a table without any watermark was never processed.
+func (s *structuredMetadataRetention) lastApplied(ref ChunkEntry, userID string) model.Time {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var lastApplied model.Time
+	first := true
+	for table := tableStart(ref.From); !table.After(ref.Through); table = table.Add(tableDuration) {
+		var (
+			watermark model.Time
+			found     bool
+		)
+		for _, key := range []mergedKey{{table: table, userID: userID}, {table: table}} {
+			ts, ok := s.applied[key]
+			if !ok {
+				continue
+			}
+			if !found || ts < watermark {
+				watermark = ts
+			}
+			found = true
+		}
+		if first || watermark < lastApplied {
+			lastApplied = watermark
+		}
+		first = false
+	}
+	return lastApplied
+}
+
+func tableStart(ts model.Time) model.Time {
+	period := int64(tableDuration / time.Millisecond)
+	return model.Time(int64(ts) / period * period)
+}
+
+func (s *structuredMetadataRetention) loadWatermarks(ctx context.Context) (map[mergedKey]model.Time, error) {
+	loaded := map[mergedKey]model.Time{}
+	objects, _, err := s.objectClient.List(ctx, StructuredMetadataRetentionPrefix, "")
+	if err != nil {
+		return loaded, err
+	}
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		watermarks, err := s.readWatermarks(ctx, object.Key)
+		if err != nil {
+			return loaded, err
+		}
+		for userID, ts := range watermarks.Applied {
+			loaded[mergedKey{table: watermarks.TableStart, userID: userID}] = ts
+		}
+	}
+	return loaded, nil
+}
+
+func (s *structuredMetadataRetention) readWatermarks(ctx context.Context, key string) (structuredMetadataRetentionWatermarks, error) {
+	var watermarks structuredMetadataRetentionWatermarks
+	reader, _, err := s.objectClient.GetObject(ctx, key)
+	if err != nil {
+		return watermarks, err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(&watermarks); err != nil {
+		return watermarks, fmt.Errorf("failed to decode structured metadata retention watermarks %s: %w", key, err)
+	}
+	return watermarks, nil
+}
+
+func (s *structuredMetadataRetention) storeWatermarks(ctx context.Context, table model.Time, userIDs []string, ts model.Time) error {
+	key := structuredMetadataRetentionObjectKey(table)
+	watermarks, err := s.readWatermarks(ctx, key)
+	if err != nil && !s.objectClient.IsObjectNotFoundErr(err) {
+		return err
+	}
+	watermarks.TableStart = table
+	if watermarks.Applied == nil {
+		watermarks.Applied = map[string]model.Time{}
+	}
+	for _, userID := range userIDs {
+		if ts > watermarks.Applied[userID] {
+			watermarks.Applied[userID] = ts
+		}
+	}
+
+	data, err := json.Marshal(watermarks)
+	if err != nil {
+		return err
+	}
+	return s.objectClient.PutObject(ctx, key, bytes.NewReader(data))
+}
+
+// hasStructuredMetadataRetention tells if the default limits or any tenant have structured metadata retention rules.
+func hasStructuredMetadataRetention(limits Limits) bool {
+	if defaults := limits.DefaultLimits(); defaults != nil && len(defaults.StructuredMetadataRetention) > 0 {
+		return true
+	}
+	for _, l := range limits.AllByUserID() {
+		if l != nil && len(l.StructuredMetadataRetention) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// expired returns the filter removing the lines of the chunk which are out of the retention of the structured metadata rules.
+// streamPeriod is the retention period of the stream of the chunk, 0 meaning retention is disabled for it.
+func (s *structuredMetadataRetention) expired(ref ChunkEntry, streamPeriod time.Duration, now model.Time) (bool, filter.Func) {
+	userID := unsafeGetString(ref.UserID)
+	rules := matchingStructuredMetadataRetention(s.limits.StructuredMetadataRetention(userID), ref.Labels)
+	if len(rules) == 0 {
+		return false, nil
+	}
+
+	lastApplied := s.lastApplied(ref, userID)
+
+	chunkMayHaveExpiredLines := false
+	for _, rule := range rules {
+		period := time.Duration(rule.Period)
+		if !shortensRetention(period, streamPeriod) {
+			continue
+		}
+		if now.Sub(ref.Through) > period && lastApplied.Sub(ref.Through) <= period {
+			chunkMayHaveExpiredLines = true
+			break
+		}
+	}
+	if !chunkMayHaveExpiredLines {
+		return false, nil
+	}
+
+	return true, func(ts time.Time, _ string, structuredMetadata ...labels.Label) bool {
+		rule, found := structuredMetadataRetentionFor(rules, structuredMetadata)
+		if !found || !shortensRetention(time.Duration(rule.Period), streamPeriod) {
+			return false
+		}
+		return now.Time().Sub(ts) > time.Duration(rule.Period)
+	}
+}
+
+// shortensRetention tells if the period of a rule is shorter than the retention period of the stream.
+// The rules can not extend the retention of the streams.
+func shortensRetention(period, streamPeriod time.Duration) bool {
+	return streamPeriod <= 0 || period < streamPeriod
+}
+
+// matchingStructuredMetadataRetention returns the rules whose selector matches the stream.
+func matchingStructuredMetadataRetention(rules []validation.StructuredMetadataRetention, lbs labels.Labels) []validation.StructuredMetadataRetention {
+	var matched []validation.StructuredMetadataRetention
+Outer:
+	for _, rule := range rules {
+		for _, m := range rule.Matchers {
+			if !m.Matches(lbs.Get(m.Name)) {
+				continue Outer
+			}
+		}
+		matched = append(matched, rule)
+	}
+	return matched
+}
+
+// structuredMetadataRetentionFor returns the rule to apply to a log line with the given structured metadata.
+// In case multiple rules are matching, the highest priority is picked and then the lowest period.
+func structuredMetadataRetentionFor(rules []validation.StructuredMetadataRetention, structuredMetadata []labels.Label) (validation.StructuredMetadataRetention, bool) {
+	var (
+		matchedRule validation.StructuredMetadataRetention
+		found       bool
+	)
+Outer:
+	for _, rule := range rules {
+		for _, m := range rule.StructuredMetadataMatchers {
+			if !m.Matches(structuredMetadataValue(structuredMetadata, m.Name)) {
+				continue Outer
+			}
+		}
+		if found {
+			if matchedRule.Priority > rule.Priority {
+				continue
+			}
+			if matchedRule.Priority == rule.Priority && matchedRule.Period <= rule.Period {
+				continue
+			}
+		}
+		found = true
+		matchedRule = rule
+	}
+	return matchedRule, found
+}
+
+func structuredMetadataValue(structuredMetadata []labels.Label, name string) string {
+	for _, l := range structuredMetadata {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,362 @@
+package rollup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compression"
+	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	util_validation "github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+const (
+	blockSize  = 256 * 1024
+	targetSize = 1500 * 1024
+)
+
+// userRollups holds the rules of a user which are due in a table and the series of the table matching them.
+type userRollups struct {
+	userID string
+	rules  []*ruleRollups
+	// series is keyed by series ID. A nil value means the series does not match any rule.
+	series map[string]*seriesChunks
+}
+
+type seriesChunks struct {
+	labels labels.Labels
+	chunks []retention.ChunkRef
+	rules  []*ruleRollups
+}
+
+// ruleRollups aggregates the log lines of the series matching a rule.
+type ruleRollups struct {
+	*util_validation.RollupRule
+	matchers []*labels.Matcher
+	pipeline log.Pipeline
+
+	// rolledUp is true when the index of the table already has the rollups of the rule.
+	rolledUp bool
+	// series is the number of series rolled up.
+	series int
+	// aggregates is keyed by the hash of the labels of the rollup series.
+	aggregates map[uint64]*aggregate
+}
+
+type aggregate struct {
+	labels  labels.Labels
+	buckets map[int64]*bucket
+}
+
+type bucket struct {
+	count, bytes, sum float64
+}
+
+func newUserRollups(userID string, rules []*util_validation.RollupRule) (*userRollups, error) {
+	user := &userRollups{
+		userID: userID,
+		series: map[string]*seriesChunks{},
+	}
+	for _, rule := range rules {
+		expr, err := syntax.ParseLogSelector(rule.Selector, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of rollup rule %s: %w", rule.Name, err)
+		}
+		pipeline, err := expr.Pipeline()
+		if err != nil {
+			return nil, fmt.Errorf("invalid pipeline of rollup rule %s: %w", rule.Name, err)
+		}
+		user.rules = append(user.rules, &ruleRollups{
+			RollupRule: rule,
+			matchers:   expr.Matchers(),
+			pipeline:   pipeline,
+			aggregates: map[uint64]*aggregate{},
+		})
+	}
+	return user, nil
+}
+
+// addChunk tracks the chunk if its series matches rules of the user.
+// The chunks of the rollups themselves tell which rules were already rolled up in the table.
+func (u *userRollups) addChunk(c retention.ChunkEntry) {
+	if len(u.rules) == 0 {
+		return
+	}
+	if name := c.Labels.Get(constants.RollupLabel); name != "" {
+		for _, r := range u.rules {
+			if r.Name == name {
+				r.rolledUp = true
+			}
+		}
+		return
+	}
+
+	s, ok := u.series[string(c.SeriesID)]
+	if !ok {
+		var rules []*ruleRollups
+		for _, r := range u.rules {
+			if matches(r.matchers, c.Labels) {
+				rules = append(rules, r)
+			}
+		}
+		if len(rules) > 0 {
+			s = &seriesChunks{labels: c.Labels.Copy(), rules: rules}
+		}
+		u.series[string(c.SeriesID)] = s
+	}
+	if s == nil {
+		return
+	}
+	s.chunks = append(s.chunks, retention.ChunkRef{
+		ChunkID: slices.Clone(c.ChunkID),
+		From:    c.From,
+		Through: c.Through,
+	})
+}
+
+// rollup aggregates the lines of the series within the table interval and returns the chunks of the rollups.
+func (u *userRollups) rollup(ctx context.Context, tableInterval model.Interval, chunkClient client.Client) ([]chunk.Chunk, error) {
+	for _, s := range u.series {
+		if s == nil {
+			continue
+		}
+		var rules []*ruleRollups
+		for _, r := range s.rules {
+			if !r.rolledUp {
+				rules = append(rules, r)
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		if err := u.rollupSeries(ctx, s, rules, tableInterval, chunkClient); err != nil {
+			return nil, err
+		}
+	}
+
+	var chunks []chunk.Chunk
+	for _, r := range u.rules {
+		if r.rolledUp {
+			continue
+		}
+		for _, agg := range r.aggregates {
+			chks, err := u.buildChunks(r, agg)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, chks...)
+		}
+	}
+	return chunks, nil
+}
+
+// rollupSeries feeds the lines of the series to the rules. The chunks are read by groups of overlapping chunks
+// to deduplicate the lines written by multiple ingesters without loading all the chunks of the series at once.
+func (u *userRollups) rollupSeries(ctx context.Context, s *seriesChunks, rules []*ruleRollups, tableInterval model.Interval, chunkClient client.Client) error {
+	sort.Slice(s.chunks, func(i, j int) bool {
+		return s.chunks[i].From < s.chunks[j].From
+	})
+
+	streams := make([]log.StreamPipeline, 0, len(rules))
+	for _, r := range rules {
+		streams = append(streams, r.pipeline.ForStream(s.labels))
+		r.series++
+	}
+
+	from, through := tableInterval.Start.Time(), tableInterval.End.Time().Add(time.Millisecond)
+	for start := 0; start < len(s.chunks); {
+		end, groupThrough := start+1, s.chunks[start].Through
+		for end < len(s.chunks) && s.chunks[end].From <= groupThrough {
+			if s.chunks[end].Through > groupThrough {
+				groupThrough = s.chunks[end].Through
+			}
+			end++
+		}
+
+		keys := make([]chunk.Chunk, 0, end-start)
+		for _, ref := range s.chunks[start:end] {
+			c, err := chunk.ParseExternalKey(u.userID, string(ref.ChunkID))
+			if err != nil {
+				return err
+			}
+			keys = append(keys, c)
+		}
+		chks, err := chunkClient.GetChunks(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		its := make([]iter.EntryIterator, 0, len(chks))
+		for _, chk := range chks {
+			facade, ok := chk.Data.(*chunkenc.Facade)
+			if !ok {
+				return errors.New("invalid chunk type")
+			}
+			it, err := facade.LokiChunk().Iterator(ctx, from, through, logproto.FORWARD, log.NewNoopPipeline().ForStream(s.labels))
+			if err != nil {
+				return err
+			}
+			its = append(its, it)
+		}
+
+		it := iter.NewMergeEntryIterator(ctx, its, logproto.FORWARD)
+		for it.Next() {
+			entry := it.At()
+			for i, r := range rules {
+				r.add(streams[i], entry, tableInterval)
+			}
+		}
+		if err := it.Err(); err != nil {
+			_ = it.Close()
+			return err
+		}
+		if err := it.Close(); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// add aggregates the line into the bucket of its rollup series. A bucket holds the lines of (t-resolution, t]
+// so a range aggregation over the rollups ending at a multiple of the resolution covers exactly its range.
+// The last bucket of the table is stored at the end of the table interval to keep the rollups within the table.
+func (r *ruleRollups) add(sp log.StreamPipeline, entry logproto.Entry, tableInterval model.Interval) {
+	ts := entry.Timestamp.UnixNano()
+	line, lbs, ok := sp.ProcessString(ts, entry.Line, logproto.FromLabelAdaptersToLabels(entry.StructuredMetadata)...)
+	if !ok {
+		return
+	}
+	result := lbs.Labels()
+
+	b := labels.NewScratchBuilder(len(r.By) + 1)
+	b.Add(constants.RollupLabel, r.Name)
+	for _, name := range r.By {
+		if v := result.Get(name); v != "" {
+			b.Add(name, v)
+		}
+	}
+	b.Sort()
+	group := b.Labels()
+
+	agg, ok := r.aggregates[group.Hash()]
+	if !ok {
+		agg = &aggregate{labels: group, buckets: map[int64]*bucket{}}
+		r.aggregates[group.Hash()] = agg
+	}
+
+	resolution := time.Duration(r.Resolution).Nanoseconds()
+	bucketTs := ts - ts%resolution
+	if ts%resolution != 0 {
+		bucketTs += resolution
+	}
+	if tableEnd := tableInterval.End.Time().UnixNano(); bucketTs > tableEnd {
+		bucketTs = tableEnd
+	}
+	bkt, ok := agg.buckets[bucketTs]
+	if !ok {
+		bkt = &bucket{}
+		agg.buckets[bucketTs] = bkt
+	}
+
+	bkt.count++
+	bkt.bytes += float64(len(line))
+	if r.Unwrap != "" {
+		if v, err := strconv.ParseFloat(result.Get(r.Unwrap), 64); err == nil {
+			bkt.sum += v
+		}
+	}
+}
+
+// buildChunks writes the buckets of the rollup series into chunks of empty lines carrying the aggregated values
+// as structured metadata.
+func (u *userRollups) buildChunks(r *ruleRollups, agg *aggregate) ([]chunk.Chunk, error) {
+	var (
+		chunks []chunk.Chunk
+		c      *chunkenc.MemChunk
+	)
+	metric := labels.NewBuilder(agg.labels).Set(labels.MetricName, "logs").Labels()
+	fp := model.Fingerprint(agg.labels.Hash())
+
+	flush := func() error {
+		if c == nil || c.Size() == 0 {
+			return nil
+		}
+		if err := c.Close(); err != nil {
+			return err
+		}
+		from, through := c.Bounds()
+		chk := chunk.NewChunk(u.userID, fp, metric, chunkenc.NewFacade(c, blockSize, targetSize), model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(through.UnixNano()))
+		if err := chk.Encode(); err != nil {
+			return err
+		}
+		chunks = append(chunks, chk)
+		return nil
+	}
+
+	for _, ts := range sortedTimes(agg.buckets) {
+		bkt := agg.buckets[ts]
+		metadata := logproto.FromLabelsToLabelAdapters(labels.FromStrings(
+			constants.RollupCount, formatValue(bkt.count),
+			constants.RollupBytes, formatValue(bkt.bytes),
+		))
+		if r.Unwrap != "" {
+			metadata = append(metadata, logproto.LabelAdapter{Name: constants.RollupSum, Value: formatValue(bkt.sum)})
+		}
+		entry := &logproto.Entry{Timestamp: time.Unix(0, ts), StructuredMetadata: metadata}
+
+		if c != nil && !c.SpaceFor(entry) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			c = nil
+		}
+		if c == nil {
+			c = chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, compression.Snappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, blockSize, targetSize)
+		}
+		if _, err := c.Append(entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+func matches(matchers []*labels.Matcher, lbs labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbs.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedTimes(buckets map[int64]*bucket) []int64 {
+	times := make([]int64, 0, len(buckets))
+	for ts := range buckets {
+		times = append(times, ts)
+	}
+	slices.Sort(times)
+	return times
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
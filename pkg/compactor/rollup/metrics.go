@@ -0,0 +1,28 @@
+package rollup
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+type metrics struct {
+	chunksWrittenTotal prometheus.Counter
+	rulesRolledUpTotal prometheus.Counter
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		chunksWrittenTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "compactor_rollup_chunks_written_total",
+			Help:      "Total number of rollup chunks written by the compactor.",
+		}),
+		rulesRolledUpTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "compactor_rollup_rules_rolled_up_total",
+			Help:      "Total number of rollup rules rolled up in an index table of a user.",
+		}),
+	}
+}
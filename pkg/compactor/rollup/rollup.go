@@ -0,0 +1,215 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	util_validation "github.com/grafana/loki/v3/pkg/util/validation"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type Limits interface {
+	RollupRules(ctx context.Context, userID string) []*util_validation.RollupRule
+	AllByUserID() map[string]*validation.Limits
+	DefaultLimits() *validation.Limits
+}
+
+// Manager computes the rollups of the index tables processed by the retention.
+// The rollups of a rule are computed once per table, when the whole table is older than the "after" of the rule.
+// To avoid processing the tables again on every retention run, the rules rolled up in the tables are tracked in memory,
+// and the index of the tables is checked for existing rollups after a restart.
+type Manager struct {
+	limits  Limits
+	metrics *metrics
+
+	mtx        sync.Mutex
+	phaseStart model.Time
+	// rolledUp holds the rules rolled up by the previous retention phases which finished successfully.
+	rolledUp map[rolledUpKey]struct{}
+	// pending holds the rules rolled up by the running retention phase.
+	pending map[rolledUpKey]struct{}
+}
+
+type rolledUpKey struct {
+	table  model.Time
+	userID string
+	rule   string
+}
+
+func NewManager(limits Limits, r prometheus.Registerer) *Manager {
+	return &Manager{
+		limits:   limits,
+		metrics:  newMetrics(r),
+		rolledUp: map[rolledUpKey]struct{}{},
+		pending:  map[rolledUpKey]struct{}{},
+	}
+}
+
+func (m *Manager) MarkPhaseStarted() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.phaseStart = model.Now()
+	m.pending = map[rolledUpKey]struct{}{}
+}
+
+func (m *Manager) MarkPhaseFailed() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.pending = map[rolledUpKey]struct{}{}
+}
+
+func (m *Manager) MarkPhaseFinished() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for key := range m.pending {
+		m.rolledUp[key] = struct{}{}
+	}
+	m.pending = map[rolledUpKey]struct{}{}
+}
+
+// IntervalMayHaveRollups tells if the index of the table of interval for userID has rules to roll up.
+// When userID is empty, it checks the common index of the table with the rules of all the users.
+// The rules are considered rolled up by the retention phase since the table is processed right after.
+func (m *Manager) IntervalMayHaveRollups(interval model.Interval, userID string) bool {
+	var rules []*util_validation.RollupRule
+	if userID == "" {
+		rules = m.allRules()
+	} else {
+		rules = m.limits.RollupRules(context.Background(), userID)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	mayHaveRollups := false
+	for _, rule := range m.dueRules(rules, interval, userID) {
+		m.pending[rolledUpKey{table: interval.Start, userID: userID, rule: rule.Name}] = struct{}{}
+		mayHaveRollups = true
+	}
+	return mayHaveRollups
+}
+
+// allRules returns the default rules and the rules of all the users with overrides.
+func (m *Manager) allRules() []*util_validation.RollupRule {
+	var rules []*util_validation.RollupRule
+	if defaults := m.limits.DefaultLimits(); defaults != nil {
+		rules = append(rules, defaults.RollupRules...)
+	}
+	for _, limits := range m.limits.AllByUserID() {
+		rules = append(rules, limits.RollupRules...)
+	}
+	return rules
+}
+
+// dueRules returns the rules whose rollups have to be computed for the table of interval: the whole table is older than
+// their "after" and they have not been rolled up by a previous retention phase. It must be called with mtx held.
+func (m *Manager) dueRules(rules []*util_validation.RollupRule, interval model.Interval, userID string) []*util_validation.RollupRule {
+	var due []*util_validation.RollupRule
+	for _, rule := range rules {
+		if !interval.End.Before(m.phaseStart.Add(-time.Duration(rule.After))) {
+			continue
+		}
+		if _, ok := m.rolledUp[rolledUpKey{table: interval.Start, userID: userID, rule: rule.Name}]; ok {
+			continue
+		}
+		due = append(due, rule)
+	}
+	return due
+}
+
+// WrapTableMarker returns a TableMarker computing the rollups of the tables before marking their chunks with next.
+func (m *Manager) WrapTableMarker(next retention.TableMarker, chunkClient client.Client) retention.TableMarker {
+	return &tableMarker{
+		manager:     m,
+		next:        next,
+		chunkClient: chunkClient,
+	}
+}
+
+type tableMarker struct {
+	manager     *Manager
+	next        retention.TableMarker
+	chunkClient client.Client
+}
+
+func (t *tableMarker) MarkForDelete(ctx context.Context, tableName, userID string, indexProcessor retention.IndexProcessor, logger log.Logger) (bool, bool, error) {
+	rolledUp, err := t.manager.rollupTable(ctx, tableName, indexProcessor, t.chunkClient, logger)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to compute rollups: %w", err)
+	}
+
+	empty, modified, err := t.next.MarkForDelete(ctx, tableName, userID, indexProcessor, logger)
+	if err != nil {
+		return false, false, err
+	}
+	// the rollups are added to the index when it is built so the table is not empty.
+	return empty && !rolledUp, modified || rolledUp, nil
+}
+
+// rollupTable computes and indexes the rollups of the rules of the users of the table which are due.
+// It returns true if rollup chunks were added to the index.
+func (m *Manager) rollupTable(ctx context.Context, tableName string, indexProcessor retention.IndexProcessor, chunkClient client.Client, logger log.Logger) (bool, error) {
+	tableInterval := retention.ExtractIntervalFromTableName(tableName)
+	users := map[string]*userRollups{}
+
+	err := indexProcessor.ForEachChunk(ctx, func(c retention.ChunkEntry) (bool, error) {
+		userID := string(c.UserID)
+		user, ok := users[userID]
+		if !ok {
+			m.mtx.Lock()
+			rules := m.dueRules(m.limits.RollupRules(ctx, userID), tableInterval, userID)
+			m.mtx.Unlock()
+
+			var err error
+			user, err = newUserRollups(userID, rules)
+			if err != nil {
+				return false, err
+			}
+			users[userID] = user
+		}
+		user.addChunk(c)
+		return false, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	rolledUp := false
+	for _, user := range users {
+		chunks, err := user.rollup(ctx, tableInterval, chunkClient)
+		if err != nil {
+			return false, err
+		}
+		for _, chk := range chunks {
+			indexed, err := indexProcessor.IndexChunk(chk)
+			if err != nil {
+				return false, err
+			}
+			if !indexed {
+				continue
+			}
+			if err := chunkClient.PutChunks(ctx, []chunk.Chunk{chk}); err != nil {
+				return false, err
+			}
+			rolledUp = true
+			m.metrics.chunksWrittenTotal.Inc()
+		}
+		for _, r := range user.rules {
+			if r.rolledUp || r.series == 0 {
+				continue
+			}
+			m.metrics.rulesRolledUpTotal.Inc()
+			level.Info(logger).Log("msg", "computed rollups", "user", user.userID, "rule", r.Name, "series", r.series, "rollup_series", len(r.aggregates))
+		}
+	}
+	return rolledUp, nil
+}
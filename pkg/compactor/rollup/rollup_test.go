@@ -0,0 +1,256 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compression"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	logql_log "github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	util_validation "github.com/grafana/loki/v3/pkg/util/validation"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+var schemaCfg = config.SchemaConfig{
+	Configs: []config.PeriodConfig{{From: config.DayTime{Time: 0}, Schema: "v13"}},
+}
+
+type fakeLimits struct {
+	rules map[string][]*util_validation.RollupRule
+}
+
+func (f fakeLimits) RollupRules(_ context.Context, userID string) []*util_validation.RollupRule {
+	return f.rules[userID]
+}
+
+func (f fakeLimits) AllByUserID() map[string]*validation.Limits {
+	res := map[string]*validation.Limits{}
+	for userID, rules := range f.rules {
+		res[userID] = &validation.Limits{RollupRules: rules}
+	}
+	return res
+}
+
+func (f fakeLimits) DefaultLimits() *validation.Limits {
+	return &validation.Limits{}
+}
+
+// fakeIndex is an index table whose chunks are stored by fakeChunkClient.
+type fakeIndex struct {
+	chunks []chunk.Chunk
+}
+
+func (f *fakeIndex) ForEachChunk(_ context.Context, callback retention.ChunkEntryCallback) error {
+	for _, c := range f.chunks {
+		if _, err := callback(retention.ChunkEntry{
+			ChunkRef: retention.ChunkRef{
+				UserID:   []byte(c.UserID),
+				SeriesID: []byte(fmt.Sprintf("%d", c.Fingerprint)),
+				ChunkID:  []byte(schemaCfg.ExternalKey(c.ChunkRef)),
+				From:     c.From,
+				Through:  c.Through,
+			},
+			Labels: labels.NewBuilder(c.Metric).Del(labels.MetricName).Labels(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeIndex) IndexChunk(c chunk.Chunk) (bool, error) {
+	f.chunks = append(f.chunks, c)
+	return true, nil
+}
+
+func (f *fakeIndex) CleanupSeries(_ []byte, _ labels.Labels) error {
+	return nil
+}
+
+type fakeChunkClient struct {
+	chunks map[string]chunk.Chunk
+}
+
+func (f *fakeChunkClient) Stop() {}
+
+func (f *fakeChunkClient) PutChunks(_ context.Context, chunks []chunk.Chunk) error {
+	for _, c := range chunks {
+		f.chunks[schemaCfg.ExternalKey(c.ChunkRef)] = c
+	}
+	return nil
+}
+
+func (f *fakeChunkClient) GetChunks(_ context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	res := make([]chunk.Chunk, 0, len(chunks))
+	for _, c := range chunks {
+		stored, ok := f.chunks[schemaCfg.ExternalKey(c.ChunkRef)]
+		if !ok {
+			return nil, fmt.Errorf("chunk %s not found", schemaCfg.ExternalKey(c.ChunkRef))
+		}
+		res = append(res, stored)
+	}
+	return res, nil
+}
+
+func (f *fakeChunkClient) DeleteChunk(_ context.Context, _, _ string) error { return nil }
+func (f *fakeChunkClient) IsChunkNotFoundErr(_ error) bool                  { return false }
+func (f *fakeChunkClient) IsRetryableErr(_ error) bool                      { return false }
+
+type noopMarker struct{}
+
+func (noopMarker) MarkForDelete(_ context.Context, _, _ string, _ retention.IndexProcessor, _ log.Logger) (bool, bool, error) {
+	return false, false, nil
+}
+
+func createChunk(t *testing.T, userID string, lbs labels.Labels, entries ...logproto.Entry) chunk.Chunk {
+	t.Helper()
+	c := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, compression.Snappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, blockSize, targetSize)
+	for i := range entries {
+		_, err := c.Append(&entries[i])
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.Close())
+	from, through := c.Bounds()
+	metric := labels.NewBuilder(lbs).Set(labels.MetricName, "logs").Labels()
+	chk := chunk.NewChunk(userID, model.Fingerprint(lbs.Hash()), metric, chunkenc.NewFacade(c, blockSize, targetSize), model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(through.UnixNano()))
+	require.NoError(t, chk.Encode())
+	return chk
+}
+
+// rollupValues returns the structured metadata of the rollup chunks of the index by rollup series and timestamp.
+func rollupValues(t *testing.T, index *fakeIndex) map[string]map[time.Time]string {
+	res := map[string]map[time.Time]string{}
+	for _, c := range index.chunks {
+		lbs := labels.NewBuilder(c.Metric).Del(labels.MetricName).Labels()
+		if lbs.Get("__rollup__") == "" {
+			continue
+		}
+		it, err := c.Data.(*chunkenc.Facade).LokiChunk().Iterator(context.Background(), c.From.Time(), c.Through.Time().Add(time.Millisecond), logproto.FORWARD, logql_log.NewNoopPipeline().ForStream(lbs))
+		require.NoError(t, err)
+		for it.Next() {
+			if res[lbs.String()] == nil {
+				res[lbs.String()] = map[time.Time]string{}
+			}
+			res[lbs.String()][it.At().Timestamp.UTC()] = logproto.FromLabelAdaptersToLabels(it.At().StructuredMetadata).String()
+		}
+		require.NoError(t, it.Close())
+	}
+	return res
+}
+
+func TestManager_Rollup(t *testing.T) {
+	day := model.Now().Add(-40 * 24 * time.Hour).Time().UTC().Truncate(24 * time.Hour)
+	tableName := fmt.Sprintf("index_%d", day.Unix()/86400)
+	rule := &util_validation.RollupRule{
+		Name:       "nginx",
+		Selector:   `{app="nginx"} | logfmt`,
+		By:         []string{"status"},
+		Unwrap:     "duration",
+		Resolution: model.Duration(time.Minute),
+		After:      model.Duration(30 * 24 * time.Hour),
+	}
+	limits := fakeLimits{rules: map[string][]*util_validation.RollupRule{"1": {rule}}}
+
+	entries := []logproto.Entry{
+		{Timestamp: day.Add(10 * time.Second), Line: "status=200 duration=1.5"},
+		{Timestamp: day.Add(20 * time.Second), Line: "status=500 duration=2"},
+		{Timestamp: day.Add(70 * time.Second), Line: "status=200 duration=0.5"},
+		{Timestamp: day.Add(24*time.Hour - 30*time.Second), Line: "status=200 duration=1"},
+	}
+	nginx := labels.FromStrings("app", "nginx", "pod", "a")
+	index := &fakeIndex{}
+	chunkClient := &fakeChunkClient{chunks: map[string]chunk.Chunk{}}
+	for _, c := range []chunk.Chunk{
+		createChunk(t, "1", nginx, entries...),
+		// the same lines written by another ingester are counted once.
+		createChunk(t, "1", nginx, entries[:2]...),
+		createChunk(t, "1", labels.FromStrings("app", "other"), logproto.Entry{Timestamp: day.Add(10 * time.Second), Line: "status=200 duration=1"}),
+		createChunk(t, "2", nginx, entries...),
+	} {
+		index.chunks = append(index.chunks, c)
+		require.NoError(t, chunkClient.PutChunks(context.Background(), []chunk.Chunk{c}))
+	}
+
+	m := NewManager(limits, prometheus.NewRegistry())
+	marker := m.WrapTableMarker(noopMarker{}, chunkClient)
+	interval := retention.ExtractIntervalFromTableName(tableName)
+
+	m.MarkPhaseStarted()
+	require.True(t, m.IntervalMayHaveRollups(interval, "1"))
+	require.False(t, m.IntervalMayHaveRollups(interval, "2"))
+	empty, modified, err := marker.MarkForDelete(context.Background(), tableName, "1", index, log.NewNopLogger())
+	require.NoError(t, err)
+	require.False(t, empty)
+	require.True(t, modified)
+	m.MarkPhaseFinished()
+
+	require.Equal(t, map[string]map[time.Time]string{
+		`{__rollup__="nginx", status="200"}`: {
+			day.Add(time.Minute):                     `{rollup_bytes="23", rollup_count="1", rollup_sum="1.5"}`,
+			day.Add(2 * time.Minute):                 `{rollup_bytes="23", rollup_count="1", rollup_sum="0.5"}`,
+			day.Add(24*time.Hour - time.Millisecond): `{rollup_bytes="21", rollup_count="1", rollup_sum="1"}`,
+		},
+		`{__rollup__="nginx", status="500"}`: {
+			day.Add(time.Minute): `{rollup_bytes="21", rollup_count="1", rollup_sum="2"}`,
+		},
+	}, rollupValues(t, index))
+	for _, c := range index.chunks[4:] {
+		require.Equal(t, "1", c.UserID)
+	}
+
+	// the table is not processed again once rolled up.
+	m.MarkPhaseStarted()
+	require.False(t, m.IntervalMayHaveRollups(interval, "1"))
+	m.MarkPhaseFinished()
+
+	// the rollups already in the index are not computed again after a restart.
+	indexed := len(index.chunks)
+	m = NewManager(limits, prometheus.NewRegistry())
+	marker = m.WrapTableMarker(noopMarker{}, chunkClient)
+	m.MarkPhaseStarted()
+	require.True(t, m.IntervalMayHaveRollups(interval, "1"))
+	_, modified, err = marker.MarkForDelete(context.Background(), tableName, "1", index, log.NewNopLogger())
+	require.NoError(t, err)
+	require.False(t, modified)
+	require.Len(t, index.chunks, indexed)
+}
+
+func TestManager_PhaseFailed(t *testing.T) {
+	limits := fakeLimits{rules: map[string][]*util_validation.RollupRule{"1": {{
+		Name:       "all",
+		Selector:   `{app="nginx"}`,
+		Resolution: model.Duration(time.Minute),
+		After:      model.Duration(30 * 24 * time.Hour),
+	}}}}
+	m := NewManager(limits, prometheus.NewRegistry())
+	old := retention.ExtractIntervalFromTableName(fmt.Sprintf("index_%d", model.Now().Add(-40*24*time.Hour).Unix()/86400))
+	recent := retention.ExtractIntervalFromTableName(fmt.Sprintf("index_%d", model.Now().Add(-29*24*time.Hour).Unix()/86400))
+
+	m.MarkPhaseStarted()
+	require.True(t, m.IntervalMayHaveRollups(old, "1"))
+	require.True(t, m.IntervalMayHaveRollups(old, ""))
+	require.False(t, m.IntervalMayHaveRollups(recent, "1"))
+	m.MarkPhaseFailed()
+
+	// the tables of a failed phase are processed again.
+	m.MarkPhaseStarted()
+	require.True(t, m.IntervalMayHaveRollups(old, "1"))
+	require.True(t, m.IntervalMayHaveRollups(old, ""))
+	m.MarkPhaseFinished()
+
+	m.MarkPhaseStarted()
+	require.False(t, m.IntervalMayHaveRollups(old, "1"))
+	require.False(t, m.IntervalMayHaveRollups(old, ""))
+}
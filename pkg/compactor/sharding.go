@@ -0,0 +1,272 @@
+package compactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	compactor_grpc "github.com/grafana/loki/v3/pkg/compactor/client/grpc"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
+)
+
+// ringNumTokensSharded is the number of tokens of each compactor in the ring when the compactors share the tables,
+// so that the tables are evenly distributed between them.
+const ringNumTokensSharded = 128
+
+// forwardedToLeaderHeader marks the requests forwarded to the leader, so that they are not forwarded again while
+// the compactors disagree on the leader.
+const forwardedToLeaderHeader = "X-Loki-Compactor-Forwarded"
+
+var errLeadershipChanging = errors.New("the leadership of the compactors is changing, retry the request later")
+
+func numRingTokens(cfg Config) int {
+	if cfg.ShardingEnabled {
+		return ringNumTokensSharded
+	}
+	return ringNumTokens
+}
+
+// tableSharder returns the sharder of the tables, which is nil when the compactor processes all of them.
+func (c *Compactor) tableSharder() tableSharder {
+	if !c.cfg.ShardingEnabled {
+		return nil
+	}
+	return c
+}
+
+// OwnsTable tells if this compactor processes the table.
+func (c *Compactor) OwnsTable(tableName string) (bool, error) {
+	return lokiring.IsInReplicationSet(c.ring, lokiring.TokenFor(tableName, ""), c.ringLifecycler.GetInstanceAddr())
+}
+
+// OwnsUserIndexSet tells if this compactor processes the index of the user in the table.
+func (c *Compactor) OwnsUserIndexSet(tableName, userID string) (bool, error) {
+	return lokiring.IsInReplicationSet(c.ring, lokiring.TokenFor(tableName, "/"+userID), c.ringLifecycler.GetInstanceAddr())
+}
+
+// IsLeader tells if this compactor manages the delete requests and the rehydration requests when the compactors share the tables.
+func (c *Compactor) IsLeader() bool {
+	return c.leader.Load()
+}
+
+func (c *Compactor) InstanceID() string {
+	return c.ringLifecycler.GetInstanceID()
+}
+
+// ActiveInstances returns the IDs of the healthy compactors of the ring.
+func (c *Compactor) ActiveInstances() ([]string, error) {
+	rs, err := c.ring.GetAllHealthy(ring.Write)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(rs.Instances))
+	for _, instance := range rs.Instances {
+		ids = append(ids, instance.Id)
+	}
+	return ids, nil
+}
+
+// updateLeadership checks if this compactor owns the leader token and switches the stores of the requests to
+// read-only when it doesn't, since only the leader writes them.
+func (c *Compactor) updateLeadership() {
+	leader, err := lokiring.IsInReplicationSet(c.ring, ringKeyOfLeader, c.ringLifecycler.GetInstanceAddr())
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "error asking ring for the leader of the compactors, will check again", "err", err)
+		return
+	}
+	if leader == c.leader.Load() {
+		return
+	}
+
+	if c.deleteRequestsStore != nil {
+		// the store is reloaded when this compactor becomes the leader, it is retried on the next check when it fails.
+		if err := c.deleteRequestsStore.SetReadOnly(!leader); err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to switch the delete requests store to the new leadership, will check again", "leader", leader, "err", err)
+			return
+		}
+	}
+	if c.TieringManager != nil {
+		c.TieringManager.SetFollower(!leader)
+	}
+	c.leader.Store(leader)
+
+	if leader {
+		level.Info(util_log.Logger).Log("msg", "this instance has been chosen as the leader of the compactors")
+		c.metrics.shardLeader.Set(1)
+	} else {
+		level.Info(util_log.Logger).Log("msg", "this instance is no longer the leader of the compactors")
+		c.metrics.shardLeader.Set(0)
+	}
+}
+
+// leaderAddr returns the address of the compactor owning the leader token.
+func (c *Compactor) leaderAddr() (string, error) {
+	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	rs, err := c.ring.Get(ringKeyOfLeader, ring.Write, bufDescs, bufHosts, bufZones)
+	if err != nil {
+		return "", err
+	}
+	addrs := rs.GetAddresses()
+	if len(addrs) != 1 {
+		return "", fmt.Errorf("expected a single leader of the compactors in the ring, got %d", len(addrs))
+	}
+	return addrs[0], nil
+}
+
+// getLeaderConn returns the connection to the leader, it is dialed again when the leader changes.
+func (c *Compactor) getLeaderConn() (*grpc.ClientConn, error) {
+	addr, err := c.leaderAddr()
+	if err != nil {
+		return nil, err
+	}
+	if addr == c.ringLifecycler.GetInstanceAddr() {
+		// this compactor takes the leadership on its next check of the ring.
+		return nil, errLeadershipChanging
+	}
+
+	c.leaderConnMtx.Lock()
+	defer c.leaderConnMtx.Unlock()
+
+	if c.leaderConn != nil && c.leaderConnAddr == addr {
+		return c.leaderConn, nil
+	}
+	if c.leaderConn != nil {
+		c.leaderConn.Close()
+		c.leaderConn = nil
+	}
+
+	dialOpts, err := c.cfg.LeaderClient.DialOption(grpcclient.Instrument(c.metrics.leaderRequestDuration))
+	if err != nil {
+		return nil, err
+	}
+	// nolint:staticcheck // grpc.Dial() has been deprecated; we'll address it before upgrading to gRPC 2.
+	conn, err := grpc.Dial(addr, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c.leaderConn, c.leaderConnAddr = conn, addr
+	return conn, nil
+}
+
+func (c *Compactor) closeLeaderConn() {
+	c.leaderConnMtx.Lock()
+	defer c.leaderConnMtx.Unlock()
+
+	if c.leaderConn != nil {
+		c.leaderConn.Close()
+		c.leaderConn = nil
+	}
+}
+
+// ForwardToLeader serves the requests for the delete requests and the rehydration requests on the leader when the
+// compactors share the tables, since only the leader writes them and the other compactors only keep a copy reloaded
+// periodically. The requests are forwarded over the httpgrpc endpoint of the leader.
+func (c *Compactor) ForwardToLeader(h http.Handler) http.Handler {
+	if !c.cfg.ShardingEnabled {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.IsLeader() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get(forwardedToLeaderHeader) != "" {
+			// the leader which forwarded the request lost its leadership, the client retries once the ring settles.
+			http.Error(w, errLeadershipChanging.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := c.getLeaderConn()
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to connect to the leader of the compactors", "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		r.Header.Set(forwardedToLeaderHeader, "true")
+		req, err := httpgrpc.FromHTTPRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+		if _, userCtx, err := user.ExtractOrgIDFromHTTPRequest(r); err == nil {
+			ctx = userCtx
+		}
+
+		resp, err := httpgrpc.NewHTTPClient(conn).Handle(ctx, req)
+		if err != nil {
+			// some errors carry the response of the leader.
+			var ok bool
+			resp, ok = httpgrpc.HTTPResponseFromError(err)
+			if !ok {
+				level.Error(util_log.Logger).Log("msg", "failed to forward the request to the leader of the compactors", "err", err)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if err := httpgrpc.WriteResponse(w, resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ForwardGRPCToLeader serves the delete requests and the cache generation numbers loaded by the queriers on the
+// leader when the compactors share the tables, so that they are never older than the ones served by the leader.
+func (c *Compactor) ForwardGRPCToLeader(s compactor_grpc.CompactorServer) compactor_grpc.CompactorServer {
+	if !c.cfg.ShardingEnabled {
+		return s
+	}
+	return &leaderForwardingGRPCServer{compactor: c, local: s}
+}
+
+type leaderForwardingGRPCServer struct {
+	compactor *Compactor
+	local     compactor_grpc.CompactorServer
+}
+
+func (s *leaderForwardingGRPCServer) GetDeleteRequests(ctx context.Context, req *compactor_grpc.GetDeleteRequestsRequest) (*compactor_grpc.GetDeleteRequestsResponse, error) {
+	if s.compactor.IsLeader() {
+		return s.local.GetDeleteRequests(ctx, req)
+	}
+	client, ctx, err := s.leaderClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetDeleteRequests(ctx, req)
+}
+
+func (s *leaderForwardingGRPCServer) GetCacheGenNumbers(ctx context.Context, req *compactor_grpc.GetCacheGenNumbersRequest) (*compactor_grpc.GetCacheGenNumbersResponse, error) {
+	if s.compactor.IsLeader() {
+		return s.local.GetCacheGenNumbers(ctx, req)
+	}
+	client, ctx, err := s.leaderClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetCacheGenNumbers(ctx, req)
+}
+
+// leaderClient returns the client of the leader and the context marking the request as forwarded to it.
+func (s *leaderForwardingGRPCServer) leaderClient(ctx context.Context) (compactor_grpc.CompactorClient, context.Context, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(forwardedToLeaderHeader)) > 0 {
+		return nil, nil, status.Error(codes.Unavailable, errLeadershipChanging.Error())
+	}
+	conn, err := s.compactor.getLeaderConn()
+	if err != nil {
+		return nil, nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return compactor_grpc.NewCompactorClient(conn), metadata.AppendToOutgoingContext(ctx, forwardedToLeaderHeader, "true"), nil
+}
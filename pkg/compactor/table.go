@@ -2,10 +2,12 @@ package compactor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -25,10 +27,23 @@ const (
 
 var errRetentionFileCountNotOne = fmt.Errorf("can't apply retention when index file count is not one")
 
+// errOwnershipLost is returned when the ring moved the table or the index set to another compactor while it was processed.
+var errOwnershipLost = errors.New("the index set is now owned by another compactor")
+
 type tableExpirationChecker interface {
 	IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool
 }
 
+// tableSharder tells which parts of the tables are processed by this compactor when the compactors share the tables.
+type tableSharder interface {
+	OwnsTable(tableName string) (bool, error)
+	OwnsUserIndexSet(tableName, userID string) (bool, error)
+}
+
+// userIndexSetsShardingMinTableAge is the minimum age of the end of the tables whose user index sets are shared
+// between the compactors, so that the ingesters are done uploading index to them.
+const userIndexSetsShardingMinTableAge = 24 * time.Hour
+
 type IndexCompactor interface {
 	// NewTableCompactor returns a new TableCompactor for compacting a table.
 	// commonIndexSet refers to common index files or in other words multi-tenant index.
@@ -76,12 +91,18 @@ type table struct {
 	tableMarker        retention.TableMarker
 	expirationChecker  tableExpirationChecker
 	periodConfig       config.PeriodConfig
+	// sharder is nil when the compactor processes all the tables.
+	sharder tableSharder
+	// shardedByUser is set when the index sets of the table are shared between the compactors by user.
+	shardedByUser bool
 
 	baseUserIndexSet, baseCommonIndexSet storage.IndexSet
 
 	indexSets             map[string]*indexSet
 	usersWithPerUserIndex []string
 	logger                log.Logger
+	// skipped is set when the table is owned by other compactors.
+	skipped bool
 
 	ctx context.Context
 }
@@ -89,7 +110,7 @@ type table struct {
 func newTable(ctx context.Context, workingDirectory string, indexStorageClient storage.Client,
 	indexCompactor IndexCompactor, periodConfig config.PeriodConfig,
 	tableMarker retention.TableMarker, expirationChecker tableExpirationChecker,
-	uploadConcurrency int,
+	uploadConcurrency int, sharder tableSharder,
 ) (*table, error) {
 	err := chunk_util.EnsureDirectory(workingDirectory)
 	if err != nil {
@@ -109,6 +130,7 @@ func newTable(ctx context.Context, workingDirectory string, indexStorageClient s
 		baseUserIndexSet:   storage.NewIndexSet(indexStorageClient, true),
 		baseCommonIndexSet: storage.NewIndexSet(indexStorageClient, false),
 		uploadConcurrency:  uploadConcurrency,
+		sharder:            sharder,
 	}
 	table.logger = log.With(util_log.Logger, "table-name", table.name)
 
@@ -127,6 +149,19 @@ func (t *table) compact(applyRetention bool) error {
 		return nil
 	}
 
+	if t.sharder != nil {
+		owned, ok, err := t.ownedUserIndexSets(len(indexFiles) > 0, usersWithPerUserIndex)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			level.Info(t.logger).Log("msg", "skipping table owned by another compactor")
+			t.skipped = true
+			return nil
+		}
+		usersWithPerUserIndex = owned
+	}
+
 	t.usersWithPerUserIndex = usersWithPerUserIndex
 
 	level.Info(t.logger).Log("msg", "listed files", "count", len(indexFiles))
@@ -181,7 +216,14 @@ func (t *table) compact(applyRetention bool) error {
 		}
 	}
 
-	return t.done()
+	err = t.done()
+	if errors.Is(err, errOwnershipLost) {
+		// the new owner compacts the index sets left behind, along with the compacted ones already uploaded.
+		level.Warn(t.logger).Log("msg", "stopped processing table moved to another compactor", "err", err)
+		t.skipped = true
+		return nil
+	}
+	return err
 }
 
 func (t *table) done() error {
@@ -199,14 +241,14 @@ func (t *table) done() error {
 	}
 
 	err := concurrency.ForEachJob(t.ctx, len(userIDs), t.uploadConcurrency, func(_ context.Context, idx int) error {
-		return t.indexSets[userIDs[idx]].done()
+		return t.indexSets[userIDs[idx]].done(t.ownershipChecker(userIDs[idx]))
 	})
 	if err != nil {
 		return err
 	}
 
 	if commonIndexSet, ok := t.indexSets[""]; ok {
-		if err := commonIndexSet.done(); err != nil {
+		if err := commonIndexSet.done(t.ownershipChecker("")); err != nil {
 			return err
 		}
 	}
@@ -214,6 +256,61 @@ func (t *table) done() error {
 	return nil
 }
 
+// ownedUserIndexSets returns the users whose index sets are processed by this compactor, and false when none of the table is.
+// The index sets of the old tables without common index are shared between the compactors by the hash of the table and
+// the user. The other tables are processed entirely by the compactor owning the table, since compacting the common index
+// updates the index sets of all the users.
+func (t *table) ownedUserIndexSets(hasCommonIndex bool, usersWithPerUserIndex []string) ([]string, bool, error) {
+	tableInterval := retention.ExtractIntervalFromTableName(t.name)
+	if hasCommonIndex || !tableInterval.End.Before(model.Now().Add(-userIndexSetsShardingMinTableAge)) {
+		t.shardedByUser = false
+		ownsTable, err := t.sharder.OwnsTable(t.name)
+		if err != nil {
+			return nil, false, err
+		}
+		return usersWithPerUserIndex, ownsTable, nil
+	}
+
+	t.shardedByUser = true
+	var owned []string
+	for _, userID := range usersWithPerUserIndex {
+		ownsUserIndexSet, err := t.sharder.OwnsUserIndexSet(t.name, userID)
+		if err != nil {
+			return nil, false, err
+		}
+		if ownsUserIndexSet {
+			owned = append(owned, userID)
+		}
+	}
+	return owned, len(owned) > 0, nil
+}
+
+// ownershipChecker returns the check run by the index set of the user before uploading the compacted index and
+// removing the source files, since the ring may have moved it to another compactor while it was compacted.
+// It narrows the window in which two compactors process the same index set without closing it, since the ring
+// can change right after the check.
+func (t *table) ownershipChecker(userID string) func() error {
+	if t.sharder == nil {
+		return nil
+	}
+	return func() error {
+		var owned bool
+		var err error
+		if t.shardedByUser && userID != "" {
+			owned, err = t.sharder.OwnsUserIndexSet(t.name, userID)
+		} else {
+			owned, err = t.sharder.OwnsTable(t.name)
+		}
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return errOwnershipLost
+		}
+		return nil
+	}
+}
+
 // applyRetention applies retention on the index sets
 func (t *table) applyRetention() error {
 	tableInterval := retention.ExtractIntervalFromTableName(t.name)
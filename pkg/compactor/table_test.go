@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/common/model"
@@ -199,7 +201,7 @@ func TestTable_Compaction(t *testing.T) {
 					require.NoError(t, err)
 
 					table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
-						newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10)
+						newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10, nil)
 					require.NoError(t, err)
 
 					require.NoError(t, table.compact(false))
@@ -236,7 +238,7 @@ func TestTable_Compaction(t *testing.T) {
 
 					// running compaction again should not do anything.
 					table, err = newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
-						newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10)
+						newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10, nil)
 					require.NoError(t, err)
 
 					require.NoError(t, table.compact(false))
@@ -378,7 +380,7 @@ func TestTable_CompactionRetention(t *testing.T) {
 					newTestIndexCompactor(), config.PeriodConfig{},
 					tt.tableMarker, IntervalMayHaveExpiredChunksFunc(func(_ model.Interval, _ string) bool {
 						return true
-					}), 10)
+					}), 10, nil)
 				require.NoError(t, err)
 
 				require.NoError(t, table.compact(true))
@@ -451,7 +453,7 @@ func TestTable_CompactionFailure(t *testing.T) {
 	require.NoError(t, err)
 
 	table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
-		newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10)
+		newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10, nil)
 	require.NoError(t, err)
 
 	// compaction should fail due to a non-boltdb file.
@@ -469,10 +471,138 @@ func TestTable_CompactionFailure(t *testing.T) {
 	require.NoError(t, os.Remove(filepath.Join(tablePathInStorage, "fail.gz")))
 
 	table, err = newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
-		newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10)
+		newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10, nil)
 	require.NoError(t, err)
 	require.NoError(t, table.compact(false))
 
 	// ensure that we have cleanup the local working directory after successful compaction.
 	require.NoFileExists(t, tableWorkingDirectory)
 }
+
+type fakeTableSharder struct {
+	ownsTable bool
+	users     map[string]struct{}
+}
+
+func (f fakeTableSharder) OwnsTable(_ string) (bool, error) {
+	return f.ownsTable, nil
+}
+
+func (f fakeTableSharder) OwnsUserIndexSet(_, userID string) (bool, error) {
+	_, ok := f.users[userID]
+	return ok, nil
+}
+
+func TestTable_Sharding(t *testing.T) {
+	now := model.Now()
+	for _, tc := range []struct {
+		name          string
+		tableAge      time.Duration
+		sharder       fakeTableSharder
+		expectSkipped bool
+		expectUsers   []string
+	}{
+		{
+			name:          "recent table owned by another compactor",
+			sharder:       fakeTableSharder{users: map[string]struct{}{BuildUserID(0): {}}},
+			expectSkipped: true,
+		},
+		{
+			name:        "recent table owned by this compactor",
+			sharder:     fakeTableSharder{ownsTable: true},
+			expectUsers: []string{BuildUserID(0), BuildUserID(1)},
+		},
+		{
+			name:        "old table shared by user",
+			tableAge:    72 * time.Hour,
+			sharder:     fakeTableSharder{ownsTable: true, users: map[string]struct{}{BuildUserID(0): {}}},
+			expectUsers: []string{BuildUserID(0)},
+		},
+		{
+			name:          "old table without owned users",
+			tableAge:      72 * time.Hour,
+			sharder:       fakeTableSharder{ownsTable: true},
+			expectSkipped: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			tableName := fmt.Sprintf("index_%d", now.Add(-tc.tableAge).Unix()/86400)
+			objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+			tablePathInStorage := filepath.Join(objectStoragePath, tableName)
+			tableWorkingDirectory := filepath.Join(tempDir, workingDirName, tableName)
+
+			SetupTable(t, tablePathInStorage, IndexesConfig{}, PerUserIndexesConfig{
+				IndexesConfig: IndexesConfig{NumCompactedFiles: 2},
+				NumUsers:      2,
+			})
+
+			objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
+			require.NoError(t, err)
+
+			table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+				newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10, tc.sharder)
+			require.NoError(t, err)
+			require.NoError(t, table.compact(false))
+			require.Equal(t, tc.expectSkipped, table.skipped)
+			require.ElementsMatch(t, tc.expectUsers, table.usersWithPerUserIndex)
+
+			// only the index sets owned by this compactor are compacted.
+			for i := 0; i < 2; i++ {
+				userID := BuildUserID(i)
+				files, _ := listDir(t, filepath.Join(tablePathInStorage, userID))
+				if slices.Contains(tc.expectUsers, userID) {
+					require.Len(t, files, 1)
+				} else {
+					require.Len(t, files, 2)
+				}
+			}
+		})
+	}
+}
+
+// movingTableSharder owns the table only on the first check, as if the ring moved it to another compactor while it
+// was compacted.
+type movingTableSharder struct {
+	checks int
+}
+
+func (m *movingTableSharder) OwnsTable(_ string) (bool, error) {
+	m.checks++
+	return m.checks == 1, nil
+}
+
+func (m *movingTableSharder) OwnsUserIndexSet(_, _ string) (bool, error) {
+	return false, nil
+}
+
+func TestTable_ShardingOwnershipLost(t *testing.T) {
+	tempDir := t.TempDir()
+	tableName := fmt.Sprintf("index_%d", model.Now().Unix()/86400)
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	tablePathInStorage := filepath.Join(objectStoragePath, tableName)
+	tableWorkingDirectory := filepath.Join(tempDir, workingDirName, tableName)
+
+	SetupTable(t, tablePathInStorage, IndexesConfig{}, PerUserIndexesConfig{
+		IndexesConfig: IndexesConfig{NumCompactedFiles: 2},
+		NumUsers:      2,
+	})
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
+	require.NoError(t, err)
+
+	sharder := &movingTableSharder{}
+	table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+		newTestIndexCompactor(), config.PeriodConfig{}, nil, nil, 10, sharder)
+	require.NoError(t, err)
+	require.NoError(t, table.compact(false))
+	require.True(t, table.skipped)
+	require.Greater(t, sharder.checks, 1)
+
+	// nothing is uploaded or removed by this compactor once the table has moved.
+	for i := 0; i < 2; i++ {
+		files, _ := listDir(t, filepath.Join(tablePathInStorage, BuildUserID(i)))
+		require.Len(t, files, 2)
+	}
+	require.NoFileExists(t, tableWorkingDirectory)
+}
@@ -0,0 +1,70 @@
+package tiering
+
+import (
+	"context"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+)
+
+// chunkClient gives the compactor access to the chunks of both tiers: the chunks not found in the standard tier are read
+// from the cold store, and the chunks are deleted from both.
+type chunkClient struct {
+	standard, cold client.Client
+}
+
+// NewChunkClient returns a client reading the chunks from the cold store when they are not found in standard,
+// writing them to standard and deleting them from both.
+func NewChunkClient(standard, cold client.Client) client.Client {
+	return &chunkClient{standard: standard, cold: cold}
+}
+
+func (c *chunkClient) Stop() {
+	c.standard.Stop()
+}
+
+func (c *chunkClient) PutChunks(ctx context.Context, chunks []chunk.Chunk) error {
+	return c.standard.PutChunks(ctx, chunks)
+}
+
+func (c *chunkClient) GetChunks(ctx context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	res, err := c.standard.GetChunks(ctx, chunks)
+	if err == nil || !c.standard.IsChunkNotFoundErr(err) {
+		return res, err
+	}
+
+	// fetch the chunks one by one to find the ones missing from the standard tier.
+	res = make([]chunk.Chunk, 0, len(chunks))
+	for _, chk := range chunks {
+		fetched, err := c.standard.GetChunks(ctx, []chunk.Chunk{chk})
+		if err != nil && c.standard.IsChunkNotFoundErr(err) {
+			fetched, err = c.cold.GetChunks(ctx, []chunk.Chunk{chk})
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, fetched...)
+	}
+	return res, nil
+}
+
+// DeleteChunk deletes the chunk from both tiers. It returns a not found error only when the chunk is in none of them.
+func (c *chunkClient) DeleteChunk(ctx context.Context, userID, chunkID string) error {
+	standardErr := c.standard.DeleteChunk(ctx, userID, chunkID)
+	if standardErr != nil && !c.standard.IsChunkNotFoundErr(standardErr) {
+		return standardErr
+	}
+	coldErr := c.cold.DeleteChunk(ctx, userID, chunkID)
+	if coldErr != nil && c.cold.IsChunkNotFoundErr(coldErr) && standardErr == nil {
+		return nil
+	}
+	return coldErr
+}
+
+func (c *chunkClient) IsChunkNotFoundErr(err error) bool {
+	return c.standard.IsChunkNotFoundErr(err) || c.cold.IsChunkNotFoundErr(err)
+}
+
+func (c *chunkClient) IsRetryableErr(err error) bool {
+	return c.standard.IsRetryableErr(err) || c.cold.IsRetryableErr(err)
+}
@@ -0,0 +1,46 @@
+package tiering
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/grafana/loki/v3/pkg/storage/config"
+)
+
+type Config struct {
+	ColdStore          string        `yaml:"cold_store"`
+	ColdStoreKeyPrefix string        `yaml:"cold_store_key_prefix"`
+	RehydrationPeriod  time.Duration `yaml:"rehydration_period"`
+	TSDBFormatV4       bool          `yaml:"tsdb_format_v4_enabled"`
+}
+
+// RegisterFlagsWithPrefix registers flags.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.ColdStore, prefix+"cold-store", "", "Store the chunks older than the cold_tier_after limit of their tenant are moved to by the compactor. The index of the moved chunks records their tier, and queries touching them fail until they are rehydrated with the /loki/api/v1/rehydrate endpoint. Tiering is disabled when empty. Requires retention to be enabled.")
+	f.StringVar(&cfg.ColdStoreKeyPrefix, prefix+"cold-store.key-prefix", "cold/", "Path prefix of the chunks and the rehydration requests in the cold store.")
+	f.DurationVar(&cfg.RehydrationPeriod, prefix+"rehydration-period", 72*time.Hour, "Default time the chunks of a rehydration request stay in the standard tier once rehydrated, before they are moved back to the cold store.")
+	f.BoolVar(&cfg.TSDBFormatV4, prefix+"tsdb-format-v4-enabled", false, "Write the TSDB indexes compacted by the compactor with the version 4 of the format, which records the storage tier of the chunks. Required by the cold store. Older Loki versions can't read this format: enable it only once all the queriers, index gateways and rulers are upgraded.")
+}
+
+func (cfg *Config) Enabled() bool {
+	return cfg.ColdStore != ""
+}
+
+// Validate verifies the config does not contain inappropriate values
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if !cfg.TSDBFormatV4 {
+		return errors.New("the cold store requires the version 4 of the TSDB format, set tsdb_format_v4_enabled once all the readers of the index are upgraded")
+	}
+	if err := config.ValidatePathPrefix(cfg.ColdStoreKeyPrefix); err != nil {
+		return fmt.Errorf("validate cold store path prefix: %w", err)
+	}
+	if cfg.RehydrationPeriod <= 0 {
+		return errors.New("rehydration period must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package tiering
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+type metrics struct {
+	chunksArchivedTotal      prometheus.Counter
+	chunksRehydratedTotal    prometheus.Counter
+	rehydrationRequestsTotal *prometheus.CounterVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		chunksArchivedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "compactor_tiering_chunks_archived_total",
+			Help:      "Total number of chunks moved to the cold store by the compactor.",
+		}),
+		chunksRehydratedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "compactor_tiering_chunks_rehydrated_total",
+			Help:      "Total number of chunks copied back from the cold store to the standard tier by the compactor.",
+		}),
+		rehydrationRequestsTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "compactor_tiering_rehydration_requests_received_total",
+			Help:      "Number of rehydration requests received per user.",
+		}, []string{"user"}),
+	}
+}
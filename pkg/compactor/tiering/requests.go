@@ -0,0 +1,223 @@
+package tiering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+// requestsObjectKey is the key of the rehydration requests in the cold store.
+const requestsObjectKey = "rehydration_requests.json"
+
+type RequestStatus string
+
+const (
+	StatusReceived  RequestStatus = "received"
+	StatusProcessed RequestStatus = "processed"
+)
+
+// Request asks for the archived chunks of the streams matching Query between StartTime and EndTime to be copied back
+// to the standard tier. The chunks stay in the standard tier for Period once the request is processed.
+type Request struct {
+	RequestID string         `json:"request_id"`
+	Query     string         `json:"query"`
+	StartTime model.Time     `json:"start_time"`
+	EndTime   model.Time     `json:"end_time"`
+	Period    model.Duration `json:"period"`
+	Status    RequestStatus  `json:"status"`
+	CreatedAt model.Time     `json:"created_at"`
+	// ExpiresAt is set once the request is processed.
+	ExpiresAt model.Time `json:"expires_at,omitempty"`
+
+	UserID   string            `json:"user_id"`
+	matchers []*labels.Matcher `json:"-"`
+}
+
+func (r *Request) parseQuery() error {
+	matchers, err := syntax.ParseMatchers(r.Query, true)
+	if err != nil {
+		return err
+	}
+	r.matchers = matchers
+	return nil
+}
+
+// active tells if the chunks of the request have to be in the standard tier during a retention phase started at now.
+func (r *Request) active(now model.Time) bool {
+	return r.Status == StatusReceived || r.ExpiresAt.After(now)
+}
+
+func (r *Request) overlaps(from, through model.Time) bool {
+	return r.StartTime <= through && from <= r.EndTime
+}
+
+func (r *Request) matches(userID string, from, through model.Time, lbs labels.Labels) bool {
+	if r.UserID != userID || !r.overlaps(from, through) {
+		return false
+	}
+	for _, m := range r.matchers {
+		if !m.Matches(lbs.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadRequests reads the rehydration requests from the cold store.
+func (m *Manager) loadRequests(ctx context.Context) error {
+	reader, _, err := m.coldObjectClient.GetObject(ctx, requestsObjectKey)
+	if err != nil {
+		if m.coldObjectClient.IsObjectNotFoundErr(err) {
+			return nil
+		}
+		return err
+	}
+	defer reader.Close()
+
+	var requests []*Request
+	if err := json.NewDecoder(reader).Decode(&requests); err != nil {
+		return fmt.Errorf("failed to decode rehydration requests: %w", err)
+	}
+	for _, r := range requests {
+		if err := r.parseQuery(); err != nil {
+			return fmt.Errorf("invalid query of rehydration request %s: %w", r.RequestID, err)
+		}
+	}
+	m.requests = requests
+	return nil
+}
+
+// saveRequests writes the rehydration requests to the cold store. It must be called with mtx held.
+func (m *Manager) saveRequests(ctx context.Context, requests []*Request) error {
+	data, err := json.Marshal(requests)
+	if err != nil {
+		return err
+	}
+	return m.coldObjectClient.PutObject(ctx, requestsObjectKey, bytes.NewReader(data))
+}
+
+// AddRehydrationRequestHandler handles the addition of a rehydration request.
+func (m *Manager) AddRehydrationRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := m.parseRequest(userID, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mtx.Lock()
+	requests := append(append(make([]*Request, 0, len(m.requests)+1), m.requests...), req)
+	if err := m.saveRequests(ctx, requests); err != nil {
+		m.mtx.Unlock()
+		level.Error(util_log.Logger).Log("msg", "error adding rehydration request to the store", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m.requests = requests
+	m.mtx.Unlock()
+
+	level.Info(util_log.Logger).Log(
+		"msg", "rehydration request for user added",
+		"request_id", req.RequestID,
+		"user", userID,
+		"query", req.Query,
+		"period", req.Period.String(),
+	)
+	m.metrics.rehydrationRequestsTotal.WithLabelValues(userID).Inc()
+	util.WriteJSONResponse(w, req)
+}
+
+func (m *Manager) parseRequest(userID string, r *http.Request) (*Request, error) {
+	params := r.Form
+	req := &Request{
+		UserID:    userID,
+		Query:     params.Get("query"),
+		Period:    model.Duration(m.rehydrationPeriod),
+		Status:    StatusReceived,
+		CreatedAt: model.Now(),
+	}
+	if req.Query == "" {
+		return nil, errors.New("query not set")
+	}
+	if err := req.parseQuery(); err != nil {
+		return nil, err
+	}
+
+	if params.Get("start") == "" {
+		return nil, errors.New("start time not set")
+	}
+	start, err := util.ParseTime(params.Get("start"))
+	if err != nil {
+		return nil, errors.New("invalid start time: require unix seconds or RFC3339 format")
+	}
+	req.StartTime = model.Time(start)
+
+	req.EndTime = req.CreatedAt
+	if end := params.Get("end"); end != "" {
+		t, err := util.ParseTime(end)
+		if err != nil {
+			return nil, errors.New("invalid end time: require unix seconds or RFC3339 format")
+		}
+		req.EndTime = model.Time(t)
+	}
+	if req.StartTime > req.EndTime {
+		return nil, errors.New("start time can't be greater than end time")
+	}
+
+	if period := params.Get("period"); period != "" {
+		d, err := model.ParseDuration(period)
+		if err != nil {
+			return nil, fmt.Errorf("invalid period: %w", err)
+		}
+		if d <= 0 {
+			return nil, errors.New("period must be positive")
+		}
+		req.Period = d
+	}
+
+	req.RequestID = fmt.Sprintf("%016x", xxhash.Sum64String(fmt.Sprintf("%s/%s/%d/%d/%d", userID, req.Query, req.StartTime, req.EndTime, req.CreatedAt)))
+	return req, nil
+}
+
+// GetRehydrationRequestsHandler handles the listing of the rehydration requests of a user.
+func (m *Manager) GetRehydrationRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mtx.Lock()
+	requests := make([]Request, 0, len(m.requests))
+	for _, req := range m.requests {
+		if req.UserID == userID {
+			requests = append(requests, *req)
+		}
+	}
+	m.mtx.Unlock()
+
+	util.WriteJSONResponse(w, requests)
+}
@@ -0,0 +1,356 @@
+package tiering
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// copyBatchSize is the number of chunks copied between the tiers at once.
+const copyBatchSize = 50
+
+type Limits interface {
+	ColdTierAfter(userID string) time.Duration
+	AllByUserID() map[string]*validation.Limits
+	DefaultLimits() *validation.Limits
+}
+
+// chunkTierSetter is implemented by the index processors of the indexes recording the storage tier of the chunks.
+type chunkTierSetter interface {
+	SetChunkTier(ref retention.ChunkEntry, tier tsdbindex.Tier) error
+}
+
+// Manager moves the chunks between the standard tier and the cold store while the retention processes the index tables.
+// The chunks older than the cold_tier_after limit of their tenant are copied to the cold store and their tier is
+// recorded in the index, which lets the queries touching them fail fast. Their copy in the standard tier is deleted by
+// a sweeper once the new index is uploaded, after the retention delete delay.
+// The archived chunks matching a rehydration request are copied back to the standard tier until the request expires.
+type Manager struct {
+	limits            Limits
+	coldObjectClient  client.ObjectClient
+	coldChunkClient   client.Client
+	rehydrationPeriod time.Duration
+	metrics           *metrics
+
+	mtx        sync.Mutex
+	phaseStart model.Time
+	requests   []*Request
+	// phaseRequests are the requests applied by the running retention phase.
+	phaseRequests []*Request
+	// follower is set when another compactor manages the rehydration requests.
+	follower bool
+}
+
+func NewManager(limits Limits, coldObjectClient client.ObjectClient, coldChunkClient client.Client, rehydrationPeriod time.Duration, r prometheus.Registerer) (*Manager, error) {
+	m := &Manager{
+		limits:            limits,
+		coldObjectClient:  coldObjectClient,
+		coldChunkClient:   coldChunkClient,
+		rehydrationPeriod: rehydrationPeriod,
+		metrics:           newMetrics(r),
+	}
+	if err := m.loadRequests(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load rehydration requests: %w", err)
+	}
+	return m, nil
+}
+
+// ColdChunkClient returns the client of the chunks in the cold store.
+func (m *Manager) ColdChunkClient() client.Client {
+	return m.coldChunkClient
+}
+
+// SetFollower sets whether another compactor manages the rehydration requests, in which case they are reloaded from
+// the cold store by each retention phase instead of being updated.
+func (m *Manager) SetFollower(follower bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.follower = follower
+}
+
+func (m *Manager) MarkPhaseStarted() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.follower {
+		if err := m.loadRequests(context.Background()); err != nil {
+			level.Error(util_log.Logger).Log("msg", "failed to reload rehydration requests", "err", err)
+		}
+	}
+	m.phaseStart = model.Now()
+	m.phaseRequests = m.requests
+}
+
+func (m *Manager) MarkPhaseFailed() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.phaseRequests = nil
+}
+
+// MarkPhaseFinished marks the requests received before the phase as processed and removes the requests which expired
+// before the phase, whose chunks were archived again.
+func (m *Manager) MarkPhaseFinished() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	applied := make(map[string]struct{}, len(m.phaseRequests))
+	for _, req := range m.phaseRequests {
+		applied[req.RequestID] = struct{}{}
+	}
+	m.phaseRequests = nil
+	if m.follower {
+		return
+	}
+
+	now := model.Now()
+	requests := make([]*Request, 0, len(m.requests))
+	changed := false
+	for _, req := range m.requests {
+		if _, ok := applied[req.RequestID]; !ok {
+			requests = append(requests, req)
+			continue
+		}
+		switch {
+		case req.Status == StatusReceived:
+			processed := *req
+			processed.Status = StatusProcessed
+			processed.ExpiresAt = now.Add(time.Duration(req.Period))
+			requests = append(requests, &processed)
+			changed = true
+		case req.active(m.phaseStart):
+			requests = append(requests, req)
+		default:
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// the requests are applied again by the next phase when they can't be saved.
+	if err := m.saveRequests(context.Background(), requests); err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to save rehydration requests", "err", err)
+		return
+	}
+	m.requests = requests
+}
+
+// IntervalMayHaveChunksToMove tells if the index of the table of interval for userID may have chunks to move between
+// the tiers. When userID is empty, it checks the common index of the table with the limits of all the users.
+func (m *Manager) IntervalMayHaveChunksToMove(interval model.Interval, userID string) bool {
+	m.mtx.Lock()
+	phaseStart, requests := m.phaseStart, m.phaseRequests
+	m.mtx.Unlock()
+
+	for _, req := range requests {
+		if userID != "" && req.UserID != userID {
+			continue
+		}
+		// the chunks of the expired requests are archived again.
+		if req.overlaps(interval.Start, interval.End) && (req.Status == StatusReceived || !req.active(phaseStart)) {
+			return true
+		}
+	}
+
+	var after time.Duration
+	if userID == "" {
+		after = m.minColdTierAfter()
+	} else {
+		after = m.limits.ColdTierAfter(userID)
+	}
+	return after > 0 && interval.Start.Before(phaseStart.Add(-after))
+}
+
+// minColdTierAfter returns the smallest cold_tier_after of the default limits and of the users with overrides.
+func (m *Manager) minColdTierAfter() time.Duration {
+	var after time.Duration
+	update := func(limits *validation.Limits) {
+		if limits == nil || limits.ColdTierAfter <= 0 {
+			return
+		}
+		if after == 0 || time.Duration(limits.ColdTierAfter) < after {
+			after = time.Duration(limits.ColdTierAfter)
+		}
+	}
+	update(m.limits.DefaultLimits())
+	for _, limits := range m.limits.AllByUserID() {
+		update(limits)
+	}
+	return after
+}
+
+// WrapTableMarker returns a TableMarker moving the chunks of the tables between the tiers before marking their chunks
+// with next. chunkClient is the client of the standard tier, and the copy of the archived chunks in the standard tier is
+// marked for deletion in workingDir.
+func (m *Manager) WrapTableMarker(next retention.TableMarker, chunkClient client.Client, workingDir string) retention.TableMarker {
+	return &tableMarker{
+		manager:     m,
+		next:        next,
+		chunkClient: chunkClient,
+		readClient:  NewChunkClient(chunkClient, m.coldChunkClient),
+		workingDir:  workingDir,
+	}
+}
+
+type tableMarker struct {
+	manager     *Manager
+	next        retention.TableMarker
+	chunkClient client.Client
+	// readClient reads the chunks to archive from the cold store when their copy in the standard tier was already deleted.
+	readClient client.Client
+	workingDir string
+}
+
+func (t *tableMarker) MarkForDelete(ctx context.Context, tableName, userID string, indexProcessor retention.IndexProcessor, logger log.Logger) (bool, bool, error) {
+	setter, ok := indexProcessor.(chunkTierSetter)
+	if !ok {
+		return t.next.MarkForDelete(ctx, tableName, userID, indexProcessor, logger)
+	}
+
+	archived, rehydrated, err := t.moveChunks(ctx, indexProcessor, setter)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to move chunks between storage tiers: %w", err)
+	}
+
+	empty, modified, err := t.next.MarkForDelete(ctx, tableName, userID, indexProcessor, logger)
+	if err != nil {
+		return false, false, err
+	}
+
+	if len(archived) > 0 {
+		if err := t.markForDelete(archived); err != nil {
+			return false, false, fmt.Errorf("failed to mark archived chunks for deletion: %w", err)
+		}
+		level.Info(logger).Log("msg", "archived chunks to the cold store", "chunks", len(archived))
+	}
+	if rehydrated > 0 {
+		level.Info(logger).Log("msg", "rehydrated chunks from the cold store", "chunks", rehydrated)
+	}
+	return empty, modified || len(archived) > 0 || rehydrated > 0, nil
+}
+
+// moveChunks copies the chunks of the table to their tier and lines up the change of their tier in the index.
+// It returns the archived chunks, whose copy in the standard tier has to be deleted, and the number of rehydrated chunks.
+func (t *tableMarker) moveChunks(ctx context.Context, indexProcessor retention.IndexProcessor, setter chunkTierSetter) ([]retention.ChunkEntry, int, error) {
+	m := t.manager
+	m.mtx.Lock()
+	now, requests := m.phaseStart, m.phaseRequests
+	m.mtx.Unlock()
+
+	coldTierAfter := map[string]time.Duration{}
+	var archive, rehydrate []retention.ChunkEntry
+	err := indexProcessor.ForEachChunk(ctx, func(c retention.ChunkEntry) (bool, error) {
+		userID := string(c.UserID)
+		rehydrating := false
+		for _, req := range requests {
+			if req.active(now) && req.matches(userID, c.From, c.Through, c.Labels) {
+				rehydrating = true
+				break
+			}
+		}
+
+		switch {
+		case c.Tier == tsdbindex.TierCold && rehydrating:
+			rehydrate = append(rehydrate, copyEntry(c))
+		case c.Tier == tsdbindex.TierStandard && !rehydrating:
+			after, ok := coldTierAfter[userID]
+			if !ok {
+				after = m.limits.ColdTierAfter(userID)
+				coldTierAfter[userID] = after
+			}
+			if after > 0 && c.Through.Before(now.Add(-after)) {
+				archive = append(archive, copyEntry(c))
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := copyChunks(ctx, archive, t.readClient, m.coldChunkClient); err != nil {
+		return nil, 0, err
+	}
+	if err := copyChunks(ctx, rehydrate, m.coldChunkClient, t.chunkClient); err != nil {
+		return nil, 0, err
+	}
+
+	for _, c := range archive {
+		if err := setter.SetChunkTier(c, tsdbindex.TierCold); err != nil {
+			return nil, 0, err
+		}
+	}
+	for _, c := range rehydrate {
+		if err := setter.SetChunkTier(c, tsdbindex.TierStandard); err != nil {
+			return nil, 0, err
+		}
+	}
+	m.metrics.chunksArchivedTotal.Add(float64(len(archive)))
+	m.metrics.chunksRehydratedTotal.Add(float64(len(rehydrate)))
+	return archive, len(rehydrate), nil
+}
+
+// markForDelete marks the copy of the archived chunks in the standard tier for deletion by the sweeper of workingDir.
+func (t *tableMarker) markForDelete(chunks []retention.ChunkEntry) error {
+	writer, err := retention.NewMarkerStorageWriter(t.workingDir)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := writer.Put(c.ChunkID); err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+func copyChunks(ctx context.Context, chunks []retention.ChunkEntry, from, to client.Client) error {
+	for start := 0; start < len(chunks); start += copyBatchSize {
+		batch := chunks[start:min(start+copyBatchSize, len(chunks))]
+		keys := make([]chunk.Chunk, 0, len(batch))
+		for _, c := range batch {
+			key, err := chunk.ParseExternalKey(string(c.UserID), string(c.ChunkID))
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		chks, err := from.GetChunks(ctx, keys)
+		if err != nil {
+			return err
+		}
+		if err := to.PutChunks(ctx, chks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyEntry copies the reference of the chunk since the entries of ForEachChunk are only valid during the callback.
+func copyEntry(c retention.ChunkEntry) retention.ChunkEntry {
+	return retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{
+			UserID:   slices.Clone(c.UserID),
+			SeriesID: slices.Clone(c.SeriesID),
+			ChunkID:  slices.Clone(c.ChunkID),
+			From:     c.From,
+			Through:  c.Through,
+		},
+		Tier: c.Tier,
+	}
+}
@@ -0,0 +1,236 @@
+package tiering
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compression"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+var schemaCfg = config.SchemaConfig{
+	Configs: []config.PeriodConfig{{From: config.DayTime{Time: 0}, Schema: "v13"}},
+}
+
+type fakeLimits struct {
+	coldTierAfter map[string]time.Duration
+}
+
+func (f fakeLimits) ColdTierAfter(userID string) time.Duration {
+	return f.coldTierAfter[userID]
+}
+
+func (f fakeLimits) AllByUserID() map[string]*validation.Limits {
+	res := map[string]*validation.Limits{}
+	for userID, after := range f.coldTierAfter {
+		res[userID] = &validation.Limits{ColdTierAfter: model.Duration(after)}
+	}
+	return res
+}
+
+func (f fakeLimits) DefaultLimits() *validation.Limits {
+	return &validation.Limits{}
+}
+
+// fakeIndex is an index table recording the tier of its chunks.
+type fakeIndex struct {
+	chunks []chunk.Chunk
+	tiers  map[string]tsdbindex.Tier
+}
+
+func (f *fakeIndex) ForEachChunk(_ context.Context, callback retention.ChunkEntryCallback) error {
+	for _, c := range f.chunks {
+		if _, err := callback(retention.ChunkEntry{
+			ChunkRef: retention.ChunkRef{
+				UserID:   []byte(c.UserID),
+				SeriesID: []byte(fmt.Sprintf("%d", c.Fingerprint)),
+				ChunkID:  []byte(schemaCfg.ExternalKey(c.ChunkRef)),
+				From:     c.From,
+				Through:  c.Through,
+			},
+			Labels: labels.NewBuilder(c.Metric).Del(labels.MetricName).Labels(),
+			Tier:   f.tiers[schemaCfg.ExternalKey(c.ChunkRef)],
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeIndex) IndexChunk(_ chunk.Chunk) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeIndex) CleanupSeries(_ []byte, _ labels.Labels) error {
+	return nil
+}
+
+func (f *fakeIndex) SetChunkTier(ref retention.ChunkEntry, tier tsdbindex.Tier) error {
+	f.tiers[string(ref.ChunkID)] = tier
+	return nil
+}
+
+type noopMarker struct{}
+
+func (noopMarker) MarkForDelete(_ context.Context, _, _ string, _ retention.IndexProcessor, _ log.Logger) (bool, bool, error) {
+	return false, false, nil
+}
+
+func createChunk(t *testing.T, userID string, lbs labels.Labels, ts time.Time) chunk.Chunk {
+	t.Helper()
+	c := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, compression.Snappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, 256*1024, 1500*1024)
+	_, err := c.Append(&logproto.Entry{Timestamp: ts, Line: "line"})
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+	metric := labels.NewBuilder(lbs).Set(labels.MetricName, "logs").Labels()
+	chk := chunk.NewChunk(userID, model.Fingerprint(lbs.Hash()), metric, chunkenc.NewFacade(c, 256*1024, 1500*1024), model.TimeFromUnixNano(ts.UnixNano()), model.TimeFromUnixNano(ts.UnixNano()))
+	require.NoError(t, chk.Encode())
+	return chk
+}
+
+func newFSClients(t *testing.T) (client.ObjectClient, client.Client) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	return objectClient, client.NewClient(objectClient, client.FSEncoder, schemaCfg)
+}
+
+func hasChunk(t *testing.T, chunkClient client.Client, c chunk.Chunk) bool {
+	_, err := chunkClient.GetChunks(context.Background(), []chunk.Chunk{c})
+	if err != nil {
+		require.True(t, chunkClient.IsChunkNotFoundErr(err), err)
+		return false
+	}
+	return true
+}
+
+func runPhase(t *testing.T, m *Manager, marker retention.TableMarker, tableName string, index *fakeIndex) bool {
+	m.MarkPhaseStarted()
+	_, modified, err := marker.MarkForDelete(context.Background(), tableName, "", index, log.NewNopLogger())
+	require.NoError(t, err)
+	m.MarkPhaseFinished()
+	return modified
+}
+
+func TestManager_Tiering(t *testing.T) {
+	day := model.Now().Add(-70 * 24 * time.Hour).Time().UTC().Truncate(24 * time.Hour)
+	tableName := fmt.Sprintf("index_%d", day.Unix()/86400)
+	limits := fakeLimits{coldTierAfter: map[string]time.Duration{"1": 60 * 24 * time.Hour}}
+
+	_, hot := newFSClients(t)
+	coldObjectClient, cold := newFSClients(t)
+	archived := createChunk(t, "1", labels.FromStrings("app", "foo"), day.Add(time.Hour))
+	other := createChunk(t, "1", labels.FromStrings("app", "bar"), day.Add(time.Hour))
+	notTiered := createChunk(t, "2", labels.FromStrings("app", "foo"), day.Add(time.Hour))
+	index := &fakeIndex{chunks: []chunk.Chunk{archived, other, notTiered}, tiers: map[string]tsdbindex.Tier{}}
+	require.NoError(t, hot.PutChunks(context.Background(), index.chunks))
+
+	m, err := NewManager(limits, coldObjectClient, cold, time.Hour, prometheus.NewRegistry())
+	require.NoError(t, err)
+	workDir := t.TempDir()
+	marker := m.WrapTableMarker(noopMarker{}, hot, workDir)
+	interval := retention.ExtractIntervalFromTableName(tableName)
+
+	m.MarkPhaseStarted()
+	require.True(t, m.IntervalMayHaveChunksToMove(interval, "1"))
+	require.True(t, m.IntervalMayHaveChunksToMove(interval, ""))
+	require.False(t, m.IntervalMayHaveChunksToMove(interval, "2"))
+	m.MarkPhaseFinished()
+
+	// the chunks of user 1 are archived and their copy in the standard tier is marked for deletion.
+	require.True(t, runPhase(t, m, marker, tableName, index))
+	require.Equal(t, map[string]tsdbindex.Tier{
+		schemaCfg.ExternalKey(archived.ChunkRef): tsdbindex.TierCold,
+		schemaCfg.ExternalKey(other.ChunkRef):    tsdbindex.TierCold,
+	}, index.tiers)
+	require.True(t, hasChunk(t, cold, archived))
+	require.True(t, hasChunk(t, cold, other))
+	require.False(t, hasChunk(t, cold, notTiered))
+	markers, err := os.ReadDir(filepath.Join(workDir, retention.MarkersFolder))
+	require.NoError(t, err)
+	require.Len(t, markers, 1)
+
+	// simulate the sweeper.
+	require.NoError(t, hot.DeleteChunk(context.Background(), "1", schemaCfg.ExternalKey(archived.ChunkRef)))
+	require.NoError(t, hot.DeleteChunk(context.Background(), "1", schemaCfg.ExternalKey(other.ChunkRef)))
+	require.False(t, runPhase(t, m, marker, tableName, index))
+
+	// a rehydration request copies back the matching chunks to the standard tier.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/rehydrate", strings.NewReader(url.Values{
+		"query":  []string{`{app="foo"}`},
+		"start":  []string{fmt.Sprint(day.Unix())},
+		"end":    []string{fmt.Sprint(day.Add(24 * time.Hour).Unix())},
+		"period": []string{"1ms"},
+	}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m.AddRehydrationRequestHandler(rec, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	require.True(t, runPhase(t, m, marker, tableName, index))
+	require.Equal(t, tsdbindex.TierStandard, index.tiers[schemaCfg.ExternalKey(archived.ChunkRef)])
+	require.Equal(t, tsdbindex.TierCold, index.tiers[schemaCfg.ExternalKey(other.ChunkRef)])
+	require.True(t, hasChunk(t, hot, archived))
+	require.False(t, hasChunk(t, hot, other))
+
+	// the requests are persisted in the cold store.
+	m, err = NewManager(limits, coldObjectClient, cold, time.Hour, prometheus.NewRegistry())
+	require.NoError(t, err)
+	marker = m.WrapTableMarker(noopMarker{}, hot, workDir)
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/loki/api/v1/rehydrate", nil)
+	m.GetRehydrationRequestsHandler(rec, req.WithContext(user.InjectOrgID(req.Context(), "1")))
+	var requests []Request
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &requests))
+	require.Len(t, requests, 1)
+	require.Equal(t, StatusProcessed, requests[0].Status)
+
+	// the chunks are archived again once the request expired, and the request is removed.
+	time.Sleep(10 * time.Millisecond)
+	require.True(t, runPhase(t, m, marker, tableName, index))
+	require.Equal(t, tsdbindex.TierCold, index.tiers[schemaCfg.ExternalKey(archived.ChunkRef)])
+	require.Empty(t, m.requests)
+}
+
+func TestChunkClient(t *testing.T) {
+	_, standard := newFSClients(t)
+	_, cold := newFSClients(t)
+	ts := time.Now().UTC()
+	hotChunk := createChunk(t, "1", labels.FromStrings("app", "hot"), ts)
+	coldChunk := createChunk(t, "1", labels.FromStrings("app", "cold"), ts)
+	require.NoError(t, standard.PutChunks(context.Background(), []chunk.Chunk{hotChunk}))
+	require.NoError(t, cold.PutChunks(context.Background(), []chunk.Chunk{coldChunk}))
+
+	c := NewChunkClient(standard, cold)
+	chks, err := c.GetChunks(context.Background(), []chunk.Chunk{hotChunk, coldChunk})
+	require.NoError(t, err)
+	require.Len(t, chks, 2)
+
+	require.NoError(t, c.DeleteChunk(context.Background(), "1", schemaCfg.ExternalKey(coldChunk.ChunkRef)))
+	require.False(t, hasChunk(t, cold, coldChunk))
+	err = c.DeleteChunk(context.Background(), "1", schemaCfg.ExternalKey(coldChunk.ChunkRef))
+	require.True(t, c.IsChunkNotFoundErr(err))
+}
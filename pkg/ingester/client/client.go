@@ -8,6 +8,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/util/server"
 
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/middleware"
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
@@ -36,6 +37,7 @@ type ClosableHealthAndIngesterClient struct {
 	logproto.QuerierClient
 	logproto.StreamDataClient
 	grpc_health_v1.HealthClient
+	httpgrpc.HTTPClient
 	io.Closer
 }
 
@@ -85,6 +87,7 @@ func New(cfg Config, addr string) (HealthAndIngesterClient, error) {
 		QuerierClient:    logproto.NewQuerierClient(conn),
 		StreamDataClient: logproto.NewStreamDataClient(conn),
 		HealthClient:     grpc_health_v1.NewHealthClient(conn),
+		HTTPClient:       httpgrpc.NewHTTPClient(conn),
 		Closer:           conn,
 	}, nil
 }
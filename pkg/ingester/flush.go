@@ -80,6 +80,27 @@ func (f *flushReasonCounter) Log() []interface{} {
 	return log
 }
 
+// Map returns the non-zero counts keyed by flush reason.
+func (f *flushReasonCounter) Map() map[string]int {
+	reasons := map[string]int{}
+	for reason, n := range map[string]int{
+		flushReasonIdle:     f.flushReasonIdle,
+		flushReasonMaxAge:   f.flushReasonMaxAge,
+		flushReasonForced:   f.flushReasonForced,
+		flushReasonNotOwned: f.flushReasonNotOwned,
+		flushReasonFull:     f.flushReasonFull,
+		flushReasonSynced:   f.flushReasonSynced,
+	} {
+		if n > 0 {
+			reasons[reason] = n
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return reasons
+}
+
 func (f *flushReasonCounter) IncrementForReason(reason string) error {
 	switch reason {
 	case flushReasonIdle:
@@ -307,10 +328,21 @@ func (i *Ingester) flushUserSeries(ctx context.Context, userID string, fp model.
 	ctx, cancelFunc := context.WithTimeout(ctx, i.cfg.FlushOpTimeout)
 	defer cancelFunc()
 	err := i.flushChunks(ctx, fp, labels, chunks, chunkMtx)
+	stream, ok := instance.streams.LoadByFP(fp)
+	if ok {
+		// Chunks flushed before an error are released from the tenant memory too.
+		stream.chunkMtx.Lock()
+		instance.updateMemoryBytes(stream)
+		stream.chunkMtx.Unlock()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to flush chunks: %w, num_chunks: %d, labels: %s", err, len(chunks), lbs)
 	}
 
+	if ok {
+		stream.recordFlush(chunks)
+	}
+
 	return nil
 }
 
@@ -445,6 +477,20 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 			return err
 		}
 
+		dedupeOutcome := ""
+		if i.cfg.FlushDedupe.Enabled {
+			dedupeOutcome = i.dedupeFlush(ctx, &ch, c, labelPairs.String(), chunkMtx)
+			i.metrics.chunksFlushDedupe.WithLabelValues(dedupeOutcome).Inc()
+			switch dedupeOutcome {
+			case flushDedupeSkipped:
+				i.markChunkAsFlushed(cs[j], chunkMtx)
+				continue
+			case flushDedupeDeferred:
+				// the chunk is picked up again by the next flush sweep.
+				continue
+			}
+		}
+
 		if err := i.flushChunk(ctx, &ch); err != nil {
 			return err
 		}
@@ -0,0 +1,145 @@
+package ingester
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	chunkclient "github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
+)
+
+const (
+	// flushDedupeOwner means the chunk was uploaded by the replica owning the flush of its stream.
+	flushDedupeOwner = "owner"
+	// flushDedupeSkipped means the owner already uploaded an identical chunk.
+	flushDedupeSkipped = "skipped"
+	// flushDedupeDeferred means the upload is postponed to give the owner a chance to upload an identical chunk.
+	flushDedupeDeferred = "deferred"
+	// flushDedupeFallback means the chunk was uploaded by a replica not owning its flush,
+	// because the owner didn't upload an identical chunk in time.
+	flushDedupeFallback = "fallback"
+)
+
+// FlushDedupeConfig configures the deduplication of chunks flushed by the replicas of a stream.
+type FlushDedupeConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	OwnerTimeout time.Duration `yaml:"owner_timeout"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *FlushDedupeConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ingester.flush-dedupe.enabled", false, "Deduplicate the chunks flushed by the replicas of a stream. A single replica of each stream, chosen from the ring, uploads its chunks right away. The other replicas skip the upload of a chunk only once the owner uploaded a chunk with the same boundaries and checksum, and upload their own copy otherwise, for example when they cut their chunks at different points or the owner missed some entries.")
+	f.DurationVar(&cfg.OwnerTimeout, "ingester.flush-dedupe.owner-timeout", 5*time.Minute, "How long a replica not owning the flush of a stream waits for the owner to upload an identical chunk before uploading its own copy.")
+}
+
+func (cfg *FlushDedupeConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.OwnerTimeout <= 0 {
+		return fmt.Errorf("invalid flush deduplication owner timeout: %s", cfg.OwnerTimeout)
+	}
+	return nil
+}
+
+// SetFlushDedupeClient sets the object client of the chunks, used to find the chunks already uploaded by the owner
+// of their flush, and the encoder of their keys. Flush deduplication is disabled until it is set.
+func (i *Ingester) SetFlushDedupeClient(objectClient chunkclient.ObjectClient, keyEncoder chunkclient.KeyEncoder) {
+	i.flushDedupeClient = objectClient
+	i.flushDedupeKeyEncoder = keyEncoder
+}
+
+// flushOwner returns the ID of the replica responsible for uploading the chunks of the stream,
+// and whether this ingester is one of the replicas of the stream.
+//
+// The owner is picked among the replication set of the stream in the ring using its fingerprint, so that every
+// replica agrees on the owner without further coordination while the uploads are spread across the replicas.
+func (i *Ingester) flushOwner(userID, lbs string, fp model.Fingerprint) (string, bool, error) {
+	if i.readRing == nil {
+		return i.lifecycler.ID, true, nil
+	}
+
+	rs, err := i.readRing.Get(lokiring.TokenFor(userID, lbs), ring.WriteNoExtend, nil, nil, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	ids := make([]string, 0, len(rs.Instances))
+	isReplica := false
+	for _, inst := range rs.Instances {
+		ids = append(ids, inst.Id)
+		if inst.Id == i.lifecycler.ID {
+			isReplica = true
+		}
+	}
+	if len(ids) == 0 {
+		return "", false, ring.ErrEmptyRing
+	}
+	sort.Strings(ids)
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(fp))
+	return ids[xxhash.Sum64(buf[:])%uint64(len(ids))], isReplica, nil
+}
+
+// dedupeFlush decides whether the encoded chunk has to be uploaded by this ingester, and returns the outcome.
+//
+// The owner of the flush always uploads the chunk. Other replicas skip the upload once the object of the chunk
+// exists: its key holds the fingerprint, the boundaries and the checksum of the chunk, so the owner uploaded an
+// identical chunk, and indexed it. Otherwise, the upload is deferred until the owner timeout elapses, or done right
+// away when the chunk is forcibly flushed, for example on shutdown.
+func (i *Ingester) dedupeFlush(ctx context.Context, ch *chunk.Chunk, desc *chunkDesc, lbs string, chunkMtx sync.Locker) string {
+	if i.flushDedupeClient == nil {
+		return flushDedupeOwner
+	}
+	owner, isReplica, err := i.flushOwner(ch.UserID, lbs, model.Fingerprint(ch.Fingerprint))
+	if err != nil {
+		level.Warn(i.logger).Log("msg", "failed to get flush owner, uploading chunk", "err", err)
+		return flushDedupeOwner
+	}
+	if owner == i.lifecycler.ID || !isReplica {
+		return flushDedupeOwner
+	}
+
+	uploaded, err := i.flushDedupeClient.ObjectExists(ctx, i.flushDedupeChunkKey(ch))
+	if err != nil {
+		level.Warn(i.logger).Log("msg", "failed to check if the owner uploaded the chunk", "owner", owner, "err", err)
+	}
+	if uploaded {
+		return flushDedupeSkipped
+	}
+
+	chunkMtx.Lock()
+	defer chunkMtx.Unlock()
+	if desc.reason == flushReasonForced {
+		return flushDedupeFallback
+	}
+	if desc.flushDeferred.IsZero() {
+		desc.flushDeferred = time.Now()
+	}
+	if time.Since(desc.flushDeferred) >= i.cfg.FlushDedupe.OwnerTimeout {
+		return flushDedupeFallback
+	}
+	return flushDedupeDeferred
+}
+
+// flushDedupeChunkKey returns the key of the object of the chunk, as written by the chunk store.
+func (i *Ingester) flushDedupeChunkKey(ch *chunk.Chunk) string {
+	schemaCfg := config.SchemaConfig{Configs: i.periodicConfigs}
+	if i.flushDedupeKeyEncoder != nil {
+		return i.flushDedupeKeyEncoder(schemaCfg, *ch)
+	}
+	return schemaCfg.ExternalKey(ch.ChunkRef)
+}
@@ -0,0 +1,185 @@
+package ingester
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/runtime"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	chunkclient "github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/testutils"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func chunksFor(s *testStore, userID string, fp model.Fingerprint) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var n int
+	for _, c := range s.chunks[userID] {
+		if model.Fingerprint(c.Fingerprint) == fp {
+			n++
+		}
+	}
+	return n
+}
+
+func newDedupeTestIngester(t *testing.T, id string, store Store, objectClient chunkclient.ObjectClient, readRing ring.ReadRing) *Ingester {
+	cfg := defaultIngesterTestConfig(t)
+	cfg.LifecyclerConfig.ID = id
+	cfg.MaxChunkIdle = time.Nanosecond
+	cfg.FlushDedupe = FlushDedupeConfig{Enabled: true, OwnerTimeout: time.Hour}
+
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	i, err := New(cfg, client.Config{}, store, limits, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokitlog.NewNopLogger(), nil, readRing, nil)
+	require.NoError(t, err)
+	i.SetFlushDedupeClient(objectClient, nil)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), i)
+	})
+	return i
+}
+
+func TestFlushDedupe(t *testing.T) {
+	const tenant = "test"
+	objectClient := testutils.NewInMemoryObjectClient()
+	// the store writes the objects of the chunks like the chunk store does.
+	store := &testStore{chunks: map[string][]chunk.Chunk{}}
+	store.onPut = func(ctx context.Context, chunks []chunk.Chunk) error {
+		schemaCfg := config.SchemaConfig{Configs: defaultPeriodConfigs}
+		for _, c := range chunks {
+			if err := objectClient.PutObject(ctx, schemaCfg.ExternalKey(c.ChunkRef), bytes.NewReader(nil)); err != nil {
+				return err
+			}
+			store.chunks[c.UserID] = append(store.chunks[c.UserID], c)
+		}
+		return nil
+	}
+	readRing := newReadRingMock([]ring.InstanceDesc{
+		{Id: "ingester-a", Addr: "ingester-a", Timestamp: time.Now().UnixNano(), State: ring.ACTIVE, Tokens: []uint32{1}},
+		{Id: "ingester-b", Addr: "ingester-b", Timestamp: time.Now().UnixNano(), State: ring.ACTIVE, Tokens: []uint32{2}},
+	}, 0)
+	a := newDedupeTestIngester(t, "ingester-a", store, objectClient, readRing)
+	b := newDedupeTestIngester(t, "ingester-b", store, objectClient, readRing)
+
+	inst, err := a.GetOrCreateInstance(tenant)
+	require.NoError(t, err)
+	fingerprint := func(lbs string) model.Fingerprint {
+		ls, err := syntax.ParseLabels(lbs)
+		require.NoError(t, err)
+		return inst.getHashForLabels(ls)
+	}
+
+	// find two streams whose flush is owned by a, one cut identically by both replicas and one cut differently,
+	// and a stream whose flush is owned by b.
+	var identical, different, ownedByB string
+	for j := 0; identical == "" || different == "" || ownedByB == ""; j++ {
+		lbs := fmt.Sprintf(`{app="app-%d"}`, j)
+		owner, isReplica, err := a.flushOwner(tenant, lbs, fingerprint(lbs))
+		require.NoError(t, err)
+		require.True(t, isReplica)
+		switch {
+		case owner == "ingester-b" && ownedByB == "":
+			ownedByB = lbs
+		case owner == "ingester-a" && identical == "":
+			identical = lbs
+		case owner == "ingester-a" && different == "":
+			different = lbs
+		}
+	}
+
+	now := time.Now()
+	ctx := user.InjectOrgID(context.Background(), tenant)
+	push := func(ing *Ingester, lbs string, from, to int) {
+		stream := logproto.Stream{Labels: lbs}
+		for k := from; k < to; k++ {
+			stream.Entries = append(stream.Entries, logproto.Entry{Timestamp: now.Add(time.Duration(k) * time.Second), Line: fmt.Sprintf("line %d", k)})
+		}
+		_, err := ing.Push(ctx, &logproto.PushRequest{Streams: []logproto.Stream{stream}})
+		require.NoError(t, err)
+	}
+	cutChunk := func(ing *Ingester, lbs string) {
+		inst, err := ing.GetOrCreateInstance(tenant)
+		require.NoError(t, err)
+		require.NoError(t, inst.streams.ForEach(func(s *stream) (bool, error) {
+			if s.labelsString == lbs {
+				s.chunkMtx.Lock()
+				defer s.chunkMtx.Unlock()
+				s.chunks[len(s.chunks)-1].closed = true
+			}
+			return true, nil
+		}))
+	}
+
+	// both replicas receive the same writes, b cuts the chunks of one of the streams in the middle of them.
+	for _, lbs := range []string{identical, different, ownedByB} {
+		push(a, lbs, 0, 4)
+		push(b, lbs, 0, 2)
+		if lbs == different {
+			cutChunk(b, lbs)
+		}
+		push(b, lbs, 2, 4)
+	}
+
+	outcomes := func(ing *Ingester, outcome string) float64 {
+		return testutil.ToFloat64(ing.metrics.chunksFlushDedupe.WithLabelValues(outcome))
+	}
+
+	// b defers the flush of its chunk of a stream owned by a, which didn't upload its chunk yet.
+	require.NoError(t, b.flushUserSeries(context.Background(), tenant, fingerprint(identical), false))
+	require.Equal(t, 1.0, outcomes(b, flushDedupeDeferred))
+	require.Equal(t, 0, chunksFor(store, tenant, fingerprint(identical)))
+
+	// a owns the flush and uploads its chunk, b then skips its identical chunk.
+	require.NoError(t, a.flushUserSeries(context.Background(), tenant, fingerprint(identical), false))
+	require.Equal(t, 1.0, outcomes(a, flushDedupeOwner))
+	require.NoError(t, b.flushUserSeries(context.Background(), tenant, fingerprint(identical), false))
+	require.Equal(t, 1.0, outcomes(b, flushDedupeSkipped))
+	require.Equal(t, 1, chunksFor(store, tenant, fingerprint(identical)))
+
+	// the skipped chunk is flushed and not picked up again.
+	require.NoError(t, b.flushUserSeries(context.Background(), tenant, fingerprint(identical), false))
+	require.Equal(t, 1.0, outcomes(b, flushDedupeSkipped))
+
+	// the chunks b cut differently are not identical to the chunk of a, they are uploaded after the owner timeout.
+	require.NoError(t, a.flushUserSeries(context.Background(), tenant, fingerprint(different), false))
+	require.NoError(t, b.flushUserSeries(context.Background(), tenant, fingerprint(different), false))
+	require.Equal(t, 1.0, outcomes(b, flushDedupeSkipped))
+	require.Equal(t, 3.0, outcomes(b, flushDedupeDeferred))
+	require.Equal(t, 1, chunksFor(store, tenant, fingerprint(different)))
+
+	b.cfg.FlushDedupe.OwnerTimeout = time.Nanosecond
+	require.NoError(t, b.flushUserSeries(context.Background(), tenant, fingerprint(different), false))
+	require.Equal(t, 2.0, outcomes(b, flushDedupeFallback))
+	require.Equal(t, 3, chunksFor(store, tenant, fingerprint(different)))
+
+	// a defers the upload of the stream owned by b, until the owner timeout elapses.
+	require.NoError(t, a.flushUserSeries(context.Background(), tenant, fingerprint(ownedByB), false))
+	require.Equal(t, 1.0, outcomes(a, flushDedupeDeferred))
+	require.Equal(t, 0, chunksFor(store, tenant, fingerprint(ownedByB)))
+
+	a.cfg.FlushDedupe.OwnerTimeout = time.Nanosecond
+	require.NoError(t, a.flushUserSeries(context.Background(), tenant, fingerprint(ownedByB), false))
+	require.Equal(t, 1.0, outcomes(a, flushDedupeFallback))
+	require.Equal(t, 1, chunksFor(store, tenant, fingerprint(ownedByB)))
+}
@@ -51,6 +51,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/runtime"
 	"github.com/grafana/loki/v3/pkg/storage"
 	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	chunkclient "github.com/grafana/loki/v3/pkg/storage/chunk/client"
 	"github.com/grafana/loki/v3/pkg/storage/config"
 	"github.com/grafana/loki/v3/pkg/storage/stores"
 	indexstore "github.com/grafana/loki/v3/pkg/storage/stores/index"
@@ -132,6 +133,10 @@ type Config struct {
 	OwnedStreamsCheckInterval time.Duration `yaml:"owned_streams_check_interval" doc:"description=Interval at which the ingester ownedStreamService checks for changes in the ring to recalculate owned streams."`
 
 	KafkaIngestion KafkaIngestionConfig `yaml:"kafka_ingestion,omitempty"`
+
+	Snapshot SnapshotConfig `yaml:"snapshot,omitempty" doc:"description=Snapshots of the in-memory streams uploaded to object storage, which a replacement ingester can restore from instead of replaying the WAL."`
+
+	FlushDedupe FlushDedupeConfig `yaml:"flush_dedupe,omitempty" doc:"description=Deduplication of the chunks flushed by the replicas of a stream."`
 }
 
 // RegisterFlags registers the flags.
@@ -139,6 +144,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.LifecyclerConfig.RegisterFlags(f, util_log.Logger)
 	cfg.WAL.RegisterFlags(f)
 	cfg.KafkaIngestion.RegisterFlags(f)
+	cfg.Snapshot.RegisterFlags(f)
+	cfg.FlushDedupe.RegisterFlags(f)
 
 	f.IntVar(&cfg.ConcurrentFlushes, "ingester.concurrent-flushes", 32, "How many flushes can happen concurrently from each stream.")
 	f.DurationVar(&cfg.FlushCheckPeriod, "ingester.flush-check-period", 30*time.Second, "How often should the ingester see if there are any blocks to flush. The first flush check is delayed by a random time up to 0.8x the flush check period. Additionally, there is +/- 1% jitter added to the interval.")
@@ -174,6 +181,14 @@ func (cfg *Config) Validate() error {
 		return err
 	}
 
+	if err = cfg.Snapshot.Validate(); err != nil {
+		return err
+	}
+
+	if err = cfg.FlushDedupe.Validate(); err != nil {
+		return err
+	}
+
 	if cfg.FlushOpBackoff.MinBackoff > cfg.FlushOpBackoff.MaxBackoff {
 		return errors.New("invalid flush op min backoff: cannot be larger than max backoff")
 	}
@@ -228,6 +243,8 @@ type Interface interface {
 	ShutdownHandler(w http.ResponseWriter, r *http.Request)
 	PrepareShutdown(w http.ResponseWriter, r *http.Request)
 	PreparePartitionDownscaleHandler(w http.ResponseWriter, r *http.Request)
+	SnapshotHandler(w http.ResponseWriter, r *http.Request)
+	StreamStatsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 // Ingester builds chunks for incoming log streams.
@@ -280,6 +297,13 @@ type Ingester struct {
 
 	wal WAL
 
+	// Only set when snapshots are enabled.
+	snapshotter *snapshotter
+
+	// Only set when flush deduplication is enabled.
+	flushDedupeClient     chunkclient.ObjectClient
+	flushDedupeKeyEncoder chunkclient.KeyEncoder
+
 	chunkFilter      chunk.RequestChunkFilterer
 	extractorWrapper lokilog.SampleExtractorWrapper
 	pipelineWrapper  lokilog.PipelineWrapper
@@ -516,6 +540,12 @@ func (i *Ingester) starting(ctx context.Context) (err error) {
 		}
 	}()
 
+	if i.snapshotter != nil && i.cfg.Snapshot.RestoreFrom != "" {
+		if err := i.restoreSnapshot(ctx); err != nil {
+			return fmt.Errorf("failed to restore snapshot %s: %w", i.cfg.Snapshot.RestoreFrom, err)
+		}
+	}
+
 	if i.cfg.WAL.Enabled {
 		start := time.Now()
 
@@ -706,6 +736,10 @@ func (i *Ingester) stopping(_ error) error {
 	}
 	i.flushQueuesDone.Wait()
 
+	for _, instance := range i.getInstances() {
+		instance.deleteMetrics()
+	}
+
 	i.streamRateCalculator.Stop()
 
 	// In case the flag to terminate on shutdown is set or this instance is marked to release its resources,
@@ -996,6 +1030,9 @@ func (i *Ingester) Push(ctx context.Context, req *logproto.PushRequest) (*logpro
 	if err != nil {
 		return &logproto.PushResponse{}, err
 	}
+	if err := i.enforceTenantMemoryLimit(ctx, instance, req); err != nil {
+		return &logproto.PushResponse{}, err
+	}
 	return &logproto.PushResponse{}, instance.Push(ctx, req)
 }
 
@@ -82,6 +82,17 @@ var (
 		Help:      "The total number of streams removed per tenant.",
 	}, []string{"tenant"})
 
+	memoryTenantBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: constants.Loki,
+		Name:      "ingester_memory_tenant_bytes",
+		Help:      "The total bytes of head blocks and unflushed chunks in memory per tenant.",
+	}, []string{"tenant"})
+	memoryLimitFlushedStreams = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: constants.Loki,
+		Name:      "ingester_memory_limit_flushed_streams_total",
+		Help:      "The total number of streams flushed because the tenant exceeded its memory limit.",
+	}, []string{"tenant"})
+
 	streamsCountStats = analytics.NewInt("ingester_streams_count")
 )
 
@@ -99,6 +110,12 @@ type instance struct {
 	streamsCreatedTotal prometheus.Counter
 	streamsRemovedTotal prometheus.Counter
 
+	// memoryBytes is the total of the memoryBytes of the streams, see updateMemoryBytes.
+	memoryBytes      atomic.Int64
+	memoryBytesGauge prometheus.Gauge
+	// lastMemoryLimitFlush is when the largest streams were last flushed because of the memory limit.
+	lastMemoryLimitFlush atomic.Time
+
 	tailers   map[uint32]*tailer
 	tailerMtx sync.RWMutex
 
@@ -160,6 +177,7 @@ func newInstance(
 
 		streamsCreatedTotal: streamsCreatedTotal.WithLabelValues(instanceID),
 		streamsRemovedTotal: streamsRemovedTotal.WithLabelValues(instanceID),
+		memoryBytesGauge:    memoryTenantBytes.WithLabelValues(instanceID),
 
 		tailers:            map[uint32]*tailer{},
 		limiter:            limiter,
@@ -211,6 +229,7 @@ func (i *instance) consumeChunk(ctx context.Context, ls labels.Labels, chunk *lo
 	err := s.consumeChunk(ctx, chunk)
 	if err == nil {
 		i.metrics.memoryChunks.Inc()
+		i.updateMemoryBytes(s)
 	}
 
 	return err
@@ -250,6 +269,7 @@ func (i *instance) Push(ctx context.Context, req *logproto.PushRequest) error {
 		}
 
 		_, appendErr = s.Push(ctx, reqStream.Entries, record, 0, false, rateLimitWholeStream, i.customStreamsTracker)
+		i.updateMemoryBytes(s)
 		s.chunkMtx.Unlock()
 	}
 
@@ -416,6 +436,16 @@ func (i *instance) removeStream(s *stream) {
 		memoryStreamsLabelsBytes.Sub(float64(len(s.labels.String())))
 		streamsCountStats.Add(-1)
 		i.ownedStreamsSvc.trackRemovedStream(s.fp)
+		i.memoryBytesGauge.Set(float64(i.memoryBytes.Sub(s.memoryBytes)))
+		s.memoryBytes = 0
+	}
+}
+
+// updateMemoryBytes accounts the changes in memory held by the stream to the tenant.
+// The stream's chunkMtx must be held.
+func (i *instance) updateMemoryBytes(s *stream) {
+	if delta := s.updateMemoryBytes(); delta != 0 {
+		i.memoryBytesGauge.Set(float64(i.memoryBytes.Add(delta)))
 	}
 }
 
@@ -1008,6 +1038,13 @@ func (i *instance) closeTailers() {
 	}
 }
 
+// deleteMetrics removes the per-tenant memory metrics of the instance once it is torn down,
+// so that the series of tenants the ingester doesn't serve anymore are not exported forever.
+func (i *instance) deleteMetrics() {
+	memoryTenantBytes.DeleteLabelValues(i.instanceID)
+	memoryLimitFlushedStreams.DeleteLabelValues(i.instanceID)
+}
+
 func (i *instance) openTailersCount() uint32 {
 	i.checkClosedTailers()
 
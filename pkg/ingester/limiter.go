@@ -33,6 +33,8 @@ type Limits interface {
 	PerStreamRateLimit(userID string) validation.RateLimit
 	ShardStreams(userID string) shardstreams.Config
 	IngestionPartitionsTenantShardSize(userID string) int
+	MaxTenantMemoryBytes(userID string) int
+	TenantMemoryLimitPolicy(userID string) string
 }
 
 // Limiter implements primitives to get the maximum number of streams
@@ -70,6 +70,14 @@ type ingesterMetrics struct {
 	flushQueueLength       prometheus.Gauge
 	duplicateLogBytesTotal *prometheus.CounterVec
 	streamsOwnershipCheck  prometheus.Histogram
+
+	snapshotOperations *prometheus.CounterVec
+	snapshotInProgress *prometheus.GaugeVec
+	snapshotDuration   *prometheus.GaugeVec
+	snapshotSeries     *prometheus.CounterVec
+	snapshotBytes      *prometheus.CounterVec
+
+	chunksFlushDedupe *prometheus.CounterVec
 }
 
 // setRecoveryBytesInUse bounds the bytes reports to >= 0.
@@ -322,6 +330,44 @@ func newIngesterMetrics(r prometheus.Registerer, metricsNamespace string) *inges
 			Buckets: []float64{100, 250, 350, 500, 750, 1000, 1500, 2000, 5000},
 		}),
 
+		snapshotOperations: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Subsystem: "ingester",
+			Name:      "snapshot_operations_total",
+			Help:      "Total number of snapshot operations by operation and status.",
+		}, []string{"op", "status"}),
+		snapshotInProgress: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Subsystem: "ingester",
+			Name:      "snapshot_in_progress",
+			Help:      "1 if a snapshot operation is in progress, 0 otherwise.",
+		}, []string{"op"}),
+		snapshotDuration: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Subsystem: "ingester",
+			Name:      "snapshot_duration_seconds",
+			Help:      "Time taken by the last snapshot operation.",
+		}, []string{"op"}),
+		snapshotSeries: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Subsystem: "ingester",
+			Name:      "snapshot_series_total",
+			Help:      "Total number of series written to or read from snapshots.",
+		}, []string{"op"}),
+		snapshotBytes: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Subsystem: "ingester",
+			Name:      "snapshot_bytes_total",
+			Help:      "Total number of bytes uploaded to or downloaded from snapshots.",
+		}, []string{"op"}),
+
+		chunksFlushDedupe: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Subsystem: "ingester",
+			Name:      "chunks_flush_dedupe_total",
+			Help:      "Total number of chunks evaluated for flush deduplication by outcome.",
+		}, []string{"outcome"}),
+
 		duplicateLogBytesTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
 			Namespace: metricsNamespace,
 			Subsystem: "ingester",
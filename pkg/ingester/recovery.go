@@ -224,6 +224,7 @@ func (r *ingesterRecoverer) Close() {
 				}
 			}
 
+			inst.updateMemoryBytes(s)
 			return nil
 		})
 	}
@@ -0,0 +1,481 @@
+package ingester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+
+	"github.com/grafana/loki/v3/pkg/ingester/wal"
+	chunkclient "github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/util"
+)
+
+const (
+	snapshotVersion = 1
+
+	snapshotSeriesFile   = "series"
+	snapshotManifestFile = "manifest.json"
+
+	snapshotOpCreate  = "create"
+	snapshotOpRestore = "restore"
+)
+
+var (
+	errSnapshotsDisabled     = errors.New("ingester snapshots are not enabled")
+	errSnapshotInProgress    = errors.New("another snapshot operation is in progress")
+	errSnapshotNotFound      = errors.New("no snapshot found")
+	errSnapshotCorrupted     = errors.New("snapshot checksum mismatch")
+	errSnapshotSeriesMissing = errors.New("snapshot series count mismatch")
+)
+
+// SnapshotConfig configures the upload and restore of ingester snapshots.
+//
+// A snapshot is a consistent copy of all in-memory streams of an ingester encoded in the
+// WAL checkpoint format. It is uploaded to object storage and can be used by a replacement
+// ingester to restore the streams before it joins the ring, instead of replaying a WAL.
+type SnapshotConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Store       string        `yaml:"store"`
+	Prefix      string        `yaml:"prefix"`
+	RestoreFrom string        `yaml:"restore_from"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *SnapshotConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ingester.snapshot.enabled", false, "Enable the /ingester/snapshot endpoint which uploads a snapshot of all in-memory streams to object storage.")
+	f.StringVar(&cfg.Store, "ingester.snapshot.store", "", "Object store used for ingester snapshots. If empty, the object store of the active schema period is used.")
+	f.StringVar(&cfg.Prefix, "ingester.snapshot.prefix", "ingester-snapshots/", "Path prefix under which ingester snapshots are stored.")
+	f.StringVar(&cfg.RestoreFrom, "ingester.snapshot.restore-from", "", "Snapshot to restore before joining the ring, either <ingester-id> for the most recent snapshot of that ingester or <ingester-id>/<snapshot-id> for a specific one.")
+	f.DurationVar(&cfg.Timeout, "ingester.snapshot.timeout", 30*time.Minute, "Timeout for creating or restoring a snapshot.")
+}
+
+func (cfg *SnapshotConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		return fmt.Errorf("invalid snapshot prefix %q: must end with a slash", cfg.Prefix)
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid snapshot timeout: %s", cfg.Timeout)
+	}
+	return nil
+}
+
+// SnapshotManifest describes an uploaded snapshot. It is written after the series object,
+// so its presence marks the snapshot as complete.
+type SnapshotManifest struct {
+	Version    int       `json:"version"`
+	ID         string    `json:"id"`
+	IngesterID string    `json:"ingester_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Tenants    int       `json:"tenants"`
+	Series     int       `json:"series"`
+	Chunks     int       `json:"chunks"`
+	Size       int64     `json:"size"`
+	// Checksum is the hex encoded SHA-256 of the series object.
+	Checksum string `json:"checksum"`
+}
+
+type snapshotter struct {
+	cfg        SnapshotConfig
+	client     chunkclient.ObjectClient
+	ingesterID string
+	tmpDir     string
+	metrics    *ingesterMetrics
+	logger     log.Logger
+
+	// only one snapshot operation is allowed at a time.
+	mtx sync.Mutex
+}
+
+func newSnapshotter(cfg SnapshotConfig, objectClient chunkclient.ObjectClient, ingesterID, tmpDir string, metrics *ingesterMetrics, logger log.Logger) *snapshotter {
+	return &snapshotter{
+		cfg:        cfg,
+		client:     objectClient,
+		ingesterID: ingesterID,
+		tmpDir:     tmpDir,
+		metrics:    metrics,
+		logger:     logger,
+	}
+}
+
+// snapshotID returns an ID for a snapshot created at the given time.
+// IDs sort lexicographically in creation order.
+func snapshotID(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+func (s *snapshotter) key(ingesterID, id, file string) string {
+	return s.cfg.Prefix + path.Join(ingesterID, id, file)
+}
+
+// Create writes all streams of the given instances into a snapshot and uploads it.
+func (s *snapshotter) Create(ctx context.Context, ing ingesterInstances) (_ *SnapshotManifest, err error) {
+	if !s.mtx.TryLock() {
+		return nil, errSnapshotInProgress
+	}
+	defer s.mtx.Unlock()
+
+	start := time.Now()
+	s.metrics.snapshotInProgress.WithLabelValues(snapshotOpCreate).Set(1)
+	defer func() {
+		s.metrics.snapshotInProgress.WithLabelValues(snapshotOpCreate).Set(0)
+		s.metrics.snapshotDuration.WithLabelValues(snapshotOpCreate).Set(time.Since(start).Seconds())
+		s.metrics.snapshotOperations.WithLabelValues(snapshotOpCreate, statusLabel(err)).Inc()
+	}()
+
+	f, err := os.CreateTemp(s.tmpDir, "snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	manifest := &SnapshotManifest{
+		Version:    snapshotVersion,
+		ID:         snapshotID(start),
+		IngesterID: s.ingesterID,
+		CreatedAt:  start.UTC(),
+		Tenants:    len(ing.getInstances()),
+	}
+
+	hash := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(f, hash))
+
+	var (
+		buf    []byte
+		lenBuf = make([]byte, binary.MaxVarintLen64)
+	)
+	iter := newStreamsIterator(ing)
+	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		series := iter.Stream()
+		buf, err = encodeWithTypeHeader(series, wal.CheckpointRecord, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		n := binary.PutUvarint(lenBuf, uint64(len(buf)))
+		if _, err := w.Write(lenBuf[:n]); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return nil, err
+		}
+
+		manifest.Series++
+		manifest.Chunks += len(series.Chunks)
+		manifest.Size += int64(n + len(buf))
+		s.metrics.snapshotSeries.WithLabelValues(snapshotOpCreate).Inc()
+		s.metrics.snapshotBytes.WithLabelValues(snapshotOpCreate).Add(float64(n + len(buf)))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	manifest.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.client.PutObject(ctx, s.key(s.ingesterID, manifest.ID, snapshotSeriesFile), f); err != nil {
+		return nil, fmt.Errorf("upload snapshot series: %w", err)
+	}
+
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.PutObject(ctx, s.key(s.ingesterID, manifest.ID, snapshotManifestFile), bytes.NewReader(b)); err != nil {
+		return nil, fmt.Errorf("upload snapshot manifest: %w", err)
+	}
+
+	level.Info(s.logger).Log("msg", "snapshot uploaded", "id", manifest.ID, "series", manifest.Series, "chunks", manifest.Chunks, "size", manifest.Size, "elapsed", time.Since(start))
+	return manifest, nil
+}
+
+// List returns the manifests of all complete snapshots of the given ingester, oldest first.
+func (s *snapshotter) List(ctx context.Context, ingesterID string) ([]*SnapshotManifest, error) {
+	_, prefixes, err := s.client.List(ctx, s.cfg.Prefix+ingesterID+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		ids = append(ids, path.Base(string(p)))
+	}
+	sort.Strings(ids)
+
+	manifests := make([]*SnapshotManifest, 0, len(ids))
+	for _, id := range ids {
+		m, err := s.manifest(ctx, ingesterID, id)
+		if err != nil {
+			if s.client.IsObjectNotFoundErr(err) {
+				// the snapshot upload did not complete.
+				continue
+			}
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+func (s *snapshotter) manifest(ctx context.Context, ingesterID, id string) (*SnapshotManifest, error) {
+	rc, _, err := s.client.GetObject(ctx, s.key(ingesterID, id, snapshotManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var m SnapshotManifest
+	if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decode snapshot manifest: %w", err)
+	}
+	if m.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// resolve returns the manifest referenced by a restore-from value.
+func (s *snapshotter) resolve(ctx context.Context, ref string) (*SnapshotManifest, error) {
+	if ingesterID, id, ok := strings.Cut(ref, "/"); ok {
+		return s.manifest(ctx, ingesterID, id)
+	}
+
+	manifests, err := s.List(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("%w for ingester %s", errSnapshotNotFound, ref)
+	}
+	return manifests[len(manifests)-1], nil
+}
+
+// Restore downloads the referenced snapshot, verifies its integrity and replays it through the recoverer.
+func (s *snapshotter) Restore(ctx context.Context, ref string, recoverer Recoverer) (_ *SnapshotManifest, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	start := time.Now()
+	s.metrics.snapshotInProgress.WithLabelValues(snapshotOpRestore).Set(1)
+	defer func() {
+		s.metrics.snapshotInProgress.WithLabelValues(snapshotOpRestore).Set(0)
+		s.metrics.snapshotDuration.WithLabelValues(snapshotOpRestore).Set(time.Since(start).Seconds())
+		s.metrics.snapshotOperations.WithLabelValues(snapshotOpRestore, statusLabel(err)).Inc()
+	}()
+
+	manifest, err := s.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, _, err := s.client.GetObject(ctx, s.key(manifest.IngesterID, manifest.ID, snapshotSeriesFile))
+	if err != nil {
+		return nil, fmt.Errorf("download snapshot series: %w", err)
+	}
+	defer rc.Close()
+
+	// Download the series object first so that the checksum is verified before anything is replayed.
+	f, err := os.CreateTemp(s.tmpDir, "snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), rc)
+	if err != nil {
+		return nil, fmt.Errorf("download snapshot series: %w", err)
+	}
+	s.metrics.snapshotBytes.WithLabelValues(snapshotOpRestore).Add(float64(size))
+	if size != manifest.Size || hex.EncodeToString(hash.Sum(nil)) != manifest.Checksum {
+		return nil, fmt.Errorf("%w: snapshot %s/%s", errSnapshotCorrupted, manifest.IngesterID, manifest.ID)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader := newSnapshotReader(f, s.metrics)
+	if err := RecoverCheckpoint(reader, recoverer); err != nil {
+		return nil, err
+	}
+	if err := reader.Err(); err != nil {
+		return nil, err
+	}
+	if reader.records != manifest.Series {
+		return nil, fmt.Errorf("%w: expected %d, restored %d", errSnapshotSeriesMissing, manifest.Series, reader.records)
+	}
+
+	level.Info(s.logger).Log("msg", "snapshot restored", "ingester", manifest.IngesterID, "id", manifest.ID, "series", manifest.Series, "elapsed", time.Since(start))
+	return manifest, nil
+}
+
+// snapshotReader reads the length prefixed checkpoint records of a snapshot.
+type snapshotReader struct {
+	r       *bufio.Reader
+	rec     []byte
+	err     error
+	records int
+	metrics *ingesterMetrics
+}
+
+func newSnapshotReader(r io.Reader, metrics *ingesterMetrics) *snapshotReader {
+	return &snapshotReader{
+		r:       bufio.NewReader(r),
+		metrics: metrics,
+	}
+}
+
+func (r *snapshotReader) Next() bool {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		return false
+	}
+
+	if uint64(cap(r.rec)) < n {
+		r.rec = make([]byte, n)
+	}
+	r.rec = r.rec[:n]
+	if _, err := io.ReadFull(r.r, r.rec); err != nil {
+		r.err = fmt.Errorf("read snapshot record: %w", err)
+		return false
+	}
+
+	r.records++
+	r.metrics.snapshotSeries.WithLabelValues(snapshotOpRestore).Inc()
+	return true
+}
+
+func (r *snapshotReader) Err() error { return r.err }
+
+// Record should not be used across multiple calls to Next()
+func (r *snapshotReader) Record() []byte { return r.rec }
+
+func statusLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// SetSnapshotClient sets the object client used to upload and restore snapshots.
+// It must be called before the ingester is started for a configured restore to take effect.
+func (i *Ingester) SetSnapshotClient(objectClient chunkclient.ObjectClient) {
+	tmpDir := ""
+	if i.cfg.WAL.Enabled {
+		tmpDir = i.cfg.WAL.Dir
+	}
+	i.snapshotter = newSnapshotter(i.cfg.Snapshot, objectClient, i.cfg.LifecyclerConfig.ID, tmpDir, i.metrics, i.logger)
+}
+
+// SnapshotHandler handles the /ingester/snapshot endpoint.
+//
+// Following methods are supported:
+//
+//   - GET
+//     Returns the manifests of all snapshots uploaded by this ingester.
+//
+//   - POST
+//     Uploads a snapshot of all in-memory streams and returns its manifest. If the `stop_writes`
+//     parameter is set, the ingester stops accepting pushes before taking the snapshot, so that
+//     the snapshot contains every acknowledged write. This is meant to be used right before the
+//     ingester is shut down and replaced.
+func (i *Ingester) SnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if i.snapshotter == nil {
+		http.Error(w, errSnapshotsDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	if i.State() != services.Running {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), i.cfg.Snapshot.Timeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		manifests, err := i.snapshotter.List(ctx, i.lifecycler.ID)
+		if err != nil {
+			level.Error(i.logger).Log("msg", "failed to list snapshots", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSONResponse(w, manifests)
+	case http.MethodPost:
+		if util.FlagFromValues(r.URL.Query(), "stop_writes", false) {
+			i.stopIncomingRequests()
+		}
+
+		manifest, err := i.snapshotter.Create(ctx, i)
+		if err != nil {
+			level.Error(i.logger).Log("msg", "failed to create snapshot", "err", err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, errSnapshotInProgress) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		util.WriteJSONResponse(w, manifest)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// restoreSnapshot restores the streams of the configured snapshot. It is called on startup,
+// before the ingester joins the ring.
+func (i *Ingester) restoreSnapshot(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, i.cfg.Snapshot.Timeout)
+	defer cancel()
+
+	// Disable the in process stream limit checks while restoring.
+	// It is re-enabled in the recover's Close() method.
+	i.limiter.DisableForWALReplay()
+	recoverer := newIngesterRecoverer(i)
+	defer recoverer.Close()
+
+	level.Info(i.logger).Log("msg", "restoring from snapshot", "snapshot", i.cfg.Snapshot.RestoreFrom)
+	_, err := i.snapshotter.Restore(ctx, i.cfg.Snapshot.RestoreFrom, recoverer)
+	return err
+}
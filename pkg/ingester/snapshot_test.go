@@ -0,0 +1,138 @@
+package ingester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gokit_log "github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/runtime"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/testutils"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func newSnapshotTestIngester(t *testing.T, cfg Config, objectClient *testutils.InMemoryObjectClient) (*Ingester, error) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	i, err := New(cfg, client.Config{}, &mockStore{chunks: map[string][]chunk.Chunk{}}, limits, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokit_log.NewNopLogger(), nil, mockReadRingWithOneActiveIngester(), nil)
+	require.NoError(t, err)
+	i.SetSnapshotClient(objectClient)
+	return i, services.StartAndAwaitRunning(context.Background(), i)
+}
+
+func TestIngesterSnapshotRestore(t *testing.T) {
+	objectClient := testutils.NewInMemoryObjectClient()
+
+	cfg := defaultIngesterTestConfig(t)
+	cfg.Snapshot.Enabled = true
+
+	i, err := newSnapshotTestIngester(t, cfg, objectClient)
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	req := logproto.PushRequest{
+		Streams: []logproto.Stream{
+			{Labels: `{foo="bar",bar="baz1"}`},
+			{Labels: `{foo="bar",bar="baz2"}`},
+		},
+	}
+	start := time.Now()
+	steps := 10
+	end := start.Add(time.Second * time.Duration(steps))
+	for j := 0; j < steps; j++ {
+		for k := range req.Streams {
+			req.Streams[k].Entries = append(req.Streams[k].Entries, logproto.Entry{
+				Timestamp: start.Add(time.Duration(j) * time.Second),
+				Line:      fmt.Sprintf("line %d", j),
+			})
+		}
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	_, err = i.Push(ctx, &req)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	i.SnapshotHandler(rec, httptest.NewRequest(http.MethodPost, "/ingester/snapshot?stop_writes=true", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var manifest SnapshotManifest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &manifest))
+	require.Equal(t, "localhost", manifest.IngesterID)
+	require.Equal(t, 1, manifest.Tenants)
+	require.Equal(t, 2, manifest.Series)
+	require.Equal(t, 2, manifest.Chunks)
+
+	// writes are rejected once the snapshot was taken with stop_writes.
+	_, err = i.Push(ctx, &req)
+	require.ErrorIs(t, err, ErrReadOnly)
+
+	rec = httptest.NewRecorder()
+	i.SnapshotHandler(rec, httptest.NewRequest(http.MethodGet, "/ingester/snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var manifests []SnapshotManifest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &manifests))
+	require.Equal(t, []SnapshotManifest{manifest}, manifests)
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), i))
+
+	// start a replacement ingester restoring the latest snapshot of the previous one.
+	restoreCfg := defaultIngesterTestConfig(t)
+	restoreCfg.Snapshot.Enabled = true
+	restoreCfg.Snapshot.RestoreFrom = "localhost"
+	i, err = newSnapshotTestIngester(t, restoreCfg, objectClient)
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	ensureIngesterData(ctx, t, start, end, i)
+}
+
+func TestIngesterSnapshotRestoreCorrupted(t *testing.T) {
+	objectClient := testutils.NewInMemoryObjectClient()
+
+	cfg := defaultIngesterTestConfig(t)
+	cfg.Snapshot.Enabled = true
+
+	i, err := newSnapshotTestIngester(t, cfg, objectClient)
+	require.NoError(t, err)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	_, err = i.Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{{
+			Labels:  `{foo="bar"}`,
+			Entries: []logproto.Entry{{Timestamp: time.Now(), Line: "line"}},
+		}},
+	})
+	require.NoError(t, err)
+
+	manifest, err := i.snapshotter.Create(context.Background(), i)
+	require.NoError(t, err)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), i))
+
+	// flip a byte of the uploaded series object.
+	key := i.snapshotter.key(manifest.IngesterID, manifest.ID, snapshotSeriesFile)
+	data := bytes.Clone(objectClient.Internals()[key])
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, objectClient.PutObject(context.Background(), key, bytes.NewReader(data)))
+
+	restoreCfg := defaultIngesterTestConfig(t)
+	restoreCfg.Snapshot.Enabled = true
+	restoreCfg.Snapshot.RestoreFrom = manifest.IngesterID + "/" + manifest.ID
+	_, err = newSnapshotTestIngester(t, restoreCfg, objectClient)
+	require.ErrorIs(t, err, errSnapshotCorrupted)
+}
@@ -73,6 +73,16 @@ type stream struct {
 	// introduced to facilitate removing the ordering constraint.
 	entryCt int64
 
+	// Bytes of lines and structured metadata accepted by the stream, and the
+	// chunks flushed so far, reported by the stream stats endpoint.
+	lineBytes               int64
+	structuredMetadataBytes int64
+	flushedChunks           int
+	flushReasons            flushReasonCounter
+
+	// memoryBytes is the memory held by the head blocks and the unflushed chunks of the stream.
+	memoryBytes int64
+
 	unorderedWrites      bool
 	streamRateCalculator *StreamRateCalculator
 
@@ -92,6 +102,8 @@ type chunkDesc struct {
 	reason  string
 
 	lastUpdated time.Time
+	// flushDeferred is when the upload of the chunk was first deferred to the replica owning its flush.
+	flushDeferred time.Time
 }
 
 type entryWithError struct {
@@ -362,6 +374,8 @@ func (s *stream) storeEntries(ctx context.Context, entries []logproto.Entry, usa
 		}
 
 		bytesAdded += len(entries[i].Line)
+		s.lineBytes += int64(len(entries[i].Line))
+		s.structuredMetadataBytes += int64(util.StructuredMetadataSize(entries[i].StructuredMetadata))
 		storedEntries = append(storedEntries, entries[i])
 	}
 	s.reportMetrics(ctx, outOfOrderSamples, outOfOrderBytes, 0, 0, usageTracker)
@@ -0,0 +1,99 @@
+package ingester
+
+import (
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/util"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// recordFlush records the chunks of the stream which were flushed, either uploaded or
+// deduplicated against the chunk of another replica.
+func (s *stream) recordFlush(chunks []*chunkDesc) {
+	s.chunkMtx.Lock()
+	defer s.chunkMtx.Unlock()
+
+	for _, c := range chunks {
+		if c.flushed.IsZero() {
+			continue
+		}
+		s.flushedChunks++
+		_ = s.flushReasons.IncrementForReason(c.reason)
+	}
+}
+
+// stats reports the compression statistics of the chunks held in memory by the stream.
+func (s *stream) stats() loghttp.StreamStats {
+	s.chunkMtx.RLock()
+	defer s.chunkMtx.RUnlock()
+
+	st := loghttp.StreamStats{
+		Tenant:                  s.tenant,
+		Stream:                  s.labelsString,
+		Chunks:                  len(s.chunks),
+		LineBytes:               s.lineBytes,
+		StructuredMetadataBytes: s.structuredMetadataBytes,
+		FlushedChunks:           s.flushedChunks,
+		FlushReasons:            s.flushReasons.Map(),
+	}
+
+	for _, c := range s.chunks {
+		st.Entries += int64(c.chunk.Size())
+		st.Utilization += c.chunk.Utilization()
+		st.CompressedBytes += int64(c.chunk.CompressedSize())
+		st.UncompressedBytes += int64(c.chunk.UncompressedSize())
+	}
+	if len(s.chunks) > 0 {
+		st.Utilization /= float64(len(s.chunks))
+	}
+	if st.CompressedBytes > 0 {
+		st.CompressionRatio = float64(st.UncompressedBytes) / float64(st.CompressedBytes)
+	}
+	if total := st.LineBytes + st.StructuredMetadataBytes; total > 0 {
+		st.StructuredMetadataShare = float64(st.StructuredMetadataBytes) / float64(total)
+	}
+	return st
+}
+
+// StreamStatsHandler handles the /ingester/stream_stats endpoint.
+//
+// It reports the compression statistics of the in-memory streams, sorted by the `sort_by`
+// parameter with the worst streams first, and limited to `limit` streams.
+// If the `tenant` parameter is set, only the streams of that tenant are reported.
+func (i *Ingester) StreamStatsHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := loghttp.ParseStreamStatsQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	var instances []*instance
+	if tenant := r.Form.Get("tenant"); tenant != "" {
+		if inst, ok := i.getInstanceByID(tenant); ok {
+			instances = append(instances, inst)
+		}
+	} else {
+		instances = i.getInstances()
+	}
+
+	var streams []loghttp.StreamStats
+	for _, inst := range instances {
+		err := inst.forAllStreams(r.Context(), func(s *stream) error {
+			streams = append(streams, s.stats())
+			return nil
+		})
+		if err != nil {
+			level.Error(i.logger).Log("msg", "failed to collect stream stats", "tenant", inst.instanceID, "err", err)
+			serverutil.WriteError(err, w)
+			return
+		}
+	}
+
+	util.WriteJSONResponse(w, loghttp.StreamStatsResponse{
+		Streams: loghttp.TopStreamStats(streams, req.SortBy, req.Limit),
+	})
+}
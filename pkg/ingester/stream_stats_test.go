@@ -0,0 +1,97 @@
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestIngester_StreamStatsHandler(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	store, ing := newTestStore(t, cfg, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	now := time.Now()
+	for _, tenant := range []string{"tenant-1", "tenant-2"} {
+		_, err := ing.Push(user.InjectOrgID(context.Background(), tenant), &logproto.PushRequest{
+			Streams: []logproto.Stream{
+				{
+					Labels: `{app="plain"}`,
+					Entries: []logproto.Entry{
+						{Timestamp: now, Line: "0123456789"},
+						{Timestamp: now.Add(time.Second), Line: "0123456789"},
+					},
+				},
+				{
+					Labels: `{app="metadata"}`,
+					Entries: []logproto.Entry{
+						{Timestamp: now, Line: "0123456789", StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "0123456789"}}},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	// flush the streams of the first tenant, the chunks are retained in memory.
+	ing.cfg.RetainPeriod = time.Hour
+	inst, ok := ing.getInstanceByID("tenant-1")
+	require.True(t, ok)
+	ing.sweepInstance(inst, true, false)
+	require.Eventually(t, func() bool {
+		store.mtx.Lock()
+		defer store.mtx.Unlock()
+		return len(store.chunks["tenant-1"]) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	get := func(url string) loghttp.StreamStatsResponse {
+		rec := httptest.NewRecorder()
+		ing.StreamStatsHandler(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp loghttp.StreamStatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := get("/ingester/stream_stats?tenant=tenant-1&sort_by=structured_metadata_share")
+	require.Len(t, resp.Streams, 2)
+
+	metadata := resp.Streams[0]
+	require.Equal(t, "tenant-1", metadata.Tenant)
+	require.Equal(t, `{app="metadata"}`, metadata.Stream)
+	require.Equal(t, 1, metadata.Chunks)
+	require.Equal(t, int64(1), metadata.Entries)
+	require.Equal(t, int64(10), metadata.LineBytes)
+	require.Equal(t, int64(18), metadata.StructuredMetadataBytes)
+	require.InDelta(t, 18.0/28.0, metadata.StructuredMetadataShare, 0.0001)
+	require.Equal(t, 1, metadata.FlushedChunks)
+	require.Equal(t, map[string]int{flushReasonForced: 1}, metadata.FlushReasons)
+	require.Greater(t, metadata.CompressedBytes, int64(0))
+	require.Greater(t, metadata.UncompressedBytes, int64(0))
+
+	plain := resp.Streams[1]
+	require.Equal(t, `{app="plain"}`, plain.Stream)
+	require.Equal(t, int64(2), plain.Entries)
+	require.Equal(t, 0.0, plain.StructuredMetadataShare)
+
+	// without a tenant, streams of all tenants are reported up to the limit.
+	resp = get("/ingester/stream_stats?limit=3&sort_by=entries")
+	require.Len(t, resp.Streams, 3)
+	require.Equal(t, "tenant-1", resp.Streams[0].Tenant)
+	require.Equal(t, int64(2), resp.Streams[0].Entries)
+	require.Equal(t, "tenant-2", resp.Streams[1].Tenant)
+	require.Equal(t, int64(2), resp.Streams[1].Entries)
+	require.Empty(t, resp.Streams[1].FlushReasons, "streams of tenant-2 were not flushed")
+}
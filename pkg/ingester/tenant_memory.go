@@ -0,0 +1,126 @@
+package ingester
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// updateMemoryBytes recomputes the memory held by the head blocks and unflushed chunks of the stream,
+// and returns the difference with the previous value. The chunkMtx must be held.
+//
+// Cut blocks are compressed in memory while head blocks are not, which CompressedSize accounts for.
+func (s *stream) updateMemoryBytes() int64 {
+	var bytes int64
+	for _, c := range s.chunks {
+		if c.flushed.IsZero() {
+			bytes += int64(c.chunk.CompressedSize())
+		}
+	}
+	delta := bytes - s.memoryBytes
+	s.memoryBytes = bytes
+	return delta
+}
+
+// enforceTenantMemoryLimit applies the tenant memory limit policy when the streams of the tenant
+// hold more memory than allowed.
+//
+// With the flush policy, the largest streams of the tenant are flushed to bring its memory back
+// under 90% of the limit and the write is accepted. With the reject policy, the write is rejected
+// until the regular flushes release enough memory.
+func (i *Ingester) enforceTenantMemoryLimit(ctx context.Context, inst *instance, req *logproto.PushRequest) error {
+	limit := int64(i.limiter.limits.MaxTenantMemoryBytes(inst.instanceID))
+	if limit <= 0 {
+		return nil
+	}
+	current := inst.memoryBytes.Load()
+	if current < limit {
+		return nil
+	}
+
+	if i.limiter.limits.TenantMemoryLimitPolicy(inst.instanceID) == validation.TenantMemoryLimitPolicyReject {
+		return inst.discardForMemoryLimit(ctx, req, current, limit)
+	}
+
+	// Flushing takes a while to release memory, don't pile up flushes in the meantime.
+	if last := inst.lastMemoryLimitFlush.Load(); time.Since(last) < i.cfg.FlushCheckPeriod {
+		return nil
+	}
+	inst.lastMemoryLimitFlush.Store(time.Now())
+	i.flushLargestStreams(inst, current-limit*9/10)
+	return nil
+}
+
+// flushLargestStreams schedules the immediate flush of the largest streams of the tenant
+// until they add up to at least the given amount of bytes.
+func (i *Ingester) flushLargestStreams(inst *instance, bytes int64) {
+	// The flush queues are closed when the ingester stops.
+	if i.State() != services.Running {
+		return
+	}
+
+	type streamSize struct {
+		stream *stream
+		bytes  int64
+	}
+	var streams []streamSize
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		s.chunkMtx.RLock()
+		streams = append(streams, streamSize{stream: s, bytes: s.memoryBytes})
+		s.chunkMtx.RUnlock()
+		return true, nil
+	})
+	sort.Slice(streams, func(a, b int) bool {
+		return streams[a].bytes > streams[b].bytes
+	})
+
+	var flushed int64
+	var count int
+	for _, s := range streams {
+		if flushed >= bytes || s.bytes == 0 {
+			break
+		}
+		i.sweepStream(inst, s.stream, true)
+		flushed += s.bytes
+		count++
+	}
+
+	memoryLimitFlushedStreams.WithLabelValues(inst.instanceID).Add(float64(count))
+	level.Info(i.logger).Log(
+		"msg", "tenant exceeded its memory limit, flushing its largest streams",
+		"tenant", inst.instanceID,
+		"memory_bytes", inst.memoryBytes.Load(),
+		"streams", count,
+		"flushed_bytes", flushed,
+	)
+}
+
+// discardForMemoryLimit discards the entries of the request because the tenant exceeded its memory limit.
+func (i *instance) discardForMemoryLimit(ctx context.Context, req *logproto.PushRequest, current, limit int64) error {
+	var lines, bytes int
+	for _, s := range req.Streams {
+		streamBytes := util.EntriesTotalSize(s.Entries)
+		lines += len(s.Entries)
+		bytes += streamBytes
+
+		if i.customStreamsTracker != nil {
+			if lbs, err := syntax.ParseLabels(s.Labels); err == nil {
+				i.customStreamsTracker.DiscardedBytesAdd(ctx, i.instanceID, validation.TenantMemoryLimit, lbs, float64(streamBytes))
+			}
+		}
+	}
+	validation.DiscardedSamples.WithLabelValues(validation.TenantMemoryLimit, i.instanceID).Add(float64(lines))
+	validation.DiscardedBytes.WithLabelValues(validation.TenantMemoryLimit, i.instanceID).Add(float64(bytes))
+
+	return httpgrpc.Errorf(http.StatusTooManyRequests, validation.TenantMemoryLimitErrorMsg, i.instanceID, limit, current, lines, bytes)
+}
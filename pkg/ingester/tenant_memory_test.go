@@ -0,0 +1,140 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
+	"github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/runtime"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+func newTenantMemoryTestIngester(t *testing.T, maxBytes int, policy string) (*testStore, *Ingester) {
+	store := &testStore{chunks: map[string][]chunk.Chunk{}}
+
+	limitsCfg := defaultLimitsTestConfig()
+	require.NoError(t, limitsCfg.MaxTenantMemoryBytes.Set(fmt.Sprint(maxBytes)))
+	limitsCfg.TenantMemoryLimitPolicy = policy
+	limits, err := validation.NewOverrides(limitsCfg, nil)
+	require.NoError(t, err)
+
+	i, err := New(defaultIngesterTestConfig(t), client.Config{}, store, limits, runtime.DefaultTenantConfigs(), nil, writefailures.Cfg{}, constants.Loki, gokitlog.NewNopLogger(), nil, mockReadRingWithOneActiveIngester(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), i)
+	})
+	return store, i
+}
+
+func tenantMemoryTestPush(app string, n int) *logproto.PushRequest {
+	entries := make([]logproto.Entry, 0, n)
+	for j := 0; j < n; j++ {
+		entries = append(entries, logproto.Entry{Timestamp: time.Unix(0, int64(j)), Line: fmt.Sprintf("line %d of %s", j, app)})
+	}
+	return &logproto.PushRequest{Streams: []logproto.Stream{{Labels: fmt.Sprintf(`{app="%s"}`, app), Entries: entries}}}
+}
+
+func TestTenantMemoryAccounting(t *testing.T) {
+	_, i := newTenantMemoryTestIngester(t, 0, validation.TenantMemoryLimitPolicyFlush)
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	for _, app := range []string{"small", "large"} {
+		_, err := i.Push(ctx, tenantMemoryTestPush(app, 100))
+		require.NoError(t, err)
+	}
+	_, err := i.Push(ctx, tenantMemoryTestPush("large", 100))
+	require.NoError(t, err)
+
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	var expected int64
+	_ = inst.streams.ForEach(func(s *stream) (bool, error) {
+		for _, c := range s.chunks {
+			expected += int64(c.chunk.CompressedSize())
+		}
+		return true, nil
+	})
+	require.Greater(t, expected, int64(0))
+	require.Equal(t, expected, inst.memoryBytes.Load())
+
+	// flushed chunks are released from the tenant memory.
+	require.NoError(t, inst.streams.ForEach(func(s *stream) (bool, error) {
+		return true, i.flushUserSeries(context.Background(), "test", s.fp, true)
+	}))
+	require.Equal(t, int64(0), inst.memoryBytes.Load())
+}
+
+func TestTenantMemoryMetricsDeletedOnShutdown(t *testing.T) {
+	_, i := newTenantMemoryTestIngester(t, 0, validation.TenantMemoryLimitPolicyFlush)
+	ctx := user.InjectOrgID(context.Background(), "shutdown")
+
+	_, err := i.Push(ctx, tenantMemoryTestPush("app", 10))
+	require.NoError(t, err)
+	require.Greater(t, testutil.ToFloat64(memoryTenantBytes.WithLabelValues("shutdown")), float64(0))
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), i))
+	require.False(t, memoryTenantBytes.DeleteLabelValues("shutdown"), "the tenant memory series should be deleted")
+}
+
+func TestTenantMemoryLimit(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	t.Run("reject", func(t *testing.T) {
+		_, i := newTenantMemoryTestIngester(t, 1000, validation.TenantMemoryLimitPolicyReject)
+
+		// the first write is accepted, and brings the tenant over its limit.
+		_, err := i.Push(ctx, tenantMemoryTestPush("large", 100))
+		require.NoError(t, err)
+
+		_, err = i.Push(ctx, tenantMemoryTestPush("small", 1))
+		require.Error(t, err)
+		resp, ok := httpgrpc.HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+		require.Contains(t, string(resp.Body), "Maximum in-memory bytes exceeded for user test")
+
+		inst, ok := i.getInstanceByID("test")
+		require.True(t, ok)
+		_, ok = inst.streams.Load(`{app="small"}`)
+		require.False(t, ok)
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		store, i := newTenantMemoryTestIngester(t, 1000, validation.TenantMemoryLimitPolicyFlush)
+
+		_, err := i.Push(ctx, tenantMemoryTestPush("small", 1))
+		require.NoError(t, err)
+		_, err = i.Push(ctx, tenantMemoryTestPush("large", 100))
+		require.NoError(t, err)
+
+		// the write is accepted, while the largest stream is flushed.
+		_, err = i.Push(ctx, tenantMemoryTestPush("other", 1))
+		require.NoError(t, err)
+
+		inst, ok := i.getInstanceByID("test")
+		require.True(t, ok)
+		require.Eventually(t, func() bool {
+			return inst.memoryBytes.Load() < 1000
+		}, 5*time.Second, 10*time.Millisecond)
+
+		chunks := store.getChunksForUser("test")
+		require.Len(t, chunks, 1)
+		require.Equal(t, "large", chunks[0].Metric.Get("app"))
+	})
+}
@@ -34,11 +34,13 @@ const (
 	labelValuesPath         = "/loki/api/v1/label/%s/values"
 	seriesPath              = "/loki/api/v1/series"
 	tailPath                = "/loki/api/v1/tail"
+	tailV2Path              = "/loki/api/v2/tail"
 	statsPath               = "/loki/api/v1/index/stats"
 	volumePath              = "/loki/api/v1/index/volume"
 	volumeRangePath         = "/loki/api/v1/index/volume_range"
 	detectedFieldsPath      = "/loki/api/v1/detected_fields"
 	detectedFieldValuesPath = "/loki/api/v1/detected_field/%s/values"
+	deleteStatusPath        = "/loki/api/v1/delete/status"
 	defaultAuthHeader       = "Authorization"
 
 	// HTTP header keys
@@ -58,11 +60,13 @@ type Client interface {
 	ListLabelValues(name string, quiet bool, start, end time.Time) (*loghttp.LabelResponse, error)
 	Series(matchers []string, start, end time.Time, quiet bool) (*loghttp.SeriesResponse, error)
 	LiveTailQueryConn(queryStr string, delayFor time.Duration, limit int, start time.Time, quiet bool) (*websocket.Conn, error)
+	LiveTailQueryV2(queryStr string, delayFor time.Duration, limit int, start time.Time, resumeToken string, quiet bool) (io.ReadCloser, error)
 	GetOrgID() string
 	GetStats(queryStr string, start, end time.Time, quiet bool) (*logproto.IndexStatsResponse, error)
 	GetVolume(query *volume.Query) (*loghttp.QueryResponse, error)
 	GetVolumeRange(query *volume.Query) (*loghttp.QueryResponse, error)
 	GetDetectedFields(queryStr, fieldName string, fieldLimit, lineLimit int, start, end time.Time, step time.Duration, quiet bool) (*loghttp.DetectedFieldsResponse, error)
+	GetDeleteRequestDetails(requestID string, quiet bool) (*loghttp.DeleteRequestDetails, error)
 }
 
 // Tripperware can wrap a roundtripper.
@@ -89,6 +93,7 @@ type DefaultClient struct {
 	ProxyURL        string
 	BackoffConfig   BackoffConfig
 	Compression     bool
+	Stream          bool
 }
 
 // Query uses the /api/v1/query endpoint to execute an instant query
@@ -180,6 +185,55 @@ func (c *DefaultClient) LiveTailQueryConn(queryStr string, delayFor time.Duratio
 	return c.wsConnect(tailPath, params.Encode(), quiet)
 }
 
+// LiveTailQueryV2 uses /loki/api/v2/tail to start tailing and returns the stream of server-sent events.
+// If resumeToken is set, tailing resumes after the last entry received before.
+func (c *DefaultClient) LiveTailQueryV2(queryStr string, delayFor time.Duration, limit int, start time.Time, resumeToken string, quiet bool) (io.ReadCloser, error) {
+	params := util.NewQueryStringBuilder()
+	params.SetString("query", queryStr)
+	if delayFor != 0 {
+		params.SetInt("delay_for", int64(delayFor.Seconds()))
+	}
+	params.SetInt("limit", int64(limit))
+	params.SetInt("start", start.UnixNano())
+	if resumeToken != "" {
+		params.SetString("resume_token", resumeToken)
+	}
+
+	us, err := buildURL(c.Address, tailV2Path, params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if !quiet {
+		log.Println(us)
+	}
+
+	req, err := http.NewRequest("GET", us, nil)
+	if err != nil {
+		return nil, err
+	}
+	h, err := c.getHTTPRequestHeader()
+	if err != nil {
+		return nil, err
+	}
+	h.Set("Accept", "text/event-stream")
+	req.Header = h
+
+	client, err := c.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		buf, _ := io.ReadAll(resp.Body) // nolint
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("Error response from server: %s (%d)", string(buf), resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
 func (c *DefaultClient) GetOrgID() string {
 	return c.OrgID
 }
@@ -266,6 +320,18 @@ func (c *DefaultClient) GetDetectedFields(
 	return &r, nil
 }
 
+// GetDeleteRequestDetails returns the status, progress and audit log of a delete request.
+func (c *DefaultClient) GetDeleteRequestDetails(requestID string, quiet bool) (*loghttp.DeleteRequestDetails, error) {
+	params := util.NewQueryStringBuilder()
+	params.SetString("request_id", requestID)
+
+	var details loghttp.DeleteRequestDetails
+	if err := c.doRequest(deleteStatusPath, params.Encode(), quiet, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
 func (c *DefaultClient) doQuery(
 	path string,
 	query string,
@@ -274,14 +340,39 @@ func (c *DefaultClient) doQuery(
 	var err error
 	var r loghttp.QueryResponse
 
-	if err = c.doRequest(path, query, quiet, &r); err != nil {
-		return nil, err
+	if !c.Stream {
+		if err = c.doRequest(path, query, quiet, &r); err != nil {
+			return nil, err
+		}
+		return &r, nil
 	}
 
+	// Log queries are streamed by the server as newline delimited JSON, while other queries are not.
+	err = c.do(path, query, quiet, loghttp.NDJSONContentType, func(resp *http.Response) error {
+		if loghttp.StreamContentType(resp.Header.Get("Content-Type")) == "" {
+			return json.NewDecoder(resp.Body).Decode(&r)
+		}
+		streamed, err := loghttp.ReadQueryResponseStream(resp.Body)
+		if err != nil {
+			return err
+		}
+		r = *streamed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return &r, nil
 }
 
 func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{}) error {
+	return c.do(path, query, quiet, "", func(resp *http.Response) error {
+		return json.NewDecoder(resp.Body).Decode(out)
+	})
+}
+
+// do sends the request and decodes the response with decode. If accept is set, it is sent as the Accept header.
+func (c *DefaultClient) do(path, query string, quiet bool, accept string, decode func(*http.Response) error) error {
 	us, err := buildURL(c.Address, path, query)
 	if err != nil {
 		return err
@@ -299,38 +390,15 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 	if err != nil {
 		return err
 	}
-	req.Header = h
-
-	// Parse the URL to extract the host
-	clientConfig := config.HTTPClientConfig{
-		TLSConfig: c.TLSConfig,
-	}
-
-	if c.ProxyURL != "" {
-		prox, err := url.Parse(c.ProxyURL)
-		if err != nil {
-			return err
-		}
-		clientConfig.ProxyURL = config.URL{URL: prox}
+	if accept != "" {
+		h.Set("Accept", accept)
 	}
+	req.Header = h
 
-	client, err := config.NewClientFromConfig(clientConfig, "promtail", config.WithHTTP2Disabled())
+	client, err := c.httpClient()
 	if err != nil {
 		return err
 	}
-	if c.Tripperware != nil {
-		client.Transport = c.Tripperware(client.Transport)
-	}
-	if c.Compression {
-		// NewClientFromConfig() above returns an http.Client that uses a transport which
-		// has compression explicitly disabled. Here we re-enable it. If the caller
-		// defines a custom Tripperware that isn't an http.Transport then this won't work,
-		// but in that case they control the transport anyway and can configure
-		// compression that way.
-		if transport, ok := client.Transport.(*http.Transport); ok {
-			transport.DisableCompression = false
-		}
-	}
 
 	var resp *http.Response
 
@@ -377,10 +445,44 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 			log.Println("error closing body", err)
 		}
 	}()
-	return json.NewDecoder(resp.Body).Decode(out)
+	return decode(resp)
 }
 
 // nolint:goconst
+func (c *DefaultClient) httpClient() (*http.Client, error) {
+	// Parse the URL to extract the host
+	clientConfig := config.HTTPClientConfig{
+		TLSConfig: c.TLSConfig,
+	}
+
+	if c.ProxyURL != "" {
+		prox, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		clientConfig.ProxyURL = config.URL{URL: prox}
+	}
+
+	client, err := config.NewClientFromConfig(clientConfig, "promtail", config.WithHTTP2Disabled())
+	if err != nil {
+		return nil, err
+	}
+	if c.Tripperware != nil {
+		client.Transport = c.Tripperware(client.Transport)
+	}
+	if c.Compression {
+		// NewClientFromConfig() above returns an http.Client that uses a transport which
+		// has compression explicitly disabled. Here we re-enable it. If the caller
+		// defines a custom Tripperware that isn't an http.Transport then this won't work,
+		// but in that case they control the transport anyway and can configure
+		// compression that way.
+		if transport, ok := client.Transport.(*http.Transport); ok {
+			transport.DisableCompression = false
+		}
+	}
+	return client, nil
+}
+
 func (c *DefaultClient) getHTTPRequestHeader() (http.Header, error) {
 	h := make(http.Header)
 
@@ -187,6 +187,10 @@ func (f *FileClient) LiveTailQueryConn(_ string, _ time.Duration, _ int, _ time.
 	return nil, fmt.Errorf("LiveTailQuery: %w", ErrNotSupported)
 }
 
+func (f *FileClient) LiveTailQueryV2(_ string, _ time.Duration, _ int, _ time.Time, _ string, _ bool) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("LiveTailQuery: %w", ErrNotSupported)
+}
+
 func (f *FileClient) GetOrgID() string {
 	return f.orgID
 }
@@ -217,6 +221,10 @@ func (f *FileClient) GetDetectedFields(
 	return nil, ErrNotSupported
 }
 
+func (f *FileClient) GetDeleteRequestDetails(_ string, _ bool) (*loghttp.DeleteRequestDetails, error) {
+	return nil, ErrNotSupported
+}
+
 type limiter struct {
 	n int
 }
@@ -237,6 +245,10 @@ func (l *limiter) BlockedQueries(_ context.Context, _ string) []*validation.Bloc
 	return []*validation.BlockedQuery{}
 }
 
+func (l *limiter) RollupRules(_ context.Context, _ string) []*validation.RollupRule {
+	return nil
+}
+
 func (l *limiter) RequiredLabels(_ context.Context, _ string) []string {
 	return nil
 }
@@ -0,0 +1,100 @@
+package deletion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logcli/client"
+	"github.com/grafana/loki/v3/pkg/loghttp"
+)
+
+type StatusQuery struct {
+	RequestID string
+	Quiet     bool
+	Timezone  *time.Location
+}
+
+// Do gets the details of the delete request and prints them out.
+func (q *StatusQuery) Do(c client.Client, outputMode string) {
+	details, err := c.GetDeleteRequestDetails(q.RequestID, q.Quiet)
+	if err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+
+	switch outputMode {
+	case "raw", "jsonl":
+		out, err := json.Marshal(details)
+		if err != nil {
+			log.Fatalf("Error marshalling response: %+v", err)
+		}
+		fmt.Println(string(out))
+	default:
+		q.printDetails(os.Stdout, details)
+	}
+}
+
+func (q *StatusQuery) printDetails(w io.Writer, details *loghttp.DeleteRequestDetails) {
+	bold := color.New(color.Bold)
+	p := details.Progress
+
+	fmt.Fprintf(w, "Request ID:\t%s\n", bold.Sprint(details.RequestID))
+	fmt.Fprintf(w, "Query:\t\t%s\n", details.Query)
+	fmt.Fprintf(w, "Interval:\t%s - %s\n", q.formatTime(details.StartTime), q.formatTime(details.EndTime))
+	fmt.Fprintf(w, "Status:\t\t%s (%d/%d shards processed)\n", bold.Sprint(details.Status), details.ProcessedShards, details.Shards)
+	fmt.Fprintf(w, "Requested by:\t%s\n", details.RequestedBy)
+	fmt.Fprintf(w, "Created:\t%s\n", q.formatTime(details.CreatedAt))
+	fmt.Fprintf(w, "Started:\t%s\n", q.formatTime(details.ProcessingStartedAt))
+	fmt.Fprintf(w, "Processed:\t%s\n", q.formatTime(details.ProcessedAt))
+	if details.CancelledAt != 0 {
+		fmt.Fprintf(w, "Cancelled:\t%s\n", q.formatTime(details.CancelledAt))
+	}
+	fmt.Fprintf(w, "Progress:\t%d tables processed, %d chunks deleted, %d chunks rewritten, %d lines deleted\n",
+		p.TablesProcessed, p.ChunksDeleted, p.ChunksRewritten, p.DeletedLines)
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tEVENT\tACTOR\tSHARD\tDETAILS")
+	for _, event := range details.AuditLog {
+		shard := "-"
+		if event.SequenceNum != nil {
+			shard = fmt.Sprint(*event.SequenceNum)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", q.formatTime(event.Time), event.Event, event.Actor, shard, eventDetails(event))
+	}
+	if err := tw.Flush(); err != nil {
+		log.Fatalf("Error writing output: %+v", err)
+	}
+}
+
+func (q *StatusQuery) formatTime(t model.Time) string {
+	if t == 0 {
+		return "-"
+	}
+	tz := q.Timezone
+	if tz == nil {
+		tz = time.Local
+	}
+	return t.Time().In(tz).Format(time.RFC3339)
+}
+
+func eventDetails(event loghttp.DeleteRequestAuditEvent) string {
+	var details string
+	if event.Reason != "" {
+		details = event.Reason + " "
+	}
+	if event.Progress != nil {
+		details += fmt.Sprintf("tables=%d chunks_deleted=%d chunks_rewritten=%d deleted_lines=%d",
+			event.Progress.TablesProcessed, event.Progress.ChunksDeleted, event.Progress.ChunksRewritten, event.Progress.DeletedLines)
+	} else if event.Shards > 0 {
+		details += fmt.Sprintf("shards=%d", event.Shards)
+	}
+	return details
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -469,6 +470,10 @@ func (t *testQueryClient) LiveTailQueryConn(_ string, _ time.Duration, _ int, _
 	panic("implement me")
 }
 
+func (t *testQueryClient) LiveTailQueryV2(_ string, _ time.Duration, _ int, _ time.Time, _ string, _ bool) (io.ReadCloser, error) {
+	panic("implement me")
+}
+
 func (t *testQueryClient) GetOrgID() string {
 	panic("implement me")
 }
@@ -495,6 +500,10 @@ func (t *testQueryClient) GetDetectedFields(
 	panic("not implemented")
 }
 
+func (t *testQueryClient) GetDeleteRequestDetails(_ string, _ bool) (*loghttp.DeleteRequestDetails, error) {
+	panic("not implemented")
+}
+
 var legacySchemaConfigContents = `schema_config:
   configs:
   - from: 2020-05-15
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"bufio"
 	"context"
 	"log"
 	"os"
@@ -10,7 +11,6 @@ import (
 	"time"
 
 	"github.com/fatih/color"
-	"github.com/gorilla/websocket"
 	"github.com/grafana/dskit/backoff"
 
 	"github.com/grafana/loki/v3/pkg/logcli/client"
@@ -20,9 +20,10 @@ import (
 	"github.com/grafana/loki/v3/pkg/util/unmarshal"
 )
 
-// TailQuery connects to the Loki websocket endpoint and tails logs
+// TailQuery connects to the Loki tail endpoint and tails logs.
+// When the connection breaks, tailing resumes after the last entry received.
 func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.LogOutput) {
-	conn, err := c.LiveTailQueryConn(q.QueryString, delayFor, q.Limit, q.Start, q.Quiet)
+	body, err := c.LiveTailQueryV2(q.QueryString, delayFor, q.Limit, q.Start, "", q.Quiet)
 	if err != nil {
 		log.Fatalf("Tailing logs failed: %+v", err)
 	}
@@ -31,9 +32,6 @@ func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.Lo
 		stopChan := make(chan os.Signal, 1)
 		signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
 		<-stopChan
-		if err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-			log.Println("Error closing websocket:", err)
-		}
 		os.Exit(0)
 	}()
 
@@ -45,55 +43,54 @@ func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.Lo
 		log.Println("Print only labels key:", color.RedString(strings.Join(q.ShowLabelsKey, ",")))
 	}
 
-	lastReceivedTimestamp := q.Start
+	var resumeToken string
+	r := bufio.NewReader(body)
 
 	for {
-		tailResponse := new(loghttp.TailResponse)
-		err := unmarshal.ReadTailResponseJSON(tailResponse, conn)
+		var ev loghttp.TailEvent
+		err := unmarshal.ReadTailEvent(r, &ev)
 		if err != nil {
-			// Check if the websocket connection closed unexpectedly. If so, retry.
-			// The connection might close unexpectedly if the querier handling the tail request
-			// in Loki stops running. The following error would be printed:
-			// "websocket: close 1006 (abnormal closure): unexpected EOF"
-			if websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
-				log.Printf("Remote websocket connection closed unexpectedly (%+v). Connecting again.", err)
-
-				// Close previous connection. If it fails to close the connection it should be fine as it is already broken.
-				if err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
-					log.Printf("Error closing websocket: %+v", err)
-				}
-
-				// Try to re-establish the connection up to 5 times.
-				backoff := backoff.New(context.Background(), backoff.Config{
-					MinBackoff: 1 * time.Second,
-					MaxBackoff: 10 * time.Second,
-					MaxRetries: 5,
-				})
-
-				for backoff.Ongoing() {
-					conn, err = c.LiveTailQueryConn(q.QueryString, delayFor, q.Limit, lastReceivedTimestamp, q.Quiet)
-					if err == nil {
-						break
-					}
-
-					log.Println("Error recreating tailing connection after unexpected close, will retry:", err)
-					backoff.Wait()
+			// The connection might break if the querier handling the tail request
+			// in Loki stops running. Resume tailing after the last event received.
+			log.Printf("Tailing connection closed unexpectedly (%+v). Connecting again.", err)
+			_ = body.Close()
+
+			// Try to re-establish the connection up to 5 times.
+			backoff := backoff.New(context.Background(), backoff.Config{
+				MinBackoff: 1 * time.Second,
+				MaxBackoff: 10 * time.Second,
+				MaxRetries: 5,
+			})
+
+			for backoff.Ongoing() {
+				body, err = c.LiveTailQueryV2(q.QueryString, delayFor, q.Limit, q.Start, resumeToken, q.Quiet)
+				if err == nil {
+					break
 				}
 
-				if err = backoff.Err(); err != nil {
-					log.Println("Error recreating tailing connection:", err)
-					return
-				}
+				log.Println("Error recreating tailing connection after unexpected close, will retry:", err)
+				backoff.Wait()
+			}
 
-				continue
+			if err = backoff.Err(); err != nil {
+				log.Println("Error recreating tailing connection:", err)
+				return
 			}
 
-			log.Println("Error reading stream:", err)
+			r = bufio.NewReader(body)
+			continue
+		}
+
+		if ev.Type == loghttp.TailEventError {
+			log.Println("Error reading stream:", ev.Error)
 			return
 		}
+		if ev.ID != "" {
+			resumeToken = ev.ID
+		}
 
 		labels := loghttp.LabelSet{}
-		for _, stream := range tailResponse.Streams {
+		for _, stream := range ev.Response.Streams {
 			if !q.NoLabels {
 				if len(q.IgnoreLabelsKey) > 0 || len(q.ShowLabelsKey) > 0 {
 
@@ -116,13 +113,12 @@ func (q *Query) TailQuery(delayFor time.Duration, c client.Client, out output.Lo
 
 			for _, entry := range stream.Entries {
 				out.FormatAndPrintln(entry.Timestamp, labels, 0, entry.Line)
-				lastReceivedTimestamp = entry.Timestamp
 			}
 
 		}
-		if len(tailResponse.DroppedStreams) != 0 {
-			log.Println("Server dropped following entries due to slow client")
-			for _, d := range tailResponse.DroppedStreams {
+		if len(ev.Response.DroppedStreams) != 0 {
+			log.Println("Server dropped following entries")
+			for _, d := range ev.Response.DroppedStreams {
 				log.Println(d.Timestamp, d.Labels)
 			}
 		}
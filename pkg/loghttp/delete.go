@@ -0,0 +1,64 @@
+package loghttp
+
+import "github.com/prometheus/common/model"
+
+// DeleteRequestEvent is the type of an event of the audit log of a delete request.
+type DeleteRequestEvent string
+
+const (
+	DeleteRequestCreated           DeleteRequestEvent = "created"
+	DeleteRequestCancelled         DeleteRequestEvent = "cancelled"
+	DeleteRequestProcessingStarted DeleteRequestEvent = "processing_started"
+	DeleteRequestProcessingFailed  DeleteRequestEvent = "processing_failed"
+	DeleteRequestProcessed         DeleteRequestEvent = "processed"
+)
+
+// DeleteRequestProgress is the progress of the processing of a delete request by the compactor.
+type DeleteRequestProgress struct {
+	TablesProcessed int64 `json:"tables_processed"`
+	ChunksDeleted   int64 `json:"chunks_deleted"`
+	ChunksRewritten int64 `json:"chunks_rewritten"`
+	DeletedLines    int64 `json:"deleted_lines"`
+}
+
+// Add adds the progress of another shard of the delete request.
+func (p *DeleteRequestProgress) Add(other DeleteRequestProgress) {
+	p.TablesProcessed += other.TablesProcessed
+	p.ChunksDeleted += other.ChunksDeleted
+	p.ChunksRewritten += other.ChunksRewritten
+	p.DeletedLines += other.DeletedLines
+}
+
+// DeleteRequestAuditEvent is an event of the audit log of a delete request.
+// The events of the shards of a delete request have their sequence number and interval.
+type DeleteRequestAuditEvent struct {
+	Time        model.Time             `json:"time"`
+	Event       DeleteRequestEvent     `json:"event"`
+	Actor       string                 `json:"actor,omitempty"`
+	Query       string                 `json:"query,omitempty"`
+	StartTime   model.Time             `json:"start_time,omitempty"`
+	EndTime     model.Time             `json:"end_time,omitempty"`
+	Shards      int                    `json:"shards,omitempty"`
+	SequenceNum *int64                 `json:"sequence_num,omitempty"`
+	Reason      string                 `json:"reason,omitempty"`
+	Progress    *DeleteRequestProgress `json:"progress,omitempty"`
+}
+
+// DeleteRequestDetails is the status, progress and audit log of a delete request.
+// Cancelled delete requests are only kept in their audit log.
+type DeleteRequestDetails struct {
+	RequestID           string                    `json:"request_id"`
+	Query               string                    `json:"query"`
+	StartTime           model.Time                `json:"start_time"`
+	EndTime             model.Time                `json:"end_time"`
+	Status              string                    `json:"status"`
+	RequestedBy         string                    `json:"requested_by,omitempty"`
+	CreatedAt           model.Time                `json:"created_at"`
+	ProcessingStartedAt model.Time                `json:"processing_started_at,omitempty"`
+	ProcessedAt         model.Time                `json:"processed_at,omitempty"`
+	CancelledAt         model.Time                `json:"cancelled_at,omitempty"`
+	Shards              int                       `json:"shards"`
+	ProcessedShards     int                       `json:"processed_shards"`
+	Progress            DeleteRequestProgress     `json:"progress"`
+	AuditLog            []DeleteRequestAuditEvent `json:"audit_log"`
+}
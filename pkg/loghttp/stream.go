@@ -0,0 +1,130 @@
+package loghttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/jsonparser"
+)
+
+const (
+	// NDJSONContentType is the content type of query responses streamed as newline delimited JSON.
+	// Each line is a query response holding the next log entries, the last line holds the statistics.
+	NDJSONContentType = "application/x-ndjson"
+	// ProtobufStreamContentType is the content type of query responses streamed as
+	// varint length-delimited protobuf frames.
+	ProtobufStreamContentType = "application/vnd.loki.stream+protobuf"
+)
+
+// StreamContentType returns the streaming content type of the Accept header, or "" if streaming is not requested.
+func StreamContentType(accept string) string {
+	mediaType, _, err := mime.ParseMediaType(accept)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case NDJSONContentType, ProtobufStreamContentType:
+		return mediaType
+	}
+	return ""
+}
+
+// QueryResponseStreamDecoder decodes query responses streamed as newline delimited JSON.
+type QueryResponseStreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewQueryResponseStreamDecoder returns a decoder reading newline delimited JSON query responses from r.
+func NewQueryResponseStreamDecoder(r io.Reader) *QueryResponseStreamDecoder {
+	return &QueryResponseStreamDecoder{r: bufio.NewReader(r)}
+}
+
+// Next returns the next query response of the stream, or io.EOF once the stream is over.
+// A stream that failed on the server side returns its error.
+func (d *QueryResponseStreamDecoder) Next() (*QueryResponse, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if status, _ := jsonparser.GetString(line, "status"); status == QueryStatusError {
+			msg, _ := jsonparser.GetString(line, "error")
+			return nil, fmt.Errorf("query failed: %s", msg)
+		}
+		var resp QueryResponse
+		if err := resp.UnmarshalJSON(line); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+}
+
+// ReadQueryResponseStream reads all query responses of a newline delimited JSON stream
+// and merges them into a single response.
+func ReadQueryResponseStream(r io.Reader) (*QueryResponse, error) {
+	var (
+		dec    = NewQueryResponseStreamDecoder(r)
+		result *QueryResponse
+	)
+	for {
+		resp, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = resp
+			continue
+		}
+
+		if streams, ok := resp.Data.Result.(Streams); ok {
+			merged, _ := result.Data.Result.(Streams)
+			result.Data.Result = append(merged, streams...)
+		}
+		result.Data.Statistics.Merge(resp.Data.Statistics)
+		result.Warnings = append(result.Warnings, resp.Warnings...)
+	}
+	if result == nil {
+		return nil, errors.New("empty query response stream")
+	}
+	return result, nil
+}
+
+// WriteProtobufFrame writes msg to w as a varint length-delimited frame.
+func WriteProtobufFrame(w io.Writer, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(buf, uint64(len(data)))
+	_, err = w.Write(append(buf[:n], data...))
+	return err
+}
+
+// ReadProtobufFrame reads the next varint length-delimited frame of r into msg.
+// It returns io.EOF once the stream is over.
+func ReadProtobufFrame(r *bufio.Reader, msg proto.Message) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
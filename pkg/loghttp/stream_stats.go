@@ -0,0 +1,127 @@
+package loghttp
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	StreamStatsSortByCompressionRatio        = "compression_ratio"
+	StreamStatsSortByCompressedBytes         = "compressed_bytes"
+	StreamStatsSortByUncompressedBytes       = "uncompressed_bytes"
+	StreamStatsSortByEntries                 = "entries"
+	StreamStatsSortByStructuredMetadataShare = "structured_metadata_share"
+	StreamStatsSortByUtilization             = "utilization"
+
+	defaultStreamStatsLimit = 100
+)
+
+// StreamStats describes the in-memory chunks of a single stream held by an ingester.
+type StreamStats struct {
+	Tenant string `json:"tenant"`
+	Stream string `json:"stream"`
+
+	Chunks  int   `json:"chunks"`
+	Entries int64 `json:"entries"`
+	// Utilization is the average utilization of the in-memory chunks.
+	Utilization       float64 `json:"utilization"`
+	CompressedBytes   int64   `json:"compressed_bytes"`
+	UncompressedBytes int64   `json:"uncompressed_bytes"`
+	CompressionRatio  float64 `json:"compression_ratio"`
+
+	// Line and structured metadata bytes accepted by the stream since it was created.
+	LineBytes               int64   `json:"line_bytes"`
+	StructuredMetadataBytes int64   `json:"structured_metadata_bytes"`
+	StructuredMetadataShare float64 `json:"structured_metadata_share"`
+
+	FlushedChunks int            `json:"flushed_chunks"`
+	FlushReasons  map[string]int `json:"flush_reasons,omitempty"`
+
+	// Ingesters is the number of ingesters holding the stream, only set by the querier.
+	Ingesters int `json:"ingesters,omitempty"`
+}
+
+// StreamStatsResponse is the response of the stream stats endpoints.
+type StreamStatsResponse struct {
+	Streams []StreamStats `json:"streams"`
+}
+
+// StreamStatsRequest holds the parameters of a stream stats request.
+type StreamStatsRequest struct {
+	Limit  int
+	SortBy string
+}
+
+// ParseStreamStatsQuery parses a StreamStatsRequest from an http request.
+func ParseStreamStatsQuery(r *http.Request) (*StreamStatsRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	l, err := parseInt(r.Form.Get("limit"), defaultStreamStatsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if l <= 0 {
+		return nil, errors.New("limit must be a positive value")
+	}
+
+	sortBy := r.Form.Get("sort_by")
+	switch sortBy {
+	case "":
+		sortBy = StreamStatsSortByCompressionRatio
+	case StreamStatsSortByCompressionRatio,
+		StreamStatsSortByCompressedBytes,
+		StreamStatsSortByUncompressedBytes,
+		StreamStatsSortByEntries,
+		StreamStatsSortByStructuredMetadataShare,
+		StreamStatsSortByUtilization:
+	default:
+		return nil, fmt.Errorf("invalid sort_by %q", sortBy)
+	}
+
+	return &StreamStatsRequest{Limit: l, SortBy: sortBy}, nil
+}
+
+// TopStreamStats sorts the streams with the worst first and returns at most limit of them.
+// Streams are sorted by ascending compression ratio and utilization, and by descending value otherwise.
+func TopStreamStats(streams []StreamStats, sortBy string, limit int) []StreamStats {
+	less := func(a, b StreamStats) bool {
+		switch sortBy {
+		case StreamStatsSortByCompressedBytes:
+			return a.CompressedBytes > b.CompressedBytes
+		case StreamStatsSortByUncompressedBytes:
+			return a.UncompressedBytes > b.UncompressedBytes
+		case StreamStatsSortByEntries:
+			return a.Entries > b.Entries
+		case StreamStatsSortByStructuredMetadataShare:
+			return a.StructuredMetadataShare > b.StructuredMetadataShare
+		case StreamStatsSortByUtilization:
+			return a.Utilization < b.Utilization
+		default:
+			return a.CompressionRatio < b.CompressionRatio
+		}
+	}
+
+	sort.SliceStable(streams, func(i, j int) bool {
+		if less(streams[i], streams[j]) {
+			return true
+		}
+		if less(streams[j], streams[i]) {
+			return false
+		}
+		// keep the output deterministic for ties.
+		if streams[i].Tenant != streams[j].Tenant {
+			return streams[i].Tenant < streams[j].Tenant
+		}
+		return streams[i].Stream < streams[j].Stream
+	})
+
+	if limit > 0 && len(streams) > limit {
+		streams = streams[:limit]
+	}
+	return streams
+}
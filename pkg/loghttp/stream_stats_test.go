@@ -0,0 +1,57 @@
+package loghttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamStatsQuery(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		expected *StreamStatsRequest
+		err      bool
+	}{
+		{url: "/loki/api/v1/stream_stats", expected: &StreamStatsRequest{Limit: 100, SortBy: StreamStatsSortByCompressionRatio}},
+		{url: "/loki/api/v1/stream_stats?limit=5&sort_by=entries", expected: &StreamStatsRequest{Limit: 5, SortBy: StreamStatsSortByEntries}},
+		{url: "/loki/api/v1/stream_stats?limit=0", err: true},
+		{url: "/loki/api/v1/stream_stats?sort_by=foo", err: true},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+
+			req, err := ParseStreamStatsQuery(r)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, req)
+		})
+	}
+}
+
+func TestTopStreamStats(t *testing.T) {
+	streams := func() []StreamStats {
+		return []StreamStats{
+			{Stream: `{a="1"}`, CompressionRatio: 5, CompressedBytes: 10, StructuredMetadataShare: 0.1},
+			{Stream: `{a="2"}`, CompressionRatio: 1.5, CompressedBytes: 30, StructuredMetadataShare: 0.6},
+			{Stream: `{a="3"}`, CompressionRatio: 3, CompressedBytes: 20, StructuredMetadataShare: 0.6},
+		}
+	}
+
+	names := func(streams []StreamStats) []string {
+		var out []string
+		for _, s := range streams {
+			out = append(out, s.Stream)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{`{a="2"}`, `{a="3"}`}, names(TopStreamStats(streams(), StreamStatsSortByCompressionRatio, 2)))
+	require.Equal(t, []string{`{a="2"}`, `{a="3"}`, `{a="1"}`}, names(TopStreamStats(streams(), StreamStatsSortByCompressedBytes, 0)))
+	// ties are broken by stream labels.
+	require.Equal(t, []string{`{a="2"}`, `{a="3"}`, `{a="1"}`}, names(TopStreamStats(streams(), StreamStatsSortByStructuredMetadataShare, 10)))
+}
@@ -0,0 +1,65 @@
+package loghttp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestStreamContentType(t *testing.T) {
+	require.Equal(t, NDJSONContentType, StreamContentType("application/x-ndjson"))
+	require.Equal(t, NDJSONContentType, StreamContentType("application/x-ndjson; charset=utf-8"))
+	require.Equal(t, ProtobufStreamContentType, StreamContentType(ProtobufStreamContentType))
+	require.Equal(t, "", StreamContentType("application/json"))
+	require.Equal(t, "", StreamContentType(""))
+}
+
+func TestReadQueryResponseStream(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"foo":"bar"},"values":[["2","b"]]}],"stats":{}}}
+{"status":"success","data":{"resultType":"streams","result":[{"stream":{"foo":"bar"},"values":[["1","a"]]}],"stats":{}}}
+{"status":"success","warnings":["slow"],"data":{"resultType":"streams","result":[],"stats":{"summary":{"splits":2}}}}
+`
+	resp, err := ReadQueryResponseStream(strings.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, QueryStatusSuccess, resp.Status)
+	require.Equal(t, []string{"slow"}, resp.Warnings)
+	require.Equal(t, int64(2), resp.Data.Statistics.Summary.Splits)
+
+	streams := resp.Data.Result.(Streams)
+	require.Len(t, streams, 2)
+	require.Equal(t, "b", streams[0].Entries[0].Line)
+	require.Equal(t, "a", streams[1].Entries[0].Line)
+
+	_, err = ReadQueryResponseStream(strings.NewReader(body + `{"status":"error","errorType":"internal","error":"querier failed"}` + "\n"))
+	require.EqualError(t, err, "query failed: querier failed")
+
+	_, err = ReadQueryResponseStream(strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestProtobufFrames(t *testing.T) {
+	var buf bytes.Buffer
+	for _, selector := range []string{`{foo="bar"}`, `{foo="baz"}`} {
+		require.NoError(t, WriteProtobufFrame(&buf, &logproto.QueryRequest{Selector: selector}))
+	}
+
+	r := bufio.NewReader(&buf)
+	var selectors []string
+	for {
+		var req logproto.QueryRequest
+		err := ReadProtobufFrame(r, &req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		selectors = append(selectors, req.Selector)
+	}
+	require.Equal(t, []string{`{foo="bar"}`, `{foo="baz"}`}, selectors)
+}
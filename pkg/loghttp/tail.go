@@ -1,9 +1,11 @@
 package loghttp
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	json "github.com/json-iterator/go"
@@ -104,3 +106,119 @@ func ParseTailQuery(r *http.Request) (*logproto.TailRequest, error) {
 	}
 	return &req, nil
 }
+
+const (
+	// TailEventEntries is the type of the tail v2 events holding a TailResponse.
+	TailEventEntries = "entries"
+	// TailEventError is the type of the tail v2 event sent before the server ends the tail because of an error.
+	TailEventError = "error"
+)
+
+// TailResumeToken identifies the position of a client in a tail, so that it can resume
+// tailing after a disconnect without missing or repeating entries.
+type TailResumeToken struct {
+	// Timestamp of the last entry received by the client.
+	Timestamp time.Time
+	// Entries is the number of entries with exactly Timestamp received by the client.
+	Entries int
+}
+
+// String encodes the token, it is used as the ID of tail v2 events.
+func (t TailResumeToken) String() string {
+	return fmt.Sprintf("%d-%d", t.Timestamp.UnixNano(), t.Entries)
+}
+
+// Advance returns the token following the delivery of an entry with the given timestamp.
+func (t TailResumeToken) Advance(ts time.Time) TailResumeToken {
+	if ts.Equal(t.Timestamp) {
+		return TailResumeToken{Timestamp: t.Timestamp, Entries: t.Entries + 1}
+	}
+	return TailResumeToken{Timestamp: ts, Entries: 1}
+}
+
+// ParseTailResumeToken decodes a token encoded by TailResumeToken.String.
+func ParseTailResumeToken(s string) (TailResumeToken, error) {
+	ts, entries, ok := strings.Cut(s, "-")
+	if !ok {
+		return TailResumeToken{}, fmt.Errorf("invalid resume token %q", s)
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return TailResumeToken{}, fmt.Errorf("invalid resume token %q: %w", s, err)
+	}
+	n, err := strconv.Atoi(entries)
+	if err != nil || n < 0 {
+		return TailResumeToken{}, fmt.Errorf("invalid resume token %q", s)
+	}
+	return TailResumeToken{Timestamp: time.Unix(0, nanos), Entries: n}, nil
+}
+
+// TailEvent is an event of a tail v2 request.
+type TailEvent struct {
+	// ID is the resume token of the client after receiving the event.
+	ID       string
+	Type     string
+	Response TailResponse
+	// Error is set for TailEventError events.
+	Error string
+}
+
+// ParseTailV2Query parses a tail v2 request from an http request.
+// The resume token is read from the `resume_token` parameter or the `Last-Event-ID` header,
+// and when set overrides the start of the request.
+func ParseTailV2Query(r *http.Request) (*logproto.TailRequest, *TailResumeToken, error) {
+	req, err := ParseTailQuery(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := r.Form.Get("resume_token")
+	if token == "" {
+		token = r.Header.Get("Last-Event-ID")
+	}
+	if token == "" {
+		return req, nil, nil
+	}
+
+	resume, err := ParseTailResumeToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Start = resume.Timestamp
+	return req, &resume, nil
+}
+
+// ParseTailV2Request validates a tail v2 request received over gRPC. The query plan is parsed from the query
+// when missing, and the resume token, when set, overrides the start of the request.
+func ParseTailV2Request(r *logproto.TailV2Request) (*logproto.TailRequest, *TailResumeToken, error) {
+	if r.Request == nil {
+		return nil, nil, errors.New("missing tail request")
+	}
+	req := *r.Request
+	if req.Plan == nil {
+		parsed, err := syntax.ParseExpr(req.Query)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Plan = &plan.QueryPlan{AST: parsed}
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultQueryLimit
+	}
+	if req.Start.IsZero() {
+		req.Start = time.Now().Add(-defaultSince)
+	}
+	if req.DelayFor > maxDelayForInTailing {
+		return nil, nil, fmt.Errorf("delay_for can't be greater than %d", maxDelayForInTailing)
+	}
+	if r.ResumeToken == "" {
+		return &req, nil, nil
+	}
+
+	resume, err := ParseTailResumeToken(r.ResumeToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Start = resume.Timestamp
+	return &req, &resume, nil
+}
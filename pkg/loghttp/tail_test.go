@@ -60,3 +60,96 @@ func TestParseTailQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTailV2Query(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name       string
+		r          *http.Request
+		wantStart  time.Time
+		wantResume *TailResumeToken
+		wantErr    bool
+	}{
+		{
+			name:      "no resume token",
+			r:         &http.Request{URL: mustParseURL(`?query={foo="bar"}&start=2017-06-10T21:42:24.760738998Z`)},
+			wantStart: time.Date(2017, 06, 10, 21, 42, 24, 760738998, time.UTC),
+		},
+		{
+			name:       "resume token parameter",
+			r:          &http.Request{URL: mustParseURL(`?query={foo="bar"}&start=2017-06-10T21:42:24.760738998Z&resume_token=2000-3`)},
+			wantStart:  time.Unix(0, 2000),
+			wantResume: &TailResumeToken{Timestamp: time.Unix(0, 2000), Entries: 3},
+		},
+		{
+			name:       "last event id header",
+			r:          &http.Request{URL: mustParseURL(`?query={foo="bar"}&start=2017-06-10T21:42:24.760738998Z`), Header: http.Header{"Last-Event-Id": []string{"2000-1"}}},
+			wantStart:  time.Unix(0, 2000),
+			wantResume: &TailResumeToken{Timestamp: time.Unix(0, 2000), Entries: 1},
+		},
+		{
+			name:    "bad resume token",
+			r:       &http.Request{URL: mustParseURL(`?query={foo="bar"}&resume_token=2000`)},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.r.ParseForm())
+			req, resume, err := ParseTailV2Query(tc.r)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tc.wantStart.Equal(req.Start))
+			require.Equal(t, tc.wantResume, resume)
+		})
+	}
+}
+
+func TestTailResumeToken(t *testing.T) {
+	token := TailResumeToken{Timestamp: time.Unix(0, 10)}
+	token = token.Advance(time.Unix(0, 10))
+	token = token.Advance(time.Unix(0, 10))
+	require.Equal(t, "10-2", token.String())
+	token = token.Advance(time.Unix(0, 20))
+	require.Equal(t, "20-1", token.String())
+
+	parsed, err := ParseTailResumeToken(token.String())
+	require.NoError(t, err)
+	require.Equal(t, token, parsed)
+
+	for _, s := range []string{"", "10", "a-1", "10-a", "10--1"} {
+		_, err := ParseTailResumeToken(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseTailV2Request(t *testing.T) {
+	req, resume, err := ParseTailV2Request(&logproto.TailV2Request{
+		Request:     &logproto.TailRequest{Query: `{foo="bar"}`, Start: time.Unix(0, 1000)},
+		ResumeToken: "2000-3",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, req.Plan)
+	require.Equal(t, uint32(defaultQueryLimit), req.Limit)
+	require.True(t, time.Unix(0, 2000).Equal(req.Start))
+	require.Equal(t, &TailResumeToken{Timestamp: time.Unix(0, 2000), Entries: 3}, resume)
+
+	req, resume, err = ParseTailV2Request(&logproto.TailV2Request{Request: &logproto.TailRequest{Query: `{foo="bar"}`, Limit: 10}})
+	require.NoError(t, err)
+	require.Nil(t, resume)
+	require.Equal(t, uint32(10), req.Limit)
+	require.False(t, req.Start.IsZero())
+
+	for _, r := range []*logproto.TailV2Request{
+		{},
+		{Request: &logproto.TailRequest{Query: `{foo=`}},
+		{Request: &logproto.TailRequest{Query: `{foo="bar"}`, DelayFor: 6}},
+		{Request: &logproto.TailRequest{Query: `{foo="bar"}`}, ResumeToken: "2000"},
+	} {
+		_, _, err := ParseTailV2Request(r)
+		require.Error(t, err)
+	}
+}
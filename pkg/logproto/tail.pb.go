@@ -0,0 +1,962 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: pkg/logproto/tail.proto
+
+package logproto
+
+import (
+	context "context"
+	fmt "fmt"
+	_ "github.com/gogo/protobuf/gogoproto"
+	proto "github.com/gogo/protobuf/proto"
+	_ "github.com/grafana/loki/pkg/push"
+	github_com_grafana_loki_pkg_push "github.com/grafana/loki/pkg/push"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+	reflect "reflect"
+	strings "strings"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type TailV2Request struct {
+	Request *TailRequest `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	// resumeToken is the resumeToken of the last response received by the client,
+	// set to resume tailing after a disconnect. It overrides the start of the request.
+	ResumeToken string `protobuf:"bytes,2,opt,name=resumeToken,proto3" json:"resumeToken,omitempty"`
+}
+
+func (m *TailV2Request) Reset()      { *m = TailV2Request{} }
+func (*TailV2Request) ProtoMessage() {}
+func (*TailV2Request) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f1bc591f0093dbf3, []int{0}
+}
+func (m *TailV2Request) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TailV2Request) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TailV2Request.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TailV2Request) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TailV2Request.Merge(m, src)
+}
+func (m *TailV2Request) XXX_Size() int {
+	return m.Size()
+}
+func (m *TailV2Request) XXX_DiscardUnknown() {
+	xxx_messageInfo_TailV2Request.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TailV2Request proto.InternalMessageInfo
+
+func (m *TailV2Request) GetRequest() *TailRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *TailV2Request) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+type TailV2Response struct {
+	Streams        []github_com_grafana_loki_pkg_push.Stream `protobuf:"bytes,1,rep,name=streams,proto3,customtype=github.com/grafana/loki/pkg/push.Stream" json:"streams"`
+	DroppedStreams []*DroppedStream                          `protobuf:"bytes,2,rep,name=droppedStreams,proto3" json:"droppedStreams,omitempty"`
+	// resumeToken identifies the position of the client once it received the response.
+	ResumeToken string `protobuf:"bytes,3,opt,name=resumeToken,proto3" json:"resumeToken,omitempty"`
+}
+
+func (m *TailV2Response) Reset()      { *m = TailV2Response{} }
+func (*TailV2Response) ProtoMessage() {}
+func (*TailV2Response) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f1bc591f0093dbf3, []int{1}
+}
+func (m *TailV2Response) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TailV2Response) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TailV2Response.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TailV2Response) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TailV2Response.Merge(m, src)
+}
+func (m *TailV2Response) XXX_Size() int {
+	return m.Size()
+}
+func (m *TailV2Response) XXX_DiscardUnknown() {
+	xxx_messageInfo_TailV2Response.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TailV2Response proto.InternalMessageInfo
+
+func (m *TailV2Response) GetDroppedStreams() []*DroppedStream {
+	if m != nil {
+		return m.DroppedStreams
+	}
+	return nil
+}
+
+func (m *TailV2Response) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*TailV2Request)(nil), "logproto.TailV2Request")
+	proto.RegisterType((*TailV2Response)(nil), "logproto.TailV2Response")
+}
+
+func init() { proto.RegisterFile("pkg/logproto/tail.proto", fileDescriptor_f1bc591f0093dbf3) }
+
+var fileDescriptor_f1bc591f0093dbf3 = []byte{
+	// 354 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x52, 0xbd, 0x4e, 0x2a, 0x41,
+	0x18, 0x9d, 0x81, 0x1b, 0xb8, 0x77, 0xc8, 0xa5, 0x58, 0x35, 0x6c, 0x30, 0x19, 0x36, 0x34, 0x52,
+	0x31, 0x66, 0xa9, 0x8d, 0x91, 0x98, 0xd8, 0x2f, 0xc4, 0xc2, 0xc4, 0x62, 0x56, 0xc6, 0x65, 0xc3,
+	0xc2, 0x8c, 0x33, 0xbb, 0xd6, 0x3e, 0x82, 0x8f, 0xe1, 0xa3, 0x50, 0xd2, 0x49, 0x2c, 0x88, 0x0c,
+	0x8d, 0x25, 0x8f, 0x60, 0xf6, 0x0f, 0xf9, 0x89, 0xcd, 0xee, 0xc9, 0x77, 0xce, 0xf7, 0x9d, 0x9c,
+	0x93, 0x41, 0x35, 0x31, 0xf2, 0x48, 0xc0, 0x3d, 0x21, 0x79, 0xc8, 0x49, 0x48, 0xfd, 0xa0, 0x9d,
+	0x40, 0xe3, 0x6f, 0x3e, 0xac, 0x1f, 0x7b, 0xdc, 0xe3, 0x29, 0x1f, 0xa3, 0x94, 0xaf, 0x9f, 0xee,
+	0x2c, 0xe6, 0x20, 0x23, 0x8f, 0x62, 0x52, 0x44, 0x6a, 0x98, 0x7c, 0xd2, 0x61, 0xd3, 0x45, 0xff,
+	0xfb, 0xd4, 0x0f, 0x6e, 0x6d, 0x87, 0x3d, 0x45, 0x4c, 0x85, 0x06, 0x41, 0x65, 0x99, 0x42, 0x13,
+	0x5a, 0xb0, 0x55, 0xb1, 0x4f, 0xda, 0x9b, 0x3b, 0xb1, 0x32, 0xd3, 0x39, 0xb9, 0xca, 0xb0, 0x50,
+	0x45, 0x32, 0x15, 0x8d, 0x59, 0x9f, 0x8f, 0xd8, 0xc4, 0x2c, 0x58, 0xb0, 0xf5, 0xcf, 0xd9, 0x1e,
+	0x35, 0xdf, 0x21, 0xaa, 0xe6, 0x26, 0x4a, 0xf0, 0x89, 0x62, 0x86, 0x8b, 0xca, 0x2a, 0x94, 0x8c,
+	0x8e, 0x95, 0x09, 0xad, 0x62, 0xab, 0x62, 0xd7, 0x7e, 0x5c, 0x7a, 0x09, 0x71, 0x35, 0xa0, 0x22,
+	0x64, 0xb2, 0x4b, 0xa6, 0x8b, 0x06, 0xf8, 0x58, 0x34, 0xce, 0x3c, 0x3f, 0x1c, 0x46, 0x6e, 0xfb,
+	0x81, 0x8f, 0x89, 0x27, 0xe9, 0x23, 0x9d, 0x50, 0x12, 0xf0, 0x91, 0x4f, 0xf2, 0x54, 0xd9, 0x9e,
+	0x93, 0x1f, 0x36, 0x2e, 0x51, 0x75, 0x20, 0xb9, 0x10, 0x6c, 0xd0, 0xcb, 0xac, 0x0a, 0xfb, 0x56,
+	0xd7, 0xdb, 0xbc, 0xb3, 0x27, 0xdf, 0x4f, 0x56, 0x3c, 0x48, 0x66, 0xdf, 0xa0, 0x52, 0x1a, 0xcc,
+	0xb8, 0x40, 0x7f, 0x62, 0x64, 0xd4, 0x76, 0xdb, 0xda, 0xf4, 0x5a, 0x37, 0x0f, 0x89, 0xb4, 0x8b,
+	0x26, 0x38, 0x87, 0xdd, 0xfb, 0xd9, 0x12, 0x83, 0xf9, 0x12, 0x83, 0xf5, 0x12, 0xc3, 0x17, 0x8d,
+	0xe1, 0x9b, 0xc6, 0x70, 0xaa, 0x31, 0x9c, 0x69, 0x0c, 0x3f, 0x35, 0x86, 0x5f, 0x1a, 0x83, 0xb5,
+	0xc6, 0xf0, 0x75, 0x85, 0xc1, 0x6c, 0x85, 0xc1, 0x7c, 0x85, 0xc1, 0xdd, 0xaf, 0x9d, 0x3c, 0x77,
+	0xc8, 0xf6, 0x4b, 0x70, 0x4b, 0xc9, 0xaf, 0xf3, 0x3d, 0x00, 0xa3, 0x50, 0x7e, 0x9a, 0x59, 0x02,
+	0x00, 0x00,
+}
+
+func (this *TailV2Request) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*TailV2Request)
+	if !ok {
+		that2, ok := that.(TailV2Request)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Request.Equal(that1.Request) {
+		return false
+	}
+	if this.ResumeToken != that1.ResumeToken {
+		return false
+	}
+	return true
+}
+func (this *TailV2Response) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*TailV2Response)
+	if !ok {
+		that2, ok := that.(TailV2Response)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Streams) != len(that1.Streams) {
+		return false
+	}
+	for i := range this.Streams {
+		if !this.Streams[i].Equal(that1.Streams[i]) {
+			return false
+		}
+	}
+	if len(this.DroppedStreams) != len(that1.DroppedStreams) {
+		return false
+	}
+	for i := range this.DroppedStreams {
+		if !this.DroppedStreams[i].Equal(that1.DroppedStreams[i]) {
+			return false
+		}
+	}
+	if this.ResumeToken != that1.ResumeToken {
+		return false
+	}
+	return true
+}
+func (this *TailV2Request) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&logproto.TailV2Request{")
+	if this.Request != nil {
+		s = append(s, "Request: "+fmt.Sprintf("%#v", this.Request)+",\n")
+	}
+	s = append(s, "ResumeToken: "+fmt.Sprintf("%#v", this.ResumeToken)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *TailV2Response) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&logproto.TailV2Response{")
+	s = append(s, "Streams: "+fmt.Sprintf("%#v", this.Streams)+",\n")
+	if this.DroppedStreams != nil {
+		s = append(s, "DroppedStreams: "+fmt.Sprintf("%#v", this.DroppedStreams)+",\n")
+	}
+	s = append(s, "ResumeToken: "+fmt.Sprintf("%#v", this.ResumeToken)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringTail(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// TailV2Client is the client API for TailV2 service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type TailV2Client interface {
+	Tail(ctx context.Context, in *TailV2Request, opts ...grpc.CallOption) (TailV2_TailClient, error)
+}
+
+type tailV2Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewTailV2Client(cc *grpc.ClientConn) TailV2Client {
+	return &tailV2Client{cc}
+}
+
+func (c *tailV2Client) Tail(ctx context.Context, in *TailV2Request, opts ...grpc.CallOption) (TailV2_TailClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TailV2_serviceDesc.Streams[0], "/logproto.TailV2/Tail", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tailV2TailClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TailV2_TailClient interface {
+	Recv() (*TailV2Response, error)
+	grpc.ClientStream
+}
+
+type tailV2TailClient struct {
+	grpc.ClientStream
+}
+
+func (x *tailV2TailClient) Recv() (*TailV2Response, error) {
+	m := new(TailV2Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TailV2Server is the server API for TailV2 service.
+type TailV2Server interface {
+	Tail(*TailV2Request, TailV2_TailServer) error
+}
+
+// UnimplementedTailV2Server can be embedded to have forward compatible implementations.
+type UnimplementedTailV2Server struct {
+}
+
+func (*UnimplementedTailV2Server) Tail(req *TailV2Request, srv TailV2_TailServer) error {
+	return status.Errorf(codes.Unimplemented, "method Tail not implemented")
+}
+
+func RegisterTailV2Server(s *grpc.Server, srv TailV2Server) {
+	s.RegisterService(&_TailV2_serviceDesc, srv)
+}
+
+func _TailV2_Tail_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TailV2Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TailV2Server).Tail(m, &tailV2TailServer{stream})
+}
+
+type TailV2_TailServer interface {
+	Send(*TailV2Response) error
+	grpc.ServerStream
+}
+
+type tailV2TailServer struct {
+	grpc.ServerStream
+}
+
+func (x *tailV2TailServer) Send(m *TailV2Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _TailV2_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "logproto.TailV2",
+	HandlerType: (*TailV2Server)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Tail",
+			Handler:       _TailV2_Tail_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/logproto/tail.proto",
+}
+
+func (m *TailV2Request) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TailV2Request) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TailV2Request) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.ResumeToken) > 0 {
+		i -= len(m.ResumeToken)
+		copy(dAtA[i:], m.ResumeToken)
+		i = encodeVarintTail(dAtA, i, uint64(len(m.ResumeToken)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Request != nil {
+		{
+			size, err := m.Request.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTail(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TailV2Response) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TailV2Response) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TailV2Response) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.ResumeToken) > 0 {
+		i -= len(m.ResumeToken)
+		copy(dAtA[i:], m.ResumeToken)
+		i = encodeVarintTail(dAtA, i, uint64(len(m.ResumeToken)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.DroppedStreams) > 0 {
+		for iNdEx := len(m.DroppedStreams) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.DroppedStreams[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTail(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Streams) > 0 {
+		for iNdEx := len(m.Streams) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Streams[iNdEx].Size()
+				i -= size
+				if _, err := m.Streams[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintTail(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintTail(dAtA []byte, offset int, v uint64) int {
+	offset -= sovTail(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *TailV2Request) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Request != nil {
+		l = m.Request.Size()
+		n += 1 + l + sovTail(uint64(l))
+	}
+	l = len(m.ResumeToken)
+	if l > 0 {
+		n += 1 + l + sovTail(uint64(l))
+	}
+	return n
+}
+
+func (m *TailV2Response) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Streams) > 0 {
+		for _, e := range m.Streams {
+			l = e.Size()
+			n += 1 + l + sovTail(uint64(l))
+		}
+	}
+	if len(m.DroppedStreams) > 0 {
+		for _, e := range m.DroppedStreams {
+			l = e.Size()
+			n += 1 + l + sovTail(uint64(l))
+		}
+	}
+	l = len(m.ResumeToken)
+	if l > 0 {
+		n += 1 + l + sovTail(uint64(l))
+	}
+	return n
+}
+
+func sovTail(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozTail(x uint64) (n int) {
+	return sovTail(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *TailV2Request) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&TailV2Request{`,
+		`Request:` + strings.Replace(fmt.Sprintf("%v", this.Request), "TailRequest", "TailRequest", 1) + `,`,
+		`ResumeToken:` + fmt.Sprintf("%v", this.ResumeToken) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *TailV2Response) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForDroppedStreams := "[]*DroppedStream{"
+	for _, f := range this.DroppedStreams {
+		repeatedStringForDroppedStreams += strings.Replace(fmt.Sprintf("%v", f), "DroppedStream", "DroppedStream", 1) + ","
+	}
+	repeatedStringForDroppedStreams += "}"
+	s := strings.Join([]string{`&TailV2Response{`,
+		`Streams:` + fmt.Sprintf("%v", this.Streams) + `,`,
+		`DroppedStreams:` + repeatedStringForDroppedStreams + `,`,
+		`ResumeToken:` + fmt.Sprintf("%v", this.ResumeToken) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringTail(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *TailV2Request) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTail
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TailV2Request: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TailV2Request: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Request", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTail
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTail
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Request == nil {
+				m.Request = &TailRequest{}
+			}
+			if err := m.Request.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResumeToken", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTail
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTail
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ResumeToken = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTail(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTail
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTail
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TailV2Response) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTail
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TailV2Response: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TailV2Response: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Streams", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTail
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTail
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Streams = append(m.Streams, github_com_grafana_loki_pkg_push.Stream{})
+			if err := m.Streams[len(m.Streams)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DroppedStreams", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTail
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTail
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DroppedStreams = append(m.DroppedStreams, &DroppedStream{})
+			if err := m.DroppedStreams[len(m.DroppedStreams)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResumeToken", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTail
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTail
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ResumeToken = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTail(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthTail
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthTail
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipTail(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowTail
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowTail
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthTail
+			}
+			iNdEx += length
+			if iNdEx < 0 {
+				return 0, ErrInvalidLengthTail
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowTail
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipTail(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+				if iNdEx < 0 {
+					return 0, ErrInvalidLengthTail
+				}
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthTail = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowTail   = fmt.Errorf("proto: integer overflow")
+)
@@ -0,0 +1,42 @@
+//go:build linux
+
+package logql
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cpuTimer measures the CPU time of the goroutine evaluating a query.
+// The goroutine is locked to its thread until the timer is stopped, so that the CPU time of the thread is the CPU
+// time of the goroutine, including the garbage collection work assisting its allocations.
+type cpuTimer struct {
+	start time.Duration
+	err   error
+}
+
+func startCPUTimer() cpuTimer {
+	runtime.LockOSThread()
+	start, err := threadCPUTime()
+	return cpuTimer{start: start, err: err}
+}
+
+// Stop unlocks the goroutine from its thread and returns the CPU time spent since the timer started.
+func (t cpuTimer) Stop() time.Duration {
+	defer runtime.UnlockOSThread()
+	end, err := threadCPUTime()
+	if t.err != nil || err != nil {
+		return 0
+	}
+	return end - t.start
+}
+
+func threadCPUTime() (time.Duration, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_THREAD_CPUTIME_ID, &ts); err != nil {
+		return 0, err
+	}
+	return time.Duration(ts.Nano()), nil
+}
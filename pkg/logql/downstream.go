@@ -385,6 +385,38 @@ func (e *CountMinSketchEvalExpr) Walk(f syntax.WalkFn) {
 	}
 }
 
+// ShardedTopKExpr evaluates `topk(k, sum by (<labels>) (<range aggregation>))` over shards in two phases:
+// the local topk of each shard, then the exact sum of the candidate series, see newShardedTopKStepEvaluator.
+type ShardedTopKExpr struct {
+	syntax.SampleExpr
+	k           int
+	inner       *syntax.VectorAggregationExpr
+	downstreams []DownstreamSampleExpr
+}
+
+func (e ShardedTopKExpr) String() string {
+	var sb strings.Builder
+	for i, d := range e.downstreams {
+		if i >= defaultMaxDepth {
+			break
+		}
+
+		if i > 0 {
+			sb.WriteString(" ++ ")
+		}
+
+		sb.WriteString(d.String())
+	}
+	return fmt.Sprintf("ShardedTopK<%d, %s>", e.k, sb.String())
+}
+
+func (e *ShardedTopKExpr) Walk(f syntax.WalkFn) {
+	f(e)
+	for _, d := range e.downstreams {
+		d.Walk(f)
+	}
+}
+
 type Downstreamable interface {
 	Downstreamer(context.Context) Downstreamer
 }
@@ -660,6 +692,8 @@ func (ev *DownstreamEvaluator) NewStepEvaluator(
 			return nil, fmt.Errorf("unexpected matrix type: got (%T), want (CountMinSketchVector)", results[0].Data)
 		}
 		return NewCountMinSketchVectorStepEvaluator(vector), nil
+	case *ShardedTopKExpr:
+		return ev.newShardedTopKStepEvaluator(ctx, nextEvFactory, e, params)
 	default:
 		return ev.defaultEvaluator.NewStepEvaluator(ctx, nextEvFactory, e, params)
 	}
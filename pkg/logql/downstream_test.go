@@ -403,6 +403,148 @@ func TestApproxTopkSketches(t *testing.T) {
 	}
 }
 
+func TestShardedTopK(t *testing.T) {
+	var (
+		shards = 4
+		rounds = 10
+		query  = `topk(3, sum by (client) (count_over_time({app="foo"} | logfmt [1s])))`
+	)
+
+	// podsByShard returns a pod name for each shard.
+	podsByShard := func() []string {
+		pods := make([]string, shards)
+		for i, found := 0, 0; found < shards; i++ {
+			pod := fmt.Sprintf("pod-%d", i)
+			shard := labels.FromStrings("app", "foo", "pod", pod).Hash() % uint64(shards)
+			if pods[shard] == "" {
+				pods[shard] = pod
+				found++
+			}
+		}
+		return pods
+	}
+
+	for _, tc := range []struct {
+		name string
+		// counts returns the number of lines per second of each client of each pod.
+		counts func() map[string]map[string]int
+	}{
+		{
+			// every pod has the same clients, the local topk are the global topk.
+			name: "uniform",
+			counts: func() map[string]map[string]int {
+				counts := map[string]map[string]int{}
+				for p := 0; p < 8; p++ {
+					clients := map[string]int{}
+					for c := 0; c < 10; c++ {
+						clients[fmt.Sprintf("client-%d", c)] = c + 1
+					}
+					counts[fmt.Sprintf("pod-%d", p)] = clients
+				}
+				return counts
+			},
+		},
+		{
+			// the largest client is never part of a local topk and requires merging all the series.
+			name: "spread",
+			counts: func() map[string]map[string]int {
+				counts := map[string]map[string]int{}
+				for s, pod := range podsByShard() {
+					clients := map[string]int{"spread": 19}
+					for c := 0; c < 5; c++ {
+						clients[fmt.Sprintf("%s-client-%d", pod, c)] = 20 + 5*s + c
+					}
+					counts[pod] = clients
+				}
+				return counts
+			},
+		},
+	} {
+		var streams []logproto.Stream
+		for pod, clients := range tc.counts() {
+			ls := labels.FromStrings("app", "foo", "pod", pod)
+			stream := logproto.Stream{Labels: ls.String(), Hash: ls.Hash()}
+			for j := 0; j <= rounds; j++ {
+				var lines []string
+				for client, n := range clients {
+					for i := 0; i < n; i++ {
+						lines = append(lines, fmt.Sprintf("client=%s", client))
+					}
+				}
+				for i, line := range lines {
+					stream.Entries = append(stream.Entries, logproto.Entry{
+						Timestamp: time.Unix(int64(j), int64(i+1)),
+						Line:      line,
+					})
+				}
+			}
+			streams = append(streams, stream)
+		}
+
+		q := NewMockQuerier(shards, streams)
+		opts := EngineOpts{}
+		regular := NewEngine(opts, q, NoLimits, log.NewNopLogger())
+		sharded := NewDownstreamEngine(opts, MockDownstreamer{regular}, NoLimits, log.NewNopLogger())
+
+		for _, instant := range []bool{false, true} {
+			start, end, step := time.Unix(1, 0), time.Unix(int64(rounds), 0), time.Second
+			name := tc.name + "_range"
+			if instant {
+				start, step = end, 0
+				name = tc.name + "_instant"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				params, err := NewLiteralParams(query, start, end, step, 0, logproto.FORWARD, 100, nil, nil)
+				require.NoError(t, err)
+				ctx := user.InjectOrgID(context.Background(), "fake")
+
+				mapper := NewShardMapper(NewPowerOfTwoStrategy(ConstantShards(shards)), nilShardMetrics, []string{ShardTopK})
+				_, _, mapped, err := mapper.Parse(params.GetExpression())
+				require.NoError(t, err)
+				require.IsType(t, &ShardedTopKExpr{}, mapped)
+
+				res, err := regular.Query(params).Exec(ctx)
+				require.NoError(t, err)
+
+				shardedRes, err := sharded.Query(ctx, ParamsWithExpressionOverride{Params: params, ExpressionOverride: mapped}).Exec(ctx)
+				require.NoError(t, err)
+
+				require.Equal(t, res.Data, shardedRes.Data)
+			})
+		}
+	}
+}
+
+func TestTopkOfCandidates(t *testing.T) {
+	a, b, c := labels.FromStrings("client", "a"), labels.FromStrings("client", "b"), labels.FromStrings("client", "c")
+	sample := func(ls labels.Labels, f float64) promql.Sample {
+		return promql.Sample{T: 1000, F: f, Metric: ls}
+	}
+
+	// local top2 of each shard: a and b on the first one, a and c on the second one.
+	candidates, thresholds := topkCandidates([][]promql.Vector{
+		{{sample(a, 10), sample(b, 5)}},
+		{{sample(a, 8), sample(c, 6)}},
+	}, 2)
+	require.Equal(t, map[uint64]labels.Labels{a.Hash(): a, b.Hash(): b, c.Hash(): c}, candidates)
+	require.Equal(t, []float64{11}, thresholds)
+
+	// b and c are both below the threshold: an other series could be in the top2.
+	_, ok := topkOfCandidates([][]promql.Vector{
+		{{sample(a, 10), sample(b, 5), sample(c, 1)}},
+		{{sample(a, 8), sample(b, 2), sample(c, 6)}},
+	}, candidates, thresholds, 2)
+	require.False(t, ok)
+
+	steps, ok := topkOfCandidates([][]promql.Vector{
+		{{sample(a, 10), sample(b, 5), sample(c, 6)}},
+		{{sample(a, 8), sample(b, 2), sample(c, 6)}},
+	}, candidates, thresholds, 2)
+	require.True(t, ok)
+	require.Equal(t, []promql.Vector{{sample(a, 18), sample(c, 12)}}, steps)
+}
+
 func TestShardCounter(t *testing.T) {
 	var (
 		shards   = 3
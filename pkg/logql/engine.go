@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unsafe"
 
 	"github.com/opentracing/opentracing-go"
 
@@ -205,6 +206,12 @@ type Query interface {
 	Exec(ctx context.Context) (logqlmodel.Result, error)
 }
 
+// Explainer is a query whose evaluation can be explained.
+type Explainer interface {
+	// Explain returns the tree of the evaluation of the query.
+	Explain(ctx context.Context) (string, error)
+}
+
 type query struct {
 	logger       log.Logger
 	params       Params
@@ -230,6 +237,37 @@ func (q *query) resultLength(res promql_parser.Value) int {
 	}
 }
 
+// resultBytes returns the approximate bytes held in memory by the result of a query.
+func resultBytes(res promql_parser.Value) int64 {
+	var size int
+	switch r := res.(type) {
+	case promql.Vector:
+		for _, s := range r {
+			size += labelsBytes(s.Metric) + int(unsafe.Sizeof(promql.FPoint{}))
+		}
+	case promql.Matrix:
+		for _, s := range r {
+			size += labelsBytes(s.Metric) + len(s.Floats)*int(unsafe.Sizeof(promql.FPoint{}))
+		}
+	case logqlmodel.Streams:
+		for _, s := range r {
+			size += len(s.Labels)
+			for _, e := range s.Entries {
+				size += e.Size()
+			}
+		}
+	}
+	return int64(size)
+}
+
+func labelsBytes(lbls labels.Labels) int {
+	var size int
+	lbls.Range(func(l labels.Label) {
+		size += len(l.Name) + len(l.Value)
+	})
+	return size
+}
+
 // Exec Implements `Query`. It handles instrumentation & defers to Eval.
 func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "query.Exec")
@@ -271,13 +309,21 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 	start := time.Now()
 	statsCtx, ctx := stats.NewContext(ctx)
 	metadataCtx, ctx := metadata.NewContext(ctx)
+	optimizerCtx, ctx := newOptimizerContext(ctx)
 
+	cpuTimer := startCPUTimer()
 	data, err := q.Eval(ctx)
+	statsCtx.AddCPUTime(cpuTimer.Stop())
+	statsCtx.RetainBytes(resultBytes(data))
 
 	queueTime, _ := ctx.Value(httpreq.QueryQueueTimeHTTPHeader).(time.Duration)
 
 	statResult := statsCtx.Result(time.Since(start), queueTime, q.resultLength(data))
 	sp.LogKV(statResult.KVList()...)
+	if rewrites := optimizerCtx.Rewrites(); len(rewrites) > 0 {
+		sp.LogKV("optimizations", rewriteRules(rewrites))
+		statResult.Summary.Merge(stats.Summary{OptimizerRewrites: rewriteCounts(rewrites)})
+	}
 
 	status, _ := server.ClientHTTPStatusAndError(err)
 
@@ -310,6 +356,12 @@ func (q *query) Eval(ctx context.Context) (promql_parser.Value, error) {
 		return value, err
 
 	case syntax.LogSelectorExpr:
+		e, rewrites, err := optimizeLogSelectorExpr(e)
+		if err != nil {
+			return nil, err
+		}
+		recordRewrites(ctx, rewrites)
+
 		itr, err := q.evaluator.NewIterator(ctx, e, q.params)
 		if err != nil {
 			return nil, err
@@ -341,6 +393,70 @@ func (q *query) checkBlocked(ctx context.Context, tenants []string) bool {
 	return false
 }
 
+// rewriteSample checks the interval of expr and returns expr rewritten by the rollups and the optimizer.
+func (q *query) rewriteSample(ctx context.Context, tenantIDs []string, expr syntax.SampleExpr) (syntax.SampleExpr, []Rewrite, error) {
+	maxIntervalCapture := func(id string) time.Duration { return q.limits.MaxQueryRange(ctx, id) }
+	maxQueryInterval := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, maxIntervalCapture)
+	if maxQueryInterval != 0 {
+		if err := q.checkIntervalLimit(expr, maxQueryInterval); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var rewrites []Rewrite
+	// rollups are computed by tenant so they are only used by queries of a single tenant.
+	if len(tenantIDs) == 1 {
+		var err error
+		expr, rewrites, err = rewriteRollups(expr, q.limits.RollupRules(ctx, tenantIDs[0]), q.params.Start(), q.params.End(), q.params.Step(), time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	expr, optimized, err := optimizeSampleExpr(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expr, append(rewrites, optimized...), nil
+}
+
+// Explain returns the tree of the rewrites applied to the query and of the step evaluators of a metric query.
+// Log queries are not evaluated by step evaluators, only their rewrites are returned.
+func (q *query) Explain(ctx context.Context) (string, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tree := NewTree()
+	root := tree.Child(q.params.QueryString())
+	switch e := q.params.GetExpression().(type) {
+	case *syntax.LiteralExpr, *syntax.VectorExpr:
+	case syntax.SampleExpr:
+		expr, rewrites, err := q.rewriteSample(ctx, tenantIDs, e)
+		if err != nil {
+			return "", err
+		}
+		stepEvaluator, err := q.evaluator.NewStepEvaluator(ctx, q.evaluator, expr, q.params)
+		if err != nil {
+			return "", err
+		}
+		defer util.LogErrorWithContext(ctx, "closing SampleExpr", stepEvaluator.Close)
+
+		ExplainRewrites(root, rewrites)
+		stepEvaluator.Explain(root)
+	case syntax.LogSelectorExpr:
+		_, rewrites, err := optimizeLogSelectorExpr(e)
+		if err != nil {
+			return "", err
+		}
+		ExplainRewrites(root, rewrites)
+	default:
+		return "", fmt.Errorf("unexpected type (%T): cannot explain", e)
+	}
+	return tree.String(), nil
+}
+
 // evalSample evaluate a sampleExpr
 func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_parser.Value, error) {
 	if lit, ok := expr.(*syntax.LiteralExpr); ok {
@@ -355,19 +471,11 @@ func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_
 		return nil, err
 	}
 
-	maxIntervalCapture := func(id string) time.Duration { return q.limits.MaxQueryRange(ctx, id) }
-	maxQueryInterval := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, maxIntervalCapture)
-	if maxQueryInterval != 0 {
-		err = q.checkIntervalLimit(expr, maxQueryInterval)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	expr, err = optimizeSampleExpr(expr)
+	expr, rewrites, err := q.rewriteSample(ctx, tenantIDs, expr)
 	if err != nil {
 		return nil, err
 	}
+	recordRewrites(ctx, rewrites)
 
 	stepEvaluator, err := q.evaluator.NewStepEvaluator(ctx, q.evaluator, expr, q.params)
 	if err != nil {
@@ -2276,6 +2276,7 @@ type statsQuerier struct{}
 func (statsQuerier) SelectLogs(ctx context.Context, _ SelectLogParams) (iter.EntryIterator, error) {
 	st := stats.FromContext(ctx)
 	st.AddDecompressedBytes(1)
+	st.RetainBytes(10)
 	return iter.NoopEntryIterator, nil
 }
 
@@ -2299,6 +2300,22 @@ func TestEngine_Stats(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, int64(1), r.Statistics.TotalDecompressedBytes())
 	require.Equal(t, queueTime.Seconds(), r.Statistics.Summary.QueueTime)
+	require.Equal(t, int64(10), r.Statistics.Summary.PeakMemoryBytes)
+}
+
+func TestResultBytes(t *testing.T) {
+	require.Equal(t, int64(0), resultBytes(promql.Scalar{}))
+	require.Equal(t, int64(2*(len("app")+len("foo")+16)), resultBytes(promql.Vector{
+		{Metric: labels.FromStrings("app", "foo"), T: 1, F: 1},
+		{Metric: labels.FromStrings("app", "bar")},
+	}))
+	require.Equal(t, int64(len("app")+len("foo")+2*16), resultBytes(promql.Matrix{
+		{Metric: labels.FromStrings("app", "foo"), Floats: []promql.FPoint{{T: 1, F: 1}, {T: 2, F: 2}}},
+	}))
+	entry := logproto.Entry{Timestamp: time.Unix(0, 1), Line: "line"}
+	require.Equal(t, int64(len(`{app="foo"}`)+entry.Size()), resultBytes(logqlmodel.Streams{
+		{Labels: `{app="foo"}`, Entries: []logproto.Entry{entry}},
+	}))
 }
 
 type metaQuerier struct{}
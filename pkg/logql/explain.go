@@ -74,3 +74,14 @@ func (e *CountMinSketchVectorStepEvaluator) Explain(parent Node) {
 func (EmptyEvaluator[SampleVector]) Explain(parent Node) {
 	parent.Child("Empty")
 }
+
+// ExplainRewrites adds the rewrites applied by the optimizer to the explain tree.
+func ExplainRewrites(parent Node, rewrites []Rewrite) {
+	if len(rewrites) == 0 {
+		return
+	}
+	b := parent.Child("Optimizations")
+	for _, r := range rewrites {
+		b.Childf("%s: %s => %s", r.Rule, r.Before, r.After)
+	}
+}
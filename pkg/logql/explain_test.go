@@ -53,3 +53,47 @@ func TestExplain(t *testing.T) {
 `
 	require.Equal(t, expected, tree.String())
 }
+
+func TestQueryExplain(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "fake")
+	engine := NewEngine(EngineOpts{}, NewMockQuerier(1, nil), NoLimits, log.NewNopLogger())
+
+	for _, tt := range []struct {
+		query    string
+		expected string
+	}{
+		{
+			`sum by (level) (count_over_time({app="foo"} | json | level="error" |= "x"[5m]))`,
+			`sum by (level) (count_over_time({app="foo"} | json | level="error" |= "x"[5m]))
+ ├── Optimizations
+ │    ├── push_line_filters: {app="foo"} | json | level="error" |= "x" => {app="foo"} |= "x" | json | level="error"
+ │    └── narrow_json: {app="foo"} |= "x" | json | level="error" => {app="foo"} |= "x" | json level="level" | level="error"
+ └── [sum,  by (level)] VectorAgg
+      └── RangeVectorAgg
+`,
+		},
+		{
+			`{app="foo"} |~ ".*timeout.*"`,
+			`{app="foo"} |~ ".*timeout.*"
+ └── Optimizations
+      └── regexp_to_substring: {app="foo"} |~ ".*timeout.*" => {app="foo"} |= "timeout"
+`,
+		},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			q := engine.Query(LiteralParams{
+				queryString: tt.query,
+				queryExpr:   syntax.MustParseExpr(tt.query),
+				start:       time.Unix(60, 0),
+				end:         time.Unix(60, 0),
+				limit:       1000,
+			})
+			explainer, ok := q.(Explainer)
+			require.True(t, ok)
+
+			explained, err := explainer.Explain(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, explained)
+		})
+	}
+}
@@ -19,12 +19,14 @@ type Limits interface {
 	MaxQueryRange(ctx context.Context, userID string) time.Duration
 	QueryTimeout(context.Context, string) time.Duration
 	BlockedQueries(context.Context, string) []*validation.BlockedQuery
+	RollupRules(context.Context, string) []*validation.RollupRule
 }
 
 type fakeLimits struct {
 	maxSeries      int
 	timeout        time.Duration
 	blockedQueries []*validation.BlockedQuery
+	rollupRules    []*validation.RollupRule
 	rangeLimit     time.Duration
 	requiredLabels []string
 }
@@ -45,6 +47,10 @@ func (f fakeLimits) BlockedQueries(_ context.Context, _ string) []*validation.Bl
 	return f.blockedQueries
 }
 
+func (f fakeLimits) RollupRules(_ context.Context, _ string) []*validation.RollupRule {
+	return f.rollupRules
+}
+
 func (f fakeLimits) RequiredLabels(_ context.Context, _ string) []string {
 	return f.requiredLabels
 }
@@ -92,6 +92,11 @@ func (p *Hints) NoLabels() bool {
 	return p.noLabels || p.AllRequiredExtracted()
 }
 
+// RequiredLabels returns the labels required by the query, or nil if all labels are required.
+func (p *Hints) RequiredLabels() []string {
+	return p.requiredLabels
+}
+
 func (p *Hints) RecordExtracted(key string) {
 	p.extracted = append(p.extracted, key)
 }
@@ -221,6 +221,10 @@ func RecordRangeAndInstantQueryMetrics(
 
 	logValues = append(logValues, tagsToKeyValues(queryTags)...)
 
+	if rewrites := RewritesFromContext(ctx); len(rewrites) > 0 {
+		logValues = append(logValues, "optimizations", rewriteRules(rewrites))
+	}
+
 	if httpreq.ExtractHeader(ctx, httpreq.LokiDisablePipelineWrappersHeader) == "true" {
 		logValues = append(logValues, "disable_pipeline_wrappers", "true")
 	} else {
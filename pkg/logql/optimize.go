@@ -1,86 +1,472 @@
 package logql
 
-import "github.com/grafana/loki/v3/pkg/logql/syntax"
+import (
+	"context"
+	regexpsyntax "regexp/syntax"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+// Rules of the optimizer.
+const (
+	RuleRemoveLineFormat  = "remove_line_format"
+	RulePushLineFilters   = "push_line_filters"
+	RuleRegexpToSubstring = "regexp_to_substring"
+	RuleMergeLineFilters  = "merge_line_filters"
+	RuleNarrowJSON        = "narrow_json"
+)
+
+var optimizerRewrites = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: constants.Loki,
+	Name:      "logql_optimizer_rewrites_total",
+	Help:      "Total number of rewrites applied to queries by the LogQL optimizer.",
+}, []string{"rule"})
+
+// Rewrite is a rewrite of a log pipeline applied by the optimizer.
+type Rewrite struct {
+	Rule   string
+	Before string
+	After  string
+}
+
+// pipelineRule rewrites the stages of a pipeline and returns true if the pipeline changed.
+type pipelineRule struct {
+	name  string
+	apply func(p *syntax.PipelineExpr, scope pipelineScope) bool
+}
+
+// pipelineScope is the expression a pipeline is evaluated in.
+type pipelineScope struct {
+	// rangeExpr is the range aggregation of a metric query, nil for log queries.
+	rangeExpr *syntax.RangeAggregationExpr
+	// grouping is the grouping used to extract the samples of rangeExpr.
+	grouping *syntax.Grouping
+}
+
+// pipelineRules are applied in order to every pipeline of a query.
+var pipelineRules = []pipelineRule{
+	{name: RuleRemoveLineFormat, apply: removeLineformat},
+	{name: RulePushLineFilters, apply: pushLineFilters},
+	{name: RuleMergeLineFilters, apply: mergeLineFilters},
+	{name: RuleRegexpToSubstring, apply: regexpToSubstring},
+	{name: RuleNarrowJSON, apply: narrowJSON},
+}
 
 // optimizeSampleExpr Attempt to optimize the SampleExpr to another that will run faster but will produce the same result.
-func optimizeSampleExpr(expr syntax.SampleExpr) (syntax.SampleExpr, error) {
-	var skip bool
+func optimizeSampleExpr(expr syntax.SampleExpr) (syntax.SampleExpr, []Rewrite, error) {
 	// we skip sharding AST for now, it's not easy to clone them since they are not part of the language.
+	if isShardedExpr(expr) {
+		return expr, nil, nil
+	}
+	expr, err := syntax.Clone[syntax.SampleExpr](expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the grouping of a sum is used to extract the samples of the nested range aggregation.
+	groupings := map[*syntax.RangeAggregationExpr]*syntax.Grouping{}
 	expr.Walk(func(e syntax.Expr) {
-		switch e.(type) {
-		case *ConcatSampleExpr, DownstreamSampleExpr, *QuantileSketchEvalExpr, *QuantileSketchMergeExpr, *MergeFirstOverTimeExpr, *MergeLastOverTimeExpr:
-			skip = true
+		if vecExpr, ok := e.(*syntax.VectorAggregationExpr); ok {
+			if rangeExpr, ok := vecExpr.Left.(*syntax.RangeAggregationExpr); ok && rangeExpr.Grouping == nil &&
+				syntax.CanInjectVectorGrouping(vecExpr.Operation, rangeExpr.Operation) {
+				groupings[rangeExpr] = vecExpr.Grouping
+			}
+		}
+	})
+
+	var rewrites []Rewrite
+	expr.Walk(func(e syntax.Expr) {
+		rangeExpr, ok := e.(*syntax.RangeAggregationExpr)
+		if !ok {
+			return
+		}
+		pipelineExpr, ok := rangeExpr.Left.Left.(*syntax.PipelineExpr)
+		if !ok {
 			return
 		}
+		grouping, ok := groupings[rangeExpr]
+		if !ok {
+			grouping = rangeExpr.Grouping
+		}
+		rewrites = append(rewrites, optimizePipeline(pipelineExpr, pipelineScope{rangeExpr: rangeExpr, grouping: grouping})...)
+		// transform into a matcherExpr if there's no more pipeline.
+		if len(pipelineExpr.MultiStages) == 0 {
+			rangeExpr.Left.Left = &syntax.MatchersExpr{Mts: rangeExpr.Left.Left.Matchers()}
+		}
+	})
+	return expr, rewrites, nil
+}
+
+// isShardedExpr returns true if expr contains sharding expressions, which are not part of the language.
+func isShardedExpr(expr syntax.SampleExpr) bool {
+	var sharded bool
+	expr.Walk(func(e syntax.Expr) {
+		switch e.(type) {
+		case *ConcatSampleExpr, DownstreamSampleExpr, *QuantileSketchEvalExpr, *QuantileSketchMergeExpr, *MergeFirstOverTimeExpr, *MergeLastOverTimeExpr, *ShardedTopKExpr:
+			sharded = true
+		}
 	})
-	if skip {
-		return expr, nil
+	return sharded
+}
+
+// optimizeLogSelectorExpr Attempt to optimize the LogSelectorExpr to another that will run faster but will produce the same result.
+func optimizeLogSelectorExpr(expr syntax.LogSelectorExpr) (syntax.LogSelectorExpr, []Rewrite, error) {
+	// sharded log selectors are not part of the language and can't be cloned.
+	if _, ok := expr.(*syntax.PipelineExpr); !ok {
+		return expr, nil, nil
 	}
-	expr, err := syntax.Clone[syntax.SampleExpr](expr)
+	pipelineExpr, err := syntax.Clone[*syntax.PipelineExpr](expr.(*syntax.PipelineExpr))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	removeLineformat(expr)
-	return expr, nil
+	rewrites := optimizePipeline(pipelineExpr, pipelineScope{})
+	return pipelineExpr, rewrites, nil
+}
+
+func optimizePipeline(p *syntax.PipelineExpr, scope pipelineScope) []Rewrite {
+	var rewrites []Rewrite
+	for _, rule := range pipelineRules {
+		before := p.String()
+		if !rule.apply(p, scope) {
+			continue
+		}
+		optimizerRewrites.WithLabelValues(rule.name).Inc()
+		rewrites = append(rewrites, Rewrite{Rule: rule.name, Before: before, After: p.String()})
+	}
+	return rewrites
 }
 
 // removeLineformat removes unnecessary line_format within a SampleExpr.
-func removeLineformat(expr syntax.SampleExpr) {
-	expr.Walk(func(e syntax.Expr) {
-		rangeExpr, ok := e.(*syntax.RangeAggregationExpr)
+func removeLineformat(pipelineExpr *syntax.PipelineExpr, scope pipelineScope) bool {
+	rangeExpr := scope.rangeExpr
+	// line_format changes the entries returned by log queries.
+	if rangeExpr == nil {
+		return false
+	}
+	// bytes operation count bytes of the log line so line_format changes the result.
+	if rangeExpr.Operation == syntax.OpRangeTypeBytes ||
+		rangeExpr.Operation == syntax.OpRangeTypeBytesRate {
+		return false
+	}
+	temp := pipelineExpr.MultiStages[:0]
+	for i, s := range pipelineExpr.MultiStages {
+		_, ok := s.(*syntax.LineFmtExpr)
 		if !ok {
-			return
+			temp = append(temp, s)
+			continue
 		}
-		// bytes operation count bytes of the log line so line_format changes the result.
-		if rangeExpr.Operation == syntax.OpRangeTypeBytes ||
-			rangeExpr.Operation == syntax.OpRangeTypeBytesRate {
-			return
+		// we found a lineFmtExpr, we need to check if it's followed by a labelParser or lineFilter
+		// in which case it could be useful for further processing.
+		var found bool
+		for j := i; j < len(pipelineExpr.MultiStages); j++ {
+			if _, ok := pipelineExpr.MultiStages[j].(*syntax.LogfmtParserExpr); ok {
+				found = true
+				break
+			}
+			if _, ok := pipelineExpr.MultiStages[j].(*syntax.LabelParserExpr); ok {
+				found = true
+				break
+			}
+			if _, ok := pipelineExpr.MultiStages[j].(*syntax.LineFilterExpr); ok {
+				found = true
+				break
+			}
+			if _, ok := pipelineExpr.MultiStages[j].(*syntax.JSONExpressionParser); ok {
+				found = true
+				break
+			}
+			if _, ok := pipelineExpr.MultiStages[j].(*syntax.LogfmtExpressionParser); ok {
+				found = true
+				break
+			}
 		}
-		pipelineExpr, ok := rangeExpr.Left.Left.(*syntax.PipelineExpr)
+		if found {
+			// we cannot remove safely the linefmtExpr.
+			temp = append(temp, s)
+		}
+	}
+	changed := len(temp) != len(pipelineExpr.MultiStages)
+	pipelineExpr.MultiStages = temp
+	return changed
+}
+
+// pushLineFilters moves line filters before the parsers and label filters preceding them,
+// so that lines are filtered before being parsed.
+// Line filters are never moved before stages changing the line, like line_format or unpack.
+func pushLineFilters(p *syntax.PipelineExpr, _ pipelineScope) bool {
+	var (
+		stages = make(syntax.MultiStageExpr, 0, len(p.MultiStages))
+		moved  bool
+	)
+	for _, s := range p.MultiStages {
+		filter, ok := s.(*syntax.LineFilterExpr)
 		if !ok {
-			return
+			stages = append(stages, s)
+			continue
+		}
+		i := len(stages)
+		for i > 0 && keepsLine(stages[i-1]) {
+			i--
+		}
+		if i < len(stages) {
+			moved = true
+		}
+		stages = slices.Insert(stages, i, syntax.StageExpr(filter))
+	}
+	p.MultiStages = stages
+	return moved
+}
+
+// keepsLine returns true if a line filter can be moved before s:
+// s neither changes the line nor is a line filter itself, which keeps the order of line filters.
+func keepsLine(s syntax.StageExpr) bool {
+	switch s := s.(type) {
+	case *syntax.LabelParserExpr:
+		return s.Op != syntax.OpParserTypeUnpack
+	case *syntax.LogfmtParserExpr, *syntax.JSONExpressionParser, *syntax.LogfmtExpressionParser,
+		*syntax.LabelFilterExpr, *syntax.LabelFmtExpr, *syntax.DropLabelsExpr, *syntax.KeepLabelsExpr:
+		return true
+	default:
+		return false
+	}
+}
+
+// mergeLineFilters chains adjacent line filter stages into a single stage.
+func mergeLineFilters(p *syntax.PipelineExpr, _ pipelineScope) bool {
+	var (
+		stages = make(syntax.MultiStageExpr, 0, len(p.MultiStages))
+		run    []*syntax.LineFilterExpr
+		merged bool
+	)
+	flush := func() {
+		if len(run) > 1 {
+			merged = true
+			stages = append(stages, syntax.CombineLineFilters(run))
+		} else if len(run) == 1 {
+			stages = append(stages, run[0])
 		}
-		temp := pipelineExpr.MultiStages[:0]
-		for i, s := range pipelineExpr.MultiStages {
-			_, ok := s.(*syntax.LineFmtExpr)
-			if !ok {
-				temp = append(temp, s)
+		run = nil
+	}
+	for _, s := range p.MultiStages {
+		if filter, ok := s.(*syntax.LineFilterExpr); ok {
+			run = append(run, filter)
+			continue
+		}
+		flush()
+		stages = append(stages, s)
+	}
+	flush()
+	p.MultiStages = stages
+	return merged
+}
+
+// regexpToSubstring converts regular expression line filters matching a literal, like |~ "error" or |~ ".*error.*",
+// into substring line filters.
+func regexpToSubstring(p *syntax.PipelineExpr, _ pipelineScope) bool {
+	var converted bool
+	for _, s := range p.MultiStages {
+		filter, ok := s.(*syntax.LineFilterExpr)
+		if !ok {
+			continue
+		}
+		for curr := filter; curr != nil; curr = curr.Left {
+			if curr.Ty != log.LineMatchRegexp && curr.Ty != log.LineMatchNotRegexp {
 				continue
 			}
-			// we found a lineFmtExpr, we need to check if it's followed by a labelParser or lineFilter
-			// in which case it could be useful for further processing.
-			var found bool
-			for j := i; j < len(pipelineExpr.MultiStages); j++ {
-				if _, ok := pipelineExpr.MultiStages[j].(*syntax.LogfmtParserExpr); ok {
-					found = true
-					break
-				}
-				if _, ok := pipelineExpr.MultiStages[j].(*syntax.LabelParserExpr); ok {
-					found = true
-					break
-				}
-				if _, ok := pipelineExpr.MultiStages[j].(*syntax.LineFilterExpr); ok {
-					found = true
-					break
-				}
-				if _, ok := pipelineExpr.MultiStages[j].(*syntax.JSONExpressionParser); ok {
-					found = true
-					break
-				}
-				if _, ok := pipelineExpr.MultiStages[j].(*syntax.LogfmtExpressionParser); ok {
-					found = true
+			// filters chained with "or" share the same match type, they are only converted together.
+			literals := make([]string, 0, 1)
+			for or := curr; or != nil; or = or.Or {
+				lit, ok := regexpLiteral(or)
+				if !ok {
 					break
 				}
+				literals = append(literals, lit)
+			}
+			if len(literals) == 0 || orLen(curr) != len(literals) {
+				continue
+			}
+
+			ty := log.LineMatchEqual
+			if curr.Ty == log.LineMatchNotRegexp {
+				ty = log.LineMatchNotEqual
 			}
-			if found {
-				// we cannot remove safely the linefmtExpr.
-				temp = append(temp, s)
+			i := 0
+			for or := curr; or != nil; or = or.Or {
+				or.Ty = ty
+				or.Match = literals[i]
+				i++
 			}
+			converted = true
 		}
-		pipelineExpr.MultiStages = temp
-		// transform into a matcherExpr if there's no more pipeline.
-		if len(pipelineExpr.MultiStages) == 0 {
-			rangeExpr.Left.Left = &syntax.MatchersExpr{Mts: rangeExpr.Left.Left.Matchers()}
+	}
+	return converted
+}
+
+func orLen(f *syntax.LineFilterExpr) int {
+	n := 0
+	for ; f != nil; f = f.Or {
+		n++
+	}
+	return n
+}
+
+// regexpLiteral returns the literal matched by the regular expression of the filter,
+// ignoring leading and trailing .* which don't change the lines matched.
+func regexpLiteral(f *syntax.LineFilterExpr) (string, bool) {
+	if f.Op != "" {
+		return "", false
+	}
+	re, err := regexpsyntax.Parse(f.Match, regexpsyntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op == regexpsyntax.OpConcat {
+		subs := re.Sub
+		for len(subs) > 0 && isAnyStar(subs[0]) {
+			subs = subs[1:]
 		}
-	})
+		for len(subs) > 0 && isAnyStar(subs[len(subs)-1]) {
+			subs = subs[:len(subs)-1]
+		}
+		if len(subs) != 1 {
+			return "", false
+		}
+		re = subs[0]
+	}
+	if re.Op != regexpsyntax.OpLiteral || re.Flags&regexpsyntax.FoldCase != 0 || len(re.Rune) == 0 {
+		return "", false
+	}
+	return string(re.Rune), true
+}
+
+func isAnyStar(re *regexpsyntax.Regexp) bool {
+	return re.Op == regexpsyntax.OpStar && len(re.Sub) == 1 &&
+		(re.Sub[0].Op == regexpsyntax.OpAnyCharNotNL || re.Sub[0].Op == regexpsyntax.OpAnyChar)
+}
+
+// narrowJSON replaces the json parser of metric queries by a json parser extracting only the fields
+// required by the query, as given by the parser hints.
+// The json parser is only narrowed when the fields can be addressed directly: every required label
+// must be a top-level field, which names without underscores guarantee since nested fields and
+// sanitized names are joined with underscores, and only filters may follow the parser.
+func narrowJSON(p *syntax.PipelineExpr, scope pipelineScope) bool {
+	if scope.rangeExpr == nil || scope.grouping == nil || scope.grouping.Without {
+		return false
+	}
+
+	var (
+		parser   = -1
+		required []string
+	)
+	for i, s := range p.MultiStages {
+		switch s := s.(type) {
+		case *syntax.LabelParserExpr:
+			if s.Op != syntax.OpParserTypeJSON || s.Param != "" || parser >= 0 {
+				return false
+			}
+			parser = i
+		case *syntax.LabelFilterExpr:
+			required = append(required, s.RequiredLabelNames()...)
+		case *syntax.LineFilterExpr:
+		default:
+			return false
+		}
+	}
+	if parser < 0 {
+		return false
+	}
+
+	var metricLabelName string
+	if unwrap := scope.rangeExpr.Left.Unwrap; unwrap != nil {
+		metricLabelName = unwrap.Identifier
+		for _, f := range unwrap.PostFilters {
+			required = append(required, f.RequiredLabelNames()...)
+		}
+	}
+
+	hints := log.NewParserHint(required, scope.grouping.Groups, false, scope.grouping.Singleton(), metricLabelName, nil)
+	fields := slices.Clone(hints.RequiredLabels())
+	if len(fields) == 0 {
+		return false
+	}
+	for _, f := range fields {
+		if strings.Contains(f, "_") || !model.LabelName(f).IsValid() {
+			return false
+		}
+	}
+	slices.Sort(fields)
+
+	expressions := make([]log.LabelExtractionExpr, 0, len(fields))
+	for _, f := range fields {
+		expressions = append(expressions, log.NewLabelExtractionExpr(f, f))
+	}
+	p.MultiStages[parser] = &syntax.JSONExpressionParser{Expressions: expressions}
+	return true
+}
+
+type optimizerContextKey struct{}
+
+// optimizerContext collects the rewrites applied to a query.
+type optimizerContext struct {
+	mtx      sync.Mutex
+	rewrites []Rewrite
+}
+
+func newOptimizerContext(ctx context.Context) (*optimizerContext, context.Context) {
+	c := &optimizerContext{}
+	return c, context.WithValue(ctx, optimizerContextKey{}, c)
+}
+
+func recordRewrites(ctx context.Context, rewrites []Rewrite) {
+	c, ok := ctx.Value(optimizerContextKey{}).(*optimizerContext)
+	if !ok || len(rewrites) == 0 {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.rewrites = append(c.rewrites, rewrites...)
+}
+
+// Rewrites returns the rewrites applied to the query.
+func (c *optimizerContext) Rewrites() []Rewrite {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return slices.Clone(c.rewrites)
+}
+
+// RewritesFromContext returns the rewrites applied by the optimizer to the query executed with ctx.
+func RewritesFromContext(ctx context.Context) []Rewrite {
+	c, ok := ctx.Value(optimizerContextKey{}).(*optimizerContext)
+	if !ok {
+		return nil
+	}
+	return c.Rewrites()
+}
+
+// rewriteCounts returns the number of rewrites by rule.
+func rewriteCounts(rewrites []Rewrite) map[string]int64 {
+	counts := make(map[string]int64, len(rewrites))
+	for _, r := range rewrites {
+		counts[r.Rule]++
+	}
+	return counts
+}
+
+// rewriteRules returns the comma separated rules of rewrites.
+func rewriteRules(rewrites []Rewrite) string {
+	rules := make([]string, 0, len(rewrites))
+	for _, r := range rewrites {
+		rules = append(rules, r.Rule)
+	}
+	return strings.Join(rules, ",")
 }
@@ -1,11 +1,14 @@
 package logql
 
 import (
+	"context"
 	"testing"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
 )
 
 func Test_optimizeSampleExpr(t *testing.T) {
@@ -24,7 +27,7 @@ func Test_optimizeSampleExpr(t *testing.T) {
 
 		// remove line_format that is not required.
 		{`sum by(name)(rate({region="us-east1"} | line_format "something else"[5m]))`, `sum by (name)(rate({region="us-east1"}[5m]))`},
-		{`sum by(name)(rate({region="us-east1"} | json | line_format "something else" | unwrap foo[5m]))`, `sum by (name)(rate({region="us-east1"} | json | unwrap foo[5m]))`},
+		{`sum by(name)(rate({region="us-east1"} | json | line_format "something else" | unwrap foo[5m]))`, `sum by (name)(rate({region="us-east1"} | json foo="foo",name="name" | unwrap foo[5m]))`},
 		{`quantile_over_time(1,{region="us-east1"} | json | line_format "something else" | unwrap foo[5m])`, `quantile_over_time(1,{region="us-east1"} | json | unwrap foo[5m])`},
 		{`sum by(name)(count_over_time({region="us-east1"} | json | line_format "something else" | label_format foo=bar | line_format "boo"[5m]))`, `sum by (name)(count_over_time({region="us-east1"} | json | label_format foo=bar[5m]))`},
 	}
@@ -32,9 +35,260 @@ func Test_optimizeSampleExpr(t *testing.T) {
 		t.Run(tt.in, func(t *testing.T) {
 			e, err := syntax.ParseSampleExpr(tt.in)
 			require.NoError(t, err)
-			got, err := optimizeSampleExpr(e)
+			got, _, err := optimizeSampleExpr(e)
 			require.NoError(t, err)
 			require.Equal(t, tt.expected, got.String())
 		})
 	}
 }
+
+func Test_optimizeSampleExprRules(t *testing.T) {
+	tests := []struct {
+		in, expected string
+		rules        []string
+	}{
+		// push line filters before parsers and label filters.
+		{
+			`sum by (level) (count_over_time({app=~".+"} | logfmt | level="error" |= "x"[5m]))`,
+			`sum by (level)(count_over_time({app=~".+"} |= "x" | logfmt | level="error"[5m]))`,
+			[]string{RulePushLineFilters},
+		},
+		{
+			`count_over_time({app="foo"} | logfmt | line_format "{{.msg}}" |= "x"[5m])`,
+			`count_over_time({app="foo"} | logfmt | line_format "{{.msg}}" |= "x"[5m])`,
+			nil,
+		},
+		{
+			`count_over_time({app="foo"} | unpack |= "x"[5m])`,
+			`count_over_time({app="foo"} | unpack |= "x"[5m])`,
+			nil,
+		},
+		// convert literal regexes to substring filters.
+		{
+			`count_over_time({app="foo"} |~ ".*error.*"[5m])`,
+			`count_over_time({app="foo"} |= "error"[5m])`,
+			[]string{RuleRegexpToSubstring},
+		},
+		{
+			`count_over_time({app="foo"} !~ "error"[5m])`,
+			`count_over_time({app="foo"} != "error"[5m])`,
+			[]string{RuleRegexpToSubstring},
+		},
+		{
+			`count_over_time({app="foo"} |~ "(?i)error"[5m])`,
+			`count_over_time({app="foo"} |~ "(?i)error"[5m])`,
+			nil,
+		},
+		{
+			`count_over_time({app="foo"} |~ "err.r"[5m])`,
+			`count_over_time({app="foo"} |~ "err.r"[5m])`,
+			nil,
+		},
+		// merge adjacent line filters.
+		{
+			`count_over_time({app="foo"} |= "a" | logfmt |= "b"[5m])`,
+			`count_over_time({app="foo"} |= "a" |= "b" | logfmt[5m])`,
+			[]string{RulePushLineFilters, RuleMergeLineFilters},
+		},
+		// narrow json to the fields used by the query.
+		{
+			`sum by (level) (count_over_time({app=~".+"} |= "x" | json | level="error"[5m]))`,
+			`sum by (level)(count_over_time({app=~".+"} |= "x" | json level="level" | level="error"[5m]))`,
+			[]string{RuleNarrowJSON},
+		},
+		{
+			`sum by (name) (rate({app="foo"} | json | unwrap latency[5m]))`,
+			`sum by (name)(rate({app="foo"} | json latency="latency",name="name" | unwrap latency[5m]))`,
+			[]string{RuleNarrowJSON},
+		},
+		{
+			`sum by (status_code) (count_over_time({app="foo"} | json[5m]))`,
+			`sum by (status_code)(count_over_time({app="foo"} | json[5m]))`,
+			nil,
+		},
+		{
+			`sum without (level) (count_over_time({app="foo"} | json[5m]))`,
+			`sum without (level)(count_over_time({app="foo"} | json[5m]))`,
+			nil,
+		},
+		{
+			`count_over_time({app="foo"} | json[5m])`,
+			`count_over_time({app="foo"} | json[5m])`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			e, err := syntax.ParseSampleExpr(tt.in)
+			require.NoError(t, err)
+			got, rewrites, err := optimizeSampleExpr(e)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got.String())
+
+			var rules []string
+			for _, r := range rewrites {
+				rules = append(rules, r.Rule)
+			}
+			require.Equal(t, tt.rules, rules)
+		})
+	}
+}
+
+func Test_optimizeLogSelectorExpr(t *testing.T) {
+	for _, tt := range []struct {
+		in, expected string
+	}{
+		{`{app="foo"} | json | level="error" |~ "error"`, `{app="foo"} |= "error" | json | level="error"`},
+		{`{app="foo"} | json`, `{app="foo"} | json`},
+		{`{app="foo"} | line_format "{{.msg}}"`, `{app="foo"} | line_format "{{.msg}}"`},
+		{`{app="foo"}`, `{app="foo"}`},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			e, err := syntax.ParseLogSelector(tt.in, true)
+			require.NoError(t, err)
+			got, _, err := optimizeLogSelectorExpr(e)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got.String())
+		})
+	}
+}
+
+// equivalenceLines are log lines whose json fields are not plain scalars, or are not json at all.
+var equivalenceLines = []string{
+	`{"level":"error","latency":"5","name":"api","msg":"x"}`,
+	`{"level":{"value":"error"},"latency":"5","name":"api","msg":"x"}`,
+	`{"level":["error","warn"],"latency":[1,2],"name":"api","msg":"x"}`,
+	`{"latency":"5","msg":"x"}`,
+	`{"level":"error","latency":"5","name":"api","msg":"x"`,
+	`level=error latency=5 name=api msg=x`,
+	`not a json line with an error in it`,
+}
+
+// narrowJSONLines are the equivalence lines whose required json fields are scalars or missing:
+// a narrowed json parser extracts the raw value of nested objects and arrays.
+var narrowJSONLines = []string{
+	`{"level":"error","latency":"5","name":"api","msg":"x"}`,
+	`{"latency":"5","msg":"x"}`,
+	`{"level":"error","latency":"5","name":"api","msg":"x"`,
+	`{"level":"error","latency":5,"name":"api","nested":{"level":"warn"},"msg":"x"}`,
+	`level=error latency=5 name=api msg=x`,
+	`not a json line with an error in it`,
+}
+
+func Test_optimizeSampleExprEquivalence(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		lines []string
+	}{
+		{`sum by (level) (count_over_time({app="foo"} | json | level="error" |= "x"[5m]))`, narrowJSONLines},
+		{`sum by (level, __error__) (count_over_time({app="foo"} | json | level="error" |~ ".*x.*"[5m]))`, narrowJSONLines},
+		{`sum by (name) (sum_over_time({app="foo"} | json |= "x" | unwrap latency[5m]))`, narrowJSONLines},
+		{`sum by (level_value) (count_over_time({app="foo"} | json | line_format "{{.name}}"[5m]))`, equivalenceLines},
+		{`count_over_time({app="foo"} | logfmt |~ "error" | level="error"[5m])`, equivalenceLines},
+	} {
+		query := tt.query
+		t.Run(query, func(t *testing.T) {
+			original, err := syntax.ParseSampleExpr(query)
+			require.NoError(t, err)
+			optimized, rewrites, err := optimizeSampleExpr(original)
+			require.NoError(t, err)
+			require.NotEmpty(t, rewrites)
+
+			originalExtractor, err := original.Extractor()
+			require.NoError(t, err)
+			optimizedExtractor, err := optimized.Extractor()
+			require.NoError(t, err)
+
+			lbls := labels.FromStrings("app", "foo")
+			for _, line := range tt.lines {
+				wantValue, wantLabels, wantOK := originalExtractor.ForStream(lbls).ProcessString(0, line)
+				gotValue, gotLabels, gotOK := optimizedExtractor.ForStream(lbls).ProcessString(0, line)
+				require.Equal(t, wantOK, gotOK, line)
+				if !wantOK {
+					continue
+				}
+				require.Equal(t, wantValue, gotValue, line)
+				require.Equal(t, resultLabels(original, wantLabels.Labels()), resultLabels(original, gotLabels.Labels()), line)
+			}
+		})
+	}
+}
+
+// resultLabels returns the labels of a sample kept in the result of expr, the error label failing the query.
+func resultLabels(expr syntax.SampleExpr, lbls labels.Labels) string {
+	vecExpr, ok := expr.(*syntax.VectorAggregationExpr)
+	if !ok || vecExpr.Grouping == nil || vecExpr.Grouping.Without {
+		return lbls.String()
+	}
+	b := labels.NewBuilder(labels.EmptyLabels())
+	for _, name := range append([]string{logqlmodel.ErrorLabel}, vecExpr.Grouping.Groups...) {
+		if v := lbls.Get(name); v != "" {
+			b.Set(name, v)
+		}
+	}
+	return b.Labels().String()
+}
+
+func Test_optimizeLogSelectorExprEquivalence(t *testing.T) {
+	for _, query := range []string{
+		`{app="foo"} | json | level="error" |~ ".*x.*"`,
+		`{app="foo"} | json | level=~".+" |= "x" |= "api"`,
+		`{app="foo"} | logfmt | level="error" |~ "error"`,
+	} {
+		t.Run(query, func(t *testing.T) {
+			original, err := syntax.ParseLogSelector(query, true)
+			require.NoError(t, err)
+			optimized, rewrites, err := optimizeLogSelectorExpr(original)
+			require.NoError(t, err)
+			require.NotEmpty(t, rewrites)
+
+			originalPipeline, err := original.Pipeline()
+			require.NoError(t, err)
+			optimizedPipeline, err := optimized.Pipeline()
+			require.NoError(t, err)
+
+			lbls := labels.FromStrings("app", "foo")
+			for _, line := range equivalenceLines {
+				wantLine, wantLabels, wantOK := originalPipeline.ForStream(lbls).ProcessString(0, line)
+				gotLine, gotLabels, gotOK := optimizedPipeline.ForStream(lbls).ProcessString(0, line)
+				require.Equal(t, wantOK, gotOK, line)
+				if !wantOK {
+					continue
+				}
+				require.Equal(t, wantLine, gotLine, line)
+				require.Equal(t, wantLabels.String(), gotLabels.String(), line)
+			}
+		})
+	}
+}
+
+func Test_RewritesFromContext(t *testing.T) {
+	_, ctx := newOptimizerContext(context.Background())
+	e, err := syntax.ParseSampleExpr(`count_over_time({app="foo"} | logfmt |~ "error"[5m])`)
+	require.NoError(t, err)
+	_, rewrites, err := optimizeSampleExpr(e)
+	require.NoError(t, err)
+	recordRewrites(ctx, rewrites)
+
+	require.Equal(t, []Rewrite{
+		{
+			Rule:   RulePushLineFilters,
+			Before: `{app="foo"} | logfmt |~ "error"`,
+			After:  `{app="foo"} |~ "error" | logfmt`,
+		},
+		{
+			Rule:   RuleRegexpToSubstring,
+			Before: `{app="foo"} |~ "error" | logfmt`,
+			After:  `{app="foo"} |= "error" | logfmt`,
+		},
+	}, RewritesFromContext(ctx))
+	require.Equal(t, "push_line_filters,regexp_to_substring", rewriteRules(RewritesFromContext(ctx)))
+	require.Nil(t, RewritesFromContext(context.Background()))
+
+	tree := NewTree()
+	ExplainRewrites(tree, RewritesFromContext(ctx))
+	require.Equal(t, `Optimizations
+ ├── push_line_filters: {app="foo"} | logfmt |~ "error" => {app="foo"} |~ "error" | logfmt
+ └── regexp_to_substring: {app="foo"} |~ "error" | logfmt => {app="foo"} |= "error" | logfmt
+`, tree.String())
+}
@@ -0,0 +1,182 @@
+package logql
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+// RuleRollup is the rule of the rewrites of metric queries into queries of the rollups computed by the compactor.
+const RuleRollup = "rollup"
+
+// rollupRewriter rewrites the sums of range aggregations matching a rollup rule into sums of the rollups of the rule.
+type rollupRewriter struct {
+	rules []rollupRule
+	// start, end and step are the evaluation range of the query.
+	start, end time.Time
+	step       time.Duration
+	now        time.Time
+}
+
+type rollupRule struct {
+	*validation.RollupRule
+	selector string
+}
+
+func newRollupRewriter(rules []*validation.RollupRule, start, end time.Time, step time.Duration, now time.Time) *rollupRewriter {
+	r := &rollupRewriter{start: start, end: end, step: step, now: now}
+	for _, rule := range rules {
+		// the rules are validated with the limits.
+		selector, err := syntax.ParseLogSelector(rule.Selector, true)
+		if err != nil {
+			continue
+		}
+		r.rules = append(r.rules, rollupRule{RollupRule: rule, selector: selector.String()})
+	}
+	return r
+}
+
+// rewriteRollups rewrites the parts of expr which can be answered from the rollups of the rules.
+func rewriteRollups(expr syntax.SampleExpr, rules []*validation.RollupRule, start, end time.Time, step time.Duration, now time.Time) (syntax.SampleExpr, []Rewrite, error) {
+	if len(rules) == 0 || isShardedExpr(expr) {
+		return expr, nil, nil
+	}
+	rewritten, err := syntax.Clone[syntax.SampleExpr](expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := newRollupRewriter(rules, start, end, step, now)
+	var rewrites []Rewrite
+	rewritten, err = r.rewrite(rewritten, &rewrites)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rewrites) == 0 {
+		return expr, nil, nil
+	}
+	return rewritten, rewrites, nil
+}
+
+func (r *rollupRewriter) rewrite(expr syntax.SampleExpr, rewrites *[]Rewrite) (syntax.SampleExpr, error) {
+	var err error
+	switch e := expr.(type) {
+	case *syntax.VectorAggregationExpr:
+		rewritten, ok, err := r.rewriteAggregation(e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			optimizerRewrites.WithLabelValues(RuleRollup).Inc()
+			*rewrites = append(*rewrites, Rewrite{Rule: RuleRollup, Before: e.String(), After: rewritten.String()})
+			return rewritten, nil
+		}
+		e.Left, err = r.rewrite(e.Left, rewrites)
+	case *syntax.BinOpExpr:
+		if e.SampleExpr, err = r.rewrite(e.SampleExpr, rewrites); err != nil {
+			return nil, err
+		}
+		e.RHS, err = r.rewrite(e.RHS, rewrites)
+	case *syntax.LabelReplaceExpr:
+		e.Left, err = r.rewrite(e.Left, rewrites)
+	}
+	return expr, err
+}
+
+// rewriteAggregation rewrites a sum of a range aggregation matching a rollup rule into a sum of its rollups.
+// The rollups are summed over the range, and rates are divided by the range. The grouping of the sum is injected
+// into the sum over time by the evaluator so the other rollup values never create additional series.
+func (r *rollupRewriter) rewriteAggregation(e *syntax.VectorAggregationExpr) (syntax.SampleExpr, bool, error) {
+	if e.Operation != syntax.OpTypeSum || e.Grouping.Without {
+		return nil, false, nil
+	}
+	rangeExpr, ok := e.Left.(*syntax.RangeAggregationExpr)
+	if !ok || rangeExpr.Grouping != nil {
+		return nil, false, nil
+	}
+
+	var value string
+	switch rangeExpr.Operation {
+	case syntax.OpRangeTypeCount, syntax.OpRangeTypeRate:
+		value = constants.RollupCount
+	case syntax.OpRangeTypeBytes, syntax.OpRangeTypeBytesRate:
+		value = constants.RollupBytes
+	case syntax.OpRangeTypeSum:
+		value = constants.RollupSum
+	default:
+		return nil, false, nil
+	}
+
+	logRange := rangeExpr.Left
+	if (value == constants.RollupSum) != (logRange.Unwrap != nil) {
+		return nil, false, nil
+	}
+	rule, ok := r.matchingRule(logRange, e.Grouping)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var offset string
+	if logRange.Offset != 0 {
+		offset = " offset " + model.Duration(logRange.Offset).String()
+	}
+	query := fmt.Sprintf(`sum%s (sum_over_time({%s=%q} | unwrap %s [%s]%s))`,
+		e.Grouping, constants.RollupLabel, rule.Name, value, model.Duration(logRange.Interval), offset)
+	if rangeExpr.Operation == syntax.OpRangeTypeRate || rangeExpr.Operation == syntax.OpRangeTypeBytesRate {
+		query += " / " + strconv.FormatFloat(logRange.Interval.Seconds(), 'f', -1, 64)
+	}
+
+	rewritten, err := syntax.ParseSampleExpr(query)
+	if err != nil {
+		return nil, false, err
+	}
+	return rewritten, true, nil
+}
+
+// matchingRule returns the rule whose rollups can answer the range aggregation of logRange grouped by grouping.
+// The selector and the unwrapped label have to be the ones of the rule, the grouping labels have to be aggregated by
+// the rule, the range has to be a multiple of its resolution, and the whole range of the query has to be rolled up.
+// The start and the step of the query have to be aligned with the resolution too, otherwise the range of the evaluations
+// would cover a part of the rollup intervals at their boundaries.
+func (r *rollupRewriter) matchingRule(logRange *syntax.LogRange, grouping *syntax.Grouping) (rollupRule, bool) {
+	selector := logRange.Left.String()
+	for _, rule := range r.rules {
+		if rule.selector != selector {
+			continue
+		}
+		if logRange.Unwrap != nil && (logRange.Unwrap.Identifier != rule.Unwrap || logRange.Unwrap.Operation != "" || len(logRange.Unwrap.PostFilters) > 0) {
+			continue
+		}
+		resolution := time.Duration(rule.Resolution)
+		if resolution <= 0 || logRange.Interval%resolution != 0 || logRange.Offset%resolution != 0 {
+			continue
+		}
+		if r.start.UnixNano()%int64(resolution) != 0 || r.step%resolution != 0 {
+			continue
+		}
+		if !r.end.Add(-logRange.Offset).Before(r.now.Add(-time.Duration(rule.QueryableAfter()))) {
+			continue
+		}
+		if !containsAll(rule.By, grouping.Groups) {
+			continue
+		}
+		return rule, true
+	}
+	return rollupRule{}, false
+}
+
+func containsAll(labels, subset []string) bool {
+	for _, l := range subset {
+		if !slices.Contains(labels, l) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package logql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+func Test_rewriteRollups(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour).Truncate(time.Hour)
+	rules := []*validation.RollupRule{
+		{
+			Name:       "nginx",
+			Selector:   `{app="nginx"} | logfmt`,
+			By:         []string{"namespace", "status"},
+			Unwrap:     "duration",
+			Resolution: model.Duration(time.Minute),
+			After:      model.Duration(30 * 24 * time.Hour),
+		},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		// start defaults to end, for an instant query.
+		start    time.Time
+		end      time.Time
+		step     time.Duration
+		expected string
+	}{
+		{
+			name:     "count",
+			query:    `sum by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			end:      old,
+			expected: `sum by (status)(sum_over_time({__rollup__="nginx"} | unwrap rollup_count[5m]))`,
+		},
+		{
+			name:     "rate without grouping",
+			query:    `sum(rate({app="nginx"} | logfmt [1h]))`,
+			end:      old,
+			expected: `(sum(sum_over_time({__rollup__="nginx"} | unwrap rollup_count[1h])) / 3600)`,
+		},
+		{
+			name:     "bytes rate with offset",
+			query:    `sum by (namespace, status) (bytes_rate({app="nginx"} | logfmt [2m] offset 1h))`,
+			end:      old,
+			expected: `(sum by (namespace,status)(sum_over_time({__rollup__="nginx"} | unwrap rollup_bytes[2m] offset 1h0m0s)) / 120)`,
+		},
+		{
+			name:     "unwrap sum nested in a binary operation",
+			query:    `sum by (status) (sum_over_time({app="nginx"} | logfmt | unwrap duration [5m])) / sum by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			end:      old,
+			expected: `(sum by (status)(sum_over_time({__rollup__="nginx"} | unwrap rollup_sum[5m])) / sum by (status)(sum_over_time({__rollup__="nginx"} | unwrap rollup_count[5m])))`,
+		},
+		{
+			name:     "recent data",
+			query:    `sum by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			end:      now.Add(-30 * 24 * time.Hour),
+			expected: `sum by (status)(count_over_time({app="nginx"} | logfmt[5m]))`,
+		},
+		{
+			name:     "different selector",
+			query:    `sum by (status) (count_over_time({app="nginx"} | json [5m]))`,
+			end:      old,
+			expected: `sum by (status)(count_over_time({app="nginx"} | json[5m]))`,
+		},
+		{
+			name:     "label not aggregated by the rule",
+			query:    `sum by (pod) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			end:      old,
+			expected: `sum by (pod)(count_over_time({app="nginx"} | logfmt[5m]))`,
+		},
+		{
+			name:     "range not aligned with the resolution",
+			query:    `sum by (status) (count_over_time({app="nginx"} | logfmt [90s]))`,
+			end:      old,
+			expected: `sum by (status)(count_over_time({app="nginx"} | logfmt[1m30s]))`,
+		},
+		{
+			name:     "range query aligned with the resolution",
+			query:    `sum by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			start:    old.Add(-time.Hour),
+			end:      old,
+			step:     5 * time.Minute,
+			expected: `sum by (status)(sum_over_time({__rollup__="nginx"} | unwrap rollup_count[5m]))`,
+		},
+		{
+			name:     "start not aligned with the resolution",
+			query:    `sum by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			start:    old.Add(-time.Hour + 30*time.Second),
+			end:      old,
+			step:     5 * time.Minute,
+			expected: `sum by (status)(count_over_time({app="nginx"} | logfmt[5m]))`,
+		},
+		{
+			name:     "step not aligned with the resolution",
+			query:    `sum by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			start:    old.Add(-time.Hour),
+			end:      old,
+			step:     90 * time.Second,
+			expected: `sum by (status)(count_over_time({app="nginx"} | logfmt[5m]))`,
+		},
+		{
+			name:     "different unwrapped label",
+			query:    `sum by (status) (sum_over_time({app="nginx"} | logfmt | unwrap size [5m]))`,
+			end:      old,
+			expected: `sum by (status)(sum_over_time({app="nginx"} | logfmt | unwrap size[5m]))`,
+		},
+		{
+			name:     "not a sum",
+			query:    `max by (status) (count_over_time({app="nginx"} | logfmt [5m]))`,
+			end:      old,
+			expected: `max by (status)(count_over_time({app="nginx"} | logfmt[5m]))`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, err := syntax.ParseSampleExpr(tc.query)
+			require.NoError(t, err)
+			before := expr.String()
+
+			start := tc.start
+			if start.IsZero() {
+				start = tc.end
+			}
+			got, rewrites, err := rewriteRollups(expr, rules, start, tc.end, tc.step, now)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got.String())
+			// the original expression is never modified.
+			require.Equal(t, before, expr.String())
+			if tc.expected == before {
+				require.Empty(t, rewrites)
+			} else {
+				require.NotEmpty(t, rewrites)
+				require.Equal(t, RuleRollup, rewrites[0].Rule)
+			}
+		})
+	}
+}
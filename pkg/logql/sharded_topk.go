@@ -0,0 +1,273 @@
+package logql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+// newShardedTopKStepEvaluator evaluates `topk(k, sum by (<labels>) (<range aggregation>))` in two phases,
+// without merging all the series of all the shards in the query frontend:
+//
+//  1. Each shard returns its local topk. The union of the local topk series are the candidates.
+//     A series which is not a candidate is below the k-th value of every shard returning k series,
+//     so its sum is at most the threshold: the sum of the k-th values of these shards.
+//  2. Each shard returns the sums of the candidates only, which gives the exact sum of each candidate.
+//     If the k-th largest candidate is not below the threshold at every step, the candidates contain the topk.
+//
+// Otherwise, the topk is evaluated on the merged sums of all the shards, like an unsharded topk.
+// The bound requires samples that are not negative, see ShardMapper.mapTopKExpr.
+func (ev *DownstreamEvaluator) newShardedTopKStepEvaluator(
+	ctx context.Context,
+	nextEvFactory SampleEvaluatorFactory,
+	e *ShardedTopKExpr,
+	params Params,
+) (StepEvaluator, error) {
+	logger := util_log.WithContext(ctx, util_log.Logger)
+
+	// Phase 1: the local topk of each shard.
+	local, err := ev.downstreamShards(ctx, e.downstreams, &syntax.VectorAggregationExpr{
+		Left:      e.inner,
+		Grouping:  &syntax.Grouping{},
+		Params:    e.k,
+		Operation: syntax.OpTypeTopK,
+	}, params)
+	if err != nil {
+		return nil, err
+	}
+	candidates, thresholds := topkCandidates(local, e.k)
+	if len(candidates) == 0 {
+		return resultStepEvaluator(nil, params), nil
+	}
+
+	// Phase 2: the exact sums of the candidates.
+	filtered, err := candidatesExpr(e.inner, candidates)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := ev.downstreamShards(ctx, e.downstreams, filtered, params)
+	if err != nil {
+		return nil, err
+	}
+	steps, ok := topkOfCandidates(sums, candidates, thresholds, e.k)
+	if ok {
+		level.Debug(logger).Log("msg", "sharded topk", "candidates", len(candidates), "shards", len(e.downstreams))
+		return resultStepEvaluator(steps, params), nil
+	}
+
+	level.Debug(logger).Log("msg", "sharded topk candidates are below the threshold, merging all the series of the shards", "candidates", len(candidates), "shards", len(e.downstreams))
+	var head *ConcatSampleExpr
+	for i := len(e.downstreams) - 1; i >= 0; i-- {
+		head = &ConcatSampleExpr{
+			DownstreamSampleExpr: e.downstreams[i],
+			next:                 head,
+		}
+	}
+	return ev.NewStepEvaluator(ctx, nextEvFactory, &syntax.VectorAggregationExpr{
+		Left: &syntax.VectorAggregationExpr{
+			Left:      head,
+			Grouping:  e.inner.Grouping,
+			Operation: syntax.OpTypeSum,
+		},
+		Grouping:  &syntax.Grouping{},
+		Params:    e.k,
+		Operation: syntax.OpTypeTopK,
+	}, params)
+}
+
+// downstreamShards executes the expression on each shard of the downstreams,
+// and returns the vectors of each step of each shard.
+func (ev *DownstreamEvaluator) downstreamShards(ctx context.Context, downstreams []DownstreamSampleExpr, expr syntax.SampleExpr, params Params) ([][]promql.Vector, error) {
+	queries := make([]DownstreamQuery, 0, len(downstreams))
+	for _, d := range downstreams {
+		queries = append(queries, DownstreamQuery{
+			Params: ParamsWithExpressionOverride{
+				Params:             ParamOverridesFromShard(params, d.shard),
+				ExpressionOverride: expr,
+			},
+		})
+	}
+
+	acc := NewBufferedAccumulator(len(queries))
+	results, err := ev.Downstream(ctx, queries, acc)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := make([][]promql.Vector, 0, len(results))
+	for _, res := range results {
+		stepper, err := NewResultStepEvaluator(res, params)
+		if err != nil {
+			return nil, err
+		}
+		var steps []promql.Vector
+		for next, _, r := stepper.Next(); next; next, _, r = stepper.Next() {
+			steps = append(steps, slices.Clone(r.SampleVector()))
+		}
+		if err := stepper.Error(); err != nil {
+			return nil, err
+		}
+		shards = append(shards, steps)
+	}
+	return shards, nil
+}
+
+// topkCandidates returns the series of the local topk of the shards, and the threshold of each step:
+// the maximum sum of a series which is not a candidate.
+func topkCandidates(shards [][]promql.Vector, k int) (map[uint64]labels.Labels, []float64) {
+	candidates := map[uint64]labels.Labels{}
+	var thresholds []float64
+	for _, steps := range shards {
+		if len(steps) > len(thresholds) {
+			thresholds = append(thresholds, make([]float64, len(steps)-len(thresholds))...)
+		}
+		for i, vec := range steps {
+			for _, s := range vec {
+				candidates[s.Metric.Hash()] = s.Metric
+			}
+			// A shard returning less than k series returned all its series.
+			if len(vec) < k {
+				continue
+			}
+			kth := vec[0].F
+			for _, s := range vec[1:] {
+				kth = min(kth, s.F)
+			}
+			thresholds[i] += kth
+		}
+	}
+	return candidates, thresholds
+}
+
+// candidatesExpr returns the sum with a label filter selecting the candidate series,
+// e.g. `sum by (foo) (count_over_time({app="bar"} | foo=~"a|b" [1m]))`.
+// The filter selects the cartesian product of the values of each label, which is a superset of the candidates.
+func candidatesExpr(inner *syntax.VectorAggregationExpr, candidates map[uint64]labels.Labels) (syntax.SampleExpr, error) {
+	expr, err := syntax.Clone(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := make(syntax.MultiStageExpr, 0, len(expr.Grouping.Groups))
+	for _, name := range expr.Grouping.Groups {
+		values := map[string]struct{}{}
+		for _, ls := range candidates {
+			values[ls.Get(name)] = struct{}{}
+		}
+		patterns := make([]string, 0, len(values))
+		for v := range values {
+			patterns = append(patterns, regexp.QuoteMeta(v))
+		}
+		sort.Strings(patterns)
+
+		m, err := labels.NewMatcher(labels.MatchRegexp, name, strings.Join(patterns, "|"))
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, &syntax.LabelFilterExpr{LabelFilterer: log.NewStringLabelFilter(m)})
+	}
+
+	logRange := expr.Left.(*syntax.RangeAggregationExpr).Left
+	switch selector := logRange.Left.(type) {
+	case *syntax.MatchersExpr:
+		logRange.Left = &syntax.PipelineExpr{Left: selector, MultiStages: stages}
+	case *syntax.PipelineExpr:
+		selector.MultiStages = append(selector.MultiStages, stages...)
+	default:
+		return nil, fmt.Errorf("unexpected log selector type (%T) for sharded topk", selector)
+	}
+	return expr, nil
+}
+
+// topkOfCandidates returns the topk of each step from the sums of the candidates on each shard.
+// It returns false if the k-th largest candidate is below the threshold of a step,
+// as a series which is not a candidate could then be part of the topk.
+func topkOfCandidates(shards [][]promql.Vector, candidates map[uint64]labels.Labels, thresholds []float64, k int) ([]promql.Vector, bool) {
+	var steps []promql.Vector
+	for _, shard := range shards {
+		if len(shard) > len(steps) {
+			steps = append(steps, make([]promql.Vector, len(shard)-len(steps))...)
+		}
+		for i, vec := range shard {
+			steps[i] = append(steps[i], vec...)
+		}
+	}
+
+	for i, vec := range steps {
+		sums := map[uint64]*promql.Sample{}
+		for _, s := range vec {
+			h := s.Metric.Hash()
+			if _, ok := candidates[h]; !ok {
+				// the label filter selects more series than the candidates.
+				continue
+			}
+			if sum, ok := sums[h]; ok {
+				sum.F += s.F
+				continue
+			}
+			sums[h] = &promql.Sample{T: s.T, F: s.F, Metric: s.Metric}
+		}
+
+		top := make(promql.Vector, 0, len(sums))
+		for _, s := range sums {
+			top = append(top, *s)
+		}
+		sort.Slice(top, func(i, j int) bool { return top[i].F > top[j].F })
+
+		var threshold float64
+		if i < len(thresholds) {
+			threshold = thresholds[i]
+		}
+		if len(top) < k {
+			// less than k candidates means no shard returned k series, there is no other series.
+			if threshold > 0 {
+				return nil, false
+			}
+		} else {
+			if top[k-1].F < threshold {
+				return nil, false
+			}
+			top = top[:k]
+		}
+		steps[i] = top
+	}
+	return steps, true
+}
+
+// resultStepEvaluator returns a StepEvaluator over the vectors of each step.
+func resultStepEvaluator(steps []promql.Vector, params Params) StepEvaluator {
+	if GetRangeType(params) == InstantType {
+		var vec promql.Vector
+		if len(steps) > 0 {
+			vec = steps[0]
+		}
+		return NewVectorStepEvaluator(params.Start(), vec)
+	}
+
+	index := map[uint64]int{}
+	var matrix promql.Matrix
+	for _, vec := range steps {
+		for _, s := range vec {
+			h := s.Metric.Hash()
+			i, ok := index[h]
+			if !ok {
+				i = len(matrix)
+				index[h] = i
+				matrix = append(matrix, promql.Series{Metric: s.Metric})
+			}
+			matrix[i].Floats = append(matrix[i].Floats, promql.FPoint{T: s.T, F: s.F})
+		}
+	}
+	return NewMatrixStepEvaluator(params.Start(), params.End(), params.Step(), matrix)
+}
@@ -17,6 +17,7 @@ const (
 	ShardFirstOverTime    = "first_over_time"
 	ShardQuantileOverTime = "quantile_over_time"
 	SupportApproxTopk     = "approx_topk"
+	ShardTopK             = "topk"
 )
 
 type ShardMapper struct {
@@ -26,6 +27,7 @@ type ShardMapper struct {
 	lastOverTimeSharding     bool
 	firstOverTimeSharding    bool
 	approxTopkSupport        bool
+	topkSharding             bool
 }
 
 func NewShardMapper(strategy ShardingStrategy, metrics *MapperMetrics, shardAggregation []string) ShardMapper {
@@ -36,6 +38,7 @@ func NewShardMapper(strategy ShardingStrategy, metrics *MapperMetrics, shardAggr
 		lastOverTimeSharding:     false,
 		firstOverTimeSharding:    false,
 		approxTopkSupport:        false,
+		topkSharding:             false,
 	}
 	for _, a := range shardAggregation {
 		switch a {
@@ -47,6 +50,8 @@ func NewShardMapper(strategy ShardingStrategy, metrics *MapperMetrics, shardAggr
 			mapper.firstOverTimeSharding = true
 		case SupportApproxTopk:
 			mapper.approxTopkSupport = true
+		case ShardTopK:
+			mapper.topkSharding = true
 		}
 	}
 
@@ -57,6 +62,11 @@ func NewShardMapperMetrics(registerer prometheus.Registerer) *MapperMetrics {
 	return newMapperMetrics(registerer, "shard")
 }
 
+// NewFederationMapperMetrics returns the metrics of the shard mapper used to map queries across federated clusters.
+func NewFederationMapperMetrics(registerer prometheus.Registerer) *MapperMetrics {
+	return newMapperMetrics(registerer, "federation")
+}
+
 func (m ShardMapper) Parse(parsed syntax.Expr) (noop bool, bytesPerShard uint64, expr syntax.Expr, err error) {
 	recorder := m.metrics.downstreamRecorder()
 
@@ -235,6 +245,13 @@ func (m ShardMapper) wrappedShardedVectorAggr(expr *syntax.VectorAggregationExpr
 // technically, std{dev,var} are also parallelizable if there is no cross-shard merging
 // in descendent nodes in the AST. This optimization is currently avoided for simplicity.
 func (m ShardMapper) mapVectorAggregationExpr(expr *syntax.VectorAggregationExpr, r *downstreamRecorder, topLevel bool) (syntax.SampleExpr, uint64, error) {
+	if expr.Operation == syntax.OpTypeTopK && m.topkSharding {
+		mapped, bytesPerShard, ok, err := m.mapTopKExpr(expr, r, topLevel)
+		if err != nil || ok {
+			return mapped, bytesPerShard, err
+		}
+	}
+
 	if expr.Shardable(topLevel) {
 		switch expr.Operation {
 
@@ -388,6 +405,68 @@ func (m ShardMapper) mapVectorAggregationExpr(expr *syntax.VectorAggregationExpr
 	}, bytesPerShard, nil
 }
 
+// mapTopKExpr maps `topk(k, sum by (<labels>) (<range aggregation>))` to a two-phase sharded topk.
+// It returns false if the expression can't be mapped, in which case the topk is evaluated on the merged shards.
+//
+// The sum of a series over the shards can only be bounded if the samples of the range aggregation are not negative,
+// so only counting range aggregations without unwrap are supported.
+// The candidate series are selected with a label filter on the log pipeline, which requires a `by` grouping.
+func (m ShardMapper) mapTopKExpr(expr *syntax.VectorAggregationExpr, r *downstreamRecorder, topLevel bool) (syntax.SampleExpr, uint64, bool, error) {
+	// only a topk over all the series is supported, not a topk per group.
+	if expr.Params < 1 || (expr.Grouping != nil && (expr.Grouping.Without || len(expr.Grouping.Groups) > 0)) {
+		return nil, 0, false, nil
+	}
+	inner, ok := expr.Left.(*syntax.VectorAggregationExpr)
+	if !ok || inner.Operation != syntax.OpTypeSum || inner.Grouping == nil || inner.Grouping.Without || len(inner.Grouping.Groups) == 0 {
+		return nil, 0, false, nil
+	}
+	if !inner.Shardable(topLevel) {
+		return nil, 0, false, nil
+	}
+	rangeExpr, ok := inner.Left.(*syntax.RangeAggregationExpr)
+	if !ok || rangeExpr.Left.Unwrap != nil {
+		return nil, 0, false, nil
+	}
+	switch rangeExpr.Operation {
+	case syntax.OpRangeTypeCount, syntax.OpRangeTypeRate, syntax.OpRangeTypeBytes, syntax.OpRangeTypeBytesRate:
+	default:
+		return nil, 0, false, nil
+	}
+	switch rangeExpr.Left.Left.(type) {
+	case *syntax.MatchersExpr, *syntax.PipelineExpr:
+	default:
+		return nil, 0, false, nil
+	}
+
+	shards, bytesPerShard, err := m.shards.Shards(inner)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if len(shards) < 2 {
+		// there is nothing to gain from two phases over a single shard.
+		return nil, 0, false, nil
+	}
+
+	// topk(k, sum by (foo) (x)) ->
+	// shardedTopK<k,
+	//   downstream<sum by (foo) (x), shard=1> ++ downstream<sum by (foo) (x), shard=2>...
+	// >
+	downstreams := make([]DownstreamSampleExpr, 0, len(shards))
+	for i := range shards {
+		downstreams = append(downstreams, DownstreamSampleExpr{
+			shard:      &shards[i],
+			SampleExpr: inner,
+		})
+	}
+
+	r.Add(len(shards), MetricsKey)
+	return &ShardedTopKExpr{
+		k:           expr.Params,
+		inner:       inner,
+		downstreams: downstreams,
+	}, bytesPerShard, true, nil
+}
+
 func (m ShardMapper) mapLabelReplaceExpr(expr *syntax.LabelReplaceExpr, r *downstreamRecorder, topLevel bool) (syntax.SampleExpr, uint64, error) {
 	subMapped, bytesPerShard, err := m.Map(expr.Left, r, topLevel)
 	if err != nil {
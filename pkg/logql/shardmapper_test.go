@@ -1697,7 +1697,7 @@ func TestMapping(t *testing.T) {
 			mapped, _, err := m.Map(ast, nilShardMetrics.downstreamRecorder(), true)
 			switch e := mapped.(type) {
 			case syntax.SampleExpr:
-				optimized, err := optimizeSampleExpr(e)
+				optimized, _, err := optimizeSampleExpr(e)
 				require.NoError(t, err)
 				require.Equal(t, mapped.String(), optimized.String())
 			}
@@ -1809,3 +1809,43 @@ func TestShardTopk(t *testing.T) {
 )`
 	require.Equal(t, expected, mappedExpr.Pretty(0))
 }
+
+func TestMappingStrings_ShardedTopK(t *testing.T) {
+	m := NewShardMapper(NewPowerOfTwoStrategy(ConstantShards(2)), nilShardMetrics, []string{ShardTopK})
+	for _, tc := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  `topk(3, sum by (client) (count_over_time({app="foo"} | logfmt [1m])))`,
+			out: `ShardedTopK<3, downstream<sumby(client)(count_over_time({app="foo"}|logfmt[1m])),shard=0_of_2> ++ downstream<sumby(client)(count_over_time({app="foo"}|logfmt[1m])),shard=1_of_2>>`,
+		},
+		{
+			in:  `topk(3, sum by (client) (rate({app="foo"}[1m])))`,
+			out: `ShardedTopK<3, downstream<sumby(client)(rate({app="foo"}[1m])),shard=0_of_2> ++ downstream<sumby(client)(rate({app="foo"}[1m])),shard=1_of_2>>`,
+		},
+		{
+			// unwrapped samples can be negative.
+			in:  `topk(3, sum by (client) (sum_over_time({app="foo"} | logfmt | unwrap value [1m])))`,
+			out: `topk(3, sumby(client)(downstream<sumby(client)(sum_over_time({app="foo"}|logfmt|unwrapvalue[1m])),shard=0_of_2> ++ downstream<sumby(client)(sum_over_time({app="foo"}|logfmt|unwrapvalue[1m])),shard=1_of_2>))`,
+		},
+		{
+			in:  `topk by (pod) (3, sum by (client, pod) (count_over_time({app="foo"} | logfmt [1m])))`,
+			out: `topkby(pod)(3, sumby(client,pod)(downstream<sumby(client,pod)(count_over_time({app="foo"}|logfmt[1m])),shard=0_of_2> ++ downstream<sumby(client,pod)(count_over_time({app="foo"}|logfmt[1m])),shard=1_of_2>))`,
+		},
+		{
+			in:  `topk(3, sum without (client) (count_over_time({app="foo"} | logfmt [1m])))`,
+			out: `topk(3, sumwithout(client)(downstream<sumwithout(client)(count_over_time({app="foo"}|logfmt[1m])),shard=0_of_2> ++ downstream<sumwithout(client)(count_over_time({app="foo"}|logfmt[1m])),shard=1_of_2>))`,
+		},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			ast, err := syntax.ParseExpr(tc.in)
+			require.Nil(t, err)
+
+			mapped, _, err := m.Map(ast, nilShardMetrics.downstreamRecorder(), true)
+			require.Nil(t, err)
+
+			require.Equal(t, removeWhiteSpace(tc.out), removeWhiteSpace(mapped.String()))
+		})
+	}
+}
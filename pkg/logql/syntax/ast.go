@@ -232,6 +232,11 @@ func (m MultiStageExpr) reorderStages() []StageExpr {
 	return result
 }
 
+// CombineLineFilters chains consecutive line filter stages into a single stage.
+func CombineLineFilters(in []*LineFilterExpr) *LineFilterExpr {
+	return combineFilters(in).(*LineFilterExpr)
+}
+
 func combineFilters(in []*LineFilterExpr) StageExpr {
 	result := in[len(in)-1]
 	for i := len(in) - 2; i >= 0; i-- {
@@ -1593,7 +1598,7 @@ func (e *VectorAggregationExpr) Extractor() (log.SampleExtractor, error) {
 	}
 	// inject in the range vector extractor the outer groups to improve performance.
 	// This is only possible if the operation is a sum. Anything else needs all labels.
-	if r, ok := e.Left.(*RangeAggregationExpr); ok && canInjectVectorGrouping(e.Operation, r.Operation) {
+	if r, ok := e.Left.(*RangeAggregationExpr); ok && CanInjectVectorGrouping(e.Operation, r.Operation) {
 		// if the range vec operation has no grouping we can push down the vec one.
 		if r.Grouping == nil {
 			return r.extractor(e.Grouping)
@@ -1602,8 +1607,8 @@ func (e *VectorAggregationExpr) Extractor() (log.SampleExtractor, error) {
 	return e.Left.Extractor()
 }
 
-// canInjectVectorGrouping tells if a vector operation can inject grouping into the nested range vector.
-func canInjectVectorGrouping(vecOp, rangeOp string) bool {
+// CanInjectVectorGrouping tells if a vector operation can inject grouping into the nested range vector.
+func CanInjectVectorGrouping(vecOp, rangeOp string) bool {
 	if vecOp != OpTypeSum {
 		return false
 	}
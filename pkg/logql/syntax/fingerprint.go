@@ -0,0 +1,65 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"text/scanner"
+
+	"github.com/grafana/loki/v3/pkg/util"
+)
+
+// Normalize returns the canonical form of the expression with its literal values replaced by placeholders.
+// Strings, numbers, durations and bytes are replaced by `?` and range intervals by `[?]`,
+// e.g: `sum(rate({app="foo"} |= "error" [5m])) > 10` -> `sum(rate({app=?} |= ? [?])) > ?`.
+// Queries differing only in their literal values share the same normalized form.
+func Normalize(expr Expr) string {
+	query := expr.String()
+
+	l := lexer{
+		Scanner: Scanner{
+			Mode: scanner.SkipComments | scanner.ScanStrings,
+		},
+	}
+	l.Init(strings.NewReader(query))
+	l.Scanner.Error = func(_ *Scanner, msg string) {
+		l.Error(msg)
+	}
+
+	var (
+		sb    strings.Builder
+		last  int
+		lval  exprSymType
+		tok   int
+		start int
+	)
+	for {
+		tok = l.Lex(&lval)
+		if tok == 0 || len(l.errs) > 0 {
+			break
+		}
+		// The scanner position is at the beginning of the last token scanned.
+		start = l.Position.Offset
+		switch tok {
+		case STRING, NUMBER, DURATION, BYTES:
+			sb.WriteString(query[last:start])
+			sb.WriteString("?")
+		case RANGE:
+			sb.WriteString(query[last:start])
+			sb.WriteString("[?]")
+		default:
+			continue
+		}
+		last = l.Pos().Offset
+	}
+	if len(l.errs) > 0 {
+		// The canonical form of a parsed expression is always valid, fall back to it nonetheless.
+		return query
+	}
+	sb.WriteString(query[last:])
+	return sb.String()
+}
+
+// Fingerprint returns the fingerprint of the expression, the hash of its normalized form.
+func Fingerprint(expr Expr) string {
+	return fmt.Sprintf("%08x", util.HashedQuery(Normalize(expr)))
+}
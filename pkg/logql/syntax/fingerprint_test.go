@@ -0,0 +1,33 @@
+package syntax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		expected string
+	}{
+		{`{app="foo"}`, `{app=?}`},
+		{`{app="foo", env=~"prod|dev"} |= "error" != "timeout"`, `{app=?, env=~?} |= ? != ?`},
+		{`{app="foo"} | json | duration > 10s and size < 20MB | line_format "{{.msg}}"`, `{app=?} | json | ( duration>? , size<? ) | line_format ?`},
+		{`sum by (level) (rate({app="foo"}[5m])) > 10`, `(sum by (level)(rate({app=?}[?])) > ?)`},
+		{`topk(10, sum(count_over_time({app="foo"}[1h] offset 1d)))`, `topk(?,sum(count_over_time({app=?}[?] offset ?)))`},
+		{`quantile_over_time(0.99, {app="foo"} | logfmt | unwrap latency [1m]) by (pod)`, `quantile_over_time(?,{app=?} | logfmt | unwrap latency[?]) by (pod)`},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			require.Equal(t, tc.expected, Normalize(MustParseExpr(tc.query)))
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint(MustParseExpr(`sum(rate({app="foo"} |= "error" [5m]))`))
+	require.Len(t, fp, 8)
+	require.Equal(t, fp, Fingerprint(MustParseExpr(`sum(rate({app="bar"} |= "timeout" [1h]))`)))
+	require.NotEqual(t, fp, Fingerprint(MustParseExpr(`sum(rate({env="bar"} |= "timeout" [1h]))`)))
+	require.NotEqual(t, fp, Fingerprint(MustParseExpr(`sum(count_over_time({app="foo"} |= "error" [5m]))`)))
+}
@@ -51,6 +51,12 @@ type Context struct {
 	// result accumulates results for JoinResult.
 	result Result
 
+	// cpuTime is the CPU time spent evaluating the query, in nanoseconds.
+	cpuTime int64
+	// retainedBytes and peakRetainedBytes are the bytes currently and at most held in memory by the query.
+	retainedBytes     int64
+	peakRetainedBytes int64
+
 	mtx sync.Mutex
 }
 
@@ -133,6 +139,9 @@ func (c *Context) Reset() {
 	c.result.Reset()
 	c.caches.Reset()
 	c.index.Reset()
+	atomic.StoreInt64(&c.cpuTime, 0)
+	atomic.StoreInt64(&c.retainedBytes, 0)
+	atomic.StoreInt64(&c.peakRetainedBytes, 0)
 }
 
 // Result calculates the summary based on store and ingester data.
@@ -149,6 +158,10 @@ func (c *Context) Result(execTime time.Duration, queueTime time.Duration, totalE
 	})
 
 	r.ComputeSummary(execTime, queueTime, totalEntriesReturned)
+	r.Summary.Merge(Summary{
+		CPUTime:         time.Duration(atomic.LoadInt64(&c.cpuTime)).Seconds(),
+		PeakMemoryBytes: atomic.LoadInt64(&c.peakRetainedBytes),
+	})
 
 	return r
 }
@@ -222,6 +235,19 @@ func (s *Store) ChunksDownloadDuration() time.Duration {
 func (s *Summary) Merge(m Summary) {
 	s.Splits += m.Splits
 	s.Shards += m.Shards
+	s.CPUTime += m.CPUTime
+	s.PeakMemoryBytes += m.PeakMemoryBytes
+	if len(m.OptimizerRewrites) > 0 {
+		// results are copied by value, so the map is never updated in place.
+		rewrites := make(map[string]int64, len(s.OptimizerRewrites)+len(m.OptimizerRewrites))
+		for rule, n := range s.OptimizerRewrites {
+			rewrites[rule] += n
+		}
+		for rule, n := range m.OptimizerRewrites {
+			rewrites[rule] += n
+		}
+		s.OptimizerRewrites = rewrites
+	}
 }
 
 func (q *Querier) Merge(m Querier) {
@@ -502,6 +528,27 @@ func (c *Context) AddCacheQueryLengthServed(t CacheType, i time.Duration) {
 	atomic.AddInt64(&stats.QueryLengthServed, int64(i))
 }
 
+// AddCPUTime adds the CPU time spent evaluating the query.
+func (c *Context) AddCPUTime(i time.Duration) {
+	atomic.AddInt64(&c.cpuTime, int64(i))
+}
+
+// RetainBytes records bytes held in memory by the query until they are released, updating its peak memory.
+func (c *Context) RetainBytes(i int64) {
+	retained := atomic.AddInt64(&c.retainedBytes, i)
+	for {
+		peak := atomic.LoadInt64(&c.peakRetainedBytes)
+		if retained <= peak || atomic.CompareAndSwapInt64(&c.peakRetainedBytes, peak, retained) {
+			return
+		}
+	}
+}
+
+// ReleaseBytes records bytes retained by the query that are no longer held in memory.
+func (c *Context) ReleaseBytes(i int64) {
+	atomic.AddInt64(&c.retainedBytes, -i)
+}
+
 func (c *Context) AddSplitQueries(num int64) {
 	atomic.AddInt64(&c.result.Summary.Splits, num)
 }
@@ -584,6 +631,8 @@ func (s Summary) kvList() []any {
 		"Summary.PostFilterLines", s.TotalPostFilterLines,
 		"Summary.ExecTime", ConvertSecondsToNanoseconds(s.ExecTime),
 		"Summary.QueueTime", ConvertSecondsToNanoseconds(s.QueueTime),
+		"Summary.CPUTime", ConvertSecondsToNanoseconds(s.CPUTime),
+		"Summary.PeakMemoryBytes", humanize.Bytes(uint64(s.PeakMemoryBytes)),
 	}
 }
 
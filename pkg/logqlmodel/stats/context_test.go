@@ -296,9 +296,40 @@ func TestResult_Merge(t *testing.T) {
 	}, res)
 }
 
+func TestResult_MergeOptimizerRewrites(t *testing.T) {
+	statsCtx, ctx := NewContext(context.Background())
+	shard := Result{Summary: Summary{OptimizerRewrites: map[string]int64{"push_line_filters": 1}}}
+	JoinResults(ctx, shard)
+	JoinResults(ctx, Result{Summary: Summary{OptimizerRewrites: map[string]int64{"push_line_filters": 1, "merge_line_filters": 1}}})
+	JoinResults(ctx, Result{})
+
+	res := statsCtx.Result(time.Second, 0, 0)
+	require.Equal(t, map[string]int64{"push_line_filters": 2, "merge_line_filters": 1}, res.Summary.OptimizerRewrites)
+	// the merged results are left unchanged.
+	require.Equal(t, map[string]int64{"push_line_filters": 1}, shard.Summary.OptimizerRewrites)
+}
+
+func TestResult_CPUTimeAndPeakMemory(t *testing.T) {
+	statsCtx, ctx := NewContext(context.Background())
+	statsCtx.AddCPUTime(time.Second)
+	statsCtx.RetainBytes(100)
+	statsCtx.RetainBytes(50)
+	statsCtx.ReleaseBytes(100)
+	statsCtx.RetainBytes(80)
+	statsCtx.ReleaseBytes(130)
+	// the subqueries of the query are summed.
+	JoinResults(ctx, Result{Summary: Summary{CPUTime: 2, PeakMemoryBytes: 1000}})
+
+	res := statsCtx.Result(time.Second, 0, 0)
+	require.Equal(t, 3.0, res.Summary.CPUTime)
+	require.Equal(t, int64(1150), res.Summary.PeakMemoryBytes)
+}
+
 func TestReset(t *testing.T) {
 	statsCtx, ctx := NewContext(context.Background())
 	fakeIngesterQuery(ctx)
+	statsCtx.AddCPUTime(time.Second)
+	statsCtx.RetainBytes(100)
 	res := statsCtx.Result(2*time.Second, 2*time.Millisecond, 10)
 	require.NotEmpty(t, res)
 	statsCtx.Reset()
@@ -8,6 +8,7 @@ import (
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
 	io "io"
 	math "math"
 	math_bits "math/bits"
@@ -232,6 +233,12 @@ type Summary struct {
 	TotalPostFilterLines int64 `protobuf:"varint,11,opt,name=totalPostFilterLines,proto3" json:"totalPostFilterLines"`
 	// Total bytes processed of metadata.
 	TotalStructuredMetadataBytesProcessed int64 `protobuf:"varint,12,opt,name=totalStructuredMetadataBytesProcessed,proto3" json:"totalStructuredMetadataBytesProcessed"`
+	// Number of rewrites applied by the LogQL optimizer to the queries executed, by rule.
+	OptimizerRewrites map[string]int64 `protobuf:"bytes,13,rep,name=optimizerRewrites,proto3" json:"optimizerRewrites,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// CPU time in seconds spent evaluating the query, summed over its subqueries.
+	CPUTime float64 `protobuf:"fixed64,14,opt,name=cpuTime,proto3" json:"cpuTime,omitempty"`
+	// Peak bytes of chunks and results held in memory by the query, summed over its subqueries.
+	PeakMemoryBytes int64 `protobuf:"varint,15,opt,name=peakMemoryBytes,proto3" json:"peakMemoryBytes,omitempty"`
 }
 
 func (m *Summary) Reset()      { *m = Summary{} }
@@ -350,6 +357,27 @@ func (m *Summary) GetTotalStructuredMetadataBytesProcessed() int64 {
 	return 0
 }
 
+func (m *Summary) GetOptimizerRewrites() map[string]int64 {
+	if m != nil {
+		return m.OptimizerRewrites
+	}
+	return nil
+}
+
+func (m *Summary) GetCPUTime() float64 {
+	if m != nil {
+		return m.CPUTime
+	}
+	return 0
+}
+
+func (m *Summary) GetPeakMemoryBytes() int64 {
+	if m != nil {
+		return m.PeakMemoryBytes
+	}
+	return 0
+}
+
 // Statistics from Index queries
 // TODO(owen-d): include bytes.
 // Needs some index methods added to return _sized_ chunk refs to know
@@ -871,6 +899,7 @@ func init() {
 	proto.RegisterType((*Result)(nil), "stats.Result")
 	proto.RegisterType((*Caches)(nil), "stats.Caches")
 	proto.RegisterType((*Summary)(nil), "stats.Summary")
+	proto.RegisterMapType((map[string]int64)(nil), "stats.Summary.OptimizerRewritesEntry")
 	proto.RegisterType((*Index)(nil), "stats.Index")
 	proto.RegisterType((*Querier)(nil), "stats.Querier")
 	proto.RegisterType((*Ingester)(nil), "stats.Ingester")
@@ -882,95 +911,103 @@ func init() {
 func init() { proto.RegisterFile("pkg/logqlmodel/stats/stats.proto", fileDescriptor_6cdfe5d2aea33ebb) }
 
 var fileDescriptor_6cdfe5d2aea33ebb = []byte{
-	// 1397 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x58, 0xcd, 0x8f, 0xdc, 0xc4,
-	0x12, 0x9f, 0xd9, 0x89, 0x67, 0x37, 0xbd, 0x5f, 0x49, 0xef, 0xe6, 0x65, 0xf2, 0x12, 0xd9, 0xfb,
-	0xe6, 0x11, 0x11, 0x84, 0xb4, 0xa3, 0x10, 0x24, 0x04, 0x22, 0x12, 0xf2, 0x86, 0x95, 0x22, 0x6d,
-	0x44, 0xa8, 0x05, 0x81, 0xe0, 0xe4, 0xb5, 0x7b, 0x67, 0xac, 0x78, 0xdc, 0xb3, 0x76, 0x7b, 0xc9,
-	0x9e, 0xe0, 0x4f, 0xe0, 0xce, 0x1d, 0x71, 0xe1, 0xc4, 0x89, 0x33, 0x97, 0x1c, 0x73, 0xcc, 0xc9,
-	0x22, 0x93, 0x0b, 0xf2, 0x29, 0x67, 0xc4, 0x01, 0x75, 0x75, 0x8f, 0xbf, 0xc6, 0xb3, 0xd9, 0xcb,
-	0xb8, 0xeb, 0x57, 0xf5, 0xab, 0x6a, 0x97, 0xab, 0xab, 0x5a, 0x43, 0x76, 0x26, 0x4f, 0x86, 0x83,
-	0x80, 0x0f, 0x4f, 0x82, 0x31, 0xf7, 0x58, 0x30, 0x88, 0x85, 0x23, 0x62, 0xf5, 0xbb, 0x3b, 0x89,
-	0xb8, 0xe0, 0xd4, 0x40, 0xe1, 0xbf, 0xdb, 0x43, 0x3e, 0xe4, 0x88, 0x0c, 0xe4, 0x4a, 0x29, 0xfb,
-	0x3f, 0x2f, 0x91, 0x2e, 0xb0, 0x38, 0x09, 0x04, 0xfd, 0x90, 0x2c, 0xc7, 0xc9, 0x78, 0xec, 0x44,
-	0x67, 0xbd, 0xf6, 0x4e, 0xfb, 0xce, 0xea, 0x7b, 0x1b, 0xbb, 0xca, 0xcd, 0xa1, 0x42, 0xed, 0xcd,
-	0x67, 0xa9, 0xd5, 0xca, 0x52, 0x6b, 0x66, 0x06, 0xb3, 0x85, 0xa4, 0x9e, 0x24, 0x2c, 0xf2, 0x59,
-	0xd4, 0x5b, 0xaa, 0x50, 0x3f, 0x57, 0x68, 0x41, 0xd5, 0x66, 0x30, 0x5b, 0xd0, 0xfb, 0x64, 0xc5,
-	0x0f, 0x87, 0x2c, 0x16, 0x2c, 0xea, 0x75, 0x90, 0xbb, 0xa9, 0xb9, 0x0f, 0x35, 0x6c, 0x5f, 0xd1,
-	0xe4, 0xdc, 0x10, 0xf2, 0x15, 0x7d, 0x9f, 0x74, 0x5d, 0xc7, 0x1d, 0xb1, 0xb8, 0x77, 0x09, 0xc9,
-	0xeb, 0x9a, 0xbc, 0x87, 0xa0, 0xbd, 0xae, 0xa9, 0x06, 0x1a, 0x81, 0xb6, 0xa5, 0x77, 0x89, 0xe1,
-	0x87, 0x1e, 0x7b, 0xda, 0x33, 0x90, 0xb4, 0x96, 0x47, 0xf4, 0xd8, 0xd3, 0x82, 0x83, 0x26, 0xa0,
-	0x1e, 0xfd, 0x9f, 0x2e, 0x91, 0xee, 0x5e, 0xce, 0x76, 0x47, 0x49, 0xf8, 0x44, 0xa7, 0x69, 0xad,
-	0x1c, 0xb2, 0x14, 0x51, 0x9a, 0x80, 0x7a, 0x14, 0x01, 0x97, 0xce, 0xa3, 0x94, 0x03, 0xca, 0x37,
-	0x8b, 0xf0, 0xc3, 0xe8, 0xb4, 0x54, 0x39, 0x1b, 0x9a, 0xa3, 0x6d, 0x40, 0x3f, 0xe9, 0x1e, 0x59,
-	0x45, 0x33, 0xf5, 0x4d, 0x75, 0x52, 0xaa, 0xd4, 0x2d, 0x4d, 0x2d, 0x1b, 0x42, 0x59, 0xa0, 0xfb,
-	0x64, 0xed, 0x94, 0x07, 0xc9, 0x98, 0x69, 0x2f, 0x46, 0x83, 0x97, 0x6d, 0xed, 0xa5, 0x62, 0x09,
-	0x15, 0x49, 0xfa, 0x89, 0xe5, 0x57, 0x9e, 0xed, 0xa6, 0x7b, 0x9e, 0x9f, 0xb2, 0x25, 0x54, 0x24,
-	0xf9, 0x52, 0x81, 0x73, 0xc4, 0x02, 0xed, 0x66, 0xf9, 0xbc, 0x97, 0x2a, 0x19, 0x42, 0x59, 0xa0,
-	0xdf, 0x92, 0x2d, 0x3f, 0x8c, 0x85, 0x13, 0x8a, 0x47, 0x4c, 0x44, 0xbe, 0xab, 0x9d, 0xad, 0x34,
-	0x38, 0xbb, 0xa9, 0x9d, 0x35, 0x11, 0xa0, 0x09, 0xec, 0xff, 0xde, 0x25, 0xcb, 0xfa, 0x98, 0xd0,
-	0x2f, 0xc9, 0xf5, 0xa3, 0x33, 0xc1, 0xe2, 0xc7, 0x11, 0x77, 0x59, 0x1c, 0x33, 0xef, 0x31, 0x8b,
-	0x0e, 0x99, 0xcb, 0x43, 0x0f, 0x0b, 0xa6, 0x63, 0xdf, 0xcc, 0x52, 0x6b, 0x91, 0x09, 0x2c, 0x52,
-	0x48, 0xb7, 0x81, 0x1f, 0x36, 0xba, 0x5d, 0x2a, 0xdc, 0x2e, 0x30, 0x81, 0x45, 0x0a, 0xfa, 0x90,
-	0x6c, 0x09, 0x2e, 0x9c, 0xc0, 0xae, 0x84, 0xc5, 0x9a, 0xeb, 0xd8, 0xd7, 0x65, 0x12, 0x1a, 0xd4,
-	0xd0, 0x04, 0xe6, 0xae, 0x0e, 0x2a, 0xa1, 0xb0, 0x06, 0xcb, 0xae, 0xaa, 0x6a, 0x68, 0x02, 0xe9,
-	0x1d, 0xb2, 0xc2, 0x9e, 0x32, 0xf7, 0x0b, 0x7f, 0xcc, 0xb0, 0xfa, 0xda, 0xf6, 0x9a, 0x6c, 0x00,
-	0x33, 0x0c, 0xf2, 0x15, 0x7d, 0x97, 0x5c, 0x3e, 0x49, 0x58, 0xc2, 0xd0, 0xb4, 0x8b, 0xa6, 0xeb,
-	0x59, 0x6a, 0x15, 0x20, 0x14, 0x4b, 0xba, 0x4b, 0x48, 0x9c, 0x1c, 0xa9, 0xd6, 0x13, 0x63, 0x1d,
-	0x75, 0xec, 0x8d, 0x2c, 0xb5, 0x4a, 0x28, 0x94, 0xd6, 0xf4, 0x80, 0x6c, 0xe3, 0xee, 0x3e, 0x0d,
-	0x85, 0x2a, 0x47, 0x91, 0x44, 0x21, 0xf3, 0xb0, 0x68, 0x3a, 0x76, 0x2f, 0x4b, 0xad, 0x46, 0x3d,
-	0x34, 0xa2, 0xb4, 0x4f, 0xba, 0xf1, 0x24, 0xf0, 0x45, 0xdc, 0xbb, 0x8c, 0x7c, 0x22, 0xcf, 0xaf,
-	0x42, 0x40, 0x3f, 0xd1, 0x66, 0xe4, 0x44, 0x5e, 0xdc, 0x23, 0x25, 0x1b, 0x44, 0x40, 0x3f, 0xf3,
-	0x5d, 0x3d, 0xe6, 0xb1, 0xd8, 0xf7, 0x03, 0xc1, 0x22, 0xcc, 0x5e, 0x6f, 0xb5, 0xb6, 0xab, 0x9a,
-	0x1e, 0x1a, 0x51, 0xfa, 0x3d, 0xb9, 0x8d, 0xf8, 0xa1, 0x88, 0x12, 0x57, 0x24, 0x11, 0xf3, 0x1e,
-	0x31, 0xe1, 0x78, 0x8e, 0x70, 0x6a, 0x25, 0xb1, 0x86, 0xee, 0xdf, 0xc9, 0x52, 0xeb, 0x62, 0x04,
-	0xb8, 0x98, 0x59, 0xff, 0xef, 0x36, 0x31, 0xb0, 0xf3, 0xd2, 0xbb, 0x64, 0x15, 0x29, 0x7b, 0xb2,
-	0x67, 0xc6, 0xfa, 0xb4, 0x6c, 0xca, 0x53, 0x5d, 0x82, 0xa1, 0x2c, 0xd0, 0x4f, 0xc8, 0x95, 0x49,
-	0xfe, 0x42, 0x9a, 0xa7, 0x8e, 0xc3, 0x76, 0x96, 0x5a, 0x73, 0x3a, 0x98, 0x43, 0xe8, 0x47, 0x64,
-	0x43, 0xe5, 0xf5, 0x41, 0x12, 0x39, 0xc2, 0xe7, 0xa1, 0xae, 0x7d, 0x9a, 0xa5, 0x56, 0x4d, 0x03,
-	0x35, 0x59, 0x46, 0x4f, 0x62, 0xe6, 0xd9, 0x01, 0xe7, 0x63, 0xe5, 0x54, 0xcd, 0xa1, 0x15, 0x15,
-	0xbd, 0xae, 0x83, 0x39, 0xa4, 0xff, 0x31, 0x59, 0xd6, 0x33, 0x52, 0xce, 0x88, 0x58, 0xf0, 0x88,
-	0xd5, 0xc6, 0xca, 0xa1, 0xc4, 0x8a, 0x19, 0x81, 0x26, 0xa0, 0x1e, 0xfd, 0x5f, 0x97, 0xc8, 0xca,
-	0xc3, 0x62, 0x14, 0xae, 0x61, 0x66, 0x80, 0xc9, 0x26, 0xa6, 0x9a, 0x8d, 0x61, 0x5f, 0x91, 0xbd,
-	0xb5, 0x8c, 0x43, 0x45, 0xa2, 0xfb, 0x84, 0x96, 0xf2, 0xf9, 0xc8, 0x11, 0xc8, 0x55, 0x29, 0xfc,
-	0x4f, 0x96, 0x5a, 0x0d, 0x5a, 0x68, 0xc0, 0xf2, 0xe8, 0x36, 0xca, 0xb1, 0x4e, 0x62, 0x11, 0x5d,
-	0xe3, 0x50, 0x91, 0x64, 0xf2, 0x8b, 0xe3, 0x7f, 0xc8, 0x42, 0xa1, 0xbb, 0x05, 0x26, 0xbf, 0xaa,
-	0x81, 0x9a, 0x5c, 0xe4, 0xcb, 0xb8, 0x70, 0xbe, 0xfe, 0xb9, 0x44, 0x0c, 0xd4, 0xe7, 0x81, 0x75,
-	0x59, 0xb0, 0x63, 0x5d, 0x6d, 0x45, 0xe0, 0x5c, 0x03, 0x35, 0x99, 0x7e, 0x46, 0xae, 0x95, 0x90,
-	0x07, 0xfc, 0xbb, 0x30, 0xe0, 0x8e, 0x97, 0x67, 0xed, 0x46, 0x96, 0x5a, 0xcd, 0x06, 0xd0, 0x0c,
-	0xcb, 0x6f, 0xe0, 0x56, 0x30, 0x6c, 0x66, 0x9d, 0xe2, 0x1b, 0xcc, 0x6b, 0xa1, 0x01, 0xa3, 0x2e,
-	0xb9, 0x21, 0x3b, 0xd7, 0x19, 0xb0, 0x63, 0x16, 0xb1, 0xd0, 0x65, 0x5e, 0x71, 0xf8, 0x7a, 0xeb,
-	0x58, 0x97, 0xb7, 0xb3, 0xd4, 0xfa, 0xdf, 0x42, 0xa3, 0xd9, 0x09, 0x85, 0xc5, 0x7e, 0x8a, 0xdb,
-	0x4f, 0xed, 0x6e, 0x21, 0xb1, 0x05, 0xb7, 0x9f, 0xd9, 0xfb, 0x01, 0x3b, 0x8e, 0xf7, 0x99, 0x70,
-	0x47, 0x79, 0x5f, 0x2f, 0xbf, 0x5f, 0x45, 0x0b, 0x0d, 0x18, 0xfd, 0x9a, 0xf4, 0x5c, 0x8e, 0xe5,
-	0xee, 0xf3, 0x70, 0x8f, 0x87, 0x22, 0xe2, 0xc1, 0x81, 0x23, 0x58, 0xe8, 0x9e, 0x61, 0xeb, 0xef,
-	0xd8, 0xb7, 0xb2, 0xd4, 0x5a, 0x68, 0x03, 0x0b, 0x35, 0xd4, 0x23, 0xb7, 0x26, 0xfe, 0x84, 0xc9,
-	0x21, 0xf9, 0x55, 0xe4, 0x4c, 0x26, 0x2c, 0x52, 0x07, 0x94, 0x79, 0xaa, 0xb5, 0xaa, 0x51, 0xb1,
-	0x93, 0xa5, 0xd6, 0xb9, 0x76, 0x70, 0xae, 0xb6, 0xff, 0x9b, 0x41, 0x0c, 0xcc, 0x93, 0x2c, 0xbf,
-	0x11, 0x73, 0x3c, 0x95, 0x34, 0xd9, 0x0e, 0xcb, 0x75, 0x5f, 0xd5, 0x40, 0x4d, 0xae, 0x70, 0xd5,
-	0xee, 0x8c, 0x06, 0xae, 0xda, 0x4f, 0x4d, 0xa6, 0x7b, 0xe4, 0xaa, 0xc7, 0x5c, 0x3e, 0x9e, 0x44,
-	0xd8, 0x7b, 0x55, 0x68, 0x95, 0xba, 0x6b, 0x59, 0x6a, 0xcd, 0x2b, 0x61, 0x1e, 0xaa, 0x3b, 0x29,
-	0x67, 0x68, 0xce, 0x89, 0xda, 0xc6, 0x3c, 0x44, 0xef, 0x93, 0xcd, 0xfa, 0x3e, 0xd4, 0x54, 0xdd,
-	0xca, 0x52, 0xab, 0xae, 0x82, 0x3a, 0x20, 0xe9, 0x78, 0x96, 0x1e, 0x24, 0x93, 0xc0, 0x77, 0x1d,
-	0x49, 0xbf, 0x5c, 0xd0, 0x6b, 0x2a, 0xa8, 0x03, 0x92, 0x3e, 0xa9, 0x4d, 0x4f, 0x52, 0xd0, 0x6b,
-	0x2a, 0xa8, 0x03, 0x74, 0x42, 0x76, 0xf2, 0xc4, 0x2e, 0x98, 0x6f, 0x7a, 0x1a, 0xbf, 0x95, 0xa5,
-	0xd6, 0x1b, 0x6d, 0xe1, 0x8d, 0x16, 0xf4, 0x8c, 0xfc, 0xbf, 0x9c, 0xc3, 0x45, 0x41, 0xd5, 0x8c,
-	0x7e, 0x3b, 0x4b, 0xad, 0x8b, 0x98, 0xc3, 0x45, 0x8c, 0xfa, 0x7f, 0x74, 0x88, 0x81, 0xf7, 0x62,
-	0xd9, 0xe3, 0x99, 0xba, 0xd3, 0xec, 0xf3, 0x24, 0xac, 0x4c, 0x98, 0x32, 0x0e, 0x15, 0x49, 0x0e,
-	0x49, 0x36, 0xbb, 0x09, 0x9d, 0x24, 0x72, 0x56, 0xa9, 0x4e, 0x69, 0xa8, 0x21, 0x59, 0xd7, 0xc1,
-	0x1c, 0x42, 0x3f, 0x20, 0xeb, 0x1a, 0xc3, 0xe6, 0xad, 0x6e, 0xa7, 0x86, 0x7d, 0x35, 0x4b, 0xad,
-	0xaa, 0x02, 0xaa, 0xa2, 0x24, 0xe2, 0x75, 0x1a, 0x98, 0xcb, 0xfc, 0xd3, 0xfc, 0x2e, 0x8a, 0xc4,
-	0x8a, 0x02, 0xaa, 0xa2, 0xbc, 0x55, 0x22, 0x80, 0x23, 0x49, 0x1d, 0x2f, 0xbc, 0x55, 0xe6, 0x20,
-	0x14, 0x4b, 0x79, 0x59, 0x8d, 0xd4, 0x5e, 0xd5, 0x59, 0x32, 0xd4, 0x65, 0x75, 0x86, 0x41, 0xbe,
-	0x92, 0x09, 0xf4, 0xca, 0x2d, 0x7e, 0xb9, 0x18, 0x92, 0x65, 0x1c, 0x2a, 0x92, 0x3c, 0x6f, 0xd8,
-	0x8e, 0x0f, 0x58, 0x38, 0x14, 0xa3, 0x43, 0x16, 0x9d, 0xe6, 0x57, 0x50, 0x3c, 0x6f, 0x73, 0x4a,
-	0x98, 0x87, 0x6c, 0xf6, 0xfc, 0xa5, 0xd9, 0x7a, 0xf1, 0xd2, 0x6c, 0xbd, 0x7e, 0x69, 0xb6, 0x7f,
-	0x98, 0x9a, 0xed, 0x5f, 0xa6, 0x66, 0xfb, 0xd9, 0xd4, 0x6c, 0x3f, 0x9f, 0x9a, 0xed, 0x3f, 0xa7,
-	0x66, 0xfb, 0xaf, 0xa9, 0xd9, 0x7a, 0x3d, 0x35, 0xdb, 0x3f, 0xbe, 0x32, 0x5b, 0xcf, 0x5f, 0x99,
-	0xad, 0x17, 0xaf, 0xcc, 0xd6, 0x37, 0x83, 0xa1, 0x2f, 0x46, 0xc9, 0xd1, 0xae, 0xcb, 0xc7, 0x83,
-	0x61, 0xe4, 0x1c, 0x3b, 0xa1, 0x33, 0x08, 0xf8, 0x13, 0x7f, 0x70, 0x7a, 0x6f, 0xd0, 0xf4, 0xc7,
-	0xc3, 0x51, 0x17, 0xff, 0x56, 0xb8, 0xf7, 0x6f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x2a, 0x3e, 0x6e,
-	0x00, 0x97, 0x10, 0x00, 0x00,
+	// 1532 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x58, 0xcd, 0x6f, 0xdc, 0x54,
+	0x10, 0xcf, 0x66, 0xe3, 0x24, 0x7d, 0xf9, 0x7e, 0x49, 0x5b, 0xf7, 0x83, 0x75, 0x58, 0xa8, 0x28,
+	0x02, 0x65, 0xd5, 0x16, 0x09, 0xa8, 0xa8, 0x84, 0x9c, 0x10, 0x54, 0x29, 0x51, 0xc3, 0x84, 0x0a,
+	0x04, 0x27, 0xc7, 0x9e, 0xec, 0x5a, 0xf1, 0xda, 0x8e, 0xfd, 0x9c, 0x76, 0xb9, 0xc0, 0x9f, 0xc0,
+	0x9d, 0x3b, 0xe2, 0xc2, 0x89, 0x3f, 0x81, 0x4b, 0x8f, 0x3d, 0xf6, 0x64, 0xd1, 0xed, 0x05, 0xf9,
+	0xd4, 0x73, 0xc5, 0x01, 0xbd, 0xf7, 0xbc, 0xfe, 0x5a, 0x6f, 0x9a, 0x4b, 0xfc, 0xe6, 0x37, 0xf3,
+	0x9b, 0xf7, 0x3c, 0x9e, 0x37, 0x33, 0x59, 0xb2, 0xe9, 0x9f, 0x74, 0x3b, 0x8e, 0xd7, 0x3d, 0x75,
+	0xfa, 0x9e, 0x85, 0x4e, 0x27, 0x64, 0x06, 0x0b, 0xe5, 0xdf, 0x2d, 0x3f, 0xf0, 0x98, 0x47, 0x15,
+	0x21, 0x5c, 0xdf, 0xe8, 0x7a, 0x5d, 0x4f, 0x20, 0x1d, 0xbe, 0x92, 0xca, 0xf6, 0xef, 0xd3, 0x64,
+	0x16, 0x30, 0x8c, 0x1c, 0x46, 0x3f, 0x27, 0x73, 0x61, 0xd4, 0xef, 0x1b, 0xc1, 0x40, 0x6d, 0x6c,
+	0x36, 0x6e, 0x2f, 0xdc, 0x5d, 0xde, 0x92, 0x6e, 0x0e, 0x25, 0xaa, 0xaf, 0x3c, 0x8b, 0xb5, 0xa9,
+	0x24, 0xd6, 0x46, 0x66, 0x30, 0x5a, 0x70, 0xea, 0x69, 0x84, 0x81, 0x8d, 0x81, 0x3a, 0x5d, 0xa2,
+	0x7e, 0x23, 0xd1, 0x9c, 0x9a, 0x9a, 0xc1, 0x68, 0x41, 0x1f, 0x90, 0x79, 0xdb, 0xed, 0x62, 0xc8,
+	0x30, 0x50, 0x9b, 0x82, 0xbb, 0x92, 0x72, 0x1f, 0xa6, 0xb0, 0xbe, 0x9a, 0x92, 0x33, 0x43, 0xc8,
+	0x56, 0xf4, 0x13, 0x32, 0x6b, 0x1a, 0x66, 0x0f, 0x43, 0x75, 0x46, 0x90, 0x97, 0x52, 0xf2, 0xb6,
+	0x00, 0xf5, 0xa5, 0x94, 0xaa, 0x08, 0x23, 0x48, 0x6d, 0xe9, 0x1d, 0xa2, 0xd8, 0xae, 0x85, 0x4f,
+	0x55, 0x45, 0x90, 0x16, 0xb3, 0x1d, 0x2d, 0x7c, 0x9a, 0x73, 0x84, 0x09, 0xc8, 0x47, 0xfb, 0xb7,
+	0x19, 0x32, 0xbb, 0x9d, 0xb1, 0xcd, 0x5e, 0xe4, 0x9e, 0xa8, 0x8d, 0x12, 0x5b, 0x68, 0x0b, 0x3b,
+	0x72, 0x13, 0x90, 0x8f, 0x7c, 0xc3, 0xe9, 0xf3, 0x28, 0xc5, 0x0d, 0xf9, 0x9b, 0x05, 0xe2, 0xc3,
+	0xa8, 0xcd, 0x1a, 0xce, 0x72, 0xca, 0x49, 0x6d, 0x20, 0x7d, 0xd2, 0x6d, 0xb2, 0x20, 0xcc, 0xe4,
+	0x37, 0x55, 0x67, 0x6a, 0xa8, 0xeb, 0x29, 0xb5, 0x68, 0x08, 0x45, 0x81, 0xee, 0x92, 0xc5, 0x33,
+	0xcf, 0x89, 0xfa, 0x98, 0x7a, 0x51, 0x6a, 0xbc, 0x6c, 0xa4, 0x5e, 0x4a, 0x96, 0x50, 0x92, 0xb8,
+	0x9f, 0x90, 0x7f, 0xe5, 0xd1, 0x69, 0x66, 0xcf, 0xf3, 0x53, 0xb4, 0x84, 0x92, 0xc4, 0x5f, 0xca,
+	0x31, 0x8e, 0xd0, 0x49, 0xdd, 0xcc, 0x9d, 0xf7, 0x52, 0x05, 0x43, 0x28, 0x0a, 0xf4, 0x47, 0xb2,
+	0x6e, 0xbb, 0x21, 0x33, 0x5c, 0xb6, 0x8f, 0x2c, 0xb0, 0xcd, 0xd4, 0xd9, 0x7c, 0x8d, 0xb3, 0x1b,
+	0xa9, 0xb3, 0x3a, 0x02, 0xd4, 0x81, 0xed, 0x37, 0xf3, 0x64, 0x2e, 0xbd, 0x26, 0xf4, 0x31, 0xb9,
+	0x7a, 0x34, 0x60, 0x18, 0x1e, 0x04, 0x9e, 0x89, 0x61, 0x88, 0xd6, 0x01, 0x06, 0x87, 0x68, 0x7a,
+	0xae, 0x25, 0x12, 0xa6, 0xa9, 0xdf, 0x48, 0x62, 0x6d, 0x92, 0x09, 0x4c, 0x52, 0x70, 0xb7, 0x8e,
+	0xed, 0xd6, 0xba, 0x9d, 0xce, 0xdd, 0x4e, 0x30, 0x81, 0x49, 0x0a, 0xfa, 0x90, 0xac, 0x33, 0x8f,
+	0x19, 0x8e, 0x5e, 0xda, 0x56, 0xe4, 0x5c, 0x53, 0xbf, 0xca, 0x83, 0x50, 0xa3, 0x86, 0x3a, 0x30,
+	0x73, 0xb5, 0x57, 0xda, 0x4a, 0x9d, 0xa9, 0xb8, 0x2a, 0xab, 0xa1, 0x0e, 0xa4, 0xb7, 0xc9, 0x3c,
+	0x3e, 0x45, 0xf3, 0x5b, 0xbb, 0x8f, 0x22, 0xfb, 0x1a, 0xfa, 0x22, 0x2f, 0x00, 0x23, 0x0c, 0xb2,
+	0x15, 0xfd, 0x88, 0x5c, 0x3a, 0x8d, 0x30, 0x42, 0x61, 0x3a, 0x2b, 0x4c, 0x97, 0x92, 0x58, 0xcb,
+	0x41, 0xc8, 0x97, 0x74, 0x8b, 0x90, 0x30, 0x3a, 0x92, 0xa5, 0x27, 0x14, 0x79, 0xd4, 0xd4, 0x97,
+	0x93, 0x58, 0x2b, 0xa0, 0x50, 0x58, 0xd3, 0x3d, 0xb2, 0x21, 0x4e, 0xf7, 0x95, 0xcb, 0x84, 0x0e,
+	0x59, 0x14, 0xb8, 0x68, 0x89, 0xa4, 0x69, 0xea, 0x6a, 0x12, 0x6b, 0xb5, 0x7a, 0xa8, 0x45, 0x69,
+	0x9b, 0xcc, 0x86, 0xbe, 0x63, 0xb3, 0x50, 0xbd, 0x24, 0xf8, 0x84, 0xdf, 0x5f, 0x89, 0x40, 0xfa,
+	0x14, 0x36, 0x3d, 0x23, 0xb0, 0x42, 0x95, 0x14, 0x6c, 0x04, 0x02, 0xe9, 0x33, 0x3b, 0xd5, 0x81,
+	0x17, 0xb2, 0x5d, 0xdb, 0x61, 0x18, 0x88, 0xe8, 0xa9, 0x0b, 0x95, 0x53, 0x55, 0xf4, 0x50, 0x8b,
+	0xd2, 0x9f, 0xc9, 0x2d, 0x81, 0x1f, 0xb2, 0x20, 0x32, 0x59, 0x14, 0xa0, 0xb5, 0x8f, 0xcc, 0xb0,
+	0x0c, 0x66, 0x54, 0x52, 0x62, 0x51, 0xb8, 0xff, 0x30, 0x89, 0xb5, 0x8b, 0x11, 0xe0, 0x62, 0x66,
+	0x94, 0x91, 0x35, 0xcf, 0x67, 0x76, 0xdf, 0xfe, 0x09, 0x03, 0xc0, 0x27, 0x81, 0xcd, 0x30, 0x54,
+	0x97, 0x36, 0x9b, 0xb7, 0x17, 0xee, 0xde, 0x2a, 0x77, 0xa0, 0xad, 0x47, 0x55, 0x3b, 0x1e, 0xe2,
+	0x81, 0xae, 0x25, 0xb1, 0x76, 0x63, 0xcc, 0xc7, 0xc7, 0x5e, 0xdf, 0x66, 0xd8, 0xf7, 0xd9, 0x00,
+	0xc6, 0x37, 0xa0, 0xf7, 0xc9, 0x9c, 0xe9, 0x47, 0x22, 0x6b, 0x96, 0x45, 0xd6, 0x6c, 0x0e, 0x63,
+	0x6d, 0x6e, 0xfb, 0xe0, 0x31, 0x87, 0x92, 0x58, 0x5b, 0x4b, 0xb5, 0x05, 0x2f, 0x23, 0x02, 0xfd,
+	0x9a, 0xac, 0xf8, 0x68, 0x9c, 0xec, 0x63, 0xdf, 0x0b, 0x06, 0xe2, 0x6d, 0xd4, 0x15, 0x11, 0x9c,
+	0x77, 0x92, 0x58, 0xbb, 0x56, 0x51, 0x15, 0x1c, 0x54, 0x59, 0xd7, 0x77, 0xc8, 0x95, 0xfa, 0x57,
+	0xa2, 0xab, 0xa4, 0x79, 0x82, 0xb2, 0x11, 0x5f, 0x02, 0xbe, 0xa4, 0x1b, 0x44, 0x39, 0x33, 0x9c,
+	0x08, 0xe5, 0x6d, 0x07, 0x29, 0xdc, 0x9f, 0xfe, 0xac, 0xd1, 0x7e, 0xd3, 0x20, 0x8a, 0x68, 0x5d,
+	0xf4, 0x0e, 0x59, 0x10, 0x31, 0xdf, 0xe6, 0x4d, 0x27, 0x4c, 0xcb, 0xcd, 0x0a, 0x2f, 0x8b, 0x05,
+	0x18, 0x8a, 0x02, 0xfd, 0x92, 0xac, 0xfa, 0x59, 0x46, 0xa4, 0x3c, 0x59, 0x4f, 0x36, 0x92, 0x58,
+	0x1b, 0xd3, 0xc1, 0x18, 0x42, 0xef, 0x93, 0x65, 0x99, 0x98, 0x3b, 0x51, 0x60, 0x30, 0xdb, 0x73,
+	0xd3, 0xe2, 0x41, 0x93, 0x58, 0xab, 0x68, 0xa0, 0x22, 0xf3, 0xdd, 0xa3, 0x10, 0x2d, 0xdd, 0xf1,
+	0xbc, 0xbe, 0x74, 0x2a, 0x1b, 0xf9, 0xbc, 0xdc, 0xbd, 0xaa, 0x83, 0x31, 0xa4, 0xfd, 0x05, 0x99,
+	0x4b, 0x87, 0x0c, 0xde, 0x64, 0x43, 0xe6, 0x05, 0x58, 0xe9, 0xcb, 0x87, 0x1c, 0xcb, 0x9b, 0xac,
+	0x30, 0x01, 0xf9, 0x68, 0xff, 0x39, 0x4d, 0xe6, 0x1f, 0xe6, 0xb3, 0xc4, 0xa2, 0x88, 0x0c, 0x20,
+	0xef, 0x02, 0xb2, 0x5a, 0x2b, 0xfa, 0x2a, 0x6f, 0x4e, 0x45, 0x1c, 0x4a, 0x12, 0xdd, 0x25, 0xb4,
+	0x10, 0xcf, 0x7d, 0x83, 0x09, 0xae, 0x0c, 0xe1, 0x95, 0x24, 0xd6, 0x6a, 0xb4, 0x50, 0x83, 0x65,
+	0xbb, 0xeb, 0x42, 0x0e, 0xd3, 0x20, 0xe6, 0xbb, 0xa7, 0x38, 0x94, 0x24, 0x1e, 0xfc, 0xbc, 0x7e,
+	0x1e, 0xa2, 0xcb, 0xd4, 0x99, 0x3c, 0xf8, 0x65, 0x0d, 0x54, 0xe4, 0x3c, 0x5e, 0xca, 0x85, 0xe3,
+	0xf5, 0xdf, 0x0c, 0x51, 0x84, 0x3e, 0xdb, 0x38, 0x4d, 0x0b, 0x3c, 0x56, 0x1b, 0x95, 0x8d, 0x33,
+	0x0d, 0x54, 0x64, 0xfa, 0x88, 0x5c, 0x2e, 0x20, 0x3b, 0xde, 0x13, 0xd7, 0xf1, 0x0c, 0x2b, 0x8b,
+	0xda, 0xb5, 0x24, 0xd6, 0xea, 0x0d, 0xa0, 0x1e, 0xe6, 0xdf, 0xc0, 0x2c, 0x61, 0xe2, 0x5e, 0x37,
+	0xf3, 0x6f, 0x30, 0xae, 0x85, 0x1a, 0x8c, 0x9a, 0xe4, 0x1a, 0x2f, 0xfd, 0x03, 0xc0, 0x63, 0x0c,
+	0xd0, 0x35, 0xd1, 0xca, 0xab, 0x97, 0xba, 0x24, 0xf2, 0xf2, 0x56, 0x12, 0x6b, 0xef, 0x4e, 0x34,
+	0x1a, 0x95, 0x38, 0x98, 0xec, 0x27, 0x1f, 0x1f, 0x2b, 0xc3, 0x19, 0xc7, 0x26, 0x8c, 0x8f, 0xa3,
+	0xf7, 0x03, 0x3c, 0x0e, 0x77, 0x91, 0x99, 0xbd, 0xac, 0x31, 0x16, 0xdf, 0xaf, 0xa4, 0x85, 0x1a,
+	0x8c, 0x7e, 0x4f, 0x54, 0xd3, 0x13, 0xe9, 0x6e, 0x7b, 0xee, 0xb6, 0xe7, 0xb2, 0xc0, 0x73, 0xf6,
+	0x0c, 0x86, 0xae, 0x39, 0x10, 0xbd, 0xb3, 0xa9, 0xdf, 0x4c, 0x62, 0x6d, 0xa2, 0x0d, 0x4c, 0xd4,
+	0x50, 0x8b, 0xdc, 0xf4, 0x6d, 0x1f, 0xf9, 0x94, 0xf1, 0x5d, 0x60, 0xf8, 0x3e, 0x06, 0xf2, 0x82,
+	0xa2, 0x25, 0x7b, 0x93, 0xec, 0xb5, 0x9b, 0x49, 0xac, 0x9d, 0x6b, 0x07, 0xe7, 0x6a, 0xdb, 0x7f,
+	0x29, 0x44, 0x11, 0x71, 0xe2, 0xe9, 0xd7, 0x43, 0xc3, 0x92, 0x41, 0x13, 0x15, 0xb8, 0x90, 0xf7,
+	0x65, 0x0d, 0x54, 0xe4, 0x12, 0x57, 0x9e, 0x4e, 0xa9, 0xe1, 0xca, 0xf3, 0x54, 0x64, 0xba, 0x4d,
+	0xd6, 0x2c, 0x34, 0xbd, 0xbe, 0x1f, 0x88, 0xe6, 0x25, 0xb7, 0x96, 0xa1, 0xbb, 0xcc, 0xbb, 0xc6,
+	0x98, 0x12, 0xc6, 0xa1, 0xaa, 0x93, 0x62, 0x84, 0xc6, 0x9c, 0xc8, 0x63, 0x8c, 0x43, 0xf4, 0x01,
+	0x59, 0xa9, 0x9e, 0x43, 0x8e, 0x25, 0xeb, 0x49, 0xac, 0x55, 0x55, 0x50, 0x05, 0x38, 0x5d, 0xdc,
+	0xa5, 0x9d, 0xc8, 0x77, 0x6c, 0xd3, 0x60, 0x38, 0x9a, 0x4a, 0x04, 0xbd, 0xa2, 0x82, 0x2a, 0xc0,
+	0xe9, 0x7e, 0x65, 0xfc, 0x20, 0x39, 0xbd, 0xa2, 0x82, 0x2a, 0x40, 0x7d, 0xb2, 0x99, 0x05, 0x76,
+	0xc2, 0x80, 0x90, 0x8e, 0x33, 0xef, 0x27, 0xb1, 0xf6, 0x56, 0x5b, 0x78, 0xab, 0x05, 0x1d, 0x90,
+	0xf7, 0x8a, 0x31, 0x9c, 0xb4, 0xa9, 0x1c, 0x72, 0x3e, 0x48, 0x62, 0xed, 0x22, 0xe6, 0x70, 0x11,
+	0xa3, 0xf6, 0xdf, 0x4d, 0xa2, 0x88, 0x7f, 0x2c, 0x78, 0x8d, 0x47, 0x39, 0x14, 0xee, 0x7a, 0x91,
+	0x5b, 0xea, 0x30, 0x45, 0x1c, 0x4a, 0x12, 0x6f, 0x92, 0x38, 0x1a, 0x25, 0x4f, 0x23, 0x0c, 0x59,
+	0x5a, 0x29, 0x15, 0xd9, 0x24, 0xab, 0x3a, 0x18, 0x43, 0xe8, 0xa7, 0x64, 0x29, 0xc5, 0x44, 0xf1,
+	0x96, 0xe3, 0xbd, 0xa2, 0xaf, 0x25, 0xb1, 0x56, 0x56, 0x40, 0x59, 0xe4, 0x44, 0xf1, 0xff, 0x08,
+	0xa0, 0x89, 0xf6, 0x59, 0x36, 0xcc, 0x0b, 0x62, 0x49, 0x01, 0x65, 0x91, 0x8f, 0xe5, 0x02, 0x10,
+	0x2d, 0x49, 0x5e, 0x2f, 0x31, 0x96, 0x67, 0x20, 0xe4, 0x4b, 0x3e, 0xed, 0x07, 0xf2, 0xac, 0xf2,
+	0x2e, 0x29, 0x72, 0xda, 0x1f, 0x61, 0x90, 0xad, 0x78, 0x00, 0xad, 0x62, 0x89, 0x9f, 0xcb, 0x9b,
+	0x64, 0x11, 0x87, 0x92, 0xc4, 0xef, 0x9b, 0x28, 0xc7, 0x7b, 0xe8, 0x76, 0x59, 0xef, 0x10, 0x83,
+	0xb3, 0x6c, 0x86, 0x17, 0xf7, 0x6d, 0x4c, 0x09, 0xe3, 0x90, 0x8e, 0xcf, 0x5f, 0xb6, 0xa6, 0x5e,
+	0xbc, 0x6c, 0x4d, 0xbd, 0x7e, 0xd9, 0x6a, 0xfc, 0x32, 0x6c, 0x35, 0xfe, 0x18, 0xb6, 0x1a, 0xcf,
+	0x86, 0xad, 0xc6, 0xf3, 0x61, 0xab, 0xf1, 0xcf, 0xb0, 0xd5, 0xf8, 0x77, 0xd8, 0x9a, 0x7a, 0x3d,
+	0x6c, 0x35, 0x7e, 0x7d, 0xd5, 0x9a, 0x7a, 0xfe, 0xaa, 0x35, 0xf5, 0xe2, 0x55, 0x6b, 0xea, 0x87,
+	0x4e, 0xd7, 0x66, 0xbd, 0xe8, 0x68, 0xcb, 0xf4, 0xfa, 0x9d, 0x6e, 0x60, 0x1c, 0x1b, 0xae, 0xd1,
+	0x71, 0xbc, 0x13, 0xbb, 0x73, 0x76, 0xaf, 0x53, 0xf7, 0xcb, 0xcd, 0xd1, 0xac, 0xf8, 0x5d, 0xe6,
+	0xde, 0xff, 0x03, 0x00, 0xd5, 0x6c, 0xf9, 0x51, 0xd8, 0x11, 0x00, 0x00,
 }
 
 func (this *Result) Equal(that interface{}) bool {
@@ -1109,6 +1146,20 @@ func (this *Summary) Equal(that interface{}) bool {
 	if this.TotalStructuredMetadataBytesProcessed != that1.TotalStructuredMetadataBytesProcessed {
 		return false
 	}
+	if len(this.OptimizerRewrites) != len(that1.OptimizerRewrites) {
+		return false
+	}
+	for i := range this.OptimizerRewrites {
+		if this.OptimizerRewrites[i] != that1.OptimizerRewrites[i] {
+			return false
+		}
+	}
+	if this.CPUTime != that1.CPUTime {
+		return false
+	}
+	if this.PeakMemoryBytes != that1.PeakMemoryBytes {
+		return false
+	}
 	return true
 }
 func (this *Index) Equal(that interface{}) bool {
@@ -1377,7 +1428,7 @@ func (this *Summary) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 16)
+	s := make([]string, 0, 19)
 	s = append(s, "&stats.Summary{")
 	s = append(s, "BytesProcessedPerSecond: "+fmt.Sprintf("%#v", this.BytesProcessedPerSecond)+",\n")
 	s = append(s, "LinesProcessedPerSecond: "+fmt.Sprintf("%#v", this.LinesProcessedPerSecond)+",\n")
@@ -1391,6 +1442,21 @@ func (this *Summary) GoString() string {
 	s = append(s, "Shards: "+fmt.Sprintf("%#v", this.Shards)+",\n")
 	s = append(s, "TotalPostFilterLines: "+fmt.Sprintf("%#v", this.TotalPostFilterLines)+",\n")
 	s = append(s, "TotalStructuredMetadataBytesProcessed: "+fmt.Sprintf("%#v", this.TotalStructuredMetadataBytesProcessed)+",\n")
+	keysForOptimizerRewrites := make([]string, 0, len(this.OptimizerRewrites))
+	for k, _ := range this.OptimizerRewrites {
+		keysForOptimizerRewrites = append(keysForOptimizerRewrites, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForOptimizerRewrites)
+	mapStringForOptimizerRewrites := "map[string]int64{"
+	for _, k := range keysForOptimizerRewrites {
+		mapStringForOptimizerRewrites += fmt.Sprintf("%#v: %#v,", k, this.OptimizerRewrites[k])
+	}
+	mapStringForOptimizerRewrites += "}"
+	if this.OptimizerRewrites != nil {
+		s = append(s, "OptimizerRewrites: "+mapStringForOptimizerRewrites+",\n")
+	}
+	s = append(s, "CPUTime: "+fmt.Sprintf("%#v", this.CPUTime)+",\n")
+	s = append(s, "PeakMemoryBytes: "+fmt.Sprintf("%#v", this.PeakMemoryBytes)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1687,6 +1753,34 @@ func (m *Summary) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.PeakMemoryBytes != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.PeakMemoryBytes))
+		i--
+		dAtA[i] = 0x78
+	}
+	if m.CPUTime != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.CPUTime))))
+		i--
+		dAtA[i] = 0x71
+	}
+	if len(m.OptimizerRewrites) > 0 {
+		for k := range m.OptimizerRewrites {
+			v := m.OptimizerRewrites[k]
+			baseI := i
+			i = encodeVarintStats(dAtA, i, uint64(v))
+			i--
+			dAtA[i] = 0x10
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintStats(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintStats(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x6a
+		}
+	}
 	if m.TotalStructuredMetadataBytesProcessed != 0 {
 		i = encodeVarintStats(dAtA, i, uint64(m.TotalStructuredMetadataBytesProcessed))
 		i--
@@ -2187,6 +2281,20 @@ func (m *Summary) Size() (n int) {
 	if m.TotalStructuredMetadataBytesProcessed != 0 {
 		n += 1 + sovStats(uint64(m.TotalStructuredMetadataBytesProcessed))
 	}
+	if len(m.OptimizerRewrites) > 0 {
+		for k, v := range m.OptimizerRewrites {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovStats(uint64(len(k))) + 1 + sovStats(uint64(v))
+			n += mapEntrySize + 1 + sovStats(uint64(mapEntrySize))
+		}
+	}
+	if m.CPUTime != 0 {
+		n += 9
+	}
+	if m.PeakMemoryBytes != 0 {
+		n += 1 + sovStats(uint64(m.PeakMemoryBytes))
+	}
 	return n
 }
 
@@ -2387,6 +2495,16 @@ func (this *Summary) String() string {
 	if this == nil {
 		return "nil"
 	}
+	keysForOptimizerRewrites := make([]string, 0, len(this.OptimizerRewrites))
+	for k, _ := range this.OptimizerRewrites {
+		keysForOptimizerRewrites = append(keysForOptimizerRewrites, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForOptimizerRewrites)
+	mapStringForOptimizerRewrites := "map[string]int64{"
+	for _, k := range keysForOptimizerRewrites {
+		mapStringForOptimizerRewrites += fmt.Sprintf("%v: %v,", k, this.OptimizerRewrites[k])
+	}
+	mapStringForOptimizerRewrites += "}"
 	s := strings.Join([]string{`&Summary{`,
 		`BytesProcessedPerSecond:` + fmt.Sprintf("%v", this.BytesProcessedPerSecond) + `,`,
 		`LinesProcessedPerSecond:` + fmt.Sprintf("%v", this.LinesProcessedPerSecond) + `,`,
@@ -2400,6 +2518,9 @@ func (this *Summary) String() string {
 		`Shards:` + fmt.Sprintf("%v", this.Shards) + `,`,
 		`TotalPostFilterLines:` + fmt.Sprintf("%v", this.TotalPostFilterLines) + `,`,
 		`TotalStructuredMetadataBytesProcessed:` + fmt.Sprintf("%v", this.TotalStructuredMetadataBytesProcessed) + `,`,
+		`OptimizerRewrites:` + mapStringForOptimizerRewrites + `,`,
+		`CPUTime:` + fmt.Sprintf("%v", this.CPUTime) + `,`,
+		`PeakMemoryBytes:` + fmt.Sprintf("%v", this.PeakMemoryBytes) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -3277,6 +3398,149 @@ func (m *Summary) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OptimizerRewrites", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.OptimizerRewrites == nil {
+				m.OptimizerRewrites = make(map[string]int64)
+			}
+			var mapkey string
+			var mapvalue int64
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStats
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStats
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthStats
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthStats
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStats
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapvalue |= int64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipStats(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthStats
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.OptimizerRewrites[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 14:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CPUTime", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.CPUTime = float64(math.Float64frombits(v))
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeakMemoryBytes", wireType)
+			}
+			m.PeakMemoryBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PeakMemoryBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
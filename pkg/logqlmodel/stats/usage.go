@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"time"
+)
+
+// Usage is the resource usage of a query, broken down by stage.
+// It is derived from the statistics of the query, durations are in seconds with a microsecond precision.
+type Usage struct {
+	QueueTime          float64 `json:"queueTime"`
+	ShardsTime         float64 `json:"shardsTime"`
+	IndexTime          float64 `json:"indexTime"`
+	ChunksDownloadTime float64 `json:"chunksDownloadTime"`
+	CacheTime          float64 `json:"cacheTime"`
+	ExecTime           float64 `json:"execTime"`
+	CPUTime            float64 `json:"cpuTime"`
+
+	PeakMemoryBytes int64 `json:"peakMemoryBytes"`
+
+	BytesProcessed   int64 `json:"bytesProcessed"`
+	LinesProcessed   int64 `json:"linesProcessed"`
+	ChunksRef        int64 `json:"chunksRef"`
+	ChunksDownloaded int64 `json:"chunksDownloaded"`
+	Shards           int64 `json:"shards"`
+	Splits           int64 `json:"splits"`
+
+	CacheHitRatio CacheHitRatio `json:"cacheHitRatio"`
+}
+
+// CacheHitRatio is the ratio of the entries found in each cache to the entries requested.
+// The ratio of a cache that was not used is 0.
+type CacheHitRatio struct {
+	Chunk               float64 `json:"chunk"`
+	Index               float64 `json:"index"`
+	Result              float64 `json:"result"`
+	StatsResult         float64 `json:"statsResult"`
+	VolumeResult        float64 `json:"volumeResult"`
+	SeriesResult        float64 `json:"seriesResult"`
+	LabelResult         float64 `json:"labelResult"`
+	InstantMetricResult float64 `json:"instantMetricResult"`
+}
+
+// Usage returns the resource usage of the query.
+func (r Result) Usage() Usage {
+	return Usage{
+		QueueTime:          r.Summary.QueueTime,
+		ShardsTime:         seconds(time.Duration(r.Index.ShardsDuration)),
+		IndexTime:          seconds(r.ChunkRefsFetchTime()),
+		ChunksDownloadTime: seconds(r.ChunksDownloadTime()),
+		CacheTime:          seconds(r.Caches.DownloadTime()),
+		ExecTime:           r.Summary.ExecTime,
+		CPUTime:            seconds(ConvertSecondsToNanoseconds(r.Summary.CPUTime)),
+
+		PeakMemoryBytes: r.Summary.PeakMemoryBytes,
+
+		BytesProcessed:   r.Summary.TotalBytesProcessed,
+		LinesProcessed:   r.Summary.TotalLinesProcessed,
+		ChunksRef:        r.TotalChunksRef(),
+		ChunksDownloaded: r.TotalChunksDownloaded(),
+		Shards:           r.Summary.Shards,
+		Splits:           r.Summary.Splits,
+
+		CacheHitRatio: CacheHitRatio{
+			Chunk:               r.Caches.Chunk.HitRatio(),
+			Index:               r.Caches.Index.HitRatio(),
+			Result:              r.Caches.Result.HitRatio(),
+			StatsResult:         r.Caches.StatsResult.HitRatio(),
+			VolumeResult:        r.Caches.VolumeResult.HitRatio(),
+			SeriesResult:        r.Caches.SeriesResult.HitRatio(),
+			LabelResult:         r.Caches.LabelResult.HitRatio(),
+			InstantMetricResult: r.Caches.InstantMetricResult.HitRatio(),
+		},
+	}
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Microsecond).Seconds()
+}
+
+// DownloadTime returns the time spent fetching entries from all caches.
+func (c Caches) DownloadTime() time.Duration {
+	return c.Chunk.CacheDownloadTime() +
+		c.Index.CacheDownloadTime() +
+		c.Result.CacheDownloadTime() +
+		c.StatsResult.CacheDownloadTime() +
+		c.VolumeResult.CacheDownloadTime() +
+		c.SeriesResult.CacheDownloadTime() +
+		c.LabelResult.CacheDownloadTime() +
+		c.InstantMetricResult.CacheDownloadTime()
+}
+
+// HitRatio returns the ratio of the entries found in the cache to the entries requested.
+func (c Cache) HitRatio() float64 {
+	if c.EntriesRequested <= 0 {
+		return 0
+	}
+	return float64(c.EntriesFound) / float64(c.EntriesRequested)
+}
+
+// ResultWithUsage is the JSON representation of the statistics of a query in HTTP responses,
+// holding the resource usage of the query next to its statistics.
+type ResultWithUsage struct {
+	Result
+	Usage Usage `json:"usage"`
+}
+
+// WithUsage returns the statistics along with the resource usage derived from them.
+func (r Result) WithUsage() ResultWithUsage {
+	return ResultWithUsage{Result: r, Usage: r.Usage()}
+}
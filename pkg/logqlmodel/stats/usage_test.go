@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult_Usage(t *testing.T) {
+	r := Result{
+		Summary: Summary{
+			QueueTime:           0.5,
+			ExecTime:            2,
+			CPUTime:             1.5,
+			PeakMemoryBytes:     4096,
+			TotalBytesProcessed: 1024,
+			TotalLinesProcessed: 10,
+			Shards:              4,
+			Splits:              2,
+		},
+		Querier: Querier{Store: Store{
+			TotalChunksRef:        5,
+			TotalChunksDownloaded: 3,
+			ChunksDownloadTime:    int64(300 * time.Millisecond),
+			ChunkRefsFetchTime:    int64(100*time.Millisecond + 10),
+		}},
+		Ingester: Ingester{Store: Store{
+			TotalChunksRef:     2,
+			ChunkRefsFetchTime: int64(50 * time.Millisecond),
+		}},
+		Index: Index{ShardsDuration: int64(20 * time.Millisecond)},
+		Caches: Caches{
+			Chunk:  Cache{EntriesFound: 1, EntriesRequested: 4, DownloadTime: int64(10 * time.Millisecond)},
+			Result: Cache{EntriesFound: 2, EntriesRequested: 2, DownloadTime: int64(5 * time.Millisecond)},
+		},
+	}
+
+	require.Equal(t, Usage{
+		QueueTime:          0.5,
+		ShardsTime:         0.02,
+		IndexTime:          0.15,
+		ChunksDownloadTime: 0.3,
+		CacheTime:          0.015,
+		ExecTime:           2,
+		CPUTime:            1.5,
+		PeakMemoryBytes:    4096,
+		BytesProcessed:     1024,
+		LinesProcessed:     10,
+		ChunksRef:          7,
+		ChunksDownloaded:   3,
+		Shards:             4,
+		Splits:             2,
+		CacheHitRatio: CacheHitRatio{
+			Chunk:  0.25,
+			Result: 1,
+		},
+	}, r.Usage())
+}
@@ -15,7 +15,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/NYTimes/gziphandler"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/dns"
@@ -50,6 +49,8 @@ import (
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql"
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/lokifrontend/asyncquery"
+	"github.com/grafana/loki/v3/pkg/lokifrontend/fingerprints"
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend"
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend/transport"
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend/v1/frontendv1pb"
@@ -543,7 +544,11 @@ func (t *Loki) initQuerier() (services.Service, error) {
 	// we disable the proxying of the tail routes in initQueryFrontend() and we still want these routes regiestered
 	// on the external router.
 	t.Server.HTTP.Path("/loki/api/v1/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailHandler)))
+	t.Server.HTTP.Path("/loki/api/v2/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailV2Handler)))
 	t.Server.HTTP.Path("/api/prom/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailHandler)))
+	t.Server.HTTP.Path("/loki/api/v1/stream_stats").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.StreamStatsHandler)))
+	// Tail v2 is also served over gRPC, for clients which prefer a gRPC stream to server-sent events.
+	logproto.RegisterTailV2Server(t.Server.GRPC, t.querierAPI)
 
 	internalMiddlewares := []queryrangebase.Middleware{
 		serverutil.RecoveryMiddleware,
@@ -587,10 +592,44 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 		level.Warn(util_log.Logger).Log("msg", "The config setting shutdown marker path is not set. The /ingester/prepare_shutdown endpoint won't work")
 	}
 
-	t.Ingester, err = ingester.New(t.Cfg.Ingester, t.Cfg.IngesterClient, t.Store, t.Overrides, t.tenantConfigs, prometheus.DefaultRegisterer, t.Cfg.Distributor.WriteFailuresLogging, t.Cfg.MetricsNamespace, logger, t.UsageTracker, t.ring, t.partitionRingWatcher)
+	ing, err := ingester.New(t.Cfg.Ingester, t.Cfg.IngesterClient, t.Store, t.Overrides, t.tenantConfigs, prometheus.DefaultRegisterer, t.Cfg.Distributor.WriteFailuresLogging, t.Cfg.MetricsNamespace, logger, t.UsageTracker, t.ring, t.partitionRingWatcher)
 	if err != nil {
 		return
 	}
+	t.Ingester = ing
+
+	if t.Cfg.Ingester.Snapshot.Enabled {
+		store := t.Cfg.Ingester.Snapshot.Store
+		if store == "" {
+			period, err := t.Cfg.SchemaConfig.SchemaForTime(model.Now())
+			if err != nil {
+				return nil, err
+			}
+			store = period.ObjectType
+		}
+
+		objectClient, err := storage.NewObjectClient(store, "ingester-snapshot", t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ingester snapshot object client: %w", err)
+		}
+		ing.SetSnapshotClient(objectClient)
+	}
+
+	if t.Cfg.Ingester.FlushDedupe.Enabled {
+		period, err := t.Cfg.SchemaConfig.SchemaForTime(model.Now())
+		if err != nil {
+			return nil, err
+		}
+		objectClient, err := storage.NewObjectClient(period.ObjectType, "ingester-flush-dedupe", t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ingester flush deduplication object client: %w", err)
+		}
+		var keyEncoder client.KeyEncoder
+		if period.ObjectType == types.StorageTypeFileSystem {
+			keyEncoder = client.FSEncoder
+		}
+		ing.SetFlushDedupeClient(objectClient, keyEncoder)
+	}
 
 	if t.Cfg.Ingester.Wrapper != nil {
 		t.Ingester = t.Cfg.Ingester.Wrapper.Wrap(t.Ingester)
@@ -615,6 +654,12 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	t.Server.HTTP.Methods("POST", "GET").Path("/ingester/shutdown").Handler(
 		httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.ShutdownHandler)),
 	)
+	t.Server.HTTP.Methods("POST", "GET").Path("/ingester/snapshot").Handler(
+		httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.SnapshotHandler)),
+	)
+	t.Server.HTTP.Methods("GET").Path("/ingester/stream_stats").Handler(
+		httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.StreamStatsHandler)),
+	)
 	return t.Ingester, nil
 }
 
@@ -981,6 +1026,8 @@ func (disabledShuffleShardingLimits) MaxQueriersPerUser(_ string) uint { return
 
 func (disabledShuffleShardingLimits) MaxQueryCapacity(_ string) float64 { return 0 }
 
+func (disabledShuffleShardingLimits) QueryQueueWeight(_ string) int { return 1 }
+
 // ingesterQueryOptions exists simply to avoid dependency cycles when using querier.Config directly in queryrange.NewMiddleware
 type ingesterQueryOptions struct {
 	querier.Config
@@ -1012,8 +1059,15 @@ func (t *Loki) initQueryFrontendMiddleware() (_ services.Service, err error) {
 		return
 	}
 	t.stopper = stopper
-	t.QueryFrontEndMiddleware = middleware
+	t.queryFingerprints, err = fingerprints.NewTracker(t.Cfg.Frontend.QueryFingerprints, t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore, util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	t.QueryFrontEndMiddleware = queryrangebase.MergeMiddlewares(t.queryFingerprints.Middleware(), middleware)
 
+	if t.queryFingerprints != nil {
+		return t.queryFingerprints, nil
+	}
 	return services.NewIdleService(nil, nil), nil
 }
 
@@ -1107,17 +1161,44 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 
 	roundTripper := queryrange.NewSerializeRoundTripper(t.QueryFrontEndMiddleware.Wrap(frontendTripper), queryrange.DefaultCodec)
 
+	var asyncQueries *asyncquery.Manager
+	if t.Cfg.Frontend.AsyncQuery.Enabled {
+		store := t.Cfg.Frontend.AsyncQuery.Store
+		if store == "" {
+			period, err := t.Cfg.SchemaConfig.SchemaForTime(model.Now())
+			if err != nil {
+				return nil, err
+			}
+			store = period.ObjectType
+		}
+
+		objectClient, err := storage.NewObjectClient(store, "async-query", t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create async query object client: %w", err)
+		}
+		asyncQueries = asyncquery.NewManager(
+			t.Cfg.Frontend.AsyncQuery,
+			objectClient,
+			t.QueryFrontEndMiddleware.Wrap(asyncquery.ProgressMiddleware().Wrap(frontendTripper)),
+			queryrange.DefaultCodec,
+			t.Overrides,
+			util_log.Logger,
+			prometheus.DefaultRegisterer,
+		)
+	}
+
 	frontendHandler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
 	if t.Cfg.Frontend.CompressResponses {
-		frontendHandler = gziphandler.GzipHandler(frontendHandler)
+		frontendHandler = transport.NewCompressionHandler(frontendHandler)
 	}
 
 	toMerge := []middleware.Interface{
 		httpreq.ExtractQueryTagsMiddleware(),
-		httpreq.PropagateHeadersMiddleware(httpreq.LokiActorPathHeader, httpreq.LokiEncodingFlagsHeader, httpreq.LokiDisablePipelineWrappersHeader),
+		httpreq.PropagateHeadersMiddleware(httpreq.LokiActorPathHeader, httpreq.LokiQueryPriorityHeader, httpreq.LokiEncodingFlagsHeader, httpreq.LokiDisablePipelineWrappersHeader, httpreq.LokiFederatedHeader),
 		serverutil.RecoveryHTTPMiddleware,
 		t.HTTPAuthMiddleware,
 		queryrange.StatsHTTPMiddleware,
+		queryrange.DryRunHTTPMiddleware,
 		serverutil.NewPrepopulateMiddleware(),
 		serverutil.ResponseJSONMiddleware(),
 	}
@@ -1181,16 +1262,59 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	t.Server.HTTP.Path("/api/prom/label/{name}/values").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/series").Methods("GET", "POST").Handler(frontendHandler)
 
-	// Only register tailing requests if this process does not act as a Querier
-	// If this process is also a Querier the Querier will register the tail endpoints.
+	if asyncQueries != nil {
+		asyncMiddleware := middleware.Merge(
+			serverutil.RecoveryHTTPMiddleware,
+			t.HTTPAuthMiddleware,
+		)
+		t.Server.HTTP.Path("/loki/api/v1/async_queries").Methods("POST").Handler(asyncMiddleware.Wrap(http.HandlerFunc(asyncQueries.SubmitHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/async_queries/{id}").Methods("GET").Handler(asyncMiddleware.Wrap(http.HandlerFunc(asyncQueries.StatusHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/async_queries/{id}").Methods("DELETE").Handler(asyncMiddleware.Wrap(http.HandlerFunc(asyncQueries.CancelHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/async_queries/{id}/results").Methods("GET").Handler(asyncMiddleware.Wrap(http.HandlerFunc(asyncQueries.ResultsHandler)))
+	}
+
+	if t.queryFingerprints != nil {
+		fingerprintsMiddleware := middleware.Merge(
+			serverutil.RecoveryHTTPMiddleware,
+			t.HTTPAuthMiddleware,
+		)
+		t.Server.HTTP.Path("/loki/api/v1/query_fingerprints").Methods("GET").Handler(fingerprintsMiddleware.Wrap(http.HandlerFunc(t.queryFingerprints.ListHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_fingerprints/rules").Methods("GET").Handler(fingerprintsMiddleware.Wrap(http.HandlerFunc(t.queryFingerprints.RulesHandler)))
+		// The rules are written through admin endpoints, outside of the tenant API.
+		if t.Cfg.Frontend.QueryFingerprints.AdminAPIEnabled {
+			t.Server.HTTP.Path("/query-frontend/query_fingerprints/rules").Methods("POST").Handler(fingerprintsMiddleware.Wrap(http.HandlerFunc(t.queryFingerprints.SetRuleHandler)))
+			t.Server.HTTP.Path("/query-frontend/query_fingerprints/rules/{fingerprint}").Methods("DELETE").Handler(fingerprintsMiddleware.Wrap(http.HandlerFunc(t.queryFingerprints.DeleteRuleHandler)))
+		}
+	}
+
+	// Only register tailing and stream stats requests if this process does not act as a Querier
+	// If this process is also a Querier the Querier will register these endpoints.
 	if !t.isModuleActive(Querier) {
-		// defer tail endpoints to the default handler
+		// defer tail and stream stats endpoints to the default handler
 		t.Server.HTTP.Path("/loki/api/v1/tail").Methods("GET", "POST").Handler(defaultHandler)
+		t.Server.HTTP.Path("/loki/api/v2/tail").Methods("GET", "POST").Handler(defaultHandler)
 		t.Server.HTTP.Path("/api/prom/tail").Methods("GET", "POST").Handler(defaultHandler)
+		t.Server.HTTP.Path("/loki/api/v1/stream_stats").Methods("GET", "POST").Handler(defaultHandler)
+	}
+
+	startAsyncQueries := func(ctx context.Context) error {
+		if asyncQueries == nil {
+			return nil
+		}
+		return services.StartAndAwaitRunning(ctx, asyncQueries)
+	}
+	stopAsyncQueries := func() {
+		if asyncQueries == nil {
+			return
+		}
+		if err := services.StopAndAwaitTerminated(context.Background(), asyncQueries); err != nil {
+			level.Warn(util_log.Logger).Log("msg", "failed to stop async query manager", "err", err)
+		}
 	}
 
 	if t.frontend == nil {
-		return services.NewIdleService(nil, func(_ error) error {
+		return services.NewIdleService(startAsyncQueries, func(_ error) error {
+			stopAsyncQueries()
 			if t.stopper != nil {
 				t.stopper.Stop()
 				t.stopper = nil
@@ -1200,10 +1324,14 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	}
 
 	return services.NewIdleService(func(ctx context.Context) error {
-		return services.StartAndAwaitRunning(ctx, t.frontend)
+		if err := services.StartAndAwaitRunning(ctx, t.frontend); err != nil {
+			return err
+		}
+		return startAsyncQueries(ctx)
 	}, func(_ error) error {
 		// Log but not return in case of error, so that other following dependencies
 		// are stopped too.
+		stopAsyncQueries()
 		if err := services.StopAndAwaitTerminated(context.Background(), t.frontend); err != nil {
 			level.Warn(util_log.Logger).Log("msg", "failed to stop frontend service", "err", err)
 		}
@@ -1363,6 +1491,7 @@ func (t *Loki) initMemberlistKV() (services.Service, error) {
 		ring.GetCodec(),
 		analytics.JSONCodec,
 		ring.GetPartitionRingCodec(),
+		fingerprints.RulesCodec,
 	}
 
 	dnsProviderReg := prometheus.WrapRegistererWithPrefix(
@@ -1433,13 +1562,25 @@ func (t *Loki) initCompactor() (services.Service, error) {
 		}
 	}
 
-	t.compactor, err = compactor.NewCompactor(t.Cfg.CompactorConfig, objectClients, deleteRequestStoreClient, t.Cfg.SchemaConfig, t.Overrides, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
+	var coldStoreClient client.ObjectClient
+	if t.Cfg.CompactorConfig.Tiering.Enabled() {
+		coldStoreClient, err = storage.NewObjectClient(t.Cfg.CompactorConfig.Tiering.ColdStore, "cold-store", t.Cfg.StorageConfig, t.ClientMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cold store object client: %w", err)
+		}
+	}
+
+	t.compactor, err = compactor.NewCompactor(t.Cfg.CompactorConfig, objectClients, deleteRequestStoreClient, coldStoreClient, t.Cfg.SchemaConfig, t.Overrides, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
 	if err != nil {
 		return nil, err
 	}
 
 	t.compactor.RegisterIndexCompactor(types.BoltDBShipperType, boltdbcompactor.NewIndexCompactor())
-	t.compactor.RegisterIndexCompactor(types.TSDBType, tsdb.NewIndexCompactor())
+	if t.Cfg.CompactorConfig.Tiering.TSDBFormatV4 {
+		t.compactor.RegisterIndexCompactor(types.TSDBType, tsdb.NewIndexCompactorWithFormatV4())
+	} else {
+		t.compactor.RegisterIndexCompactor(types.TSDBType, tsdb.NewIndexCompactor())
+	}
 	t.Server.HTTP.Path("/compactor/ring").Methods("GET", "POST").Handler(t.compactor)
 
 	if t.Cfg.InternalServer.Enable {
@@ -1447,11 +1588,18 @@ func (t *Loki) initCompactor() (services.Service, error) {
 	}
 
 	if t.Cfg.CompactorConfig.RetentionEnabled {
-		t.Server.HTTP.Path("/loki/api/v1/delete").Methods("PUT", "POST").Handler(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.AddDeleteRequestHandler))
-		t.Server.HTTP.Path("/loki/api/v1/delete").Methods("GET").Handler(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.GetAllDeleteRequestsHandler))
-		t.Server.HTTP.Path("/loki/api/v1/delete").Methods("DELETE").Handler(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.CancelDeleteRequestHandler))
-		t.Server.HTTP.Path("/loki/api/v1/cache/generation_numbers").Methods("GET").Handler(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.GetCacheGenerationNumberHandler))
-		grpc.RegisterCompactorServer(t.Server.GRPC, t.compactor.DeleteRequestsGRPCHandler)
+		t.Server.HTTP.Path("/loki/api/v1/delete").Methods("PUT", "POST").Handler(t.compactor.ForwardToLeader(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.AddDeleteRequestHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/delete").Methods("GET").Handler(t.compactor.ForwardToLeader(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.GetAllDeleteRequestsHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/delete").Methods("DELETE").Handler(t.compactor.ForwardToLeader(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.CancelDeleteRequestHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/delete/dry_run").Methods("GET", "POST").Handler(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.DryRunDeleteRequestHandler))
+		t.Server.HTTP.Path("/loki/api/v1/delete/status").Methods("GET").Handler(t.compactor.ForwardToLeader(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.GetDeleteRequestDetailsHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/cache/generation_numbers").Methods("GET").Handler(t.compactor.ForwardToLeader(t.addCompactorMiddleware(t.compactor.DeleteRequestsHandler.GetCacheGenerationNumberHandler)))
+		grpc.RegisterCompactorServer(t.Server.GRPC, t.compactor.ForwardGRPCToLeader(t.compactor.DeleteRequestsGRPCHandler))
+	}
+
+	if t.compactor.TieringManager != nil {
+		t.Server.HTTP.Path("/loki/api/v1/rehydrate").Methods("PUT", "POST").Handler(t.compactor.ForwardToLeader(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.TieringManager.AddRehydrationRequestHandler))))
+		t.Server.HTTP.Path("/loki/api/v1/rehydrate").Methods("GET").Handler(t.compactor.ForwardToLeader(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.TieringManager.GetRehydrationRequestsHandler))))
 	}
 
 	return t.compactor, nil
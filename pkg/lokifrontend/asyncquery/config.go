@@ -0,0 +1,62 @@
+package asyncquery
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config configures asynchronous queries in the query frontend.
+//
+// An asynchronous query is submitted through the HTTP API and executed in the background by
+// the query frontend. Its status and paginated results are persisted in object storage, so
+// that they can be fetched after the client disconnected and from any query frontend replica.
+type Config struct {
+	Enabled       bool          `yaml:"enabled"`
+	Store         string        `yaml:"store"`
+	Prefix        string        `yaml:"prefix"`
+	MaxConcurrent int           `yaml:"max_concurrent"`
+	MaxQueued     int           `yaml:"max_queued"`
+	Timeout       time.Duration `yaml:"timeout"`
+	ResultsTTL    time.Duration `yaml:"results_ttl"`
+	PageSize      int           `yaml:"page_size"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "frontend.async-query.enabled", false, "Enable the /loki/api/v1/async_queries endpoints which execute long-running queries in the background and persist their results to object storage.")
+	f.StringVar(&cfg.Store, "frontend.async-query.store", "", "Object store used for the status and results of asynchronous queries. If empty, the object store of the active schema period is used.")
+	f.StringVar(&cfg.Prefix, "frontend.async-query.prefix", "async-queries/", "Path prefix under which the status and results of asynchronous queries are stored.")
+	f.IntVar(&cfg.MaxConcurrent, "frontend.async-query.max-concurrent", 4, "Maximum number of asynchronous queries executed at the same time by a query frontend.")
+	f.IntVar(&cfg.MaxQueued, "frontend.async-query.max-queued", 100, "Maximum number of asynchronous queries waiting for execution in a query frontend.")
+	f.DurationVar(&cfg.Timeout, "frontend.async-query.timeout", 6*time.Hour, "Maximum execution time of an asynchronous query.")
+	f.DurationVar(&cfg.ResultsTTL, "frontend.async-query.results-ttl", 24*time.Hour, "How long the results of a finished asynchronous query are kept in object storage.")
+	f.IntVar(&cfg.PageSize, "frontend.async-query.page-size", 5000, "Maximum number of log entries or samples per page of asynchronous query results.")
+}
+
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Prefix == "" || !strings.HasSuffix(cfg.Prefix, "/") {
+		return fmt.Errorf("invalid async query prefix %q: must not be empty and end with a slash", cfg.Prefix)
+	}
+	if cfg.MaxConcurrent <= 0 {
+		return errors.New("async query max concurrent must be greater than 0")
+	}
+	if cfg.MaxQueued <= 0 {
+		return errors.New("async query max queued must be greater than 0")
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid async query timeout: %s", cfg.Timeout)
+	}
+	if cfg.ResultsTTL <= 0 {
+		return fmt.Errorf("invalid async query results ttl: %s", cfg.ResultsTTL)
+	}
+	if cfg.PageSize <= 0 {
+		return errors.New("async query page size must be greater than 0")
+	}
+	return nil
+}
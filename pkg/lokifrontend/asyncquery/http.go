@@ -0,0 +1,71 @@
+package asyncquery
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/httpgrpc"
+
+	"github.com/grafana/loki/v3/pkg/util"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// SubmitHandler submits an asynchronous query. It accepts the same parameters as /loki/api/v1/query_range.
+func (m *Manager) SubmitHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	status, err := m.Submit(r.Context(), r.Form)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, status)
+}
+
+// StatusHandler returns the status and progress of an asynchronous query.
+func (m *Manager) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := m.Status(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, status)
+}
+
+// ResultsHandler returns a page of the results of a succeeded asynchronous query,
+// encoded like the response of /loki/api/v1/query_range.
+func (m *Manager) ResultsHandler(w http.ResponseWriter, r *http.Request) {
+	var page int
+	if v := r.FormValue("page"); v != "" {
+		var err error
+		page, err = strconv.Atoi(v)
+		if err != nil {
+			serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid page %q: %s", v, err.Error()), w)
+			return
+		}
+	}
+
+	rc, err := m.Results(r.Context(), mux.Vars(r)["id"], page)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	_, _ = io.Copy(w, rc)
+}
+
+// CancelHandler cancels a queued or running asynchronous query, or deletes the results of a finished one.
+func (m *Manager) CancelHandler(w http.ResponseWriter, r *http.Request) {
+	if err := m.Cancel(r.Context(), mux.Vars(r)["id"]); err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,763 @@
+package asyncquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/queue"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+const (
+	statusFile    = "status.json"
+	cancelFile    = "cancel"
+	pagesDir      = "pages"
+	activeDir     = "active"
+	queryRangeURI = "/loki/api/v1/query_range"
+
+	// statusUpdateInterval is the interval in which the status of queued and running queries
+	// is persisted and cancellation requests of other query frontends are picked up.
+	statusUpdateInterval = 10 * time.Second
+	// staleStatusPeriod is the period after which a query is considered lost if its status is not updated anymore,
+	// e.g. because the query frontend executing it crashed.
+	staleStatusPeriod = 6 * statusUpdateInterval
+	cleanupInterval   = time.Hour
+
+	// PriorityClass is the priority class of asynchronous queries in the query scheduler.
+	PriorityClass = "async"
+)
+
+// State is the state of an asynchronous query.
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Finished returns true if the query is not queued or running anymore.
+func (s State) Finished() bool {
+	return s != StateQueued && s != StateRunning
+}
+
+var (
+	errQueryNotFound = httpgrpc.Errorf(http.StatusNotFound, "async query not found")
+	errQueueFull     = httpgrpc.Errorf(http.StatusServiceUnavailable, "too many async queries waiting for execution, try again later")
+	errNotSucceeded  = httpgrpc.Errorf(http.StatusBadRequest, "async query has no results, only succeeded queries have results")
+)
+
+// Status is the persisted status of an asynchronous query.
+type Status struct {
+	ID     string     `json:"id"`
+	Query  string     `json:"query"`
+	Params url.Values `json:"params"`
+	State  State      `json:"state"`
+	Error  string     `json:"error,omitempty"`
+
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	Progress Progress `json:"progress"`
+	Pages    int      `json:"pages"`
+	Items    int      `json:"items"`
+}
+
+// Limits is the interface of the per-tenant limits for asynchronous queries.
+type Limits interface {
+	MaxAsyncQueries(userID string) int
+}
+
+type metrics struct {
+	submitted *prometheus.CounterVec
+	finished  *prometheus.CounterVec
+	active    prometheus.Gauge
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		submitted: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "frontend_async_queries_submitted_total",
+			Help:      "Total number of submitted asynchronous queries.",
+		}, []string{"tenant"}),
+		finished: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "frontend_async_queries_finished_total",
+			Help:      "Total number of finished asynchronous queries by state.",
+		}, []string{"state"}),
+		active: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "frontend_async_queries_active",
+			Help:      "Number of asynchronous queries queued or running in this query frontend.",
+		}),
+	}
+}
+
+// job is an asynchronous query owned by this query frontend.
+type job struct {
+	orgID    string
+	progress *progress
+
+	// guarded by Manager.mtx
+	status          Status
+	cancelRequested bool
+	cancel          context.CancelFunc
+}
+
+// Manager executes asynchronous queries through the query frontend and persists their status
+// and results in object storage.
+//
+// Queries are sent to the query scheduler in the background sub-queue of the tenant,
+// so that they do not delay interactive queries of the same tenant.
+type Manager struct {
+	services.Service
+
+	cfg     Config
+	client  client.ObjectClient
+	handler queryrangebase.Handler
+	codec   queryrangebase.Codec
+	limits  Limits
+	logger  log.Logger
+	metrics *metrics
+
+	queue chan *job
+
+	mtx  sync.Mutex
+	jobs map[string]*job
+
+	now func() time.Time
+}
+
+// NewManager creates a new manager for asynchronous queries.
+// The handler must be the query frontend middleware chain, ideally wrapping ProgressMiddleware.
+func NewManager(cfg Config, objectClient client.ObjectClient, handler queryrangebase.Handler, codec queryrangebase.Codec, limits Limits, logger log.Logger, r prometheus.Registerer) *Manager {
+	m := &Manager{
+		cfg:     cfg,
+		client:  objectClient,
+		handler: handler,
+		codec:   codec,
+		limits:  limits,
+		logger:  log.With(logger, "component", "async-query-manager"),
+		metrics: newMetrics(r),
+		queue:   make(chan *job, cfg.MaxQueued),
+		jobs:    make(map[string]*job),
+		now:     time.Now,
+	}
+	m.Service = services.NewBasicService(nil, m.running, m.stopping)
+	return m
+}
+
+func (m *Manager) running(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < m.cfg.MaxConcurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.worker(ctx)
+		}()
+	}
+	defer wg.Wait()
+
+	m.cleanup(ctx)
+
+	statusTicker := time.NewTicker(statusUpdateInterval)
+	defer statusTicker.Stop()
+	cleanupTicker := time.NewTicker(cleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-statusTicker.C:
+			m.updateStatuses(ctx)
+		case <-cleanupTicker.C:
+			m.cleanup(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// stopping marks all queries that are still queued as failed.
+// Running queries are cancelled when the context of the workers is cancelled and marked as failed by the workers.
+func (m *Manager) stopping(_ error) error {
+	for {
+		select {
+		case j := <-m.queue:
+			m.finish(j, StateFailed, "query frontend shut down before the query was executed")
+		default:
+			return nil
+		}
+	}
+}
+
+func (m *Manager) worker(ctx context.Context) {
+	for {
+		select {
+		case j := <-m.queue:
+			m.execute(ctx, j)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Submit validates the query range request given by params and queues it for execution.
+func (m *Manager) Submit(ctx context.Context, params url.Values) (Status, error) {
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+
+	httpReq, err := newQueryRangeRequest(ctx, params)
+	if err != nil {
+		return Status{}, err
+	}
+	req, err := m.codec.DecodeRequest(ctx, httpReq, nil)
+	if err != nil {
+		return Status{}, err
+	}
+
+	now := m.now()
+	j := &job{
+		orgID:    orgID,
+		progress: &progress{},
+		status: Status{
+			ID:          uuid.NewString(),
+			Query:       req.GetQuery(),
+			Params:      params,
+			State:       StateQueued,
+			SubmittedAt: now,
+			UpdatedAt:   now,
+		},
+	}
+
+	// The quota is enforced across query frontends with the active markers in object storage,
+	// the queries of this query frontend whose marker is not written yet are counted locally.
+	limit := validation.SmallestPositiveIntPerTenant(tenantIDs, m.limits.MaxAsyncQueries)
+	var active int
+	if limit > 0 {
+		if active, err = m.countActive(ctx, orgID); err != nil {
+			return Status{}, err
+		}
+	}
+
+	m.mtx.Lock()
+	if limit > 0 {
+		if local := m.activeJobs(orgID); local > active {
+			active = local
+		}
+		if active >= limit {
+			m.mtx.Unlock()
+			return Status{}, httpgrpc.Errorf(http.StatusTooManyRequests, "too many async queries: tenant has %d queued or running async queries, limit is %d", active, limit)
+		}
+	}
+	m.jobs[j.status.ID] = j
+	m.metrics.active.Inc()
+	status := j.status
+	m.mtx.Unlock()
+
+	if err := m.writeStatus(ctx, orgID, status); err != nil {
+		m.remove(j)
+		return Status{}, err
+	}
+	if err := m.writeActiveMarker(ctx, orgID, status.ID); err != nil {
+		m.remove(j)
+		_ = m.client.DeleteObject(ctx, m.key(orgID, status.ID, statusFile))
+		return Status{}, err
+	}
+
+	select {
+	case m.queue <- j:
+	default:
+		m.remove(j)
+		_ = m.client.DeleteObject(ctx, m.activeKey(orgID, status.ID))
+		_ = m.client.DeleteObject(ctx, m.key(orgID, status.ID, statusFile))
+		return Status{}, errQueueFull
+	}
+
+	m.metrics.submitted.WithLabelValues(orgID).Inc()
+	level.Info(m.logger).Log("msg", "async query submitted", "org_id", orgID, "id", status.ID, "query", status.Query)
+	return status, nil
+}
+
+// activeJobs returns the number of queued or running queries of the given tenant owned by this query frontend.
+// It must be called with m.mtx held.
+func (m *Manager) activeJobs(orgID string) int {
+	var n int
+	for _, j := range m.jobs {
+		if j.orgID == orgID {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Manager) remove(j *job) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.jobs[j.status.ID]; ok {
+		delete(m.jobs, j.status.ID)
+		m.metrics.active.Dec()
+	}
+}
+
+func (m *Manager) execute(ctx context.Context, j *job) {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	m.mtx.Lock()
+	if j.cancelRequested {
+		m.mtx.Unlock()
+		m.finish(j, StateCancelled, "")
+		return
+	}
+	now := m.now()
+	j.cancel = cancel
+	j.status.State = StateRunning
+	j.status.StartedAt = &now
+	id, params := j.status.ID, j.status.Params
+	m.mtx.Unlock()
+	m.persist(ctx, j)
+
+	ctx = user.InjectOrgID(ctx, j.orgID)
+	ctx = httpreq.InjectActorPath(ctx, queue.BackgroundQueueName)
+	ctx = httpreq.InjectHeader(ctx, httpreq.LokiQueryPriorityHeader, PriorityClass)
+	ctx = injectProgress(ctx, j.progress)
+
+	pages, items, err := m.run(ctx, j, params)
+
+	m.mtx.Lock()
+	cancelled := j.cancelRequested
+	j.status.Pages = pages
+	j.status.Items = items
+	m.mtx.Unlock()
+
+	switch {
+	case cancelled:
+		m.finish(j, StateCancelled, "")
+	case err != nil:
+		level.Warn(m.logger).Log("msg", "async query failed", "org_id", j.orgID, "id", id, "err", err)
+		m.finish(j, StateFailed, err.Error())
+	default:
+		m.finish(j, StateSucceeded, "")
+	}
+}
+
+// run executes the query and writes the pages of its result.
+func (m *Manager) run(ctx context.Context, j *job, params url.Values) (int, int, error) {
+	httpReq, err := newQueryRangeRequest(ctx, params)
+	if err != nil {
+		return 0, 0, err
+	}
+	req, err := m.codec.DecodeRequest(ctx, httpReq, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := m.handler.Do(ctx, req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the statistics of the merged response are more accurate than the sum of the downstream
+	// requests, e.g. because of results cache hits.
+	if r, ok := res.(interface{ GetStatistics() stats.Result }); ok {
+		summary := r.GetStatistics().Summary
+		if summary.TotalBytesProcessed > j.progress.bytesProcessed.Load() {
+			j.progress.bytesProcessed.Store(summary.TotalBytesProcessed)
+		}
+		if summary.TotalLinesProcessed > j.progress.linesProcessed.Load() {
+			j.progress.linesProcessed.Store(summary.TotalLinesProcessed)
+		}
+	}
+
+	pages, items, err := paginate(res, m.cfg.PageSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, page := range pages {
+		httpRes, err := m.codec.EncodeResponse(ctx, httpReq, page)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = m.client.PutObject(ctx, m.key(j.orgID, j.status.ID, pagesDir, pageFile(i)), httpRes.Body)
+		_ = httpRes.Body.Close()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to write page %d: %w", i, err)
+		}
+	}
+	return len(pages), items, nil
+}
+
+// finish sets the final state of the query, persists it and releases the query from this query frontend.
+func (m *Manager) finish(j *job, state State, errMsg string) {
+	m.mtx.Lock()
+	now := m.now()
+	j.status.State = state
+	j.status.Error = errMsg
+	j.status.FinishedAt = &now
+	expiresAt := now.Add(m.cfg.ResultsTTL)
+	j.status.ExpiresAt = &expiresAt
+	m.mtx.Unlock()
+
+	// the query context may already be cancelled at this point
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	m.persist(ctx, j)
+	_ = m.client.DeleteObject(ctx, m.key(j.orgID, j.status.ID, cancelFile))
+	_ = m.client.DeleteObject(ctx, m.activeKey(j.orgID, j.status.ID))
+
+	m.remove(j)
+	m.metrics.finished.WithLabelValues(string(state)).Inc()
+}
+
+// persist writes the current status of a query owned by this query frontend.
+func (m *Manager) persist(ctx context.Context, j *job) {
+	status := m.snapshot(j)
+	if err := m.writeStatus(ctx, j.orgID, status); err != nil {
+		level.Warn(m.logger).Log("msg", "failed to persist async query status", "org_id", j.orgID, "id", status.ID, "err", err)
+	}
+}
+
+func (m *Manager) snapshot(j *job) Status {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	j.status.Progress = j.progress.snapshot()
+	j.status.UpdatedAt = m.now()
+	return j.status
+}
+
+// updateStatuses persists the status of all queries owned by this query frontend
+// and cancels queries that were cancelled through another query frontend.
+func (m *Manager) updateStatuses(ctx context.Context) {
+	m.mtx.Lock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mtx.Unlock()
+
+	for _, j := range jobs {
+		cancelled, err := m.client.ObjectExists(ctx, m.key(j.orgID, j.status.ID, cancelFile))
+		if err != nil {
+			level.Warn(m.logger).Log("msg", "failed to check async query cancellation", "org_id", j.orgID, "id", j.status.ID, "err", err)
+		}
+		if cancelled {
+			m.cancelJob(j)
+		}
+		m.persist(ctx, j)
+		if err := m.writeActiveMarker(ctx, j.orgID, j.status.ID); err != nil {
+			level.Warn(m.logger).Log("msg", "failed to refresh async query active marker", "org_id", j.orgID, "id", j.status.ID, "err", err)
+		}
+	}
+}
+
+// countActive returns the number of queued or running queries of the tenant across all query frontends.
+// Markers which are not refreshed anymore belong to lost queries and are not counted.
+func (m *Manager) countActive(ctx context.Context, orgID string) (int, error) {
+	objects, _, err := m.client.List(ctx, m.activeKey(orgID, "")+"/", "")
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	now := m.now()
+	for _, obj := range objects {
+		updatedAt, err := m.readActiveMarker(ctx, obj.Key)
+		if err != nil {
+			if m.client.IsObjectNotFoundErr(err) {
+				continue
+			}
+			return 0, err
+		}
+		if now.Sub(updatedAt) <= staleStatusPeriod {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// writeActiveMarker writes or refreshes the marker of a queued or running query, which holds the time of the refresh.
+func (m *Manager) writeActiveMarker(ctx context.Context, orgID, id string) error {
+	return m.client.PutObject(ctx, m.activeKey(orgID, id), strings.NewReader(m.now().Format(time.RFC3339Nano)))
+}
+
+func (m *Manager) readActiveMarker(ctx context.Context, key string) (time.Time, error) {
+	rc, _, err := m.client.GetObject(ctx, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	updatedAt, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		// a corrupted marker is treated as stale.
+		return time.Time{}, nil
+	}
+	return updatedAt, nil
+}
+
+// cancelJob cancels a query owned by this query frontend.
+func (m *Manager) cancelJob(j *job) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	j.cancelRequested = true
+	if j.cancel != nil {
+		j.cancel()
+	}
+}
+
+// Status returns the status of a query.
+func (m *Manager) Status(ctx context.Context, id string) (Status, error) {
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+
+	m.mtx.Lock()
+	j, ok := m.jobs[id]
+	m.mtx.Unlock()
+	if ok && j.orgID == orgID {
+		return m.snapshot(j), nil
+	}
+
+	return m.readStatus(ctx, orgID, id)
+}
+
+// Cancel cancels a queued or running query. If the query already finished, its results are deleted.
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	j, ok := m.jobs[id]
+	m.mtx.Unlock()
+	if ok && j.orgID == orgID {
+		m.cancelJob(j)
+		return nil
+	}
+
+	status, err := m.readStatus(ctx, orgID, id)
+	if err != nil {
+		return err
+	}
+	if status.State.Finished() {
+		return m.delete(ctx, orgID, id)
+	}
+	// the query is owned by another query frontend which picks up the cancellation
+	// the next time it updates the status of the query.
+	return m.client.PutObject(ctx, m.key(orgID, id, cancelFile), strings.NewReader(m.now().Format(time.RFC3339)))
+}
+
+// Results returns the encoded page of the results of a succeeded query.
+func (m *Manager) Results(ctx context.Context, id string, page int) (io.ReadCloser, error) {
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := m.Status(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status.State != StateSucceeded {
+		return nil, errNotSucceeded
+	}
+	if page < 0 || page >= status.Pages {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "invalid page %d: async query has %d pages", page, status.Pages)
+	}
+
+	rc, _, err := m.client.GetObject(ctx, m.key(orgID, id, pagesDir, pageFile(page)))
+	if err != nil {
+		if m.client.IsObjectNotFoundErr(err) {
+			return nil, errQueryNotFound
+		}
+		return nil, err
+	}
+	return rc, nil
+}
+
+// cleanup deletes expired results and queries that are lost because their query frontend stopped updating them.
+func (m *Manager) cleanup(ctx context.Context) {
+	_, tenants, err := m.client.List(ctx, m.cfg.Prefix, "/")
+	if err != nil {
+		level.Warn(m.logger).Log("msg", "failed to list async query tenants", "err", err)
+		return
+	}
+
+	now := m.now()
+	for _, tenantPrefix := range tenants {
+		orgID := path.Base(strings.TrimSuffix(string(tenantPrefix), "/"))
+		m.cleanupActiveMarkers(ctx, orgID)
+
+		_, ids, err := m.client.List(ctx, string(tenantPrefix), "/")
+		if err != nil {
+			level.Warn(m.logger).Log("msg", "failed to list async queries", "org_id", orgID, "err", err)
+			continue
+		}
+		for _, idPrefix := range ids {
+			id := path.Base(strings.TrimSuffix(string(idPrefix), "/"))
+			if _, err := uuid.Parse(id); err != nil {
+				continue
+			}
+			status, err := m.readStatus(ctx, orgID, id)
+			if err != nil && !errors.Is(err, errQueryNotFound) {
+				level.Warn(m.logger).Log("msg", "failed to read async query status", "org_id", orgID, "id", id, "err", err)
+				continue
+			}
+			// objects without status or expired statuses are deleted, since readStatus does not return
+			// expired queries.
+			if err == nil && (status.ExpiresAt == nil || now.Before(*status.ExpiresAt)) {
+				continue
+			}
+			if err := m.delete(ctx, orgID, id); err != nil {
+				level.Warn(m.logger).Log("msg", "failed to delete async query", "org_id", orgID, "id", id, "err", err)
+				continue
+			}
+			level.Debug(m.logger).Log("msg", "deleted expired async query", "org_id", orgID, "id", id)
+		}
+	}
+}
+
+// cleanupActiveMarkers deletes the markers of lost queries, which are not refreshed anymore by their query frontend.
+func (m *Manager) cleanupActiveMarkers(ctx context.Context, orgID string) {
+	objects, _, err := m.client.List(ctx, m.activeKey(orgID, "")+"/", "")
+	if err != nil {
+		level.Warn(m.logger).Log("msg", "failed to list async query active markers", "org_id", orgID, "err", err)
+		return
+	}
+	now := m.now()
+	for _, obj := range objects {
+		updatedAt, err := m.readActiveMarker(ctx, obj.Key)
+		if err != nil || now.Sub(updatedAt) <= staleStatusPeriod {
+			continue
+		}
+		if err := m.client.DeleteObject(ctx, obj.Key); err != nil && !m.client.IsObjectNotFoundErr(err) {
+			level.Warn(m.logger).Log("msg", "failed to delete async query active marker", "org_id", orgID, "key", obj.Key, "err", err)
+		}
+	}
+}
+
+func (m *Manager) delete(ctx context.Context, orgID, id string) error {
+	objects, _, err := m.client.List(ctx, m.key(orgID, id)+"/", "")
+	if err != nil {
+		return err
+	}
+	// delete the status first, so that a partially deleted query is reported as not found.
+	if err := m.client.DeleteObject(ctx, m.key(orgID, id, statusFile)); err != nil && !m.client.IsObjectNotFoundErr(err) {
+		return err
+	}
+	for _, obj := range objects {
+		if err := m.client.DeleteObject(ctx, obj.Key); err != nil && !m.client.IsObjectNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) writeStatus(ctx context.Context, orgID string, status Status) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return m.client.PutObject(ctx, m.key(orgID, status.ID, statusFile), bytes.NewReader(data))
+}
+
+// readStatus reads the status of a query from object storage.
+// Queries that are not updated anymore by their query frontend are reported as failed,
+// expired queries are reported as not found.
+func (m *Manager) readStatus(ctx context.Context, orgID, id string) (Status, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Status{}, errQueryNotFound
+	}
+
+	rc, _, err := m.client.GetObject(ctx, m.key(orgID, id, statusFile))
+	if err != nil {
+		if m.client.IsObjectNotFoundErr(err) {
+			return Status{}, errQueryNotFound
+		}
+		return Status{}, err
+	}
+	defer rc.Close()
+
+	var status Status
+	if err := json.NewDecoder(rc).Decode(&status); err != nil {
+		return Status{}, fmt.Errorf("failed to decode async query status: %w", err)
+	}
+
+	now := m.now()
+	if !status.State.Finished() && now.Sub(status.UpdatedAt) > staleStatusPeriod {
+		status.State = StateFailed
+		status.Error = "async query was lost, the query frontend executing it stopped updating its status"
+		expiresAt := status.UpdatedAt.Add(m.cfg.ResultsTTL)
+		status.ExpiresAt = &expiresAt
+	}
+	if status.ExpiresAt != nil && !now.Before(*status.ExpiresAt) {
+		return Status{}, errQueryNotFound
+	}
+	return status, nil
+}
+
+func (m *Manager) key(orgID, id string, elem ...string) string {
+	return m.cfg.Prefix + path.Join(append([]string{orgID, id}, elem...)...)
+}
+
+// activeKey is the key of the active marker of a query, or of the directory of the markers if id is empty.
+func (m *Manager) activeKey(orgID, id string) string {
+	return m.cfg.Prefix + path.Join(orgID, activeDir, id)
+}
+
+func pageFile(page int) string {
+	return strconv.Itoa(page) + ".json"
+}
+
+func newQueryRangeRequest(ctx context.Context, params url.Values) (*http.Request, error) {
+	uri := queryRangeURI + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = uri
+	return req, nil
+}
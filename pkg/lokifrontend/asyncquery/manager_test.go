@@ -0,0 +1,317 @@
+package asyncquery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/queue"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/testutils"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+type fakeLimits struct {
+	maxAsyncQueries int
+}
+
+func (f fakeLimits) MaxAsyncQueries(_ string) int {
+	return f.maxAsyncQueries
+}
+
+func testConfig() Config {
+	return Config{
+		Enabled:       true,
+		Prefix:        "async-queries/",
+		MaxConcurrent: 2,
+		MaxQueued:     10,
+		Timeout:       time.Minute,
+		ResultsTTL:    time.Hour,
+		PageSize:      2,
+	}
+}
+
+func testParams() url.Values {
+	return url.Values{
+		"query": []string{`{app="foo"}`},
+		"start": []string{"2024-01-01T00:00:00Z"},
+		"end":   []string{"2024-01-02T00:00:00Z"},
+		"limit": []string{"100"},
+	}
+}
+
+func testResponse() *queryrange.LokiResponse {
+	return &queryrange.LokiResponse{
+		Status:    loghttp.QueryStatusSuccess,
+		Direction: logproto.BACKWARD,
+		Limit:     100,
+		Version:   uint32(loghttp.VersionV1),
+		Data: queryrange.LokiData{
+			ResultType: loghttp.ResultTypeStream,
+			Result: []logproto.Stream{
+				{
+					Labels: `{app="foo", pod="a"}`,
+					Entries: []logproto.Entry{
+						{Timestamp: time.Unix(3, 0), Line: "3"},
+						{Timestamp: time.Unix(2, 0), Line: "2"},
+						{Timestamp: time.Unix(1, 0), Line: "1"},
+					},
+				},
+				{
+					Labels: `{app="foo", pod="b"}`,
+					Entries: []logproto.Entry{
+						{Timestamp: time.Unix(1, 0), Line: "1"},
+					},
+				},
+			},
+		},
+		Statistics: stats.Result{Summary: stats.Summary{TotalBytesProcessed: 100, TotalLinesProcessed: 10}},
+	}
+}
+
+func newTestManager(t *testing.T, cfg Config, objectClient *testutils.InMemoryObjectClient, limits Limits, handler queryrangebase.Handler) *Manager {
+	m := NewManager(cfg, objectClient, ProgressMiddleware().Wrap(handler), queryrange.DefaultCodec, limits, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), m))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), m)
+	})
+	return m
+}
+
+func waitForState(t *testing.T, ctx context.Context, m *Manager, id string, state State) Status {
+	var status Status
+	require.Eventually(t, func() bool {
+		var err error
+		status, err = m.Status(ctx, id)
+		require.NoError(t, err)
+		return status.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func TestManager_Submit(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	objectClient := testutils.NewInMemoryObjectClient()
+
+	handler := queryrangebase.HandlerFunc(func(ctx context.Context, req queryrangebase.Request) (queryrangebase.Response, error) {
+		require.Equal(t, []string{queue.BackgroundQueueName}, httpreq.ExtractActorPath(ctx))
+		orgID, err := user.ExtractOrgID(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "tenant", orgID)
+		require.Equal(t, `{app="foo"}`, req.GetQuery())
+		return testResponse(), nil
+	})
+	m := newTestManager(t, testConfig(), objectClient, fakeLimits{}, handler)
+
+	status, err := m.Submit(ctx, testParams())
+	require.NoError(t, err)
+	require.Equal(t, `{app="foo"}`, status.Query)
+
+	status = waitForState(t, ctx, m, status.ID, StateSucceeded)
+	require.Equal(t, 2, status.Pages)
+	require.Equal(t, 4, status.Items)
+	require.Equal(t, int64(1), status.Progress.ShardsIssued)
+	require.Equal(t, int64(1), status.Progress.ShardsDone)
+	require.Equal(t, int64(100), status.Progress.BytesProcessed)
+	require.NotNil(t, status.ExpiresAt)
+
+	// the status is served by other query frontends from object storage
+	other := NewManager(testConfig(), objectClient, handler, queryrange.DefaultCodec, fakeLimits{}, log.NewNopLogger(), prometheus.NewRegistry())
+	persisted, err := other.Status(ctx, status.ID)
+	require.NoError(t, err)
+	require.Equal(t, StateSucceeded, persisted.State)
+	require.Equal(t, 2, persisted.Pages)
+
+	var lines []string
+	for page := 0; page < status.Pages; page++ {
+		rc, err := other.Results(ctx, status.ID, page)
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+
+		var res loghttp.QueryResponse
+		require.NoError(t, json.Unmarshal(data, &res))
+		for _, s := range res.Data.Result.(loghttp.Streams) {
+			for _, e := range s.Entries {
+				lines = append(lines, e.Line)
+			}
+		}
+	}
+	require.Equal(t, []string{"3", "2", "1", "1"}, lines)
+
+	_, err = other.Results(ctx, status.ID, 2)
+	require.Error(t, err)
+
+	// other tenants can not see the query
+	_, err = m.Status(user.InjectOrgID(context.Background(), "other"), status.ID)
+	require.Equal(t, errQueryNotFound, err)
+
+	// deleting a finished query deletes its results
+	require.NoError(t, other.Cancel(ctx, status.ID))
+	_, err = m.Status(ctx, status.ID)
+	require.Equal(t, errQueryNotFound, err)
+	objects, _, err := objectClient.List(ctx, "", "")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+}
+
+func TestManager_Failed(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	handler := queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "max entries limit per query exceeded")
+	})
+	m := newTestManager(t, testConfig(), testutils.NewInMemoryObjectClient(), fakeLimits{}, handler)
+
+	_, err := m.Submit(ctx, url.Values{"query": []string{"not a query"}})
+	require.Error(t, err)
+
+	status, err := m.Submit(ctx, testParams())
+	require.NoError(t, err)
+	status = waitForState(t, ctx, m, status.ID, StateFailed)
+	require.Contains(t, status.Error, "max entries limit per query exceeded")
+
+	_, err = m.Results(ctx, status.ID, 0)
+	require.Equal(t, errNotSucceeded, err)
+}
+
+func TestManager_QuotaAndCancel(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	objectClient := testutils.NewInMemoryObjectClient()
+
+	started := make(chan struct{}, 1)
+	handler := queryrangebase.HandlerFunc(func(ctx context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	m := newTestManager(t, testConfig(), objectClient, fakeLimits{maxAsyncQueries: 1}, handler)
+
+	status, err := m.Submit(ctx, testParams())
+	require.NoError(t, err)
+	<-started
+	waitForState(t, ctx, m, status.ID, StateRunning)
+
+	_, err = m.Submit(ctx, testParams())
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+
+	// the quota is per tenant
+	_, err = m.Submit(user.InjectOrgID(context.Background(), "other"), testParams())
+	require.NoError(t, err)
+	<-started
+
+	// cancel through another query frontend, which is picked up on the next status update
+	other := NewManager(testConfig(), objectClient, handler, queryrange.DefaultCodec, fakeLimits{}, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, other.Cancel(ctx, status.ID))
+	m.updateStatuses(ctx)
+	waitForState(t, ctx, m, status.ID, StateCancelled)
+
+	// the quota is released
+	status, err = m.Submit(ctx, testParams())
+	require.NoError(t, err)
+	<-started
+	require.NoError(t, m.Cancel(ctx, status.ID))
+	waitForState(t, ctx, m, status.ID, StateCancelled)
+}
+
+func TestManager_QuotaAcrossQueryFrontends(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	objectClient := testutils.NewInMemoryObjectClient()
+
+	started := make(chan struct{}, 1)
+	handler := queryrangebase.HandlerFunc(func(ctx context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	m := newTestManager(t, testConfig(), objectClient, fakeLimits{maxAsyncQueries: 1}, handler)
+	other := newTestManager(t, testConfig(), objectClient, fakeLimits{maxAsyncQueries: 1}, handler)
+
+	status, err := m.Submit(ctx, testParams())
+	require.NoError(t, err)
+	<-started
+
+	_, err = other.Submit(ctx, testParams())
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+
+	// the markers of lost queries are not counted, and deleted by the cleanup.
+	later := NewManager(testConfig(), objectClient, handler, queryrange.DefaultCodec, fakeLimits{}, log.NewNopLogger(), prometheus.NewRegistry())
+	later.now = func() time.Time { return time.Now().Add(2 * staleStatusPeriod) }
+	active, err := later.countActive(ctx, "tenant")
+	require.NoError(t, err)
+	require.Equal(t, 0, active)
+	later.cleanup(ctx)
+	exists, err := objectClient.ObjectExists(ctx, m.activeKey("tenant", status.ID))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// the marker is written back on the next status update of the owner.
+	m.updateStatuses(ctx)
+	active, err = other.countActive(ctx, "tenant")
+	require.NoError(t, err)
+	require.Equal(t, 1, active)
+
+	// the quota is released once the query finished.
+	require.NoError(t, other.Cancel(ctx, status.ID))
+	m.updateStatuses(ctx)
+	waitForState(t, ctx, m, status.ID, StateCancelled)
+	_, err = other.Submit(ctx, testParams())
+	require.NoError(t, err)
+	<-started
+}
+
+func TestManager_StaleAndExpired(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "tenant")
+	objectClient := testutils.NewInMemoryObjectClient()
+	cfg := testConfig()
+	m := NewManager(cfg, objectClient, nil, queryrange.DefaultCodec, fakeLimits{}, log.NewNopLogger(), prometheus.NewRegistry())
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	lost := Status{ID: "8d4c6f5e-2b4a-4f7e-9c1d-3a2b1c0d9e8f", State: StateRunning, UpdatedAt: now.Add(-2 * staleStatusPeriod)}
+	require.NoError(t, m.writeStatus(ctx, "tenant", lost))
+	expiresAt := now.Add(-time.Minute)
+	expired := Status{ID: "1f0e9d8c-7b6a-4954-8d3c-2b1a0f9e8d7c", State: StateSucceeded, UpdatedAt: now, ExpiresAt: &expiresAt}
+	require.NoError(t, m.writeStatus(ctx, "tenant", expired))
+
+	status, err := m.Status(ctx, lost.ID)
+	require.NoError(t, err)
+	require.Equal(t, StateFailed, status.State)
+	require.NotEmpty(t, status.Error)
+
+	_, err = m.Status(ctx, expired.ID)
+	require.Equal(t, errQueryNotFound, err)
+
+	m.cleanup(ctx)
+	objects, _, err := objectClient.List(ctx, "", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, m.key("tenant", lost.ID, statusFile), objects[0].Key)
+
+	// lost queries are deleted once their results would have expired
+	now = now.Add(cfg.ResultsTTL)
+	m.cleanup(ctx)
+	objects, _, err = objectClient.List(ctx, "", "")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+}
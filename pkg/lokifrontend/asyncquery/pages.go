@@ -0,0 +1,123 @@
+package asyncquery
+
+import (
+	"fmt"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+)
+
+// paginate splits the response of a query into pages of at most pageSize log entries or samples.
+// Streams and series that do not fit into a page are continued on the next page.
+// The statistics and warnings of the query are only part of the first page.
+// It also returns the total number of log entries or samples of the response.
+func paginate(res queryrangebase.Response, pageSize int) ([]queryrangebase.Response, int, error) {
+	switch r := res.(type) {
+	case *queryrange.LokiResponse:
+		return paginateStreams(r, pageSize), countEntries(r.Data.Result), nil
+	case *queryrange.LokiPromResponse:
+		return paginateSeries(r, pageSize), countSamples(r.Response.Data.Result), nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported response type %T", res)
+	}
+}
+
+func paginateStreams(res *queryrange.LokiResponse, pageSize int) []queryrangebase.Response {
+	newPage := func(first bool) *queryrange.LokiResponse {
+		page := &queryrange.LokiResponse{
+			Status:    res.Status,
+			Direction: res.Direction,
+			Limit:     res.Limit,
+			Version:   res.Version,
+			Data: queryrange.LokiData{
+				ResultType: res.Data.ResultType,
+			},
+		}
+		if first {
+			page.Statistics = res.Statistics
+			page.Warnings = res.Warnings
+		}
+		return page
+	}
+
+	page := newPage(true)
+	pages := []queryrangebase.Response{page}
+	size := 0
+	for _, stream := range res.Data.Result {
+		entries := stream.Entries
+		for len(entries) > 0 {
+			if size == pageSize {
+				page = newPage(false)
+				pages = append(pages, page)
+				size = 0
+			}
+			n := min(pageSize-size, len(entries))
+			page.Data.Result = append(page.Data.Result, logproto.Stream{
+				Labels:  stream.Labels,
+				Entries: entries[:n],
+				Hash:    stream.Hash,
+			})
+			entries = entries[n:]
+			size += n
+		}
+	}
+	return pages
+}
+
+func paginateSeries(res *queryrange.LokiPromResponse, pageSize int) []queryrangebase.Response {
+	newPage := func(first bool) *queryrange.LokiPromResponse {
+		page := &queryrange.LokiPromResponse{
+			Response: &queryrangebase.PrometheusResponse{
+				Status: res.Response.Status,
+				Data: queryrangebase.PrometheusData{
+					ResultType: res.Response.Data.ResultType,
+					Result:     []queryrangebase.SampleStream{},
+				},
+			},
+		}
+		if first {
+			page.Statistics = res.Statistics
+			page.Response.Warnings = res.Response.Warnings
+		}
+		return page
+	}
+
+	page := newPage(true)
+	pages := []queryrangebase.Response{page}
+	size := 0
+	for _, series := range res.Response.Data.Result {
+		samples := series.Samples
+		for len(samples) > 0 {
+			if size == pageSize {
+				page = newPage(false)
+				pages = append(pages, page)
+				size = 0
+			}
+			n := min(pageSize-size, len(samples))
+			page.Response.Data.Result = append(page.Response.Data.Result, queryrangebase.SampleStream{
+				Labels:  series.Labels,
+				Samples: samples[:n],
+			})
+			samples = samples[n:]
+			size += n
+		}
+	}
+	return pages
+}
+
+func countEntries(streams []logproto.Stream) int {
+	var n int
+	for _, s := range streams {
+		n += len(s.Entries)
+	}
+	return n
+}
+
+func countSamples(series []queryrangebase.SampleStream) int {
+	var n int
+	for _, s := range series {
+		n += len(s.Samples)
+	}
+	return n
+}
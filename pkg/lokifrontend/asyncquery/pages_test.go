@@ -0,0 +1,65 @@
+package asyncquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+)
+
+func TestPaginate(t *testing.T) {
+	t.Run("streams", func(t *testing.T) {
+		res := testResponse()
+		pages, items, err := paginate(res, 2)
+		require.NoError(t, err)
+		require.Equal(t, 4, items)
+		require.Len(t, pages, 2)
+
+		first := pages[0].(*queryrange.LokiResponse)
+		require.Len(t, first.Data.Result, 1)
+		require.Len(t, first.Data.Result[0].Entries, 2)
+		require.Equal(t, res.Statistics, first.Statistics)
+
+		second := pages[1].(*queryrange.LokiResponse)
+		require.Len(t, second.Data.Result, 2)
+		require.Equal(t, `{app="foo", pod="a"}`, second.Data.Result[0].Labels)
+		require.Len(t, second.Data.Result[0].Entries, 1)
+		require.Equal(t, `{app="foo", pod="b"}`, second.Data.Result[1].Labels)
+		require.Zero(t, second.Statistics.Summary.TotalBytesProcessed)
+	})
+
+	t.Run("series", func(t *testing.T) {
+		res := &queryrange.LokiPromResponse{
+			Response: &queryrangebase.PrometheusResponse{
+				Status: "success",
+				Data: queryrangebase.PrometheusData{
+					ResultType: "matrix",
+					Result: []queryrangebase.SampleStream{
+						{
+							Labels:  []logproto.LabelAdapter{{Name: "app", Value: "foo"}},
+							Samples: []logproto.LegacySample{{TimestampMs: 1, Value: 1}, {TimestampMs: 2, Value: 2}, {TimestampMs: 3, Value: 3}},
+						},
+					},
+				},
+			},
+		}
+		pages, items, err := paginate(res, 2)
+		require.NoError(t, err)
+		require.Equal(t, 3, items)
+		require.Len(t, pages, 2)
+		require.Len(t, pages[0].(*queryrange.LokiPromResponse).Response.Data.Result[0].Samples, 2)
+		require.Len(t, pages[1].(*queryrange.LokiPromResponse).Response.Data.Result[0].Samples, 1)
+	})
+
+	t.Run("empty result", func(t *testing.T) {
+		res := testResponse()
+		res.Data.Result = nil
+		pages, items, err := paginate(res, 2)
+		require.NoError(t, err)
+		require.Zero(t, items)
+		require.Len(t, pages, 1)
+	})
+}
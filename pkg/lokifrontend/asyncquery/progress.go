@@ -0,0 +1,78 @@
+package asyncquery
+
+import (
+	"context"
+
+	"go.uber.org/atomic"
+
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+)
+
+type progressContextKey struct{}
+
+// Progress describes how far the execution of an asynchronous query has come.
+type Progress struct {
+	ShardsIssued   int64 `json:"shards_issued"`
+	ShardsDone     int64 `json:"shards_done"`
+	BytesProcessed int64 `json:"bytes_processed"`
+	LinesProcessed int64 `json:"lines_processed"`
+}
+
+// progress tracks the downstream requests of a single asynchronous query.
+type progress struct {
+	shardsIssued   atomic.Int64
+	shardsDone     atomic.Int64
+	bytesProcessed atomic.Int64
+	linesProcessed atomic.Int64
+}
+
+func (p *progress) observe(res queryrangebase.Response) {
+	p.shardsDone.Inc()
+	if r, ok := res.(interface{ GetStatistics() stats.Result }); ok {
+		summary := r.GetStatistics().Summary
+		p.bytesProcessed.Add(summary.TotalBytesProcessed)
+		p.linesProcessed.Add(summary.TotalLinesProcessed)
+	}
+}
+
+func (p *progress) snapshot() Progress {
+	return Progress{
+		ShardsIssued:   p.shardsIssued.Load(),
+		ShardsDone:     p.shardsDone.Load(),
+		BytesProcessed: p.bytesProcessed.Load(),
+		LinesProcessed: p.linesProcessed.Load(),
+	}
+}
+
+func injectProgress(ctx context.Context, p *progress) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, p)
+}
+
+func extractProgress(ctx context.Context) *progress {
+	p, _ := ctx.Value(progressContextKey{}).(*progress)
+	return p
+}
+
+// ProgressMiddleware records the progress of asynchronous queries.
+// It must wrap the handler that sends split and sharded requests to the queriers,
+// so that every downstream request is counted as one shard.
+// Requests that are not part of an asynchronous query are passed through.
+func ProgressMiddleware() queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return queryrangebase.HandlerFunc(func(ctx context.Context, req queryrangebase.Request) (queryrangebase.Response, error) {
+			p := extractProgress(ctx)
+			if p == nil {
+				return next.Do(ctx, req)
+			}
+
+			p.shardsIssued.Inc()
+			res, err := next.Do(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			p.observe(res)
+			return res, nil
+		})
+	})
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/grafana/dskit/crypto/tls"
 
+	"github.com/grafana/loki/v3/pkg/lokifrontend/asyncquery"
+	"github.com/grafana/loki/v3/pkg/lokifrontend/fingerprints"
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend/transport"
 	v1 "github.com/grafana/loki/v3/pkg/lokifrontend/frontend/v1"
 	v2 "github.com/grafana/loki/v3/pkg/lokifrontend/frontend/v2"
@@ -20,6 +22,9 @@ type Config struct {
 
 	TailProxyURL string           `yaml:"tail_proxy_url"`
 	TLS          tls.ClientConfig `yaml:"tail_tls_config"`
+
+	AsyncQuery        asyncquery.Config   `yaml:"async_query"`
+	QueryFingerprints fingerprints.Config `yaml:"query_fingerprints"`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -28,8 +33,17 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.FrontendV1.RegisterFlags(f)
 	cfg.FrontendV2.RegisterFlags(f)
 	cfg.TLS.RegisterFlagsWithPrefix("frontend.tail-tls-config", f)
+	cfg.AsyncQuery.RegisterFlags(f)
+	cfg.QueryFingerprints.RegisterFlags(f)
 
 	f.BoolVar(&cfg.CompressResponses, "querier.compress-http-responses", true, "Compress HTTP responses.")
 	f.StringVar(&cfg.DownstreamURL, "frontend.downstream-url", "", "URL of downstream Loki.")
 	f.StringVar(&cfg.TailProxyURL, "frontend.tail-proxy-url", "", "URL of querier for tail proxy.")
 }
+
+func (cfg *Config) Validate() error {
+	if err := cfg.AsyncQuery.Validate(); err != nil {
+		return err
+	}
+	return cfg.QueryFingerprints.Validate()
+}
@@ -0,0 +1,35 @@
+package fingerprints
+
+import (
+	"errors"
+	"flag"
+)
+
+// Config configures the tracking of query fingerprints in the query frontend.
+//
+// A query fingerprint is the hash of the query with its literal values and range intervals replaced
+// by placeholders, so that queries differing only in the values of their labels, filters or ranges
+// share the same fingerprint. The query frontend tracks the concurrency and the cost of each
+// fingerprint, and lets operators throttle or block a fingerprint at runtime through the admin API.
+type Config struct {
+	Enabled         bool `yaml:"enabled"`
+	MaxTracked      int  `yaml:"max_tracked"`
+	AdminAPIEnabled bool `yaml:"admin_api_enabled"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "frontend.query-fingerprints.enabled", false, "Track the concurrency and cost of query fingerprints and enable the /loki/api/v1/query_fingerprints endpoints to list them and their rules.")
+	f.IntVar(&cfg.MaxTracked, "frontend.query-fingerprints.max-tracked", 10000, "Maximum number of fingerprints tracked by a query frontend. The least recently seen fingerprints are evicted first.")
+	f.BoolVar(&cfg.AdminAPIEnabled, "frontend.query-fingerprints.admin-api-enabled", false, "Enable the /query-frontend/query_fingerprints/rules admin endpoints to throttle or block query fingerprints at runtime. The rules are stored in the KV store of the ingester ring and shared by all the query frontends. The admin endpoints must not be exposed to the tenants.")
+}
+
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxTracked <= 0 {
+		return errors.New("query fingerprints max tracked must be greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package fingerprints
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+const defaultLimit = 20
+
+// FingerprintsResponse is the response of the ListHandler.
+type FingerprintsResponse struct {
+	Fingerprints []Stats `json:"fingerprints"`
+}
+
+// RulesResponse is the response of the RulesHandler.
+type RulesResponse struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ListHandler lists the top fingerprints of the tenant, by default by bytes processed.
+func (t *Tracker) ListHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	limit := defaultLimit
+	if v := r.FormValue("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid limit %q", v), w)
+			return
+		}
+	}
+
+	sortBy := SortByBytes
+	if v := r.FormValue("sort"); v != "" {
+		sortBy = SortBy(v)
+		switch sortBy {
+		case SortByBytes, SortByDuration, SortByCount, SortByRunning:
+		default:
+			serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid sort %q: must be one of %q, %q, %q or %q", v, SortByBytes, SortByDuration, SortByCount, SortByRunning), w)
+			return
+		}
+	}
+
+	util.WriteJSONResponse(w, FingerprintsResponse{Fingerprints: t.Top(tenantID, sortBy, limit)})
+}
+
+// RulesHandler lists the rules of the tenant.
+func (t *Tracker) RulesHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, RulesResponse{Rules: t.Rules(tenantID)})
+}
+
+// SetRuleHandler throttles or blocks a fingerprint of the tenant. The fingerprint is either given by
+// the fingerprint parameter or computed from the query parameter.
+func (t *Tracker) SetRuleHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	rule, err := t.parseRule(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if err := t.SetRule(r.Context(), tenantID, rule); err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	util.WriteJSONResponse(w, rule)
+}
+
+// DeleteRuleHandler deletes the rule of a fingerprint of the tenant.
+func (t *Tracker) DeleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := tenantFromRequest(r)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	fp := mux.Vars(r)["fingerprint"]
+	found, err := t.DeleteRule(r.Context(), tenantID, fp)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if !found {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusNotFound, "no rule for query fingerprint %s", fp), w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *Tracker) parseRule(r *http.Request) (Rule, error) {
+	if err := r.ParseForm(); err != nil {
+		return Rule{}, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	rule := Rule{
+		Fingerprint: r.Form.Get("fingerprint"),
+		Action:      Action(r.Form.Get("action")),
+		CreatedAt:   t.now(),
+	}
+	if q := r.Form.Get("query"); q != "" {
+		if rule.Fingerprint != "" {
+			return Rule{}, httpgrpc.Errorf(http.StatusBadRequest, "only one of fingerprint or query can be given")
+		}
+		expr, err := syntax.ParseExpr(q)
+		if err != nil {
+			return Rule{}, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+		}
+		rule.Fingerprint = syntax.Fingerprint(expr)
+	}
+
+	var err error
+	if rule.MaxConcurrency, err = formInt(r, "max_concurrency"); err != nil {
+		return Rule{}, err
+	}
+	if rule.MaxQueriesPerMinute, err = formInt(r, "max_queries_per_minute"); err != nil {
+		return Rule{}, err
+	}
+	if v := r.Form.Get("duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Rule{}, httpgrpc.Errorf(http.StatusBadRequest, "invalid duration %q", v)
+		}
+		expiresAt := rule.CreatedAt.Add(d)
+		rule.ExpiresAt = &expiresAt
+	}
+	return rule, nil
+}
+
+func formInt(r *http.Request, name string) (int, error) {
+	v := r.Form.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, httpgrpc.Errorf(http.StatusBadRequest, "invalid %s %q: %s", name, v, err.Error())
+	}
+	return i, nil
+}
+
+func tenantFromRequest(r *http.Request) (string, error) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return "", httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+	return tenant.JoinTenantIDs(tenantIDs), nil
+}
@@ -0,0 +1,102 @@
+package fingerprints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(tracker *Tracker) http.Handler {
+	router := mux.NewRouter()
+	router.Path("/loki/api/v1/query_fingerprints").Methods("GET").HandlerFunc(tracker.ListHandler)
+	router.Path("/loki/api/v1/query_fingerprints/rules").Methods("GET").HandlerFunc(tracker.RulesHandler)
+	router.Path("/query-frontend/query_fingerprints/rules").Methods("POST").HandlerFunc(tracker.SetRuleHandler)
+	router.Path("/query-frontend/query_fingerprints/rules/{fingerprint}").Methods("DELETE").HandlerFunc(tracker.DeleteRuleHandler)
+	return router
+}
+
+func request(t *testing.T, handler http.Handler, method, target string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func Test_Handlers(t *testing.T) {
+	tracker := newTestTracker(10)
+	handler := tracker.Middleware().Wrap(testHandler(100))
+	router := newTestRouter(tracker)
+
+	require.NoError(t, do(t, handler, "1", `{app="foo"} |= "error"`))
+
+	w := request(t, router, "GET", "/loki/api/v1/query_fingerprints?limit=10&sort=count", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list FingerprintsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Fingerprints, 1)
+	require.Equal(t, `{app=?} |= ?`, list.Fingerprints[0].Query)
+
+	// The fingerprint of a rule can be computed from a query.
+	w = request(t, router, "POST", "/query-frontend/query_fingerprints/rules", url.Values{
+		"query":                  []string{`{app="bar"} |= "timeout"`},
+		"action":                 []string{"throttle"},
+		"max_queries_per_minute": []string{"10"},
+		"duration":               []string{"1h"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(t, router, "GET", "/loki/api/v1/query_fingerprints/rules", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var rules RulesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+	expiresAt := testTime.Add(time.Hour)
+	require.Equal(t, []Rule{{
+		Fingerprint:         list.Fingerprints[0].Fingerprint,
+		Action:              ActionThrottle,
+		MaxQueriesPerMinute: 10,
+		CreatedAt:           testTime,
+		ExpiresAt:           &expiresAt,
+	}}, rules.Rules)
+
+	w = request(t, router, "DELETE", "/query-frontend/query_fingerprints/rules/"+list.Fingerprints[0].Fingerprint, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = request(t, router, "DELETE", "/query-frontend/query_fingerprints/rules/"+list.Fingerprints[0].Fingerprint, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_HandlersInvalid(t *testing.T) {
+	router := newTestRouter(newTestTracker(10))
+
+	for _, tc := range []struct {
+		method, target string
+		form           url.Values
+	}{
+		{"GET", "/loki/api/v1/query_fingerprints?limit=-1", nil},
+		{"GET", "/loki/api/v1/query_fingerprints?sort=cost", nil},
+		{"POST", "/query-frontend/query_fingerprints/rules", url.Values{"action": []string{"block"}}},
+		{"POST", "/query-frontend/query_fingerprints/rules", url.Values{"fingerprint": []string{"a"}, "action": []string{"drop"}}},
+		{"POST", "/query-frontend/query_fingerprints/rules", url.Values{"query": []string{`{app=`}, "action": []string{"block"}}},
+		{"POST", "/query-frontend/query_fingerprints/rules", url.Values{"fingerprint": []string{"a"}, "query": []string{`{app="foo"}`}, "action": []string{"block"}}},
+		{"POST", "/query-frontend/query_fingerprints/rules", url.Values{"fingerprint": []string{"a"}, "action": []string{"throttle"}, "max_concurrency": []string{"one"}}},
+		{"POST", "/query-frontend/query_fingerprints/rules", url.Values{"fingerprint": []string{"a"}, "action": []string{"block"}, "duration": []string{"-1h"}}},
+	} {
+		w := request(t, router, tc.method, tc.target, tc.form)
+		require.Equal(t, http.StatusBadRequest, w.Code, tc.target, tc.form)
+	}
+}
@@ -0,0 +1,131 @@
+package fingerprints
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/dskit/kv/memberlist"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// rulesKey is the key of the RuleSet in the KV store.
+const rulesKey = "query-fingerprint-rules"
+
+// RuleSet is the set of rules of all the tenants, shared by the query frontends through the KV store.
+type RuleSet struct {
+	// Rules are indexed by tenant and fingerprint.
+	Rules map[string]*RuleDesc `json:"rules"`
+}
+
+// RuleDesc is a rule of a tenant in the RuleSet. A deleted rule is kept as a tombstone, so that its
+// deletion is propagated by gossiping KV stores.
+type RuleDesc struct {
+	Tenant    string    `json:"tenant"`
+	Rule      Rule      `json:"rule"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Deleted   bool      `json:"deleted,omitempty"`
+}
+
+func ruleSetKey(tenantID, fp string) string {
+	return tenantID + "/" + fp
+}
+
+func newRuleSet() *RuleSet {
+	return &RuleSet{Rules: map[string]*RuleDesc{}}
+}
+
+// newer returns true if d replaces other: the latest update wins, and a deletion wins over an update made at the same time.
+func (d *RuleDesc) newer(other *RuleDesc) bool {
+	if !d.UpdatedAt.Equal(other.UpdatedAt) {
+		return d.UpdatedAt.After(other.UpdatedAt)
+	}
+	return d.Deleted && !other.Deleted
+}
+
+// Merge implements the memberlist.Mergeable interface.
+// Each rule is merged independently, keeping its latest update.
+func (s *RuleSet) Merge(mergeable memberlist.Mergeable, _ bool) (memberlist.Mergeable, error) {
+	if mergeable == nil {
+		return nil, nil
+	}
+	other, ok := mergeable.(*RuleSet)
+	if !ok {
+		return nil, fmt.Errorf("expected *fingerprints.RuleSet, got %T", mergeable)
+	}
+	if other == nil {
+		return nil, nil
+	}
+	if s.Rules == nil {
+		s.Rules = map[string]*RuleDesc{}
+	}
+
+	change := newRuleSet()
+	for k, d := range other.Rules {
+		if current, ok := s.Rules[k]; ok && !d.newer(current) {
+			continue
+		}
+		s.Rules[k] = d
+		change.Rules[k] = d
+	}
+	if len(change.Rules) == 0 {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// MergeContent implements the memberlist.Mergeable interface.
+func (s *RuleSet) MergeContent() []string {
+	keys := make([]string, 0, len(s.Rules))
+	for k := range s.Rules {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// RemoveTombstones implements the memberlist.Mergeable interface.
+func (s *RuleSet) RemoveTombstones(limit time.Time) (total, removed int) {
+	for k, d := range s.Rules {
+		if !d.Deleted {
+			continue
+		}
+		if limit.IsZero() || d.UpdatedAt.Before(limit) {
+			delete(s.Rules, k)
+			removed++
+		} else {
+			total++
+		}
+	}
+	return total, removed
+}
+
+// Clone implements the memberlist.Mergeable interface.
+func (s *RuleSet) Clone() memberlist.Mergeable {
+	clone := newRuleSet()
+	for k, d := range s.Rules {
+		desc := *d
+		clone.Rules[k] = &desc
+	}
+	return clone
+}
+
+// RulesCodec is the codec of the RuleSet in the KV store.
+var RulesCodec = rulesCodec{}
+
+type rulesCodec struct{}
+
+func (rulesCodec) Decode(data []byte) (interface{}, error) {
+	s := newRuleSet()
+	if err := jsoniter.ConfigFastest.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Rules == nil {
+		s.Rules = map[string]*RuleDesc{}
+	}
+	return s, nil
+}
+
+func (rulesCodec) Encode(obj interface{}) ([]byte, error) {
+	return jsoniter.ConfigFastest.Marshal(obj)
+}
+
+func (rulesCodec) CodecID() string { return "fingerprints.rulesCodec" }
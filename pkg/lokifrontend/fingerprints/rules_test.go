@@ -0,0 +1,60 @@
+package fingerprints
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RuleSetMerge(t *testing.T) {
+	block := Rule{Fingerprint: "a", Action: ActionBlock}
+	throttle := Rule{Fingerprint: "a", Action: ActionThrottle, MaxConcurrency: 1}
+
+	set := &RuleSet{Rules: map[string]*RuleDesc{
+		"1/a": {Tenant: "1", Rule: block, UpdatedAt: testTime},
+	}}
+
+	// An older update is ignored.
+	change, err := set.Merge(&RuleSet{Rules: map[string]*RuleDesc{
+		"1/a": {Tenant: "1", Rule: throttle, UpdatedAt: testTime.Add(-time.Second)},
+	}}, false)
+	require.NoError(t, err)
+	require.Nil(t, change)
+	require.Equal(t, block, set.Rules["1/a"].Rule)
+
+	// A newer update replaces the rule.
+	change, err = set.Merge(&RuleSet{Rules: map[string]*RuleDesc{
+		"1/a": {Tenant: "1", Rule: throttle, UpdatedAt: testTime.Add(time.Second)},
+		"2/a": {Tenant: "2", Rule: block, UpdatedAt: testTime},
+	}}, false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"1/a", "2/a"}, change.MergeContent())
+	require.Equal(t, throttle, set.Rules["1/a"].Rule)
+
+	// A deletion made at the same time as an update wins.
+	_, err = set.Merge(&RuleSet{Rules: map[string]*RuleDesc{
+		"2/a": {Tenant: "2", Rule: block, UpdatedAt: testTime, Deleted: true},
+	}}, false)
+	require.NoError(t, err)
+	require.True(t, set.Rules["2/a"].Deleted)
+
+	total, removed := set.RemoveTombstones(testTime.Add(-time.Second))
+	require.Equal(t, 1, total)
+	require.Equal(t, 0, removed)
+	total, removed = set.RemoveTombstones(time.Time{})
+	require.Equal(t, 0, total)
+	require.Equal(t, 1, removed)
+	require.Len(t, set.Rules, 1)
+}
+
+func Test_RulesCodec(t *testing.T) {
+	set := &RuleSet{Rules: map[string]*RuleDesc{
+		"1/a": {Tenant: "1", Rule: Rule{Fingerprint: "a", Action: ActionBlock, CreatedAt: testTime}, UpdatedAt: testTime},
+	}}
+	data, err := RulesCodec.Encode(set)
+	require.NoError(t, err)
+	decoded, err := RulesCodec.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, set, decoded)
+}
@@ -0,0 +1,500 @@
+package fingerprints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+)
+
+// Action is the action applied by a rule to the queries of a fingerprint.
+type Action string
+
+const (
+	// ActionBlock rejects all the queries of the fingerprint.
+	ActionBlock Action = "block"
+	// ActionThrottle rejects the queries of the fingerprint exceeding its maximum concurrency or rate.
+	ActionThrottle Action = "throttle"
+)
+
+// Rule throttles or blocks the queries of a fingerprint of a tenant.
+// Rules are shared by all the query frontends, but the maximum concurrency and queries per minute
+// of a throttle rule are enforced by each query frontend independently.
+type Rule struct {
+	Fingerprint         string     `json:"fingerprint"`
+	Action              Action     `json:"action"`
+	MaxConcurrency      int        `json:"maxConcurrency,omitempty"`
+	MaxQueriesPerMinute int        `json:"maxQueriesPerMinute,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	ExpiresAt           *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Validate returns an error if the rule is invalid.
+func (r Rule) Validate() error {
+	if r.Fingerprint == "" {
+		return httpgrpc.Errorf(http.StatusBadRequest, "missing fingerprint")
+	}
+	switch r.Action {
+	case ActionBlock:
+	case ActionThrottle:
+		if r.MaxConcurrency < 0 || r.MaxQueriesPerMinute < 0 {
+			return httpgrpc.Errorf(http.StatusBadRequest, "the maximum concurrency and queries per minute of a throttle rule must not be negative")
+		}
+		if r.MaxConcurrency == 0 && r.MaxQueriesPerMinute == 0 {
+			return httpgrpc.Errorf(http.StatusBadRequest, "a throttle rule requires a maximum concurrency or queries per minute")
+		}
+	default:
+		return httpgrpc.Errorf(http.StatusBadRequest, "invalid action %q: must be one of %q or %q", r.Action, ActionBlock, ActionThrottle)
+	}
+	return nil
+}
+
+func (r Rule) expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// Stats are the statistics of the queries of a fingerprint of a tenant, since the fingerprint was first tracked.
+type Stats struct {
+	Fingerprint string `json:"fingerprint"`
+	// Query is the normalized query of the fingerprint.
+	Query string `json:"query"`
+
+	Count     int64 `json:"count"`
+	Running   int64 `json:"running"`
+	Throttled int64 `json:"throttled"`
+	Blocked   int64 `json:"blocked"`
+
+	// BytesProcessed and Duration are the total cost of the completed queries of the fingerprint.
+	BytesProcessed int64   `json:"bytesProcessed"`
+	Duration       float64 `json:"duration"`
+
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// SortBy is the order in which fingerprints are listed, always descending.
+type SortBy string
+
+const (
+	SortByBytes    SortBy = "bytes"
+	SortByDuration SortBy = "duration"
+	SortByCount    SortBy = "count"
+	SortByRunning  SortBy = "running"
+)
+
+func (s SortBy) less(a, b *Stats) bool {
+	switch s {
+	case SortByDuration:
+		if a.Duration != b.Duration {
+			return a.Duration > b.Duration
+		}
+	case SortByCount:
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+	case SortByRunning:
+		if a.Running != b.Running {
+			return a.Running > b.Running
+		}
+	}
+	if a.BytesProcessed != b.BytesProcessed {
+		return a.BytesProcessed > b.BytesProcessed
+	}
+	return a.Fingerprint < b.Fingerprint
+}
+
+type key struct {
+	tenant      string
+	fingerprint string
+}
+
+type rule struct {
+	Rule
+	updatedAt time.Time
+	limiter   *rate.Limiter
+}
+
+func newRule(d *RuleDesc) *rule {
+	r := &rule{Rule: d.Rule, updatedAt: d.UpdatedAt}
+	if r.Action == ActionThrottle && r.MaxQueriesPerMinute > 0 {
+		r.limiter = rate.NewLimiter(rate.Limit(float64(r.MaxQueriesPerMinute)/60), r.MaxQueriesPerMinute)
+	}
+	return r
+}
+
+type metrics struct {
+	rejected *prometheus.CounterVec
+	tracked  prometheus.Gauge
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		rejected: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "frontend_query_fingerprints_rejected_total",
+			Help:      "Total number of queries rejected by query fingerprint rules by action.",
+		}, []string{"tenant", "action"}),
+		tracked: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "frontend_query_fingerprints_tracked",
+			Help:      "Number of query fingerprints tracked by this query frontend.",
+		}),
+	}
+}
+
+// Tracker tracks the concurrency and cost of the query fingerprints of each tenant,
+// and applies the rules throttling or blocking them.
+// Fingerprints are kept in memory and are local to each query frontend, while rules are stored in
+// the KV store and shared by all the query frontends.
+type Tracker struct {
+	services.Service
+
+	cfg     Config
+	kv      kv.Client
+	logger  log.Logger
+	metrics *metrics
+	now     func() time.Time
+
+	mtx          sync.Mutex
+	fingerprints map[key]*Stats
+	rules        map[key]*rule
+}
+
+// NewTracker creates the fingerprint tracker, storing the rules in the given KV store.
+// It returns nil when tracking is disabled.
+func NewTracker(cfg Config, kvConfig kv.Config, logger log.Logger, r prometheus.Registerer) (*Tracker, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	kvClient, err := kv.NewClient(kvConfig, RulesCodec, kv.RegistererWithKVName(r, "query-fingerprint-rules"), logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the query fingerprint rules KV client: %w", err)
+	}
+	t := &Tracker{
+		cfg:          cfg,
+		kv:           kvClient,
+		logger:       logger,
+		metrics:      newMetrics(r),
+		now:          time.Now,
+		fingerprints: make(map[key]*Stats),
+		rules:        make(map[key]*rule),
+	}
+	t.Service = services.NewBasicService(t.starting, t.running, nil)
+	return t, nil
+}
+
+func (t *Tracker) starting(ctx context.Context) error {
+	v, err := t.kv.Get(ctx, rulesKey)
+	if err != nil {
+		return fmt.Errorf("failed to get the query fingerprint rules: %w", err)
+	}
+	t.syncRules(v)
+	return nil
+}
+
+// running keeps the rules of this query frontend in sync with the rules of the KV store.
+func (t *Tracker) running(ctx context.Context) error {
+	t.kv.WatchKey(ctx, rulesKey, func(v interface{}) bool {
+		t.syncRules(v)
+		return true
+	})
+	return nil
+}
+
+// syncRules replaces the rules with the rules of the KV store. The limiters of the unchanged rules are kept.
+func (t *Tracker) syncRules(v interface{}) {
+	set, ok := v.(*RuleSet)
+	if !ok || set == nil {
+		set = newRuleSet()
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := t.now()
+	rules := make(map[key]*rule, len(set.Rules))
+	for _, d := range set.Rules {
+		if d.Deleted || d.Rule.expired(now) {
+			continue
+		}
+		k := key{tenant: d.Tenant, fingerprint: d.Rule.Fingerprint}
+		if r, ok := t.rules[k]; ok && r.updatedAt.Equal(d.UpdatedAt) {
+			rules[k] = r
+			continue
+		}
+		rules[k] = newRule(d)
+	}
+	t.rules = rules
+}
+
+// Middleware returns the middleware tracking the fingerprints of log and metric queries, and rejecting
+// the queries throttled or blocked by a rule.
+func (t *Tracker) Middleware() queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		if t == nil {
+			return next
+		}
+		return queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+			switch r.(type) {
+			case *queryrange.LokiRequest, *queryrange.LokiInstantRequest:
+			default:
+				return next.Do(ctx, r)
+			}
+			if queryrange.IsDryRun(ctx) {
+				return next.Do(ctx, r)
+			}
+
+			params, err := queryrange.ParamsFromRequest(r)
+			if err != nil {
+				return nil, err
+			}
+			tenantIDs, err := tenant.TenantIDs(ctx)
+			if err != nil {
+				return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+			}
+			tenantID := tenant.JoinTenantIDs(tenantIDs)
+			expr := params.GetExpression()
+			fp := syntax.Fingerprint(expr)
+
+			s, err := t.start(tenantID, fp, expr)
+			if err != nil {
+				level.Warn(t.logger).Log("msg", "query rejected by query fingerprint rule", "tenant", tenantID, "fingerprint", fp, "query", params.QueryString(), "err", err)
+				return nil, err
+			}
+
+			start := t.now()
+			resp, err := next.Do(ctx, r)
+			t.finish(s, resp, t.now().Sub(start))
+			return resp, err
+		})
+	})
+}
+
+// start records the start of a query of the fingerprint, or returns an error if a rule rejects it.
+func (t *Tracker) start(tenantID, fp string, expr syntax.Expr) (*Stats, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := t.now()
+	k := key{tenant: tenantID, fingerprint: fp}
+	s, ok := t.fingerprints[k]
+	if !ok {
+		t.evict()
+		s = &Stats{Fingerprint: fp, Query: syntax.Normalize(expr), FirstSeen: now}
+		t.fingerprints[k] = s
+		t.metrics.tracked.Set(float64(len(t.fingerprints)))
+	}
+	s.Count++
+	s.LastSeen = now
+
+	if r, ok := t.rules[k]; ok {
+		if r.expired(now) {
+			delete(t.rules, k)
+		} else if err := r.apply(s, now); err != nil {
+			t.metrics.rejected.WithLabelValues(tenantID, string(r.Action)).Inc()
+			return nil, err
+		}
+	}
+
+	s.Running++
+	return s, nil
+}
+
+// apply returns an error if the rule rejects a new query of the fingerprint.
+func (r *rule) apply(s *Stats, now time.Time) error {
+	if r.Action == ActionBlock {
+		s.Blocked++
+		return fmt.Errorf("%w: query fingerprint %s is blocked", logqlmodel.ErrBlocked, s.Fingerprint)
+	}
+	if r.MaxConcurrency > 0 && s.Running >= int64(r.MaxConcurrency) {
+		s.Throttled++
+		return httpgrpc.Errorf(http.StatusTooManyRequests, "query fingerprint %s is throttled: too many running queries (limit: %d)", s.Fingerprint, r.MaxConcurrency)
+	}
+	if r.limiter != nil && !r.limiter.AllowN(now, 1) {
+		s.Throttled++
+		return httpgrpc.Errorf(http.StatusTooManyRequests, "query fingerprint %s is throttled: too many queries per minute (limit: %d)", s.Fingerprint, r.MaxQueriesPerMinute)
+	}
+	return nil
+}
+
+// finish records the completion of a query of the fingerprint.
+func (t *Tracker) finish(s *Stats, resp queryrangebase.Response, duration time.Duration) {
+	var bytes int64
+	if statistics := responseStatistics(resp); statistics != nil {
+		bytes = statistics.Summary.TotalBytesProcessed
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	s.Running--
+	s.BytesProcessed += bytes
+	s.Duration += duration.Seconds()
+}
+
+// evict removes the least recently seen fingerprint without running queries when the tracker is full.
+// It must be called with the lock held.
+func (t *Tracker) evict() {
+	if len(t.fingerprints) < t.cfg.MaxTracked {
+		return
+	}
+	var (
+		oldest key
+		found  bool
+	)
+	for k, s := range t.fingerprints {
+		if s.Running > 0 {
+			continue
+		}
+		if !found || s.LastSeen.Before(t.fingerprints[oldest].LastSeen) {
+			oldest, found = k, true
+		}
+	}
+	if found {
+		delete(t.fingerprints, oldest)
+	}
+}
+
+// Top returns the statistics of the top fingerprints of the tenant in the given order.
+// A limit of 0 returns all the fingerprints of the tenant.
+func (t *Tracker) Top(tenantID string, sortBy SortBy, limit int) []Stats {
+	t.mtx.Lock()
+	top := make([]*Stats, 0, len(t.fingerprints))
+	for k, s := range t.fingerprints {
+		if k.tenant == tenantID {
+			top = append(top, s)
+		}
+	}
+	sort.Slice(top, func(i, j int) bool { return sortBy.less(top[i], top[j]) })
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	res := make([]Stats, 0, len(top))
+	for _, s := range top {
+		res = append(res, *s)
+	}
+	t.mtx.Unlock()
+	return res
+}
+
+// SetRule adds the rule for the fingerprint of the tenant, replacing the existing one.
+func (t *Tracker) SetRule(ctx context.Context, tenantID string, r Rule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	k := ruleSetKey(tenantID, r.Fingerprint)
+	if err := t.updateRules(ctx, func(set *RuleSet, now time.Time) bool {
+		set.Rules[k] = &RuleDesc{Tenant: tenantID, Rule: r, UpdatedAt: updateTime(set.Rules[k], now)}
+		return true
+	}); err != nil {
+		return err
+	}
+	level.Info(t.logger).Log("msg", "query fingerprint rule set", "tenant", tenantID, "fingerprint", r.Fingerprint, "action", r.Action)
+	return nil
+}
+
+// DeleteRule deletes the rule for the fingerprint of the tenant. It returns false if there is none.
+func (t *Tracker) DeleteRule(ctx context.Context, tenantID, fp string) (bool, error) {
+	k := ruleSetKey(tenantID, fp)
+	var found bool
+	if err := t.updateRules(ctx, func(set *RuleSet, now time.Time) bool {
+		d, ok := set.Rules[k]
+		found = ok && !d.Deleted && !d.Rule.expired(now)
+		if !ok || d.Deleted {
+			return false
+		}
+		set.Rules[k] = &RuleDesc{Tenant: tenantID, Rule: d.Rule, UpdatedAt: updateTime(d, now), Deleted: true}
+		return true
+	}); err != nil {
+		return false, err
+	}
+	if found {
+		level.Info(t.logger).Log("msg", "query fingerprint rule deleted", "tenant", tenantID, "fingerprint", fp)
+	}
+	return found, nil
+}
+
+// updateRules updates the rules of the KV store with f, which returns false to leave them unchanged.
+// The expired rules are deleted with every update.
+func (t *Tracker) updateRules(ctx context.Context, f func(set *RuleSet, now time.Time) bool) error {
+	var updated *RuleSet
+	err := t.kv.CAS(ctx, rulesKey, func(in interface{}) (interface{}, bool, error) {
+		set, ok := in.(*RuleSet)
+		if !ok || set == nil {
+			set = newRuleSet()
+		}
+		now := t.now()
+		if !f(set, now) {
+			updated = set
+			return nil, false, nil
+		}
+		for k, d := range set.Rules {
+			if !d.Deleted && d.Rule.expired(now) {
+				set.Rules[k] = &RuleDesc{Tenant: d.Tenant, Rule: d.Rule, UpdatedAt: updateTime(d, now), Deleted: true}
+			}
+		}
+		updated = set
+		return set, true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the query fingerprint rules: %w", err)
+	}
+	t.syncRules(updated)
+	return nil
+}
+
+// updateTime returns the time of an update of the rule, after its previous update so that the update
+// wins when the rules are merged even if the clocks of the query frontends are skewed.
+func updateTime(previous *RuleDesc, now time.Time) time.Time {
+	if previous != nil && !now.After(previous.UpdatedAt) {
+		return previous.UpdatedAt.Add(time.Nanosecond)
+	}
+	return now
+}
+
+// Rules returns the rules of the tenant ordered by fingerprint.
+func (t *Tracker) Rules(tenantID string) []Rule {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := t.now()
+	res := []Rule{}
+	for k, r := range t.rules {
+		if k.tenant != tenantID {
+			continue
+		}
+		if r.expired(now) {
+			delete(t.rules, k)
+			continue
+		}
+		res = append(res, r.Rule)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Fingerprint < res[j].Fingerprint })
+	return res
+}
+
+func responseStatistics(resp queryrangebase.Response) *stats.Result {
+	switch r := resp.(type) {
+	case *queryrange.LokiResponse:
+		return &r.Statistics
+	case *queryrange.LokiPromResponse:
+		return &r.Statistics
+	default:
+		return nil
+	}
+}
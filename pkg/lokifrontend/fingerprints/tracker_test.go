@@ -0,0 +1,268 @@
+package fingerprints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestTracker(maxTracked int) *Tracker {
+	kvClient, _ := consul.NewInMemoryClient(RulesCodec, log.NewNopLogger(), nil)
+	return newTestTrackerWithKV(kvClient, maxTracked)
+}
+
+func newTestTrackerWithKV(kvClient kv.Client, maxTracked int) *Tracker {
+	t, err := NewTracker(Config{Enabled: true, MaxTracked: maxTracked}, kv.Config{Mock: kvClient}, log.NewNopLogger(), prometheus.NewRegistry())
+	if err != nil {
+		panic(err)
+	}
+	t.now = func() time.Time { return testTime }
+	return t
+}
+
+func testRequest(query string) *queryrange.LokiRequest {
+	return &queryrange.LokiRequest{
+		Query:   query,
+		Limit:   100,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+		Path:    "/loki/api/v1/query_range",
+		Plan:    &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+	}
+}
+
+func fingerprint(query string) string {
+	return syntax.Fingerprint(syntax.MustParseExpr(query))
+}
+
+func do(t *testing.T, handler queryrangebase.Handler, tenantID, query string) error {
+	t.Helper()
+	_, err := handler.Do(user.InjectOrgID(context.Background(), tenantID), testRequest(query))
+	return err
+}
+
+func testHandler(bytes int64) queryrangebase.Handler {
+	return queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return &queryrange.LokiResponse{
+			Status:     loghttp.QueryStatusSuccess,
+			Statistics: stats.Result{Summary: stats.Summary{TotalBytesProcessed: bytes}},
+		}, nil
+	})
+}
+
+func Test_TrackerStats(t *testing.T) {
+	tracker := newTestTracker(10)
+	handler := tracker.Middleware().Wrap(testHandler(100))
+
+	require.NoError(t, do(t, handler, "1", `{app="foo"} |= "error"`))
+	require.NoError(t, do(t, handler, "1", `{app="bar"} |= "timeout"`))
+	require.NoError(t, do(t, handler, "1", `sum(rate({app="foo"}[5m]))`))
+	require.NoError(t, do(t, handler, "2", `{app="foo"} |= "error"`))
+
+	require.Equal(t, []Stats{
+		{
+			Fingerprint:    fingerprint(`{app="foo"} |= "error"`),
+			Query:          `{app=?} |= ?`,
+			Count:          2,
+			BytesProcessed: 200,
+			FirstSeen:      testTime,
+			LastSeen:       testTime,
+		},
+		{
+			Fingerprint:    fingerprint(`sum(rate({app="foo"}[5m]))`),
+			Query:          `sum(rate({app=?}[?]))`,
+			Count:          1,
+			BytesProcessed: 100,
+			FirstSeen:      testTime,
+			LastSeen:       testTime,
+		},
+	}, tracker.Top("1", SortByBytes, 0))
+
+	require.Len(t, tracker.Top("1", SortByCount, 1), 1)
+	require.Equal(t, int64(2), tracker.Top("1", SortByCount, 1)[0].Count)
+	require.Len(t, tracker.Top("2", SortByBytes, 0), 1)
+	require.Empty(t, tracker.Top("3", SortByBytes, 0))
+}
+
+func Test_TrackerBlock(t *testing.T) {
+	tracker := newTestTracker(10)
+	handler := tracker.Middleware().Wrap(testHandler(100))
+
+	query := `{app="foo"} |= "error"`
+	require.NoError(t, tracker.SetRule(context.Background(), "1", Rule{Fingerprint: fingerprint(query), Action: ActionBlock}))
+
+	err := do(t, handler, "1", `{app="bar"} |= "timeout"`)
+	require.ErrorIs(t, err, logqlmodel.ErrBlocked)
+	status, _ := serverutil.ClientHTTPStatusAndError(err)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// Rules only apply to the tenant they were set for.
+	require.NoError(t, do(t, handler, "2", query))
+	require.NoError(t, do(t, handler, "1", `{app="foo"} != "error"`))
+
+	top := tracker.Top("1", SortByBytes, 0)
+	require.Len(t, top, 2)
+	require.Equal(t, fingerprint(query), top[1].Fingerprint)
+	require.Equal(t, int64(1), top[1].Blocked)
+	require.Equal(t, int64(0), top[1].BytesProcessed)
+
+	found, err := tracker.DeleteRule(context.Background(), "1", fingerprint(query))
+	require.NoError(t, err)
+	require.True(t, found)
+	found, err = tracker.DeleteRule(context.Background(), "1", fingerprint(query))
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, do(t, handler, "1", query))
+}
+
+func Test_TrackerThrottleConcurrency(t *testing.T) {
+	tracker := newTestTracker(10)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := tracker.Middleware().Wrap(queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		started <- struct{}{}
+		<-release
+		return &queryrange.LokiResponse{}, nil
+	}))
+
+	query := `{app="foo"} |= "error"`
+	require.NoError(t, tracker.SetRule(context.Background(), "1", Rule{Fingerprint: fingerprint(query), Action: ActionThrottle, MaxConcurrency: 1}))
+
+	done := make(chan error)
+	go func() { done <- do(t, handler, "1", query) }()
+	<-started
+
+	err := do(t, handler, "1", query)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+
+	top := tracker.Top("1", SortByRunning, 1)
+	require.Equal(t, int64(1), top[0].Running)
+	require.Equal(t, int64(1), top[0].Throttled)
+
+	close(release)
+	require.NoError(t, <-done)
+	go func() { <-started }()
+	require.NoError(t, do(t, handler, "1", query))
+	require.Equal(t, int64(0), tracker.Top("1", SortByRunning, 1)[0].Running)
+}
+
+func Test_TrackerThrottleRate(t *testing.T) {
+	tracker := newTestTracker(10)
+	handler := tracker.Middleware().Wrap(testHandler(100))
+
+	query := `{app="foo"} |= "error"`
+	require.NoError(t, tracker.SetRule(context.Background(), "1", Rule{Fingerprint: fingerprint(query), Action: ActionThrottle, MaxQueriesPerMinute: 2}))
+
+	require.NoError(t, do(t, handler, "1", query))
+	require.NoError(t, do(t, handler, "1", query))
+	require.Error(t, do(t, handler, "1", query))
+
+	// The limiter refills at 2 queries per minute.
+	tracker.now = func() time.Time { return testTime.Add(30 * time.Second) }
+	require.NoError(t, do(t, handler, "1", query))
+}
+
+func Test_TrackerRuleExpiry(t *testing.T) {
+	tracker := newTestTracker(10)
+	handler := tracker.Middleware().Wrap(testHandler(100))
+
+	query := `{app="foo"} |= "error"`
+	expiresAt := testTime.Add(time.Minute)
+	require.NoError(t, tracker.SetRule(context.Background(), "1", Rule{Fingerprint: fingerprint(query), Action: ActionBlock, ExpiresAt: &expiresAt}))
+	require.Len(t, tracker.Rules("1"), 1)
+	require.Error(t, do(t, handler, "1", query))
+
+	tracker.now = func() time.Time { return expiresAt }
+	require.NoError(t, do(t, handler, "1", query))
+	require.Empty(t, tracker.Rules("1"))
+}
+
+func Test_TrackerEviction(t *testing.T) {
+	tracker := newTestTracker(2)
+	handler := tracker.Middleware().Wrap(testHandler(100))
+
+	require.NoError(t, do(t, handler, "1", `{app="foo"}`))
+	tracker.now = func() time.Time { return testTime.Add(time.Second) }
+	require.NoError(t, do(t, handler, "1", `{app="foo"} |= "error"`))
+	tracker.now = func() time.Time { return testTime.Add(2 * time.Second) }
+	require.NoError(t, do(t, handler, "1", `{app="foo"} != "error"`))
+
+	var fps []string
+	for _, s := range tracker.Top("1", SortByBytes, 0) {
+		fps = append(fps, s.Fingerprint)
+	}
+	require.ElementsMatch(t, []string{fingerprint(`{app="foo"} |= "error"`), fingerprint(`{app="foo"} != "error"`)}, fps)
+}
+
+func Test_TrackerSharedRules(t *testing.T) {
+	kvClient, closer := consul.NewInMemoryClient(RulesCodec, log.NewNopLogger(), nil)
+	t.Cleanup(func() { closer.Close() })
+
+	// Rules set through one query frontend apply to the queries of the others.
+	a := newTestTrackerWithKV(kvClient, 10)
+	b := newTestTrackerWithKV(kvClient, 10)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b)) })
+	handler := b.Middleware().Wrap(testHandler(100))
+
+	query := `{app="foo"} |= "error"`
+	require.NoError(t, a.SetRule(context.Background(), "1", Rule{Fingerprint: fingerprint(query), Action: ActionBlock}))
+	require.Eventually(t, func() bool {
+		return errors.Is(do(t, handler, "1", query), logqlmodel.ErrBlocked)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A query frontend started later loads the existing rules.
+	c := newTestTrackerWithKV(kvClient, 10)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c)) })
+	require.Len(t, c.Rules("1"), 1)
+
+	found, err := c.DeleteRule(context.Background(), "1", fingerprint(query))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Eventually(t, func() bool {
+		return do(t, handler, "1", query) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, c.Rules("1"))
+}
+
+func Test_TrackerDisabled(t *testing.T) {
+	tracker, err := NewTracker(Config{}, kv.Config{}, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.Nil(t, tracker)
+	require.NoError(t, do(t, tracker.Middleware().Wrap(testHandler(100)), "1", `{app="foo"}`))
+}
+
+func Test_RuleValidate(t *testing.T) {
+	require.NoError(t, Rule{Fingerprint: "a", Action: ActionBlock}.Validate())
+	require.NoError(t, Rule{Fingerprint: "a", Action: ActionThrottle, MaxConcurrency: 1}.Validate())
+	require.Error(t, Rule{Action: ActionBlock}.Validate())
+	require.Error(t, Rule{Fingerprint: "a", Action: "drop"}.Validate())
+	require.Error(t, Rule{Fingerprint: "a", Action: ActionThrottle}.Validate())
+	require.Error(t, Rule{Fingerprint: "a", Action: ActionThrottle, MaxConcurrency: -1, MaxQueriesPerMinute: 1}.Validate())
+}
@@ -2,9 +2,13 @@ package transport
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
 )
 
 func TestFormatRequestHeaders(t *testing.T) {
@@ -21,3 +25,32 @@ func TestFormatRequestHeaders(t *testing.T) {
 
 	require.Equal(t, expected, fields)
 }
+
+func TestCompressionHandler(t *testing.T) {
+	body := strings.Repeat("log line\n", 1024)
+	h := NewCompressionHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+
+	for _, tc := range []struct {
+		accept   string
+		encoding string
+	}{
+		{accept: "application/json", encoding: "gzip"},
+		{accept: loghttp.NDJSONContentType, encoding: ""},
+		{accept: loghttp.ProtobufStreamContentType, encoding: ""},
+	} {
+		t.Run(tc.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range", nil)
+			req.Header.Set("Accept", tc.accept)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+			require.Equal(t, tc.encoding, w.Header().Get("Content-Encoding"))
+			if tc.encoding == "" {
+				require.Equal(t, body, w.Body.String())
+			}
+		})
+	}
+}
@@ -46,6 +46,9 @@ type Limits interface {
 
 	// MaxQueryCapacity returns how much of the available query capacity can be used by this user.
 	MaxQueryCapacity(user string) float64
+
+	// QueryQueueWeight returns the weight of the tenant's queue relative to the queues of other tenants.
+	QueryQueueWeight(user string) int
 }
 
 // Frontend queues HTTP requests, dispatches them to backends, and handles retries
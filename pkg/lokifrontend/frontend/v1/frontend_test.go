@@ -306,3 +306,7 @@ func (l mockLimits) MaxQueriersPerUser(_ string) uint {
 func (l mockLimits) MaxQueryCapacity(_ string) float64 {
 	return l.queryCapacity
 }
+
+func (l mockLimits) QueryQueueWeight(_ string) int {
+	return 1
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange"
 	index_stats "github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/util"
 	"github.com/grafana/loki/v3/pkg/util/httpreq"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	"github.com/grafana/loki/v3/pkg/util/marshal"
@@ -38,6 +39,9 @@ import (
 
 const (
 	wsPingPeriod = 1 * time.Second
+
+	// how often keep-alive comments are sent to tail v2 clients when there are no entries to send
+	sseKeepAlivePeriod = 5 * time.Second
 )
 
 type QueryResponse struct {
@@ -169,7 +173,7 @@ func (q *QuerierAPI) TailHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	tailer, err := q.querier.Tail(r.Context(), req, encodingFlags.Has(httpreq.FlagCategorizeLabels))
+	tailer, err := q.querier.Tail(r.Context(), req, TailOptions{CategorizeLabels: encodingFlags.Has(httpreq.FlagCategorizeLabels)})
 	if err != nil {
 		if err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error())); err != nil {
 			level.Error(logger).Log("msg", "Error connecting to ingesters for tailing", "err", err)
@@ -251,6 +255,23 @@ func (q *QuerierAPI) TailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StreamStatsHandler returns the compression statistics of the tenant's streams held in memory by the ingesters.
+func (q *QuerierAPI) StreamStatsHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := loghttp.ParseStreamStatsQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	resp, err := q.querier.StreamStats(r.Context(), req)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONResponse(w, resp)
+}
+
 // SeriesHandler returns the list of time series that match a certain label set.
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#finding-series-by-label-matchers
 func (q *QuerierAPI) SeriesHandler(ctx context.Context, req *logproto.SeriesRequest) (*logproto.SeriesResponse, stats.Result, error) {
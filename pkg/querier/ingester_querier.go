@@ -2,7 +2,10 @@ package querier
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/distributor/clientpool"
 	"github.com/grafana/loki/v3/pkg/ingester/client"
 	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
@@ -447,6 +451,70 @@ func (q *IngesterQuerier) DetectedLabel(ctx context.Context, req *logproto.Detec
 	return &logproto.LabelToValuesResponse{Labels: mergedResult}, nil
 }
 
+// StreamStats fetches the compression statistics of the tenant's streams from all ingesters.
+// Ingesters are queried over HTTP-over-gRPC. As streams are replicated, the statistics of a stream
+// are taken from the ingester holding the most entries for it.
+func (q *IngesterQuerier) StreamStats(ctx context.Context, req *loghttp.StreamStatsRequest) ([]loghttp.StreamStats, error) {
+	tenantID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("tenant", tenantID)
+	params.Set("limit", strconv.Itoa(req.Limit))
+	params.Set("sort_by", req.SortBy)
+	httpReq := &httpgrpc.HTTPRequest{
+		Method: http.MethodGet,
+		Url:    "/ingester/stream_stats?" + params.Encode(),
+	}
+
+	resps, err := q.forAllIngesters(ctx, func(ctx context.Context, querierClient logproto.QuerierClient) (interface{}, error) {
+		httpClient, ok := querierClient.(httpgrpc.HTTPClient)
+		if !ok {
+			return nil, errors.New("ingester client does not support HTTP requests")
+		}
+
+		resp, err := httpClient.Handle(ctx, httpReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Code != http.StatusOK {
+			return nil, httpgrpc.ErrorFromHTTPResponse(resp)
+		}
+
+		var out loghttp.StreamStatsResponse
+		if err := json.Unmarshal(resp.Body, &out); err != nil {
+			return nil, errors.Wrap(err, "decode stream stats response")
+		}
+		return out.Streams, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type streamKey struct{ tenant, stream string }
+	merged := map[streamKey]loghttp.StreamStats{}
+	for _, resp := range resps {
+		for _, st := range resp.response.([]loghttp.StreamStats) {
+			key := streamKey{st.Tenant, st.Stream}
+			prev, ok := merged[key]
+			st.Ingesters = prev.Ingesters + 1
+			if ok && prev.Entries >= st.Entries {
+				prev.Ingesters = st.Ingesters
+				st = prev
+			}
+			merged[key] = st
+		}
+	}
+
+	streams := make([]loghttp.StreamStats, 0, len(merged))
+	for _, st := range merged {
+		streams = append(streams, st)
+	}
+	return loghttp.TopStreamStats(streams, req.SortBy, req.Limit), nil
+}
+
 func convertMatchersToString(matchers []*labels.Matcher) string {
 	out := strings.Builder{}
 	out.WriteRune('{')
@@ -2,7 +2,9 @@ package querier
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/ring"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
@@ -21,6 +24,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql"
 	"github.com/grafana/loki/v3/pkg/util/constants"
@@ -522,6 +526,41 @@ func TestIngesterQuerier_DetectedLabels(t *testing.T) {
 	})
 }
 
+func TestIngesterQuerier_StreamStats(t *testing.T) {
+	response := func(streams ...loghttp.StreamStats) *httpgrpc.HTTPResponse {
+		body, err := json.Marshal(loghttp.StreamStatsResponse{Streams: streams})
+		require.NoError(t, err)
+		return &httpgrpc.HTTPResponse{Code: http.StatusOK, Body: body}
+	}
+
+	ingesterClient := newQuerierClientMock()
+	ingesterClient.On("Handle", mock.Anything, mock.MatchedBy(func(req *httpgrpc.HTTPRequest) bool {
+		return req.Url == "/ingester/stream_stats?limit=2&sort_by=compression_ratio&tenant=test"
+	}), mock.Anything).Return(response(
+		loghttp.StreamStats{Tenant: "test", Stream: `{foo="bar"}`, Entries: 10, CompressionRatio: 2},
+		loghttp.StreamStats{Tenant: "test", Stream: `{foo="baz"}`, Entries: 10, CompressionRatio: 4},
+	), nil).Once()
+	ingesterClient.On("Handle", mock.Anything, mock.Anything, mock.Anything).Return(response(
+		loghttp.StreamStats{Tenant: "test", Stream: `{foo="bar"}`, Entries: 12, CompressionRatio: 3},
+		loghttp.StreamStats{Tenant: "test", Stream: `{foo="qux"}`, Entries: 1, CompressionRatio: 1},
+	), nil).Once()
+
+	ingesterQuerier, err := newTestIngesterQuerier(newReadRingMock([]ring.InstanceDesc{mockInstanceDesc("1.1.1.1", ring.ACTIVE), mockInstanceDesc("3.3.3.3", ring.ACTIVE)}, 0), ingesterClient)
+	require.NoError(t, err)
+
+	streams, err := ingesterQuerier.StreamStats(user.InjectOrgID(context.Background(), "test"), &loghttp.StreamStatsRequest{
+		Limit:  2,
+		SortBy: loghttp.StreamStatsSortByCompressionRatio,
+	})
+	require.NoError(t, err)
+
+	// the replica with the most entries is kept for streams held by multiple ingesters.
+	require.Equal(t, []loghttp.StreamStats{
+		{Tenant: "test", Stream: `{foo="qux"}`, Entries: 1, CompressionRatio: 1, Ingesters: 1},
+		{Tenant: "test", Stream: `{foo="bar"}`, Entries: 12, CompressionRatio: 3, Ingesters: 2},
+	}, streams)
+}
+
 func newTestIngesterQuerier(readRingMock *readRingMock, ingesterClient *querierClientMock) (*IngesterQuerier, error) {
 	return newIngesterQuerier(
 		mockQuerierConfig(),
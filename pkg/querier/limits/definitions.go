@@ -18,5 +18,6 @@ type Limits interface {
 	QueryTimeout(context.Context, string) time.Duration
 	MaxStreamsMatchersPerQuery(context.Context, string) int
 	MaxConcurrentTailRequests(context.Context, string) int
+	MaxTailBytesPerSecond(context.Context, string) int
 	MaxEntriesLimitPerQuery(context.Context, string) int
 }
@@ -95,6 +95,47 @@ func (q *MultiTenantQuerier) SelectLogs(ctx context.Context, params logql.Select
 	return iter.NewSortEntryIterator(iters, params.Direction), nil
 }
 
+// Tail tails the logs of every tenant of the request and merges them, adding the tenant label to each stream.
+func (q *MultiTenantQuerier) Tail(ctx context.Context, req *logproto.TailRequest, opts TailOptions) (*Tailer, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tenantIDs) == 1 {
+		return q.Querier.Tail(ctx, req, opts)
+	}
+
+	selector, err := syntax.ParseLogSelector(req.Query, true)
+	if err != nil {
+		return nil, err
+	}
+	matchedTenants, filteredMatchers := filterValuesByMatchers(defaultTenantLabel, tenantIDs, selector.Matchers()...)
+	updatedSelector := replaceMatchers(selector, filteredMatchers)
+
+	tailers := make(map[string]*Tailer, len(matchedTenants))
+	for id := range matchedTenants {
+		tenantReq := *req
+		tenantReq.Query = updatedSelector.String()
+		tenantReq.Plan = &plan.QueryPlan{
+			AST: updatedSelector,
+		}
+
+		tailer, err := q.Querier.Tail(user.InjectOrgID(ctx, id), &tenantReq, opts)
+		if err != nil {
+			for _, t := range tailers {
+				if err := t.close(); err != nil {
+					level.Error(q.logger).Log("msg", "failed to close tailer", "err", err)
+				}
+			}
+			return nil, err
+		}
+		tailers[id] = tailer
+	}
+
+	return newMultiTenantTailer(tailers, q.logger), nil
+}
+
 func (q *MultiTenantQuerier) SelectSamples(ctx context.Context, params logql.SelectSampleParams) (iter.SampleIterator, error) {
 	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
@@ -330,6 +371,31 @@ func (q *MultiTenantQuerier) DetectedLabels(ctx context.Context, req *logproto.D
 	}, nil
 }
 
+func (q *MultiTenantQuerier) StreamStats(ctx context.Context, req *loghttp.StreamStatsRequest) (*loghttp.StreamStatsResponse, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tenantIDs) == 1 {
+		return q.Querier.StreamStats(ctx, req)
+	}
+
+	var streams []loghttp.StreamStats
+	for _, id := range tenantIDs {
+		singleContext := user.InjectOrgID(ctx, id)
+		resp, err := q.Querier.StreamStats(singleContext, req)
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, resp.Streams...)
+	}
+
+	return &loghttp.StreamStatsResponse{
+		Streams: loghttp.TopStreamStats(streams, req.SortBy, req.Limit),
+	}, nil
+}
+
 // removeTenantSelector filters the given tenant IDs based on any tenant ID filter the in passed selector.
 func removeTenantSelector(params logql.SelectSampleParams, tenantIDs []string) (map[string]struct{}, syntax.Expr, error) {
 	expr, err := params.Expr()
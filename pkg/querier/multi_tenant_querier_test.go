@@ -345,6 +345,69 @@ func TestMultiTenantQuerier_Label(t *testing.T) {
 	}
 }
 
+func TestMultiTenantQuerier_Tail(t *testing.T) {
+	tailDisconnectedIngesters := func([]string) (map[string]logproto.Querier_TailClient, error) {
+		return map[string]logproto.Querier_TailClient{}, nil
+	}
+	// each tenant tails a single entry whose line is the tenant ID.
+	newTenantTailer := func(ctx context.Context) *Tailer {
+		id, _ := user.ExtractOrgID(ctx)
+		historic := iter.NewStreamIterator(logproto.Stream{
+			Labels:  `{type="test"}`,
+			Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: id}},
+		})
+		return newTailer(0, map[string]logproto.Querier_TailClient{}, historic, tailDisconnectedIngesters, timeout, throttle, TailOptions{}, nil, NewMetrics(nil), log.NewNopLogger())
+	}
+
+	for _, tc := range []struct {
+		desc            string
+		orgID           string
+		query           string
+		expectedStreams []logproto.Stream
+	}{
+		{
+			desc:  "multiple tenants",
+			orgID: "1|2",
+			query: `{type="test"}`,
+			expectedStreams: []logproto.Stream{
+				{Labels: `{__tenant_id__="1", type="test"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "1"}}},
+				{Labels: `{__tenant_id__="2", type="test"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "2"}}},
+			},
+		},
+		{
+			desc:  "multiple tenants filtered by the tenant label",
+			orgID: "1|2",
+			query: `{type="test", __tenant_id__="2"}`,
+			expectedStreams: []logproto.Stream{
+				{Labels: `{__tenant_id__="2", type="test"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "2"}}},
+			},
+		},
+		{
+			desc:  "single tenant",
+			orgID: "1",
+			query: `{type="test"}`,
+			expectedStreams: []logproto.Stream{
+				{Labels: `{type="test"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "1"}}},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			querier := newQuerierMock()
+			querier.On("Tail", mock.Anything, mock.Anything, mock.Anything).Return(newTenantTailer, nil)
+			multiTenantQuerier := NewMultiTenantQuerier(querier, log.NewNopLogger())
+			ctx := user.InjectOrgID(context.Background(), tc.orgID)
+
+			tailer, err := multiTenantQuerier.Tail(ctx, &logproto.TailRequest{Query: tc.query}, TailOptions{})
+			require.NoError(t, err)
+			defer tailer.close()
+
+			responses, err := readFromTailer(tailer, len(tc.expectedStreams))
+			require.NoError(t, err)
+			require.ElementsMatch(t, tc.expectedStreams, flattenStreamsFromResponses(responses))
+		})
+	}
+}
+
 func TestMultiTenantQuerierSeries(t *testing.T) {
 	for _, tc := range []struct {
 		desc           string
@@ -22,6 +22,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/loki/v3/pkg/compactor/deletion"
@@ -102,13 +103,14 @@ type Querier interface {
 	logql.Querier
 	Label(ctx context.Context, req *logproto.LabelRequest) (*logproto.LabelResponse, error)
 	Series(ctx context.Context, req *logproto.SeriesRequest) (*logproto.SeriesResponse, error)
-	Tail(ctx context.Context, req *logproto.TailRequest, categorizedLabels bool) (*Tailer, error)
+	Tail(ctx context.Context, req *logproto.TailRequest, opts TailOptions) (*Tailer, error)
 	IndexStats(ctx context.Context, req *loghttp.RangeQuery) (*stats.Stats, error)
 	IndexShards(ctx context.Context, req *loghttp.RangeQuery, targetBytesPerShard uint64) (*logproto.ShardsResponse, error)
 	Volume(ctx context.Context, req *logproto.VolumeRequest) (*logproto.VolumeResponse, error)
 	DetectedFields(ctx context.Context, req *logproto.DetectedFieldsRequest) (*logproto.DetectedFieldsResponse, error)
 	Patterns(ctx context.Context, req *logproto.QueryPatternsRequest) (*logproto.QueryPatternsResponse, error)
 	DetectedLabels(ctx context.Context, req *logproto.DetectedLabelsRequest) (*logproto.DetectedLabelsResponse, error)
+	StreamStats(ctx context.Context, req *loghttp.StreamStatsRequest) (*loghttp.StreamStatsResponse, error)
 	WithPatternQuerier(patternQuerier PatterQuerier)
 }
 
@@ -461,7 +463,7 @@ func (*SingleTenantQuerier) Check(_ context.Context, _ *grpc_health_v1.HealthChe
 }
 
 // Tail keeps getting matching logs from all ingesters for given query
-func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailRequest, categorizedLabels bool) (*Tailer, error) {
+func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailRequest, opts TailOptions) (*Tailer, error) {
 	err := q.checkTailRequestLimit(ctx)
 	if err != nil {
 		return nil, err
@@ -482,13 +484,19 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 		level.Error(spanlogger.FromContext(ctx)).Log("msg", "failed loading deletes for user", "err", err)
 	}
 
+	// When resuming, the entries following the start of the request are returned instead of the most recent ones.
+	direction := logproto.BACKWARD
+	if opts.Resume {
+		direction = logproto.FORWARD
+	}
+
 	histReq := logql.SelectLogParams{
 		QueryRequest: &logproto.QueryRequest{
 			Selector:  req.Query,
 			Start:     req.Start,
 			End:       time.Now(),
 			Limit:     req.Limit,
-			Direction: logproto.BACKWARD,
+			Direction: direction,
 			Deletes:   deletes,
 			Plan:      req.Plan,
 		},
@@ -515,26 +523,55 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 		return nil, err
 	}
 
-	histIterators, err := q.SelectLogs(queryCtx, histReq)
-	if err != nil {
-		return nil, err
+	var historicEntries iter.EntryIterator
+	if opts.Resume {
+		// The missed entries are fetched page by page as the client consumes them,
+		// each page being bound by the query timeout.
+		historicEntries = newResumedHistoryIterator(histReq.Start, req.Limit, func(start time.Time) ([]logproto.Stream, error) {
+			pageCtx, cancelPage := context.WithDeadline(tailCtx, time.Now().Add(queryTimeout))
+			defer cancelPage()
+
+			pageReq := *histReq.QueryRequest
+			pageReq.Start = start
+			it, err := q.SelectLogs(pageCtx, logql.SelectLogParams{QueryRequest: &pageReq})
+			if err != nil {
+				return nil, err
+			}
+			defer it.Close()
+			resp, _, err := iter.ReadBatch(it, req.Limit)
+			if err != nil {
+				return nil, err
+			}
+			return resp.Streams, nil
+		})
+	} else {
+		histIterators, err := q.SelectLogs(queryCtx, histReq)
+		if err != nil {
+			return nil, err
+		}
+		historicEntries, err = iter.NewReversedIter(histIterators, req.Limit, true)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	reversedIterator, err := iter.NewReversedIter(histIterators, req.Limit, true)
-	if err != nil {
-		return nil, err
+	// Only the tails applying backpressure are rate limited: they wait for the limiter instead of dropping entries.
+	var rateLimiter *rate.Limiter
+	if limit := q.limits.MaxTailBytesPerSecond(ctx, tenantID); limit > 0 && opts.Backpressure {
+		rateLimiter = rate.NewLimiter(rate.Limit(limit), limit)
 	}
 
 	return newTailer(
 		time.Duration(req.DelayFor)*time.Second,
 		tailClients,
-		reversedIterator,
+		historicEntries,
 		func(connectedIngestersAddr []string) (map[string]logproto.Querier_TailClient, error) {
 			return q.ingesterQuerier.TailDisconnectedIngesters(tailCtx, req, connectedIngestersAddr)
 		},
 		q.cfg.TailMaxDuration,
 		tailerWaitEntryThrottle,
-		categorizedLabels,
+		opts,
+		rateLimiter,
 		q.metrics,
 		q.logger,
 	), nil
@@ -1046,6 +1083,19 @@ type PatterQuerier interface {
 	Patterns(ctx context.Context, req *logproto.QueryPatternsRequest) (*logproto.QueryPatternsResponse, error)
 }
 
+// StreamStats returns the compression statistics of the streams held in memory by the ingesters.
+func (q *SingleTenantQuerier) StreamStats(ctx context.Context, req *loghttp.StreamStatsRequest) (*loghttp.StreamStatsResponse, error) {
+	if q.cfg.QueryStoreOnly {
+		return &loghttp.StreamStatsResponse{Streams: []loghttp.StreamStats{}}, nil
+	}
+
+	streams, err := q.ingesterQuerier.StreamStats(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &loghttp.StreamStatsResponse{Streams: streams}, nil
+}
+
 func (q *SingleTenantQuerier) WithPatternQuerier(pq PatterQuerier) {
 	q.patternQuerier = pq
 }
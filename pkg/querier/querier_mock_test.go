@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/loghttp"
 
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/ring"
 	ring_client "github.com/grafana/dskit/ring/client"
 	"github.com/prometheus/client_golang/prometheus"
@@ -134,6 +135,15 @@ func (c *querierClientMock) GetVolume(ctx context.Context, in *logproto.VolumeRe
 	return res.(*logproto.VolumeResponse), args.Error(1)
 }
 
+func (c *querierClientMock) Handle(ctx context.Context, in *httpgrpc.HTTPRequest, opts ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+	args := c.Called(ctx, in, opts)
+	res := args.Get(0)
+	if res == nil {
+		return (*httpgrpc.HTTPResponse)(nil), args.Error(1)
+	}
+	return res.(*httpgrpc.HTTPResponse), args.Error(1)
+}
+
 func (c *querierClientMock) Context() context.Context {
 	return context.Background()
 }
@@ -752,8 +762,9 @@ func (q *querierMock) Series(ctx context.Context, req *logproto.SeriesRequest) (
 	return args.Get(0).(func() *logproto.SeriesResponse)(), args.Error(1)
 }
 
-func (q *querierMock) Tail(_ context.Context, _ *logproto.TailRequest, _ bool) (*Tailer, error) {
-	return nil, errors.New("querierMock.Tail() has not been mocked")
+func (q *querierMock) Tail(ctx context.Context, req *logproto.TailRequest, opts TailOptions) (*Tailer, error) {
+	args := q.Called(ctx, req, opts)
+	return args.Get(0).(func(context.Context) *Tailer)(ctx), args.Error(1)
 }
 
 func (q *querierMock) IndexStats(_ context.Context, _ *loghttp.RangeQuery) (*stats.Stats, error) {
@@ -820,6 +831,18 @@ func (q *querierMock) DetectedLabels(ctx context.Context, req *logproto.Detected
 	return resp.(*logproto.DetectedLabelsResponse), err
 }
 
+func (q *querierMock) StreamStats(ctx context.Context, req *loghttp.StreamStatsRequest) (*loghttp.StreamStatsResponse, error) {
+	args := q.MethodCalled("StreamStats", ctx, req)
+
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp == nil {
+		return nil, err
+	}
+
+	return resp.(*loghttp.StreamStatsResponse), err
+}
+
 func (q *querierMock) WithPatternQuerier(_ PatterQuerier) {}
 
 type engineMock struct {
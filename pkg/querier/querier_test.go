@@ -127,7 +127,7 @@ func TestQuerier_Tail_QueryTimeoutConfigFlag(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := user.InjectOrgID(context.Background(), "test")
-	_, err = q.Tail(ctx, &request, false)
+	_, err = q.Tail(ctx, &request, TailOptions{})
 	require.NoError(t, err)
 
 	calls := ingesterClient.GetMockedCallsByMethod("Query")
@@ -562,7 +562,7 @@ func TestQuerier_concurrentTailLimits(t *testing.T) {
 			require.NoError(t, err)
 
 			ctx := user.InjectOrgID(context.Background(), "test")
-			_, err = q.Tail(ctx, &request, false)
+			_, err = q.Tail(ctx, &request, TailOptions{})
 			assert.Equal(t, testData.expectedError, err)
 		})
 	}
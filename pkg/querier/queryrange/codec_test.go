@@ -2044,6 +2044,32 @@ var (
 			"totalLinesProcessed": 25,
 			"totalStructuredMetadataBytesProcessed": 0,
             "totalPostFilterLines": 0
+		},
+		"usage": {
+			"queueTime": 21,
+			"shardsTime": 0,
+			"indexTime": 0,
+			"chunksDownloadTime": 0,
+			"cacheTime": 0,
+			"execTime": 22,
+			"cpuTime": 0,
+			"peakMemoryBytes": 0,
+			"bytesProcessed": 24,
+			"linesProcessed": 25,
+			"chunksRef": 17,
+			"chunksDownloaded": 18,
+			"shards": 0,
+			"splits": 0,
+			"cacheHitRatio": {
+				"chunk": 0,
+				"index": 0,
+				"result": 0,
+				"statsResult": 0,
+				"volumeResult": 0,
+				"seriesResult": 0,
+				"labelResult": 0,
+				"instantMetricResult": 0
+			}
 		}
 	},`
 	matrixString = `{
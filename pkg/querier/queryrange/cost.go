@@ -0,0 +1,405 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/sharding"
+	"github.com/grafana/loki/v3/pkg/storage/types"
+	"github.com/grafana/loki/v3/pkg/util/spanlogger"
+	"github.com/grafana/loki/v3/pkg/util/validation"
+)
+
+const (
+	// DryRunParam is the query parameter that requests the cost estimate of a query instead of executing it.
+	DryRunParam = "dry_run"
+
+	limErrQueryBudgetExhaustedTmpl = "the query bytes budget of tenant %s is exhausted (query: %s, budget: %s per minute); retry in %s or reduce the amount of data queried"
+)
+
+type dryRunContextKey struct{}
+
+type statsEstimateContextKey struct{}
+
+// statsEstimate is the combined index stats of a request, estimated once by the query cost middleware
+// and reused by the middlewares below it which need the same stats.
+type statsEstimate struct {
+	query      string
+	start, end time.Time
+	step       int64
+	stats      stats.Stats
+}
+
+func injectStatsEstimate(ctx context.Context, r queryrangebase.Request, s stats.Stats) context.Context {
+	return context.WithValue(ctx, statsEstimateContextKey{}, &statsEstimate{
+		query: r.GetQuery(),
+		start: r.GetStart(),
+		end:   r.GetEnd(),
+		step:  r.GetStep(),
+		stats: s,
+	})
+}
+
+// statsEstimateFromContext returns the index stats estimated for the request r, if the request was not
+// changed since the estimation, e.g. by splitting or sharding. Aligning the request to its step moves its
+// range by less than a step and keeps the estimation.
+func statsEstimateFromContext(ctx context.Context, r queryrangebase.Request) (stats.Stats, bool) {
+	e, ok := ctx.Value(statsEstimateContextKey{}).(*statsEstimate)
+	if !ok || e.query != r.GetQuery() {
+		return stats.Stats{}, false
+	}
+	if e.start.Equal(r.GetStart()) && e.end.Equal(r.GetEnd()) {
+		return e.stats, true
+	}
+	if e.step > 0 && r.GetStart().UnixMilli() == e.start.UnixMilli()/e.step*e.step && r.GetEnd().UnixMilli() == e.end.UnixMilli()/e.step*e.step {
+		return e.stats, true
+	}
+	return stats.Stats{}, false
+}
+
+// DryRunHTTPMiddleware marks requests with the dry_run parameter, so that the query frontend
+// returns the estimated cost of the query instead of executing it.
+var DryRunHTTPMiddleware = middleware.Func(func(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.URL.Query().Get(DryRunParam); v != "" {
+			dryRun, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "invalid dry_run parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if dryRun {
+				r = r.WithContext(InjectDryRun(r.Context()))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+})
+
+// InjectDryRun marks the request in ctx as dry run.
+func InjectDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, true)
+}
+
+// IsDryRun returns true if the request in ctx is a dry run.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunContextKey{}).(bool)
+	return dryRun
+}
+
+// QueryCost is the cost of a query estimated from the index stats of its stream selectors.
+type QueryCost struct {
+	Bytes   uint64 `json:"bytes"`
+	Chunks  uint64 `json:"chunks"`
+	Streams uint64 `json:"streams"`
+	Entries uint64 `json:"entries"`
+	Shards  int    `json:"shards"`
+}
+
+// QueryBudget is the state of the query bytes budget of a tenant.
+type QueryBudget struct {
+	Tenant         string `json:"tenant"`
+	BytesPerMinute int    `json:"bytesPerMinute"`
+	Burst          int    `json:"burst"`
+	Available      int    `json:"available"`
+}
+
+// QueryCostResponse is the response of a dry run query.
+type QueryCostResponse struct {
+	Cost     QueryCost     `json:"cost"`
+	Budgets  []QueryBudget `json:"budgets,omitempty"`
+	Admitted bool          `json:"admitted"`
+
+	headers []queryrangebase.PrometheusResponseHeader
+}
+
+var _ queryrangebase.Response = &QueryCostResponse{}
+
+func (r *QueryCostResponse) GetHeaders() []*queryrangebase.PrometheusResponseHeader {
+	return convertPrometheusResponseHeadersToPointers(r.headers)
+}
+
+func (r *QueryCostResponse) WithHeaders(headers []queryrangebase.PrometheusResponseHeader) queryrangebase.Response {
+	r.headers = headers
+	return r
+}
+
+func (r *QueryCostResponse) SetHeader(name, value string) {
+	r.headers = setHeader(r.headers, name, value)
+}
+
+// Implement proto.Message
+func (r *QueryCostResponse) Reset()         {}
+func (r *QueryCostResponse) String() string { return "" }
+func (r *QueryCostResponse) ProtoMessage()  {}
+
+type QueryCostMetrics struct {
+	estimatedBytes prometheus.Histogram
+	rejected       *prometheus.CounterVec
+}
+
+func NewQueryCostMetrics(registerer prometheus.Registerer, metricsNamespace string) *QueryCostMetrics {
+	return &QueryCostMetrics{
+		estimatedBytes: promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_estimated_bytes",
+			Help:      "Estimated number of bytes read by log and metric queries.",
+			Buckets:   prometheus.ExponentialBuckets(1<<20, 4, 12), // 1MB -> 4TB
+		}),
+		rejected: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_budget_rejected_total",
+			Help:      "Total number of queries rejected because the query bytes budget of the tenant was exhausted.",
+		}, []string{"tenant"}),
+	}
+}
+
+// queryBudgets holds the per-tenant token buckets of bytes read by queries.
+// The buckets are held in memory, each query frontend enforces the budgets independently.
+type queryBudgets struct {
+	mtx      sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newQueryBudgets() *queryBudgets {
+	return &queryBudgets{limiters: make(map[string]*rate.Limiter)}
+}
+
+// limiter returns the token bucket of the tenant, updated to the current limits.
+func (b *queryBudgets) limiter(now time.Time, tenantID string, bytesPerMinute, burst int) *rate.Limiter {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	limit := rate.Limit(float64(bytesPerMinute) / time.Minute.Seconds())
+	lim, ok := b.limiters[tenantID]
+	if !ok {
+		lim = rate.NewLimiter(limit, burst)
+		b.limiters[tenantID] = lim
+		return lim
+	}
+	if lim.Limit() != limit {
+		lim.SetLimitAt(now, limit)
+	}
+	if lim.Burst() != burst {
+		lim.SetBurstAt(now, burst)
+	}
+	return lim
+}
+
+type queryCostMiddleware struct {
+	next              queryrangebase.Handler
+	logger            log.Logger
+	statsHandler      queryrangebase.Handler
+	cfg               []config.PeriodConfig
+	maxLookBackPeriod time.Duration
+	shardingEnabled   bool
+	limits            Limits
+	budgets           *queryBudgets
+	metrics           *QueryCostMetrics
+	now               func() time.Time
+}
+
+// newQueryCostMiddleware creates a new Middleware that estimates the cost of log and metric queries
+// from the index stats and the shard factor of the query.
+// The cost of every query is estimated once, recorded in the query log and the metrics, and the index stats
+// are passed down to the query size limiter through the context.
+// It enforces the per-tenant query bytes budgets and answers dry run requests with the estimated cost.
+// The cost can only be estimated for queries on TSDB, other queries are passed through.
+func newQueryCostMiddleware(
+	cfg []config.PeriodConfig,
+	engineOpts logql.EngineOpts,
+	logger log.Logger,
+	limits Limits,
+	shardingEnabled bool,
+	budgets *queryBudgets,
+	metrics *QueryCostMetrics,
+	statsHandler queryrangebase.Handler,
+) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &queryCostMiddleware{
+			next:              next,
+			logger:            logger,
+			statsHandler:      statsHandler,
+			cfg:               cfg,
+			maxLookBackPeriod: engineOpts.MaxLookBackPeriod,
+			shardingEnabled:   shardingEnabled,
+			limits:            limits,
+			budgets:           budgets,
+			metrics:           metrics,
+			now:               time.Now,
+		}
+	})
+}
+
+func (q *queryCostMiddleware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	switch r.(type) {
+	case *LokiRequest, *LokiInstantRequest:
+	default:
+		return q.next.Do(ctx, r)
+	}
+
+	log := spanlogger.FromContext(ctx)
+	dryRun := IsDryRun(ctx)
+
+	schemaCfg, err := getSchemaCfgForRequest(q.cfg, r)
+	if err != nil || schemaCfg.IndexType != types.TSDBType {
+		if dryRun {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "the cost of the query can not be estimated, estimations are only supported for queries on TSDB")
+		}
+		return q.next.Do(ctx, r)
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	combined, err := getStatsForRequest(ctx, q.logger, q.statsHandler, r, q.maxLookBackPeriod)
+	if err != nil {
+		if !dryRun && !q.hasBudget(tenantIDs) {
+			// The estimation is only informative for this query, do not fail it.
+			level.Warn(log).Log("msg", "failed to estimate the cost of the query", "err", err)
+			return q.next.Do(ctx, r)
+		}
+		return nil, httpgrpc.Errorf(http.StatusInternalServerError, "Failed to estimate the cost of the query: %s", err.Error())
+	}
+	ctx = injectStatsEstimate(ctx, r, combined)
+	cost := q.costFromStats(combined, tenantIDs)
+	q.metrics.estimatedBytes.Observe(float64(cost.Bytes))
+	if entry := queryLogEntryFromContext(ctx); entry != nil {
+		entry.CostEstimate = &cost
+	}
+	level.Debug(log).Log("msg", "estimated query cost", "bytes", cost.Bytes, "chunks", cost.Chunks, "streams", cost.Streams, "shards", cost.Shards)
+
+	if dryRun {
+		budgets, admitted := q.budgetsFor(tenantIDs, cost)
+		return &QueryCostResponse{Cost: cost, Budgets: budgets, Admitted: admitted}, nil
+	}
+
+	if err := q.admit(tenantIDs, cost); err != nil {
+		level.Warn(log).Log("msg", "query exceeds budget", "status", "rejected", "limit_name", "QueryBytesBudgetPerMinute", "estimated_bytes", humanize.IBytes(cost.Bytes), "err", err)
+		return nil, err
+	}
+	return q.next.Do(ctx, r)
+}
+
+func (q *queryCostMiddleware) hasBudget(tenantIDs []string) bool {
+	for _, tenantID := range tenantIDs {
+		if perMinute, _ := q.budgetFor(tenantID); perMinute > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *queryCostMiddleware) costFromStats(combined stats.Stats, tenantIDs []string) QueryCost {
+	cost := QueryCost{
+		Bytes:   combined.Bytes,
+		Chunks:  combined.Chunks,
+		Streams: combined.Streams,
+		Entries: combined.Entries,
+		Shards:  1,
+	}
+	if q.shardingEnabled {
+		maxBytesPerShard := validation.SmallestPositiveIntPerTenant(tenantIDs, q.limits.TSDBMaxBytesPerShard)
+		if factor := sharding.GuessShardFactor(combined.Bytes, uint64(maxBytesPerShard), 0); factor > 1 {
+			cost.Shards = factor
+		}
+	}
+	return cost
+}
+
+func (q *queryCostMiddleware) budgetFor(tenantID string) (int, int) {
+	bytesPerMinute := q.limits.QueryBytesBudgetPerMinute(tenantID)
+	burst := q.limits.QueryBytesBudgetBurst(tenantID)
+	if burst <= 0 {
+		burst = bytesPerMinute
+	}
+	return bytesPerMinute, burst
+}
+
+// admit consumes the estimated bytes of the query from the budgets of all tenants of the query.
+// Queries estimated to read more than the burst of a budget consume the full burst.
+// If any budget can not afford the query, no budget is consumed and an error is returned.
+func (q *queryCostMiddleware) admit(tenantIDs []string, cost QueryCost) error {
+	now := q.now()
+	reservations := make([]*rate.Reservation, 0, len(tenantIDs))
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	for _, tenantID := range tenantIDs {
+		bytesPerMinute, burst := q.budgetFor(tenantID)
+		if bytesPerMinute <= 0 {
+			continue
+		}
+
+		lim := q.budgets.limiter(now, tenantID, bytesPerMinute, burst)
+		n := int(min(cost.Bytes, uint64(burst)))
+		r := lim.ReserveN(now, n)
+		if delay := r.DelayFrom(now); !r.OK() || delay > 0 {
+			r.CancelAt(now)
+			cancel()
+			q.metrics.rejected.WithLabelValues(tenantID).Inc()
+			return httpgrpc.Errorf(http.StatusTooManyRequests, limErrQueryBudgetExhaustedTmpl, tenantID, humanize.IBytes(cost.Bytes), humanize.IBytes(uint64(bytesPerMinute)), delay.Round(time.Second))
+		}
+		reservations = append(reservations, r)
+	}
+	return nil
+}
+
+// budgetsFor returns the budgets of the tenants and if the query would be admitted, without consuming the budgets.
+func (q *queryCostMiddleware) budgetsFor(tenantIDs []string, cost QueryCost) ([]QueryBudget, bool) {
+	now := q.now()
+	admitted := true
+	var budgets []QueryBudget
+	for _, tenantID := range tenantIDs {
+		bytesPerMinute, burst := q.budgetFor(tenantID)
+		if bytesPerMinute <= 0 {
+			continue
+		}
+
+		lim := q.budgets.limiter(now, tenantID, bytesPerMinute, burst)
+		available := int(lim.TokensAt(now))
+		if available < int(min(cost.Bytes, uint64(burst))) {
+			admitted = false
+		}
+		budgets = append(budgets, QueryBudget{
+			Tenant:         tenantID,
+			BytesPerMinute: bytesPerMinute,
+			Burst:          burst,
+			Available:      available,
+		})
+	}
+	return budgets, admitted
+}
+
+func writeQueryCostResponseJSON(r *QueryCostResponse, w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		Status string             `json:"status"`
+		Data   *QueryCostResponse `json:"data"`
+	}{
+		Status: "success",
+		Data:   r,
+	})
+}
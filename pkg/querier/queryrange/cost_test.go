@@ -0,0 +1,165 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/storage/stores/index/stats"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+func newTestQueryCostHandler(schemas []config.PeriodConfig, limits Limits, statsBytes uint64) (*queryCostMiddleware, *int, *int) {
+	statsCount, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: statsBytes, Chunks: 10, Streams: 2})
+	queryCount, queryHandler := counter()
+	mw := newQueryCostMiddleware(schemas, testEngineOpts, util_log.Logger, limits, true, newQueryBudgets(), NewQueryCostMetrics(prometheus.NewRegistry(), constants.Loki), statsHandler)
+	h := mw.Wrap(queryHandler).(*queryCostMiddleware)
+	return h, statsCount, queryCount
+}
+
+func testQueryCostRequest() *LokiRequest {
+	return &LokiRequest{
+		Query:     `rate({app="foo"} |= "foo"[1m])`,
+		Limit:     1000,
+		Step:      30000,
+		StartTs:   testTime.Add(-6 * time.Hour),
+		EndTs:     testTime,
+		Direction: logproto.FORWARD,
+		Path:      "/query_range",
+		Plan: &plan.QueryPlan{
+			AST: syntax.MustParseExpr(`rate({app="foo"} |= "foo"[1m])`),
+		},
+	}
+}
+
+func TestQueryCostMiddleware_DryRun(t *testing.T) {
+	limits := fakeLimits{queryBytesBudgetPerMinute: 1000}
+	h, statsCount, queryCount := newTestQueryCostHandler(testSchemasTSDB, limits, 400)
+
+	ctx := InjectDryRun(user.InjectOrgID(context.Background(), "1"))
+	res, err := h.Do(ctx, testQueryCostRequest())
+	require.NoError(t, err)
+	require.Equal(t, 1, *statsCount)
+	require.Equal(t, 0, *queryCount)
+
+	cost := res.(*QueryCostResponse)
+	require.Equal(t, QueryCost{Bytes: 400, Chunks: 10, Streams: 2, Shards: 1}, cost.Cost)
+	require.True(t, cost.Admitted)
+	require.Equal(t, []QueryBudget{{Tenant: "1", BytesPerMinute: 1000, Burst: 1000, Available: 1000}}, cost.Budgets)
+
+	// dry runs don't consume the budget
+	_, err = h.Do(ctx, testQueryCostRequest())
+	require.NoError(t, err)
+	_, err = h.Do(user.InjectOrgID(context.Background(), "1"), testQueryCostRequest())
+	require.NoError(t, err)
+	require.Equal(t, 1, *queryCount)
+}
+
+func TestQueryCostMiddleware_Budget(t *testing.T) {
+	limits := fakeLimits{queryBytesBudgetPerMinute: 600, queryBytesBudgetBurst: 1000}
+	h, _, queryCount := newTestQueryCostHandler(testSchemasTSDB, limits, 400)
+
+	now := time.Unix(0, 0)
+	h.now = func() time.Time { return now }
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	for i := 0; i < 2; i++ {
+		_, err := h.Do(ctx, testQueryCostRequest())
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, *queryCount)
+
+	_, err := h.Do(ctx, testQueryCostRequest())
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+	require.Equal(t, 2, *queryCount)
+
+	// the budget is per tenant
+	_, err = h.Do(user.InjectOrgID(context.Background(), "2"), testQueryCostRequest())
+	require.NoError(t, err)
+	require.Equal(t, 3, *queryCount)
+
+	// the budget refills at 10 bytes per second
+	now = now.Add(20 * time.Second)
+	_, err = h.Do(ctx, testQueryCostRequest())
+	require.NoError(t, err)
+	require.Equal(t, 4, *queryCount)
+}
+
+func TestQueryCostMiddleware_EstimatesEveryQuery(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	limits := fakeLimits{maxQueryBytesRead: 1000}
+
+	// the query size limiter below the middleware reuses the estimated stats.
+	statsCount, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 400, Chunks: 10, Streams: 2})
+	queryCount, queryHandler := counter()
+	h := queryrangebase.MergeMiddlewares(
+		newQueryCostMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, true, newQueryBudgets(), NewQueryCostMetrics(prometheus.NewRegistry(), constants.Loki), statsHandler),
+		NewQuerySizeLimiterMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, statsHandler),
+	).Wrap(queryHandler)
+
+	_, err := h.Do(ctx, testQueryCostRequest())
+	require.NoError(t, err)
+	require.Equal(t, 1, *statsCount)
+	require.Equal(t, 1, *queryCount)
+
+	// a request changed below the middleware is estimated again.
+	req := testQueryCostRequest()
+	ctx = injectStatsEstimate(ctx, req, stats.Stats{Bytes: 2000})
+	req.StartTs = req.StartTs.Add(time.Hour)
+	_, err = NewQuerySizeLimiterMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, statsHandler).Wrap(queryHandler).Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, 2, *statsCount)
+}
+
+func TestQueryCostMiddleware_NoEstimation(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	// the cost of queries not on TSDB can not be estimated
+	h, statsCount, queryCount := newTestQueryCostHandler(testSchemas, fakeLimits{queryBytesBudgetPerMinute: 1}, 400)
+	_, err := h.Do(ctx, testQueryCostRequest())
+	require.NoError(t, err)
+	require.Equal(t, 0, *statsCount)
+	require.Equal(t, 1, *queryCount)
+
+	_, err = h.Do(InjectDryRun(ctx), testQueryCostRequest())
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusBadRequest), resp.Code)
+}
+
+func TestDryRunHTTPMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		dryRun   bool
+		expected int
+	}{
+		{query: "", expected: http.StatusOK},
+		{query: "?dry_run=true", dryRun: true, expected: http.StatusOK},
+		{query: "?dry_run=false", expected: http.StatusOK},
+		{query: "?dry_run=foo", expected: http.StatusBadRequest},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			h := DryRunHTTPMiddleware.Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				require.Equal(t, tc.dryRun, IsDryRun(r.Context()))
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range"+tc.query, nil))
+			require.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
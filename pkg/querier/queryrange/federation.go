@@ -0,0 +1,445 @@
+package queryrange
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/util/spanlogger"
+)
+
+// FederationConfig configures the clusters queried by a federating query frontend.
+type FederationConfig struct {
+	ClusterLabel          string                `yaml:"cluster_label"`
+	LocalCluster          string                `yaml:"local_cluster"`
+	AllowLocalOnlyQueries bool                  `yaml:"allow_local_only_queries"`
+	Remotes               []RemoteClusterConfig `yaml:"remotes" doc:"description=Remote Loki clusters queried together with the local cluster. Federation is enabled when at least one remote cluster is configured."`
+}
+
+// RemoteClusterConfig configures a remote Loki cluster.
+type RemoteClusterConfig struct {
+	Name    string        `yaml:"name" doc:"description=Name of the cluster, set as value of the cluster label on the results of the cluster."`
+	URL     string        `yaml:"url" doc:"description=Base URL of the query frontend of the cluster, for example https://loki.eu-west-1.example.com."`
+	Timeout time.Duration `yaml:"timeout" doc:"description=Timeout of the queries sent to the cluster. When the timeout is exceeded, the query returns the results of the other clusters with a warning. 0 uses the timeout of the query."`
+
+	HTTPClientConfig config.HTTPClientConfig `yaml:"http_client_config,omitempty" doc:"description=The HTTP client configuration of the queries sent to the cluster, such as TLS and authentication."`
+}
+
+// RegisterFlags adds the flags required to configure this flag set.
+func (cfg *FederationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.ClusterLabel, "querier.federation.cluster-label", "cluster", "Label added to the results of federated queries to identify the cluster they come from.")
+	f.StringVar(&cfg.LocalCluster, "querier.federation.local-cluster", "local", "Name of the local cluster, set as value of the cluster label on the results of the local cluster.")
+	f.BoolVar(&cfg.AllowLocalOnlyQueries, "querier.federation.allow-local-only-queries", false, "Answer the queries that can not be split across clusters with the results of the local cluster only, with a warning. When disabled, these queries fail.")
+}
+
+// Validate validates the config.
+func (cfg *FederationConfig) Validate() error {
+	if len(cfg.Remotes) == 0 {
+		return nil
+	}
+	if cfg.ClusterLabel == "" || !model.LabelName(cfg.ClusterLabel).IsValid() {
+		return fmt.Errorf("invalid federation cluster label %q", cfg.ClusterLabel)
+	}
+
+	names := map[string]struct{}{cfg.LocalCluster: {}}
+	for _, remote := range cfg.Remotes {
+		if remote.Name == "" {
+			return errors.New("the name of a remote cluster must not be empty")
+		}
+		if _, ok := names[remote.Name]; ok {
+			return fmt.Errorf("duplicate federated cluster %q", remote.Name)
+		}
+		names[remote.Name] = struct{}{}
+
+		u, err := url.Parse(remote.URL)
+		if err != nil {
+			return errors.Wrapf(err, "invalid URL of remote cluster %q", remote.Name)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid URL of remote cluster %q: scheme and host are required", remote.Name)
+		}
+		if err := remote.HTTPClientConfig.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid HTTP client config of remote cluster %q", remote.Name)
+		}
+	}
+	return nil
+}
+
+type FederationMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	mapper   *logql.MapperMetrics
+}
+
+func NewFederationMetrics(registerer prometheus.Registerer, metricsNamespace string) *FederationMetrics {
+	return &FederationMetrics{
+		requests: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_federated_requests_total",
+			Help:      "Total number of queries sent to federated clusters.",
+		}, []string{"cluster", "status"}),
+		duration: promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_federated_request_duration_seconds",
+			Help:      "Time spent by federated clusters answering queries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"cluster"}),
+		mapper: logql.NewFederationMapperMetrics(registerer),
+	}
+}
+
+// federatedCluster is a cluster queried by the federation middleware.
+type federatedCluster struct {
+	name    string
+	handler queryrangebase.Handler
+	timeout time.Duration
+	local   bool
+	metrics *FederationMetrics
+}
+
+type federationMiddleware struct {
+	cfg      FederationConfig
+	logger   log.Logger
+	next     queryrangebase.Handler
+	clusters []federatedCluster
+	ng       *logql.DownstreamEngine
+	metrics  *FederationMetrics
+}
+
+// NewFederationMiddleware creates a middleware querying the configured remote clusters together with the local one.
+// Each cluster is treated as a shard of the query: the query is mapped by the shard mapper and each downstream
+// query is sent to the cluster of its shard. The results are labeled with the cluster they come from.
+// Remote clusters that fail or time out are left out of the results with a warning.
+func NewFederationMiddleware(
+	cfg FederationConfig,
+	engineOpts logql.EngineOpts,
+	logger log.Logger,
+	limits Limits,
+	metrics *FederationMetrics,
+) (queryrangebase.Middleware, error) {
+	if len(cfg.Remotes) == 0 {
+		return queryrangebase.PassthroughMiddleware, nil
+	}
+
+	remotes := make([]federatedCluster, 0, len(cfg.Remotes))
+	for _, remote := range cfg.Remotes {
+		u, err := url.Parse(remote.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid URL of remote cluster %q", remote.Name)
+		}
+		client, err := config.NewClientFromConfig(remote.HTTPClientConfig, "loki-federation-"+remote.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid HTTP client config of remote cluster %q", remote.Name)
+		}
+		remotes = append(remotes, federatedCluster{
+			name:    remote.Name,
+			handler: newRemoteClusterHandler(u, client),
+			timeout: remote.Timeout,
+			metrics: metrics,
+		})
+	}
+
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		clusters := append([]federatedCluster{{name: cfg.LocalCluster, handler: next, local: true}}, remotes...)
+
+		f := &federationMiddleware{
+			cfg:      cfg,
+			logger:   log.With(logger, "middleware", "Federation"),
+			next:     next,
+			clusters: clusters,
+			metrics:  metrics,
+		}
+		f.ng = logql.NewDownstreamEngine(engineOpts, federatedDownstreamHandler{federation: f, limits: limits}, limits, logger)
+		return f
+	}), nil
+}
+
+func (f *federationMiddleware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	var path string
+	switch r := r.(type) {
+	case *LokiRequest:
+		path = r.GetPath()
+	case *LokiInstantRequest:
+		path = r.GetPath()
+	default:
+		return f.next.Do(ctx, r)
+	}
+
+	// Queries from a federating query frontend are only answered by the local cluster.
+	if httpreq.ExtractHeader(ctx, httpreq.LokiFederatedHeader) != "" {
+		return f.next.Do(ctx, r)
+	}
+
+	// The cluster label is injected into the streams of the cluster responses before they are merged,
+	// so the split below must not stream their entries to the client.
+	ctx = withResponseStream(ctx, nil)
+
+	logger := spanlogger.FromContextWithFallback(ctx, f.logger)
+
+	params, err := ParamsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := logql.NewShardMapper(clusterShards(len(f.clusters)), f.metrics.mapper, nil)
+	noop, _, parsed, err := mapper.Parse(params.GetExpression())
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed mapping AST for federation", "err", err.Error(), "query", r.GetQuery())
+		return nil, err
+	}
+	level.Debug(logger).Log("no-op", noop, "mapped", parsed.String())
+
+	// Queries that can not be split across clusters are answered by the local cluster, if allowed.
+	if noop {
+		if !f.cfg.AllowLocalOnlyQueries {
+			return nil, unfederatedQueryError()
+		}
+		resp, err := f.clusters[0].do(ctx, r, f.cfg.ClusterLabel)
+		if err != nil {
+			return nil, err
+		}
+		addWarnings(resp, unfederatedQueryWarning(f.clusters[0].name))
+		return resp, nil
+	}
+
+	res, err := f.ng.Query(ctx, logql.ParamsWithExpressionOverride{Params: params, ExpressionOverride: parsed}).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resultToResponse(res, params, path)
+}
+
+// federatedDownstreamHandler sends the downstream queries of the federation engine to the cluster of their shard.
+type federatedDownstreamHandler struct {
+	federation *federationMiddleware
+	limits     Limits
+}
+
+func (h federatedDownstreamHandler) Downstreamer(ctx context.Context) logql.Downstreamer {
+	in := DownstreamHandler{limits: h.limits, next: h.federation.next}.Downstreamer(ctx).(*instance)
+	return &federatedInstance{instance: in, federation: h.federation}
+}
+
+type federatedInstance struct {
+	*instance
+	federation *federationMiddleware
+}
+
+func (in *federatedInstance) Downstream(ctx context.Context, queries []logql.DownstreamQuery, acc logql.Accumulator) ([]logqlmodel.Result, error) {
+	f := in.federation
+	return in.For(ctx, queries, acc, func(qry logql.DownstreamQuery) (logqlmodel.Result, error) {
+		req := withoutShards(ParamsToLokiRequest(qry.Params).WithQuery(qry.Params.GetExpression().String()))
+
+		cluster, ok := f.clusterFor(qry.Params.Shards())
+		if !ok {
+			// Parts of queries that can not be split across clusters are answered by the local cluster, if allowed.
+			if !f.cfg.AllowLocalOnlyQueries {
+				return logqlmodel.Result{}, unfederatedQueryError()
+			}
+			res, err := f.clusters[0].result(ctx, req, f.cfg.ClusterLabel)
+			if err != nil {
+				return logqlmodel.Result{}, err
+			}
+			res.Warnings = append(res.Warnings, unfederatedQueryWarning(f.clusters[0].name))
+			return res, nil
+		}
+
+		res, err := cluster.result(ctx, req, f.cfg.ClusterLabel)
+		if err != nil {
+			if cluster.local {
+				return logqlmodel.Result{}, err
+			}
+			level.Warn(util_log.WithContext(ctx, f.logger)).Log("msg", "federated cluster unavailable", "cluster", cluster.name, "err", err)
+			return emptyResult(qry.Params, fmt.Sprintf("cluster %s is unavailable, its results are missing: %s", cluster.name, err)), nil
+		}
+		return res, nil
+	})
+}
+
+// clusterFor returns the cluster of the shard of a downstream query.
+func (f *federationMiddleware) clusterFor(shards []string) (federatedCluster, bool) {
+	parsed, _, err := logql.ParseShards(shards)
+	if err != nil || len(parsed) != 1 || parsed[0].PowerOfTwo == nil || int(parsed[0].PowerOfTwo.Of) != len(f.clusters) {
+		return federatedCluster{}, false
+	}
+	return f.clusters[parsed[0].PowerOfTwo.Shard], true
+}
+
+// clusterShards is the sharding strategy of federated queries, mapping every cluster to exactly one shard
+// whatever the number of clusters: the shards only route the downstream queries and don't split the series.
+type clusterShards int
+
+func (n clusterShards) Shards(_ syntax.Expr) ([]logql.ShardWithChunkRefs, uint64, error) {
+	shards := make([]logql.ShardWithChunkRefs, 0, n)
+	for i := 0; i < int(n); i++ {
+		shards = append(shards, logql.ShardWithChunkRefs{
+			Shard: logql.NewPowerOfTwoShard(index.ShardAnnotation{Shard: uint32(i), Of: uint32(n)}),
+		})
+	}
+	return shards, 0, nil
+}
+
+func (n clusterShards) Resolver() logql.ShardResolver {
+	return logql.ConstantShards(n)
+}
+
+func (c federatedCluster) result(ctx context.Context, req queryrangebase.Request, clusterLabel string) (logqlmodel.Result, error) {
+	resp, err := c.do(ctx, req, clusterLabel)
+	if err != nil {
+		return logqlmodel.Result{}, err
+	}
+	return ResponseToResult(resp)
+}
+
+// do sends req to the cluster and adds the cluster label to the series of the response.
+func (c federatedCluster) do(ctx context.Context, req queryrangebase.Request, clusterLabel string) (queryrangebase.Response, error) {
+	if c.local {
+		resp, err := c.handler.Do(withResponseStream(ctx, nil), req)
+		if err != nil {
+			return nil, err
+		}
+		return resp, injectClusterLabel(resp, clusterLabel, c.name)
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	resp, err := c.handler.Do(ctx, req)
+	c.metrics.duration.WithLabelValues(c.name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.metrics.requests.WithLabelValues(c.name, "error").Inc()
+		return nil, err
+	}
+	c.metrics.requests.WithLabelValues(c.name, "success").Inc()
+	return resp, injectClusterLabel(resp, clusterLabel, c.name)
+}
+
+// remoteClusterHandler sends queries to the query frontend of a remote cluster.
+type remoteClusterHandler struct {
+	url    *url.URL
+	client *http.Client
+}
+
+func newRemoteClusterHandler(u *url.URL, client *http.Client) queryrangebase.Handler {
+	return &remoteClusterHandler{url: u, client: client}
+}
+
+func (h *remoteClusterHandler) Do(ctx context.Context, req queryrangebase.Request) (queryrangebase.Response, error) {
+	httpReq, err := DefaultCodec.EncodeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *h.url
+	u.Path = strings.TrimSuffix(h.url.Path, "/") + httpReq.URL.Path
+	u.RawQuery = httpReq.URL.RawQuery
+	httpReq.URL = &u
+	httpReq.Host = u.Host
+	// RequestURI must not be set on client requests.
+	httpReq.RequestURI = ""
+	httpReq.Header.Set("Accept", ProtobufType)
+	httpReq.Header.Set(httpreq.LokiFederatedHeader, "true")
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return DefaultCodec.DecodeResponse(ctx, resp, req)
+}
+
+// withoutShards returns the downstream request of a federated query, which is sharded by the clusters themselves.
+func withoutShards(req queryrangebase.Request) queryrangebase.Request {
+	switch r := req.(type) {
+	case *LokiRequest:
+		r.Shards = nil
+	case *LokiInstantRequest:
+		r.Shards = nil
+	}
+	return req
+}
+
+// injectClusterLabel sets the cluster label on the series of resp.
+func injectClusterLabel(resp queryrangebase.Response, name, value string) error {
+	switch r := resp.(type) {
+	case *LokiResponse:
+		for i, stream := range r.Data.Result {
+			lbls, err := syntax.ParseLabels(stream.Labels)
+			if err != nil {
+				return err
+			}
+			lbls = labels.NewBuilder(lbls).Set(name, value).Labels()
+			r.Data.Result[i].Labels = lbls.String()
+			r.Data.Result[i].Hash = lbls.Hash()
+		}
+	case *LokiPromResponse:
+		for i, series := range r.Response.Data.Result {
+			lbls := labels.NewBuilder(logproto.FromLabelAdaptersToLabels(series.Labels)).Set(name, value).Labels()
+			r.Response.Data.Result[i].Labels = logproto.FromLabelsToLabelAdapters(lbls)
+		}
+	}
+	return nil
+}
+
+func addWarnings(resp queryrangebase.Response, warnings ...string) {
+	switch r := resp.(type) {
+	case *LokiResponse:
+		r.Warnings = append(r.Warnings, warnings...)
+	case *LokiPromResponse:
+		r.Response.Warnings = append(r.Response.Warnings, warnings...)
+	}
+}
+
+// emptyResult returns the result of a downstream query answered by no cluster.
+func emptyResult(params logql.Params, warning string) logqlmodel.Result {
+	res := logqlmodel.Result{Warnings: []string{warning}}
+	switch {
+	case params.GetExpression() != nil && isLogSelector(params.GetExpression()):
+		res.Data = logqlmodel.Streams{}
+	case logql.GetRangeType(params) == logql.InstantType:
+		res.Data = promql.Vector{}
+	default:
+		res.Data = promql.Matrix{}
+	}
+	return res
+}
+
+func isLogSelector(expr syntax.Expr) bool {
+	_, ok := expr.(syntax.LogSelectorExpr)
+	return ok
+}
+
+func unfederatedQueryError() error {
+	return httpgrpc.Errorf(http.StatusBadRequest, "the query can not be federated across clusters, enable allow_local_only_queries to answer it with the results of the local cluster only")
+}
+
+func unfederatedQueryWarning(localCluster string) string {
+	return fmt.Sprintf("the query can not be federated, only the results of cluster %s are returned", localCluster)
+}
@@ -0,0 +1,382 @@
+package queryrange
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/config"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+// remoteCluster serves queries with handler like the query frontend of a remote cluster.
+func remoteCluster(t *testing.T, handler queryrangebase.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ctx, err := user.ExtractOrgIDFromHTTPRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(httpreq.LokiFederatedHeader) == "" {
+			http.Error(w, "missing federated header", http.StatusBadRequest)
+			return
+		}
+		req, err := DefaultCodec.DecodeRequest(ctx, r, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := handler.Do(ctx, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		httpResp, err := DefaultCodec.EncodeResponse(ctx, r, resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer httpResp.Body.Close()
+		for k, v := range httpResp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(httpResp.StatusCode)
+		_, _ = io.Copy(w, httpResp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func streamsHandler(line string) queryrangebase.Handler {
+	return queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		return &LokiResponse{
+			Status:    loghttp.QueryStatusSuccess,
+			Direction: r.(*LokiRequest).Direction,
+			Limit:     r.(*LokiRequest).Limit,
+			Version:   uint32(loghttp.VersionV1),
+			Data: LokiData{
+				ResultType: loghttp.ResultTypeStream,
+				Result: []logproto.Stream{
+					{Labels: `{app="foo"}`, Entries: []logproto.Entry{{Timestamp: start.Add(time.Second), Line: line}}},
+				},
+			},
+		}, nil
+	})
+}
+
+func vectorHandler(value float64) queryrangebase.Handler {
+	return queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		return &LokiPromResponse{
+			Response: &queryrangebase.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: queryrangebase.PrometheusData{
+					ResultType: loghttp.ResultTypeVector,
+					Result: []queryrangebase.SampleStream{
+						{
+							Labels:  []logproto.LabelAdapter{{Name: "app", Value: "foo"}},
+							Samples: []logproto.LegacySample{{TimestampMs: r.GetEnd().UnixMilli(), Value: value}},
+						},
+					},
+				},
+			},
+		}, nil
+	})
+}
+
+func newTestFederation(t *testing.T, local queryrangebase.Handler, remotes ...RemoteClusterConfig) queryrangebase.Handler {
+	t.Helper()
+	return newTestFederationWithConfig(t, local, FederationConfig{ClusterLabel: "cluster", LocalCluster: "local", Remotes: remotes})
+}
+
+func newTestFederationWithConfig(t *testing.T, local queryrangebase.Handler, cfg FederationConfig) queryrangebase.Handler {
+	t.Helper()
+	require.NoError(t, cfg.Validate())
+
+	mware, err := NewFederationMiddleware(
+		cfg,
+		testEngineOpts,
+		log.NewNopLogger(),
+		fakeLimits{maxSeries: math.MaxInt32, maxQueryParallelism: 1, queryTimeout: time.Minute},
+		NewFederationMetrics(prometheus.NewRegistry(), "loki"),
+	)
+	require.NoError(t, err)
+	return mware.Wrap(local)
+}
+
+func federatedLogRequest() *LokiRequest {
+	req := defaultReq()
+	req.Query = `{app="foo"}`
+	req.Plan = &plan.QueryPlan{AST: syntax.MustParseExpr(req.Query)}
+	return req
+}
+
+func Test_FederationLogQuery(t *testing.T) {
+	remote := remoteCluster(t, streamsHandler("remote"))
+	handler := newTestFederation(t, streamsHandler("local"), RemoteClusterConfig{Name: "eu", URL: remote.URL})
+
+	resp, err := handler.Do(user.InjectOrgID(context.Background(), "1"), federatedLogRequest())
+	require.NoError(t, err)
+
+	lokiResp := resp.(*LokiResponse)
+	require.Empty(t, lokiResp.Warnings)
+	lines := map[string]string{}
+	for _, stream := range lokiResp.Data.Result {
+		for _, e := range stream.Entries {
+			lines[stream.Labels] = e.Line
+		}
+	}
+	require.Equal(t, map[string]string{
+		`{app="foo", cluster="local"}`: "local",
+		`{app="foo", cluster="eu"}`:    "remote",
+	}, lines)
+}
+
+func Test_FederationRemoteUnavailable(t *testing.T) {
+	failing := remoteCluster(t, queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return nil, context.DeadlineExceeded
+	}))
+	slow := remoteCluster(t, queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+		return streamsHandler("slow").Do(ctx, r)
+	}))
+	handler := newTestFederation(t, streamsHandler("local"),
+		RemoteClusterConfig{Name: "eu", URL: failing.URL},
+		RemoteClusterConfig{Name: "us", URL: slow.URL, Timeout: 100 * time.Millisecond},
+	)
+
+	resp, err := handler.Do(user.InjectOrgID(context.Background(), "1"), federatedLogRequest())
+	require.NoError(t, err)
+
+	lokiResp := resp.(*LokiResponse)
+	require.Len(t, lokiResp.Data.Result, 1)
+	require.Equal(t, `{app="foo", cluster="local"}`, lokiResp.Data.Result[0].Labels)
+	require.Len(t, lokiResp.Warnings, 2)
+	require.Contains(t, lokiResp.Warnings[0]+lokiResp.Warnings[1], "cluster eu is unavailable")
+	require.Contains(t, lokiResp.Warnings[0]+lokiResp.Warnings[1], "cluster us is unavailable")
+}
+
+func Test_FederationLocalFailure(t *testing.T) {
+	remote := remoteCluster(t, streamsHandler("remote"))
+	local := queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return nil, context.Canceled
+	})
+	handler := newTestFederation(t, local, RemoteClusterConfig{Name: "eu", URL: remote.URL})
+
+	_, err := handler.Do(user.InjectOrgID(context.Background(), "1"), federatedLogRequest())
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_FederationMetricQuery(t *testing.T) {
+	remote := remoteCluster(t, vectorHandler(2))
+	handler := newTestFederation(t, vectorHandler(1), RemoteClusterConfig{Name: "eu", URL: remote.URL})
+
+	for _, tc := range []struct {
+		query    string
+		expected map[string]float64
+	}{
+		{
+			query: `count_over_time({app="foo"}[1m])`,
+			expected: map[string]float64{
+				`{app="foo", cluster="local"}`: 1,
+				`{app="foo", cluster="eu"}`:    2,
+			},
+		},
+		{
+			query:    `sum by (app) (count_over_time({app="foo"}[1m]))`,
+			expected: map[string]float64{`{app="foo"}`: 3},
+		},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			req := &LokiInstantRequest{
+				Query:  tc.query,
+				Limit:  100,
+				TimeTs: end,
+				Path:   "/loki/api/v1/query",
+				Plan:   &plan.QueryPlan{AST: syntax.MustParseExpr(tc.query)},
+			}
+			resp, err := handler.Do(user.InjectOrgID(context.Background(), "1"), req)
+			require.NoError(t, err)
+
+			values := map[string]float64{}
+			for _, series := range resp.(*LokiPromResponse).Response.Data.Result {
+				values[logproto.FromLabelAdaptersToLabels(series.Labels).String()] = series.Samples[0].Value
+			}
+			require.Equal(t, tc.expected, values)
+		})
+	}
+}
+
+func Test_FederationOddClusterCount(t *testing.T) {
+	eu := remoteCluster(t, vectorHandler(2))
+	ap := remoteCluster(t, vectorHandler(4))
+	handler := newTestFederation(t, vectorHandler(1), RemoteClusterConfig{Name: "eu", URL: eu.URL}, RemoteClusterConfig{Name: "ap", URL: ap.URL})
+
+	for _, tc := range []struct {
+		query    string
+		expected map[string]float64
+	}{
+		{
+			query: `count_over_time({app="foo"}[1m])`,
+			expected: map[string]float64{
+				`{app="foo", cluster="local"}`: 1,
+				`{app="foo", cluster="eu"}`:    2,
+				`{app="foo", cluster="ap"}`:    4,
+			},
+		},
+		{
+			query:    `sum by (app) (count_over_time({app="foo"}[1m]))`,
+			expected: map[string]float64{`{app="foo"}`: 7},
+		},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			req := &LokiInstantRequest{
+				Query:  tc.query,
+				Limit:  100,
+				TimeTs: end,
+				Path:   "/loki/api/v1/query",
+				Plan:   &plan.QueryPlan{AST: syntax.MustParseExpr(tc.query)},
+			}
+			resp, err := handler.Do(user.InjectOrgID(context.Background(), "1"), req)
+			require.NoError(t, err)
+			require.Empty(t, resp.(*LokiPromResponse).Response.Warnings)
+
+			values := map[string]float64{}
+			for _, series := range resp.(*LokiPromResponse).Response.Data.Result {
+				values[logproto.FromLabelAdaptersToLabels(series.Labels).String()] = series.Samples[0].Value
+			}
+			require.Equal(t, tc.expected, values)
+		})
+	}
+}
+
+func Test_FederationUnfederatedQuery(t *testing.T) {
+	remote := remoteCluster(t, queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		t.Fatal("queries that can not be federated must not be sent to remote clusters")
+		return nil, nil
+	}))
+	query := `quantile_over_time(0.99, {app="foo"} | unwrap latency [1m]) by (app)`
+	req := &LokiInstantRequest{
+		Query:  query,
+		Limit:  100,
+		TimeTs: end,
+		Path:   "/loki/api/v1/query",
+		Plan:   &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+	}
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	// by default, the query fails instead of silently returning the results of the local cluster only.
+	handler := newTestFederation(t, vectorHandler(1), RemoteClusterConfig{Name: "eu", URL: remote.URL})
+	_, err := handler.Do(ctx, req)
+	require.Error(t, err)
+	httpResp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusBadRequest), httpResp.Code)
+
+	handler = newTestFederationWithConfig(t, vectorHandler(1), FederationConfig{
+		ClusterLabel:          "cluster",
+		LocalCluster:          "local",
+		AllowLocalOnlyQueries: true,
+		Remotes:               []RemoteClusterConfig{{Name: "eu", URL: remote.URL}},
+	})
+	resp, err := handler.Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, []string{unfederatedQueryWarning("local")}, resp.(*LokiPromResponse).Response.Warnings)
+}
+
+func Test_FederationRemoteHTTPClientConfig(t *testing.T) {
+	remote := remoteCluster(t, streamsHandler("remote"))
+	authenticated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "loki" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		remote.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(authenticated.Close)
+
+	handler := newTestFederation(t, streamsHandler("local"), RemoteClusterConfig{
+		Name: "eu",
+		URL:  authenticated.URL,
+		HTTPClientConfig: config.HTTPClientConfig{
+			BasicAuth: &config.BasicAuth{Username: "loki", Password: "secret"},
+		},
+	})
+
+	resp, err := handler.Do(user.InjectOrgID(context.Background(), "1"), federatedLogRequest())
+	require.NoError(t, err)
+	require.Empty(t, resp.(*LokiResponse).Warnings)
+	require.Len(t, resp.(*LokiResponse).Data.Result, 2)
+}
+
+func Test_FederationFederatedRequest(t *testing.T) {
+	remote := remoteCluster(t, queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		t.Fatal("federated queries must not be federated again")
+		return nil, nil
+	}))
+	handler := newTestFederation(t, streamsHandler("local"), RemoteClusterConfig{Name: "eu", URL: remote.URL})
+
+	ctx := httpreq.InjectHeader(user.InjectOrgID(context.Background(), "1"), httpreq.LokiFederatedHeader, "true")
+	resp, err := handler.Do(ctx, federatedLogRequest())
+	require.NoError(t, err)
+	require.Equal(t, `{app="foo"}`, resp.(*LokiResponse).Data.Result[0].Labels)
+}
+
+func Test_FederationConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		cfg  FederationConfig
+		err  string
+	}{
+		{
+			desc: "disabled",
+			cfg:  FederationConfig{},
+		},
+		{
+			desc: "valid",
+			cfg:  FederationConfig{ClusterLabel: "cluster", LocalCluster: "local", Remotes: []RemoteClusterConfig{{Name: "eu", URL: "https://loki.eu"}}},
+		},
+		{
+			desc: "duplicate cluster",
+			cfg:  FederationConfig{ClusterLabel: "cluster", LocalCluster: "eu", Remotes: []RemoteClusterConfig{{Name: "eu", URL: "https://loki.eu"}}},
+			err:  `duplicate federated cluster "eu"`,
+		},
+		{
+			desc: "invalid url",
+			cfg:  FederationConfig{ClusterLabel: "cluster", LocalCluster: "local", Remotes: []RemoteClusterConfig{{Name: "eu", URL: "loki.eu"}}},
+			err:  `invalid URL of remote cluster "eu"`,
+		},
+		{
+			desc: "invalid label",
+			cfg:  FederationConfig{ClusterLabel: "1cluster", LocalCluster: "local", Remotes: []RemoteClusterConfig{{Name: "eu", URL: "https://loki.eu"}}},
+			err:  `invalid federation cluster label "1cluster"`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
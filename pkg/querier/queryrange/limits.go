@@ -278,27 +278,41 @@ func (q *querySizeLimiter) getBytesReadForRequest(ctx context.Context, r queryra
 	sp, ctx := opentracing.StartSpanFromContext(ctx, "querySizeLimiter.getBytesReadForRequest")
 	defer sp.Finish()
 
-	expr, err := syntax.ParseExpr(r.GetQuery())
+	// reuse the stats estimated by the query cost middleware for the same request.
+	if combinedStats, ok := statsEstimateFromContext(ctx, r); ok {
+		return combinedStats.Bytes, nil
+	}
+
+	combinedStats, err := getStatsForRequest(ctx, q.logger, q.statsHandler, r, q.maxLookBackPeriod)
 	if err != nil {
 		return 0, err
 	}
+	return combinedStats.Bytes, nil
+}
+
+// getStatsForRequest returns the combined index stats of all stream selectors of the query in r.
+func getStatsForRequest(ctx context.Context, logger log.Logger, statsHandler queryrangebase.Handler, r queryrangebase.Request, maxLookBackPeriod time.Duration) (stats.Stats, error) {
+	expr, err := syntax.ParseExpr(r.GetQuery())
+	if err != nil {
+		return stats.Stats{}, err
+	}
 
 	matcherGroups, err := syntax.MatcherGroups(expr)
 	if err != nil {
-		return 0, err
+		return stats.Stats{}, err
 	}
 
 	// TODO: Set concurrency dynamically as in shardResolverForConf?
 	start := time.Now()
 	const maxConcurrentIndexReq = 10
-	matcherStats, err := getStatsForMatchers(ctx, q.logger, q.statsHandler, model.Time(r.GetStart().UnixMilli()), model.Time(r.GetEnd().UnixMilli()), matcherGroups, maxConcurrentIndexReq, q.maxLookBackPeriod)
+	matcherStats, err := getStatsForMatchers(ctx, logger, statsHandler, model.Time(r.GetStart().UnixMilli()), model.Time(r.GetEnd().UnixMilli()), matcherGroups, maxConcurrentIndexReq, maxLookBackPeriod)
 	if err != nil {
-		return 0, err
+		return stats.Stats{}, err
 	}
 
 	combinedStats := stats.MergeStats(matcherStats...)
 
-	level.Debug(q.logger).Log(
+	level.Debug(logger).Log(
 		append(
 			combinedStats.LoggingKeyValues(),
 			"msg", "queried index",
@@ -310,10 +324,16 @@ func (q *querySizeLimiter) getBytesReadForRequest(ctx context.Context, r queryra
 		)...,
 	)
 
-	return combinedStats.Bytes, nil
+	return combinedStats, nil
 }
 
 func (q *querySizeLimiter) getSchemaCfg(r queryrangebase.Request) (config.PeriodConfig, error) {
+	return getSchemaCfgForRequest(q.cfg, r)
+}
+
+// getSchemaCfgForRequest returns the schema config used by the query in r,
+// taking into account the range vector and offset durations of the query.
+func getSchemaCfgForRequest(cfg []config.PeriodConfig, r queryrangebase.Request) (config.PeriodConfig, error) {
 	maxRVDuration, maxOffset, err := maxRangeVectorAndOffsetDurationFromQueryString(r.GetQuery())
 	if err != nil {
 		return config.PeriodConfig{}, errors.New("failed to get range-vector and offset duration: " + err.Error())
@@ -322,7 +342,7 @@ func (q *querySizeLimiter) getSchemaCfg(r queryrangebase.Request) (config.Period
 	adjustedStart := int64(model.Time(r.GetStart().UnixMilli()).Add(-maxRVDuration).Add(-maxOffset))
 	adjustedEnd := int64(model.Time(r.GetEnd().UnixMilli()).Add(-maxOffset))
 
-	return ShardingConfigs(q.cfg).ValidRange(adjustedStart, adjustedEnd)
+	return ShardingConfigs(cfg).ValidRange(adjustedStart, adjustedEnd)
 }
 
 func (q *querySizeLimiter) guessLimitName() string {
@@ -36,6 +36,11 @@ type Limits interface {
 	MaxStatsCacheFreshness(context.Context, string) time.Duration
 	MaxMetadataCacheFreshness(context.Context, string) time.Duration
 	VolumeEnabled(string) bool
+	MaxAsyncQueries(string) int
+	QueryBytesBudgetPerMinute(string) int
+	QueryBytesBudgetBurst(string) int
+	MaxLogResultCacheEntrySize(string) int
+	MaxLogResultCacheBytes(string) int
 
 	ShardAggregations(string) []string
 }
@@ -3,12 +3,14 @@ package queryrange
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/tenant"
 	"github.com/opentracing/opentracing-go"
@@ -19,9 +21,11 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/cache"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/cache/resultscache"
 	"github.com/grafana/loki/v3/pkg/util/constants"
 	"github.com/grafana/loki/v3/pkg/util/httpreq"
 	"github.com/grafana/loki/v3/pkg/util/validation"
@@ -29,8 +33,10 @@ import (
 
 // LogResultCacheMetrics is the metrics wrapper used in log result cache.
 type LogResultCacheMetrics struct {
-	CacheHit  prometheus.Counter
-	CacheMiss prometheus.Counter
+	CacheHit       prometheus.Counter
+	CacheMiss      prometheus.Counter
+	CacheEvictions prometheus.Counter
+	CachedBytes    *prometheus.GaugeVec
 }
 
 // NewLogResultCacheMetrics creates metrics to be used in log result cache.
@@ -44,19 +50,30 @@ func NewLogResultCacheMetrics(registerer prometheus.Registerer) *LogResultCacheM
 			Namespace: constants.Loki,
 			Name:      "query_frontend_log_result_cache_miss_total",
 		}),
+		CacheEvictions: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "query_frontend_log_result_cache_evictions_total",
+			Help:      "Total number of log results evicted from the log results cache to keep tenants within their max log result cache bytes.",
+		}),
+		CachedBytes: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "query_frontend_log_result_cache_bytes",
+			Help:      "Size of the log results of a tenant stored in the log results cache by this query frontend, for the tenants with a max log result cache bytes.",
+		}, []string{"tenant"}),
 	}
 }
 
 // NewLogResultCache creates a new log result cache middleware.
-// Currently it only caches empty filter queries, this is because those are usually easily and freely cacheable.
-// Log hits are difficult to handle because of the limit query parameter and the size of the response.
-// In the future it could be extended to cache non-empty query results.
+// By default it only caches empty filter queries, this is because those are usually easily and freely cacheable.
+// Tenants with a max log result cache entry size also get the log entries of each split interval cached,
+// see doEntries for how the limit query parameter is handled.
 // see https://docs.google.com/document/d/1_mACOpxdWZ5K0cIedaja5gzMbv-m0lUVazqZd2O4mEU/edit
 func NewLogResultCache(logger log.Logger, limits Limits, cache cache.Cache, shouldCache queryrangebase.ShouldCacheFn,
 	transformer UserIDTransformer, metrics *LogResultCacheMetrics) queryrangebase.Middleware {
 	if metrics == nil {
 		metrics = NewLogResultCacheMetrics(nil)
 	}
+	budgets := newLogResultCacheBudgets()
 	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
 		return &logResultCache{
 			next:        next,
@@ -66,6 +83,7 @@ func NewLogResultCache(logger log.Logger, limits Limits, cache cache.Cache, shou
 			shouldCache: shouldCache,
 			transformer: transformer,
 			metrics:     metrics,
+			budgets:     budgets,
 		}
 	})
 }
@@ -78,6 +96,7 @@ type logResultCache struct {
 	transformer UserIDTransformer
 
 	metrics *LogResultCacheMetrics
+	budgets *logResultCacheBudgets
 	logger  log.Logger
 }
 
@@ -124,8 +143,19 @@ func (l *logResultCache) Do(ctx context.Context, req queryrangebase.Request) (qu
 		}
 	}
 
+	pipelineDisabled := httpreq.ExtractHeader(ctx, httpreq.LokiDisablePipelineWrappersHeader) == "true"
+
+	// Log entries are only cached when all tenants allow it, otherwise only empty results are cached.
+	if maxEntrySize := maxLogResultCacheEntrySize(tenantIDs, l.limits.MaxLogResultCacheEntrySize); maxEntrySize > 0 {
+		cacheKey := fmt.Sprintf("logentries:%s:%s:%d:%s:%d:%d", tenant.JoinTenantIDs(transformedTenantIDs), normalizedQuery(lokiReq), lokiReq.Limit, lokiReq.Direction, interval.Nanoseconds(), alignedStart.UnixNano()/(interval.Nanoseconds()))
+		if pipelineDisabled {
+			cacheKey = "pipeline-disabled:" + cacheKey
+		}
+		return l.doEntries(ctx, cacheKey, lokiReq, maxEntrySize)
+	}
+
 	cacheKey := fmt.Sprintf("log:%s:%s:%d:%d", tenant.JoinTenantIDs(transformedTenantIDs), req.GetQuery(), interval.Nanoseconds(), alignedStart.UnixNano()/(interval.Nanoseconds()))
-	if httpreq.ExtractHeader(ctx, httpreq.LokiDisablePipelineWrappersHeader) == "true" {
+	if pipelineDisabled {
 		cacheKey = "pipeline-disabled:" + cacheKey
 	}
 
@@ -364,3 +394,320 @@ func overlap(aFrom, aThrough, bFrom, bThrough time.Time) bool {
 
 	return true
 }
+
+// cachedLogEntries holds all log entries of a query in the time range [start, end).
+type cachedLogEntries struct {
+	start, end int64
+	resp       *LokiResponse
+}
+
+// doEntries serves a log query from the cached log entries of its split interval.
+// The cache holds the time range for which the entries matching the query are complete,
+// so only the parts of the request outside this range are queried.
+// Responses that hit the limit are only complete up to their last entry, which is the range that is cached.
+func (l *logResultCache) doEntries(ctx context.Context, cacheKey string, req *LokiRequest, maxEntrySize int) (queryrangebase.Response, error) {
+	cached, ok := l.fetchEntries(ctx, cacheKey)
+	if !ok {
+		l.metrics.CacheMiss.Inc()
+		level.Debug(l.logger).Log("msg", "cache miss", "key", cacheKey)
+		resp, err := l.next.Do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		lokiRes, ok := resp.(*LokiResponse)
+		if !ok {
+			return nil, fmt.Errorf("unexpected response type %T", resp)
+		}
+		if e, ok := completeEntries(req, lokiRes); ok {
+			l.storeEntries(ctx, cacheKey, e, maxEntrySize)
+		}
+		return resp, nil
+	}
+
+	l.metrics.CacheHit.Inc()
+	start, end := req.StartTs.UnixNano(), req.EndTs.UnixNano()
+
+	// if the query does not overlap the cached range, query it entirely and keep the larger range cached.
+	if start >= cached.end || end <= cached.start {
+		resp, err := l.next.Do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		lokiRes, ok := resp.(*LokiResponse)
+		if !ok {
+			return nil, fmt.Errorf("unexpected response type %T", resp)
+		}
+		if e, ok := completeEntries(req, lokiRes); ok && e.end-e.start > cached.end-cached.start {
+			l.storeEntries(ctx, cacheKey, e, maxEntrySize)
+		}
+		return resp, nil
+	}
+
+	known := entriesInRange(cached.resp, max(start, cached.start), min(end, cached.end))
+	// When the cached part already holds enough entries, the entries of the part that comes
+	// after it in the query direction would be cut by the limit anyway.
+	full := countEntries(known) >= int(req.Limit)
+
+	var (
+		startRequest, endRequest *LokiRequest
+		startResp, endResp       *LokiResponse
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	if start < cached.start && !(full && req.Direction == logproto.BACKWARD) {
+		startRequest = req.WithStartEnd(req.GetStartTs(), time.Unix(0, cached.start)).(*LokiRequest)
+		g.Go(func() error {
+			var err error
+			startResp, err = l.doLokiRequest(gctx, startRequest)
+			return err
+		})
+	}
+	if end > cached.end && !(full && req.Direction == logproto.FORWARD) {
+		endRequest = req.WithStartEnd(time.Unix(0, cached.end), req.GetEndTs()).(*LokiRequest)
+		g.Go(func() error {
+			var err error
+			endResp, err = l.doLokiRequest(gctx, endRequest)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	for _, resp := range []*LokiResponse{startResp, endResp} {
+		if resp != nil && resp.Status != loghttp.QueryStatusSuccess {
+			return resp, nil
+		}
+	}
+
+	// mergeLokiResponse stops collecting entries once the limit is reached,
+	// so the responses are passed in the order of the query direction.
+	responses := make([]queryrangebase.Response, 0, 3)
+	first, last := startResp, endResp
+	if req.Direction == logproto.BACKWARD {
+		first, last = endResp, startResp
+	}
+	if first != nil {
+		responses = append(responses, first)
+	}
+	responses = append(responses, known)
+	if last != nil {
+		responses = append(responses, last)
+	}
+	result := mergeLokiResponse(responses...)
+	result.Direction = req.Direction
+	result.Limit = req.Limit
+	result.Version = uint32(loghttp.GetVersion(req.Path))
+
+	// extend the cached range with the adjacent parts that were queried.
+	updated := cached
+	if startResp != nil {
+		if e, ok := completeEntries(startRequest, startResp); ok && e.end == updated.start {
+			updated = joinEntries(e, updated, req.Direction)
+		}
+	}
+	if endResp != nil {
+		if e, ok := completeEntries(endRequest, endResp); ok && e.start == updated.end {
+			updated = joinEntries(updated, e, req.Direction)
+		}
+	}
+	if updated != cached {
+		l.storeEntries(ctx, cacheKey, updated, maxEntrySize)
+	}
+	return result, nil
+}
+
+func (l *logResultCache) doLokiRequest(ctx context.Context, req *LokiRequest) (*LokiResponse, error) {
+	resp, err := l.next.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	lokiRes, ok := resp.(*LokiResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type %T", resp)
+	}
+	return lokiRes, nil
+}
+
+func (l *logResultCache) fetchEntries(ctx context.Context, cacheKey string) (cachedLogEntries, bool) {
+	_, buff, _, err := l.cache.Fetch(ctx, []string{cache.HashKey(cacheKey)})
+	if err != nil {
+		level.Warn(l.logger).Log("msg", "error fetching cache", "err", err, "cacheKey", cacheKey)
+		return cachedLogEntries{}, false
+	}
+	// evicted entries are overwritten with an empty value.
+	if len(buff) != 1 || len(buff[0]) == 0 {
+		return cachedLogEntries{}, false
+	}
+
+	var extent resultscache.Extent
+	if err := proto.Unmarshal(buff[0], &extent); err != nil {
+		level.Warn(l.logger).Log("msg", "error unmarshalling extent from cache", "err", err)
+		return cachedLogEntries{}, false
+	}
+	var resp LokiResponse
+	if err := types.UnmarshalAny(extent.Response, &resp); err != nil {
+		level.Warn(l.logger).Log("msg", "error unmarshalling response from cache", "err", err)
+		return cachedLogEntries{}, false
+	}
+	if tenantIDs, err := tenant.TenantIDs(ctx); err == nil {
+		l.budgets.touch(tenant.JoinTenantIDs(tenantIDs), cacheKey)
+	}
+	return cachedLogEntries{start: extent.Start, end: extent.End, resp: &resp}, true
+}
+
+func (l *logResultCache) storeEntries(ctx context.Context, cacheKey string, e cachedLogEntries, maxEntrySize int) {
+	anyResp, err := types.MarshalAny(e.resp)
+	if err != nil {
+		level.Warn(l.logger).Log("msg", "error marshalling response", "err", err)
+		return
+	}
+	data, err := proto.Marshal(&resultscache.Extent{Start: e.start, End: e.end, Response: anyResp})
+	if err != nil {
+		level.Warn(l.logger).Log("msg", "error marshalling extent", "err", err)
+		return
+	}
+	if len(data) > maxEntrySize {
+		level.Debug(l.logger).Log("msg", "log results too large to cache", "key", cacheKey, "size", len(data), "max_size", maxEntrySize)
+		return
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return
+	}
+	maxBytes := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, l.limits.MaxLogResultCacheBytes)
+	if maxBytes > 0 && len(data) > maxBytes {
+		level.Debug(l.logger).Log("msg", "log results larger than the log results cache budget of the tenant", "key", cacheKey, "size", len(data), "max_bytes", maxBytes)
+		return
+	}
+	if err := l.cache.Store(ctx, []string{cache.HashKey(cacheKey)}, [][]byte{data}); err != nil {
+		level.Warn(l.logger).Log("msg", "error storing cache", "err", err)
+		return
+	}
+	if maxBytes <= 0 {
+		return
+	}
+
+	// Evict the least recently used log results of the tenant exceeding its budget.
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	evicted, cachedBytes := l.budgets.add(userID, cacheKey, len(data), maxBytes)
+	l.metrics.CachedBytes.WithLabelValues(userID).Set(float64(cachedBytes))
+	if len(evicted) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(evicted))
+	bufs := make([][]byte, 0, len(evicted))
+	for _, key := range evicted {
+		keys = append(keys, cache.HashKey(key))
+		bufs = append(bufs, []byte{})
+	}
+	l.metrics.CacheEvictions.Add(float64(len(evicted)))
+	if err := l.cache.Store(ctx, keys, bufs); err != nil {
+		level.Warn(l.logger).Log("msg", "error evicting log results from cache", "err", err)
+	}
+}
+
+// completeEntries returns the time range of the request for which the response holds all matching
+// log entries, together with these entries. A response that hit the limit only holds all entries up to
+// its last entry in the query direction, excluding the timestamp of that entry since entries sharing it
+// might have been cut.
+func completeEntries(req *LokiRequest, resp *LokiResponse) (cachedLogEntries, bool) {
+	if resp.Status != loghttp.QueryStatusSuccess {
+		return cachedLogEntries{}, false
+	}
+	start, end := req.StartTs.UnixNano(), req.EndTs.UnixNano()
+	if countEntries(resp) >= int(req.Limit) {
+		oldest, newest := entriesBounds(resp)
+		if req.Direction == logproto.FORWARD {
+			end = newest
+		} else {
+			start = oldest + 1
+		}
+	}
+	if start >= end {
+		return cachedLogEntries{}, false
+	}
+
+	complete := entriesInRange(resp, start, end)
+	complete.Statistics = stats.Result{}
+	complete.Warnings = nil
+	return cachedLogEntries{start: start, end: end, resp: complete}, true
+}
+
+// joinEntries joins the cached entries of two adjacent time ranges.
+func joinEntries(a, b cachedLogEntries, direction logproto.Direction) cachedLogEntries {
+	resp := *a.resp
+	resp.Data = LokiData{
+		ResultType: loghttp.ResultTypeStream,
+		Result:     mergeOrderedNonOverlappingStreams([]*LokiResponse{a.resp, b.resp}, math.MaxUint32, direction),
+	}
+	return cachedLogEntries{start: a.start, end: b.end, resp: &resp}
+}
+
+// entriesInRange returns a copy of the response with the entries within [start, end).
+// Unlike extractLokiResponse it does not depend on the order of the entries and drops empty streams.
+func entriesInRange(r *LokiResponse, start, end int64) *LokiResponse {
+	resp := *r
+	resp.Data = LokiData{
+		ResultType: loghttp.ResultTypeStream,
+		Result:     make([]logproto.Stream, 0, len(r.Data.Result)),
+	}
+	for _, stream := range r.Data.Result {
+		entries := make([]logproto.Entry, 0, len(stream.Entries))
+		for _, entry := range stream.Entries {
+			if ts := entry.Timestamp.UnixNano(); ts >= start && ts < end {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) > 0 {
+			resp.Data.Result = append(resp.Data.Result, logproto.Stream{Labels: stream.Labels, Entries: entries, Hash: stream.Hash})
+		}
+	}
+	return &resp
+}
+
+func countEntries(r *LokiResponse) (n int) {
+	for _, stream := range r.Data.Result {
+		n += len(stream.Entries)
+	}
+	return n
+}
+
+// entriesBounds returns the timestamps of the oldest and the newest entry of a non-empty response.
+func entriesBounds(r *LokiResponse) (oldest, newest int64) {
+	oldest, newest = math.MaxInt64, math.MinInt64
+	for _, stream := range r.Data.Result {
+		for _, entry := range stream.Entries {
+			ts := entry.Timestamp.UnixNano()
+			oldest, newest = min(oldest, ts), max(newest, ts)
+		}
+	}
+	return oldest, newest
+}
+
+// normalizedQuery returns the query in its canonical form, so equivalent queries share cache entries.
+func normalizedQuery(req *LokiRequest) string {
+	if req.Plan != nil && req.Plan.AST != nil {
+		return req.Plan.AST.String()
+	}
+	expr, err := syntax.ParseExpr(req.Query)
+	if err != nil {
+		return req.Query
+	}
+	return expr.String()
+}
+
+// maxLogResultCacheEntrySize returns the smallest max log result cache entry size of the tenants,
+// or 0 if any of the tenants doesn't cache log entries.
+func maxLogResultCacheEntrySize(tenantIDs []string, f func(string) int) int {
+	result := 0
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if v <= 0 {
+			return 0
+		}
+		if result == 0 || v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package queryrange
+
+import (
+	"container/list"
+	"sync"
+)
+
+// logResultCacheBudgets accounts the bytes of the log entries stored in the log results cache per tenant,
+// to evict the least recently used entries of the tenants exceeding their budget.
+// The accounting only covers the entries stored by this query frontend.
+type logResultCacheBudgets struct {
+	mtx     sync.Mutex
+	tenants map[string]*tenantCacheBudget
+}
+
+type tenantCacheBudget struct {
+	bytes   int
+	lru     *list.List // of *cachedEntrySize, most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedEntrySize struct {
+	key  string
+	size int
+}
+
+func newLogResultCacheBudgets() *logResultCacheBudgets {
+	return &logResultCacheBudgets{tenants: map[string]*tenantCacheBudget{}}
+}
+
+// add accounts an entry of the given size stored for the tenant, and returns the keys of the least recently
+// used entries to evict to keep the tenant within its budget, together with the bytes accounted for the tenant.
+func (b *logResultCacheBudgets) add(tenantID, key string, size, budget int) ([]string, int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	t, ok := b.tenants[tenantID]
+	if !ok {
+		t = &tenantCacheBudget{lru: list.New(), entries: map[string]*list.Element{}}
+		b.tenants[tenantID] = t
+	}
+	if el, ok := t.entries[key]; ok {
+		e := el.Value.(*cachedEntrySize)
+		t.bytes += size - e.size
+		e.size = size
+		t.lru.MoveToFront(el)
+	} else {
+		t.entries[key] = t.lru.PushFront(&cachedEntrySize{key: key, size: size})
+		t.bytes += size
+	}
+
+	var evicted []string
+	for t.bytes > budget && t.lru.Len() > 1 {
+		e := t.lru.Remove(t.lru.Back()).(*cachedEntrySize)
+		delete(t.entries, e.key)
+		t.bytes -= e.size
+		evicted = append(evicted, e.key)
+	}
+	return evicted, t.bytes
+}
+
+// touch marks an entry of the tenant as recently used.
+func (b *logResultCacheBudgets) touch(tenantID, key string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if t, ok := b.tenants[tenantID]; ok {
+		if el, ok := t.entries[key]; ok {
+			t.lru.MoveToFront(el)
+		}
+	}
+}
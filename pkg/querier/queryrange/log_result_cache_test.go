@@ -772,3 +772,207 @@ func nonEmptyResponse(lokiReq *LokiRequest, start, end time.Time, labels string)
 	}
 	return r
 }
+
+func newEntriesLogResultCache(maxEntrySize int) queryrangebase.Middleware {
+	return NewLogResultCache(
+		log.NewNopLogger(),
+		fakeLimits{
+			splitDuration:              map[string]time.Duration{"foo": time.Minute},
+			maxLogResultCacheEntrySize: maxEntrySize,
+		},
+		cache.NewMockCache(),
+		nil,
+		nil,
+		nil,
+	)
+}
+
+// backwardResponse builds a response from [start, end] with 1s step in backward order.
+func backwardResponse(lokiReq *LokiRequest, start, end time.Time, labels string) *LokiResponse {
+	r := nonEmptyResponse(lokiReq, start, end, labels)
+	entries := r.Data.Result[0].Entries
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return r
+}
+
+func Test_LogResultCacheEntriesPartialOverlap(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "foo")
+	req := func(start, end time.Duration) *LokiRequest {
+		return &LokiRequest{
+			Query:     `{foo="bar"} |= "error"`,
+			StartTs:   time.Unix(0, start.Nanoseconds()),
+			EndTs:     time.Unix(0, end.Nanoseconds()),
+			Limit:     entriesLimit,
+			Direction: logproto.FORWARD,
+		}
+	}
+
+	fake := newFakeResponse([]mockResponse{
+		{
+			RequestResponse: queryrangebase.RequestResponse{
+				Request:  req(60*time.Second, 90*time.Second),
+				Response: nonEmptyResponse(req(60*time.Second, 90*time.Second), time.Unix(61, 0), time.Unix(89, 0), lblFooBar),
+			},
+		},
+		// only the part after the cached range is queried.
+		{
+			RequestResponse: queryrangebase.RequestResponse{
+				Request:  req(90*time.Second, 120*time.Second),
+				Response: nonEmptyResponse(req(90*time.Second, 120*time.Second), time.Unix(90, 0), time.Unix(119, 0), lblFooBar),
+			},
+		},
+	})
+	h := newEntriesLogResultCache(1 << 20).Wrap(fake)
+
+	resp, err := h.Do(ctx, req(60*time.Second, 90*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, nonEmptyResponse(req(60*time.Second, 90*time.Second), time.Unix(61, 0), time.Unix(89, 0), lblFooBar).Data, resp.(*LokiResponse).Data)
+
+	resp, err = h.Do(ctx, req(75*time.Second, 120*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, nonEmptyResponse(req(75*time.Second, 120*time.Second), time.Unix(75, 0), time.Unix(119, 0), lblFooBar).Data, resp.(*LokiResponse).Data)
+
+	// the cached range has been extended to the whole split.
+	resp, err = h.Do(ctx, req(60*time.Second, 120*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, nonEmptyResponse(req(60*time.Second, 120*time.Second), time.Unix(61, 0), time.Unix(119, 0), lblFooBar).Data, resp.(*LokiResponse).Data)
+
+	fake.AssertExpectations(t)
+}
+
+func Test_LogResultCacheEntriesLimit(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "foo")
+	req := func(start, end time.Duration) *LokiRequest {
+		return &LokiRequest{
+			Query:     `{foo="bar"} |= "error"`,
+			StartTs:   time.Unix(0, start.Nanoseconds()),
+			EndTs:     time.Unix(0, end.Nanoseconds()),
+			Limit:     10,
+			Direction: logproto.BACKWARD,
+		}
+	}
+
+	fake := newFakeResponse([]mockResponse{
+		{
+			RequestResponse: queryrangebase.RequestResponse{
+				Request:  req(60*time.Second, 120*time.Second),
+				Response: backwardResponse(req(60*time.Second, 120*time.Second), time.Unix(110, 0), time.Unix(119, 0), lblFooBar),
+			},
+		},
+		// the response hit the limit, so entries at 110s might be missing from the cache.
+		{
+			RequestResponse: queryrangebase.RequestResponse{
+				Request:  req(60*time.Second, 110*time.Second+1),
+				Response: backwardResponse(req(60*time.Second, 110*time.Second+1), time.Unix(101, 0), time.Unix(110, 0), lblFooBar),
+			},
+		},
+	})
+	h := newEntriesLogResultCache(1 << 20).Wrap(fake)
+
+	for i := 0; i < 3; i++ {
+		resp, err := h.Do(ctx, req(60*time.Second, 120*time.Second))
+		require.NoError(t, err)
+		require.Equal(t, backwardResponse(req(60*time.Second, 120*time.Second), time.Unix(110, 0), time.Unix(119, 0), lblFooBar).Data, resp.(*LokiResponse).Data)
+	}
+
+	fake.AssertExpectations(t)
+}
+
+func Test_LogResultCacheEntriesMaxSize(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "foo")
+	req := &LokiRequest{
+		Query:   `{foo="bar"}`,
+		StartTs: time.Unix(60, 0),
+		EndTs:   time.Unix(120, 0),
+		Limit:   entriesLimit,
+	}
+
+	// the response is larger than the max entry size so it is not cached.
+	fake := newFakeResponse([]mockResponse{
+		{
+			RequestResponse: queryrangebase.RequestResponse{
+				Request:  req,
+				Response: nonEmptyResponse(req, time.Unix(61, 0), time.Unix(119, 0), lblFooBar),
+			},
+		},
+		{
+			RequestResponse: queryrangebase.RequestResponse{
+				Request:  req,
+				Response: nonEmptyResponse(req, time.Unix(61, 0), time.Unix(119, 0), lblFooBar),
+			},
+		},
+	})
+	h := newEntriesLogResultCache(100).Wrap(fake)
+
+	for i := 0; i < 2; i++ {
+		_, err := h.Do(ctx, req)
+		require.NoError(t, err)
+	}
+	fake.AssertExpectations(t)
+}
+
+func Test_LogResultCacheEntriesMaxBytes(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "foo")
+	req := func(split int64) *LokiRequest {
+		return &LokiRequest{
+			Query:   `{foo="bar"}`,
+			StartTs: time.Unix(split*60, 0),
+			EndTs:   time.Unix((split+1)*60, 0),
+			Limit:   entriesLimit,
+		}
+	}
+	resp := func(split int64) *LokiResponse {
+		return nonEmptyResponse(req(split), time.Unix(split*60+1, 0), time.Unix(split*60+59, 0), lblFooBar)
+	}
+	newCache := func(maxBytes int, metrics *LogResultCacheMetrics) queryrangebase.Middleware {
+		return NewLogResultCache(
+			log.NewNopLogger(),
+			fakeLimits{
+				splitDuration:              map[string]time.Duration{"foo": time.Minute},
+				maxLogResultCacheEntrySize: 1 << 20,
+				maxLogResultCacheBytes:     maxBytes,
+			},
+			cache.NewMockCache(),
+			nil,
+			nil,
+			metrics,
+		)
+	}
+
+	// measure the size of the log results of a split.
+	metrics := NewLogResultCacheMetrics(prometheus.NewPedanticRegistry())
+	fake := newFakeResponse([]mockResponse{
+		{RequestResponse: queryrangebase.RequestResponse{Request: req(20), Response: resp(20)}},
+	})
+	_, err := newCache(1<<20, metrics).Wrap(fake).Do(ctx, req(20))
+	require.NoError(t, err)
+	size := testutil.ToFloat64(metrics.CachedBytes.WithLabelValues("foo"))
+	require.Greater(t, size, float64(0))
+
+	// the budget only holds the results of two splits, so the least recently used one is evicted.
+	metrics = NewLogResultCacheMetrics(prometheus.NewPedanticRegistry())
+	fake = newFakeResponse([]mockResponse{
+		{RequestResponse: queryrangebase.RequestResponse{Request: req(20), Response: resp(20)}},
+		{RequestResponse: queryrangebase.RequestResponse{Request: req(21), Response: resp(21)}},
+		{RequestResponse: queryrangebase.RequestResponse{Request: req(22), Response: resp(22)}},
+		{RequestResponse: queryrangebase.RequestResponse{Request: req(21), Response: resp(21)}},
+	})
+	h := newCache(int(size*2.5), metrics).Wrap(fake)
+
+	for _, split := range []int64{20, 21, 20, 22} {
+		_, err := h.Do(ctx, req(split))
+		require.NoError(t, err)
+	}
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.CacheEvictions))
+	require.Equal(t, 2*size, testutil.ToFloat64(metrics.CachedBytes.WithLabelValues("foo")))
+
+	// split 20 was used after split 21, so it is still cached while split 21 was evicted.
+	for _, split := range []int64{20, 22, 21} {
+		r, err := h.Do(ctx, req(split))
+		require.NoError(t, err)
+		require.Equal(t, resp(split).Data, r.(*LokiResponse).Data)
+	}
+	fake.AssertExpectations(t)
+}
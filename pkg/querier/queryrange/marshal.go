@@ -329,6 +329,11 @@ func (Codec) QueryRequestUnwrap(ctx context.Context, req *QueryRequest) (queryra
 		ctx = httpreq.InjectActorPath(ctx, actor)
 	}
 
+	// Add priority class
+	if priority, ok := req.Metadata[httpreq.LokiQueryPriorityHeader]; ok {
+		ctx = httpreq.InjectHeader(ctx, httpreq.LokiQueryPriorityHeader, priority)
+	}
+
 	// Add disable wrappers
 	if disableWrappers, ok := req.Metadata[httpreq.LokiDisablePipelineWrappersHeader]; ok {
 		ctx = httpreq.InjectHeader(ctx, httpreq.LokiDisablePipelineWrappersHeader, disableWrappers)
@@ -445,6 +450,12 @@ func (Codec) QueryRequestWrap(ctx context.Context, r queryrangebase.Request) (*Q
 		result.Metadata[httpreq.LokiActorPathHeader] = actor
 	}
 
+	// Add priority class
+	priority := httpreq.ExtractHeader(ctx, httpreq.LokiQueryPriorityHeader)
+	if priority != "" {
+		result.Metadata[httpreq.LokiQueryPriorityHeader] = priority
+	}
+
 	// Keep disable wrappers
 	disableWrappers := httpreq.ExtractHeader(ctx, httpreq.LokiDisablePipelineWrappersHeader)
 	if disableWrappers != "" {
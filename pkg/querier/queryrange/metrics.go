@@ -19,6 +19,8 @@ type Metrics struct {
 	*SplitByMetrics
 	*LogResultCacheMetrics
 	*QueryMetrics
+	*QueryCostMetrics
+	*FederationMetrics
 	*queryrangebase.ResultsCacheMetrics
 }
 
@@ -42,6 +44,8 @@ func NewMetrics(registerer prometheus.Registerer, metricsNamespace string) *Metr
 		SplitByMetrics:              NewSplitByMetrics(registerer),
 		LogResultCacheMetrics:       NewLogResultCacheMetrics(registerer),
 		QueryMetrics:                NewMiddlewareQueryMetrics(registerer, metricsNamespace),
+		QueryCostMetrics:            NewQueryCostMetrics(registerer, metricsNamespace),
+		FederationMetrics:           NewFederationMetrics(registerer, metricsNamespace),
 		ResultsCacheMetrics:         queryrangebase.NewResultsCacheMetrics(registerer),
 	}
 }
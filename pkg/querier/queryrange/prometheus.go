@@ -104,9 +104,9 @@ func (p *LokiPromResponse) marshalVector() ([]byte, error) {
 	return jsonStd.Marshal(struct {
 		Status string `json:"status"`
 		Data   struct {
-			ResultType string         `json:"resultType"`
-			Result     loghttp.Vector `json:"result"`
-			Statistics stats.Result   `json:"stats,omitempty"`
+			ResultType string                `json:"resultType"`
+			Result     loghttp.Vector        `json:"result"`
+			Statistics stats.ResultWithUsage `json:"stats,omitempty"`
 		} `json:"data,omitempty"`
 		ErrorType string   `json:"errorType,omitempty"`
 		Error     string   `json:"error,omitempty"`
@@ -114,13 +114,13 @@ func (p *LokiPromResponse) marshalVector() ([]byte, error) {
 	}{
 		Error: p.Response.Error,
 		Data: struct {
-			ResultType string         `json:"resultType"`
-			Result     loghttp.Vector `json:"result"`
-			Statistics stats.Result   `json:"stats,omitempty"`
+			ResultType string                `json:"resultType"`
+			Result     loghttp.Vector        `json:"result"`
+			Statistics stats.ResultWithUsage `json:"stats,omitempty"`
 		}{
 			ResultType: loghttp.ResultTypeVector,
 			Result:     vec,
-			Statistics: p.Statistics,
+			Statistics: p.Statistics.WithUsage(),
 		},
 		ErrorType: p.Response.ErrorType,
 		Status:    p.Response.Status,
@@ -140,7 +140,7 @@ func (p *LokiPromResponse) marshalMatrix() ([]byte, error) {
 		Status string `json:"status"`
 		Data   struct {
 			queryrangebase.PrometheusData
-			Statistics stats.Result `json:"stats,omitempty"`
+			Statistics stats.ResultWithUsage `json:"stats,omitempty"`
 		} `json:"data,omitempty"`
 		ErrorType string   `json:"errorType,omitempty"`
 		Error     string   `json:"error,omitempty"`
@@ -149,10 +149,10 @@ func (p *LokiPromResponse) marshalMatrix() ([]byte, error) {
 		Error: p.Response.Error,
 		Data: struct {
 			queryrangebase.PrometheusData
-			Statistics stats.Result `json:"stats,omitempty"`
+			Statistics stats.ResultWithUsage `json:"stats,omitempty"`
 		}{
 			PrometheusData: p.Response.Data,
-			Statistics:     p.Statistics,
+			Statistics:     p.Statistics.WithUsage(),
 		},
 		ErrorType: p.Response.ErrorType,
 		Status:    p.Response.Status,
@@ -178,9 +178,9 @@ func (p *LokiPromResponse) marshalScalar() ([]byte, error) {
 	return jsonStd.Marshal(struct {
 		Status string `json:"status"`
 		Data   struct {
-			ResultType string         `json:"resultType"`
-			Result     loghttp.Scalar `json:"result"`
-			Statistics stats.Result   `json:"stats,omitempty"`
+			ResultType string                `json:"resultType"`
+			Result     loghttp.Scalar        `json:"result"`
+			Statistics stats.ResultWithUsage `json:"stats,omitempty"`
 		} `json:"data,omitempty"`
 		ErrorType string   `json:"errorType,omitempty"`
 		Error     string   `json:"error,omitempty"`
@@ -188,13 +188,13 @@ func (p *LokiPromResponse) marshalScalar() ([]byte, error) {
 	}{
 		Error: p.Response.Error,
 		Data: struct {
-			ResultType string         `json:"resultType"`
-			Result     loghttp.Scalar `json:"result"`
-			Statistics stats.Result   `json:"stats,omitempty"`
+			ResultType string                `json:"resultType"`
+			Result     loghttp.Scalar        `json:"result"`
+			Statistics stats.ResultWithUsage `json:"stats,omitempty"`
 		}{
 			ResultType: loghttp.ResultTypeScalar,
 			Result:     scalar,
-			Statistics: p.Statistics,
+			Statistics: p.Statistics.WithUsage(),
 		},
 		ErrorType: p.Response.ErrorType,
 		Status:    p.Response.Status,
@@ -0,0 +1,292 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/pattern/aggregation"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// queryLogServiceName is the service name of the streams the query log is pushed to.
+const queryLogServiceName = "loki-query-log"
+
+// QueryLogConfig configures the query log written by the query frontend.
+type QueryLogConfig struct {
+	Enabled          bool                    `yaml:"enabled"`
+	Tenant           string                  `yaml:"tenant"`
+	LokiAddr         string                  `yaml:"loki_address" doc:"description=The address of the Loki instance to push the query log to. If empty, the query log is only written to the logs of the query frontend."`
+	WriteTimeout     time.Duration           `yaml:"timeout"`
+	PushPeriod       time.Duration           `yaml:"push_period"`
+	HTTPClientConfig config.HTTPClientConfig `yaml:"http_client_config,omitempty" doc:"description=The HTTP client configuration for pushing the query log to Loki."`
+	UseTLS           bool                    `yaml:"use_tls"`
+	BasicAuth        aggregation.BasicAuth   `yaml:"basic_auth,omitempty" doc:"description=The basic auth configuration for pushing the query log to Loki."`
+	BackoffConfig    backoff.Config          `yaml:"backoff_config,omitempty" doc:"description=The backoff configuration for pushing the query log to Loki."`
+}
+
+// RegisterFlags adds the flags required to configure this flag set.
+func (cfg *QueryLogConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "querier.query-log.enabled", false, "Write an entry to the query log for each log and metric query completed by the query frontend.")
+	f.StringVar(&cfg.Tenant, "querier.query-log.tenant", "", "Tenant the query log is pushed to. If empty, the entry of a query is pushed to the tenants of the query.")
+	f.StringVar(&cfg.LokiAddr, "querier.query-log.loki-address", "", "Loki address to push the query log to.")
+	f.DurationVar(&cfg.WriteTimeout, "querier.query-log.timeout", 10*time.Second, "Timeout for pushing the query log to Loki.")
+	f.DurationVar(&cfg.PushPeriod, "querier.query-log.push-period", 30*time.Second, "How long to wait in between pushes of the query log to Loki.")
+	f.BoolVar(&cfg.UseTLS, "querier.query-log.tls", false, "Does the Loki connection use TLS?")
+	cfg.BasicAuth.RegisterFlagsWithPrefix("querier.query-log.", f)
+	cfg.BackoffConfig.RegisterFlagsWithPrefix("querier.query-log", f)
+}
+
+// QueryLogEntry is the entry of the query log for a completed query.
+type QueryLogEntry struct {
+	Tenant      string  `json:"tenant"`
+	QueryHash   string  `json:"queryHash"`
+	Fingerprint string  `json:"fingerprint"`
+	Query       string  `json:"query"`
+	QueryType   string  `json:"queryType"`
+	RangeType   string  `json:"rangeType"`
+	Source      string  `json:"source,omitempty"`
+	Status      string  `json:"status"`
+	Length      float64 `json:"length"`
+	Duration    float64 `json:"duration"`
+
+	Usage stats.Usage `json:"usage"`
+	// CostEstimate is set when the cost of the query was estimated before its execution.
+	CostEstimate *QueryCost `json:"costEstimate,omitempty"`
+}
+
+type queryLogContextKey struct{}
+
+// queryLogEntryFromContext returns the query log entry of the query in ctx, or nil if the query isn't logged.
+func queryLogEntryFromContext(ctx context.Context) *QueryLogEntry {
+	e, _ := ctx.Value(queryLogContextKey{}).(*QueryLogEntry)
+	return e
+}
+
+// QueryLog writes the query log to the logs of the query frontend and optionally pushes it to Loki.
+type QueryLog struct {
+	cfg    QueryLogConfig
+	logger log.Logger
+
+	// newWriter creates the writer pushing the query log to the tenant.
+	newWriter func(tenantID string) (aggregation.EntryWriter, error)
+
+	mtx     sync.Mutex
+	writers map[string]aggregation.EntryWriter
+}
+
+// NewQueryLog creates the query log. It returns nil when the query log is disabled.
+func NewQueryLog(cfg QueryLogConfig, logger log.Logger) *QueryLog {
+	if !cfg.Enabled {
+		return nil
+	}
+	q := &QueryLog{
+		cfg:     cfg,
+		logger:  logger,
+		writers: make(map[string]aggregation.EntryWriter),
+	}
+	if cfg.LokiAddr != "" {
+		q.newWriter = func(tenantID string) (aggregation.EntryWriter, error) {
+			return aggregation.NewPush(
+				cfg.LokiAddr,
+				tenantID,
+				cfg.WriteTimeout,
+				cfg.PushPeriod,
+				cfg.HTTPClientConfig,
+				cfg.BasicAuth.Username,
+				string(cfg.BasicAuth.Password),
+				cfg.UseTLS,
+				&cfg.BackoffConfig,
+				logger,
+			)
+		}
+	}
+	return q
+}
+
+// Middleware returns the middleware writing an entry to the query log for each completed log and metric query.
+func (q *QueryLog) Middleware() queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		if q == nil {
+			return next
+		}
+		return queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+			switch r.(type) {
+			case *LokiRequest, *LokiInstantRequest:
+			default:
+				return next.Do(ctx, r)
+			}
+			if IsDryRun(ctx) {
+				return next.Do(ctx, r)
+			}
+
+			start := time.Now()
+			entry := &QueryLogEntry{}
+			resp, err := next.Do(context.WithValue(ctx, queryLogContextKey{}, entry), r)
+			q.complete(ctx, entry, r, resp, err, time.Since(start))
+			return resp, err
+		})
+	})
+}
+
+func (q *QueryLog) complete(ctx context.Context, entry *QueryLogEntry, r queryrangebase.Request, resp queryrangebase.Response, err error, duration time.Duration) {
+	params, perr := ParamsFromRequest(r)
+	if perr != nil {
+		level.Warn(q.logger).Log("msg", "failed to write query log entry", "err", perr)
+		return
+	}
+	tenantIDs, terr := tenant.TenantIDs(ctx)
+	if terr != nil {
+		level.Warn(q.logger).Log("msg", "failed to write query log entry", "err", terr)
+		return
+	}
+
+	status, _ := serverutil.ClientHTTPStatusAndError(err)
+	queryType, _ := logql.QueryType(params.GetExpression())
+
+	entry.Tenant = tenant.JoinTenantIDs(tenantIDs)
+	entry.QueryHash = strconv.FormatUint(uint64(util.HashedQuery(params.QueryString())), 10)
+	entry.Fingerprint = syntax.Fingerprint(params.GetExpression())
+	entry.Query = params.QueryString()
+	entry.QueryType = queryType
+	entry.RangeType = string(logql.GetRangeType(params))
+	entry.Source = querySource(httpreq.ExtractQueryTagsFromContext(ctx))
+	entry.Status = strconv.Itoa(status)
+	entry.Length = params.End().Sub(params.Start()).Seconds()
+	entry.Duration = duration.Seconds()
+	if statistics := responseStatistics(resp); statistics != nil {
+		entry.Usage = statistics.Usage()
+	}
+
+	q.write(tenantIDs, entry)
+}
+
+func (q *QueryLog) write(tenantIDs []string, entry *QueryLogEntry) {
+	level.Info(q.logger).Log(append([]any{"msg", "query log"}, entry.keyvals()...)...)
+
+	if q.newWriter == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		level.Warn(q.logger).Log("msg", "failed to encode query log entry", "err", err)
+		return
+	}
+
+	lbls := labels.FromStrings("service_name", queryLogServiceName)
+	if q.cfg.Tenant != "" {
+		lbls = labels.FromStrings("service_name", queryLogServiceName, "tenant", entry.Tenant)
+		tenantIDs = []string{q.cfg.Tenant}
+	}
+	now := time.Now()
+	for _, tenantID := range tenantIDs {
+		w, err := q.writer(tenantID)
+		if err != nil {
+			level.Warn(q.logger).Log("msg", "failed to push query log entry", "tenant", tenantID, "err", err)
+			continue
+		}
+		w.WriteEntry(now, string(line), lbls)
+	}
+}
+
+func (q *QueryLog) writer(tenantID string) (aggregation.EntryWriter, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if w, ok := q.writers[tenantID]; ok {
+		return w, nil
+	}
+	w, err := q.newWriter(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	q.writers[tenantID] = w
+	return w, nil
+}
+
+// Stop stops pushing the query log to Loki.
+func (q *QueryLog) Stop() {
+	if q == nil {
+		return
+	}
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	for _, w := range q.writers {
+		w.Stop()
+	}
+}
+
+func (e *QueryLogEntry) keyvals() []any {
+	kvs := []any{
+		"tenant", e.Tenant,
+		"query_hash", e.QueryHash,
+		"fingerprint", e.Fingerprint,
+		"query", e.Query,
+		"query_type", e.QueryType,
+		"range_type", e.RangeType,
+		"source", e.Source,
+		"status", e.Status,
+		"length", stats.ConvertSecondsToNanoseconds(e.Length),
+		"duration", stats.ConvertSecondsToNanoseconds(e.Duration),
+		"queue_time", stats.ConvertSecondsToNanoseconds(e.Usage.QueueTime),
+		"shards_time", stats.ConvertSecondsToNanoseconds(e.Usage.ShardsTime),
+		"index_time", stats.ConvertSecondsToNanoseconds(e.Usage.IndexTime),
+		"chunks_download_time", stats.ConvertSecondsToNanoseconds(e.Usage.ChunksDownloadTime),
+		"cache_time", stats.ConvertSecondsToNanoseconds(e.Usage.CacheTime),
+		"exec_time", stats.ConvertSecondsToNanoseconds(e.Usage.ExecTime),
+		"bytes_processed", e.Usage.BytesProcessed,
+		"lines_processed", e.Usage.LinesProcessed,
+		"chunks_ref", e.Usage.ChunksRef,
+		"chunks_downloaded", e.Usage.ChunksDownloaded,
+		"shards", e.Usage.Shards,
+		"splits", e.Usage.Splits,
+		"chunk_cache_hit_ratio", fmt.Sprintf("%.2f", e.Usage.CacheHitRatio.Chunk),
+		"index_cache_hit_ratio", fmt.Sprintf("%.2f", e.Usage.CacheHitRatio.Index),
+		"result_cache_hit_ratio", fmt.Sprintf("%.2f", e.Usage.CacheHitRatio.Result),
+		"instant_metric_cache_hit_ratio", fmt.Sprintf("%.2f", e.Usage.CacheHitRatio.InstantMetricResult),
+	}
+	if e.CostEstimate != nil {
+		kvs = append(kvs, "estimated_bytes", e.CostEstimate.Bytes, "estimated_shards", e.CostEstimate.Shards)
+	}
+	return kvs
+}
+
+func responseStatistics(resp queryrangebase.Response) *stats.Result {
+	switch r := resp.(type) {
+	case *LokiResponse:
+		return &r.Statistics
+	case *LokiPromResponse:
+		return &r.Statistics
+	default:
+		return nil
+	}
+}
+
+// querySource returns the source of the query from its query tags, e.g: `Source=grafana,Feature=beta` -> `grafana`.
+func querySource(queryTags string) string {
+	for _, tag := range strings.Split(queryTags, ",") {
+		k, v, ok := strings.Cut(tag, "=")
+		if ok && strings.EqualFold(k, "source") {
+			return v
+		}
+	}
+	return ""
+}
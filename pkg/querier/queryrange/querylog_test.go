@@ -0,0 +1,172 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/pattern/aggregation"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+type pushedEntry struct {
+	tenant string
+	line   string
+	labels labels.Labels
+}
+
+type fakeQueryLogWriter struct {
+	tenant string
+	mtx    *sync.Mutex
+	pushed *[]pushedEntry
+}
+
+func (w fakeQueryLogWriter) WriteEntry(_ time.Time, line string, lbls labels.Labels) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	*w.pushed = append(*w.pushed, pushedEntry{tenant: w.tenant, line: line, labels: lbls})
+}
+
+func (w fakeQueryLogWriter) Stop() {}
+
+func newTestQueryLog(cfg QueryLogConfig) (*QueryLog, *[]pushedEntry) {
+	cfg.Enabled = true
+	q := NewQueryLog(cfg, log.NewNopLogger())
+
+	var mtx sync.Mutex
+	pushed := &[]pushedEntry{}
+	q.newWriter = func(tenantID string) (aggregation.EntryWriter, error) {
+		return fakeQueryLogWriter{tenant: tenantID, mtx: &mtx, pushed: pushed}, nil
+	}
+	return q, pushed
+}
+
+func queryLogRequest(query string) *LokiRequest {
+	return &LokiRequest{
+		Query:   query,
+		Limit:   100,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+		Step:    60000,
+		Path:    "/loki/api/v1/query_range",
+		Plan:    &plan.QueryPlan{AST: syntax.MustParseExpr(query)},
+	}
+}
+
+func Test_QueryLog(t *testing.T) {
+	q, pushed := newTestQueryLog(QueryLogConfig{})
+
+	handler := q.Middleware().Wrap(queryrangebase.HandlerFunc(func(ctx context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		queryLogEntryFromContext(ctx).CostEstimate = &QueryCost{Bytes: 2048, Shards: 2}
+		return &LokiPromResponse{
+			Response: &queryrangebase.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data:   queryrangebase.PrometheusData{ResultType: loghttp.ResultTypeMatrix},
+			},
+			Statistics: stats.Result{
+				Summary: stats.Summary{QueueTime: 1, ExecTime: 2, TotalBytesProcessed: 1024, Shards: 2},
+				Caches:  stats.Caches{Result: stats.Cache{EntriesFound: 1, EntriesRequested: 2}},
+			},
+		}, nil
+	}))
+
+	ctx := httpreq.InjectQueryTags(user.InjectOrgID(context.Background(), "1"), "Source=grafana,Feature=beta")
+	_, err := handler.Do(ctx, queryLogRequest(`sum(rate({app="foo"}[1m]))`))
+	require.NoError(t, err)
+
+	require.Len(t, *pushed, 1)
+	require.Equal(t, "1", (*pushed)[0].tenant)
+	require.Equal(t, labels.FromStrings("service_name", queryLogServiceName), (*pushed)[0].labels)
+
+	var entry QueryLogEntry
+	require.NoError(t, json.Unmarshal([]byte((*pushed)[0].line), &entry))
+	require.Greater(t, entry.Duration, 0.0)
+	entry.Duration = 0
+	require.Equal(t, QueryLogEntry{
+		Tenant:      "1",
+		QueryHash:   "977982084",
+		Fingerprint: syntax.Fingerprint(syntax.MustParseExpr(`sum(rate({app="bar"}[5m]))`)),
+		Query:       `sum(rate({app="foo"}[1m]))`,
+		QueryType:   "metric",
+		RangeType:   "range",
+		Source:      "grafana",
+		Status:      "200",
+		Length:      3600,
+		Usage: stats.Usage{
+			QueueTime:      1,
+			ExecTime:       2,
+			BytesProcessed: 1024,
+			Shards:         2,
+			CacheHitRatio:  stats.CacheHitRatio{Result: 0.5},
+		},
+		CostEstimate: &QueryCost{Bytes: 2048, Shards: 2},
+	}, entry)
+}
+
+func Test_QueryLogTenant(t *testing.T) {
+	q, pushed := newTestQueryLog(QueryLogConfig{Tenant: "ops"})
+
+	handler := q.Middleware().Wrap(queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "bad query")
+	}))
+
+	_, err := handler.Do(user.InjectOrgID(context.Background(), "a|b"), queryLogRequest(`{app="foo"} |= "error"`))
+	require.Error(t, err)
+
+	require.Len(t, *pushed, 1)
+	require.Equal(t, "ops", (*pushed)[0].tenant)
+	require.Equal(t, labels.FromStrings("service_name", queryLogServiceName, "tenant", "a|b"), (*pushed)[0].labels)
+
+	var entry QueryLogEntry
+	require.NoError(t, json.Unmarshal([]byte((*pushed)[0].line), &entry))
+	require.Equal(t, "400", entry.Status)
+	require.Equal(t, "filter", entry.QueryType)
+	require.Nil(t, entry.CostEstimate)
+}
+
+func Test_QueryLogPerTenant(t *testing.T) {
+	q, pushed := newTestQueryLog(QueryLogConfig{})
+
+	handler := q.Middleware().Wrap(queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return &LokiResponse{Status: loghttp.QueryStatusSuccess}, nil
+	}))
+
+	_, err := handler.Do(user.InjectOrgID(context.Background(), "a|b"), queryLogRequest(`{app="foo"}`))
+	require.NoError(t, err)
+
+	require.Len(t, *pushed, 2)
+	require.ElementsMatch(t, []string{"a", "b"}, []string{(*pushed)[0].tenant, (*pushed)[1].tenant})
+}
+
+func Test_QueryLogDisabled(t *testing.T) {
+	q := NewQueryLog(QueryLogConfig{}, log.NewNopLogger())
+	require.Nil(t, q)
+
+	next := queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
+		return nil, nil
+	})
+	_, err := q.Middleware().Wrap(next).Do(context.Background(), queryLogRequest(`{app="foo"}`))
+	require.NoError(t, err)
+	q.Stop()
+}
+
+func Test_querySource(t *testing.T) {
+	require.Equal(t, "grafana", querySource("Source=grafana,Feature=beta"))
+	require.Equal(t, "logcli", querySource("feature=beta,source=logcli"))
+	require.Equal(t, "", querySource("Feature=beta"))
+	require.Equal(t, "", querySource(""))
+}
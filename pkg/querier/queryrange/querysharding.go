@@ -261,6 +261,11 @@ func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (que
 
 	// Merge index and volume stats result cache stats from shard resolver into the query stats.
 	res.Statistics.Merge(resolverStats.Result(0, 0, 0))
+	return resultToResponse(res, params, path)
+}
+
+// resultToResponse converts the result of the downstream engine into the response to a query.
+func resultToResponse(res logqlmodel.Result, params logql.Params, path string) (queryrangebase.Response, error) {
 	value, err := marshal.NewResultValue(res.Data)
 	if err != nil {
 		return nil, err
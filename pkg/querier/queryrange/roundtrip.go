@@ -59,6 +59,8 @@ type Config struct {
 	SeriesCacheConfig            SeriesCacheConfig        `yaml:"series_results_cache" doc:"description=If series_results_cache is not configured and cache_series_results is true, the config for the results cache is used."`
 	CacheLabelResults            bool                     `yaml:"cache_label_results"`
 	LabelsCacheConfig            LabelsCacheConfig        `yaml:"label_results_cache" doc:"description=If label_results_cache is not configured and cache_label_results is true, the config for the results cache is used."`
+	Federation                   FederationConfig         `yaml:"federation" doc:"description=Federation of log and metric queries across remote Loki clusters."`
+	QueryLog                     QueryLogConfig           `yaml:"query_log" doc:"description=Query log holding the resource usage of each log and metric query completed by the query frontend."`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
@@ -75,6 +77,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.SeriesCacheConfig.RegisterFlags(f)
 	f.BoolVar(&cfg.CacheLabelResults, "querier.cache-label-results", true, "Cache label query results.")
 	cfg.LabelsCacheConfig.RegisterFlags(f)
+	cfg.Federation.RegisterFlags(f)
+	cfg.QueryLog.RegisterFlags(f)
 }
 
 // Validate validates the config.
@@ -88,6 +92,10 @@ func (cfg *Config) Validate() error {
 			return errors.Wrap(err, "invalid index_stats_results_cache config")
 		}
 	}
+
+	if err := cfg.Federation.Validate(); err != nil {
+		return errors.Wrap(err, "invalid federation config")
+	}
 	return nil
 }
 
@@ -258,6 +266,20 @@ func NewMiddleware(
 	if err != nil {
 		return nil, nil, err
 	}
+
+	federation, err := NewFederationMiddleware(cfg.Federation, engineOpts, log, limits, metrics.FederationMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// query budgets are shared by all handlers wrapped by the middleware
+	queryBudgets := newQueryBudgets()
+	queryLog := NewQueryLog(cfg.QueryLog, log)
+	stoppers := StopperWrapper{resultsCache, statsCache, volumeCache}
+	if queryLog != nil {
+		stoppers = append(stoppers, queryLog)
+	}
+
 	return base.MiddlewareFunc(func(next base.Handler) base.Handler {
 		var (
 			metricRT         = metricsTripperware.Wrap(next)
@@ -272,8 +294,13 @@ func NewMiddleware(
 			detectedLabelsRT = detectedLabelsTripperware.Wrap(next)
 		)
 
-		return newRoundTripper(log, next, limitedRT, logFilterRT, metricRT, seriesRT, labelsRT, instantRT, statsRT, seriesVolumeRT, detectedFieldsRT, detectedLabelsRT, limits)
-	}), StopperWrapper{resultsCache, statsCache, volumeCache}, nil
+		rt := newRoundTripper(log, next, limitedRT, logFilterRT, metricRT, seriesRT, labelsRT, instantRT, statsRT, seriesVolumeRT, detectedFieldsRT, detectedLabelsRT, limits)
+		return base.MergeMiddlewares(
+			queryLog.Middleware(),
+			newQueryCostMiddleware(schema.Configs, engineOpts, log, limits, cfg.ShardedQueries, queryBudgets, metrics.QueryCostMetrics, indexStatsTripperware.Wrap(next)),
+			federation,
+		).Wrap(rt)
+	}), stoppers, nil
 }
 
 func NewDetectedLabelsTripperware(cfg Config, logger log.Logger, l Limits, schema config.SchemaConfig, metrics *Metrics, namespace string, merger base.Merger, limits Limits, iqo util.IngesterQueryOptions) (base.Middleware, error) {
@@ -345,7 +345,8 @@ func TestLogFilterTripperware(t *testing.T) {
 	h = getQueryAndStatsHandler(queryHandler, statsHandler)
 	_, err = tpw.Wrap(h).Do(ctx, lreq)
 	require.Error(t, err)
-	require.Equal(t, 2, *statsCount)
+	// the stats estimated by the query cost middleware are reused by both size limiters.
+	require.Equal(t, 1, *statsCount)
 	require.Equal(t, 0, *queryCount)
 }
 
@@ -1409,6 +1410,10 @@ type fakeLimits struct {
 	maxStatsCacheFreshness      time.Duration
 	maxMetadataCacheFreshness   time.Duration
 	volumeEnabled               bool
+	queryBytesBudgetPerMinute   int
+	queryBytesBudgetBurst       int
+	maxLogResultCacheEntrySize  int
+	maxLogResultCacheBytes      int
 }
 
 func (f fakeLimits) QuerySplitDuration(key string) time.Duration {
@@ -1508,6 +1513,10 @@ func (f fakeLimits) BlockedQueries(context.Context, string) []*validation.Blocke
 	return []*validation.BlockedQuery{}
 }
 
+func (f fakeLimits) RollupRules(context.Context, string) []*validation.RollupRule {
+	return nil
+}
+
 func (f fakeLimits) RequiredLabels(context.Context, string) []string {
 	return f.requiredLabels
 }
@@ -1528,6 +1537,26 @@ func (f fakeLimits) VolumeEnabled(_ string) bool {
 	return f.volumeEnabled
 }
 
+func (f fakeLimits) MaxAsyncQueries(_ string) int {
+	return 0
+}
+
+func (f fakeLimits) QueryBytesBudgetPerMinute(_ string) int {
+	return f.queryBytesBudgetPerMinute
+}
+
+func (f fakeLimits) QueryBytesBudgetBurst(_ string) int {
+	return f.queryBytesBudgetBurst
+}
+
+func (f fakeLimits) MaxLogResultCacheEntrySize(_ string) int {
+	return f.maxLogResultCacheEntrySize
+}
+
+func (f fakeLimits) MaxLogResultCacheBytes(_ string) int {
+	return f.maxLogResultCacheBytes
+}
+
 func (f fakeLimits) TSDBMaxBytesPerShard(_ string) int {
 	return valid.DefaultTSDBMaxBytesPerShard
 }
@@ -1,6 +1,8 @@
 package queryrange
 
 import (
+	"context"
+	"io"
 	"net/http"
 
 	"github.com/opentracing/opentracing-go"
@@ -33,6 +35,10 @@ func (rt *serializeRoundTripper) RoundTrip(r *http.Request) (*http.Response, err
 		return nil, err
 	}
 
+	if contentType := loghttp.StreamContentType(r.Header.Get("Accept")); contentType != "" && streamableRequest(request) {
+		return rt.roundTripStream(ctx, r, request, contentType)
+	}
+
 	response, err := rt.next.Do(ctx, request)
 	if err != nil {
 		return nil, err
@@ -41,6 +47,43 @@ func (rt *serializeRoundTripper) RoundTrip(r *http.Request) (*http.Response, err
 	return rt.codec.EncodeResponse(ctx, r, response)
 }
 
+// roundTripStream returns a response whose body streams the log entries of the query.
+// Errors happening before the first frame is written are returned as usual, while later errors end the stream with an error frame.
+func (rt *serializeRoundTripper) roundTripStream(ctx context.Context, r *http.Request, request queryrangebase.Request, contentType string) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	started := make(chan struct{})
+	failed := make(chan error, 1)
+
+	s := newResponseStream(r, contentType, pw)
+	s.start = func() { close(started) }
+
+	go func() {
+		err := doStream(ctx, rt.next, request, s)
+		if err != nil {
+			if !s.isStarted() {
+				failed <- err
+				_ = pw.Close()
+				return
+			}
+			_ = s.sendError(err)
+		}
+		_ = pw.Close()
+	}()
+
+	select {
+	case <-started:
+		return &http.Response{
+			Header: http.Header{
+				"Content-Type": []string{contentType},
+			},
+			Body:       pr,
+			StatusCode: http.StatusOK,
+		}, nil
+	case err := <-failed:
+		return nil, err
+	}
+}
+
 type serializeHTTPHandler struct {
 	codec queryrangebase.Codec
 	next  queryrangebase.Handler
@@ -64,6 +107,25 @@ func (rt *serializeHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if contentType := loghttp.StreamContentType(r.Header.Get("Accept")); contentType != "" && streamableRequest(request) {
+		rc := http.NewResponseController(w)
+		s := newResponseStream(r, contentType, w)
+		s.start = func() {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusOK)
+		}
+		s.flush = func() { _ = rc.Flush() }
+
+		if err := doStream(ctx, rt.next, request, s); err != nil {
+			if !s.isStarted() {
+				serverutil.WriteError(err, w)
+				return
+			}
+			_ = s.sendError(err)
+		}
+		return
+	}
+
 	response, err := rt.next.Do(ctx, request)
 	if err != nil {
 		serverutil.WriteError(err, w)
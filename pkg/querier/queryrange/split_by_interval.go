@@ -18,6 +18,7 @@ import (
 
 	"github.com/grafana/dskit/tenant"
 
+	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
@@ -121,10 +122,14 @@ func (h *splitByInterval) Process(
 		p = len(input)
 	}
 
+	// The entries of log queries are streamed to the client as soon as all splits before them
+	// in the query direction are done, since they can't be preceded by entries of later splits.
+	stream := responseStreamFromContext(ctx)
+
 	// per request wrapped handler for limiting the amount of series.
 	next := newSeriesLimiter(maxSeries).Wrap(h.next)
 	for i := 0; i < p; i++ {
-		go h.loop(ctx, ch, next)
+		go h.loop(withResponseStream(ctx, nil), ch, next)
 	}
 
 	for _, x := range input {
@@ -136,10 +141,19 @@ func (h *splitByInterval) Process(
 				return nil, data.err
 			}
 
-			responses = append(responses, data.resp)
+			resp := data.resp
+			casted, ok := data.resp.(*LokiResponse)
+			if stream != nil && ok && casted.Status == loghttp.QueryStatusSuccess {
+				sent, err := stream.streamEntries(casted, threshold, unlimited)
+				if err != nil {
+					return nil, err
+				}
+				resp = sent
+			}
+			responses = append(responses, resp)
 
 			// see if we can exit early if a limit has been reached
-			if casted, ok := data.resp.(*LokiResponse); !unlimited && ok {
+			if !unlimited && ok {
 				threshold -= casted.Count()
 
 				if threshold <= 0 {
@@ -0,0 +1,179 @@
+package queryrange
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/grafana/dskit/httpgrpc"
+	json "github.com/json-iterator/go"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+type responseStreamContextKey struct{}
+
+// responseStream writes the log entries of a query to the client while the query runs.
+// Each frame is a LokiResponse holding the next entries in the query direction,
+// the last frame holds the statistics and warnings of the whole query.
+type responseStream struct {
+	contentType string
+	version     loghttp.Version
+	flags       httpreq.EncodingFlags
+	w           io.Writer
+	// start is called before the first frame is written.
+	start func()
+	flush func()
+
+	mtx     sync.Mutex
+	started bool
+}
+
+func newResponseStream(r *http.Request, contentType string, w io.Writer) *responseStream {
+	return &responseStream{
+		contentType: contentType,
+		version:     loghttp.GetVersion(r.RequestURI),
+		flags:       httpreq.ExtractEncodingFlags(r),
+		w:           w,
+	}
+}
+
+// withResponseStream returns a context streaming the log entries of the query to s.
+// A nil s disables streaming for the handlers below the one emitting the frames.
+// The entries are streamed by the split by interval middleware, so the middlewares above it which
+// inspect or rewrite the entries of the response must disable streaming for their next handler.
+func withResponseStream(ctx context.Context, s *responseStream) context.Context {
+	return context.WithValue(ctx, responseStreamContextKey{}, s)
+}
+
+func responseStreamFromContext(ctx context.Context) *responseStream {
+	s, _ := ctx.Value(responseStreamContextKey{}).(*responseStream)
+	return s
+}
+
+// send writes resp as the next frame of the stream.
+func (s *responseStream) send(resp *LokiResponse) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.started {
+		s.started = true
+		if s.start != nil {
+			s.start()
+		}
+	}
+
+	var err error
+	switch s.contentType {
+	case loghttp.ProtobufStreamContentType:
+		err = loghttp.WriteProtobufFrame(s.w, resp)
+	default:
+		if err = encodeResponseJSONTo(s.version, resp, s.w, s.flags); err == nil {
+			_, err = s.w.Write([]byte("\n"))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if s.flush != nil {
+		s.flush()
+	}
+	return nil
+}
+
+// sendError ends a stream that already started with an error frame, since the status code has been sent.
+func (s *responseStream) sendError(err error) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	errorType := "internal"
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		err = fmt.Errorf("%s", resp.Body)
+		if resp.Code/100 == 4 {
+			errorType = "bad_data"
+		}
+	}
+	frame := &LokiResponse{
+		Status:    loghttp.QueryStatusError,
+		ErrorType: errorType,
+		Error:     err.Error(),
+	}
+
+	switch s.contentType {
+	case loghttp.ProtobufStreamContentType:
+		return loghttp.WriteProtobufFrame(s.w, frame)
+	default:
+		data, err := json.Marshal(struct {
+			Status    string `json:"status"`
+			ErrorType string `json:"errorType"`
+			Error     string `json:"error"`
+		}{frame.Status, frame.ErrorType, frame.Error})
+		if err != nil {
+			return err
+		}
+		_, err = s.w.Write(append(data, '\n'))
+		return err
+	}
+}
+
+func (s *responseStream) isStarted() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.started
+}
+
+// streamEntries sends the entries of a split response, cut to the remaining limit of the query,
+// and returns the response without entries so that only its statistics are merged into the final frame.
+func (s *responseStream) streamEntries(resp *LokiResponse, remaining int64, unlimited bool) (*LokiResponse, error) {
+	frame := *resp
+	if !unlimited && resp.Count() > remaining {
+		frame.Data = LokiData{
+			ResultType: resp.Data.ResultType,
+			Result:     mergeOrderedNonOverlappingStreams([]*LokiResponse{resp}, uint32(max(remaining, 0)), resp.Direction),
+		}
+	}
+	if frame.Count() > 0 {
+		frame.Statistics = stats.Result{}
+		frame.Warnings = nil
+		if err := s.send(&frame); err != nil {
+			return nil, err
+		}
+	}
+
+	sent := *resp
+	sent.Data = LokiData{
+		ResultType: resp.Data.ResultType,
+		Result:     []logproto.Stream{},
+	}
+	return &sent, nil
+}
+
+// streamableRequest returns true for log queries, whose entries can be streamed.
+func streamableRequest(req queryrangebase.Request) bool {
+	lokiReq, ok := req.(*LokiRequest)
+	if !ok || lokiReq.Plan == nil {
+		return false
+	}
+	_, ok = lokiReq.Plan.AST.(syntax.LogSelectorExpr)
+	return ok
+}
+
+// doStream runs the query, streaming its log entries to s, and sends the final frame.
+func doStream(ctx context.Context, next queryrangebase.Handler, req queryrangebase.Request, s *responseStream) error {
+	response, err := next.Do(withResponseStream(ctx, s), req)
+	if err != nil {
+		return err
+	}
+	lokiRes, ok := response.(*LokiResponse)
+	if !ok {
+		return fmt.Errorf("unexpected response type %T", response)
+	}
+	return s.send(lokiRes)
+}
@@ -0,0 +1,181 @@
+package queryrange
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+)
+
+func newStreamTestSplit() queryrangebase.Handler {
+	next := queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		req := r.(*LokiRequest)
+		return &LokiResponse{
+			Status:    loghttp.QueryStatusSuccess,
+			Direction: req.Direction,
+			Limit:     req.Limit,
+			Version:   uint32(loghttp.VersionV1),
+			Data: LokiData{
+				ResultType: loghttp.ResultTypeStream,
+				Result: []logproto.Stream{
+					{
+						Labels: `{foo="bar"}`,
+						Entries: []logproto.Entry{
+							{Timestamp: req.StartTs, Line: fmt.Sprintf("%d", req.StartTs.UnixNano())},
+						},
+					},
+				},
+			},
+		}, nil
+	})
+
+	return SplitByIntervalMiddleware(
+		testSchemas,
+		WithSplitByLimits(fakeLimits{maxQueryParallelism: 2}, time.Hour),
+		DefaultCodec,
+		newDefaultSplitter(fakeLimits{}, nil),
+		nilMetrics,
+	).Wrap(next)
+}
+
+func newStreamTestRequest(limit uint32) *LokiRequest {
+	return &LokiRequest{
+		Query:     `{foo="bar"}`,
+		StartTs:   time.Unix(0, 0),
+		EndTs:     time.Unix(0, (4 * time.Hour).Nanoseconds()),
+		Limit:     limit,
+		Direction: logproto.BACKWARD,
+		Path:      "/loki/api/v1/query_range",
+		Plan: &plan.QueryPlan{
+			AST: syntax.MustParseExpr(`{foo="bar"}`),
+		},
+	}
+}
+
+func TestResponseStream_NDJSON(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	var buf bytes.Buffer
+	s := newResponseStream(httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range", nil), loghttp.NDJSONContentType, &buf)
+
+	require.NoError(t, doStream(ctx, newStreamTestSplit(), newStreamTestRequest(3), s))
+
+	// every split is sent as soon as it is done and the query ends once the limit is reached,
+	// the last frame holds the statistics.
+	dec := loghttp.NewQueryResponseStreamDecoder(&buf)
+	var lines []string
+	for {
+		resp, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		for _, stream := range resp.Data.Result.(loghttp.Streams) {
+			for _, entry := range stream.Entries {
+				lines = append(lines, entry.Line)
+			}
+		}
+		if len(resp.Data.Result.(loghttp.Streams)) == 0 {
+			require.Equal(t, int64(3), resp.Data.Statistics.Summary.Splits)
+		}
+	}
+	require.Equal(t, []string{
+		fmt.Sprintf("%d", 3*time.Hour.Nanoseconds()),
+		fmt.Sprintf("%d", 2*time.Hour.Nanoseconds()),
+		fmt.Sprintf("%d", time.Hour.Nanoseconds()),
+	}, lines)
+}
+
+func TestResponseStream_Protobuf(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	var buf bytes.Buffer
+	s := newResponseStream(httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range", nil), loghttp.ProtobufStreamContentType, &buf)
+
+	require.NoError(t, doStream(ctx, newStreamTestSplit(), newStreamTestRequest(1000), s))
+
+	r := bufio.NewReader(&buf)
+	var frames, entries int64
+	for {
+		var resp LokiResponse
+		err := loghttp.ReadProtobufFrame(r, &resp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, loghttp.QueryStatusSuccess, resp.Status)
+		frames++
+		entries += resp.Count()
+	}
+	require.Equal(t, int64(5), frames)
+	require.Equal(t, int64(4), entries)
+}
+
+func TestSerializeRoundTripper_Stream(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, `/loki/api/v1/query_range?query={foo="bar"}&start=0&end=14400000000000&limit=2&direction=backward`, nil)
+		r.Header.Set("Accept", loghttp.NDJSONContentType)
+		return r.WithContext(ctx)
+	}
+
+	resp, err := NewSerializeRoundTripper(newStreamTestSplit(), DefaultCodec).RoundTrip(newRequest())
+	require.NoError(t, err)
+	require.Equal(t, loghttp.NDJSONContentType, resp.Header.Get("Content-Type"))
+	result, err := loghttp.ReadQueryResponseStream(resp.Body)
+	require.NoError(t, err)
+	require.Len(t, result.Data.Result.(loghttp.Streams), 2)
+
+	// errors before the first frame are returned as usual.
+	failing := queryrangebase.HandlerFunc(func(context.Context, queryrangebase.Request) (queryrangebase.Response, error) {
+		return nil, errors.New("failed")
+	})
+	_, err = NewSerializeRoundTripper(failing, DefaultCodec).RoundTrip(newRequest())
+	require.EqualError(t, err, "failed")
+}
+
+func TestResponseStream_Error(t *testing.T) {
+	var buf bytes.Buffer
+	s := newResponseStream(httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range", nil), loghttp.NDJSONContentType, &buf)
+	require.NoError(t, s.send(emptyResponse(newStreamTestRequest(10))))
+	require.NoError(t, s.sendError(errors.New("querier failed")))
+
+	_, err := loghttp.ReadQueryResponseStream(&buf)
+	require.EqualError(t, err, "query failed: querier failed")
+}
+
+func TestResponseStream_Federation(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	remote := remoteCluster(t, streamsHandler("remote"))
+	// the entries of the local cluster are merged with the remote ones by the federation, so they are not streamed.
+	local := queryrangebase.HandlerFunc(func(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		require.Nil(t, responseStreamFromContext(ctx))
+		return streamsHandler("local").Do(ctx, r)
+	})
+	handler := newTestFederation(t, local, RemoteClusterConfig{Name: "eu", URL: remote.URL})
+
+	var buf bytes.Buffer
+	s := newResponseStream(httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range", nil), loghttp.NDJSONContentType, &buf)
+	require.NoError(t, doStream(ctx, handler, federatedLogRequest(), s))
+
+	result, err := loghttp.ReadQueryResponseStream(&buf)
+	require.NoError(t, err)
+	var lbls []string
+	for _, stream := range result.Data.Result.(loghttp.Streams) {
+		lbls = append(lbls, stream.Labels.String())
+	}
+	require.ElementsMatch(t, []string{`{app="foo", cluster="local"}`, `{app="foo", cluster="eu"}`}, lbls)
+}
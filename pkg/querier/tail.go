@@ -7,13 +7,16 @@ import (
 	"time"
 
 	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/loki/v3/pkg/iter"
-	loghttp "github.com/grafana/loki/v3/pkg/loghttp/legacy"
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	loghttp_legacy "github.com/grafana/loki/v3/pkg/loghttp/legacy"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 )
@@ -33,8 +36,27 @@ const (
 	// with the next successfully pushed response. Once the dropped entries memory buffer
 	// exceed this value, we start skipping dropped entries too.
 	maxDroppedEntriesPerTailResponse = 1000
+
+	// the maximum number of entries received from ingesters and not yet sent to the client
+	// when the Tailer applies backpressure. Once reached, the Tailer stops reading from ingesters.
+	maxBufferedTailEntries = 10000
+
+	// how often a Tailer applying backpressure checks whether it can make progress
+	tailBackpressurePollInterval = 10 * time.Millisecond
 )
 
+// TailOptions configures how a Tailer delivers entries to the client.
+type TailOptions struct {
+	CategorizeLabels bool
+	// Backpressure makes the Tailer wait for the client to consume responses, and stop reading from
+	// ingesters when too many entries are buffered, instead of dropping entries.
+	// Entries dropped by the ingesters are still reported in the responses.
+	Backpressure bool
+	// Resume returns the historic entries following the start of the request, instead of the most recent ones,
+	// so that a client resuming a tail receives the entries it missed.
+	Resume bool
+}
+
 // Tailer manages complete lifecycle of a tail request
 type Tailer struct {
 	// openStreamIterator is for streams already open
@@ -55,10 +77,27 @@ type Tailer struct {
 
 	stopped          atomic.Bool
 	delayFor         time.Duration
-	responseChan     chan *loghttp.TailResponse
+	responseChan     chan *loghttp_legacy.TailResponse
 	closeErrChan     chan error
 	tailMaxDuration  time.Duration
 	categorizeLabels bool
+	backpressure     bool
+
+	// bufferedEntries is the number of entries received from ingesters and not yet consumed.
+	bufferedEntries atomic.Int64
+	// droppedByIngesters holds the entries dropped by ingesters, reported with the next response.
+	droppedByIngesters    []loghttp_legacy.DroppedEntry
+	droppedByIngestersMtx sync.Mutex
+
+	// rateLimiter limits the bytes sent to the client of a Tailer applying backpressure, nil if unlimited.
+	rateLimiter *rate.Limiter
+
+	// ctx is cancelled when the Tailer is closed, to stop waiting for the rate limiter.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// tenantTailers are the Tailers of each tenant of a multi-tenant tail, whose responses are merged.
+	tenantTailers []*Tailer
 
 	// if we are not seeing any response from ingester,
 	// how long do we want to wait by going into sleep
@@ -85,7 +124,7 @@ func (t *Tailer) loop() {
 	tailMaxDurationTicker := time.NewTicker(t.tailMaxDuration)
 	defer tailMaxDurationTicker.Stop()
 
-	droppedEntries := make([]loghttp.DroppedEntry, 0)
+	droppedEntries := make([]loghttp_legacy.DroppedEntry, 0)
 
 	for !t.stopped.Load() {
 		select {
@@ -106,7 +145,7 @@ func (t *Tailer) loop() {
 		// Read as much entries as we can (up to the max allowed) and populate the
 		// tail response we'll send over the response channel
 		var (
-			tailResponse = new(loghttp.TailResponse)
+			tailResponse = new(loghttp_legacy.TailResponse)
 			entriesCount = 0
 			entriesSize  = 0
 		)
@@ -114,7 +153,7 @@ func (t *Tailer) loop() {
 		for ; entriesCount < maxEntriesPerTailResponse && t.next(); entriesCount++ {
 			// If the response channel channel is blocked, we drop the current entry directly
 			// to save the effort
-			if t.isResponseChanBlocked() {
+			if !t.backpressure && t.isResponseChanBlocked() {
 				droppedEntries = dropEntry(droppedEntries, t.currEntry.Timestamp, t.currLabels)
 				continue
 			}
@@ -157,6 +196,25 @@ func (t *Tailer) loop() {
 			continue
 		}
 
+		droppedEntries = t.takeDroppedByIngesters(droppedEntries)
+
+		if t.backpressure {
+			// Wait for the rate limiter and the client instead of dropping entries.
+			if err := t.waitRateLimit(entriesSize); err != nil {
+				level.Debug(t.logger).Log("msg", "stopped waiting for the tail rate limit", "err", err)
+				return
+			}
+			if len(droppedEntries) > 0 {
+				tailResponse.DroppedEntries = droppedEntries
+			}
+			if !t.send(tailResponse) {
+				return
+			}
+			t.metrics.tailedBytesTotal.Add(float64(entriesSize))
+			droppedEntries = make([]loghttp_legacy.DroppedEntry, 0)
+			continue
+		}
+
 		// Send the tail response through the response channel without blocking.
 		// Drop the entry if the response channel buffer is full.
 		if len(droppedEntries) > 0 {
@@ -167,7 +225,7 @@ func (t *Tailer) loop() {
 		case t.responseChan <- tailResponse:
 			t.metrics.tailedBytesTotal.Add(float64(entriesSize))
 			if len(droppedEntries) > 0 {
-				droppedEntries = make([]loghttp.DroppedEntry, 0)
+				droppedEntries = make([]loghttp_legacy.DroppedEntry, 0)
 			}
 		default:
 			droppedEntries = dropEntries(droppedEntries, tailResponse.Streams)
@@ -175,6 +233,51 @@ func (t *Tailer) loop() {
 	}
 }
 
+// send blocks until the response is consumed, and returns false if the Tailer was stopped in the meantime.
+func (t *Tailer) send(resp *loghttp_legacy.TailResponse) bool {
+	ticker := time.NewTicker(tailBackpressurePollInterval)
+	defer ticker.Stop()
+
+	for !t.stopped.Load() {
+		select {
+		case t.responseChan <- resp:
+			return true
+		case <-ticker.C:
+		}
+	}
+	return false
+}
+
+// waitRateLimit waits until the rate limiter allows sending the given amount of bytes.
+// It returns an error when the Tailer is closed in the meantime.
+func (t *Tailer) waitRateLimit(bytes int) error {
+	if t.rateLimiter == nil {
+		return nil
+	}
+	// Waiting for more than the burst fails, so the bytes are charged by chunks of at most the burst.
+	burst := t.rateLimiter.Burst()
+	for bytes > 0 {
+		n := min(bytes, burst)
+		if err := t.rateLimiter.WaitN(t.ctx, n); err != nil {
+			return err
+		}
+		bytes -= n
+	}
+	return nil
+}
+
+// takeDroppedByIngesters appends the entries dropped by ingesters since the last response.
+func (t *Tailer) takeDroppedByIngesters(droppedEntries []loghttp_legacy.DroppedEntry) []loghttp_legacy.DroppedEntry {
+	t.droppedByIngestersMtx.Lock()
+	defer t.droppedByIngestersMtx.Unlock()
+
+	for _, d := range t.droppedByIngesters {
+		droppedEntries = dropEntry(droppedEntries, d.Timestamp, d.Labels)
+	}
+	t.droppedByIngesters = t.droppedByIngesters[:0]
+	return droppedEntries
+}
+
 // Checks whether we are connected to all the ingesters to tail the logs.
 // Helps in connecting to disconnected ingesters or connecting to new ingesters
 func (t *Tailer) checkIngesterConnections() error {
@@ -216,6 +319,12 @@ func (t *Tailer) readTailClient(addr string, querierTailClient logproto.Querier_
 
 	logger := util_log.WithContext(querierTailClient.Context(), t.logger)
 	for {
+		// Stop reading from the ingester while the client is catching up, gRPC flow control
+		// then pushes back to the ingester.
+		for t.backpressure && t.bufferedEntries.Load() >= maxBufferedTailEntries && !t.stopped.Load() {
+			time.Sleep(tailBackpressurePollInterval)
+		}
+
 		stopped := t.stopped.Load()
 		if stopped {
 			if err := querierTailClient.CloseSend(); err != nil {
@@ -240,7 +349,22 @@ func (t *Tailer) pushTailResponseFromIngester(resp *logproto.TailResponse) {
 	t.streamMtx.Lock()
 	defer t.streamMtx.Unlock()
 
-	itr := iter.NewStreamIterator(*resp.Stream)
+	if len(resp.DroppedStreams) > 0 {
+		t.droppedByIngestersMtx.Lock()
+		for _, d := range resp.DroppedStreams {
+			t.droppedByIngesters = dropEntry(t.droppedByIngesters, d.From, d.Labels)
+		}
+		t.droppedByIngestersMtx.Unlock()
+	}
+	if resp.Stream == nil {
+		return
+	}
+
+	var itr iter.EntryIterator = iter.NewStreamIterator(*resp.Stream)
+	if t.backpressure {
+		t.bufferedEntries.Add(int64(len(resp.Stream.Entries)))
+		itr = &bufferedEntryIterator{EntryIterator: itr, buffered: &t.bufferedEntries}
+	}
 	if t.categorizeLabels {
 		itr = iter.NewCategorizeLabelsIterator(itr)
 	}
@@ -266,6 +390,18 @@ func (t *Tailer) next() bool {
 }
 
 func (t *Tailer) close() error {
+	t.cancel()
+	if t.tenantTailers != nil {
+		t.stopped.Store(true)
+		var lastErr error
+		for _, tenantTailer := range t.tenantTailers {
+			if err := tenantTailer.close(); err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+
 	t.streamMtx.Lock()
 	defer t.streamMtx.Unlock()
 
@@ -283,7 +419,7 @@ func (t *Tailer) isResponseChanBlocked() bool {
 	return len(t.responseChan) == cap(t.responseChan)
 }
 
-func (t *Tailer) getResponseChan() <-chan *loghttp.TailResponse {
+func (t *Tailer) getResponseChan() <-chan *loghttp_legacy.TailResponse {
 	return t.responseChan
 }
 
@@ -317,26 +453,32 @@ func newTailer(
 	tailDisconnectedIngesters func([]string) (map[string]logproto.Querier_TailClient, error),
 	tailMaxDuration time.Duration,
 	waitEntryThrottle time.Duration,
-	categorizeLabels bool,
+	opts TailOptions,
+	rateLimiter *rate.Limiter,
 	m *Metrics,
 	logger log.Logger,
 ) *Tailer {
 	historicEntriesIter := historicEntries
-	if categorizeLabels {
+	if opts.CategorizeLabels {
 		historicEntriesIter = iter.NewCategorizeLabelsIterator(historicEntries)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	t := Tailer{
 		openStreamIterator:        iter.NewMergeEntryIterator(context.Background(), []iter.EntryIterator{historicEntriesIter}, logproto.FORWARD),
 		querierTailClients:        querierTailClients,
 		delayFor:                  delayFor,
-		responseChan:              make(chan *loghttp.TailResponse, maxBufferedTailResponses),
+		responseChan:              make(chan *loghttp_legacy.TailResponse, maxBufferedTailResponses),
 		closeErrChan:              make(chan error),
 		seenStreams:               make(map[uint64]struct{}),
 		tailDisconnectedIngesters: tailDisconnectedIngesters,
 		tailMaxDuration:           tailMaxDuration,
 		waitEntryThrottle:         waitEntryThrottle,
-		categorizeLabels:          categorizeLabels,
+		categorizeLabels:          opts.CategorizeLabels,
+		backpressure:              opts.Backpressure,
+		rateLimiter:               rateLimiter,
+		ctx:                       ctx,
+		cancel:                    cancel,
 		metrics:                   m,
 		logger:                    logger,
 	}
@@ -347,15 +489,54 @@ func newTailer(
 	return &t
 }
 
-func dropEntry(droppedEntries []loghttp.DroppedEntry, timestamp time.Time, labels string) []loghttp.DroppedEntry {
+// newMultiTenantTailer merges the responses of the Tailers of several tenants, adding the tenant label to each stream.
+func newMultiTenantTailer(tenantTailers map[string]*Tailer, logger log.Logger) *Tailer {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Tailer{
+		responseChan: make(chan *loghttp_legacy.TailResponse, maxBufferedTailResponses),
+		closeErrChan: make(chan error, len(tenantTailers)),
+		ctx:          ctx,
+		cancel:       cancel,
+		logger:       logger,
+	}
+	for id, tenantTailer := range tenantTailers {
+		t.tenantTailers = append(t.tenantTailers, tenantTailer)
+		go t.forwardTenantTailer(id, tenantTailer)
+	}
+	return t
+}
+
+func (t *Tailer) forwardTenantTailer(id string, tenantTailer *Tailer) {
+	ticker := time.NewTicker(tailBackpressurePollInterval)
+	defer ticker.Stop()
+
+	r := relabel{tenantID: id, cache: map[string]labels.Labels{}}
+	for !t.stopped.Load() {
+		select {
+		case resp := <-tenantTailer.getResponseChan():
+			for i := range resp.Streams {
+				resp.Streams[i].Labels = r.relabel(resp.Streams[i].Labels)
+			}
+			if !t.send(resp) {
+				return
+			}
+		case err := <-tenantTailer.getCloseErrorChan():
+			t.closeErrChan <- fmt.Errorf("tailing tenant %s: %w", id, err)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func dropEntry(droppedEntries []loghttp_legacy.DroppedEntry, timestamp time.Time, labels string) []loghttp_legacy.DroppedEntry {
 	if len(droppedEntries) >= maxDroppedEntriesPerTailResponse {
 		return droppedEntries
 	}
 
-	return append(droppedEntries, loghttp.DroppedEntry{Timestamp: timestamp, Labels: labels})
+	return append(droppedEntries, loghttp_legacy.DroppedEntry{Timestamp: timestamp, Labels: labels})
 }
 
-func dropEntries(droppedEntries []loghttp.DroppedEntry, streams []logproto.Stream) []loghttp.DroppedEntry {
+func dropEntries(droppedEntries []loghttp_legacy.DroppedEntry, streams []logproto.Stream) []loghttp_legacy.DroppedEntry {
 	for _, stream := range streams {
 		for _, entry := range stream.Entries {
 			droppedEntries = dropEntry(droppedEntries, entry.Timestamp, entry.Line)
@@ -364,3 +545,146 @@ func dropEntries(droppedEntries []loghttp.DroppedEntry, streams []logproto.Strea
 
 	return droppedEntries
 }
+
+// bufferedEntryIterator keeps track of the entries received from an ingester and not yet consumed.
+type bufferedEntryIterator struct {
+	iter.EntryIterator
+	buffered *atomic.Int64
+}
+
+func (i *bufferedEntryIterator) Next() bool {
+	if !i.EntryIterator.Next() {
+		return false
+	}
+	i.buffered.Dec()
+	return true
+}
+
+// resumedHistoryIterator iterates over the historic entries of a resumed tail in pages of the request limit,
+// so that a client resuming after a long disconnect receives all the entries it missed and not only the first ones.
+type resumedHistoryIterator struct {
+	// queryPage returns at most limit entries from start onwards.
+	queryPage func(start time.Time) ([]logproto.Stream, error)
+	limit     uint32
+
+	page     iter.EntryIterator
+	lastPage bool
+	progress bool // whether the current page returned an entry which was not already returned
+
+	// next is the start of the next page, seen holds the entries returned at next which the next page returns again.
+	next time.Time
+	seen map[string]struct{}
+
+	currEntry  logproto.Entry
+	currLabels string
+	currHash   uint64
+	err        error
+}
+
+func newResumedHistoryIterator(start time.Time, limit uint32, queryPage func(start time.Time) ([]logproto.Stream, error)) *resumedHistoryIterator {
+	return &resumedHistoryIterator{
+		queryPage: queryPage,
+		limit:     limit,
+		next:      start,
+		seen:      map[string]struct{}{},
+	}
+}
+
+func (i *resumedHistoryIterator) Next() bool {
+	for {
+		if i.page == nil {
+			if i.lastPage || i.err != nil {
+				return false
+			}
+			streams, err := i.queryPage(i.next)
+			if err != nil {
+				i.err = err
+				return false
+			}
+			var count uint32
+			for _, s := range streams {
+				count += uint32(len(s.Entries))
+			}
+			i.page = iter.NewStreamsIterator(streams, logproto.FORWARD)
+			i.lastPage = count < i.limit
+			i.progress = false
+		}
+
+		for i.page.Next() {
+			entry := i.page.At()
+			key := i.page.Labels() + "ÿ" + entry.Line
+			if entry.Timestamp.After(i.next) {
+				i.next = entry.Timestamp
+				clear(i.seen)
+			} else if _, ok := i.seen[key]; ok {
+				continue
+			}
+			i.seen[key] = struct{}{}
+			i.progress = true
+			i.currEntry, i.currLabels, i.currHash = entry, i.page.Labels(), i.page.StreamHash()
+			return true
+		}
+		i.page = nil
+
+		if !i.progress {
+			// More entries than the limit share the timestamp next: move past it rather than
+			// requesting the same page forever.
+			i.next = i.next.Add(time.Nanosecond)
+			clear(i.seen)
+		}
+	}
+}
+
+func (i *resumedHistoryIterator) At() logproto.Entry { return i.currEntry }
+func (i *resumedHistoryIterator) Labels() string     { return i.currLabels }
+func (i *resumedHistoryIterator) StreamHash() uint64 { return i.currHash }
+func (i *resumedHistoryIterator) Err() error         { return i.err }
+func (i *resumedHistoryIterator) Close() error       { return nil }
+
+// tailResumer drops the entries already received by a client resuming a tail,
+// and tracks the position of the client to issue resume tokens.
+type tailResumer struct {
+	position loghttp.TailResumeToken
+	// entries before resumeFrom, and the first skip entries at resumeFrom, were already received.
+	resumeFrom time.Time
+	skip       int
+}
+
+func newTailResumer(start time.Time, resume *loghttp.TailResumeToken) *tailResumer {
+	r := &tailResumer{position: loghttp.TailResumeToken{Timestamp: start}}
+	if resume != nil {
+		r.position = *resume
+		r.resumeFrom = resume.Timestamp
+		r.skip = resume.Entries
+	}
+	return r
+}
+
+// filter removes the entries already received by the client from the response, advances the
+// position of the client and returns whether there is anything left to send.
+func (r *tailResumer) filter(resp *loghttp_legacy.TailResponse) bool {
+	streams := resp.Streams[:0]
+	for _, stream := range resp.Streams {
+		entries := stream.Entries[:0]
+		for _, e := range stream.Entries {
+			if e.Timestamp.Before(r.resumeFrom) {
+				continue
+			}
+			if r.skip > 0 && e.Timestamp.Equal(r.resumeFrom) {
+				r.skip--
+				continue
+			}
+			// entries older than the position, delivered late by the ingesters, don't move it backwards.
+			if !e.Timestamp.Before(r.position.Timestamp) {
+				r.position = r.position.Advance(e.Timestamp)
+			}
+			entries = append(entries, e)
+		}
+		if len(entries) > 0 {
+			stream.Entries = entries
+			streams = append(streams, stream)
+		}
+	}
+	resp.Streams = streams
+	return len(resp.Streams) > 0 || len(resp.DroppedEntries) > 0
+}
@@ -2,6 +2,7 @@ package querier
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -9,11 +10,13 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 
 	gokitlog "github.com/go-kit/log"
 
 	"github.com/grafana/loki/v3/pkg/iter"
-	loghttp "github.com/grafana/loki/v3/pkg/loghttp/legacy"
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	loghttp_legacy "github.com/grafana/loki/v3/pkg/loghttp/legacy"
 	"github.com/grafana/loki/v3/pkg/logproto"
 )
 
@@ -165,7 +168,7 @@ func TestTailer(t *testing.T) {
 				tailClients["test"] = test.tailClient
 			}
 
-			tailer := newTailer(0, tailClients, test.historicEntries, tailDisconnectedIngesters, timeout, throttle, false, NewMetrics(nil), gokitlog.NewNopLogger())
+			tailer := newTailer(0, tailClients, test.historicEntries, tailDisconnectedIngesters, timeout, throttle, TailOptions{}, nil, NewMetrics(nil), gokitlog.NewNopLogger())
 			defer tailer.close()
 
 			test.tester(t, tailer, test.tailClient)
@@ -360,7 +363,7 @@ func TestCategorizedLabels(t *testing.T) {
 				tailClients[k] = v
 			}
 
-			tailer := newTailer(0, tailClients, tc.historicEntries, tailDisconnectedIngesters, timeout, throttle, tc.categorizeLabels, NewMetrics(nil), log.NewNopLogger())
+			tailer := newTailer(0, tailClients, tc.historicEntries, tailDisconnectedIngesters, timeout, throttle, TailOptions{CategorizeLabels: tc.categorizeLabels}, nil, NewMetrics(nil), log.NewNopLogger())
 			defer tailer.close()
 
 			// Make tail clients receive their responses
@@ -381,8 +384,186 @@ func TestCategorizedLabels(t *testing.T) {
 	}
 }
 
-func readFromTailer(tailer *Tailer, maxEntries int) ([]*loghttp.TailResponse, error) {
-	responses := make([]*loghttp.TailResponse, 0)
+func TestTailerBackpressure(t *testing.T) {
+	t.Parallel()
+
+	tailDisconnectedIngesters := func([]string) (map[string]logproto.Querier_TailClient, error) {
+		return map[string]logproto.Querier_TailClient{}, nil
+	}
+
+	t.Run("entries are not dropped when the client is slow", func(t *testing.T) {
+		total := (maxEntriesPerTailResponse * maxBufferedTailResponses) + 5
+		tailer := newTailer(0, map[string]logproto.Querier_TailClient{}, mockStreamIterator(1, total), tailDisconnectedIngesters, timeout, throttle, TailOptions{Backpressure: true}, nil, NewMetrics(nil), gokitlog.NewNopLogger())
+		defer tailer.close()
+
+		// let the response channel fill up before reading.
+		time.Sleep(10 * throttle)
+
+		responses, err := readFromTailer(tailer, total)
+		require.NoError(t, err)
+		assert.Equal(t, total, countEntriesInStreams(flattenStreamsFromResponses(responses)))
+		for _, response := range responses {
+			assert.Empty(t, response.DroppedEntries)
+		}
+	})
+
+	t.Run("entries dropped by ingesters are reported", func(t *testing.T) {
+		resp := mockTailResponse(mockStream(1, 1))
+		resp.DroppedStreams = []*logproto.DroppedStream{{From: time.Unix(0, 1), To: time.Unix(0, 2), Labels: `{type="test"}`}}
+		tailClient := newTailClientMock().mockRecvWithTrigger(resp)
+
+		tailer := newTailer(0, map[string]logproto.Querier_TailClient{"test": tailClient}, mockStreamIterator(0, 0), tailDisconnectedIngesters, timeout, throttle, TailOptions{Backpressure: true}, nil, NewMetrics(nil), gokitlog.NewNopLogger())
+		defer tailer.close()
+		tailClient.triggerRecv()
+
+		responses, err := readFromTailer(tailer, 1)
+		require.NoError(t, err)
+		require.Len(t, responses, 1)
+		assert.Equal(t, []loghttp_legacy.DroppedEntry{{Timestamp: time.Unix(0, 1), Labels: `{type="test"}`}}, responses[0].DroppedEntries)
+		assert.Equal(t, int64(0), tailer.bufferedEntries.Load())
+	})
+}
+
+func TestTailResumer(t *testing.T) {
+	entries := func(ts ...int64) []logproto.Entry {
+		var res []logproto.Entry
+		for _, n := range ts {
+			res = append(res, logproto.Entry{Timestamp: time.Unix(0, n), Line: fmt.Sprint(n)})
+		}
+		return res
+	}
+
+	t.Run("new tail", func(t *testing.T) {
+		r := newTailResumer(time.Unix(0, 1), nil)
+
+		resp := &loghttp_legacy.TailResponse{Streams: []logproto.Stream{{Labels: `{app="foo"}`, Entries: entries(1, 2, 2)}}}
+		require.True(t, r.filter(resp))
+		require.Equal(t, entries(1, 2, 2), resp.Streams[0].Entries)
+		require.Equal(t, "2-2", r.position.String())
+	})
+
+	t.Run("resumed tail skips the entries already received", func(t *testing.T) {
+		token, err := loghttp.ParseTailResumeToken("2-2")
+		require.NoError(t, err)
+		r := newTailResumer(token.Timestamp, &token)
+
+		resp := &loghttp_legacy.TailResponse{Streams: []logproto.Stream{
+			{Labels: `{app="foo"}`, Entries: entries(1, 2, 2)},
+			{Labels: `{app="bar"}`, Entries: entries(2, 3)},
+		}}
+		require.True(t, r.filter(resp))
+		require.Equal(t, []logproto.Stream{{Labels: `{app="bar"}`, Entries: entries(2, 3)}}, resp.Streams)
+		require.Equal(t, "3-1", r.position.String())
+
+		// nothing left to send
+		resp = &loghttp_legacy.TailResponse{Streams: []logproto.Stream{{Labels: `{app="foo"}`, Entries: entries(1)}}}
+		require.False(t, r.filter(resp))
+	})
+}
+
+func TestResumedHistoryIterator(t *testing.T) {
+	// 2 entries share each timestamp, and 3 entries the last one.
+	var stored []logproto.Entry
+	for i := 0; i < 10; i++ {
+		stored = append(stored, logproto.Entry{Timestamp: time.Unix(0, int64(i/2)), Line: fmt.Sprint(i)})
+	}
+	stored[9].Timestamp = time.Unix(0, 4)
+	stored[8].Timestamp = time.Unix(0, 4)
+	stored[7].Timestamp = time.Unix(0, 4)
+
+	for _, limit := range []uint32{1, 2, 3, 4, 100} {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			var pages int
+			it := newResumedHistoryIterator(time.Unix(0, 0), limit, func(start time.Time) ([]logproto.Stream, error) {
+				pages++
+				var page []logproto.Entry
+				for _, e := range stored {
+					if !e.Timestamp.Before(start) && uint32(len(page)) < limit {
+						page = append(page, e)
+					}
+				}
+				return []logproto.Stream{{Labels: `{app="foo"}`, Entries: page}}, nil
+			})
+
+			var got []logproto.Entry
+			for it.Next() {
+				got = append(got, it.At())
+			}
+			require.NoError(t, it.Err())
+
+			if limit < 3 {
+				// The entries sharing a timestamp beyond the limit are skipped rather than paging forever.
+				require.Subset(t, stored, got)
+				require.Less(t, len(got), len(stored))
+				return
+			}
+			require.Equal(t, stored, got)
+			if limit < uint32(len(stored)) {
+				require.Greater(t, pages, 1)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		it := newResumedHistoryIterator(time.Unix(0, 0), 10, func(time.Time) ([]logproto.Stream, error) {
+			return nil, errors.New("store unavailable")
+		})
+		require.False(t, it.Next())
+		require.EqualError(t, it.Err(), "store unavailable")
+	})
+}
+
+func TestTailerRateLimit(t *testing.T) {
+	tailDisconnectedIngesters := func([]string) (map[string]logproto.Querier_TailClient, error) {
+		return map[string]logproto.Querier_TailClient{}, nil
+	}
+
+	t.Run("closing the Tailer stops waiting for the rate limiter", func(t *testing.T) {
+		limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+		tailer := newTailer(0, map[string]logproto.Querier_TailClient{}, mockStreamIterator(0, 0), tailDisconnectedIngesters, timeout, throttle, TailOptions{Backpressure: true}, limiter, NewMetrics(nil), gokitlog.NewNopLogger())
+		require.True(t, limiter.Allow())
+
+		done := make(chan error)
+		go func() {
+			done <- tailer.waitRateLimit(1)
+		}()
+		require.NoError(t, tailer.close())
+
+		select {
+		case err := <-done:
+			require.Error(t, err)
+		case <-time.After(timeout):
+			t.Fatal("waitRateLimit did not return once the Tailer was closed")
+		}
+	})
+
+	t.Run("responses larger than the burst are fully charged", func(t *testing.T) {
+		limiter := rate.NewLimiter(rate.Limit(1000), 100)
+		tailer := newTailer(0, map[string]logproto.Querier_TailClient{}, mockStreamIterator(0, 0), tailDisconnectedIngesters, timeout, throttle, TailOptions{Backpressure: true}, limiter, NewMetrics(nil), gokitlog.NewNopLogger())
+		defer tailer.close()
+
+		start := time.Now()
+		require.NoError(t, tailer.waitRateLimit(300))
+		// the burst is available right away, the remaining 200 bytes take 200ms at 1000 bytes per second.
+		require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	})
+
+	t.Run("tails without backpressure are not rate limited", func(t *testing.T) {
+		limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+		tailer := newTailer(0, map[string]logproto.Querier_TailClient{}, mockStreamIterator(1, 5), tailDisconnectedIngesters, timeout, throttle, TailOptions{}, limiter, NewMetrics(nil), gokitlog.NewNopLogger())
+		defer tailer.close()
+
+		responses, err := readFromTailer(tailer, 5)
+		require.NoError(t, err)
+		require.Equal(t, 5, countEntriesInStreams(flattenStreamsFromResponses(responses)))
+		for _, response := range responses {
+			require.Empty(t, response.DroppedEntries)
+		}
+	})
+}
+
+func readFromTailer(tailer *Tailer, maxEntries int) ([]*loghttp_legacy.TailResponse, error) {
+	responses := make([]*loghttp_legacy.TailResponse, 0)
 	entriesCount := 0
 
 	// Ensure we do not wait indefinitely
@@ -448,7 +629,7 @@ func countEntriesInStreams(streams []logproto.Stream) int {
 // to abstract away implementation details in the Tailer when testing for the output
 // regardless how the responses have been generated (ie. multiple entries grouped
 // into the same stream)
-func flattenStreamsFromResponses(responses []*loghttp.TailResponse) []logproto.Stream {
+func flattenStreamsFromResponses(responses []*loghttp_legacy.TailResponse) []logproto.Stream {
 	result := make([]logproto.Stream, 0)
 
 	for _, response := range responses {
@@ -0,0 +1,208 @@
+package querier
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	loghttp_legacy "github.com/grafana/loki/v3/pkg/loghttp/legacy"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+	"github.com/grafana/loki/v3/pkg/util/marshal"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// tailV2Sink delivers the responses of a tail v2 request to the client.
+type tailV2Sink interface {
+	// send delivers a response, the resume token being the position of the client once it received it.
+	send(resp *loghttp_legacy.TailResponse, resumeToken string) error
+	// keepAlive is called when no response was sent for a while.
+	keepAlive() error
+}
+
+// tailV2 starts the Tailer of a tail v2 request.
+func (q *QuerierAPI) tailV2(ctx context.Context, req *logproto.TailRequest, resume *loghttp.TailResumeToken, categorizeLabels bool) (*Tailer, error) {
+	return q.querier.Tail(ctx, req, TailOptions{
+		CategorizeLabels: categorizeLabels,
+		Backpressure:     true,
+		Resume:           resume != nil,
+	})
+}
+
+// streamTailV2 sends the responses of the Tailer to the sink until the tail ends, the context is done or the sink
+// fails. It returns the error which ended the tail, and the error of the sink.
+func streamTailV2(ctx context.Context, tailer *Tailer, req *logproto.TailRequest, resume *loghttp.TailResumeToken, sink tailV2Sink, logger log.Logger) (tailErr error, sinkErr error) {
+	tenantIDs, _ := tenant.TenantIDs(ctx)
+	level.Info(logger).Log("msg", "starting to tail logs", "tenant", tenant.JoinTenantIDs(tenantIDs), "selectors", req.Query, "resumed", resume != nil)
+	defer func() {
+		level.Info(logger).Log("msg", "ended tailing logs", "tenant", tenant.JoinTenantIDs(tenantIDs), "selectors", req.Query)
+	}()
+
+	ticker := time.NewTicker(sseKeepAlivePeriod)
+	defer ticker.Stop()
+
+	resumer := newTailResumer(req.Start, resume)
+	responseChan := tailer.getResponseChan()
+	closeErrChan := tailer.getCloseErrorChan()
+	for {
+		var err error
+		select {
+		case response := <-responseChan:
+			if !resumer.filter(response) {
+				continue
+			}
+			err = sink.send(response, resumer.position.String())
+		case err := <-closeErrChan:
+			level.Error(logger).Log("msg", "Error from iterator", "err", err)
+			return err, nil
+		case <-ticker.C:
+			// Keep the connection alive through proxies and detect dead clients.
+			err = sink.keepAlive()
+		case <-ctx.Done():
+			return nil, nil
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "Error writing tail event", "err", err)
+			return nil, err
+		}
+	}
+}
+
+// sseTailSink writes the responses of a tail v2 request as server-sent events.
+type sseTailSink struct {
+	w             http.ResponseWriter
+	rc            *http.ResponseController
+	encodingFlags httpreq.EncodingFlags
+}
+
+func (s *sseTailSink) send(resp *loghttp_legacy.TailResponse, resumeToken string) error {
+	if err := marshal.WriteTailEventJSON(*resp, resumeToken, s.w, s.encodingFlags); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseTailSink) keepAlive() error {
+	if _, err := s.w.Write([]byte(": keep-alive\n\n")); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// grpcTailSink sends the responses of a tail v2 request on a gRPC stream.
+type grpcTailSink struct {
+	srv logproto.TailV2_TailServer
+}
+
+func (s *grpcTailSink) send(resp *loghttp_legacy.TailResponse, resumeToken string) error {
+	return s.srv.Send(tailV2Response(resp, resumeToken))
+}
+
+// keepAlive is a no-op, gRPC connections are kept alive by the transport.
+func (s *grpcTailSink) keepAlive() error {
+	return nil
+}
+
+func tailV2Response(resp *loghttp_legacy.TailResponse, resumeToken string) *logproto.TailV2Response {
+	out := &logproto.TailV2Response{
+		Streams:     resp.Streams,
+		ResumeToken: resumeToken,
+	}
+	for _, dropped := range resp.DroppedEntries {
+		out.DroppedStreams = append(out.DroppedStreams, &logproto.DroppedStream{
+			From:   dropped.Timestamp,
+			To:     dropped.Timestamp,
+			Labels: dropped.Labels,
+		})
+	}
+	return out
+}
+
+// TailV2Handler is a http.HandlerFunc for tail v2 requests, which stream entries as server-sent events.
+//
+// Unlike TailHandler, the entries are never silently dropped when the client is slow: the querier stops
+// reading from the ingesters until the client catches up, and the entries dropped by ingesters are reported.
+// Each event carries a resume token as ID, which the client sends back in the `resume_token` parameter or the
+// `Last-Event-ID` header to resume tailing after a disconnect.
+func (q *QuerierAPI) TailV2Handler(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+
+	req, resume, err := loghttp.ParseTailV2Query(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	if _, err := tenant.TenantIDs(r.Context()); err != nil {
+		level.Warn(logger).Log("msg", "error getting tenant id", "err", err)
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	encodingFlags := httpreq.ExtractEncodingFlags(r)
+	tailer, err := q.tailV2(r.Context(), req, resume, encodingFlags.Has(httpreq.FlagCategorizeLabels))
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	defer func() {
+		if err := tailer.close(); err != nil {
+			level.Error(logger).Log("msg", "Error closing Tailer", "err", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		level.Error(logger).Log("msg", "Error flushing tail response", "err", err)
+		return
+	}
+
+	sink := &sseTailSink{w: w, rc: rc, encodingFlags: encodingFlags}
+	if tailErr, _ := streamTailV2(r.Context(), tailer, req, resume, sink, logger); tailErr != nil {
+		if err := marshal.WriteTailErrorEvent(tailErr, w); err == nil {
+			_ = rc.Flush()
+		}
+	}
+}
+
+// Tail implements logproto.TailV2Server, streaming the responses of a tail v2 request over gRPC.
+// It has the same semantics as TailV2Handler, the resume token being sent with every response.
+func (q *QuerierAPI) Tail(r *logproto.TailV2Request, srv logproto.TailV2_TailServer) error {
+	ctx := srv.Context()
+	logger := util_log.WithContext(ctx, util_log.Logger)
+
+	req, resume, err := loghttp.ParseTailV2Request(r)
+	if err != nil {
+		return httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	if _, err := tenant.TenantIDs(ctx); err != nil {
+		return httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	tailer, err := q.tailV2(ctx, req, resume, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tailer.close(); err != nil {
+			level.Error(logger).Log("msg", "Error closing Tailer", "err", err)
+		}
+	}()
+
+	tailErr, sinkErr := streamTailV2(ctx, tailer, req, resume, &grpcTailSink{srv: srv}, logger)
+	if tailErr != nil {
+		return tailErr
+	}
+	return sinkErr
+}
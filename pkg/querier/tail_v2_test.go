@@ -0,0 +1,97 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	loghttp_legacy "github.com/grafana/loki/v3/pkg/loghttp/legacy"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+type tailV2ServerMock struct {
+	grpc.ServerStream
+	ctx       context.Context
+	cancel    context.CancelFunc
+	responses []*logproto.TailV2Response
+	entries   int
+	want      int
+}
+
+func (s *tailV2ServerMock) Context() context.Context {
+	return s.ctx
+}
+
+func (s *tailV2ServerMock) Send(resp *logproto.TailV2Response) error {
+	s.responses = append(s.responses, resp)
+	s.entries += countEntriesInStreams(resp.Streams)
+	if s.entries >= s.want {
+		s.cancel()
+	}
+	return nil
+}
+
+func TestQuerierAPI_TailGRPC(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	tailDisconnectedIngesters := func([]string) (map[string]logproto.Querier_TailClient, error) {
+		return map[string]logproto.Querier_TailClient{}, nil
+	}
+	querier := newQuerierMock()
+	querier.On("Tail", mock.Anything, mock.Anything, TailOptions{Backpressure: true, Resume: true}).Return(func(context.Context) *Tailer {
+		return newTailer(0, map[string]logproto.Querier_TailClient{}, mockStreamIterator(1, 3), tailDisconnectedIngesters, timeout, throttle, TailOptions{Backpressure: true, Resume: true}, nil, NewMetrics(nil), log.NewNopLogger())
+	}, nil)
+	api := NewQuerierAPI(mockQuerierConfig(), querier, limits, log.NewNopLogger())
+
+	ctx, cancel := context.WithTimeout(user.InjectOrgID(context.Background(), "test"), timeout)
+	defer cancel()
+	srv := &tailV2ServerMock{ctx: ctx, cancel: cancel, want: 2}
+
+	// The first entry was already received by the client.
+	err = api.Tail(&logproto.TailV2Request{
+		Request:     &logproto.TailRequest{Query: `{type="test"}`, Start: time.Unix(1, 0)},
+		ResumeToken: "1000000000-1",
+	}, srv)
+	require.NoError(t, err)
+	require.Equal(t, 2, srv.entries)
+	require.Equal(t, time.Unix(2, 0), srv.responses[0].Streams[0].Entries[0].Timestamp)
+	require.Equal(t, "3000000000-1", srv.responses[len(srv.responses)-1].ResumeToken)
+}
+
+func TestQuerierAPI_TailGRPCValidation(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+	api := NewQuerierAPI(mockQuerierConfig(), newQuerierMock(), limits, log.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(user.InjectOrgID(context.Background(), "test"))
+	defer cancel()
+	srv := &tailV2ServerMock{ctx: ctx, cancel: cancel}
+
+	for _, req := range []*logproto.TailV2Request{
+		{},
+		{Request: &logproto.TailRequest{Query: `{type="test"}`, DelayFor: 10}},
+		{Request: &logproto.TailRequest{Query: `{type="test"}`}, ResumeToken: "1000"},
+	} {
+		require.Error(t, api.Tail(req, srv))
+	}
+}
+
+func TestTailV2Response(t *testing.T) {
+	resp := tailV2Response(&loghttp_legacy.TailResponse{
+		Streams:        []logproto.Stream{mockStream(1, 1)},
+		DroppedEntries: []loghttp_legacy.DroppedEntry{{Timestamp: time.Unix(0, 1), Labels: `{type="test"}`}},
+	}, "1-1")
+	require.Equal(t, &logproto.TailV2Response{
+		Streams:        []logproto.Stream{mockStream(1, 1)},
+		DroppedStreams: []*logproto.DroppedStream{{From: time.Unix(0, 1), To: time.Unix(0, 1), Labels: `{type="test"}`}},
+		ResumeToken:    "1-1",
+	}, resp)
+}
@@ -44,6 +44,10 @@ func (ui QueueIndex) ReuseLastIndex() QueueIndex {
 type Limits interface {
 	// MaxConsumers returns the max consumers to use per tenant or 0 to allow all consumers to consume from the queue.
 	MaxConsumers(user string, allConsumers int) int
+
+	// QueueWeight returns the weight of the tenant's queue relative to the queues of other tenants.
+	// Tenants with a higher weight are dequeued more often. Values lower than 1 are treated as 1.
+	QueueWeight(user string) int
 }
 
 // Request stored into the queue.
@@ -83,6 +87,16 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, lim
 	return q
 }
 
+// SetSubQueueWeights configures the weights of the sub-queues directly below the tenant queues.
+// Requests are dequeued from these sub-queues in proportion to their weights, for example
+// to give interactive queries precedence over rule evaluations of the same tenant.
+// Sub-queues without a weight have a weight of 1. It must be called before any request is enqueued.
+func (q *RequestQueue) SetSubQueueWeights(weights map[string]int) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.queues.subQueueWeights = weights
+}
+
 // Enqueue puts the request into the queue.
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
 func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, successFn func()) error {
@@ -342,6 +342,10 @@ func (l *mockLimits) MaxConsumers(_ string, _ int) int {
 	return l.maxConsumer
 }
 
+func (l *mockLimits) QueueWeight(_ string) int {
+	return 1
+}
+
 func Test_Queue_DequeueMany(t *testing.T) {
 	tenantsQueueMaxSize := 100
 	tests := map[string]struct {
@@ -54,6 +54,9 @@ type consumer struct {
 
 	// When the last connection has been unregistered.
 	disconnectedAt time.Time
+
+	// Number of times the consumer iterated over all tenant queues.
+	round int
 }
 
 // This struct holds tenant queues for pending requests. It also keeps track of connected consumers,
@@ -74,6 +77,9 @@ type tenantQueues struct {
 	// sortedConsumer list of consumer IDs, used when creating per-user shard.
 	sortedConsumers []string
 
+	// Weights of the sub-queues directly below the tenant queues.
+	subQueueWeights map[string]int
+
 	limits Limits
 }
 
@@ -101,6 +107,9 @@ type tenantQueue struct {
 	// Seed for shuffle sharding of consumers. This seed is based on userID only and is therefore consistent
 	// between different frontends.
 	seed int64
+
+	// Weight of the queue relative to the queues of other tenants.
+	weight int
 }
 
 func newTenantQueues(maxUserQueueSize int, forgetDelay time.Duration, limits Limits) *tenantQueues {
@@ -144,9 +153,13 @@ func (q *tenantQueues) getOrAddQueue(tenantID string, path []string) (Queue, err
 			seed: util.ShuffleShardSeed(tenantID, ""),
 		}
 		uq.TreeQueue = newTreeQueue(q.maxUserQueueSize, tenantID)
+		uq.TreeQueue.weights = q.subQueueWeights
 		q.mapping.Put(tenantID, uq)
 	}
 
+	// For multi-tenant queries the smallest weight of the tenants is used.
+	uq.weight = max(1, validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, q.limits.QueueWeight))
+
 	consumersToSelect := validation.SmallestPositiveNonZeroIntPerTenant(
 		tenantIDs,
 		func(tenantID string) int {
@@ -167,6 +180,9 @@ func (q *tenantQueues) getOrAddQueue(tenantID string, path []string) (Queue, err
 // Finds next queue for the consumer. To support fair scheduling between users, client is expected
 // to pass last user index returned by this function as argument. Is there was no previous
 // last user index, use -1.
+// If tenants have different weights, the queues of tenants with a lower weight than the highest
+// weight are skipped in some of the rounds of the consumer over all queues (interleaved weighted round-robin),
+// unless there is no other queue for the consumer.
 func (q *tenantQueues) getNextQueueForConsumer(lastUserIndex QueueIndex, consumerID string) (Queue, string, QueueIndex) {
 	uid := lastUserIndex
 
@@ -177,15 +193,20 @@ func (q *tenantQueues) getNextQueueForConsumer(lastUserIndex QueueIndex, consume
 
 	// Ensure the consumer is not shutting down. If the consumer is shutting down, we shouldn't forward
 	// any more queries to it.
-	if info := q.consumers[consumerID]; info == nil || info.shuttingDown {
+	info := q.consumers[consumerID]
+	if info == nil || info.shuttingDown {
 		return nil, "", uid
 	}
 
+	maxWeight := q.maxWeight()
+	var skipped *tenantQueue
+
 	maxIters := len(q.mapping.keys) + 1
 	for iters := 0; iters < maxIters; iters++ {
 		tq, err := q.mapping.GetNext(uid)
 		if err == ErrOutOfBounds {
 			uid = StartIndex
+			info.round++
 			continue
 		}
 		if tq == nil {
@@ -199,12 +220,34 @@ func (q *tenantQueues) getNextQueueForConsumer(lastUserIndex QueueIndex, consume
 				continue
 			}
 		}
+
+		if info.round%maxWeight >= tq.weight {
+			// The tenant already had its share of this round.
+			if skipped == nil {
+				skipped = tq
+			}
+			continue
+		}
 		return tq, tq.name, uid
 	}
 
+	if skipped != nil {
+		return skipped, skipped.name, skipped.pos
+	}
 	return nil, "", uid
 }
 
+// maxWeight returns the highest weight of all tenant queues.
+func (q *tenantQueues) maxWeight() int {
+	maxWeight := 1
+	for _, key := range q.mapping.keys {
+		if tq := q.mapping.GetByKey(key); tq != nil && tq.weight > maxWeight {
+			maxWeight = tq.weight
+		}
+	}
+	return maxWeight
+}
+
 func (q *tenantQueues) addConsumerToConnection(consumerID string) {
 	info := q.consumers[consumerID]
 	if info != nil {
@@ -79,6 +79,20 @@ func TestQueues(t *testing.T) {
 	assert.Nil(t, q)
 }
 
+func TestQueuesWithWeights(t *testing.T) {
+	uq := newTenantQueues(0, 0, &mockQueueLimits{weights: map[string]int{"one": 2}})
+	uq.addConsumerToConnection("consumer-1")
+
+	// Add queues: [one two], where one has twice the weight of two
+	qOne := getOrAdd(t, uq, "one")
+	qTwo := getOrAdd(t, uq, "two")
+	lastUserIndex := confirmOrderForConsumer(t, uq, "consumer-1", -1, qOne, qTwo, qOne, qOne, qTwo, qOne, qOne, qTwo)
+
+	// a tenant with a lower weight is not skipped if there are no other queues
+	uq.deleteQueue("one")
+	confirmOrderForConsumer(t, uq, "consumer-1", lastUserIndex, qTwo, qTwo, qTwo)
+}
+
 func TestQueuesOnTerminatingConsumer(t *testing.T) {
 	uq := newTenantQueues(0, 0, noQueueLimits)
 	assert.NotNil(t, uq)
@@ -538,8 +552,13 @@ func TestShuffleConsumersCorrectness(t *testing.T) {
 
 type mockQueueLimits struct {
 	maxConsumers int
+	weights      map[string]int
 }
 
 func (l *mockQueueLimits) MaxConsumers(_ string, _ int) int {
 	return l.maxConsumers
 }
+
+func (l *mockQueueLimits) QueueWeight(tenant string) int {
+	return l.weights[tenant]
+}
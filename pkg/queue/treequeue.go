@@ -7,6 +7,11 @@ import (
 
 type QueuePath []string //nolint:revive
 
+// BackgroundQueueName is the name of the sub-queue that holds low priority requests,
+// such as asynchronous queries. Requests from this sub-queue are only dequeued if
+// the local queue and all other sub-queues of the same parent are empty.
+const BackgroundQueueName = "background"
+
 // TreeQueue is an hierarchical queue implementation where each sub-queue
 // has the same guarantees to be chosen from.
 // Each queue has also a local queue, which gets chosen with equal preference as the sub-queues.
@@ -23,6 +28,10 @@ type TreeQueue struct {
 	name string
 	// maximum queue size of the local queue
 	size int
+	// weights of the sub-queues, if nil all sub-queues have the same weight
+	weights map[string]int
+	// current weight of this queue in the smooth weighted round-robin of its parent
+	currentWeight int
 }
 
 // newTreeQueue creates a new TreeQueue instance
@@ -79,6 +88,10 @@ func (q *TreeQueue) Dequeue() Request {
 		return nil
 	}
 
+	if q.weights != nil {
+		return q.dequeueWeighted()
+	}
+
 	maxIter := len(q.mapping.keys) + 1
 	for iters := 0; iters < maxIter; iters++ {
 		if q.current == StartIndexWithLocalQueue {
@@ -98,6 +111,9 @@ func (q *TreeQueue) Dequeue() Request {
 		}
 		if subq != nil {
 			q.current = subq.pos
+			if subq.name == BackgroundQueueName {
+				continue
+			}
 			item := subq.Dequeue()
 			if item != nil {
 				if subq.Len() == 0 {
@@ -107,6 +123,53 @@ func (q *TreeQueue) Dequeue() Request {
 			}
 		}
 	}
+
+	// only serve the background queue if no other queue has items
+	if background := q.mapping.GetByKey(BackgroundQueueName); background != nil {
+		item = background.Dequeue()
+		if background.Len() == 0 {
+			q.mapping.Remove(background.name)
+		}
+		return item
+	}
+	return nil
+}
+
+// dequeueWeighted dequeues from the local queue first and then from the sub-queues
+// using smooth weighted round-robin, so that each sub-queue is chosen in proportion
+// to its weight, while requests of the sub-queues are still interleaved.
+func (q *TreeQueue) dequeueWeighted() Request {
+	if len(q.ch) > 0 {
+		return <-q.ch
+	}
+
+	for q.mapping.Len() > 0 {
+		var (
+			selected *TreeQueue
+			total    int
+		)
+		for _, key := range q.mapping.keys {
+			subq := q.mapping.GetByKey(key)
+			if subq == nil {
+				continue
+			}
+			weight := max(1, q.weights[subq.name])
+			total += weight
+			subq.currentWeight += weight
+			if selected == nil || subq.currentWeight > selected.currentWeight {
+				selected = subq
+			}
+		}
+		selected.currentWeight -= total
+
+		item := selected.Dequeue()
+		if selected.Len() == 0 {
+			q.mapping.Remove(selected.name)
+		}
+		if item != nil {
+			return item
+		}
+	}
 	return nil
 }
 
@@ -169,3 +169,51 @@ func TestTreeQueue(t *testing.T) {
 		require.Nil(t, q.mapping.GetByKey("b"))
 	})
 }
+
+func TestTreeQueue_BackgroundQueue(t *testing.T) {
+	q := newTreeQueue(10, "root")
+	q.add(QueuePath{BackgroundQueueName})
+	q.add(QueuePath{"a"})
+
+	q.mapping.GetByKey(BackgroundQueueName).Chan() <- r(900)
+	q.mapping.GetByKey(BackgroundQueueName).Chan() <- r(901)
+	q.mapping.GetByKey("a").Chan() <- r(100)
+	q.mapping.GetByKey("a").Chan() <- r(101)
+	q.Chan() <- r(0)
+
+	items := make([]int, 0, q.Len())
+	for q.Len() > 0 {
+		r := q.Dequeue()
+		if r == nil {
+			continue
+		}
+		items = append(items, r.(*dummyRequest).id)
+	}
+	require.Equal(t, []int{0, 100, 101, 900, 901}, items)
+	require.Nil(t, q.mapping.GetByKey(BackgroundQueueName))
+}
+
+func TestTreeQueue_Weights(t *testing.T) {
+	q := newTreeQueue(10, "root")
+	q.weights = map[string]int{"interactive": 3, "rule": 1}
+	q.add(QueuePath{"interactive"})
+	q.add(QueuePath{"rule"})
+
+	for i := 0; i < 6; i++ {
+		q.mapping.GetByKey("interactive").Chan() <- r(100 + i)
+	}
+	for i := 0; i < 4; i++ {
+		q.mapping.GetByKey("rule").Chan() <- r(200 + i)
+	}
+
+	items := make([]int, 0, q.Len())
+	for q.Len() > 0 {
+		r := q.Dequeue()
+		if r == nil {
+			continue
+		}
+		items = append(items, r.(*dummyRequest).id)
+	}
+	require.Equal(t, []int{100, 101, 200, 102, 103, 104, 201, 105, 202, 203}, items)
+	require.Equal(t, 0, q.mapping.Len())
+}
@@ -11,6 +11,9 @@ type Limits interface {
 
 	// MaxQueryCapacity returns how much of the available query capacity can be used by this user.
 	MaxQueryCapacity(user string) float64
+
+	// QueryQueueWeight returns the weight of the tenant's queue relative to the queues of other tenants.
+	QueryQueueWeight(user string) int
 }
 
 func NewQueueLimits(limits Limits) *QueueLimits {
@@ -44,3 +47,12 @@ func (c *QueueLimits) MaxConsumers(tenantID string, allConsumers int) int {
 
 	return res
 }
+
+// QueueWeight returns the weight of the tenant's queue relative to the queues of other tenants.
+// 1 is returned when no limits are applied.
+func (c *QueueLimits) QueueWeight(tenantID string) int {
+	if c == nil || c.limits == nil {
+		return 1
+	}
+	return c.limits.QueryQueueWeight(tenantID)
+}
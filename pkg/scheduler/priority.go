@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/loki/v3/pkg/scheduler/schedulerpb"
+	lokihttpreq "github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+// priorityClasses holds the weights of the configured priority classes and the class
+// of requests without a valid priority class.
+type priorityClasses struct {
+	weights      map[string]int
+	defaultClass string
+}
+
+// parsePriorityClasses parses priority classes in the format <name>:<weight>.
+// If defaultClass is empty, the first class is the default class.
+func parsePriorityClasses(classes []string, defaultClass string) (*priorityClasses, error) {
+	if len(classes) == 0 {
+		if defaultClass != "" {
+			return nil, fmt.Errorf("default priority class %q requires priority classes to be configured", defaultClass)
+		}
+		return nil, nil
+	}
+
+	pc := &priorityClasses{
+		weights:      make(map[string]int, len(classes)),
+		defaultClass: defaultClass,
+	}
+	for _, class := range classes {
+		name, weight, ok := strings.Cut(class, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid priority class %q: expected format <name>:<weight>", class)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 1 {
+			return nil, fmt.Errorf("invalid weight of priority class %q: must be a positive integer", name)
+		}
+		if _, ok := pc.weights[name]; ok {
+			return nil, fmt.Errorf("duplicate priority class %q", name)
+		}
+		pc.weights[name] = w
+		if pc.defaultClass == "" {
+			pc.defaultClass = name
+		}
+	}
+	if _, ok := pc.weights[pc.defaultClass]; !ok {
+		return nil, fmt.Errorf("default priority class %q is not one of the priority classes", pc.defaultClass)
+	}
+	return pc, nil
+}
+
+// classFor returns the priority class of the request, which is propagated by the query frontend
+// in the X-Loki-Query-Priority header. Requests without a known class get the default class.
+func (pc *priorityClasses) classFor(msg *schedulerpb.FrontendToScheduler) string {
+	var class string
+	if r := msg.GetHttpRequest(); r != nil {
+		key := textproto.CanonicalMIMEHeaderKey(lokihttpreq.LokiQueryPriorityHeader)
+		for _, h := range r.Headers {
+			if textproto.CanonicalMIMEHeaderKey(h.Key) == key && len(h.Values) > 0 {
+				class = h.Values[0]
+				break
+			}
+		}
+	}
+	if r := msg.GetQueryRequest(); r != nil && class == "" {
+		class = r.Metadata[lokihttpreq.LokiQueryPriorityHeader]
+	}
+
+	if _, ok := pc.weights[class]; !ok {
+		return pc.defaultClass
+	}
+	return class
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/scheduler/schedulerpb"
+	lokihttpreq "github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+func TestParsePriorityClasses(t *testing.T) {
+	pc, err := parsePriorityClasses(nil, "")
+	require.NoError(t, err)
+	require.Nil(t, pc)
+
+	pc, err = parsePriorityClasses([]string{"interactive:8", "dashboard:4", "rule:2", "async:1"}, "")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"interactive": 8, "dashboard": 4, "rule": 2, "async": 1}, pc.weights)
+	require.Equal(t, "interactive", pc.defaultClass)
+
+	pc, err = parsePriorityClasses([]string{"interactive:8", "dashboard:4"}, "dashboard")
+	require.NoError(t, err)
+	require.Equal(t, "dashboard", pc.defaultClass)
+
+	for _, tc := range []struct {
+		classes      []string
+		defaultClass string
+	}{
+		{classes: nil, defaultClass: "interactive"},
+		{classes: []string{"interactive"}},
+		{classes: []string{":1"}},
+		{classes: []string{"interactive:0"}},
+		{classes: []string{"interactive:high"}},
+		{classes: []string{"interactive:1", "interactive:2"}},
+		{classes: []string{"interactive:1"}, defaultClass: "rule"},
+	} {
+		_, err := parsePriorityClasses(tc.classes, tc.defaultClass)
+		require.Error(t, err, "classes %v, default class %q", tc.classes, tc.defaultClass)
+	}
+}
+
+func TestPriorityClasses_ClassFor(t *testing.T) {
+	pc, err := parsePriorityClasses([]string{"interactive:8", "rule:2"}, "")
+	require.NoError(t, err)
+
+	httpRequest := func(class string) *schedulerpb.FrontendToScheduler {
+		return &schedulerpb.FrontendToScheduler{
+			Request: &schedulerpb.FrontendToScheduler_HttpRequest{
+				HttpRequest: &httpgrpc.HTTPRequest{
+					Headers: []*httpgrpc.Header{{Key: "X-Loki-Query-Priority", Values: []string{class}}},
+				},
+			},
+		}
+	}
+	queryRequest := func(class string) *schedulerpb.FrontendToScheduler {
+		return &schedulerpb.FrontendToScheduler{
+			Request: &schedulerpb.FrontendToScheduler_QueryRequest{
+				QueryRequest: &queryrange.QueryRequest{
+					Metadata: map[string]string{lokihttpreq.LokiQueryPriorityHeader: class},
+				},
+			},
+		}
+	}
+
+	require.Equal(t, "rule", pc.classFor(httpRequest("rule")))
+	require.Equal(t, "rule", pc.classFor(queryRequest("rule")))
+	require.Equal(t, "interactive", pc.classFor(httpRequest("unknown")))
+	require.Equal(t, "interactive", pc.classFor(queryRequest("")))
+	require.Equal(t, "interactive", pc.classFor(&schedulerpb.FrontendToScheduler{}))
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/grpcclient"
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/middleware"
@@ -59,8 +60,9 @@ type Scheduler struct {
 	connectedFrontendsMu sync.Mutex
 	connectedFrontends   map[string]*connectedFrontend
 
-	requestQueue *queue.RequestQueue
-	activeUsers  *util.ActiveUsersCleanupService
+	requestQueue    *queue.RequestQueue
+	activeUsers     *util.ActiveUsersCleanupService
+	priorityClasses *priorityClasses
 
 	pendingRequestsMu sync.Mutex
 	pendingRequests   map[requestKey]*schedulerRequest // Request is kept in this map even after being dispatched to querier. It can still be canceled at that time.
@@ -76,6 +78,7 @@ type Scheduler struct {
 	connectedQuerierClients  prometheus.GaugeFunc
 	connectedFrontendClients prometheus.GaugeFunc
 	queueDuration            prometheus.Histogram
+	priorityClassQueueTime   *prometheus.HistogramVec
 	schedulerRunning         prometheus.Gauge
 	inflightRequests         prometheus.Summary
 
@@ -102,10 +105,12 @@ type connectedFrontend struct {
 }
 
 type Config struct {
-	MaxOutstandingPerTenant int               `yaml:"max_outstanding_requests_per_tenant"`
-	MaxQueueHierarchyLevels int               `yaml:"max_queue_hierarchy_levels"`
-	QuerierForgetDelay      time.Duration     `yaml:"querier_forget_delay"`
-	GRPCClientConfig        grpcclient.Config `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
+	MaxOutstandingPerTenant int                    `yaml:"max_outstanding_requests_per_tenant"`
+	MaxQueueHierarchyLevels int                    `yaml:"max_queue_hierarchy_levels"`
+	QuerierForgetDelay      time.Duration          `yaml:"querier_forget_delay"`
+	PriorityClasses         flagext.StringSliceCSV `yaml:"priority_classes"`
+	DefaultPriorityClass    string                 `yaml:"default_priority_class"`
+	GRPCClientConfig        grpcclient.Config      `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	// Schedulers ring
 	UseSchedulerRing bool                `yaml:"use_scheduler_ring"`
 	SchedulerRing    lokiring.RingConfig `yaml:"scheduler_ring,omitempty" doc:"description=The hash ring configuration. This option is required only if use_scheduler_ring is true."`
@@ -115,6 +120,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 32000, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.IntVar(&cfg.MaxQueueHierarchyLevels, "query-scheduler.max-queue-hierarchy-levels", 3, "Maximum number of levels of nesting of hierarchical queues. 0 means that hierarchical queues are disabled.")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
+	f.Var(&cfg.PriorityClasses, "query-scheduler.priority-classes", "Comma separated list of priority classes with their weights in the format <name>:<weight>, for example interactive:8,dashboard:4,rule:2,async:1. The priority class of a query is set with the X-Loki-Query-Priority header. The requests of each tenant are dequeued from the priority classes in proportion to their weights. Remote rule evaluations use the class rule and asynchronous queries use the class async. If empty, all requests have the same priority.")
+	f.StringVar(&cfg.DefaultPriorityClass, "query-scheduler.default-priority-class", "", "Priority class of requests without or with an unknown priority class. If empty, the first of the priority classes is used.")
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
 	f.BoolVar(&cfg.UseSchedulerRing, "query-scheduler.use-scheduler-ring", false, "Set to true to have the query schedulers create and place themselves in a ring. If no frontend_address or scheduler_address are present anywhere else in the configuration, Loki will toggle this value to true.")
 
@@ -135,6 +142,9 @@ func (cfg *Config) Validate() error {
 	if cfg.SchedulerRing.ReplicationFactor != ReplicationFactor {
 		return errors.New("Replication factor must not be changed as it will not take effect")
 	}
+	if _, err := parsePriorityClasses(cfg.PriorityClasses, cfg.DefaultPriorityClass); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -148,6 +158,11 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		}
 	}
 
+	classes, err := parsePriorityClasses(cfg.PriorityClasses, cfg.DefaultPriorityClass)
+	if err != nil {
+		return nil, err
+	}
+
 	queueMetrics := queue.NewMetrics(registerer, metricsNamespace, "query_scheduler")
 	s := &Scheduler{
 		cfg:    cfg,
@@ -159,6 +174,10 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		queueMetrics:       queueMetrics,
 		ringManager:        ringManager,
 		requestQueue:       queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, limits.NewQueueLimits(schedulerLimits), queueMetrics),
+		priorityClasses:    classes,
+	}
+	if classes != nil {
+		s.requestQueue.SetSubQueueWeights(classes.weights)
 	}
 
 	s.queueDuration = promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
@@ -167,6 +186,12 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		Help:      "Time spend by requests in queue before getting picked up by a querier.",
 		Buckets:   prometheus.DefBuckets,
 	})
+	s.priorityClassQueueTime = promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_scheduler_priority_class_queue_duration_seconds",
+		Help:      "Time spend by requests in queue before getting picked up by a querier, by priority class.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"class"})
 	s.connectedQuerierClients = promauto.With(registerer).NewGaugeFunc(prometheus.GaugeOpts{
 		Namespace: metricsNamespace,
 		Name:      "query_scheduler_connected_querier_clients",
@@ -202,7 +227,6 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		s.shouldRun.Store(true)
 	}
 
-	var err error
 	s.subservices, err = services.NewManager(svcs...)
 	if err != nil {
 		return nil, err
@@ -223,6 +247,7 @@ type schedulerRequest struct {
 	request         *httpgrpc.HTTPRequest
 	queryRequest    *queryrange.QueryRequest
 	statsEnabled    bool
+	priorityClass   string
 
 	queueTime time.Time
 
@@ -392,6 +417,12 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		}
 	}
 
+	// The priority class is the first level of the queue hierarchy of each tenant.
+	if s.priorityClasses != nil {
+		req.priorityClass = s.priorityClasses.classFor(msg)
+		queuePath = append([]string{req.priorityClass}, queuePath...)
+	}
+
 	s.activeUsers.UpdateUserTimestamp(req.tenantID, now)
 	return s.requestQueue.Enqueue(req.tenantID, queuePath, req, func() {
 		shouldCancel = false
@@ -446,6 +477,9 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 
 		reqQueueTime := time.Since(r.queueTime)
 		s.queueDuration.Observe(reqQueueTime.Seconds())
+		if r.priorityClass != "" {
+			s.priorityClassQueueTime.WithLabelValues(r.priorityClass).Observe(reqQueueTime.Seconds())
+		}
 		r.queueSpan.Finish()
 
 		// Add HTTP header to the request containing the query queue time
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"slices"
 	"sort"
 	"time"
 
@@ -101,6 +102,8 @@ type batchChunkIterator struct {
 	start, end time.Time
 	direction  logproto.Direction
 	next       chan *chunkBatch
+	// currBatch is the batch being iterated, whose chunks are held in memory.
+	currBatch *chunkBatch
 }
 
 // newBatchChunkIterator creates a new batch iterator with the given batchSize.
@@ -119,6 +122,10 @@ func newBatchChunkIterator(
 	// and only used for upstream compatibility; therefore remove it.
 	// The same applies to the sharding label which is injected by the cortex storage code.
 	matchers = removeMatchersByName(matchers, labels.MetricName, astmapper.ShardLabel)
+	// the chunk refs of some indexes do not have the labels of their series, so the rollup series are also filtered once their chunks are loaded.
+	if !selectsRollups(matchers) {
+		matchers = append(slices.Clip(matchers), labels.MustNewMatcher(labels.MatchEqual, constants.RollupLabel, ""))
+	}
 	res := &batchChunkIterator{
 		batchSize:     batchSize,
 		schemas:       s,
@@ -150,18 +157,30 @@ func (it *batchChunkIterator) loop() {
 			close(it.next)
 			return
 		}
+		batch := it.nextBatch()
 		select {
 		case <-it.ctx.Done():
+			it.release(batch)
 			close(it.next)
 			return
-		case it.next <- it.nextBatch():
+		case it.next <- batch:
 		}
 	}
 }
 
 func (it *batchChunkIterator) Next() *chunkBatch {
 	it.Start() // Ensure the iterator has started.
-	return <-it.next
+	// the chunks of the previous batch are no longer used once the next batch is requested.
+	it.release(it.currBatch)
+	it.currBatch = <-it.next
+	return it.currBatch
+}
+
+// release records that the chunks of batch are no longer held in memory.
+func (it *batchChunkIterator) release(batch *chunkBatch) {
+	if batch != nil && batch.size > 0 {
+		stats.FromContext(it.ctx).ReleaseBytes(batch.size)
+	}
 }
 
 func (it *batchChunkIterator) nextBatch() (res *chunkBatch) {
@@ -296,18 +315,32 @@ func (it *batchChunkIterator) nextBatch() (res *chunkBatch) {
 	if err != nil {
 		return &chunkBatch{err: err}
 	}
+	var size int64
+	for _, series := range chksBySeries {
+		for _, chunks := range series {
+			for _, c := range chunks {
+				if c.Chunk.Data != nil {
+					size += int64(c.Chunk.Data.Size())
+				}
+			}
+		}
+	}
+	stats.FromContext(it.ctx).RetainBytes(size)
 	return &chunkBatch{
 		chunksBySeries: chksBySeries,
 		err:            err,
 		from:           from,
 		through:        through,
 		nextChunk:      nextChunk,
+		size:           size,
 	}
 }
 
 type chunkBatch struct {
 	chunksBySeries map[model.Fingerprint][][]*LazyChunk
 	err            error
+	// size is the size of the chunks of the batch.
+	size int64
 
 	from, through time.Time
 	nextChunk     *LazyChunk
@@ -367,6 +400,8 @@ func (it *logBatchIterator) Err() error {
 
 func (it *logBatchIterator) Close() error {
 	it.cancel()
+	it.release(it.currBatch)
+	it.currBatch = nil
 	if it.curr != nil {
 		return it.curr.Close()
 	}
@@ -512,6 +547,8 @@ func (it *sampleBatchIterator) Err() error {
 
 func (it *sampleBatchIterator) Close() error {
 	it.cancel()
+	it.release(it.currBatch)
+	it.currBatch = nil
 	if it.curr != nil {
 		return it.curr.Close()
 	}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"slices"
 	"testing"
 	"time"
 
@@ -1745,6 +1746,43 @@ func TestBatchCancel(t *testing.T) {
 	require.Equal(t, context.Canceled, it.Err())
 }
 
+func TestBatchPeakMemory(t *testing.T) {
+	periodConfig := config.PeriodConfig{
+		From:      config.DayTime{Time: 0},
+		Schema:    "v11",
+		RowShards: 16,
+	}
+
+	chunkfmt, headfmt, err := periodConfig.ChunkFormat()
+	require.NoError(t, err)
+
+	var chunks []*LazyChunk
+	for i := 0; i < 3; i++ {
+		chunks = append(chunks, newLazyChunk(chunkfmt, headfmt, logproto.Stream{
+			Labels:  fooLabelsWithName.String(),
+			Entries: []logproto.Entry{{Timestamp: from.Add(time.Duration(i) * 10 * time.Millisecond), Line: "1"}},
+		}))
+	}
+	sizes := make([]int64, 0, len(chunks))
+	for _, c := range chunks {
+		sizes = append(sizes, int64(c.Chunk.Data.Size()))
+	}
+	statsCtx, ctx := stats.NewContext(context.Background())
+
+	s := config.SchemaConfig{Configs: []config.PeriodConfig{periodConfig}}
+	it, err := newLogBatchIterator(ctx, s, NilMetrics, chunks, 1, newMatchers(fooLabels.String()), log.NewNoopPipeline(), logproto.FORWARD, from, from.Add(time.Second), nil)
+	require.NoError(t, err)
+	streams, _, err := iter.ReadBatch(it, 1000)
+	require.NoError(t, err)
+	require.NoError(t, it.Close())
+	require.Len(t, streams.Streams[0].Entries, 3)
+
+	// at most the batch being iterated and the next batch, fetched ahead, are held in memory.
+	res := statsCtx.Result(0, 0, 0)
+	require.GreaterOrEqual(t, res.Summary.PeakMemoryBytes, slices.Max(sizes))
+	require.LessOrEqual(t, res.Summary.PeakMemoryBytes, max(sizes[0]+sizes[1], sizes[1]+sizes[2]))
+}
+
 var entry logproto.Entry
 
 func Benchmark_store_OverlappingChunks(b *testing.B) {
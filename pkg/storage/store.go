@@ -40,6 +40,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb"
 	"github.com/grafana/loki/v3/pkg/util"
+	"github.com/grafana/loki/v3/pkg/util/constants"
 	"github.com/grafana/loki/v3/pkg/util/deletion"
 )
 
@@ -90,6 +91,8 @@ type LokiStore struct {
 	chunksCache      cache.Cache
 	chunksCacheL2    cache.Cache
 	writeDedupeCache cache.Cache
+	// postingsCache is shared by the TSDB stores of all the periods, nil when disabled.
+	postingsCache *tsdb.PostingsCache
 
 	limits StoreLimits
 	logger log.Logger
@@ -180,6 +183,7 @@ func NewStore(cfg Config, storeCfg config.ChunkStoreConfig, schemaCfg config.Sch
 		chunksCache:      chunksCache,
 		chunksCacheL2:    chunksCacheL2,
 		writeDedupeCache: writeDedupeCache,
+		postingsCache:    tsdb.NewPostingsCache(int(cfg.TSDBShipperConfig.PostingsCacheSize), registerer),
 
 		logger: logger,
 		limits: limits,
@@ -271,7 +275,7 @@ func (s *LokiStore) storeForPeriod(p config.PeriodConfig, tableRange config.Tabl
 	indexClientLogger := log.With(s.logger, "index-store", fmt.Sprintf("%s-%s", p.IndexType, p.From.String()))
 
 	if p.IndexType == types.TSDBType {
-		if shouldUseIndexGatewayClient(s.cfg.TSDBShipperConfig) {
+		if shouldUseIndexGatewayClient(s.cfg.TSDBShipperConfig.Config) {
 			// inject the index-gateway client into the index store
 			gw, err := indexgateway.NewGatewayClient(s.cfg.TSDBShipperConfig.IndexGatewayClientConfig, indexClientReg, s.limits, indexClientLogger, s.metricsNamespace)
 			if err != nil {
@@ -291,7 +295,7 @@ func (s *LokiStore) storeForPeriod(p config.PeriodConfig, tableRange config.Tabl
 		}
 
 		name := fmt.Sprintf("%s_%s", p.ObjectType, p.From.String())
-		indexReaderWriter, stopTSDBStoreFunc, err := tsdb.NewStore(name, p.IndexTables.PathPrefix, s.cfg.TSDBShipperConfig, s.schemaCfg, f, objectClient, s.limits, tableRange, indexClientReg, indexClientLogger)
+		indexReaderWriter, stopTSDBStoreFunc, err := tsdb.NewStore(name, p.IndexTables.PathPrefix, s.cfg.TSDBShipperConfig.Config, s.schemaCfg, f, objectClient, s.limits, tableRange, s.postingsCache, indexClientReg, indexClientLogger)
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -424,6 +428,9 @@ func (s *LokiStore) lazyChunks(
 		prefiltered += len(chks[i])
 		stats.AddChunksRef(int64(len(chks[i])))
 		chks[i] = filterChunksByTime(from, through, chks[i])
+		if !selectsRollups(predicate.Matchers) {
+			chks[i] = filterRollupChunks(chks[i])
+		}
 		filtered += len(chks[i])
 	}
 
@@ -475,9 +482,13 @@ func (s *LokiStore) SelectSeries(ctx context.Context, req logql.SelectLogParams)
 	if err != nil {
 		return nil, err
 	}
-	result := make([]logproto.SeriesIdentifier, len(series))
-	for i, s := range series {
-		result[i] = logproto.SeriesIdentifierFromLabels(s)
+	selectRollups := selectsRollups(matchers)
+	result := make([]logproto.SeriesIdentifier, 0, len(series))
+	for _, s := range series {
+		if !selectRollups && s.Has(constants.RollupLabel) {
+			continue
+		}
+		result = append(result, logproto.SeriesIdentifierFromLabels(s))
 	}
 	return result, nil
 }
@@ -593,6 +604,28 @@ func filterChunksByTime(from, through model.Time, chunks []chunk.Chunk) []chunk.
 	return filtered
 }
 
+// selectsRollups tells if the matchers select the rollup series computed by the compactor.
+// The rollup series are excluded from the queries which do not match their label.
+func selectsRollups(matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if m.Name == constants.RollupLabel {
+			return true
+		}
+	}
+	return false
+}
+
+func filterRollupChunks(chunks []chunk.Chunk) []chunk.Chunk {
+	filtered := chunks[:0]
+	for _, c := range chunks {
+		if c.Metric.Has(constants.RollupLabel) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
 type failingChunkWriter struct{}
 
 func (f failingChunkWriter) Put(_ context.Context, _ []chunk.Chunk) error {
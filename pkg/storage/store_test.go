@@ -42,6 +42,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/storage/config"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/boltdb"
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb"
 	"github.com/grafana/loki/v3/pkg/util/constants"
 	"github.com/grafana/loki/v3/pkg/util/marshal"
 	"github.com/grafana/loki/v3/pkg/validation"
@@ -1275,7 +1276,7 @@ func TestStore_indexPrefixChange(t *testing.T) {
 
 	cfg := Config{
 		FSConfig:          local.FSConfig{Directory: path.Join(tempDir, "chunks")},
-		TSDBShipperConfig: shipperConfig,
+		TSDBShipperConfig: tsdb.IndexCfg{Config: shipperConfig},
 		NamedStores: NamedStores{
 			Filesystem: map[string]NamedFSConfig{
 				"named-store": {Directory: path.Join(tempDir, "named-store")},
@@ -1458,7 +1459,7 @@ func TestStore_MultiPeriod(t *testing.T) {
 			cfg := Config{
 				FSConfig:            local.FSConfig{Directory: path.Join(tempDir, "chunks")},
 				BoltDBShipperConfig: boltdb.IndexCfg{Config: shipperConfig},
-				TSDBShipperConfig:   shipperConfig,
+				TSDBShipperConfig:   tsdb.IndexCfg{Config: shipperConfig},
 				NamedStores: NamedStores{
 					Filesystem: map[string]NamedFSConfig{
 						"named-store": {Directory: path.Join(tempDir, "named-store")},
@@ -1788,6 +1789,60 @@ func Test_GetSeries(t *testing.T) {
 	}
 }
 
+func Test_store_ExcludesRollups(t *testing.T) {
+	periodConfig := config.PeriodConfig{
+		From:   config.DayTime{Time: 0},
+		Schema: "v11",
+	}
+
+	chunkfmt, headfmt, err := periodConfig.ChunkFormat()
+	require.NoError(t, err)
+
+	s := &LokiStore{
+		Store: newMockChunkStore(chunkfmt, headfmt, []*logproto.Stream{
+			{
+				Labels:  `{app="x"}`,
+				Entries: []logproto.Entry{{Timestamp: from, Line: "1"}},
+			},
+			{
+				Labels:  `{__rollup__="x_status", app="x"}`,
+				Entries: []logproto.Entry{{Timestamp: from, Line: "2"}},
+			},
+		}),
+		cfg: Config{
+			MaxChunkBatchSize: 10,
+		},
+		chunkMetrics: NilMetrics,
+		logger:       log.NewNopLogger(),
+	}
+	ctx := user.InjectOrgID(context.Background(), "test-user")
+
+	for _, tc := range []struct {
+		selector string
+		expected string
+	}{
+		{selector: `{app="x"}`, expected: `{app="x"}`},
+		{selector: `{app=~".+"}`, expected: `{app="x"}`},
+		{selector: `{__rollup__="x_status"}`, expected: `{__rollup__="x_status", app="x"}`},
+	} {
+		t.Run(tc.selector, func(t *testing.T) {
+			req := newQuery(tc.selector, from, from.Add(time.Millisecond), nil, nil)
+
+			series, err := s.SelectSeries(ctx, logql.SelectLogParams{QueryRequest: req})
+			require.NoError(t, err)
+			require.Equal(t, []logproto.SeriesIdentifier{{Labels: mustParseLabels(tc.expected)}}, series)
+
+			it, err := s.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: req})
+			require.NoError(t, err)
+			streams, _, err := iter.ReadBatch(it, req.Limit)
+			require.NoError(t, err)
+			require.NoError(t, it.Close())
+			require.Len(t, streams.Streams, 1)
+			require.Equal(t, tc.expected, streams.Streams[0].Labels)
+		})
+	}
+}
+
 func TestStore_BoltdbTsdbSameIndexPrefix(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -1818,7 +1873,7 @@ func TestStore_BoltdbTsdbSameIndexPrefix(t *testing.T) {
 	cfg := Config{
 		FSConfig:            local.FSConfig{Directory: path.Join(tempDir, "chunks")},
 		BoltDBShipperConfig: boltdbShipperConfig,
-		TSDBShipperConfig:   tsdbShipperConfig,
+		TSDBShipperConfig:   tsdb.IndexCfg{Config: tsdbShipperConfig},
 	}
 
 	schemaConfig := config.SchemaConfig{
@@ -1970,7 +2025,7 @@ func TestStore_SyncStopInteraction(t *testing.T) {
 	cfg := Config{
 		FSConfig:            local.FSConfig{Directory: path.Join(tempDir, "chunks")},
 		BoltDBShipperConfig: boltdbShipperConfig,
-		TSDBShipperConfig:   tsdbShipperConfig,
+		TSDBShipperConfig:   tsdb.IndexCfg{Config: tsdbShipperConfig},
 	}
 
 	schemaConfig := config.SchemaConfig{
@@ -91,6 +91,36 @@ func (b *Builder) DropChunk(streamID string, chk index.ChunkMeta) (bool, error)
 	return chunkFound, nil
 }
 
+func (b *Builder) SetChunkTier(streamID string, chk index.ChunkMeta, tier index.Tier) (bool, error) {
+	if !b.chunksFinalized {
+		return false, fmt.Errorf("setting the tier of chunk is only allowed on finalized chunks")
+	}
+	if b.version < index.FormatV4 {
+		return false, fmt.Errorf("setting the tier of chunk requires index format v%d, the index is built with v%d", index.FormatV4, b.version)
+	}
+
+	s, ok := b.streams[streamID]
+	if !ok {
+		return false, nil
+	}
+
+	return s.chunks.SetTier(chk, tier), nil
+}
+
+// checkVersion makes sure the version the index is built with keeps the tier of the chunks.
+// The index is never upgraded implicitly since older readers can't read FormatV4.
+func (b *Builder) checkVersion() error {
+	if b.version >= index.FormatV4 {
+		return nil
+	}
+	for _, s := range b.streams {
+		if s.chunks.HasTieredChunks() {
+			return fmt.Errorf("the index has chunks which are not in the standard tier, they require index format v%d but the index is built with v%d", index.FormatV4, b.version)
+		}
+	}
+	return nil
+}
+
 func (b *Builder) Build(
 	ctx context.Context,
 	scratchDir string,
@@ -100,6 +130,10 @@ func (b *Builder) Build(
 	// and per tenant ones during compaction
 	createFn func(from, through model.Time, checksum uint32) Identifier,
 ) (id Identifier, err error) {
+	if err := b.checkVersion(); err != nil {
+		return id, err
+	}
+
 	// Ensure the parent dir exists (i.e. index/<bucket>/<tenant>/)
 	if scratchDir != "" {
 		if err := chunk_util.EnsureDirectory(scratchDir); err != nil {
@@ -216,6 +250,9 @@ func (b *Builder) BuildInMemory(
 	// and per tenant ones during compaction
 	createFn func(from, through model.Time, checksum uint32) Identifier,
 ) (id Identifier, data []byte, err error) {
+	if err := b.checkVersion(); err != nil {
+		return id, nil, err
+	}
 	writer, err := index.NewMemWriterWithVersion(ctx, b.version)
 	if err != nil {
 		return id, nil, err
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
@@ -104,6 +105,56 @@ func Test_Build(t *testing.T) {
 		reader := getReader(tmpDir)
 		require.Equal(t, index.FormatV2, reader.Version())
 	})
+
+	t.Run("does not record the tier of the chunks before v4", func(t *testing.T) {
+		_, builder, _ := setup(index.FormatV3)
+		builder.FinalizeChunks()
+		lbls := mustParseLabels(`{foo="bar", a="b"}`)
+		_, err := builder.SetChunkTier(lbls.String(), index.ChunkMeta{MinTime: 2, MaxTime: 3, Checksum: 2}, index.TierCold)
+		require.Error(t, err)
+	})
+
+	t.Run("fails to build chunks which are not in the standard tier before v4", func(t *testing.T) {
+		ctx, builder, tmpDir := setup(index.FormatV3)
+		builder.AddSeries(mustParseLabels(`{foo="bar"}`), model.Fingerprint(1), []index.ChunkMeta{{MinTime: 1, MaxTime: 2, Checksum: 1, Tier: index.TierCold}})
+
+		_, err := builder.Build(ctx, tmpDir, func(from, through model.Time, checksum uint32) Identifier {
+			return &fakeIdentifier{parentPath: tmpDir, from: from, through: through, checksum: checksum}
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("records the tier of the chunks with v4", func(t *testing.T) {
+		ctx, builder, tmpDir := setup(index.FormatV4)
+		builder.FinalizeChunks()
+		lbls := mustParseLabels(`{foo="bar", a="b"}`)
+		found, err := builder.SetChunkTier(lbls.String(), index.ChunkMeta{MinTime: 2, MaxTime: 3, Checksum: 2}, index.TierCold)
+		require.NoError(t, err)
+		require.True(t, found)
+
+		_, err = builder.Build(ctx, tmpDir, func(from, through model.Time, checksum uint32) Identifier {
+			return &fakeIdentifier{
+				parentPath: tmpDir,
+				from:       from,
+				through:    through,
+				checksum:   checksum,
+			}
+		})
+		require.NoError(t, err)
+		reader := getReader(tmpDir)
+		require.Equal(t, index.FormatV4, reader.Version())
+
+		refs, err := NewTSDBIndex(reader).GetChunkRefs(ctx, "fake", 0, 10, nil, nil, labels.MustNewMatcher(labels.MatchEqual, "foo", "bar"))
+		require.NoError(t, err)
+		require.Len(t, refs, 5)
+		for _, ref := range refs {
+			if ref.Checksum == 2 {
+				require.Equal(t, index.TierCold, ref.Tier)
+			} else {
+				require.Equal(t, index.TierStandard, ref.Tier)
+			}
+		}
+	})
 }
 
 type fakeIdentifier struct {
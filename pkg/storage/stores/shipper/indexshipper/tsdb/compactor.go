@@ -25,14 +25,36 @@ import (
 
 const readDBsConcurrency = 50
 
-type indexProcessor struct{}
+type indexProcessor struct {
+	// formatV4 writes the compacted indexes with FormatV4, which records the storage tier of the chunks.
+	formatV4 bool
+}
 
 func NewIndexCompactor() compactor.IndexCompactor {
 	return indexProcessor{}
 }
 
+// NewIndexCompactorWithFormatV4 returns an IndexCompactor writing the compacted indexes with FormatV4 instead of the
+// format of their period config, so that the storage tier of the chunks can be recorded.
+// FormatV4 can't be read by older versions, so it must only be used once all the readers of the index support it.
+func NewIndexCompactorWithFormatV4() compactor.IndexCompactor {
+	return indexProcessor{formatV4: true}
+}
+
 func (i indexProcessor) NewTableCompactor(ctx context.Context, commonIndexSet compactor.IndexSet, existingUserIndexSet map[string]compactor.IndexSet, userIndexSetFactoryFunc compactor.MakeEmptyUserIndexSetFunc, periodConfig config.PeriodConfig) compactor.TableCompactor {
-	return newTableCompactor(ctx, commonIndexSet, existingUserIndexSet, userIndexSetFactoryFunc, periodConfig)
+	return newTableCompactor(ctx, commonIndexSet, existingUserIndexSet, userIndexSetFactoryFunc, periodConfig, i.formatV4)
+}
+
+// compactedIndexFormat returns the format to write the compacted indexes of the period with.
+func compactedIndexFormat(periodConfig config.PeriodConfig, formatV4 bool) (int, error) {
+	indexFormat, err := periodConfig.TSDBFormat()
+	if err != nil {
+		return 0, err
+	}
+	if formatV4 {
+		return max(indexFormat, tsdbindex.FormatV4), nil
+	}
+	return indexFormat, nil
 }
 
 func (i indexProcessor) OpenCompactedIndexFile(ctx context.Context, path, tableName, userID, workingDir string, periodConfig config.PeriodConfig, logger log.Logger) (compactor.CompactedIndex, error) {
@@ -47,12 +69,13 @@ func (i indexProcessor) OpenCompactedIndexFile(ctx context.Context, path, tableN
 		}
 	}()
 
-	indexFormat, err := periodConfig.TSDBFormat()
+	indexFormat, err := compactedIndexFormat(periodConfig, i.formatV4)
 	if err != nil {
 		return nil, err
 	}
 
-	builder := NewBuilder(indexFormat)
+	// an index already recording the tier of its chunks keeps its format.
+	builder := NewBuilder(max(indexFormat, indexFile.(*TSDBFile).Index.(*TSDBIndex).reader.(*tsdbindex.Reader).Version()))
 	err = indexFile.(*TSDBFile).Index.(*TSDBIndex).ForSeries(ctx, "", nil, 0, math.MaxInt64, func(lbls labels.Labels, fp model.Fingerprint, chks []tsdbindex.ChunkMeta) (stop bool) {
 		builder.AddSeries(lbls.Copy(), fp, chks)
 		return false
@@ -72,6 +95,7 @@ type tableCompactor struct {
 	userIndexSetFactoryFunc compactor.MakeEmptyUserIndexSetFunc
 	ctx                     context.Context
 	periodConfig            config.PeriodConfig
+	formatV4                bool
 	compactedIndexes        map[string]compactor.CompactedIndex
 }
 
@@ -81,6 +105,7 @@ func newTableCompactor(
 	existingUserIndexSet map[string]compactor.IndexSet,
 	userIndexSetFactoryFunc compactor.MakeEmptyUserIndexSetFunc,
 	periodConfig config.PeriodConfig,
+	formatV4 bool,
 ) *tableCompactor {
 	return &tableCompactor{
 		ctx:                     ctx,
@@ -88,6 +113,7 @@ func newTableCompactor(
 		existingUserIndexSet:    existingUserIndexSet,
 		userIndexSetFactoryFunc: userIndexSetFactoryFunc,
 		periodConfig:            periodConfig,
+		formatV4:                formatV4,
 	}
 }
 
@@ -155,7 +181,7 @@ func (t *tableCompactor) CompactTable() error {
 			}
 		}
 
-		indexType, err := t.periodConfig.TSDBFormat()
+		indexType, err := compactedIndexFormat(t.periodConfig, t.formatV4)
 		if err != nil {
 			return err
 		}
@@ -180,7 +206,7 @@ func (t *tableCompactor) CompactTable() error {
 			continue
 		}
 
-		indexType, err := t.periodConfig.TSDBFormat()
+		indexType, err := compactedIndexFormat(t.periodConfig, t.formatV4)
 		if err != nil {
 			return err
 		}
@@ -246,6 +272,8 @@ func setupBuilder(ctx context.Context, indexType int, userID string, sourceIndex
 			}
 		}()
 
+		// an index already recording the tier of its chunks keeps its format.
+		builder.version = max(builder.version, indexFile.(*TSDBFile).Index.(*TSDBIndex).reader.(*tsdbindex.Reader).Version())
 		err = indexFile.(*TSDBFile).Index.(*TSDBIndex).ForSeries(ctx, "", nil, 0, math.MaxInt64, func(lbls labels.Labels, fp model.Fingerprint, chks []tsdbindex.ChunkMeta) (stop bool) {
 			builder.AddSeries(lbls.Copy(), fp, chks)
 			return false
@@ -271,9 +299,15 @@ type compactedIndex struct {
 
 	indexChunks     []chunk.Chunk
 	deleteChunks    map[string][]tsdbindex.ChunkMeta
+	tierChanges     map[string][]tierChange
 	seriesToCleanup map[string]struct{}
 }
 
+type tierChange struct {
+	chk  tsdbindex.ChunkMeta
+	tier tsdbindex.Tier
+}
+
 func newCompactedIndex(ctx context.Context, tableName, userID, workingDir string, periodConfig config.PeriodConfig, builder *Builder) *compactedIndex {
 	return &compactedIndex{
 		ctx:             ctx,
@@ -283,6 +317,7 @@ func newCompactedIndex(ctx context.Context, tableName, userID, workingDir string
 		periodConfig:    periodConfig,
 		tableInterval:   retention.ExtractIntervalFromTableName(tableName),
 		deleteChunks:    map[string][]tsdbindex.ChunkMeta{},
+		tierChanges:     map[string][]tierChange{},
 		seriesToCleanup: map[string]struct{}{},
 	}
 }
@@ -315,6 +350,8 @@ func (c *compactedIndex) ForEachChunk(ctx context.Context, callback retention.Ch
 			chunkEntry.ChunkID = getUnsafeBytes(schemaCfg.ExternalKey(logprotoChunkRef))
 			chunkEntry.From = logprotoChunkRef.From
 			chunkEntry.Through = logprotoChunkRef.Through
+			chunkEntry.Tier = chk.Tier
+			chunkEntry.KB = chk.KB
 
 			deleteChunk, err := callback(chunkEntry)
 			if err != nil {
@@ -343,6 +380,25 @@ func (c *compactedIndex) IndexChunk(chk chunk.Chunk) (bool, error) {
 	return true, nil
 }
 
+// SetChunkTier lines up the change of the storage tier of the chunk which is applied while building the index.
+func (c *compactedIndex) SetChunkTier(ref retention.ChunkEntry, tier tsdbindex.Tier) error {
+	chk, err := chunk.ParseExternalKey(c.userID, string(ref.ChunkID))
+	if err != nil {
+		return err
+	}
+
+	seriesID := string(ref.SeriesID)
+	c.tierChanges[seriesID] = append(c.tierChanges[seriesID], tierChange{
+		chk: tsdbindex.ChunkMeta{
+			Checksum: chk.Checksum,
+			MinTime:  int64(chk.From),
+			MaxTime:  int64(chk.Through),
+		},
+		tier: tier,
+	})
+	return nil
+}
+
 // CleanupSeries removes the series from the builder(including its chunks) and deletes the list of chunks lined up for deletion.
 func (c *compactedIndex) CleanupSeries(_ []byte, lbls labels.Labels) error {
 	seriesID := lbls.String()
@@ -351,6 +407,7 @@ func (c *compactedIndex) CleanupSeries(_ []byte, lbls labels.Labels) error {
 	}
 	delete(c.builder.streams, seriesID)
 	delete(c.deleteChunks, seriesID)
+	delete(c.tierChanges, seriesID)
 	return nil
 }
 
@@ -372,6 +429,16 @@ func (c *compactedIndex) ToIndexFile() (shipperindex.Index, error) {
 	}
 	c.deleteChunks = nil
 
+	// the tier of the chunks deleted from the index is not changed.
+	for seriesID, changes := range c.tierChanges {
+		for _, change := range changes {
+			if _, err := c.builder.SetChunkTier(seriesID, change.chk, change.tier); err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.tierChanges = nil
+
 	for _, chk := range c.indexChunks {
 		// TSDB doesnt need the __name__="log" convention the old chunk store index used.
 		b := labels.NewBuilder(chk.Metric)
@@ -578,7 +578,7 @@ func TestCompactor_Compact(t *testing.T) {
 						defer initializedIndexSetsMtx.Unlock()
 						initializedIndexSets[userID] = idxSet
 						return idxSet, nil
-					}, periodConfig)
+					}, periodConfig, false)
 
 					require.NoError(t, tCompactor.CompactTable())
 
@@ -638,6 +638,7 @@ func chunkMetasToChunkEntry(schemaCfg config.SchemaConfig, userID string, lbls l
 				Through:  chunkMeta.Through(),
 			},
 			Labels: lbls,
+			KB:     chunkMeta.KB,
 		})
 	}
 
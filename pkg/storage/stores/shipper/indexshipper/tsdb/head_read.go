@@ -99,6 +99,11 @@ func (h *headIndexReader) LabelNames(matchers ...*labels.Matcher) ([]string, err
 	return labelNamesWithMatchers(h, matchers...)
 }
 
+// LabelStats returns false since the head doesn't hold precomputed label statistics.
+func (h *headIndexReader) LabelStats(_ string) (index.LabelStats, bool) {
+	return index.LabelStats{}, false
+}
+
 // Postings returns the postings list iterator for the label pairs.
 func (h *headIndexReader) Postings(name string, fpFilter index.FingerprintFilter, values ...string) (index.Postings, error) {
 	var p index.Postings
@@ -2,6 +2,8 @@ package tsdb
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
@@ -21,6 +23,18 @@ type ChunkRef struct {
 	Fingerprint model.Fingerprint
 	Start, End  model.Time
 	Checksum    uint32
+	Tier        index.Tier
+}
+
+// ColdChunksError is returned when chunks moved to the cold storage tier are needed to answer a query.
+type ColdChunksError struct {
+	Chunks        int
+	From, Through model.Time
+}
+
+func (e ColdChunksError) Error() string {
+	return fmt.Sprintf("the query needs %d chunks between %s and %s which are archived in the cold storage tier: request their rehydration with the /loki/api/v1/rehydrate endpoint and retry once it is processed",
+		e.Chunks, e.From.Time().UTC().Format(time.RFC3339), e.Through.Time().UTC().Format(time.RFC3339))
 }
 
 // Compares by (Fp, Start, End, checksum)
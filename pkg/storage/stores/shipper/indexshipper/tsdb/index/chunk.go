@@ -1,6 +1,7 @@
 package index
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/prometheus/common/model"
@@ -19,6 +20,41 @@ type ChunkMeta struct {
 	KB uint32
 
 	Entries uint32
+
+	// Tier is the storage tier of the chunk, only encoded from FormatV4.
+	Tier Tier
+}
+
+// Tier is the storage tier where a chunk is stored.
+type Tier uint8
+
+const (
+	// TierStandard is the tier of the chunks stored in the object store of their period.
+	TierStandard Tier = iota
+	// TierCold is the tier of the chunks moved to the cold storage by the compactor.
+	// They have to be rehydrated before they can be queried.
+	TierCold
+)
+
+func (t Tier) String() string {
+	switch t {
+	case TierStandard:
+		return "standard"
+	case TierCold:
+		return "cold"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// HasTieredChunks tells if some of the chunks are not in the standard tier, requiring FormatV4 to be indexed.
+func (c ChunkMetas) HasTieredChunks() bool {
+	for _, chk := range c {
+		if chk.Tier != TierStandard {
+			return true
+		}
+	}
+	return false
 }
 
 func (c ChunkMeta) From() model.Time                 { return model.Time(c.MinTime) }
@@ -52,7 +88,8 @@ func (c ChunkMetas) Bounds() (mint, maxt model.Time) {
 	return
 }
 
-// Sort by (MinTime, MaxTime, Checksum)
+// Sort by (MinTime, MaxTime, Checksum, Tier descending)
+// The colder tier comes first so it is the one kept by Finalize when a chunk was indexed in multiple tiers.
 func (c ChunkMetas) Less(i, j int) bool {
 	a, b := c[i], c[j]
 	if a.MinTime != b.MinTime {
@@ -63,7 +100,11 @@ func (c ChunkMetas) Less(i, j int) bool {
 		return a.MaxTime < b.MaxTime
 	}
 
-	return a.Checksum < b.Checksum
+	if a.Checksum != b.Checksum {
+		return a.Checksum < b.Checksum
+	}
+
+	return a.Tier > b.Tier
 }
 
 // Finalize sorts and dedupes
@@ -158,6 +199,31 @@ func (c ChunkMetas) Drop(chk ChunkMeta) (ChunkMetas, bool) {
 	return c[:j+copy(c[j:], c[j+1:])], true
 }
 
+// SetTier sets the tier of the ChunkMeta with the same bounds and checksum as chk.
+// It assumes existing ChunkMetas have already been sorted by using Finalize.
+// It returns a boolean indicating if the chunk was found.
+func (c ChunkMetas) SetTier(chk ChunkMeta, tier Tier) bool {
+	j := sort.Search(len(c), func(i int) bool {
+		ichk := c[i]
+		if ichk.MinTime != chk.MinTime {
+			return ichk.MinTime >= chk.MinTime
+		}
+
+		if ichk.MaxTime != chk.MaxTime {
+			return ichk.MaxTime >= chk.MaxTime
+		}
+
+		return ichk.Checksum >= chk.Checksum
+	})
+
+	if j >= len(c) || c[j].MinTime != chk.MinTime || c[j].MaxTime != chk.MaxTime || c[j].Checksum != chk.Checksum {
+		return false
+	}
+
+	c[j].Tier = tier
+	return true
+}
+
 // Some of these fields can realistically be 32bit, but
 // this gives us a lot of wiggle room and they're already
 // encoded only for every n-th chunk based on `ChunkPageSize`
@@ -55,6 +55,10 @@ const (
 	// FormatV3 represents 3 version of index. It adds support for
 	// paging through batches of chunks within a series
 	FormatV3 = 3
+	// FormatV4 represents 4 version of index. It adds the storage tier
+	// of the chunks to their metadata and references the label statistics
+	// table from the TOC
+	FormatV4 = 4
 
 	IndexFilename = "index"
 
@@ -137,6 +141,7 @@ type Creator struct {
 
 	labelIndexes []labelIndexHashEntry // Label index offsets.
 	labelNames   map[string]uint64     // Label names, and their usage.
+	labelStats   map[string]*LabelStats
 	// Keeps track of the fingerprint/offset for every n series
 	fingerprintOffsets FingerprintOffsets
 
@@ -159,7 +164,10 @@ type TOC struct {
 	Postings           uint64
 	PostingsTable      uint64
 	FingerprintOffsets uint64
-	Metadata           Metadata
+	// LabelStats is only set from FormatV4. The older formats have the label statistics table right after the
+	// fingerprint offsets table, where the readers not supporting it ignore it.
+	LabelStats uint64
+	Metadata   Metadata
 }
 
 // Metadata is TSDB-level metadata
@@ -178,12 +186,13 @@ func (m *Metadata) EnsureBounds(from, through int64) {
 	}
 }
 
-// NewTOCFromByteSlice return parsed TOC from given index byte slice.
-func NewTOCFromByteSlice(bs ByteSlice) (*TOC, error) {
-	if bs.Len() < indexTOCLen {
+// NewTOCFromByteSlice return parsed TOC from given index byte slice of the given version.
+func NewTOCFromByteSlice(bs ByteSlice, version int) (*TOC, error) {
+	tocLen := indexTOCLenForVersion(version)
+	if bs.Len() < tocLen {
 		return nil, tsdb_enc.ErrInvalidSize
 	}
-	b := bs.Range(bs.Len()-indexTOCLen, bs.Len())
+	b := bs.Range(bs.Len()-tocLen, bs.Len())
 
 	expCRC := binary.BigEndian.Uint32(b[len(b)-4:])
 	d := encoding.DecWrap(tsdb_enc.Decbuf{B: b[:len(b)-4]})
@@ -195,7 +204,7 @@ func NewTOCFromByteSlice(bs ByteSlice) (*TOC, error) {
 		return nil, err
 	}
 
-	return &TOC{
+	toc := &TOC{
 		Symbols:            d.Be64(),
 		Series:             d.Be64(),
 		LabelIndices:       d.Be64(),
@@ -203,12 +212,16 @@ func NewTOCFromByteSlice(bs ByteSlice) (*TOC, error) {
 		Postings:           d.Be64(),
 		PostingsTable:      d.Be64(),
 		FingerprintOffsets: d.Be64(),
-		Metadata: Metadata{
-			From:     d.Be64int64(),
-			Through:  d.Be64int64(),
-			Checksum: expCRC,
-		},
-	}, nil
+	}
+	if version >= FormatV4 {
+		toc.LabelStats = d.Be64()
+	}
+	toc.Metadata = Metadata{
+		From:     d.Be64int64(),
+		Through:  d.Be64int64(),
+		Checksum: expCRC,
+	}
+	return toc, nil
 }
 
 // For writing TSDBs using temporary files
@@ -377,6 +390,11 @@ func (w *Creator) ensureStage(s indexWriterStage) error {
 			return err
 		}
 
+		w.toc.LabelStats = w.f.Pos()
+		if err := w.writeLabelStatsTable(); err != nil {
+			return err
+		}
+
 		if err := w.writeTOC(); err != nil {
 			return err
 		}
@@ -559,6 +577,9 @@ func (w *Creator) addChunksV3(chunks []ChunkMeta, primary, scratch *encoding.Enc
 			t0 = c.MaxTime
 
 			scratch.PutBE32(c.Checksum)
+			if w.Version >= FormatV4 {
+				scratch.PutByte(byte(c.Tier))
+			}
 
 			// test if this is the last chunk in the page
 			if i%chunkPageSize == chunkPageSize-1 {
@@ -913,6 +934,16 @@ func (w *Creator) writeFingerprintOffsetsTable() error {
 
 const indexTOCLen = 8*9 + crc32.Size
 
+// indexTOCLenV4 adds the offset of the label statistics table.
+const indexTOCLenV4 = indexTOCLen + 8
+
+func indexTOCLenForVersion(version int) int {
+	if version >= FormatV4 {
+		return indexTOCLenV4
+	}
+	return indexTOCLen
+}
+
 func (w *Creator) writeTOC() error {
 	w.buf1.Reset()
 
@@ -923,6 +954,9 @@ func (w *Creator) writeTOC() error {
 	w.buf1.PutBE64(w.toc.Postings)
 	w.buf1.PutBE64(w.toc.PostingsTable)
 	w.buf1.PutBE64(w.toc.FingerprintOffsets)
+	if w.Version >= FormatV4 {
+		w.buf1.PutBE64(w.toc.LabelStats)
+	}
 
 	// metadata
 	w.buf1.PutBE64int64(w.toc.Metadata.From)
@@ -1074,6 +1108,7 @@ func (w *Creator) writePosting(name, value string, offs []uint32) error {
 		return err
 	}
 	w.cntPO++
+	w.addPostingsStats(name, len(offs))
 
 	w.buf1.Reset()
 	w.buf1.PutBE32int(len(offs))
@@ -1207,6 +1242,8 @@ type Reader struct {
 	// as there are not many and they are half of all lookups.
 
 	fingerprintOffsets FingerprintOffsets
+	// labelStats is nil when the index was written without label statistics.
+	labelStats map[string]LabelStats
 
 	dec *Decoder
 
@@ -1277,12 +1314,12 @@ func newReader(b ByteSlice, c io.Closer) (*Reader, error) {
 	}
 	r.version = int(r.b.Range(4, 5)[0])
 
-	if r.version != FormatV1 && r.version != FormatV2 && r.version != FormatV3 {
+	if r.version != FormatV1 && r.version != FormatV2 && r.version != FormatV3 && r.version != FormatV4 {
 		return nil, errors.Errorf("unknown index file version %d", r.version)
 	}
 
 	var err error
-	r.toc, err = NewTOCFromByteSlice(b)
+	r.toc, err = NewTOCFromByteSlice(b, r.version)
 	if err != nil {
 		return nil, errors.Wrap(err, "read TOC")
 	}
@@ -1362,11 +1399,42 @@ func newReader(b ByteSlice, c io.Closer) (*Reader, error) {
 		return nil, errors.Wrap(err, "loading fingerprint offsets")
 	}
 
+	labelStatsOff, ok, err := r.labelStatsTableOffset()
+	if err != nil {
+		return nil, errors.Wrap(err, "locating label stats")
+	}
+	if ok {
+		r.labelStats, err = readLabelStatsTable(r.b, labelStatsOff)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading label stats")
+		}
+	}
+
 	r.dec = newDecoder(r.lookupSymbol, DefaultMaxChunksToBypassMarkerLookup)
 
 	return r, nil
 }
 
+// labelStatsTableOffset returns the offset of the label statistics table, and false when the index has none.
+// Before FormatV4 the table isn't referenced by the TOC, it follows the fingerprint offsets table when there is space
+// left between them and the TOC.
+func (r *Reader) labelStatsTableOffset() (uint64, bool, error) {
+	if r.version >= FormatV4 {
+		return r.toc.LabelStats, true, nil
+	}
+
+	off := int(r.toc.FingerprintOffsets)
+	if off+4 > r.b.Len() {
+		return 0, false, tsdb_enc.ErrInvalidSize
+	}
+	// the table is prefixed by its length and followed by its checksum.
+	end := off + 4 + int(binary.BigEndian.Uint32(r.b.Range(off, off+4))) + crc32.Size
+	if end >= r.b.Len()-indexTOCLenForVersion(r.version) {
+		return 0, false, nil
+	}
+	return uint64(end), true, nil
+}
+
 // Version returns the file format version of the underlying index.
 func (r *Reader) Version() int {
 	return r.version
@@ -2093,7 +2161,7 @@ func buildChunkSamples(d encoding.Decbuf, numChunks int, info *chunkSamples) err
 
 	chunkPos := bufLen - d.Len()
 	chunkMeta := &ChunkMeta{}
-	if err := readChunkMeta(&d, 0, chunkMeta); err != nil {
+	if err := readChunkMeta(FormatV2, &d, 0, chunkMeta); err != nil {
 		return errors.Wrapf(d.Err(), "read meta for chunk %d", 0)
 	}
 
@@ -2109,7 +2177,7 @@ func buildChunkSamples(d encoding.Decbuf, numChunks int, info *chunkSamples) err
 
 	for i := 1; i < numChunks; i++ {
 		chunkPos = bufLen - d.Len()
-		if err := readChunkMeta(&d, t0, chunkMeta); err != nil {
+		if err := readChunkMeta(FormatV2, &d, t0, chunkMeta); err != nil {
 			return errors.Wrapf(d.Err(), "read meta for chunk %d", i)
 		}
 		if chunkMeta.MaxTime > largestMaxt {
@@ -2221,19 +2289,19 @@ func (dec *Decoder) ChunkStats(version int, b []byte, seriesRef storage.SeriesRe
 
 func (dec *Decoder) readChunkStats(version int, d *encoding.Decbuf, seriesRef storage.SeriesRef, from, through int64) (ChunkStats, error) {
 	if version > FormatV2 {
-		return dec.readChunkStatsV3(d, from, through)
+		return dec.readChunkStatsV3(version, d, from, through)
 	}
 	return dec.readChunkStatsPriorV3(d, seriesRef, from, through)
 }
 
-func (dec *Decoder) readChunkStatsV3(d *encoding.Decbuf, from, through int64) (res ChunkStats, err error) {
+func (dec *Decoder) readChunkStatsV3(version int, d *encoding.Decbuf, from, through int64) (res ChunkStats, err error) {
 	nChunks := d.Uvarint()
 	markersLn := int(d.Be32()) // markersLn
 	startMarkers := d.Len()
 
 	if nChunks < dec.maxChunksToBypassMarkerLookup {
 		d.Skip(markersLn)
-		return dec.accumulateChunkStats(d, nChunks, from, through)
+		return dec.accumulateChunkStats(version, d, nChunks, from, through)
 	}
 
 	nMarkers := d.Uvarint()
@@ -2291,9 +2359,9 @@ func (dec *Decoder) readChunkStatsV3(d *encoding.Decbuf, from, through int64) (r
 				// but this doesn't reset at page boundaries
 				// (maybe it should for more ergonomic programming).
 				// instead, we can just force the min-time to the page's min-time
-				err = readChunkMetaWithForcedMintime(d, curMarker.MinTime, chunkMeta, true)
+				err = readChunkMetaWithForcedMintime(version, d, curMarker.MinTime, chunkMeta, true)
 			} else {
-				err = readChunkMeta(d, prevMaxT, chunkMeta)
+				err = readChunkMeta(version, d, prevMaxT, chunkMeta)
 			}
 			if err != nil {
 				return res, errors.Wrap(d.Err(), "read meta for chunk")
@@ -2313,11 +2381,11 @@ func (dec *Decoder) readChunkStatsV3(d *encoding.Decbuf, from, through int64) (r
 	return res, d.Err()
 }
 
-func (dec *Decoder) accumulateChunkStats(d *encoding.Decbuf, nChunks int, from, through int64) (res ChunkStats, err error) {
+func (dec *Decoder) accumulateChunkStats(version int, d *encoding.Decbuf, nChunks int, from, through int64) (res ChunkStats, err error) {
 	var prevMaxT int64
 	chunkMeta := &ChunkMeta{}
 	for i := 0; i < nChunks; i++ {
-		if err := readChunkMeta(d, prevMaxT, chunkMeta); err != nil {
+		if err := readChunkMeta(version, d, prevMaxT, chunkMeta); err != nil {
 			return res, errors.Wrap(d.Err(), "read meta for chunk")
 		}
 		prevMaxT = chunkMeta.MaxTime
@@ -2369,12 +2437,12 @@ func (dec *Decoder) Series(version int, b []byte, seriesRef storage.SeriesRef, f
 func (dec *Decoder) readChunks(version int, d *encoding.Decbuf, seriesRef storage.SeriesRef, from int64, through int64, chks *[]ChunkMeta) error {
 	// read chunks based on fmt
 	if version > FormatV2 {
-		return dec.readChunksV3(d, from, through, chks)
+		return dec.readChunksV3(version, d, from, through, chks)
 	}
 	return dec.readChunksPriorV3(d, seriesRef, from, through, chks)
 }
 
-func (dec *Decoder) readChunksV3(d *encoding.Decbuf, from int64, through int64, chks *[]ChunkMeta) error {
+func (dec *Decoder) readChunksV3(version int, d *encoding.Decbuf, from int64, through int64, chks *[]ChunkMeta) error {
 	nChunks := d.Uvarint()
 	chunksRemaining := nChunks
 
@@ -2426,9 +2494,9 @@ iterate:
 		chunkMeta := &ChunkMeta{}
 		var err error
 		if i == 0 && forceMinTime {
-			err = readChunkMetaWithForcedMintime(d, marker.MinTime, chunkMeta, true)
+			err = readChunkMetaWithForcedMintime(version, d, marker.MinTime, chunkMeta, true)
 		} else {
-			err = readChunkMeta(d, prevMaxT, chunkMeta)
+			err = readChunkMeta(version, d, prevMaxT, chunkMeta)
 		}
 		if err != nil {
 			return errors.Wrapf(d.Err(), "read meta for chunk %d", nChunks-chunksRemaining+i)
@@ -2465,7 +2533,7 @@ func (dec *Decoder) readChunksPriorV3(d *encoding.Decbuf, seriesRef storage.Seri
 	d.Skip(cs.offset)
 
 	chunkMeta := &ChunkMeta{}
-	if err := readChunkMeta(d, cs.prevChunkMaxt, chunkMeta); err != nil {
+	if err := readChunkMeta(FormatV2, d, cs.prevChunkMaxt, chunkMeta); err != nil {
 		return errors.Wrapf(d.Err(), "read meta for chunk %d", cs.idx)
 	}
 
@@ -2475,7 +2543,7 @@ func (dec *Decoder) readChunksPriorV3(d *encoding.Decbuf, seriesRef storage.Seri
 	t0 := chunkMeta.MaxTime
 
 	for i := cs.idx + 1; i < k; i++ {
-		if err := readChunkMeta(d, t0, chunkMeta); err != nil {
+		if err := readChunkMeta(FormatV2, d, t0, chunkMeta); err != nil {
 			return errors.Wrapf(d.Err(), "read meta for chunk %d", cs.idx)
 		}
 		t0 = chunkMeta.MaxTime
@@ -2489,14 +2557,14 @@ func (dec *Decoder) readChunksPriorV3(d *encoding.Decbuf, seriesRef storage.Seri
 	return d.Err()
 }
 
-func readChunkMeta(d *encoding.Decbuf, prevChunkMaxt int64, chunkMeta *ChunkMeta) error {
+func readChunkMeta(version int, d *encoding.Decbuf, prevChunkMaxt int64, chunkMeta *ChunkMeta) error {
 	// Decode the diff against previous chunk as varint
 	// instead of uvarint because chunks may overlap
 	mint := d.Varint64() + prevChunkMaxt
-	return readChunkMetaWithForcedMintime(d, mint, chunkMeta, false)
+	return readChunkMetaWithForcedMintime(version, d, mint, chunkMeta, false)
 }
 
-func readChunkMetaWithForcedMintime(d *encoding.Decbuf, mint int64, chunkMeta *ChunkMeta, decodeMinT bool) error {
+func readChunkMetaWithForcedMintime(version int, d *encoding.Decbuf, mint int64, chunkMeta *ChunkMeta, decodeMinT bool) error {
 	if decodeMinT {
 		// skip the mint delta since we're forcing, but still need to
 		// remove the bytes from our buffer
@@ -2507,6 +2575,11 @@ func readChunkMetaWithForcedMintime(d *encoding.Decbuf, mint int64, chunkMeta *C
 	chunkMeta.KB = uint32(d.Uvarint())
 	chunkMeta.Entries = uint32(d.Uvarint64())
 	chunkMeta.Checksum = d.Be32()
+	if version >= FormatV4 {
+		chunkMeta.Tier = Tier(d.Byte())
+	} else {
+		chunkMeta.Tier = TierStandard
+	}
 
 	if d.Err() != nil {
 		return d.Err()
@@ -0,0 +1,112 @@
+package index
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	tsdb_enc "github.com/prometheus/prometheus/tsdb/encoding"
+
+	"github.com/grafana/loki/v3/pkg/util/encoding"
+)
+
+// LabelStats are the cardinality statistics of a label name, precomputed when the index is written.
+type LabelStats struct {
+	// Values is the number of distinct values of the label.
+	Values uint64
+	// Series is the number of series having the label.
+	Series uint64
+	// MaxValueSeries is the number of series of the most used value of the label.
+	MaxValueSeries uint64
+}
+
+// AvgValueSeries returns the average number of series of a value of the label.
+func (s LabelStats) AvgValueSeries() uint64 {
+	if s.Values == 0 {
+		return 0
+	}
+	return s.Series / s.Values
+}
+
+// addPostingsStats accounts the postings list of a value of the label name.
+func (w *Creator) addPostingsStats(name string, series int) {
+	if name == "" {
+		return
+	}
+	if w.labelStats == nil {
+		w.labelStats = map[string]*LabelStats{}
+	}
+	s, ok := w.labelStats[name]
+	if !ok {
+		s = &LabelStats{}
+		w.labelStats[name] = s
+	}
+	s.Values++
+	s.Series += uint64(series)
+	if uint64(series) > s.MaxValueSeries {
+		s.MaxValueSeries = uint64(series)
+	}
+}
+
+// writeLabelStatsTable writes the label statistics table right after the fingerprint offsets table. It is referenced
+// by the TOC from FormatV4, and found after the fingerprint offsets table by the readers of the older formats.
+func (w *Creator) writeLabelStatsTable() error {
+	names := make([]string, 0, len(w.labelStats))
+	for name := range w.labelStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.buf1.Reset()
+	w.buf2.Reset()
+
+	w.buf1.PutBE32int(len(names)) // Count.
+	for _, name := range names {
+		s := w.labelStats[name]
+		w.buf1.PutUvarintStr(name)
+		w.buf1.PutUvarint64(s.Values)
+		w.buf1.PutUvarint64(s.Series)
+		w.buf1.PutUvarint64(s.MaxValueSeries)
+	}
+
+	ln := w.buf1.Len()
+	if uint64(ln) > math.MaxUint32 {
+		return errors.Errorf("label stats table size exceeds 4 bytes: %d", ln)
+	}
+	w.buf2.PutBE32int(ln)
+	if err := w.write(w.buf2.Get()); err != nil {
+		return err
+	}
+
+	w.buf1.PutHash(w.crc32)
+	if err := w.write(w.buf1.Get()); err != nil {
+		return errors.Wrap(err, "failure writing label stats")
+	}
+	return nil
+}
+
+// readLabelStatsTable reads the label statistics table at off.
+func readLabelStatsTable(bs ByteSlice, off uint64) (map[string]LabelStats, error) {
+	d := encoding.DecWrap(tsdb_enc.NewDecbufAt(bs, int(off), castagnoliTable))
+	cnt := d.Be32()
+	res := make(map[string]LabelStats, int(cnt))
+	for d.Err() == nil && d.Len() > 0 && cnt > 0 {
+		name := string(d.UvarintBytes())
+		res[name] = LabelStats{
+			Values:         d.Uvarint64(),
+			Series:         d.Uvarint64(),
+			MaxValueSeries: d.Uvarint64(),
+		}
+		cnt--
+	}
+	return res, d.Err()
+}
+
+// LabelStats returns the statistics of the label name. ok is false when the index was written by a version not
+// computing the label statistics. A label name which isn't in the index has empty statistics.
+func (r *Reader) LabelStats(name string) (stats LabelStats, ok bool) {
+	if r.labelStats == nil {
+		return LabelStats{}, false
+	}
+	return r.labelStats[name], true
+}
@@ -0,0 +1,101 @@
+package index
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLabelStatsIndex(t *testing.T, version int) string {
+	fn := filepath.Join(t.TempDir(), IndexFilename)
+	iw, err := NewWriter(context.Background(), version, fn)
+	require.NoError(t, err)
+
+	series := []labels.Labels{
+		labels.FromStrings("app", "a", "pod", "1"),
+		labels.FromStrings("app", "a", "pod", "2"),
+		labels.FromStrings("app", "a", "pod", "3"),
+		labels.FromStrings("app", "b", "pod", "4"),
+	}
+	for _, s := range []string{"1", "2", "3", "4", "a", "app", "b", "pod"} {
+		require.NoError(t, iw.AddSymbol(s))
+	}
+	for i, s := range series {
+		require.NoError(t, iw.AddSeries(storage.SeriesRef(i+1), s, model.Fingerprint(i+1)))
+	}
+	_, err = iw.Close(false)
+	require.NoError(t, err)
+	return fn
+}
+
+func TestLabelStats(t *testing.T) {
+	for _, version := range []int{FormatV2, FormatV3, FormatV4} {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			ir, err := NewFileReader(writeLabelStatsIndex(t, version))
+			require.NoError(t, err)
+			defer ir.Close()
+
+			stats, ok := ir.LabelStats("app")
+			require.True(t, ok)
+			require.Equal(t, LabelStats{Values: 2, Series: 4, MaxValueSeries: 3}, stats)
+			require.Equal(t, uint64(2), stats.AvgValueSeries())
+
+			stats, ok = ir.LabelStats("pod")
+			require.True(t, ok)
+			require.Equal(t, LabelStats{Values: 4, Series: 4, MaxValueSeries: 1}, stats)
+
+			// a label name which isn't in the index has empty statistics.
+			stats, ok = ir.LabelStats("unknown")
+			require.True(t, ok)
+			require.Equal(t, LabelStats{}, stats)
+			require.Equal(t, uint64(0), stats.AvgValueSeries())
+		})
+	}
+}
+
+func TestLabelStats_IndexWithoutStats(t *testing.T) {
+	// an index written before the label statistics, without space between the fingerprint offsets table and the TOC.
+	fn := writeLabelStatsIndex(t, FormatV3)
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	toc, err := NewTOCFromByteSlice(RealByteSlice(b), FormatV3)
+	require.NoError(t, err)
+	fpOffsetsEnd := int(toc.FingerprintOffsets) + 4 + int(binary.BigEndian.Uint32(b[toc.FingerprintOffsets:])) + crc32.Size
+	b = append(b[:fpOffsetsEnd:fpOffsetsEnd], b[len(b)-indexTOCLen:]...)
+	require.NoError(t, os.WriteFile(fn, b, 0o644))
+
+	ir, err := NewFileReader(fn)
+	require.NoError(t, err)
+	defer ir.Close()
+
+	_, ok := ir.LabelStats("app")
+	require.False(t, ok)
+
+	p, err := ir.Postings("app", nil, "a")
+	require.NoError(t, err)
+	refs, err := ExpandPostings(p)
+	require.NoError(t, err)
+	require.Len(t, refs, 3)
+}
+
+func TestLabelStats_TOC(t *testing.T) {
+	b, err := os.ReadFile(writeLabelStatsIndex(t, FormatV4))
+	require.NoError(t, err)
+
+	toc, err := NewTOCFromByteSlice(RealByteSlice(b), FormatV4)
+	require.NoError(t, err)
+	require.Greater(t, toc.LabelStats, toc.FingerprintOffsets)
+
+	stats, err := readLabelStatsTable(RealByteSlice(b), toc.LabelStats)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+}
@@ -2,6 +2,7 @@ package tsdb
 
 import (
 	"context"
+	"net/http"
 	"sort"
 	"sync"
 	"time"
@@ -10,6 +11,7 @@ import (
 	v1 "github.com/grafana/loki/v3/pkg/storage/bloom/v1"
 	"github.com/grafana/loki/v3/pkg/storage/stores/index/seriesvolume"
 
+	"github.com/grafana/dskit/httpgrpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
@@ -128,8 +130,20 @@ func (c *IndexClient) GetChunkRefs(ctx context.Context, userID string, from, thr
 		return nil, err
 	}
 
+	// the chunks in the cold tier can not be fetched so the query fails before fetching any chunk.
+	var coldChunks ColdChunksError
 	refs := make([]logproto.ChunkRef, 0, len(chks))
 	for _, chk := range chks {
+		if chk.Tier == index.TierCold {
+			if coldChunks.Chunks == 0 || chk.Start < coldChunks.From {
+				coldChunks.From = chk.Start
+			}
+			if chk.End > coldChunks.Through {
+				coldChunks.Through = chk.End
+			}
+			coldChunks.Chunks++
+			continue
+		}
 		refs = append(refs, logproto.ChunkRef{
 			Fingerprint: uint64(chk.Fingerprint),
 			UserID:      chk.User,
@@ -138,6 +152,10 @@ func (c *IndexClient) GetChunkRefs(ctx context.Context, userID string, from, thr
 			Checksum:    chk.Checksum,
 		})
 	}
+	if coldChunks.Chunks > 0 {
+		// the status survives the requests to the index gateways for the query to fail with a client error.
+		return nil, httpgrpc.Errorf(http.StatusUnprocessableEntity, "%s", coldChunks.Error())
+	}
 
 	return refs, err
 }
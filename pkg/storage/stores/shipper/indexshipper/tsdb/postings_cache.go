@@ -0,0 +1,209 @@
+package tsdb
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+)
+
+// postingsCacheEntryOverhead approximates the memory used by an entry of the LRU besides its key and postings.
+const postingsCacheEntryOverhead = 128
+
+// PostingsCache is an LRU cache of the postings resolved for the matchers of the queries, shared by the TSDB indexes
+// of a store. Its memory is bounded by the size of the keys and of the postings it holds.
+type PostingsCache struct {
+	maxSizeBytes int
+
+	mtx       sync.Mutex
+	lru       *simplelru.LRU[string, []storage.SeriesRef]
+	sizeBytes int
+
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evictions prometheus.Counter
+	size      prometheus.Gauge
+	entries   prometheus.Gauge
+}
+
+// NewPostingsCache returns a cache holding up to maxSizeBytes of postings, or nil when maxSizeBytes is 0.
+func NewPostingsCache(maxSizeBytes int, r prometheus.Registerer) *PostingsCache {
+	if maxSizeBytes <= 0 {
+		return nil
+	}
+
+	c := &PostingsCache{
+		maxSizeBytes: maxSizeBytes,
+		hits: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_tsdb",
+			Name:      "postings_cache_hits_total",
+			Help:      "Total number of postings served by the postings cache.",
+		}),
+		misses: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_tsdb",
+			Name:      "postings_cache_misses_total",
+			Help:      "Total number of postings not found in the postings cache.",
+		}),
+		evictions: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_tsdb",
+			Name:      "postings_cache_evictions_total",
+			Help:      "Total number of postings evicted from the postings cache to stay within its size.",
+		}),
+		size: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki_tsdb",
+			Name:      "postings_cache_size_bytes",
+			Help:      "Estimated memory used by the postings cache.",
+		}),
+		entries: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki_tsdb",
+			Name:      "postings_cache_entries",
+			Help:      "Number of postings held by the postings cache.",
+		}),
+	}
+	// the number of entries is unbounded, the entries are evicted to stay within the size.
+	c.lru, _ = simplelru.NewLRU[string, []storage.SeriesRef](math.MaxInt, func(key string, refs []storage.SeriesRef) {
+		c.sizeBytes -= postingsCacheEntrySize(key, refs)
+	})
+	return c
+}
+
+func postingsCacheEntrySize(key string, refs []storage.SeriesRef) int {
+	return len(key) + 8*len(refs) + postingsCacheEntryOverhead
+}
+
+// maxRefs returns the number of postings above which the entry of the key is larger than the cache.
+func (c *PostingsCache) maxRefs(key string) int {
+	return max(0, (c.maxSizeBytes-len(key)-postingsCacheEntryOverhead)/8)
+}
+
+// Get returns the postings of the key. The returned postings must not be modified.
+func (c *PostingsCache) Get(key string) ([]storage.SeriesRef, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	refs, ok := c.lru.Get(key)
+	if ok {
+		c.hits.Inc()
+	} else {
+		c.misses.Inc()
+	}
+	return refs, ok
+}
+
+// Put adds the postings of the key and evicts the least recently used postings until the cache fits in its size.
+// The postings larger than the cache are not added.
+func (c *PostingsCache) Put(key string, refs []storage.SeriesRef) {
+	entrySize := postingsCacheEntrySize(key, refs)
+	if entrySize > c.maxSizeBytes {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// replacing an entry doesn't call the eviction callback.
+	if prev, ok := c.lru.Peek(key); ok {
+		c.sizeBytes -= postingsCacheEntrySize(key, prev)
+	}
+	c.lru.Add(key, refs)
+	c.sizeBytes += entrySize
+
+	for c.sizeBytes > c.maxSizeBytes {
+		if _, _, ok := c.lru.RemoveOldest(); !ok {
+			break
+		}
+		c.evictions.Inc()
+	}
+	c.size.Set(float64(c.sizeBytes))
+	c.entries.Set(float64(c.lru.Len()))
+}
+
+// expandPostingsUpTo expands the postings until more than limit are read. complete is false when the postings were
+// not all read, in which case p is positioned on the last of the returned postings.
+func expandPostingsUpTo(p index.Postings, limit int) (refs []storage.SeriesRef, complete bool, err error) {
+	for p.Next() {
+		refs = append(refs, p.At())
+		if len(refs) > limit {
+			return refs, false, nil
+		}
+	}
+	return refs, true, p.Err()
+}
+
+// partiallyExpandedPostings iterates over the postings expanded by expandPostingsUpTo and then over the rest of the
+// postings they were read from.
+type partiallyExpandedPostings struct {
+	expanded index.Postings
+	rest     index.Postings
+	inRest   bool
+}
+
+func newPartiallyExpandedPostings(expanded []storage.SeriesRef, rest index.Postings) index.Postings {
+	return &partiallyExpandedPostings{expanded: index.NewListPostings(expanded), rest: rest}
+}
+
+func (p *partiallyExpandedPostings) Next() bool {
+	if !p.inRest {
+		if p.expanded.Next() {
+			return true
+		}
+		p.inRest = true
+	}
+	return p.rest.Next()
+}
+
+func (p *partiallyExpandedPostings) Seek(v storage.SeriesRef) bool {
+	if !p.inRest {
+		if p.expanded.Seek(v) {
+			return true
+		}
+		// the rest is positioned on the last expanded postings, which is lower than v.
+		p.inRest = true
+	}
+	return p.rest.Seek(v)
+}
+
+func (p *partiallyExpandedPostings) At() storage.SeriesRef {
+	if p.inRest {
+		return p.rest.At()
+	}
+	return p.expanded.At()
+}
+
+func (p *partiallyExpandedPostings) Err() error {
+	return p.rest.Err()
+}
+
+// postingsCacheKey identifies the postings of the matchers in the shard of an index.
+// The matchers are sorted since their order doesn't change the postings.
+func postingsCacheKey(indexID uint64, fpFilter index.FingerprintFilter, matchers []*labels.Matcher) string {
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatUint(indexID, 10))
+	sb.WriteByte('/')
+	if fpFilter != nil {
+		from, through := fpFilter.GetFromThrough()
+		sb.WriteString(strconv.FormatUint(uint64(from), 16))
+		sb.WriteByte('-')
+		sb.WriteString(strconv.FormatUint(uint64(through), 16))
+	}
+
+	ms := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		ms = append(ms, m.String())
+	}
+	sort.Strings(ms)
+	for _, m := range ms {
+		sb.WriteByte('/')
+		sb.WriteString(m)
+	}
+	return sb.String()
+}
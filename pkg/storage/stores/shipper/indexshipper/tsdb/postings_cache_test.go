@@ -0,0 +1,158 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+)
+
+func TestPostingsCache(t *testing.T) {
+	require.Nil(t, NewPostingsCache(0, nil))
+
+	refs := []storage.SeriesRef{1, 2, 3, 4}
+	entrySize := postingsCacheEntrySize("a", refs)
+	c := NewPostingsCache(2*entrySize, prometheus.NewRegistry())
+
+	_, ok := c.Get("a")
+	require.False(t, ok)
+
+	c.Put("a", refs)
+	c.Put("b", refs)
+	// replacing an entry doesn't account it twice.
+	c.Put("b", refs)
+	require.Equal(t, 2*entrySize, c.sizeBytes)
+
+	got, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, refs, got)
+
+	// b is the least recently used entry and is evicted to stay within the size.
+	c.Put("c", refs)
+	_, ok = c.Get("b")
+	require.False(t, ok)
+	_, ok = c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 2*entrySize, c.sizeBytes)
+	require.Equal(t, float64(1), testutil.ToFloat64(c.evictions))
+
+	// the postings larger than the cache aren't added.
+	c.Put("d", make([]storage.SeriesRef, 2*entrySize))
+	_, ok = c.Get("d")
+	require.False(t, ok)
+	require.Equal(t, float64(2), testutil.ToFloat64(c.hits))
+	require.Equal(t, float64(3), testutil.ToFloat64(c.misses))
+}
+
+func TestPostingsCacheKey(t *testing.T) {
+	foo := labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")
+	bazz := labels.MustNewMatcher(labels.MatchRegexp, "bazz", "b.*")
+	shard := index.NewShard(0, 2)
+
+	// the order of the matchers doesn't matter.
+	require.Equal(t, postingsCacheKey(1, nil, []*labels.Matcher{foo, bazz}), postingsCacheKey(1, nil, []*labels.Matcher{bazz, foo}))
+	require.NotEqual(t, postingsCacheKey(1, nil, []*labels.Matcher{foo}), postingsCacheKey(2, nil, []*labels.Matcher{foo}))
+	require.NotEqual(t, postingsCacheKey(1, nil, []*labels.Matcher{foo}), postingsCacheKey(1, shard, []*labels.Matcher{foo}))
+}
+
+func TestTSDBIndex_PostingsCache(t *testing.T) {
+	series := []LoadableSeries{
+		{
+			Labels: mustParseLabels(`{foo="bar"}`),
+			Chunks: []index.ChunkMeta{{MinTime: 1, MaxTime: 10, Checksum: 1}},
+		},
+		{
+			Labels: mustParseLabels(`{foo="bar", bazz="buzz"}`),
+			Chunks: []index.ChunkMeta{{MinTime: 1, MaxTime: 10, Checksum: 2}},
+		},
+		{
+			Labels: mustParseLabels(`{foo="bard"}`),
+			Chunks: []index.ChunkMeta{{MinTime: 1, MaxTime: 10, Checksum: 3}},
+		},
+	}
+	idx := BuildIndex(t, t.TempDir(), series).Index.(*TSDBIndex)
+	cache := NewPostingsCache(1<<20, prometheus.NewRegistry())
+	idx.SetPostingsCache(cache)
+
+	matcher := labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")
+	expected := []ChunkRef{
+		{User: "fake", Fingerprint: model.Fingerprint(series[0].Labels.Hash()), Start: 1, End: 10, Checksum: 1},
+		{User: "fake", Fingerprint: model.Fingerprint(series[1].Labels.Hash()), Start: 1, End: 10, Checksum: 2},
+	}
+	for i := 0; i < 2; i++ {
+		refs, err := idx.GetChunkRefs(context.Background(), "fake", 1, 10, nil, nil, matcher)
+		require.NoError(t, err)
+		require.ElementsMatch(t, expected, refs)
+	}
+	require.Equal(t, float64(1), testutil.ToFloat64(cache.misses))
+	require.Equal(t, float64(1), testutil.ToFloat64(cache.hits))
+
+	// the postings of a shard are cached separately.
+	shard := index.ShardAnnotation{Shard: 0, Of: 2}
+	_, err := idx.GetChunkRefs(context.Background(), "fake", 1, 10, nil, &shard, matcher)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), testutil.ToFloat64(cache.misses))
+}
+
+func TestTSDBIndex_PostingsCacheTooSmall(t *testing.T) {
+	series := []LoadableSeries{
+		{
+			Labels: mustParseLabels(`{foo="bar"}`),
+			Chunks: []index.ChunkMeta{{MinTime: 1, MaxTime: 10, Checksum: 1}},
+		},
+		{
+			Labels: mustParseLabels(`{foo="bar", bazz="buzz"}`),
+			Chunks: []index.ChunkMeta{{MinTime: 1, MaxTime: 10, Checksum: 2}},
+		},
+	}
+	idx := BuildIndex(t, t.TempDir(), series).Index.(*TSDBIndex)
+	matcher := labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")
+	// the cache only fits a single postings for the key of the matcher.
+	cache := NewPostingsCache(postingsCacheEntrySize(postingsCacheKey(idx.id, nil, []*labels.Matcher{matcher}), make([]storage.SeriesRef, 1)), prometheus.NewRegistry())
+	idx.SetPostingsCache(cache)
+
+	refs, err := idx.GetChunkRefs(context.Background(), "fake", 1, 10, nil, nil, matcher)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []ChunkRef{
+		{User: "fake", Fingerprint: model.Fingerprint(series[0].Labels.Hash()), Start: 1, End: 10, Checksum: 1},
+		{User: "fake", Fingerprint: model.Fingerprint(series[1].Labels.Hash()), Start: 1, End: 10, Checksum: 2},
+	}, refs)
+	require.Equal(t, 0, cache.lru.Len())
+}
+
+func TestPartiallyExpandedPostings(t *testing.T) {
+	list := []storage.SeriesRef{1, 3, 5, 7, 9}
+
+	p := index.NewListPostings(list)
+	refs, complete, err := expandPostingsUpTo(p, 1)
+	require.NoError(t, err)
+	require.False(t, complete)
+	got, err := index.ExpandPostings(newPartiallyExpandedPostings(refs, p))
+	require.NoError(t, err)
+	require.Equal(t, list, got)
+
+	// seeking past the expanded postings continues in the rest.
+	p = index.NewListPostings(list)
+	refs, _, err = expandPostingsUpTo(p, 1)
+	require.NoError(t, err)
+	pp := newPartiallyExpandedPostings(refs, p)
+	require.True(t, pp.Seek(2))
+	require.Equal(t, storage.SeriesRef(3), pp.At())
+	require.True(t, pp.Seek(6))
+	require.Equal(t, storage.SeriesRef(7), pp.At())
+	require.True(t, pp.Next())
+	require.Equal(t, storage.SeriesRef(9), pp.At())
+	require.False(t, pp.Next())
+
+	refs, complete, err = expandPostingsUpTo(index.NewListPostings(list), len(list))
+	require.NoError(t, err)
+	require.True(t, complete)
+	require.Equal(t, list, refs)
+}
@@ -83,6 +83,10 @@ type IndexReader interface {
 	// The names returned are sorted.
 	LabelNamesFor(ids ...storage.SeriesRef) ([]string, error)
 
+	// LabelStats returns the precomputed cardinality statistics of the label name.
+	// ok is false when the index doesn't hold statistics.
+	LabelStats(name string) (stats index.LabelStats, ok bool)
+
 	// Close releases the underlying resources of the reader.
 	Close() error
 }
@@ -100,6 +104,11 @@ func PostingsForMatchers(ix IndexReader, fpFilter index.FingerprintFilter, ms ..
 		}
 	}
 
+	ms, empty := sortMatchersByCost(ix, ms, labelMustBeSet)
+	if empty {
+		return index.EmptyPostings(), nil
+	}
+
 	for _, m := range ms {
 		if labelMustBeSet[m.Name] {
 			// If this matcher must be non-empty, we can be smarter.
@@ -130,6 +139,9 @@ func PostingsForMatchers(ix IndexReader, fpFilter index.FingerprintFilter, ms ..
 				if err != nil {
 					return nil, err
 				}
+				if index.IsEmptyPostingsType(it) {
+					return index.EmptyPostings(), nil
+				}
 				its = append(its, it)
 			} else { // l="a"
 				// Non-Not matcher, use normal postingsForMatcher.
@@ -137,6 +149,10 @@ func PostingsForMatchers(ix IndexReader, fpFilter index.FingerprintFilter, ms ..
 				if err != nil {
 					return nil, err
 				}
+				// The intersection is empty, skip the remaining matchers.
+				if index.IsEmptyPostingsType(it) {
+					return index.EmptyPostings(), nil
+				}
 				its = append(its, it)
 			}
 		} else { // l=""
@@ -171,6 +187,48 @@ func PostingsForMatchers(ix IndexReader, fpFilter index.FingerprintFilter, ms ..
 	return it, nil
 }
 
+// sortMatchersByCost orders the matchers by the estimated cost of their postings using the label statistics of the
+// index, so that the cheap and selective matchers are evaluated first and an empty intersection is found before
+// the values of high cardinality labels are matched. It also reports when a label which must be set isn't in the
+// index. The matchers are left in order when the index has no statistics.
+func sortMatchersByCost(ix IndexReader, ms []*labels.Matcher, labelMustBeSet map[string]bool) ([]*labels.Matcher, bool) {
+	costs := make(map[*labels.Matcher]uint64, len(ms))
+	for _, m := range ms {
+		stats, ok := ix.LabelStats(m.Name)
+		if !ok {
+			return ms, false
+		}
+		if labelMustBeSet[m.Name] && stats.Series == 0 {
+			return ms, true
+		}
+		costs[m] = matcherCost(m, stats)
+	}
+
+	sorted := make([]*labels.Matcher, len(ms))
+	copy(sorted, ms)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return costs[sorted[i]] < costs[sorted[j]]
+	})
+	return sorted, false
+}
+
+// matcherCost estimates the number of label values and series read to resolve the postings of the matcher.
+func matcherCost(m *labels.Matcher, stats index.LabelStats) uint64 {
+	switch m.Type {
+	case labels.MatchEqual, labels.MatchNotEqual:
+		if m.Value != "" {
+			// A single postings list is read.
+			return 1 + stats.AvgValueSeries()
+		}
+	case labels.MatchRegexp, labels.MatchNotRegexp:
+		if setMatches := findSetMatches(m.GetRegexString()); len(setMatches) > 0 {
+			return uint64(len(setMatches)) * (1 + stats.AvgValueSeries())
+		}
+	}
+	// All the values of the label are matched and the postings of the matching ones are read.
+	return stats.Values + stats.Series
+}
+
 func postingsForMatcher(ix IndexReader, fpFilter index.FingerprintFilter, m *labels.Matcher) (index.Postings, error) {
 	// This method will not return postings for missing labels.
 
@@ -2,10 +2,12 @@ package tsdb
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"testing"
 	"time"
 
+	"github.com/go-kit/log"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
@@ -129,3 +131,55 @@ func TestQueryIndex(t *testing.T) {
 	require.Equal(t, int64(1), mint)
 	require.Equal(t, int64(50), maxt)
 }
+
+func TestSortMatchersByCost(t *testing.T) {
+	var series []LoadableSeries
+	for i := 0; i < 10; i++ {
+		app := "a"
+		if i == 0 {
+			app = "b"
+		}
+		series = append(series, LoadableSeries{
+			Labels: labels.FromStrings("app", app, "pod", fmt.Sprint(i)),
+			Chunks: []index.ChunkMeta{{MinTime: 1, MaxTime: 10, Checksum: uint32(i)}},
+		})
+	}
+	dst := BuildIndex(t, t.TempDir(), series)
+	reader, err := index.NewFileReader(dst.Path())
+	require.Nil(t, err)
+	defer reader.Close()
+
+	var (
+		podRegexp = labels.MustNewMatcher(labels.MatchRegexp, "pod", ".+")
+		appSet    = labels.MustNewMatcher(labels.MatchRegexp, "app", "a|b")
+		appEqual  = labels.MustNewMatcher(labels.MatchEqual, "app", "a")
+		podEqual  = labels.MustNewMatcher(labels.MatchEqual, "pod", "3")
+		missing   = labels.MustNewMatcher(labels.MatchEqual, "missing", "x")
+	)
+
+	mustBeSet := map[string]bool{"pod": true, "app": true}
+	ms, empty := sortMatchersByCost(reader, []*labels.Matcher{podRegexp, appSet, appEqual, podEqual}, mustBeSet)
+	require.False(t, empty)
+	require.Equal(t, []*labels.Matcher{podEqual, appEqual, appSet, podRegexp}, ms)
+
+	// a label which must be set but isn't in the index matches no series.
+	mustBeSet["missing"] = true
+	_, empty = sortMatchersByCost(reader, []*labels.Matcher{appEqual, missing}, mustBeSet)
+	require.True(t, empty)
+
+	p, err := PostingsForMatchers(reader, nil, appEqual, missing)
+	require.Nil(t, err)
+	require.False(t, p.Next())
+
+	p, err = PostingsForMatchers(reader, nil, podRegexp, appEqual, podEqual)
+	require.Nil(t, err)
+	refs, err := index.ExpandPostings(p)
+	require.Nil(t, err)
+	require.Len(t, refs, 1)
+
+	// the matchers are left in order when the index has no statistics.
+	head := NewHead("fake", NewMetrics(nil), log.NewNopLogger())
+	ms, empty = sortMatchersByCost(head.Index(), []*labels.Matcher{podRegexp, appEqual}, mustBeSet)
+	require.False(t, empty)
+	require.Equal(t, []*labels.Matcher{podRegexp, appEqual}, ms)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/v3/pkg/storage/chunk"
 	"github.com/grafana/loki/v3/pkg/storage/stores/index/seriesvolume"
@@ -120,10 +121,15 @@ func (f *TSDBFile) Reader() (io.ReadSeeker, error) {
 // and translates the IndexReader to an Index implementation
 // It loads the file into memory and doesn't keep a file descriptor open
 type TSDBIndex struct {
-	reader      IndexReader
-	chunkFilter chunk.RequestChunkFilterer
+	// id identifies the index in the postings cache.
+	id            uint64
+	reader        IndexReader
+	chunkFilter   chunk.RequestChunkFilterer
+	postingsCache *PostingsCache
 }
 
+var tsdbIndexIDs atomic.Uint64
+
 // Return the index as well as the underlying raw file reader which isn't exposed as an index
 // method but is helpful for building an io.reader for the index shipper
 func NewTSDBIndexFromFile(location string) (*TSDBIndex, GetRawFileReaderFunc, error) {
@@ -139,6 +145,7 @@ func NewTSDBIndexFromFile(location string) (*TSDBIndex, GetRawFileReaderFunc, er
 
 func NewTSDBIndex(reader IndexReader) *TSDBIndex {
 	return &TSDBIndex{
+		id:     tsdbIndexIDs.Inc(),
 		reader: reader,
 	}
 }
@@ -156,6 +163,11 @@ func (i *TSDBIndex) SetChunkFilterer(chunkFilter chunk.RequestChunkFilterer) {
 	i.chunkFilter = chunkFilter
 }
 
+// SetPostingsCache makes the index cache the postings of the matchers of the queries.
+func (i *TSDBIndex) SetPostingsCache(postingsCache *PostingsCache) {
+	i.postingsCache = postingsCache
+}
+
 // fn must NOT capture it's arguments. They're reused across series iterations and returned to
 // a pool after completion.
 // Iteration will stop if the callback returns true.
@@ -205,11 +217,33 @@ func (i *TSDBIndex) forPostings(
 	matchers []*labels.Matcher,
 	fn func(index.Postings) error,
 ) error {
-	p, err := PostingsForMatchers(i.reader, fpFilter, matchers...)
-	if err != nil {
-		return err
+	if i.postingsCache == nil {
+		p, err := PostingsForMatchers(i.reader, fpFilter, matchers...)
+		if err != nil {
+			return err
+		}
+		return fn(p)
+	}
+
+	key := postingsCacheKey(i.id, fpFilter, matchers)
+	refs, ok := i.postingsCache.Get(key)
+	if !ok {
+		p, err := PostingsForMatchers(i.reader, fpFilter, matchers...)
+		if err != nil {
+			return err
+		}
+		// the postings are only expanded while they fit in the cache.
+		var complete bool
+		refs, complete, err = expandPostingsUpTo(p, i.postingsCache.maxRefs(key))
+		if err != nil {
+			return err
+		}
+		if !complete {
+			return fn(newPartiallyExpandedPostings(refs, p))
+		}
+		i.postingsCache.Put(key, refs)
 	}
-	return fn(p)
+	return fn(index.NewListPostings(refs))
 }
 
 func (i *TSDBIndex) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, res []ChunkRef, fpFilter index.FingerprintFilter, matchers ...*labels.Matcher) ([]ChunkRef, error) {
@@ -227,6 +261,7 @@ func (i *TSDBIndex) GetChunkRefs(ctx context.Context, userID string, from, throu
 				Start:       chk.From(),
 				End:         chk.Through(),
 				Checksum:    chk.Checksum,
+				Tier:        chk.Tier,
 			})
 		}
 		return false
@@ -2,12 +2,14 @@ package tsdb
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"math"
 	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
@@ -20,9 +22,27 @@ import (
 	"github.com/grafana/loki/v3/pkg/storage/stores/index"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/downloads"
+	shipperindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/index"
 	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
 )
 
+// IndexCfg is the configuration of the TSDB shipper: the index shipper configuration and the TSDB specific settings.
+type IndexCfg struct {
+	indexshipper.Config `yaml:",inline"`
+	PostingsCacheSize   flagext.Bytes `yaml:"postings_cache_size" category:"experimental"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *IndexCfg) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers flags.
+func (cfg *IndexCfg) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	cfg.Config.RegisterFlagsWithPrefix(prefix, f)
+	f.Var(&cfg.PostingsCacheSize, prefix+"shipper.postings-cache-size", "Experimental. Maximum memory used by the LRU cache of the postings resolved for the label matchers of the queries, shared by the TSDB indexes of all the periods. It speeds up the repeated queries with negative or regular expression matchers over high cardinality labels, mostly on the index gateways. 0 disables the cache.")
+}
+
 type IndexWriter interface {
 	Append(userID string, ls labels.Labels, fprint uint64, chks tsdbindex.ChunkMetas) error
 }
@@ -44,6 +64,7 @@ func NewStore(
 	objectClient client.ObjectClient,
 	limits downloads.Limits,
 	tableRange config.TableRange,
+	postingsCache *PostingsCache,
 	reg prometheus.Registerer,
 	logger log.Logger,
 ) (
@@ -56,7 +77,7 @@ func NewStore(
 		logger: logger,
 	}
 
-	if err := storeInstance.init(name, prefix, indexShipperCfg, schemaCfg, objectClient, limits, tableRange, reg); err != nil {
+	if err := storeInstance.init(name, prefix, indexShipperCfg, schemaCfg, objectClient, limits, tableRange, postingsCache, reg); err != nil {
 		return nil, nil, err
 	}
 
@@ -64,7 +85,19 @@ func NewStore(
 }
 
 func (s *store) init(name, prefix string, indexShipperCfg indexshipper.Config, schemaCfg config.SchemaConfig, objectClient client.ObjectClient,
-	limits downloads.Limits, tableRange config.TableRange, reg prometheus.Registerer) error {
+	limits downloads.Limits, tableRange config.TableRange, postingsCache *PostingsCache, reg prometheus.Registerer) error {
+
+	openFn := OpenShippableTSDB
+	if postingsCache != nil {
+		openFn = func(p string) (shipperindex.Index, error) {
+			idx, err := OpenShippableTSDB(p)
+			if err != nil {
+				return nil, err
+			}
+			idx.(*TSDBFile).Index.(*TSDBIndex).SetPostingsCache(postingsCache)
+			return idx, nil
+		}
+	}
 
 	var err error
 	s.indexShipper, err = indexshipper.NewIndexShipper(
@@ -73,7 +106,7 @@ func (s *store) init(name, prefix string, indexShipperCfg indexshipper.Config, s
 		objectClient,
 		limits,
 		nil,
-		OpenShippableTSDB,
+		openFn,
 		tableRange,
 		prometheus.WrapRegistererWithPrefix("loki_tsdb_shipper_", reg),
 		s.logger,
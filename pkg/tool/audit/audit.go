@@ -60,6 +60,19 @@ func GetObjectClient(cfg Config) (client.ObjectClient, error) {
 	return objClient, nil
 }
 
+// GetColdObjectClient returns the client of the cold store, which is nil when no cold store is configured.
+func GetColdObjectClient(cfg Config) (client.ObjectClient, error) {
+	if cfg.ColdStore == "" {
+		return nil, nil
+	}
+	objClient, err := storage.NewObjectClient(cfg.ColdStore, "tool-audit-cold", cfg.StorageConfig, storage.NewClientMetrics())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create cold store object client: %w", err)
+	}
+
+	return client.NewPrefixedObjectClient(objClient, cfg.ColdStoreKeyPrefix), nil
+}
+
 func DownloadIndexFile(ctx context.Context, cfg Config, cloudIndexPath string, objClient client.ObjectClient, logger log.Logger) (string, error) {
 	splitPath := strings.Split(cloudIndexPath, "/")
 	localFileName := splitPath[len(splitPath)-1]
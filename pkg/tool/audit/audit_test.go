@@ -1,17 +1,30 @@
 package audit
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/v3/pkg/chunkenc"
 	"github.com/grafana/loki/v3/pkg/compactor"
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compression"
+	ingesterclient "github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
 )
 
 var errObjectNotFound = errors.New("object not found")
@@ -74,3 +87,91 @@ func TestAuditIndex(t *testing.T) {
 	require.Equal(t, 4, found)
 	require.Equal(t, 1, missing)
 }
+
+func TestScrubIndex(t *testing.T) {
+	ctx := context.Background()
+	schemaCfg := config.SchemaConfig{Configs: []config.PeriodConfig{{
+		From:       config.DayTime{Time: 0},
+		IndexType:  "tsdb",
+		ObjectType: "filesystem",
+		Schema:     "v13",
+		IndexTables: config.IndexPeriodicTableConfig{
+			PeriodicTableConfig: config.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+		},
+	}}}
+	objClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	chunkClient := client.NewClient(objClient, client.FSEncoder, schemaCfg)
+	coldFSClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	coldObjClient := client.NewPrefixedObjectClient(coldFSClient, "cold/")
+	coldChunkClient := client.NewClient(coldObjClient, client.FSEncoder, schemaCfg)
+
+	now := model.Now()
+	healthy := newTestChunk(t, labels.FromStrings("app", "healthy"), now)
+	corrupt := newTestChunk(t, labels.FromStrings("app", "corrupt"), now)
+	missing := newTestChunk(t, labels.FromStrings("app", "missing"), now)
+	cold := newTestChunk(t, labels.FromStrings("app", "cold"), now)
+	coldMissing := newTestChunk(t, labels.FromStrings("app", "cold-missing"), now)
+	require.NoError(t, chunkClient.PutChunks(ctx, []chunk.Chunk{healthy, corrupt}))
+	require.NoError(t, coldChunkClient.PutChunks(ctx, []chunk.Chunk{cold}))
+
+	// flip a byte of the data of the corrupt chunk.
+	data, err := corrupt.Encoded()
+	require.NoError(t, err)
+	data = slices.Clone(data)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, objClient.PutObject(ctx, client.FSEncoder(schemaCfg, corrupt), bytes.NewReader(data)))
+
+	chunkEntry := func(c chunk.Chunk) retention.ChunkEntry {
+		return retention.ChunkEntry{ChunkRef: retention.ChunkRef{UserID: []byte(c.UserID), ChunkID: []byte(schemaCfg.ExternalKey(c.ChunkRef))}}
+	}
+	coldChunkEntry := func(c chunk.Chunk) retention.ChunkEntry {
+		ce := chunkEntry(c)
+		ce.Tier = tsdbindex.TierCold
+		return ce
+	}
+	invalidEntry := retention.ChunkEntry{ChunkRef: retention.ChunkRef{UserID: []byte("fake"), ChunkID: []byte("invalid")}}
+	compactedIdx := testCompactedIdx{chunks: []retention.ChunkEntry{
+		chunkEntry(healthy), chunkEntry(corrupt), chunkEntry(missing), coldChunkEntry(cold), coldChunkEntry(coldMissing), invalidEntry,
+	}}
+
+	report, err := ScrubCompactedIndex(ctx, objClient, coldObjClient, schemaCfg, compactedIdx, 2, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Healthy)
+	require.Equal(t, []string{schemaCfg.ExternalKey(missing.ChunkRef)}, report.Missing)
+	require.Len(t, report.Corrupt, 1)
+	require.Equal(t, schemaCfg.ExternalKey(corrupt.ChunkRef), report.Corrupt[0].ChunkID)
+	require.ErrorIs(t, report.Corrupt[0].Err, chunk.ErrInvalidChecksum)
+	require.Equal(t, []string{schemaCfg.ExternalKey(coldMissing.ChunkRef)}, report.ColdMissing)
+	require.Len(t, report.InvalidEntries, 1)
+	require.Equal(t, "invalid", report.InvalidEntries[0].ChunkID)
+	require.False(t, report.IsHealthy())
+
+	// only the chunks of the standard tier are repaired.
+	plan := NewRepairPlan("index_19856", "fake", report)
+	require.ElementsMatch(t, []string{schemaCfg.ExternalKey(missing.ChunkRef), schemaCfg.ExternalKey(corrupt.ChunkRef)}, plan.ChunkIDs)
+
+	// the chunks of the cold tier are skipped without a cold store.
+	report, err = ScrubCompactedIndex(ctx, objClient, nil, schemaCfg, compactedIdx, 2, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Healthy)
+	require.Equal(t, 2, report.ColdSkipped)
+	require.Empty(t, report.ColdMissing)
+	require.Equal(t, []string{schemaCfg.ExternalKey(missing.ChunkRef)}, report.Missing)
+}
+
+func newTestChunk(t *testing.T, lbs labels.Labels, through model.Time) chunk.Chunk {
+	t.Helper()
+	from := through.Add(-time.Hour)
+	memChunk := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, compression.Snappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, 256*1024, 1500*1024)
+	for ts := from; !ts.After(through); ts = ts.Add(time.Minute) {
+		_, err := memChunk.Append(&logproto.Entry{Timestamp: ts.Time(), Line: ts.String()})
+		require.NoError(t, err)
+	}
+	require.NoError(t, memChunk.Close())
+
+	c := chunk.NewChunk("fake", ingesterclient.Fingerprint(lbs), lbs, chunkenc.NewFacade(memChunk, 256*1024, 1500*1024), from, through)
+	require.NoError(t, c.Encode())
+	return c
+}
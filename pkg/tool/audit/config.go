@@ -29,6 +29,11 @@ type Config struct {
 	Concurrency   int                      `yaml:"concurrency"`
 	WorkingDir    string                   `yaml:"working_dir"`
 	Period        string                   `yaml:"period,omitempty"`
+	// DeleteRequestStore is the store where the repair plans are submitted to the compactor.
+	DeleteRequestStore string `yaml:"delete_request_store,omitempty"`
+	// ColdStore is the store where the compactor moves the chunks of the cold tier.
+	ColdStore          string `yaml:"cold_store,omitempty"`
+	ColdStoreKeyPrefix string `yaml:"cold_store_key_prefix,omitempty"`
 }
 
 func (c *Config) RegisterFlags(f *flag.FlagSet) {
@@ -40,6 +45,9 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&c.Concurrency, "concurrency", 100, "amount of files to check concurrently")
 	f.StringVar(&c.WorkingDir, "working-dir", ".", "working directory to store downloaded files")
 	f.StringVar(&c.Period, "period", "", "the table period in a format like 19959")
+	f.StringVar(&c.DeleteRequestStore, "delete-request-store", "", "store used by the compactor for managing delete requests, where the repair plans are submitted. Defaults to the object store of the last period")
+	f.StringVar(&c.ColdStore, "cold-store", "", "store where the compactor moves the chunks of the cold tier, where they are scrubbed. The chunks of the cold tier are skipped when empty")
+	f.StringVar(&c.ColdStoreKeyPrefix, "cold-store.key-prefix", "cold/", "path prefix of the chunks in the cold store")
 }
 
 func (c *Config) Validate() error {
@@ -58,6 +66,9 @@ func (c *Config) Validate() error {
 	if c.Period == "" {
 		return fmt.Errorf("period argument missing. Use -period flag or add 'period' to the config file")
 	}
+	if c.DeleteRequestStore == "" && len(c.SchemaConfig.Configs) > 0 {
+		c.DeleteRequestStore = c.SchemaConfig.Configs[len(c.SchemaConfig.Configs)-1].ObjectType
+	}
 	return nil
 }
 
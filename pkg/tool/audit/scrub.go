@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	progressbar "github.com/schollz/progressbar/v3"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/grafana/loki/v3/pkg/compactor"
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/storage"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+)
+
+// CorruptChunk is a chunk whose object can't be decoded.
+type CorruptChunk struct {
+	ChunkID string
+	Err     error
+}
+
+// InvalidIndexEntry is an index entry whose chunk ID can't be parsed.
+type InvalidIndexEntry struct {
+	ChunkID string
+	Err     error
+}
+
+// ScrubReport lists the index entries pointing to missing or corrupt chunks.
+type ScrubReport struct {
+	Healthy int
+	// Missing and Corrupt are the chunks of the standard tier, which can be repaired.
+	Missing []string
+	Corrupt []CorruptChunk
+	// ColdMissing and ColdCorrupt are the chunks of the cold tier. They are never repaired since the index entries
+	// removed by the compactor have their chunks deleted from both tiers.
+	ColdMissing []string
+	ColdCorrupt []CorruptChunk
+	// ColdSkipped is the number of chunks of the cold tier which are not checked since no cold store is configured.
+	ColdSkipped int
+	// InvalidEntries are the index entries whose chunk ID can't be parsed.
+	InvalidEntries []InvalidIndexEntry
+}
+
+// IsHealthy tells if all the checked chunks of the index are present and can be decoded.
+func (r ScrubReport) IsHealthy() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0 && len(r.ColdMissing) == 0 && len(r.ColdCorrupt) == 0 && len(r.InvalidEntries) == 0
+}
+
+// DanglingChunkIDs returns the IDs of the missing and corrupt chunks of the standard tier.
+func (r ScrubReport) DanglingChunkIDs() []string {
+	ids := slices.Clone(r.Missing)
+	for _, c := range r.Corrupt {
+		ids = append(ids, c.ChunkID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Scrub downloads the index and verifies that all its chunks are present and can be decoded.
+func Scrub(ctx context.Context, cloudIndexPath, table string, cfg Config, logger log.Logger) (ScrubReport, error) {
+	level.Info(logger).Log("msg", "scrubbing index", "index", cloudIndexPath, "table", table, "tenant", cfg.Tenant, "working_dir", cfg.WorkingDir)
+
+	objClient, err := GetObjectClient(cfg)
+	if err != nil {
+		return ScrubReport{}, err
+	}
+	coldObjClient, err := GetColdObjectClient(cfg)
+	if err != nil {
+		return ScrubReport{}, err
+	}
+
+	localFile, err := DownloadIndexFile(ctx, cfg, cloudIndexPath, objClient, logger)
+	if err != nil {
+		return ScrubReport{}, err
+	}
+
+	compactedIdx, err := ParseCompactexIndex(ctx, localFile, table, cfg)
+	if err != nil {
+		return ScrubReport{}, err
+	}
+	defer compactedIdx.Cleanup()
+
+	return ScrubCompactedIndex(ctx, objClient, coldObjClient, cfg.SchemaConfig, compactedIdx, cfg.Concurrency, logger)
+}
+
+// ScrubCompactedIndex fetches the chunks of the index and decodes them, which verifies their checksum and that their
+// header matches the index entry. The chunks of the cold tier are fetched from coldObjClient, and skipped when it is nil.
+// An error is returned when a chunk can't be fetched for another reason than it is missing, so that the healthy chunks
+// are never reported.
+func ScrubCompactedIndex(ctx context.Context, objClient, coldObjClient client.ObjectClient, schemaCfg config.SchemaConfig, compactedIdx compactor.CompactedIndex, parallelism int, logger log.Logger) (ScrubReport, error) {
+	var (
+		report ScrubReport
+		mtx    sync.Mutex
+	)
+	bar := progressbar.NewOptions(-1,
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetDescription("Chunks scrubbed"),
+	)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(parallelism)
+	err := compactedIdx.ForEachChunk(ctx, func(ce retention.ChunkEntry) (deleteChunk bool, err error) {
+		bar.Add(1) // nolint:errcheck
+		userID, chunkID := string(ce.UserID), string(ce.ChunkID)
+		c, parseErr := chunk.ParseExternalKey(userID, chunkID)
+		if parseErr != nil {
+			mtx.Lock()
+			report.InvalidEntries = append(report.InvalidEntries, InvalidIndexEntry{ChunkID: chunkID, Err: parseErr})
+			mtx.Unlock()
+			level.Warn(logger).Log("msg", "index entry has an invalid chunk ID", "chunk_id", chunkID, "err", parseErr)
+			return false, ctx.Err()
+		}
+
+		cold := ce.Tier == tsdbindex.TierCold
+		chunkObjClient := objClient
+		if cold {
+			if coldObjClient == nil {
+				mtx.Lock()
+				report.ColdSkipped++
+				mtx.Unlock()
+				return false, ctx.Err()
+			}
+			chunkObjClient = coldObjClient
+		}
+
+		g.Go(func() error {
+			found, decodeErr, err := scrubChunk(ctx, chunkObjClient, schemaCfg, c)
+			if err != nil {
+				return fmt.Errorf("failed to fetch chunk %s: %w", chunkID, err)
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			switch {
+			case !found && cold:
+				report.ColdMissing = append(report.ColdMissing, chunkID)
+				level.Warn(logger).Log("msg", "chunk is missing from the cold store", "chunk_id", chunkID)
+			case !found:
+				report.Missing = append(report.Missing, chunkID)
+				level.Warn(logger).Log("msg", "chunk is missing", "chunk_id", chunkID)
+			case decodeErr != nil && cold:
+				report.ColdCorrupt = append(report.ColdCorrupt, CorruptChunk{ChunkID: chunkID, Err: decodeErr})
+				level.Warn(logger).Log("msg", "chunk of the cold store is corrupt", "chunk_id", chunkID, "err", decodeErr)
+			case decodeErr != nil:
+				report.Corrupt = append(report.Corrupt, CorruptChunk{ChunkID: chunkID, Err: decodeErr})
+				level.Warn(logger).Log("msg", "chunk is corrupt", "chunk_id", chunkID, "err", decodeErr)
+			default:
+				report.Healthy++
+			}
+			return nil
+		})
+
+		return false, ctx.Err()
+	})
+	if waitErr := g.Wait(); waitErr != nil {
+		return ScrubReport{}, waitErr
+	}
+	if err != nil {
+		return ScrubReport{}, err
+	}
+
+	slices.Sort(report.Missing)
+	slices.SortFunc(report.Corrupt, compareCorruptChunks)
+	slices.Sort(report.ColdMissing)
+	slices.SortFunc(report.ColdCorrupt, compareCorruptChunks)
+	slices.SortFunc(report.InvalidEntries, func(a, b InvalidIndexEntry) int {
+		return strings.Compare(a.ChunkID, b.ChunkID)
+	})
+	return report, nil
+}
+
+func compareCorruptChunks(a, b CorruptChunk) int {
+	return strings.Compare(a.ChunkID, b.ChunkID)
+}
+
+// scrubChunk fetches the chunk and returns whether it was found and the error decoding it.
+func scrubChunk(ctx context.Context, objClient client.ObjectClient, schemaCfg config.SchemaConfig, c chunk.Chunk) (found bool, decodeErr error, err error) {
+	reader, _, err := objClient.GetObject(ctx, chunkObjectKey(objClient, schemaCfg, c))
+	if err != nil {
+		if objClient.IsObjectNotFoundErr(err) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return false, nil, err
+	}
+	return true, c.Decode(chunk.NewDecodeContext(), buf.Bytes()), nil
+}
+
+// chunkObjectKey returns the key of the chunk in the object storage, which is encoded by the filesystem store.
+func chunkObjectKey(objClient client.ObjectClient, schemaCfg config.SchemaConfig, c chunk.Chunk) string {
+	raw := objClient
+	if prefixed, ok := objClient.(client.PrefixedObjectClient); ok {
+		raw = prefixed.GetDownstream()
+	}
+	if _, ok := raw.(*local.FSObjectClient); ok {
+		return client.FSEncoder(schemaCfg, c)
+	}
+	return schemaCfg.ExternalKey(c.ChunkRef)
+}
+
+// NewRepairPlan returns the plan removing the index entries of the missing and corrupt chunks of the standard tier of
+// the report.
+func NewRepairPlan(table, tenant string, report ScrubReport) retention.RepairPlan {
+	return retention.RepairPlan{
+		Table:     table,
+		UserID:    tenant,
+		ChunkIDs:  report.DanglingChunkIDs(),
+		CreatedAt: model.Now(),
+	}
+}
+
+// SubmitRepairPlan writes the repair plan in the delete request store, where the compactor picks it up the next time
+// it applies retention.
+func SubmitRepairPlan(ctx context.Context, cfg Config, plan retention.RepairPlan) error {
+	objClient, err := storage.NewObjectClient(cfg.DeleteRequestStore, "tool-audit", cfg.StorageConfig, storage.NewClientMetrics())
+	if err != nil {
+		return fmt.Errorf("couldn't create delete request store object client: %w", err)
+	}
+	defer objClient.Stop()
+
+	return retention.WriteRepairPlan(ctx, objClient, plan)
+}
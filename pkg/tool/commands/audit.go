@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -20,6 +21,9 @@ type AuditCommand struct {
 
 	configFile string
 
+	repairPlanFile   string
+	submitRepairPlan bool
+
 	extraArgs []string
 }
 
@@ -49,6 +53,62 @@ func (a *AuditCommand) auditIndex(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+func (a *AuditCommand) scrubIndex(_ *kingpin.ParseContext) error {
+	logger := log.NewLogfmtLogger(os.Stdout)
+
+	var auditCfg audit.Config
+	if err := util_cfg.DefaultUnmarshal(&auditCfg, a.extraArgs, flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "failed parsing config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := auditCfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed validating config: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	report, err := audit.Scrub(ctx, a.path, auditCfg.Period, auditCfg, logger)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "finished scrubbing index", "chunks_healthy", report.Healthy, "chunks_missing", len(report.Missing), "chunks_corrupt", len(report.Corrupt),
+		"cold_chunks_missing", len(report.ColdMissing), "cold_chunks_corrupt", len(report.ColdCorrupt), "cold_chunks_skipped", report.ColdSkipped, "invalid_index_entries", len(report.InvalidEntries))
+	if report.ColdSkipped > 0 {
+		level.Warn(logger).Log("msg", "the chunks of the cold tier were not scrubbed, give cold_store to scrub them", "count", report.ColdSkipped)
+	}
+	if report.IsHealthy() {
+		level.Info(logger).Log("msg", "your index is healthy", "index", a.path)
+		return nil
+	}
+	level.Error(logger).Log("msg", "your index points to missing or corrupt chunks, please check previous logs for more information", "index", a.path)
+	if len(report.ColdMissing) > 0 || len(report.ColdCorrupt) > 0 || len(report.InvalidEntries) > 0 {
+		level.Warn(logger).Log("msg", "the chunks of the cold tier and the invalid index entries are never repaired, they have to be fixed manually")
+	}
+
+	if (a.repairPlanFile == "" && !a.submitRepairPlan) || len(report.DanglingChunkIDs()) == 0 {
+		return nil
+	}
+	periodCfg := auditCfg.SchemaConfig.Configs[len(auditCfg.SchemaConfig.Configs)-1]
+	plan := audit.NewRepairPlan(periodCfg.IndexTables.Prefix+auditCfg.Period, auditCfg.Tenant, report)
+	if a.repairPlanFile != "" {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(a.repairPlanFile, data, 0o644); err != nil {
+			return fmt.Errorf("failed writing repair plan: %w", err)
+		}
+		level.Info(logger).Log("msg", "repair plan written", "file", a.repairPlanFile, "chunks", len(plan.ChunkIDs))
+	}
+	if a.submitRepairPlan {
+		if err := audit.SubmitRepairPlan(ctx, auditCfg, plan); err != nil {
+			return fmt.Errorf("failed submitting repair plan: %w", err)
+		}
+		level.Info(logger).Log("msg", "repair plan submitted, the compactor removes the index entries the next time it applies retention to the table", "table", plan.Table, "chunks", len(plan.ChunkIDs))
+	}
+	return nil
+}
+
 func (a *AuditCommand) Register(app *kingpin.Application) {
 	auditCmd := app.Command("audit", "Audit Loki state.")
 
@@ -60,4 +120,14 @@ func (a *AuditCommand) Register(app *kingpin.Application) {
 	auditIndexCmd.Flag("config.file", "Auditing and storage configuration").Required().StringVar(&a.configFile)
 	auditIndexCmd.Flag("index.file", "Index to be audited").Required().StringVar(&a.path)
 	auditIndexCmd.Arg("args", "").StringsVar(&a.extraArgs)
+
+	scrubIndexCmd := auditCmd.
+		Command("scrub", "Scrub the given index by checking all its chunks are present and can be decoded, and optionally repair it.").
+		Action(a.scrubIndex)
+
+	scrubIndexCmd.Flag("config.file", "Auditing and storage configuration").Required().StringVar(&a.configFile)
+	scrubIndexCmd.Flag("index.file", "Index to be scrubbed").Required().StringVar(&a.path)
+	scrubIndexCmd.Flag("repair-plan.file", "File where the plan removing the index entries of the missing and corrupt chunks is written").StringVar(&a.repairPlanFile)
+	scrubIndexCmd.Flag("repair-plan.submit", "Submit the repair plan to the compactor, which removes the index entries of the missing and corrupt chunks when it applies retention").BoolVar(&a.submitRepairPlan)
+	scrubIndexCmd.Arg("args", "").StringsVar(&a.extraArgs)
 }
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"gopkg.in/alecthomas/kingpin.v2"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/tool/migrate"
+	util_cfg "github.com/grafana/loki/v3/pkg/util/cfg"
+)
+
+// MigrateCommand migrates the index of a boltdb-shipper period to TSDB.
+type MigrateCommand struct {
+	configFile   string
+	schemaOutput string
+
+	extraArgs []string
+}
+
+func (m *MigrateCommand) migrateBoltDBToTSDB(_ *kingpin.ParseContext) error {
+	logger := log.NewLogfmtLogger(os.Stdout)
+
+	var migrateCfg migrate.Config
+	if err := util_cfg.DefaultUnmarshal(&migrateCfg, m.extraArgs, flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "failed parsing config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := migrateCfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed validating config: %v\n", err)
+		os.Exit(1)
+	}
+
+	objClient, err := migrate.GetObjectClient(migrateCfg)
+	if err != nil {
+		return err
+	}
+	defer objClient.Stop()
+
+	stats, schemaCfg, err := migrate.Run(context.Background(), migrateCfg, objClient, logger)
+	if err != nil {
+		return err
+	}
+	var tenants, series, chunks int
+	for _, s := range stats {
+		tenants += s.Tenants
+		series += s.Series
+		chunks += s.Chunks
+	}
+	level.Info(logger).Log("msg", "finished migrating period", "period", migrateCfg.PeriodFrom, "tables", len(stats), "tenants", tenants, "series", series, "chunks", chunks)
+
+	data, err := yaml.Marshal(struct {
+		SchemaConfig config.SchemaConfig `yaml:"schema_config"`
+	}{SchemaConfig: schemaCfg})
+	if err != nil {
+		return err
+	}
+	if m.schemaOutput == "" {
+		fmt.Print(string(data))
+	} else if err := os.WriteFile(m.schemaOutput, data, 0o644); err != nil {
+		return fmt.Errorf("failed writing schema config: %w", err)
+	}
+	level.Info(logger).Log("msg", "the TSDB index of the period was verified, deploy the migrated schema config to switch the period to TSDB. The boltdb-shipper tables are left untouched to switch back until they are deleted", "period", migrateCfg.PeriodFrom)
+	return nil
+}
+
+func (m *MigrateCommand) Register(app *kingpin.Application) {
+	migrateCmd := app.Command("migrate", "Migrate Loki data.")
+
+	// Register migrate commands.
+	boltDBToTSDBCmd := migrateCmd.
+		Command("boltdb-to-tsdb", "Build the TSDB index of a boltdb-shipper period, verify it and print the schema config switching the period to TSDB.").
+		Action(m.migrateBoltDBToTSDB)
+
+	boltDBToTSDBCmd.Flag("config.file", "Migration, schema and storage configuration").Required().StringVar(&m.configFile)
+	boltDBToTSDBCmd.Flag("schema.output", "File where the migrated schema config is written, it is printed when empty").StringVar(&m.schemaOutput)
+	boltDBToTSDBCmd.Arg("args", "").StringsVar(&m.extraArgs)
+}
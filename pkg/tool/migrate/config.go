@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/grafana/dskit/flagext"
+	dskitlog "github.com/grafana/dskit/log"
+
+	"github.com/grafana/loki/v3/pkg/storage"
+	lokiStorage "github.com/grafana/loki/v3/pkg/storage/config"
+)
+
+type FileConfig struct {
+	ConfigFile string
+}
+
+func (c *FileConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&c.ConfigFile, "config.file", "config.yaml", "configuration file to load")
+}
+
+// Config Loki related storage and schema configs, and the period to migrate
+type Config struct {
+	FileConfig      `yaml:",inline"`
+	SchemaConfig    lokiStorage.SchemaConfig `yaml:"schema_config,omitempty"`
+	StorageConfig   storage.Config           `yaml:"storage_config,omitempty"`
+	LogLevel        dskitlog.Level           `yaml:"log_level"`
+	WorkingDir      string                   `yaml:"working_dir"`
+	PeriodFrom      string                   `yaml:"period_from"`
+	IndexPrefix     string                   `yaml:"index_prefix"`
+	FetchChunkStats bool                     `yaml:"fetch_chunk_stats"`
+}
+
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	c.FileConfig.RegisterFlags(f)
+	c.SchemaConfig.RegisterFlags(f)
+	c.StorageConfig.RegisterFlags(f)
+	c.LogLevel.RegisterFlags(f)
+	f.StringVar(&c.WorkingDir, "working-dir", ".", "working directory to store downloaded and built index files")
+	f.StringVar(&c.PeriodFrom, "period-from", "", "the start date of the boltdb-shipper period to migrate, in a format like 2022-01-01")
+	f.StringVar(&c.IndexPrefix, "index-prefix", "", "the prefix of the TSDB index tables of the migrated period, it must differ from the prefix of the boltdb-shipper tables")
+	f.BoolVar(&c.FetchChunkStats, "fetch-chunk-stats", true, "fetch the chunks to record their size and number of entries in the TSDB index, disabling it records estimates which skew the query sharding")
+}
+
+func (c *Config) Validate() error {
+	if err := c.SchemaConfig.Validate(); err != nil {
+		return fmt.Errorf("schema config is invalid: %v", err)
+	}
+	if err := c.StorageConfig.Validate(); err != nil {
+		return fmt.Errorf("storage config is invalid: %v", err)
+	}
+	if c.PeriodFrom == "" {
+		return fmt.Errorf("period_from argument missing. Use -period-from flag or add 'period_from' to the config file")
+	}
+	if c.IndexPrefix == "" {
+		return fmt.Errorf("index_prefix argument missing. Use -index-prefix flag or add 'index_prefix' to the config file")
+	}
+	return nil
+}
+
+// Clone takes advantage of pass-by-value semantics to return a distinct *Config.
+// This is primarily used to parse a different flag set without mutating the original *Config.
+func (c *Config) Clone() flagext.Registerer {
+	return func(c Config) *Config {
+		return &c
+	}(*c)
+}
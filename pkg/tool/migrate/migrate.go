@@ -0,0 +1,577 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"go.etcd.io/bbolt"
+
+	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/compression"
+	"github.com/grafana/loki/v3/pkg/storage"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/util"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	boltdbcompactor "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/boltdb/compactor"
+	shipperstorage "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/storage"
+	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb"
+	tsdbindex "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
+	shipperutil "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/util"
+	"github.com/grafana/loki/v3/pkg/storage/types"
+)
+
+const (
+	gzipExtension = ".gz"
+
+	// minPeriodAge is the minimum age of the end of the migrated period, so that the ingesters are done uploading its index.
+	minPeriodAge = 24 * time.Hour
+
+	// estimatedChunkKB and estimatedChunkEntries are recorded in the TSDB index when the chunks are not fetched since
+	// the boltdb-shipper index doesn't have them: half of the default target size of the chunks.
+	estimatedChunkKB      = ((3 << 20) / 4) / 1024
+	estimatedChunkEntries = 10000
+
+	fetchChunksBatchSize = 256
+)
+
+// TableStats are the tenants, the series and the chunks of the boltdb-shipper index of a table, which are verified
+// in its TSDB index.
+type TableStats struct {
+	Table    string
+	NewTable string
+	Tenants  int
+	Series   int
+	Chunks   int
+	// Skipped is set when all the tenants of the table had already been migrated, they are only verified.
+	Skipped bool
+}
+
+// Run builds the TSDB index of the tables of the boltdb-shipper period starting at cfg.PeriodFrom, and returns the
+// schema config switching the period to TSDB once the series and the chunks of all the tables have been verified.
+// The boltdb-shipper tables are left untouched so the period can be switched back until they are deleted.
+func Run(ctx context.Context, cfg Config, objClient client.ObjectClient, logger log.Logger) ([]TableStats, config.SchemaConfig, error) {
+	idx, err := findPeriod(cfg.SchemaConfig, cfg.PeriodFrom, model.Now())
+	if err != nil {
+		return nil, config.SchemaConfig{}, err
+	}
+	periodCfg := cfg.SchemaConfig.Configs[idx]
+	if cfg.IndexPrefix == periodCfg.IndexTables.Prefix {
+		return nil, config.SchemaConfig{}, fmt.Errorf("the prefix of the TSDB tables must differ from the prefix %s of the boltdb-shipper tables", periodCfg.IndexTables.Prefix)
+	}
+
+	m, err := newMigrator(cfg, periodCfg, objClient, logger)
+	if err != nil {
+		return nil, config.SchemaConfig{}, err
+	}
+
+	periodEnd := config.DayTime{Time: cfg.SchemaConfig.Configs[idx+1].From.Time.Add(-time.Millisecond)}
+	tableNames, err := m.tables(ctx, periodCfg.GetIndexTableNumberRange(periodEnd))
+	if err != nil {
+		return nil, config.SchemaConfig{}, err
+	}
+
+	stats := make([]TableStats, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		tableStats, err := m.migrateTable(ctx, tableName)
+		if err != nil {
+			return stats, config.SchemaConfig{}, fmt.Errorf("failed to migrate table %s: %w", tableName, err)
+		}
+		level.Info(logger).Log("msg", "migrated table", "table", tableName, "new_table", tableStats.NewTable, "tenants", tableStats.Tenants,
+			"series", tableStats.Series, "chunks", tableStats.Chunks, "skipped", tableStats.Skipped)
+		stats = append(stats, tableStats)
+	}
+
+	migrated := config.SchemaConfig{Configs: slices.Clone(cfg.SchemaConfig.Configs)}
+	migrated.Configs[idx] = m.tsdbPeriodCfg
+	if err := migrated.Validate(); err != nil {
+		return stats, config.SchemaConfig{}, fmt.Errorf("migrated schema config is invalid: %w", err)
+	}
+	return stats, migrated, nil
+}
+
+// GetObjectClient returns the object client of the store of the migrated period.
+func GetObjectClient(cfg Config) (client.ObjectClient, error) {
+	for _, periodCfg := range cfg.SchemaConfig.Configs {
+		if periodCfg.From.String() != cfg.PeriodFrom {
+			continue
+		}
+		objClient, err := storage.NewObjectClient(periodCfg.ObjectType, "tool-migrate", cfg.StorageConfig, storage.NewClientMetrics())
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create object client: %w", err)
+		}
+		return objClient, nil
+	}
+	return nil, fmt.Errorf("no period starts at %s", cfg.PeriodFrom)
+}
+
+// findPeriod returns the index of the boltdb-shipper period starting at from, which must have ended for long enough to
+// not be written anymore.
+func findPeriod(schemaCfg config.SchemaConfig, from string, now model.Time) (int, error) {
+	for i, periodCfg := range schemaCfg.Configs {
+		if periodCfg.From.String() != from {
+			continue
+		}
+		if periodCfg.IndexType != types.BoltDBShipperType {
+			return 0, fmt.Errorf("the period starting at %s uses the %s index, only the %s periods can be migrated", from, periodCfg.IndexType, types.BoltDBShipperType)
+		}
+		if i == len(schemaCfg.Configs)-1 {
+			return 0, fmt.Errorf("the period starting at %s is the last period, only the periods which ended can be migrated", from)
+		}
+		if schemaCfg.Configs[i+1].From.Time.After(now.Add(-minPeriodAge)) {
+			return 0, fmt.Errorf("the period starting at %s ended less than %s ago, the ingesters may still upload its index", from, minPeriodAge)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("no period starts at %s", from)
+}
+
+type migrator struct {
+	cfg                Config
+	periodCfg          config.PeriodConfig
+	tsdbPeriodCfg      config.PeriodConfig
+	schemaCfg          config.SchemaConfig
+	indexFormat        int
+	indexStorageClient shipperstorage.Client
+	// chunkClient is nil when the stats of the chunks are estimated.
+	chunkClient client.Client
+	logger      log.Logger
+}
+
+func newMigrator(cfg Config, periodCfg config.PeriodConfig, objClient client.ObjectClient, logger log.Logger) (*migrator, error) {
+	tsdbPeriodCfg := periodCfg
+	tsdbPeriodCfg.IndexType = types.TSDBType
+	tsdbPeriodCfg.IndexTables.Prefix = cfg.IndexPrefix
+
+	indexFormat, err := tsdbPeriodCfg.TSDBFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &migrator{
+		cfg:                cfg,
+		periodCfg:          periodCfg,
+		tsdbPeriodCfg:      tsdbPeriodCfg,
+		schemaCfg:          config.SchemaConfig{Configs: []config.PeriodConfig{periodCfg}},
+		indexFormat:        indexFormat,
+		indexStorageClient: shipperstorage.NewIndexStorageClient(objClient, periodCfg.IndexTables.PathPrefix),
+		logger:             logger,
+	}
+	if cfg.FetchChunkStats {
+		m.chunkClient = newChunkClient(objClient, m.schemaCfg)
+	} else {
+		level.Warn(logger).Log("msg", "the chunks are not fetched, the TSDB index records an estimate of their size and number of entries instead of the real ones, which skews the query sharding of the migrated period",
+			"estimated_chunk_kb", estimatedChunkKB, "estimated_chunk_entries", estimatedChunkEntries)
+	}
+	return m, nil
+}
+
+// newChunkClient returns the client of the chunks, whose keys are encoded by the filesystem store.
+func newChunkClient(objClient client.ObjectClient, schemaCfg config.SchemaConfig) client.Client {
+	var encoder client.KeyEncoder
+	raw := objClient
+	if prefixed, ok := objClient.(client.PrefixedObjectClient); ok {
+		raw = prefixed.GetDownstream()
+	}
+	if _, ok := raw.(*local.FSObjectClient); ok {
+		encoder = client.FSEncoder
+	}
+	return client.NewClient(objClient, encoder, schemaCfg)
+}
+
+// tables returns the sorted names of the boltdb-shipper tables of the period.
+func (m *migrator) tables(ctx context.Context, tableRange config.TableRange) ([]string, error) {
+	tableNames, err := m.indexStorageClient.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var periodTables []string
+	for _, tableName := range tableNames {
+		if _, err := config.ExtractTableNumberFromName(tableName); err != nil {
+			continue
+		}
+		inRange, err := tableRange.TableInRange(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if inRange {
+			periodTables = append(periodTables, tableName)
+		}
+	}
+	sort.Strings(periodTables)
+	return periodTables, nil
+}
+
+// tenantIndex holds the series and the chunks of a tenant read from the boltdb-shipper index of a table.
+type tenantIndex struct {
+	series map[string]struct{}
+	// chunks holds the chunks by chunk ID, which dedupes the chunks found in multiple index files.
+	chunks map[string]*indexedChunk
+}
+
+type indexedChunk struct {
+	labels  labels.Labels
+	chunk   chunk.Chunk
+	kb      uint32
+	entries uint32
+}
+
+func (m *migrator) migrateTable(ctx context.Context, tableName string) (TableStats, error) {
+	tableNumber, err := config.ExtractTableNumberFromName(tableName)
+	if err != nil {
+		return TableStats{}, err
+	}
+	stats := TableStats{Table: tableName, NewTable: fmt.Sprintf("%s%d", m.cfg.IndexPrefix, tableNumber)}
+
+	workingDir := filepath.Join(m.cfg.WorkingDir, tableName)
+	if err := util.EnsureDirectory(workingDir); err != nil {
+		return stats, err
+	}
+	defer func() {
+		if err := os.RemoveAll(workingDir); err != nil {
+			level.Error(m.logger).Log("msg", "failed to remove working directory", "path", workingDir, "err", err)
+		}
+	}()
+
+	table, err := m.downloadBoltDBIndex(ctx, tableName, workingDir)
+	if err != nil {
+		return stats, err
+	}
+
+	// the tenants which were already migrated are only verified, so that a failed migration can be resumed.
+	_, migratedUsers, err := m.indexStorageClient.ListFiles(ctx, stats.NewTable, true)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Skipped = true
+	for _, userID := range table.users {
+		t, err := m.readTenantIndex(ctx, table, userID)
+		if err != nil {
+			return stats, err
+		}
+		if len(t.chunks) == 0 {
+			continue
+		}
+		stats.Tenants++
+		stats.Series += len(t.series)
+		stats.Chunks += len(t.chunks)
+
+		if slices.Contains(migratedUsers, userID) {
+			if err := m.verifyTenant(ctx, stats.NewTable, userID, t, workingDir); err != nil {
+				return stats, fmt.Errorf("%w, delete the index of the tenant in the table %s to migrate it again", err, stats.NewTable)
+			}
+			continue
+		}
+		stats.Skipped = false
+
+		fileName, err := m.migrateTenant(ctx, stats.NewTable, userID, t, workingDir)
+		if err != nil {
+			return stats, err
+		}
+		if err := m.verifyTenant(ctx, stats.NewTable, userID, t, workingDir); err != nil {
+			if err := m.indexStorageClient.DeleteUserFile(ctx, stats.NewTable, userID, fileName); err != nil {
+				level.Error(m.logger).Log("msg", "failed to delete the unverified TSDB index", "table", stats.NewTable, "tenant", userID, "file", fileName, "err", err)
+			}
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// boltDBTable holds the paths of the downloaded boltdb-shipper index files of a table, which are read again for each
+// tenant so that only the index of a single tenant is held in memory.
+type boltDBTable struct {
+	// commonFiles hold the index of multiple tenants, uncompacted or compacted.
+	commonFiles []string
+	userFiles   map[string][]string
+	// users are the sorted tenants having an index in the table.
+	users []string
+}
+
+// downloadBoltDBIndex downloads all the boltdb-shipper index files of the table, uncompacted or not, and lists their
+// tenants.
+func (m *migrator) downloadBoltDBIndex(ctx context.Context, tableName, workingDir string) (*boltDBTable, error) {
+	table := &boltDBTable{userFiles: map[string][]string{}}
+	users := map[string]struct{}{}
+
+	files, userIDs, err := m.indexStorageClient.ListFiles(ctx, tableName, true)
+	if err != nil {
+		return nil, err
+	}
+	downloaded := 0
+	download := func(fileName string, getFile shipperstorage.GetFileFunc) (string, error) {
+		dst := filepath.Join(workingDir, fmt.Sprintf("boltdb-%d", downloaded))
+		if err := shipperstorage.DownloadFileFromStorage(dst, shipperstorage.IsCompressedFile(fileName), false, shipperstorage.LoggerWithFilename(m.logger, fileName), getFile); err != nil {
+			return "", fmt.Errorf("couldn't download file %q from storage: %w", fileName, err)
+		}
+		downloaded++
+		return dst, nil
+	}
+
+	for _, file := range files {
+		path, err := download(file.Name, func() (io.ReadCloser, error) {
+			return m.indexStorageClient.GetFile(ctx, tableName, file.Name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		table.commonFiles = append(table.commonFiles, path)
+
+		if err := m.readBoltDBFile(ctx, path, func(entry retention.ChunkEntry) error {
+			users[string(entry.UserID)] = struct{}{}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, userID := range userIDs {
+		userFiles, err := m.indexStorageClient.ListUserFiles(ctx, tableName, userID, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range userFiles {
+			path, err := download(file.Name, func() (io.ReadCloser, error) {
+				return m.indexStorageClient.GetUserFile(ctx, tableName, userID, file.Name)
+			})
+			if err != nil {
+				return nil, err
+			}
+			table.userFiles[userID] = append(table.userFiles[userID], path)
+		}
+		users[userID] = struct{}{}
+	}
+
+	for userID := range users {
+		table.users = append(table.users, userID)
+	}
+	sort.Strings(table.users)
+	return table, nil
+}
+
+// readTenantIndex reads the chunks of the tenant from the downloaded boltdb-shipper index files of the table.
+func (m *migrator) readTenantIndex(ctx context.Context, table *boltDBTable, userID string) (*tenantIndex, error) {
+	t := &tenantIndex{series: map[string]struct{}{}, chunks: map[string]*indexedChunk{}}
+	for _, path := range slices.Concat(table.commonFiles, table.userFiles[userID]) {
+		if err := m.readBoltDBFile(ctx, path, func(entry retention.ChunkEntry) error {
+			if string(entry.UserID) != userID {
+				return nil
+			}
+			return addChunk(t, entry)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (m *migrator) readBoltDBFile(ctx context.Context, path string, callback func(entry retention.ChunkEntry) error) error {
+	db, err := shipperutil.SafeOpenBoltdbFile(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(local.IndexBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return boltdbcompactor.ForEachChunk(ctx, bucket, m.periodCfg, func(entry retention.ChunkEntry) (bool, error) {
+			return false, callback(entry)
+		})
+	})
+}
+
+func addChunk(t *tenantIndex, entry retention.ChunkEntry) error {
+	userID, chunkID := string(entry.UserID), string(entry.ChunkID)
+	c, err := chunk.ParseExternalKey(userID, chunkID)
+	if err != nil {
+		return fmt.Errorf("invalid chunk ID %s: %w", chunkID, err)
+	}
+	if entry.Labels.IsEmpty() {
+		return fmt.Errorf("labels of the series of chunk %s not found", chunkID)
+	}
+	// the boltdb-shipper index adds the metric name to the labels of the streams, which the TSDB index doesn't have.
+	lbs := labels.NewBuilder(entry.Labels.Copy()).Del(labels.MetricName).Labels()
+
+	t.series[lbs.String()] = struct{}{}
+	t.chunks[chunkID] = &indexedChunk{labels: lbs, chunk: c, kb: estimatedChunkKB, entries: estimatedChunkEntries}
+	return nil
+}
+
+// migrateTenant builds and uploads the TSDB index of the tenant, and returns the name of the uploaded file.
+func (m *migrator) migrateTenant(ctx context.Context, tableName, userID string, t *tenantIndex, workingDir string) (string, error) {
+	if m.chunkClient != nil {
+		if err := m.fetchChunkStats(ctx, t); err != nil {
+			return "", err
+		}
+	}
+
+	builder := tsdb.NewBuilder(m.indexFormat)
+	for _, c := range t.chunks {
+		builder.AddSeries(c.labels, model.Fingerprint(c.chunk.Fingerprint), []tsdbindex.ChunkMeta{{
+			Checksum: c.chunk.Checksum,
+			MinTime:  int64(c.chunk.From),
+			MaxTime:  int64(c.chunk.Through),
+			KB:       c.kb,
+			Entries:  c.entries,
+		}})
+	}
+
+	dir := filepath.Join(workingDir, userID)
+	id, err := builder.Build(ctx, dir, func(from, through model.Time, checksum uint32) tsdb.Identifier {
+		id := tsdb.SingleTenantTSDBIdentifier{
+			TS:       time.Now(),
+			From:     from,
+			Through:  through,
+			Checksum: checksum,
+		}
+		return tsdb.NewPrefixedIdentifier(id, dir, "")
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to build TSDB index of tenant %s: %w", userID, err)
+	}
+	defer func() {
+		if err := os.Remove(id.Path()); err != nil {
+			level.Error(m.logger).Log("msg", "failed to remove built index file", "path", id.Path(), "err", err)
+		}
+	}()
+
+	fileName := id.Name() + gzipExtension
+	if err := m.uploadFile(ctx, tableName, userID, fileName, id.Path()); err != nil {
+		return "", fmt.Errorf("failed to upload TSDB index of tenant %s: %w", userID, err)
+	}
+	return fileName, nil
+}
+
+// fetchChunkStats fetches the chunks of the tenant to record their size and number of entries.
+func (m *migrator) fetchChunkStats(ctx context.Context, t *tenantIndex) error {
+	chunks := make([]chunk.Chunk, 0, len(t.chunks))
+	for _, c := range t.chunks {
+		chunks = append(chunks, c.chunk)
+	}
+
+	for start := 0; start < len(chunks); start += fetchChunksBatchSize {
+		end := min(start+fetchChunksBatchSize, len(chunks))
+		fetched, err := m.chunkClient.GetChunks(ctx, chunks[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to fetch chunks, find the missing or corrupt chunks with lokitool audit scrub: %w", err)
+		}
+		for _, chk := range fetched {
+			c, ok := t.chunks[m.schemaCfg.ExternalKey(chk.ChunkRef)]
+			if !ok {
+				continue
+			}
+			c.kb = uint32(math.Round(float64(chk.Data.UncompressedSize()) / float64(1<<10)))
+			c.entries = uint32(chk.Data.Entries())
+		}
+	}
+	return nil
+}
+
+func (m *migrator) uploadFile(ctx context.Context, tableName, userID, fileName, path string) error {
+	compressedPath := path + gzipExtension
+	if err := compressFile(path, compressedPath); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(compressedPath); err != nil {
+			level.Error(m.logger).Log("msg", "failed to remove compressed index file", "path", compressedPath, "err", err)
+		}
+	}()
+
+	f, err := os.Open(compressedPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return m.indexStorageClient.PutUserFile(ctx, tableName, userID, fileName, f)
+}
+
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gzipPool := compression.GetWriterPool(compression.GZIP)
+	compressedWriter := gzipPool.GetWriter(out)
+	defer gzipPool.PutWriter(compressedWriter)
+
+	if _, err := io.Copy(compressedWriter, in); err != nil {
+		return err
+	}
+	if err := compressedWriter.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// verifyTenant downloads the TSDB index of the tenant and checks that it has the same series and chunks as its
+// boltdb-shipper index.
+func (m *migrator) verifyTenant(ctx context.Context, tableName, userID string, t *tenantIndex, workingDir string) error {
+	files, err := m.indexStorageClient.ListUserFiles(ctx, tableName, userID, true)
+	if err != nil {
+		return err
+	}
+
+	series := map[string]struct{}{}
+	chunks := 0
+	for _, file := range files {
+		dst := filepath.Join(workingDir, fmt.Sprintf("verify-%s-%s", userID, strings.TrimSuffix(file.Name, gzipExtension)))
+		if err := shipperstorage.DownloadFileFromStorage(dst, shipperstorage.IsCompressedFile(file.Name), false, shipperstorage.LoggerWithFilename(m.logger, file.Name), func() (io.ReadCloser, error) {
+			return m.indexStorageClient.GetUserFile(ctx, tableName, userID, file.Name)
+		}); err != nil {
+			return fmt.Errorf("couldn't download file %q from storage: %w", file.Name, err)
+		}
+
+		idx, _, err := tsdb.NewTSDBIndexFromFile(dst)
+		if err != nil {
+			return err
+		}
+		err = idx.ForSeries(ctx, "", nil, 0, math.MaxInt64, func(lbls labels.Labels, _ model.Fingerprint, chks []tsdbindex.ChunkMeta) (stop bool) {
+			series[lbls.String()] = struct{}{}
+			chunks += len(chks)
+			return false
+		}, labels.MustNewMatcher(labels.MatchEqual, "", ""))
+		if closeErr := idx.Close(); closeErr != nil {
+			level.Error(m.logger).Log("msg", "failed to close index file", "path", dst, "err", closeErr)
+		}
+		if removeErr := os.Remove(dst); removeErr != nil {
+			level.Error(m.logger).Log("msg", "failed to remove downloaded index file", "path", dst, "err", removeErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(series) != len(t.series) || chunks != len(t.chunks) {
+		return fmt.Errorf("the TSDB index of tenant %s has %d series and %d chunks but its boltdb-shipper index has %d series and %d chunks",
+			userID, len(series), chunks, len(t.series), len(t.chunks))
+	}
+	return nil
+}
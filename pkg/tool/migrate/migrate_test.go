@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/compression"
+	ingesterclient "github.com/grafana/loki/v3/pkg/ingester/client"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	seriesindex "github.com/grafana/loki/v3/pkg/storage/stores/series/index"
+	"github.com/grafana/loki/v3/pkg/storage/types"
+)
+
+func dayFromTime(t time.Time) config.DayTime {
+	return config.DayTime{Time: model.TimeFromUnix(t.UTC().Truncate(24 * time.Hour).Unix())}
+}
+
+func testSchemaConfig() config.SchemaConfig {
+	periodCfg := func(from time.Time, indexType string) config.PeriodConfig {
+		return config.PeriodConfig{
+			From:       dayFromTime(from),
+			IndexType:  indexType,
+			ObjectType: types.StorageTypeFileSystem,
+			Schema:     "v12",
+			IndexTables: config.IndexPeriodicTableConfig{
+				PathPrefix: "index/",
+				PeriodicTableConfig: config.PeriodicTableConfig{
+					Prefix: "index_",
+					Period: 24 * time.Hour,
+				}},
+			RowShards: 16,
+		}
+	}
+	now := time.Now()
+	return config.SchemaConfig{Configs: []config.PeriodConfig{
+		periodCfg(now.Add(-10*24*time.Hour), types.BoltDBShipperType),
+		periodCfg(now.Add(-3*24*time.Hour), types.TSDBType),
+	}}
+}
+
+func createChunk(t *testing.T, userID string, lbs labels.Labels, from, through model.Time) chunk.Chunk {
+	t.Helper()
+	const (
+		targetSize = 1500 * 1024
+		blockSize  = 256 * 1024
+	)
+	metric := labels.NewBuilder(lbs).Set(labels.MetricName, "logs").Labels()
+	chunkEnc := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, compression.Snappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, blockSize, targetSize)
+	for ts := from; !ts.After(through); ts = ts.Add(time.Minute) {
+		dup, err := chunkEnc.Append(&logproto.Entry{Timestamp: ts.Time(), Line: ts.String()})
+		require.False(t, dup)
+		require.NoError(t, err)
+	}
+	require.NoError(t, chunkEnc.Close())
+
+	c := chunk.NewChunk(userID, ingesterclient.Fingerprint(lbs), metric, chunkenc.NewFacade(chunkEnc, blockSize, targetSize), from, through)
+	require.NoError(t, c.Encode())
+	return c
+}
+
+// writeBoltDBIndex writes the boltdb-shipper index of the chunks in the table, as written by the ingesters.
+func writeBoltDBIndex(t *testing.T, objClient client.ObjectClient, schemaCfg config.SchemaConfig, periodCfg config.PeriodConfig, tableName string, chunks []chunk.Chunk) {
+	t.Helper()
+	schema, err := seriesindex.CreateSchema(periodCfg)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ingester-1")
+	db, err := bbolt.Open(path, 0o666, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(local.IndexBucketName)
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks {
+			chunkID := schemaCfg.ExternalKey(c.ChunkRef)
+			_, labelEntries, err := schema.GetCacheKeysAndLabelWriteEntries(c.From, c.Through, c.UserID, "logs", c.Metric, chunkID)
+			if err != nil {
+				return err
+			}
+			chunkEntries, err := schema.GetChunkWriteEntries(c.From, c.Through, c.UserID, "logs", c.Metric, chunkID)
+			if err != nil {
+				return err
+			}
+			entries := chunkEntries
+			for _, e := range labelEntries {
+				entries = append(entries, e...)
+			}
+			for _, e := range entries {
+				if e.TableName != tableName {
+					continue
+				}
+				if err := bucket.Put([]byte(e.HashValue+"\000"+string(e.RangeValue)), e.Value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, objClient.PutObject(context.Background(), periodCfg.IndexTables.PathPrefix+tableName+"/ingester-1", bytes.NewReader(data)))
+}
+
+func TestRun(t *testing.T) {
+	schemaCfg := testSchemaConfig()
+	periodCfg := schemaCfg.Configs[0]
+	storageCfg := storage.Config{FSConfig: local.FSConfig{Directory: t.TempDir()}}
+	objClient, err := storage.NewObjectClient(types.StorageTypeFileSystem, "test", storageCfg, storage.NewClientMetrics())
+	require.NoError(t, err)
+
+	day := periodCfg.From.Time.Add(24 * time.Hour)
+	tableNumber := day.Unix() / 86400
+	tableName := fmt.Sprintf("index_%d", tableNumber)
+	streamA := labels.FromStrings("app", "a")
+	streamB := labels.FromStrings("app", "b")
+	chunks := []chunk.Chunk{
+		createChunk(t, "1", streamA, day, day.Add(time.Hour)),
+		createChunk(t, "1", streamA, day.Add(time.Hour), day.Add(2*time.Hour)),
+		createChunk(t, "1", streamB, day, day.Add(time.Hour)),
+		createChunk(t, "2", streamA, day, day.Add(time.Hour)),
+	}
+	require.NoError(t, client.NewClient(objClient, client.FSEncoder, schemaCfg).PutChunks(context.Background(), chunks))
+	writeBoltDBIndex(t, objClient, schemaCfg, periodCfg, tableName, chunks)
+
+	cfg := Config{
+		SchemaConfig:    schemaCfg,
+		StorageConfig:   storageCfg,
+		WorkingDir:      t.TempDir(),
+		PeriodFrom:      periodCfg.From.String(),
+		IndexPrefix:     "tsdb_index_",
+		FetchChunkStats: true,
+	}
+
+	// only the boltdb-shipper periods which ended can be migrated.
+	invalidCfg := cfg
+	invalidCfg.PeriodFrom = schemaCfg.Configs[1].From.String()
+	_, _, err = Run(context.Background(), invalidCfg, objClient, log.NewNopLogger())
+	require.Error(t, err)
+
+	// the TSDB tables must not overwrite the boltdb-shipper tables.
+	invalidCfg = cfg
+	invalidCfg.IndexPrefix = periodCfg.IndexTables.Prefix
+	_, _, err = Run(context.Background(), invalidCfg, objClient, log.NewNopLogger())
+	require.Error(t, err)
+
+	stats, migratedSchema, err := Run(context.Background(), cfg, objClient, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, []TableStats{{
+		Table:    tableName,
+		NewTable: fmt.Sprintf("tsdb_index_%d", tableNumber),
+		Tenants:  2,
+		Series:   3,
+		Chunks:   4,
+	}}, stats)
+
+	for _, userID := range []string{"1", "2"} {
+		files, _, err := objClient.List(context.Background(), fmt.Sprintf("index/tsdb_index_%d/%s/", tableNumber, userID), "")
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+	}
+
+	require.Len(t, migratedSchema.Configs, 2)
+	require.Equal(t, types.TSDBType, migratedSchema.Configs[0].IndexType)
+	require.Equal(t, "tsdb_index_", migratedSchema.Configs[0].IndexTables.Prefix)
+	require.Equal(t, periodCfg.From, migratedSchema.Configs[0].From)
+	require.Equal(t, schemaCfg.Configs[1], migratedSchema.Configs[1])
+
+	// the migrated tenants are only verified when the migration is run again.
+	stats, _, err = Run(context.Background(), cfg, objClient, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.True(t, stats[0].Skipped)
+}
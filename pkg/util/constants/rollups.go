@@ -0,0 +1,11 @@
+package constants
+
+const (
+	// RollupLabel is the stream label of the rollups computed by the compactor, holding the name of their rollup rule.
+	RollupLabel = "__rollup__"
+
+	// The structured metadata of the samples of the rollups.
+	RollupCount = "rollup_count"
+	RollupBytes = "rollup_bytes"
+	RollupSum   = "rollup_sum"
+)
@@ -14,6 +14,10 @@ var (
 	// LokiActorPathHeader is the name of the header e.g. used to enqueue requests in hierarchical queues.
 	LokiActorPathHeader               = "X-Loki-Actor-Path"
 	LokiDisablePipelineWrappersHeader = "X-Loki-Disable-Pipeline-Wrappers"
+	// LokiQueryPriorityHeader is the name of the header that holds the priority class of a query.
+	LokiQueryPriorityHeader = "X-Loki-Query-Priority"
+	// LokiFederatedHeader marks queries sent by a federating query frontend, which are not federated again.
+	LokiFederatedHeader = "X-Loki-Federated"
 
 	// LokiActorPathDelimiter is the delimiter used to serialise the hierarchy of the actor.
 	LokiActorPathDelimiter = "|"
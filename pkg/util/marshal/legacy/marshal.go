@@ -27,7 +27,7 @@ func WriteQueryResponseJSON(v logqlmodel.Result, w io.Writer) error {
 
 	j := map[string]interface{}{
 		"streams": v.Data,
-		"stats":   v.Statistics,
+		"stats":   v.Statistics.WithUsage(),
 	}
 
 	return json.NewEncoder(w).Encode(j)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/websocket"
 	jsoniter "github.com/json-iterator/go"
@@ -117,6 +118,25 @@ func WriteTailResponseJSON(r legacy.TailResponse, w io.Writer, encodeFlags httpr
 	return s.Flush()
 }
 
+// WriteTailEventJSON marshals a legacy.TailResponse to v1 loghttp JSON and then
+// writes it to the provided io.Writer as a server-sent event of a tail v2 request.
+func WriteTailEventJSON(r legacy.TailResponse, id string, w io.Writer, encodeFlags httpreq.EncodingFlags) error {
+	if _, err := fmt.Fprintf(w, "event: %s\nid: %s\ndata: ", loghttp.TailEventEntries, id); err != nil {
+		return err
+	}
+	if err := WriteTailResponseJSON(r, w, encodeFlags); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n\n")
+	return err
+}
+
+// WriteTailErrorEvent writes the error ending a tail v2 request to the provided io.Writer as a server-sent event.
+func WriteTailErrorEvent(err error, w io.Writer) error {
+	_, werr := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", loghttp.TailEventError, strings.ReplaceAll(err.Error(), "\n", " "))
+	return werr
+}
+
 // WriteSeriesResponseJSON marshals a logproto.SeriesResponse to v1 loghttp JSON and then
 // writes it to the provided io.Writer.
 func WriteSeriesResponseJSON(series []logproto.SeriesIdentifier, w io.Writer) error {
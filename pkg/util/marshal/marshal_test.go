@@ -174,6 +174,32 @@ const emptyStats = `{
 		"totalLinesProcessed": 0,
 		"totalStructuredMetadataBytesProcessed": 0,
 		"totalPostFilterLines": 0
+	},
+	"usage": {
+		"queueTime": 0,
+		"shardsTime": 0,
+		"indexTime": 0,
+		"chunksDownloadTime": 0,
+		"cacheTime": 0,
+		"execTime": 0,
+		"cpuTime": 0,
+		"peakMemoryBytes": 0,
+		"bytesProcessed": 0,
+		"linesProcessed": 0,
+		"chunksRef": 0,
+		"chunksDownloaded": 0,
+		"shards": 0,
+		"splits": 0,
+		"cacheHitRatio": {
+			"chunk": 0,
+			"index": 0,
+			"result": 0,
+			"statsResult": 0,
+			"volumeResult": 0,
+			"seriesResult": 0,
+			"labelResult": 0,
+			"instantMetricResult": 0
+		}
 	}
 }`
 
@@ -315,7 +315,7 @@ func encodeData(data parser.Value, statistics stats.Result, s *jsoniter.Stream,
 
 	s.WriteMore()
 	s.WriteObjectField("stats")
-	s.WriteVal(statistics)
+	s.WriteVal(statistics.WithUsage())
 
 	s.WriteObjectEnd()
 	s.Flush()
@@ -1,6 +1,9 @@
 package unmarshal
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
 	"io"
 	"unsafe"
 
@@ -38,3 +41,53 @@ func ReadTailResponseJSON(r *loghttp.TailResponse, reader WebsocketReader) error
 	}
 	return jsoniter.Unmarshal(data, r)
 }
+
+// ReadTailEvent reads the next server-sent event of a tail v2 request.
+func ReadTailEvent(r *bufio.Reader, ev *loghttp.TailEvent) error {
+	*ev = loghttp.TailEvent{}
+
+	var data []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			// an empty line dispatches the event, events only made of comments are skipped.
+			if ev.Type == "" && data == nil {
+				continue
+			}
+			break
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			ev.Type = string(value)
+		case "id":
+			ev.ID = string(value)
+		case "data":
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+		}
+	}
+
+	switch ev.Type {
+	case loghttp.TailEventError:
+		ev.Error = string(data)
+		return nil
+	case "", loghttp.TailEventEntries:
+		ev.Type = loghttp.TailEventEntries
+		return jsoniter.Unmarshal(data, &ev.Response)
+	default:
+		return fmt.Errorf("unknown tail event %q", ev.Type)
+	}
+}
@@ -1,6 +1,9 @@
 package unmarshal
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -262,3 +265,42 @@ func Test_ReadTailResponse(t *testing.T) {
 		},
 	}, res)
 }
+
+func Test_ReadTailEvent(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, marshal.WriteTailEventJSON(legacy_loghttp.TailResponse{
+		Streams: []logproto.Stream{
+			{Labels: `{app="bar"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(0, 2), Line: "2"}}},
+		},
+		DroppedEntries: []legacy_loghttp.DroppedEntry{
+			{Timestamp: time.Unix(0, 1), Labels: `{app="foo"}`},
+		},
+	}, "2-1", &buf, nil))
+	buf.WriteString(": keep-alive\n\n")
+	require.NoError(t, marshal.WriteTailErrorEvent(errors.New("tail failed"), &buf))
+
+	r := bufio.NewReader(&buf)
+	var ev loghttp.TailEvent
+	require.NoError(t, ReadTailEvent(r, &ev))
+	require.Equal(t, loghttp.TailEvent{
+		ID:   "2-1",
+		Type: loghttp.TailEventEntries,
+		Response: loghttp.TailResponse{
+			Streams: []loghttp.Stream{
+				{
+					Labels:  loghttp.LabelSet{"app": "bar"},
+					Entries: []loghttp.Entry{{Timestamp: time.Unix(0, 2), Line: "2"}},
+				},
+			},
+			DroppedStreams: []loghttp.DroppedStream{
+				{Timestamp: time.Unix(0, 1), Labels: loghttp.LabelSet{"app": "foo"}},
+			},
+		},
+	}, ev)
+
+	// the keep-alive comment is skipped.
+	require.NoError(t, ReadTailEvent(r, &ev))
+	require.Equal(t, loghttp.TailEvent{Type: loghttp.TailEventError, Error: "tail failed"}, ev)
+
+	require.ErrorIs(t, ReadTailEvent(r, &ev), io.EOF)
+}
@@ -0,0 +1,25 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// RollupRule configures the aggregation by the compactor of the log lines of aged chunks into metric series
+// which are used to answer the matching metric queries.
+type RollupRule struct {
+	Name       string         `yaml:"name" json:"name"`
+	Selector   string         `yaml:"selector" json:"selector"`
+	By         []string       `yaml:"by" json:"by"`
+	Unwrap     string         `yaml:"unwrap" json:"unwrap"`
+	Resolution model.Duration `yaml:"resolution" json:"resolution"`
+	After      model.Duration `yaml:"after" json:"after"`
+}
+
+// QueryableAfter returns the age of the data after which the rollups of the rule are used to answer queries.
+// The rollups of an index table are computed once the whole table is older than After, so the queries have to
+// wait for an additional table period.
+func (r *RollupRule) QueryableAfter() model.Duration {
+	return r.After + model.Duration(24*time.Hour)
+}
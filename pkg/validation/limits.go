@@ -28,6 +28,7 @@ import (
 	ruler_config "github.com/grafana/loki/v3/pkg/ruler/config"
 	"github.com/grafana/loki/v3/pkg/ruler/util"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/sharding"
+	"github.com/grafana/loki/v3/pkg/util/constants"
 	"github.com/grafana/loki/v3/pkg/util/flagext"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	"github.com/grafana/loki/v3/pkg/util/validation"
@@ -49,6 +50,11 @@ const (
 	// is used to keep track of the current number of healthy distributor replicas.
 	GlobalIngestionRateStrategy = "global"
 
+	// TenantMemoryLimitPolicyFlush forces the flush of the largest streams of a tenant exceeding its memory limit.
+	TenantMemoryLimitPolicyFlush = "flush"
+	// TenantMemoryLimitPolicyReject rejects the writes of a tenant exceeding its memory limit.
+	TenantMemoryLimitPolicyReject = "reject"
+
 	bytesInMB = 1048576
 
 	defaultPerStreamRateLimit   = 3 << 20 // 3MB
@@ -95,6 +101,8 @@ type Limits struct {
 	UnorderedWrites         bool             `yaml:"unordered_writes" json:"unordered_writes"`
 	PerStreamRateLimit      flagext.ByteSize `yaml:"per_stream_rate_limit" json:"per_stream_rate_limit"`
 	PerStreamRateLimitBurst flagext.ByteSize `yaml:"per_stream_rate_limit_burst" json:"per_stream_rate_limit_burst"`
+	MaxTenantMemoryBytes    flagext.ByteSize `yaml:"max_tenant_memory_bytes" json:"max_tenant_memory_bytes"`
+	TenantMemoryLimitPolicy string           `yaml:"tenant_memory_limit_policy" json:"tenant_memory_limit_policy"`
 
 	// Querier enforced limits.
 	MaxChunksPerQuery          int              `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
@@ -110,12 +118,16 @@ type Limits struct {
 	CardinalityLimit           int              `yaml:"cardinality_limit" json:"cardinality_limit"`
 	MaxStreamsMatchersPerQuery int              `yaml:"max_streams_matchers_per_query" json:"max_streams_matchers_per_query"`
 	MaxConcurrentTailRequests  int              `yaml:"max_concurrent_tail_requests" json:"max_concurrent_tail_requests"`
+	MaxTailBytesPerSecond      flagext.ByteSize `yaml:"max_tail_bytes_per_second" json:"max_tail_bytes_per_second"`
 	MaxEntriesLimitPerQuery    int              `yaml:"max_entries_limit_per_query" json:"max_entries_limit_per_query"`
 	MaxCacheFreshness          model.Duration   `yaml:"max_cache_freshness_per_query" json:"max_cache_freshness_per_query"`
+	MaxLogResultCacheEntrySize flagext.ByteSize `yaml:"max_log_result_cache_entry_size" json:"max_log_result_cache_entry_size"`
+	MaxLogResultCacheBytes     flagext.ByteSize `yaml:"max_log_result_cache_bytes" json:"max_log_result_cache_bytes"`
 	MaxMetadataCacheFreshness  model.Duration   `yaml:"max_metadata_cache_freshness" json:"max_metadata_cache_freshness"`
 	MaxStatsCacheFreshness     model.Duration   `yaml:"max_stats_cache_freshness" json:"max_stats_cache_freshness"`
 	MaxQueriersPerTenant       uint             `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
 	MaxQueryCapacity           float64          `yaml:"max_query_capacity" json:"max_query_capacity"`
+	QueryQueueWeight           int              `yaml:"query_queue_weight" json:"query_queue_weight"`
 	QueryReadyIndexNumDays     int              `yaml:"query_ready_index_num_days" json:"query_ready_index_num_days"`
 	QueryTimeout               model.Duration   `yaml:"query_timeout" json:"query_timeout"`
 
@@ -129,8 +141,11 @@ type Limits struct {
 	MinShardingLookback              model.Duration   `yaml:"min_sharding_lookback" json:"min_sharding_lookback"`
 	MaxQueryBytesRead                flagext.ByteSize `yaml:"max_query_bytes_read" json:"max_query_bytes_read"`
 	MaxQuerierBytesRead              flagext.ByteSize `yaml:"max_querier_bytes_read" json:"max_querier_bytes_read"`
+	QueryBytesBudgetPerMinute        flagext.ByteSize `yaml:"query_bytes_budget_per_minute" json:"query_bytes_budget_per_minute"`
+	QueryBytesBudgetBurst            flagext.ByteSize `yaml:"query_bytes_budget_burst" json:"query_bytes_budget_burst"`
 	VolumeEnabled                    bool             `yaml:"volume_enabled" json:"volume_enabled" doc:"description=Enable log-volume endpoints."`
 	VolumeMaxSeries                  int              `yaml:"volume_max_series" json:"volume_max_series" doc:"description=The maximum number of aggregated series in a log-volume response"`
+	MaxAsyncQueries                  int              `yaml:"max_async_queries" json:"max_async_queries"`
 
 	// Ruler defaults and limits.
 	RulerMaxRulesPerRuleGroup   int                              `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
@@ -182,8 +197,10 @@ type Limits struct {
 	DeletionMode string `yaml:"deletion_mode" json:"deletion_mode"`
 
 	// Global and per tenant retention
-	RetentionPeriod model.Duration    `yaml:"retention_period" json:"retention_period"`
-	StreamRetention []StreamRetention `yaml:"retention_stream,omitempty" json:"retention_stream,omitempty" doc:"description=Per-stream retention to apply, if the retention is enable on the compactor side.\nExample:\n retention_stream:\n - selector: '{namespace=\"dev\"}'\n priority: 1\n period: 24h\n- selector: '{container=\"nginx\"}'\n priority: 1\n period: 744h\nSelector is a Prometheus labels matchers that will apply the 'period' retention only if the stream is matching. In case multiple stream are matching, the highest priority will be picked. If no rule is matched the 'retention_period' is used."`
+	RetentionPeriod             model.Duration                `yaml:"retention_period" json:"retention_period"`
+	StreamRetention             []StreamRetention             `yaml:"retention_stream,omitempty" json:"retention_stream,omitempty" doc:"description=Per-stream retention to apply, if the retention is enable on the compactor side.\nExample:\n retention_stream:\n - selector: '{namespace=\"dev\"}'\n priority: 1\n period: 24h\n- selector: '{container=\"nginx\"}'\n priority: 1\n period: 744h\nSelector is a Prometheus labels matchers that will apply the 'period' retention only if the stream is matching. In case multiple stream are matching, the highest priority will be picked. If no rule is matched the 'retention_period' is used."`
+	StructuredMetadataRetention []StructuredMetadataRetention `yaml:"retention_structured_metadata,omitempty" json:"retention_structured_metadata,omitempty" doc:"description=Per-line retention to apply to the log lines with matching structured metadata, if the retention is enabled on the compactor side.\nExample:\n retention_structured_metadata:\n - selector: '{namespace=\"prod\"}'\n structured_metadata: '{detected_level=\"debug\"}'\n priority: 1\n period: 72h\nThe compactor rewrites the chunks of the streams matching the selector, or of all the streams when the selector is empty, to remove the lines whose structured metadata match once they are older than 'period'. In case multiple rules are matching a line, the highest priority will be picked. The rules can only shorten the retention of the stream of the lines."`
+	ColdTierAfter               model.Duration                `yaml:"cold_tier_after" json:"cold_tier_after"`
 
 	// Config for overrides, convenient if it goes here.
 	PerTenantOverrideConfig string         `yaml:"per_tenant_override_config" json:"per_tenant_override_config"`
@@ -196,6 +213,8 @@ type Limits struct {
 
 	BlockedQueries []*validation.BlockedQuery `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty"`
 
+	RollupRules []*validation.RollupRule `yaml:"rollup_rules,omitempty" json:"rollup_rules,omitempty" doc:"description=Rollup rules to aggregate the log lines of aged chunks into metric series, if the retention is enabled on the compactor side.\nExample:\n rollup_rules:\n - name: nginx_status\n selector: '{app=\"nginx\"} | logfmt'\n by: [namespace, status]\n unwrap: duration_seconds\n resolution: 1m\n after: 720h\nThe compactor computes the count, the bytes and the sum of the unwrapped values of the lines matching the selector by the given labels at the resolution once the chunks are older than 'after', and stores them in streams with the __rollup__ label set to the name of the rule. The sum by these labels of count_over_time, rate, bytes_over_time, bytes_rate and sum_over_time queries with the same selector are answered from the rollups when the whole query range is older than 'after' plus one day."`
+
 	RequiredLabels       []string `yaml:"required_labels,omitempty" json:"required_labels,omitempty" doc:"description=Define a list of required selector labels."`
 	RequiredNumberLabels int      `yaml:"minimum_labels_number,omitempty" json:"minimum_labels_number,omitempty" doc:"description=Minimum number of label matchers a query should contain."`
 
@@ -250,6 +269,17 @@ type StreamRetention struct {
 	Matchers []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
 }
 
+// StructuredMetadataRetention is a retention rule for the log lines whose structured metadata match.
+type StructuredMetadataRetention struct {
+	Period             model.Duration `yaml:"period" json:"period" doc:"description:Retention period applied to the log lines matching the rule."`
+	Priority           int            `yaml:"priority" json:"priority" doc:"description:The larger the value, the higher the priority."`
+	Selector           string         `yaml:"selector" json:"selector" doc:"description:Stream selector expression. An empty selector matches all the streams."`
+	StructuredMetadata string         `yaml:"structured_metadata" json:"structured_metadata" doc:"description:Structured metadata matchers of the log lines, for example '{detected_level=\"debug\"}'."`
+
+	Matchers                   []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+	StructuredMetadataMatchers []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+}
+
 // LimitError are errors that do not comply with the limits specified.
 type LimitError string
 
@@ -308,6 +338,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.PerStreamRateLimit, "ingester.per-stream-rate-limit", "Maximum byte rate per second per stream, also expressible in human readable forms (1MB, 256KB, etc).")
 	_ = l.PerStreamRateLimitBurst.Set(strconv.Itoa(defaultPerStreamBurstLimit))
 	f.Var(&l.PerStreamRateLimitBurst, "ingester.per-stream-rate-limit-burst", "Maximum burst bytes per stream, also expressible in human readable forms (1MB, 256KB, etc). This is how far above the rate limit a stream can 'burst' before the stream is limited.")
+	f.Var(&l.MaxTenantMemoryBytes, "ingester.max-tenant-memory-bytes", "Maximum bytes of head blocks and unflushed chunks held in memory per user, per ingester, also expressible in human readable forms (1GB, 256MB, etc). When exceeded, the ingester applies the tenant memory limit policy. 0 to disable.")
+	f.StringVar(&l.TenantMemoryLimitPolicy, "ingester.tenant-memory-limit-policy", TenantMemoryLimitPolicyFlush, "What the ingester does when the tenant exceeds -ingester.max-tenant-memory-bytes. Supported values: 'flush' forces the flush of the largest streams of the tenant while still accepting writes, 'reject' rejects writes until enough memory is flushed.")
 
 	f.IntVar(&l.MaxChunksPerQuery, "store.query-chunk-limit", 2e6, "Maximum number of chunks that can be fetched in a single query.")
 
@@ -338,6 +370,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.CardinalityLimit, "store.cardinality-limit", 1e5, "Cardinality limit for index queries.")
 	f.IntVar(&l.MaxStreamsMatchersPerQuery, "querier.max-streams-matcher-per-query", 1000, "Maximum number of stream matchers per query.")
 	f.IntVar(&l.MaxConcurrentTailRequests, "querier.max-concurrent-tail-requests", 10, "Maximum number of concurrent tail requests.")
+	f.Var(&l.MaxTailBytesPerSecond, "querier.max-tail-bytes-per-second", "Maximum rate of log line bytes sent to the client by each tail v2 request, which is slowed down when it exceeds this rate. v1 tail requests are not limited. 0 to disable.")
 
 	_ = l.MinShardingLookback.Set("0s")
 	f.Var(&l.MinShardingLookback, "frontend.min-sharding-lookback", "Limit queries that can be sharded. Queries within the time range of now and now minus this sharding lookback are not sharded. The default value of 0s disables the lookback, causing sharding of all queries at all times.")
@@ -346,10 +379,15 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 
 	_ = l.MaxQuerierBytesRead.Set("150GB")
 	f.Var(&l.MaxQuerierBytesRead, "frontend.max-querier-bytes-read", "Max number of bytes a query can fetch after splitting and sharding. Enforced in log and metric queries only when TSDB is used. This limit is not enforced on log queries without filters. The default value of 0 disables this limit.")
+	f.Var(&l.QueryBytesBudgetPerMinute, "frontend.query-bytes-budget-per-minute", "Per-tenant budget of bytes that log and metric queries can read per minute, based on the query cost estimated from the TSDB index. Queries exceeding the remaining budget are rejected with 429. The budget is held in memory and enforced by each query frontend individually, so a tenant can read up to the budget times the number of query frontends. The default value of 0 disables this limit.")
+	f.Var(&l.QueryBytesBudgetBurst, "frontend.query-bytes-budget-burst", "Maximum number of bytes of the per-tenant query budget that can be accumulated. Queries estimated to read more than the burst consume the full burst. The default value of 0 uses the budget per minute as burst.")
 
 	_ = l.MaxCacheFreshness.Set("10m")
 	f.Var(&l.MaxCacheFreshness, "frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 
+	f.Var(&l.MaxLogResultCacheEntrySize, "frontend.max-log-result-cache-entry-size", "Maximum size of the log query results of a split interval that are stored in the log results cache. When set, log query results are cached per split interval and queries partially overlapping a cached interval only query the missing time range. Results larger than this size are not cached. The default value of 0 only caches empty log query results.")
+	f.Var(&l.MaxLogResultCacheBytes, "frontend.max-log-result-cache-bytes", "Maximum total size of the log query results of a tenant stored in the log results cache by each query frontend. When exceeded, the least recently used results of the tenant are evicted. The default value of 0 disables this limit.")
+
 	_ = l.MaxMetadataCacheFreshness.Set("24h")
 	f.Var(&l.MaxMetadataCacheFreshness, "frontend.max-metadata-cache-freshness", "Do not cache metadata request if the end time is within the frontend.max-metadata-cache-freshness window. Set this to 0 to apply no such limits. Defaults to 24h.")
 
@@ -358,6 +396,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 
 	f.UintVar(&l.MaxQueriersPerTenant, "frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
 	f.Float64Var(&l.MaxQueryCapacity, "frontend.max-query-capacity", 0, "How much of the available query capacity (\"querier\" components in distributed mode, \"read\" components in SSD mode) can be used by a single tenant. Allowed values are 0.0 to 1.0. For example, setting this to 0.5 would allow a tenant to use half of the available queriers for processing the query workload. If set to 0, query capacity is determined by frontend.max-queriers-per-tenant. When both frontend.max-queriers-per-tenant and frontend.max-query-capacity are configured, smaller value of the resulting querier replica count is considered: min(frontend.max-queriers-per-tenant, ceil(querier_replicas * frontend.max-query-capacity)). *All* queriers will handle requests for the tenant if neither limits are applied. This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL. Use this feature in a multi-tenant setup where you need to limit query capacity for certain tenants.")
+	f.IntVar(&l.QueryQueueWeight, "frontend.query-queue-weight", 1, "Weight of the tenant's queue in the query-frontend / query-scheduler relative to the queues of other tenants. Queues are dequeued using weighted round-robin, so a tenant with weight 4 gets up to four times as many requests dispatched to queriers as a tenant with weight 1 when both have queued requests. Multi-tenant queries use the smallest weight of their tenants.")
 	f.IntVar(&l.QueryReadyIndexNumDays, "store.query-ready-index-num-days", 0, "Number of days of index to be kept always downloaded for queries. Applies only to per user index in boltdb-shipper index store. 0 to disable.")
 
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 0, "Maximum number of rules per rule group per-tenant. 0 to disable.")
@@ -368,6 +407,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.RetentionPeriod.Set("0s")
 	f.Var(&l.RetentionPeriod, "store.retention", "Retention period to apply to stored data, only applies if retention_enabled is true in the compactor config. As of version 2.8.0, a zero value of 0 or 0s disables retention. In previous releases, Loki did not properly honor a zero value to disable retention and a really large value should be used instead.")
 
+	_ = l.ColdTierAfter.Set("0s")
+	f.Var(&l.ColdTierAfter, "store.cold-tier-after", "Age after which the compactor moves the chunks to the cold storage tier, only applies if the cold store is configured in the compactor config. The chunks in the cold tier have to be rehydrated before being queried. 0 disables moving chunks to the cold tier.")
+
 	_ = l.PerTenantOverridePeriod.Set("10s")
 	f.Var(&l.PerTenantOverridePeriod, "limits.per-user-override-period", "Feature renamed to 'runtime configuration'; flag deprecated in favor of -runtime-config.reload-period (runtime_config.period in YAML).")
 
@@ -428,6 +470,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	l.ShardStreams.RegisterFlagsWithPrefix("shard-streams", f)
 
 	f.IntVar(&l.VolumeMaxSeries, "limits.volume-max-series", 1000, "The default number of aggregated series or labels that can be returned from a log-volume endpoint")
+	f.IntVar(&l.MaxAsyncQueries, "frontend.max-async-queries", 5, "Maximum number of asynchronous queries per tenant that can be queued or running at the same time across all query frontends. 0 to disable the limit.")
 
 	f.BoolVar(&l.AllowStructuredMetadata, "validation.allow-structured-metadata", true, "Allow user to send structured metadata (non-indexed labels) in push payload.")
 	_ = l.MaxStructuredMetadataSize.Set(defaultMaxStructuredMetadataSize)
@@ -487,6 +530,42 @@ func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+func validateRollupRules(rules []*validation.RollupRule) error {
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" || !model.LabelValue(rule.Name).IsValid() {
+			return fmt.Errorf("invalid rollup rule name %q", rule.Name)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("duplicate rollup rule name %q", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		if _, err := syntax.ParseLogSelector(rule.Selector, true); err != nil {
+			return fmt.Errorf("invalid selector of rollup rule %q: %w", rule.Name, err)
+		}
+		for _, name := range rule.By {
+			if !model.LabelName(name).IsValid() || name == constants.RollupLabel {
+				return fmt.Errorf("invalid label %q to aggregate rollup rule %q by", name, rule.Name)
+			}
+		}
+		if rule.Unwrap != "" && !model.LabelName(rule.Unwrap).IsValid() {
+			return fmt.Errorf("invalid unwrapped label %q of rollup rule %q", rule.Unwrap, rule.Name)
+		}
+
+		if rule.Resolution == 0 {
+			rule.Resolution = model.Duration(time.Minute)
+		}
+		if rule.Resolution < 0 || (24*time.Hour)%time.Duration(rule.Resolution) != 0 {
+			return fmt.Errorf("resolution of rollup rule %q must divide 24h was %s", rule.Name, rule.Resolution)
+		}
+		if time.Duration(rule.After) < 24*time.Hour {
+			return fmt.Errorf("rollup rule %q must apply after >= 24h was %s", rule.Name, rule.After)
+		}
+	}
+	return nil
+}
+
 // Validate validates that this limits config is valid.
 func (l *Limits) Validate() error {
 	if l.StreamRetention != nil {
@@ -503,6 +582,35 @@ func (l *Limits) Validate() error {
 		}
 	}
 
+	for i, rule := range l.StructuredMetadataRetention {
+		if rule.Selector != "" {
+			matchers, err := syntax.ParseMatchers(rule.Selector, true)
+			if err != nil {
+				return fmt.Errorf("invalid labels matchers: %w", err)
+			}
+			l.StructuredMetadataRetention[i].Matchers = matchers
+		}
+		if rule.StructuredMetadata == "" {
+			return errors.New("structured metadata retention rules require structured metadata matchers")
+		}
+		matchers, err := syntax.ParseMatchers(rule.StructuredMetadata, false)
+		if err != nil {
+			return fmt.Errorf("invalid structured metadata matchers: %w", err)
+		}
+		if time.Duration(rule.Period) < 24*time.Hour {
+			return fmt.Errorf("retention period must be >= 24h was %s", rule.Period)
+		}
+		l.StructuredMetadataRetention[i].StructuredMetadataMatchers = matchers
+	}
+
+	if err := validateRollupRules(l.RollupRules); err != nil {
+		return err
+	}
+
+	if l.ColdTierAfter != 0 && time.Duration(l.ColdTierAfter) < 24*time.Hour {
+		return fmt.Errorf("cold tier after must be 0 or >= 24h was %s", l.ColdTierAfter)
+	}
+
 	if _, err := deletionmode.ParseMode(l.DeletionMode); err != nil {
 		return err
 	}
@@ -533,6 +641,12 @@ func (l *Limits) Validate() error {
 		return err
 	}
 
+	switch l.TenantMemoryLimitPolicy {
+	case "", TenantMemoryLimitPolicyFlush, TenantMemoryLimitPolicyReject:
+	default:
+		return fmt.Errorf("invalid tenant memory limit policy %q, supported values: %s, %s", l.TenantMemoryLimitPolicy, TenantMemoryLimitPolicyFlush, TenantMemoryLimitPolicyReject)
+	}
+
 	if l.TSDBMaxBytesPerShard <= 0 {
 		return errors.New("querier.tsdb-max-bytes-per-shard must be greater than 0")
 	}
@@ -644,6 +758,17 @@ func (o *Overrides) MaxLocalStreamsPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxLocalStreamsPerUser
 }
 
+// MaxTenantMemoryBytes returns the maximum bytes of head blocks and unflushed chunks a user is allowed
+// to hold in memory in a single ingester.
+func (o *Overrides) MaxTenantMemoryBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxTenantMemoryBytes.Val()
+}
+
+// TenantMemoryLimitPolicy returns what an ingester does when a user exceeds MaxTenantMemoryBytes.
+func (o *Overrides) TenantMemoryLimitPolicy(userID string) string {
+	return o.getOverridesForUser(userID).TenantMemoryLimitPolicy
+}
+
 // MaxGlobalStreamsPerUser returns the maximum number of streams a user is allowed to store
 // across the cluster.
 func (o *Overrides) MaxGlobalStreamsPerUser(userID string) int {
@@ -684,6 +809,11 @@ func (o *Overrides) MaxQueryCapacity(userID string) float64 {
 	return o.getOverridesForUser(userID).MaxQueryCapacity
 }
 
+// QueryQueueWeight returns the weight of the tenant's queue relative to the queues of other tenants.
+func (o *Overrides) QueryQueueWeight(userID string) int {
+	return o.getOverridesForUser(userID).QueryQueueWeight
+}
+
 // QueryReadyIndexNumDays returns the number of days for which we have to be query ready for a user.
 func (o *Overrides) QueryReadyIndexNumDays(userID string) int {
 	return o.getOverridesForUser(userID).QueryReadyIndexNumDays
@@ -771,11 +901,36 @@ func (o *Overrides) MaxQuerierBytesRead(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).MaxQuerierBytesRead.Val()
 }
 
+// QueryBytesBudgetPerMinute returns the bytes queries of a tenant can read per minute.
+func (o *Overrides) QueryBytesBudgetPerMinute(userID string) int {
+	return o.getOverridesForUser(userID).QueryBytesBudgetPerMinute.Val()
+}
+
+// QueryBytesBudgetBurst returns the maximum number of bytes of the query budget of a tenant that can be accumulated.
+func (o *Overrides) QueryBytesBudgetBurst(userID string) int {
+	return o.getOverridesForUser(userID).QueryBytesBudgetBurst.Val()
+}
+
+// MaxLogResultCacheEntrySize returns the maximum size of the cached log query results of a split interval.
+func (o *Overrides) MaxLogResultCacheEntrySize(userID string) int {
+	return o.getOverridesForUser(userID).MaxLogResultCacheEntrySize.Val()
+}
+
+// MaxLogResultCacheBytes returns the maximum total size of the cached log query results of a tenant.
+func (o *Overrides) MaxLogResultCacheBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxLogResultCacheBytes.Val()
+}
+
 // MaxConcurrentTailRequests returns the limit to number of concurrent tail requests.
 func (o *Overrides) MaxConcurrentTailRequests(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).MaxConcurrentTailRequests
 }
 
+// MaxTailBytesPerSecond returns the maximum rate of bytes sent by each tail v2 request.
+func (o *Overrides) MaxTailBytesPerSecond(_ context.Context, userID string) int {
+	return o.getOverridesForUser(userID).MaxTailBytesPerSecond.Val()
+}
+
 // MaxLineSize returns the maximum size in bytes the distributor should allow.
 func (o *Overrides) MaxLineSize(userID string) int {
 	return o.getOverridesForUser(userID).MaxLineSize.Val()
@@ -953,6 +1108,16 @@ func (o *Overrides) StreamRetention(userID string) []StreamRetention {
 	return o.getOverridesForUser(userID).StreamRetention
 }
 
+// StructuredMetadataRetention returns the structured metadata retention rules for a given user.
+func (o *Overrides) StructuredMetadataRetention(userID string) []StructuredMetadataRetention {
+	return o.getOverridesForUser(userID).StructuredMetadataRetention
+}
+
+// ColdTierAfter returns the age after which the chunks of a given user are moved to the cold storage tier.
+func (o *Overrides) ColdTierAfter(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).ColdTierAfter)
+}
+
 func (o *Overrides) UnorderedWrites(userID string) bool {
 	return o.getOverridesForUser(userID).UnorderedWrites
 }
@@ -965,6 +1130,10 @@ func (o *Overrides) ShardStreams(userID string) shardstreams.Config {
 	return o.getOverridesForUser(userID).ShardStreams
 }
 
+func (o *Overrides) RollupRules(_ context.Context, userID string) []*validation.RollupRule {
+	return o.getOverridesForUser(userID).RollupRules
+}
+
 func (o *Overrides) BlockedQueries(_ context.Context, userID string) []*validation.BlockedQuery {
 	return o.getOverridesForUser(userID).BlockedQueries
 }
@@ -1015,6 +1184,11 @@ func (o *Overrides) VolumeMaxSeries(userID string) int {
 	return o.getOverridesForUser(userID).VolumeMaxSeries
 }
 
+// MaxAsyncQueries returns the maximum number of queued or running asynchronous queries of a tenant.
+func (o *Overrides) MaxAsyncQueries(userID string) int {
+	return o.getOverridesForUser(userID).MaxAsyncQueries
+}
+
 func (o *Overrides) IndexGatewayShardSize(userID string) int {
 	return o.getOverridesForUser(userID).IndexGatewayShardSize
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/compression"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/util/validation"
 )
 
 func TestLimitsTagsYamlMatchJson(t *testing.T) {
@@ -360,6 +361,129 @@ func TestLimitsValidation(t *testing.T) {
 	}
 }
 
+func TestLimitsValidation_StructuredMetadataRetention(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		rule     StructuredMetadataRetention
+		expected string
+	}{
+		{
+			name: "valid rule",
+			rule: StructuredMetadataRetention{Period: model.Duration(72 * time.Hour), Selector: `{namespace="prod"}`, StructuredMetadata: `{detected_level="debug"}`},
+		},
+		{
+			name: "valid rule without selector",
+			rule: StructuredMetadataRetention{Period: model.Duration(72 * time.Hour), StructuredMetadata: `{detected_level="debug"}`},
+		},
+		{
+			name:     "missing structured metadata matchers",
+			rule:     StructuredMetadataRetention{Period: model.Duration(72 * time.Hour), Selector: `{namespace="prod"}`},
+			expected: "structured metadata retention rules require structured metadata matchers",
+		},
+		{
+			name:     "period too short",
+			rule:     StructuredMetadataRetention{Period: model.Duration(time.Hour), StructuredMetadata: `{detected_level="debug"}`},
+			expected: "retention period must be >= 24h",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limits := Limits{DeletionMode: "disabled", BloomBlockEncoding: "none"}
+			limits.TSDBShardingStrategy = logql.PowerOfTwoVersion.String()
+			limits.TSDBMaxBytesPerShard = DefaultTSDBMaxBytesPerShard
+			limits.StructuredMetadataRetention = []StructuredMetadataRetention{tc.rule}
+			err := limits.Validate()
+			if tc.expected != "" {
+				require.ErrorContains(t, err, tc.expected)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, limits.StructuredMetadataRetention[0].StructuredMetadataMatchers)
+			if tc.rule.Selector != "" {
+				require.NotEmpty(t, limits.StructuredMetadataRetention[0].Matchers)
+			}
+		})
+	}
+}
+
+func TestLimitsValidation_RollupRules(t *testing.T) {
+	validRule := func() *validation.RollupRule {
+		return &validation.RollupRule{
+			Name:     "nginx",
+			Selector: `{app="nginx"} | logfmt`,
+			By:       []string{"status"},
+			Unwrap:   "duration",
+			After:    model.Duration(30 * 24 * time.Hour),
+		}
+	}
+	for _, tc := range []struct {
+		name     string
+		rules    func() []*validation.RollupRule
+		expected string
+	}{
+		{
+			name:  "valid rule",
+			rules: func() []*validation.RollupRule { return []*validation.RollupRule{validRule()} },
+		},
+		{
+			name: "duplicate name",
+			rules: func() []*validation.RollupRule {
+				return []*validation.RollupRule{validRule(), validRule()}
+			},
+			expected: `duplicate rollup rule name "nginx"`,
+		},
+		{
+			name: "invalid selector",
+			rules: func() []*validation.RollupRule {
+				rule := validRule()
+				rule.Selector = `{app="nginx"`
+				return []*validation.RollupRule{rule}
+			},
+			expected: `invalid selector of rollup rule "nginx"`,
+		},
+		{
+			name: "aggregated by the rollup label",
+			rules: func() []*validation.RollupRule {
+				rule := validRule()
+				rule.By = []string{"__rollup__"}
+				return []*validation.RollupRule{rule}
+			},
+			expected: `invalid label "__rollup__"`,
+		},
+		{
+			name: "resolution not dividing a day",
+			rules: func() []*validation.RollupRule {
+				rule := validRule()
+				rule.Resolution = model.Duration(7 * time.Minute)
+				return []*validation.RollupRule{rule}
+			},
+			expected: `resolution of rollup rule "nginx" must divide 24h`,
+		},
+		{
+			name: "after too short",
+			rules: func() []*validation.RollupRule {
+				rule := validRule()
+				rule.After = model.Duration(time.Hour)
+				return []*validation.RollupRule{rule}
+			},
+			expected: `rollup rule "nginx" must apply after >= 24h`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limits := Limits{DeletionMode: "disabled", BloomBlockEncoding: "none"}
+			limits.TSDBShardingStrategy = logql.PowerOfTwoVersion.String()
+			limits.TSDBMaxBytesPerShard = DefaultTSDBMaxBytesPerShard
+			limits.RollupRules = tc.rules()
+			err := limits.Validate()
+			if tc.expected != "" {
+				require.ErrorContains(t, err, tc.expected)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, model.Duration(time.Minute), limits.RollupRules[0].Resolution)
+		})
+	}
+}
+
 func Test_PatternIngesterTokenizableJSONFields(t *testing.T) {
 	for _, tc := range []struct {
 		name     string
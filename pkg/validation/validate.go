@@ -29,6 +29,10 @@ const (
 	// because the limit of active streams has been reached.
 	StreamLimit         = "stream_limit"
 	StreamLimitErrorMsg = "Maximum active stream limit exceeded when trying to create stream %s, reduce the number of active streams (reduce labels or reduce label values), or contact your Loki administrator to see if the limit can be increased, user: '%s'"
+	// TenantMemoryLimit is a reason for discarding lines when the streams of the tenant hold more
+	// memory in the ingester than allowed.
+	TenantMemoryLimit         = "tenant_memory_limit"
+	TenantMemoryLimitErrorMsg = "Maximum in-memory bytes exceeded for user %s (limit: %d bytes, current: %d bytes) while attempting to ingest '%d' lines totaling '%d' bytes, reduce log volume or contact your Loki administrator to see if the limit can be increased"
 	// StreamRateLimit is a reason for discarding lines when the streams own rate limit is hit
 	// rather than the overall ingestion rate limit.
 	StreamRateLimit = "per_stream_rate_limit"
@@ -29,7 +29,7 @@ func main() {
 
 	shipper, err := indexshipper.NewIndexShipper(
 		periodCfg.IndexTables.PathPrefix,
-		conf.StorageConfig.TSDBShipperConfig,
+		conf.StorageConfig.TSDBShipperConfig.Config,
 		objectClient,
 		overrides,
 		nil,